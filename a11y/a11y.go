@@ -0,0 +1,273 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package a11y provides a accessibility audit of the rendered pages of the blog,
+// checking the order of headings, alternative text of images, ARIA landmarks
+// and the contrast of the colors of the theme (see [Contrast]). It's a optional
+// post-render stage: pages of the sitemap are requested against the
+// [http.Handler] in-process, as in the verify package, and their issues are
+// reported per page on a validation report, which fails the build when there
+// are more issues than Opts.MaxIssues:
+//
+//	if os.Args[1] == "a11y" {
+//		report := a11y.Run(ctx, blog, a11y.Opts{MaxIssues: 10, Logger: logger})
+//		for _, i := range report.Issues {
+//			fmt.Println(i)
+//		}
+//		os.Exit(report.ExitCode())
+//	}
+//
+// Checks are heuristics on the HTML of the pages, they don't replace testing
+// with assistive technologies.
+package a11y
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+
+	"forge.capytal.company/loreddev/blogo/validate"
+	"forge.capytal.company/loreddev/blogo/verify"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+// Name of the rule of the issue added when the audit has more issues than
+// Opts.MaxIssues.
+const ThresholdRule = "a11y-threshold"
+
+// A rendered page being audited.
+type Page struct {
+	// Path of the page on the site, used as the path of it's issues.
+	Path string
+	HTML []byte
+}
+
+// A audit rule, which checks a rendered page.
+type Rule interface {
+	Name() string
+	Check(p Page) []validate.Issue
+}
+
+type rule struct {
+	name  string
+	check func(p Page) []validate.Issue
+}
+
+func (r rule) Name() string {
+	return r.name
+}
+
+func (r rule) Check(p Page) []validate.Issue {
+	return r.check(p)
+}
+
+// Creates a [Rule] from a function, setting the path of the page, the name and
+// [validate.SeverityWarning] on all issues that don't have them. Issues are
+// warnings so single pages don't fail the audit, see Opts.MaxIssues.
+func NewRule(name string, check func(p Page) []validate.Issue) Rule {
+	return rule{name: name, check: func(p Page) []validate.Issue {
+		issues := check(p)
+		for i := range issues {
+			if issues[i].Path == "" {
+				issues[i].Path = p.Path
+			}
+			if issues[i].Rule == "" {
+				issues[i].Rule = name
+			}
+			if issues[i].Severity == "" {
+				issues[i].Severity = validate.SeverityWarning
+			}
+		}
+		return issues
+	}}
+}
+
+// The default rules: [Headings], [AltText], [Landmarks] and [Contrast] with
+// the [DefaultContrastPairs].
+func DefaultRules() []Rule {
+	return []Rule{Headings(), AltText(), Landmarks(), Contrast(0)}
+}
+
+type Opts struct {
+	// Rules checked on every page. Defaults to [DefaultRules].
+	Rules []Rule
+	// Max number of issues of the audit before it fails, with a issue of
+	// [ThresholdRule] and [validate.SeverityError]. Defaults to 0, so any
+	// issue fails it, negative values never fail.
+	MaxIssues int
+
+	// Path of the sitemap on the handler. Defaults to [verify.DefaultSitemap].
+	Sitemap string
+	// Number of pages requested concurrently. Defaults to 4.
+	Concurrency int
+	// Host set on the requests, see the verify package.
+	Host string
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+// Requests the sitemap from the handler, and then every URL in it, auditing the
+// pages that respond with "200 OK" and HTML. Pages that fail to be served are
+// skipped, they are reported by the verify package.
+func Run(ctx context.Context, h http.Handler, opts ...Opts) validate.Report {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Sitemap == "" {
+		opt.Sitemap = verify.DefaultSitemap
+	}
+	if opt.Concurrency <= 0 {
+		opt.Concurrency = 4
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(h, "Handler to be audited should not be nil")
+
+	log := opt.Logger.With(slog.String("sitemap", opt.Sitemap))
+	log.Debug("Auditing sitemap")
+
+	urls, err := verify.SitemapURLs(ctx, h, opt.Sitemap, opt.Host)
+	if err != nil {
+		log.Error("Failed to read sitemap", slog.String("err", err.Error()))
+		return validate.NewReport([]validate.Issue{{
+			Path:     opt.Sitemap,
+			Rule:     "sitemap",
+			Severity: validate.SeverityError,
+			Message:  "failed to read sitemap: " + err.Error(),
+		}})
+	}
+
+	pages := make([]*Page, len(urls))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, opt.Concurrency)
+
+	for i, u := range urls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			p, err := request(ctx, h, u, opt.Host)
+			if err != nil {
+				log.Debug("Skipping page", slog.String("url", u), slog.String("err", err.Error()))
+				return
+			}
+			pages[i] = p
+		}()
+	}
+	wg.Wait()
+
+	ps := make([]Page, 0, len(pages))
+	for _, p := range pages {
+		if p != nil {
+			ps = append(ps, *p)
+		}
+	}
+
+	report := Audit(ps, opt)
+
+	for _, i := range report.Issues {
+		l := log.Warn
+		if i.Severity == validate.SeverityError {
+			l = log.Error
+		}
+		l("Accessibility issue",
+			slog.String("page", i.Path),
+			slog.String("rule", i.Rule),
+			slog.String("err", i.Message))
+	}
+	log.Info("Sitemap audited",
+		slog.Int("pages", len(ps)), slog.Int("issues", len(report.Issues)))
+
+	return report
+}
+
+// Checks the pages with the rules, returning the [validate.Report] of the audit.
+// Only Opts.Rules and Opts.MaxIssues are used.
+func Audit(pages []Page, opts ...Opts) validate.Report {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Rules == nil {
+		opt.Rules = DefaultRules()
+	}
+
+	issues := []validate.Issue{}
+	for _, p := range pages {
+		for _, r := range opt.Rules {
+			issues = append(issues, r.Check(p)...)
+		}
+	}
+
+	if opt.MaxIssues >= 0 && len(issues) > opt.MaxIssues {
+		issues = append(issues, validate.Issue{
+			Path:     ".",
+			Rule:     ThresholdRule,
+			Severity: validate.SeverityError,
+			Message: fmt.Sprintf("%d accessibility issues on %d pages, above the threshold of %d",
+				len(issues), len(pages), opt.MaxIssues),
+		})
+	}
+
+	return validate.NewReport(issues)
+}
+
+func request(ctx context.Context, h http.Handler, u string, host string) (*Page, error) {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q", u)
+	}
+
+	req := httptest.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	req.URL.Path = pu.Path
+	req.URL.RawQuery = pu.RawQuery
+	req.RequestURI = pu.RequestURI()
+	switch {
+	case host != "":
+		req.Host = host
+	case pu.Host != "":
+		req.Host = pu.Host
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		return nil, fmt.Errorf("responded with status %d", w.Code)
+	}
+	if t, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type")); t != "" && t != "text/html" {
+		return nil, fmt.Errorf("content type %q is not HTML", t)
+	}
+
+	return &Page{Path: pu.Path, HTML: w.Body.Bytes()}, nil
+}
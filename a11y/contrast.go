@@ -0,0 +1,300 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package a11y
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"forge.capytal.company/loreddev/blogo/validate"
+)
+
+// Minimum contrast ratio of normal text on WCAG level AA, used by [Contrast]
+// if no minimum is provided.
+const DefaultMinContrast = 4.5
+
+// A pair of CSS custom properties of the theme, with the color of text and the
+// color of the background it's shown on.
+type ContrastPair struct {
+	Foreground string
+	Background string
+}
+
+// Common names of the colors of themes, checked by [Contrast] if no pairs are
+// provided.
+var DefaultContrastPairs = []ContrastPair{
+	{Foreground: "--fg", Background: "--bg"},
+	{Foreground: "--foreground", Background: "--background"},
+	{Foreground: "--text", Background: "--background"},
+	{Foreground: "--text-color", Background: "--background-color"},
+	{Foreground: "--color-text", Background: "--color-background"},
+}
+
+var (
+	styleElement = regexp.MustCompile(`(?is)<style\b[^>]*>(.*?)</style\s*>`)
+	cssComment   = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssVar       = regexp.MustCompile(`^var\(\s*(--[a-zA-Z0-9_-]+)\s*(?:,\s*(.+?))?\s*\)$`)
+)
+
+// Creates a [Rule] that fails for pairs of custom properties, declared on the
+// styles of the page (such as the critical CSS of the theme plugin), which
+// contrast ratio is below the minimum. Defaults to [DefaultMinContrast] and
+// [DefaultContrastPairs].
+//
+// Properties are checked on each rule that declares them, such as the
+// "prefers-color-scheme: dark" media query, with the properties of ":root" and
+// "html" as defaults. Colors that aren't opaque or not in hexadecimal, rgb()
+// or "black" and "white" aren't checked, so these are only hints of the
+// contrast of the page.
+func Contrast(min float64, pairs ...ContrastPair) Rule {
+	if min <= 0 {
+		min = DefaultMinContrast
+	}
+	if len(pairs) == 0 {
+		pairs = DefaultContrastPairs
+	}
+
+	return NewRule("contrast", func(p Page) []validate.Issue {
+		issues := []validate.Issue{}
+		seen := map[string]bool{}
+
+		rules := cssRules(p.HTML)
+
+		base := map[string]string{}
+		for _, r := range rules {
+			if r.selector == ":root" || r.selector == "html" {
+				for k, v := range r.vars {
+					base[k] = v
+				}
+			}
+		}
+
+		for _, r := range rules {
+			vars := map[string]string{}
+			for k, v := range base {
+				vars[k] = v
+			}
+			for k, v := range r.vars {
+				vars[k] = v
+			}
+
+			for _, pair := range pairs {
+				_, fok := r.vars[pair.Foreground]
+				_, bok := r.vars[pair.Background]
+				if !fok && !bok {
+					continue
+				}
+
+				fg, ok := parseColor(resolveVar(vars, vars[pair.Foreground]))
+				if !ok {
+					continue
+				}
+				bg, ok := parseColor(resolveVar(vars, vars[pair.Background]))
+				if !ok {
+					continue
+				}
+
+				ratio := contrastRatio(fg, bg)
+				if ratio >= min {
+					continue
+				}
+
+				msg := fmt.Sprintf("low contrast of %.2f:1 between %s and %s on %q, below %.1f:1",
+					ratio, pair.Foreground, pair.Background, r.selector, min)
+				if !seen[msg] {
+					seen[msg] = true
+					issues = append(issues, validate.Issue{Message: msg})
+				}
+			}
+		}
+
+		return issues
+	})
+}
+
+// A CSS rule which declares custom properties.
+type cssRule struct {
+	// Selector of the rule, prefixed by the at-rules it's nested on.
+	selector string
+	vars     map[string]string
+}
+
+// Returns the rules of the styles of the page which declare custom properties.
+func cssRules(page []byte) []cssRule {
+	rules := []cssRule{}
+
+	for _, m := range styleElement.FindAllSubmatch(commentElement.ReplaceAll(page, nil), -1) {
+		css := cssComment.ReplaceAllString(string(m[1]), "")
+
+		stack := []string{}
+		start := 0
+		for i := 0; i < len(css); i++ {
+			switch css[i] {
+			case '{':
+				prelude := css[start:i]
+				if j := strings.LastIndex(prelude, ";"); j != -1 {
+					prelude = prelude[j+1:]
+				}
+				stack = append(stack, strings.Join(strings.Fields(prelude), " "))
+				start = i + 1
+			case '}':
+				if len(stack) == 0 {
+					start = i + 1
+					continue
+				}
+				if vars := cssVars(css[start:i]); len(vars) > 0 {
+					rules = append(rules, cssRule{selector: strings.Join(stack, " "), vars: vars})
+				}
+				stack = stack[:len(stack)-1]
+				start = i + 1
+			case ';':
+				if len(stack) == 0 {
+					start = i + 1
+				}
+			}
+		}
+	}
+
+	return rules
+}
+
+// Returns the custom properties declared on the body of a rule.
+func cssVars(body string) map[string]string {
+	vars := map[string]string{}
+	for _, decl := range strings.Split(body, ";") {
+		name, value, ok := strings.Cut(decl, ":")
+		name = strings.TrimSpace(name)
+		if !ok || !strings.HasPrefix(name, "--") {
+			continue
+		}
+		value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "!important"))
+		vars[name] = value
+	}
+	return vars
+}
+
+// Resolves "var()" references of the value with the properties.
+func resolveVar(vars map[string]string, value string) string {
+	for range 8 {
+		m := cssVar.FindStringSubmatch(strings.TrimSpace(value))
+		if m == nil {
+			return value
+		}
+		v, ok := vars[m[1]]
+		if !ok {
+			v = m[2]
+		}
+		value = v
+	}
+	return value
+}
+
+// A opaque color, with the channels from 0 to 1.
+type color [3]float64
+
+// Parses colors in hexadecimal, "rgb()" and "rgba()" notation, and the "black"
+// and "white" keywords, returning false for other colors and for colors which
+// aren't opaque.
+func parseColor(s string) (color, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	switch {
+	case s == "black":
+		return color{0, 0, 0}, true
+	case s == "white":
+		return color{1, 1, 1}, true
+
+	case strings.HasPrefix(s, "#"):
+		h := s[1:]
+		if len(h) == 3 || len(h) == 4 {
+			h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]}) + strings.Repeat(string(h[3:]), 2)
+		}
+		if len(h) != 6 && len(h) != 8 {
+			return color{}, false
+		}
+		n, err := strconv.ParseUint(h, 16, 32)
+		if err != nil {
+			return color{}, false
+		}
+		if len(h) == 8 {
+			if n&0xff != 0xff {
+				return color{}, false
+			}
+			n >>= 8
+		}
+		return color{float64(n>>16&0xff) / 255, float64(n>>8&0xff) / 255, float64(n&0xff) / 255}, true
+
+	case strings.HasPrefix(s, "rgb(") || strings.HasPrefix(s, "rgba("):
+		args := s[strings.Index(s, "(")+1:]
+		args, ok := strings.CutSuffix(args, ")")
+		if !ok {
+			return color{}, false
+		}
+		fields := strings.FieldsFunc(args, func(r rune) bool { return r == ',' || r == ' ' || r == '/' })
+		if len(fields) != 3 && len(fields) != 4 {
+			return color{}, false
+		}
+
+		c := color{}
+		for i, f := range fields {
+			v, err := strconv.ParseFloat(strings.TrimSuffix(f, "%"), 64)
+			if err != nil {
+				return color{}, false
+			}
+			switch {
+			case strings.HasSuffix(f, "%"):
+				v /= 100
+			case i < 3:
+				v /= 255
+			}
+			if i == 3 {
+				if v < 1 {
+					return color{}, false
+				}
+				continue
+			}
+			c[i] = math.Min(math.Max(v, 0), 1)
+		}
+		return c, true
+	}
+
+	return color{}, false
+}
+
+// Returns the relative luminance of the color, as defined by WCAG.
+func (c color) luminance() float64 {
+	l := [3]float64{}
+	for i, v := range c {
+		if v <= 0.04045 {
+			l[i] = v / 12.92
+		} else {
+			l[i] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+	return 0.2126*l[0] + 0.7152*l[1] + 0.0722*l[2]
+}
+
+// Returns the contrast ratio of the colors, from 1 to 21.
+func contrastRatio(a, b color) float64 {
+	la, lb := a.luminance(), b.luminance()
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
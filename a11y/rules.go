@@ -0,0 +1,215 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package a11y
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"forge.capytal.company/loreddev/blogo/validate"
+)
+
+var (
+	commentElement = regexp.MustCompile(`(?s)<!--.*?-->`)
+	rawElement     = regexp.MustCompile(`(?is)<(script|style|template)\b[^>]*>.*?</(?:script|style|template)\s*>`)
+	tagPattern     = regexp.MustCompile(`(?s)<(/?)([a-zA-Z][a-zA-Z0-9-]*)\b([^>]*)>`)
+	tagAttr        = regexp.MustCompile(`(?s)\s+([a-zA-Z_:][a-zA-Z0-9_:.-]*)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+))?`)
+)
+
+// A start or end tag of a page.
+type tag struct {
+	name  string
+	end   bool
+	attrs map[string]string
+}
+
+func (t tag) attr(name string) (string, bool) {
+	v, ok := t.attrs[name]
+	return v, ok
+}
+
+// Returns the tags of the page in order, ignoring comments and the contents of
+// scripts, styles and templates.
+func tags(page []byte) []tag {
+	page = commentElement.ReplaceAll(page, nil)
+	page = rawElement.ReplaceAll(page, nil)
+
+	ts := []tag{}
+	for _, m := range tagPattern.FindAllSubmatch(page, -1) {
+		t := tag{name: strings.ToLower(string(m[2])), end: len(m[1]) > 0, attrs: map[string]string{}}
+		for _, a := range tagAttr.FindAllSubmatch(m[3], -1) {
+			name := strings.ToLower(string(a[1]))
+			if _, ok := t.attrs[name]; !ok {
+				t.attrs[name] = html.UnescapeString(strings.Trim(string(a[2]), `"'`))
+			}
+		}
+		ts = append(ts, t)
+	}
+	return ts
+}
+
+// Reports if the element is hidden from assistive technologies.
+func hidden(t tag) bool {
+	if _, ok := t.attr("hidden"); ok {
+		return true
+	}
+	v, _ := t.attr("aria-hidden")
+	return strings.EqualFold(v, "true")
+}
+
+// Creates a [Rule] that fails for pages without a "h1" heading or with more
+// than one, and for headings that skip levels, such as a "h4" after a "h2",
+// since assistive technologies use the headings to navigate the page.
+func Headings() Rule {
+	return NewRule("heading-order", func(p Page) []validate.Issue {
+		issues := []validate.Issue{}
+		h1s, prev := 0, 0
+
+		for _, t := range tags(p.HTML) {
+			if t.end || len(t.name) != 2 || t.name[0] != 'h' || t.name[1] < '1' || t.name[1] > '6' || hidden(t) {
+				continue
+			}
+			level := int(t.name[1] - '0')
+			if level == 1 {
+				h1s++
+			}
+			if prev > 0 && level > prev+1 {
+				issues = append(issues, validate.Issue{
+					Message: fmt.Sprintf("heading level skipped from h%d to h%d", prev, level),
+				})
+			}
+			prev = level
+		}
+
+		switch {
+		case h1s == 0:
+			issues = append(issues, validate.Issue{Message: "page without a h1 heading"})
+		case h1s > 1:
+			issues = append(issues, validate.Issue{Message: fmt.Sprintf("page with %d h1 headings", h1s)})
+		}
+
+		return issues
+	})
+}
+
+// Creates a [Rule] that fails for images, image inputs and image map areas
+// without a "alt" attribute. Images with a empty "alt" attribute, a
+// "presentation" role or hidden from assistive technologies are decorative, so
+// they are allowed.
+func AltText() Rule {
+	return NewRule("alt-text", func(p Page) []validate.Issue {
+		issues := []validate.Issue{}
+
+		for _, t := range tags(p.HTML) {
+			if t.end || hidden(t) {
+				continue
+			}
+
+			switch t.name {
+			case "img":
+				if role, _ := t.attr("role"); role == "presentation" || role == "none" {
+					continue
+				}
+			case "input":
+				if typ, _ := t.attr("type"); !strings.EqualFold(typ, "image") {
+					continue
+				}
+			case "area":
+				if _, ok := t.attr("href"); !ok {
+					continue
+				}
+			default:
+				continue
+			}
+
+			if _, ok := t.attr("alt"); ok {
+				continue
+			}
+			if l, _ := t.attr("aria-label"); l != "" {
+				continue
+			}
+			if l, _ := t.attr("aria-labelledby"); l != "" {
+				continue
+			}
+
+			src, _ := t.attr("src")
+			if t.name == "area" {
+				src, _ = t.attr("href")
+			}
+			issues = append(issues, validate.Issue{
+				Message: fmt.Sprintf("%s %q without alternative text", t.name, src),
+			})
+		}
+
+		return issues
+	})
+}
+
+// Elements and roles of the ARIA landmarks checked by [Landmarks].
+var landmarkRoles = map[string]string{
+	"main": "main",
+	"nav":  "navigation",
+}
+
+// Creates a [Rule] that fails for pages which don't have exactly one "main"
+// landmark, with the "main" element or role, and for pages with more than one
+// "navigation" landmark which aren't all labeled with "aria-label" or
+// "aria-labelledby", since they can't be told apart.
+func Landmarks() Rule {
+	return NewRule("landmarks", func(p Page) []validate.Issue {
+		issues := []validate.Issue{}
+		mains, navs, unlabeled := 0, 0, 0
+
+		for _, t := range tags(p.HTML) {
+			if t.end || hidden(t) {
+				continue
+			}
+
+			role, ok := t.attr("role")
+			if !ok {
+				role = landmarkRoles[t.name]
+			}
+
+			switch strings.ToLower(role) {
+			case "main":
+				mains++
+			case "navigation":
+				navs++
+				label, _ := t.attr("aria-label")
+				labelledby, _ := t.attr("aria-labelledby")
+				if label == "" && labelledby == "" {
+					unlabeled++
+				}
+			}
+		}
+
+		switch {
+		case mains == 0:
+			issues = append(issues, validate.Issue{Message: "page without a main landmark"})
+		case mains > 1:
+			issues = append(issues, validate.Issue{Message: fmt.Sprintf("page with %d main landmarks", mains)})
+		}
+		if navs > 1 && unlabeled > 0 {
+			issues = append(issues, validate.Issue{
+				Message: fmt.Sprintf("%d of %d navigation landmarks without a label", unlabeled, navs),
+			})
+		}
+
+		return issues
+	})
+}
@@ -0,0 +1,470 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package admin provides a [plugin.Middleware] serving a minimal web editor of
+// the posts of the blog, which lists and edits their source, previews changes
+// through the renderers of the engine and saves them through a
+// [plugin.WritableSourcer], so the blog can be used as a lightweight CMS.
+package admin
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/audit"
+	"forge.capytal.company/loreddev/blogo/auth"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-admin-middleware"
+
+// Creates a [plugin.Middleware] that serves the editor on Opts.Path:
+//
+//   - GET "<path>/" lists the files with one of Opts.Extensions;
+//   - GET "<path>/edit?path=PATH" shows the editor of the file at the path, or
+//     of a new file if it doesn't exist;
+//   - POST "<path>/edit" with the "path", "content" and "action" form values
+//     saves the file if the action is "save", or renders the content as if it
+//     was the file at the path if the action is "preview";
+//   - GET "<path>/audit" lists the entries of Opts.Audit, if it's set, filtered
+//     by the query values of [audit.ParseListOpts].
+//
+// Previews are rendered by the rest of the engine (see [core.WithFiles]), with
+// the file system of the sourcer with the previewed file replaced. Since this
+// file system doesn't go through the sourcer of the engine, Opts.Sourcer should
+// wrap it with the same metadata sourcers, such as the frontmatter plugin.
+//
+// All requests need to be authenticated by Opts.Authenticator, with the identity
+// having Opts.Scopes. If no authenticator is provided, all requests are rejected.
+func New(sourcer plugin.WritableSourcer, opts ...Opts) plugin.Middleware {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Path == "" {
+		opt.Path = "/.blogo/admin"
+	}
+	if opt.Scopes == nil {
+		opt.Scopes = []string{"admin"}
+	}
+	if opt.Sourcer == nil {
+		opt.Sourcer = func(s plugin.Sourcer) plugin.Sourcer { return s }
+	}
+	if opt.Extensions == nil {
+		opt.Extensions = []string{".md"}
+	}
+	if opt.ListTemplate == nil {
+		opt.ListTemplate = DefaultListTemplate
+	}
+	if opt.EditTemplate == nil {
+		opt.EditTemplate = DefaultEditTemplate
+	}
+	if opt.AuditTemplate == nil {
+		opt.AuditTemplate = DefaultAuditTemplate
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Writable sourcer should not be nil")
+
+	return &p{
+		sourcer:       sourcer,
+		authenticator: opt.Authenticator,
+		scopes:        opt.Scopes,
+
+		path:       "/" + strings.Trim(opt.Path, "/"),
+		wrap:       opt.Sourcer,
+		extensions: opt.Extensions,
+		list:       opt.ListTemplate,
+		edit:       opt.EditTemplate,
+		auditList:  opt.AuditTemplate,
+		onWrite:    opt.OnWrite,
+		audit:      opt.Audit,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Path that the editor is served on. Defaults to "/.blogo/admin".
+	Path string
+	// Authenticator of requests. If nil, all requests are rejected, so the
+	// content can't be changed by mistake.
+	Authenticator auth.Authenticator
+	// Scopes that the identity needs to have. Defaults to "admin".
+	Scopes []string
+
+	// Wraps the sourcer of the file systems of previews, so their files have the
+	// same metadata as the sourced ones. Defaults to no wrapping.
+	Sourcer func(plugin.Sourcer) plugin.Sourcer
+	// Extensions of the files that are listed and can be edited. Defaults to ".md".
+	Extensions []string
+
+	// Template of the list of files, executed with [ListInfo]. Defaults to
+	// [DefaultListTemplate].
+	ListTemplate *template.Template
+	// Template of the editor, executed with [EditInfo]. Defaults to
+	// [DefaultEditTemplate].
+	EditTemplate *template.Template
+	// Template of the audit log, executed with [AuditInfo]. Defaults to
+	// [DefaultAuditTemplate].
+	AuditTemplate *template.Template
+
+	// Log where saves of files are recorded with the "admin.save" action. By
+	// default saves are only logged.
+	Audit audit.Log
+
+	// Called with the path of each file saved, so the application can refresh
+	// the content of the blog.
+	OnWrite func(path string)
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+// Information passed to the list template.
+type ListInfo struct {
+	// Path of the editor, such as "/.blogo/admin".
+	Base  string
+	Files []File
+}
+
+// A file that can be edited.
+type File struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Information passed to the audit template.
+type AuditInfo struct {
+	// Path of the editor, such as "/.blogo/admin".
+	Base    string
+	Entries []audit.Entry
+	// URL of the page of older entries, empty if there are none.
+	Older string
+}
+
+// Information passed to the edit template.
+type EditInfo struct {
+	// Path of the editor, such as "/.blogo/admin".
+	Base    string
+	Path    string
+	Content string
+	// Reports if the file already exists, otherwise it's created when saved.
+	Exists bool
+	// Reports if the file was just saved.
+	Saved bool
+	// Error shown to the user, such as a invalid path.
+	Error string
+}
+
+type p struct {
+	sourcer       plugin.WritableSourcer
+	authenticator auth.Authenticator
+	scopes        []string
+
+	path       string
+	wrap       func(plugin.Sourcer) plugin.Sourcer
+	extensions []string
+	list       *template.Template
+	edit       *template.Template
+	auditList  *template.Template
+	onWrite    func(string)
+	audit      audit.Log
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	admin := auth.Require(p.authenticator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.serve(next, w, r)
+	}), auth.RequireOpts{
+		Scopes:     p.scopes,
+		Assertions: p.assert,
+		Logger:     p.log,
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != p.path && !strings.HasPrefix(r.URL.Path, p.path+"/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("X-Robots-Tag", "noindex")
+		w.Header().Set("Cache-Control", "no-store")
+
+		admin.ServeHTTP(w, r)
+	})
+}
+
+func (p *p) serve(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	p.assert.NotNil(p.sourcer)
+	p.assert.NotNil(p.log)
+
+	switch strings.TrimPrefix(r.URL.Path, p.path) {
+	case "", "/":
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		p.serveList(w, r)
+	case "/edit":
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			p.serveEdit(w, r)
+		case http.MethodPost:
+			if !sameOrigin(r) {
+				http.Error(w, "Cross-origin request", http.StatusForbidden)
+				return
+			}
+			p.servePost(next, w, r)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case "/audit":
+		if p.audit == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		p.serveAudit(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (p *p) serveList(w http.ResponseWriter, r *http.Request) {
+	fsys, err := p.sourcer.Source()
+	if err != nil {
+		p.log.Error("Failed to source files", slog.String("err", err.Error()))
+		http.Error(w, "Failed to source files", http.StatusBadGateway)
+		return
+	}
+
+	info := ListInfo{Base: p.path, Files: []File{}}
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !p.editable(name) {
+			return nil
+		}
+
+		f := File{Path: name}
+		if i, err := d.Info(); err == nil {
+			f.Size, f.ModTime = i.Size(), i.ModTime()
+		}
+		info.Files = append(info.Files, f)
+
+		return nil
+	})
+	if err != nil {
+		p.log.Error("Failed to list files", slog.String("err", err.Error()))
+		http.Error(w, "Failed to list files", http.StatusBadGateway)
+		return
+	}
+
+	p.execute(w, http.StatusOK, p.list, info)
+}
+
+func (p *p) serveEdit(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("path")
+	info := EditInfo{Base: p.path, Path: name, Saved: r.URL.Query().Has("saved")}
+
+	if name == "" {
+		p.execute(w, http.StatusOK, p.edit, info)
+		return
+	}
+	if !p.editable(name) {
+		info.Error = "Invalid path"
+		p.execute(w, http.StatusBadRequest, p.edit, info)
+		return
+	}
+
+	content, err := p.read(name)
+	if err == nil {
+		info.Content, info.Exists = string(content), true
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		p.log.Error("Failed to read file", slog.String("file", name), slog.String("err", err.Error()))
+		http.Error(w, "Failed to read file", http.StatusBadGateway)
+		return
+	}
+
+	p.execute(w, http.StatusOK, p.edit, info)
+}
+
+func (p *p) servePost(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 4<<20)
+
+	name := r.PostFormValue("path")
+	content := strings.ReplaceAll(r.PostFormValue("content"), "\r\n", "\n")
+
+	info := EditInfo{Base: p.path, Path: name, Content: content}
+	if !p.editable(name) {
+		info.Error = "Invalid path"
+		p.execute(w, http.StatusBadRequest, p.edit, info)
+		return
+	}
+
+	log := p.log.With(slog.String("file", name))
+
+	if r.PostFormValue("action") == "preview" {
+		servePreview(next, w, r, p.sourcer, p.wrap, name, []byte(content), log)
+		return
+	}
+
+	_, err := p.read(name)
+	created := errors.Is(err, fs.ErrNotExist)
+	switch {
+	case err == nil:
+		err = p.sourcer.Update(r.Context(), name, []byte(content))
+	case created:
+		err = p.sourcer.Create(r.Context(), name, []byte(content))
+	}
+	if err != nil {
+		log.Error("Failed to save file", slog.String("err", err.Error()))
+		info.Error = "Failed to save file"
+		p.execute(w, http.StatusBadGateway, p.edit, info)
+		return
+	}
+
+	if id, ok := auth.FromContext(r.Context()); ok {
+		log = log.With(slog.String("subject", id.Subject))
+	}
+	log.Info("File saved")
+
+	e := audit.FromRequest(r, "admin.save", name)
+	e.Details = map[string]string{"created": strconv.FormatBool(created), "size": strconv.Itoa(len(content))}
+	audit.Record(r.Context(), p.audit, e, log)
+
+	if p.onWrite != nil {
+		p.onWrite(name)
+	}
+
+	http.Redirect(w, r, p.path+"/edit?saved&path="+url.QueryEscape(name), http.StatusSeeOther)
+}
+
+func (p *p) serveAudit(w http.ResponseWriter, r *http.Request) {
+	opts, err := audit.ParseListOpts(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if opts.Limit == 0 {
+		opts.Limit = 50
+	}
+
+	entries, err := p.audit.List(r.Context(), opts)
+	if err != nil {
+		p.log.Error("Failed to list audit entries", slog.String("err", err.Error()))
+		http.Error(w, "Failed to list audit entries", http.StatusBadGateway)
+		return
+	}
+
+	info := AuditInfo{Base: p.path, Entries: entries}
+	if len(entries) > 0 && len(entries) == opts.Limit {
+		q := r.URL.Query()
+		q.Set("before", entries[len(entries)-1].Time.Format(time.RFC3339Nano))
+		info.Older = p.path + "/audit?" + q.Encode()
+	}
+
+	p.execute(w, http.StatusOK, p.auditList, info)
+}
+
+func (p *p) read(name string) ([]byte, error) {
+	fsys, err := p.sourcer.Source()
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to source files"), err)
+	}
+	return fs.ReadFile(fsys, name)
+}
+
+// Reports if the file at the path can be edited.
+func (p *p) editable(name string) bool {
+	return fs.ValidPath(name) && name != "." && slices.Contains(p.extensions, path.Ext(name))
+}
+
+func (p *p) execute(w http.ResponseWriter, status int, t *template.Template, data any) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		p.log.Error("Failed to execute admin template",
+			slog.String("template", t.Name()), slog.String("err", err.Error()))
+		http.Error(w, "Failed to render page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = buf.WriteTo(w)
+}
+
+// Reports if the request was sent by a page of the same host, so other websites
+// can't make the browser of a authenticated user change the content. Requests
+// without "Origin" or "Referer" headers, such as the ones of non-browser clients,
+// are accepted.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
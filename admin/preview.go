@@ -0,0 +1,305 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/auth"
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const previewPluginName = "blogo-admin-preview-middleware"
+
+// Creates a [plugin.Middleware] that serves "POST <Opts.Path>", rendering the
+// content of the request as if it was a file of the sourcer, through the rest of
+// the engine (see [core.WithFiles]), so editors can show exact previews of posts.
+//
+// The content is either the body of the request, if it's of type "text/markdown"
+// or "text/plain", with the path of the file on the "path" query parameter, or
+// the "content" and "path" form values. The path defaults to "preview" with the
+// first of Opts.Extensions, and the response is whatever the engine renders for
+// the file, usually the HTML of the post with it's layout.
+//
+// As with [New], Opts.Sourcer should wrap the file system with the same metadata
+// sourcers of the engine, and requests need to be authenticated by
+// Opts.Authenticator. If no authenticator is provided, all requests are rejected.
+func NewPreview(sourcer plugin.Sourcer, opts ...PreviewOpts) plugin.Middleware {
+	opt := PreviewOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Path == "" {
+		opt.Path = "/.blogo/preview"
+	}
+	if opt.Sourcer == nil {
+		opt.Sourcer = func(s plugin.Sourcer) plugin.Sourcer { return s }
+	}
+	if len(opt.Extensions) == 0 {
+		opt.Extensions = []string{".md"}
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer should not be nil")
+
+	return &preview{
+		sourcer:       sourcer,
+		authenticator: opt.Authenticator,
+		scopes:        opt.Scopes,
+
+		path:       opt.Path,
+		wrap:       opt.Sourcer,
+		extensions: opt.Extensions,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type PreviewOpts struct {
+	// Path of the endpoint. Defaults to "/.blogo/preview".
+	Path string
+	// Authenticator of requests. If nil, all requests are rejected.
+	Authenticator auth.Authenticator
+	// Scopes that the identity needs to have. By default any authenticated
+	// identity can preview content.
+	Scopes []string
+
+	// Wraps the sourcer of the file systems of previews, so their files have the
+	// same metadata as the sourced ones. Defaults to no wrapping.
+	Sourcer func(plugin.Sourcer) plugin.Sourcer
+	// Extensions of the files that can be previewed. Defaults to ".md".
+	Extensions []string
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type preview struct {
+	sourcer       plugin.Sourcer
+	authenticator auth.Authenticator
+	scopes        []string
+
+	path       string
+	wrap       func(plugin.Sourcer) plugin.Sourcer
+	extensions []string
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *preview) Name() string {
+	return previewPluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *preview) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *preview) Middleware(next http.Handler) http.Handler {
+	h := auth.Require(p.authenticator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.serve(next, w, r)
+	}), auth.RequireOpts{
+		Scopes:     p.scopes,
+		Assertions: p.assert,
+		Logger:     p.log,
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != p.path {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("X-Robots-Tag", "noindex")
+		w.Header().Set("Cache-Control", "no-store")
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (p *preview) serve(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	p.assert.NotNil(p.sourcer)
+	p.assert.NotNil(p.log)
+
+	r.Body = http.MaxBytesReader(w, r.Body, 4<<20)
+
+	var name, content string
+	switch ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct {
+	case "text/markdown", "text/plain":
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read content", http.StatusBadRequest)
+			return
+		}
+		name, content = r.URL.Query().Get("path"), string(b)
+	default:
+		name, content = r.PostFormValue("path"), r.PostFormValue("content")
+	}
+
+	if name == "" {
+		name = "preview" + p.extensions[0]
+	}
+	if !fs.ValidPath(name) || name == "." || !slices.Contains(p.extensions, path.Ext(name)) {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	servePreview(next, w, r, p.sourcer, p.wrap, name, []byte(content), p.log.With(slog.String("file", name)))
+}
+
+// Serves the content rendered as the file at the name, through the next handler,
+// with the file system of the sourcer, wrapped by wrap, with the file replaced.
+func servePreview(
+	next http.Handler,
+	w http.ResponseWriter,
+	r *http.Request,
+	sourcer plugin.Sourcer,
+	wrap func(plugin.Sourcer) plugin.Sourcer,
+	name string,
+	content []byte,
+	log *slog.Logger,
+) {
+	fsys, err := sourcer.Source()
+	if err != nil {
+		log.Error("Failed to source files", slog.String("err", err.Error()))
+		http.Error(w, "Failed to source files", http.StatusBadGateway)
+		return
+	}
+
+	fsys, err = wrap(&previewSourcer{fsys: &previewFS{FS: fsys, name: name, content: content}}).Source()
+	if err != nil {
+		log.Error("Failed to source preview", slog.String("err", err.Error()))
+		http.Error(w, "Failed to source preview", http.StatusBadGateway)
+		return
+	}
+
+	log.Debug("Serving preview")
+
+	pr := r.Clone(core.WithFiles(r.Context(), fsys))
+	pr.Method = http.MethodGet
+	pr.URL.Path, pr.URL.RawPath, pr.URL.RawQuery = "/"+name, "", ""
+	pr.Body, pr.ContentLength = http.NoBody, 0
+
+	next.ServeHTTP(w, pr)
+}
+
+type previewSourcer struct {
+	fsys fs.FS
+}
+
+func (s *previewSourcer) Name() string {
+	return pluginName
+}
+
+func (s *previewSourcer) Source() (fs.FS, error) {
+	return s.fsys, nil
+}
+
+// File system with the file at the name replaced by the content being previewed.
+type previewFS struct {
+	fs.FS
+	name    string
+	content []byte
+}
+
+func (f *previewFS) Open(name string) (fs.File, error) {
+	if name != f.name {
+		return f.FS.Open(name)
+	}
+	return &previewFile{
+		Reader: bytes.NewReader(f.content),
+		info:   previewInfo{name: path.Base(name), size: int64(len(f.content)), modTime: time.Now()},
+	}, nil
+}
+
+type previewFile struct {
+	*bytes.Reader
+	info previewInfo
+}
+
+func (f *previewFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *previewFile) Close() error {
+	return nil
+}
+
+type previewInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (i previewInfo) Name() string {
+	return i.name
+}
+
+func (i previewInfo) Size() int64 {
+	return i.size
+}
+
+func (i previewInfo) Mode() fs.FileMode {
+	return 0o444
+}
+
+func (i previewInfo) ModTime() time.Time {
+	return i.modTime
+}
+
+func (i previewInfo) IsDir() bool {
+	return false
+}
+
+func (i previewInfo) Sys() any {
+	return nil
+}
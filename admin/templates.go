@@ -0,0 +1,117 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import "html/template"
+
+// The default list of files.
+var DefaultListTemplate = template.Must(template.New("admin-list").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Posts</title>
+</head>
+<body>
+<h1>Posts</h1>
+<p><a href="{{.Base}}/edit">New post</a></p>
+<table>
+<thead><tr><th>Path</th><th>Modified</th><th>Size</th></tr></thead>
+<tbody>
+{{- range .Files}}
+<tr>
+<td><a href="{{$.Base}}/edit?path={{.Path}}">{{.Path}}</a></td>
+<td>{{if not .ModTime.IsZero}}<time datetime="{{.ModTime.Format "2006-01-02T15:04:05Z07:00"}}">{{.ModTime.Format "2006-01-02 15:04"}}</time>{{end}}</td>
+<td>{{.Size}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+// The default editor, which opens previews in a new tab.
+var DefaultEditTemplate = template.Must(template.New("admin-edit").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{if .Path}}Editing {{.Path}}{{else}}New post{{end}}</title>
+<style>
+textarea { box-sizing: border-box; width: 100%; min-height: 70vh; font-family: monospace; }
+input[name=path] { width: 100%; }
+</style>
+</head>
+<body>
+<p><a href="{{.Base}}/">All posts</a></p>
+<h1>{{if .Exists}}Editing {{.Path}}{{else}}New post{{end}}</h1>
+{{- with .Error}}
+<p role="alert"><strong>{{.}}</strong></p>
+{{- end}}
+{{- if .Saved}}
+<p role="status">Saved.</p>
+{{- end}}
+<form method="post" action="{{.Base}}/edit">
+{{- if .Exists}}
+<input type="hidden" name="path" value="{{.Path}}">
+{{- else}}
+<p><label>Path <input name="path" value="{{.Path}}" placeholder="posts/hello.md" required></label></p>
+{{- end}}
+<p><textarea name="content" spellcheck="true">{{.Content}}</textarea></p>
+<p>
+<button type="submit" name="action" value="save">Save</button>
+<button type="submit" name="action" value="preview" formtarget="_blank">Preview</button>
+</p>
+</form>
+</body>
+</html>
+`))
+
+// The default audit log, newest entries first.
+var DefaultAuditTemplate = template.Must(template.New("admin-audit").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Audit log</title>
+</head>
+<body>
+<p><a href="{{.Base}}/">All posts</a></p>
+<h1>Audit log</h1>
+<table>
+<thead><tr><th>Time</th><th>Actor</th><th>Action</th><th>Target</th><th>Details</th></tr></thead>
+<tbody>
+{{- range .Entries}}
+<tr>
+<td><time datetime="{{.Time.Format "2006-01-02T15:04:05Z07:00"}}">{{.Time.Format "2006-01-02 15:04:05"}}</time></td>
+<td>{{.Actor}}</td>
+<td>{{.Action}}</td>
+<td>{{.Target}}</td>
+<td>{{range $k, $v := .Details}}{{$k}}={{$v}} {{end}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+{{- with .Older}}
+<p><a href="{{.}}">Older entries</a></p>
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,279 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit records the admin and write operations done on the blog, such
+// as edits of the admin, Micropub posts and cache purges, on a append-only
+// [Log] persisted on a [kv.Store], so multi-author setups can know who changed
+// what and when.
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/auth"
+	"forge.capytal.company/loreddev/blogo/kv"
+	"forge.capytal.company/loreddev/blogo/ratelimit"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+// A operation recorded on the [Log].
+type Entry struct {
+	// Identifier of the entry, set by [(Log).Record].
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Subject of the identity that did the operation, empty if it was done by
+	// the application itself.
+	Actor string `json:"actor,omitempty"`
+	// Name of the operation, prefixed by the package that does it, such as
+	// "admin.save", "micropub.create" or "cache.purge".
+	Action string `json:"action"`
+	// Path of the file or other subject of the operation.
+	Target  string            `json:"target,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
+	// IP address of the request of the operation.
+	RemoteAddr string `json:"remote_addr,omitempty"`
+}
+
+// Returns a [Entry] of the operation done by the request, with the actor of the
+// identity authenticated by [auth.Require], if any, and the address of the
+// client.
+func FromRequest(r *http.Request, action, target string) Entry {
+	e := Entry{Action: action, Target: target, RemoteAddr: ratelimit.ClientIP(r)}
+	if id, ok := auth.FromContext(r.Context()); ok {
+		e.Actor = id.Subject
+	}
+	return e
+}
+
+// Append-only log of operations.
+type Log interface {
+	// Appends the entry to the log, setting it's identifier, and it's time if
+	// it's zero.
+	Record(ctx context.Context, e Entry) error
+	// Returns the entries that match the options, the newest first.
+	List(ctx context.Context, opts ListOpts) ([]Entry, error)
+}
+
+// Filters of [(Log).List].
+type ListOpts struct {
+	// Max number of entries returned. Defaults to 100.
+	Limit int
+	// Prefix of the action of the entries, such as "admin." for all the
+	// operations of the admin.
+	Action string
+	// Actor of the entries.
+	Actor string
+	// Only returns entries older than the time, used for pagination.
+	Before time.Time
+}
+
+// Creates a [Log] persisted on the store, with each entry as a key with
+// Opts.Prefix. Keys are ordered by time and never overwritten or removed by
+// the log.
+func New(s kv.Store, opts ...Opts) Log {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Prefix == "" {
+		opt.Prefix = "audit:"
+	}
+	if opt.Now == nil {
+		opt.Now = time.Now
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(s, "Store should not be nil")
+
+	return &kvLog{
+		store:  s,
+		prefix: opt.Prefix,
+		now:    opt.Now,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Prefix of the keys of the entries. Defaults to "audit:".
+	Prefix string
+	// Returns the current time. Defaults to [time.Now].
+	Now func() time.Time
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type kvLog struct {
+	store  kv.Store
+	prefix string
+	now    func() time.Time
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (l *kvLog) Record(ctx context.Context, e Entry) error {
+	l.assert.NotNil(l.store)
+	l.assert.NotNil(l.log)
+
+	if e.Time.IsZero() {
+		e.Time = l.now()
+	}
+
+	// Identifiers start with the zero-padded time, so the keys are sorted by it,
+	// followed by random bytes, so entries of the same time don't collide.
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	e.ID = fmt.Sprintf("%020d-%s", e.Time.UnixNano(), hex.EncodeToString(b))
+
+	v, err := json.Marshal(e)
+	if err != nil {
+		return errors.Join(errors.New("failed to encode audit entry"), err)
+	}
+	if err := l.store.Set(ctx, l.prefix+e.ID, v, 0); err != nil {
+		return errors.Join(errors.New("failed to record audit entry"), err)
+	}
+
+	l.log.Info("Operation recorded",
+		slog.String("action", e.Action), slog.String("actor", e.Actor), slog.String("target", e.Target))
+
+	return nil
+}
+
+func (l *kvLog) List(ctx context.Context, opts ListOpts) ([]Entry, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = 100
+	}
+
+	items, err := l.store.List(ctx, l.prefix, 0)
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to list audit entries"), err)
+	}
+
+	entries := []Entry{}
+	for _, item := range slices.Backward(items) {
+		if len(entries) >= opts.Limit {
+			break
+		}
+
+		var e Entry
+		if err := json.Unmarshal(item.Value, &e); err != nil {
+			l.log.Warn("Ignoring malformed audit entry", slog.String("key", item.Key), slog.String("err", err.Error()))
+			continue
+		}
+
+		if !strings.HasPrefix(e.Action, opts.Action) ||
+			(opts.Actor != "" && e.Actor != opts.Actor) ||
+			(!opts.Before.IsZero() && !e.Time.Before(opts.Before)) {
+			continue
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// Records the entry on the log, logging the error if it fails, since failing to
+// audit a operation that was already done shouldn't fail it's response. Does
+// nothing if the log is nil, so plugins can call it with optional logs.
+func Record(ctx context.Context, l Log, e Entry, logger *slog.Logger) {
+	if l == nil {
+		return
+	}
+	if err := l.Record(ctx, e); err != nil && logger != nil {
+		logger.Error("Failed to record audit entry",
+			slog.String("action", e.Action), slog.String("err", err.Error()))
+	}
+}
+
+// Creates a [http.Handler] that responds with the entries of the log as JSON,
+// filtered by the "limit", "action", "actor" and "before" (RFC 3339) query
+// values, authenticated by the authenticator with the scopes, which default to
+// "admin". If the authenticator is nil, all requests are rejected.
+func NewHandler(l Log, authenticator auth.Authenticator, scopes ...string) http.Handler {
+	if len(scopes) == 0 {
+		scopes = []string{"admin"}
+	}
+
+	return auth.Require(authenticator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		opts, err := ParseListOpts(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		entries, err := l.List(r.Context(), opts)
+		if err != nil {
+			http.Error(w, "Failed to list audit entries", http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(entries)
+	}), auth.RequireOpts{Scopes: scopes})
+}
+
+// Returns the [ListOpts] of the "limit", "action", "actor" and "before" query
+// values of the request.
+func ParseListOpts(r *http.Request) (ListOpts, error) {
+	q := r.URL.Query()
+	opts := ListOpts{Action: q.Get("action"), Actor: q.Get("actor")}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return opts, errors.New(`The "limit" value should be a positive integer`)
+		}
+		opts.Limit = n
+	}
+	if v := q.Get("before"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return opts, errors.New(`The "before" value should be a RFC 3339 time`)
+		}
+		opts.Before = t
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,239 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package auth provides the authentication of protected areas of the blog, such
+// as raw source downloads and administration endpoints, through the [Authenticator]
+// interface, so plugins don't depend on a specific method of authentication.
+//
+// Use [Require] to protect a [http.Handler], and [FromContext] to get the
+// [Identity] of the authenticated request inside it.
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+var (
+	// Returned by authenticators if the request doesn't have credentials.
+	ErrUnauthenticated = errors.New("request is not authenticated")
+	// Returned by authenticators if the credentials of the request are invalid.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// The authenticated user of a request.
+type Identity struct {
+	// Unique identifier of the user, such as a username or a URL.
+	Subject string
+	Name    string
+	// Scopes granted to the user, such as "create" or "admin".
+	Scopes []string
+}
+
+// Reports if the identity has the scope.
+func (i Identity) HasScope(scope string) bool {
+	return slices.Contains(i.Scopes, scope)
+}
+
+// Authenticates requests, returning [ErrUnauthenticated] if the request doesn't
+// have credentials and [ErrInvalidCredentials] (or other errors) if they are
+// not valid.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Identity, error)
+}
+
+// Type adapter to allow the use of ordinary functions as [Authenticator] implementations.
+type AuthenticatorFunc func(r *http.Request) (Identity, error)
+
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (Identity, error) {
+	return f(r)
+}
+
+// Authenticators that ask clients for credentials, returning the value of the
+// "WWW-Authenticate" header of unauthenticated responses.
+type Challenger interface {
+	Challenge() string
+}
+
+// Creates a [Authenticator] of HTTP Basic authentication, with the passwords of
+// each username. Identities have the username as subject and no scopes.
+func Basic(realm string, users map[string]string) Authenticator {
+	return &basic{realm: realm, users: users}
+}
+
+type basic struct {
+	realm string
+	users map[string]string
+}
+
+func (a *basic) Authenticate(r *http.Request) (Identity, error) {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return Identity{}, ErrUnauthenticated
+	}
+
+	expected, ok := a.users[user]
+	if !ok {
+		// Compare anyway, so the response time doesn't reveal existing users.
+		expected = pass + "-"
+	}
+	if subtle.ConstantTimeCompare([]byte(pass), []byte(expected)) != 1 {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	return Identity{Subject: user, Name: user, Scopes: []string{}}, nil
+}
+
+func (a *basic) Challenge() string {
+	return `Basic realm="` + strings.ReplaceAll(a.realm, `"`, `'`) + `", charset="UTF-8"`
+}
+
+// Creates a [Authenticator] of static bearer tokens, sent on the "Authorization"
+// header, with the identity of each token.
+func Bearer(tokens map[string]Identity) Authenticator {
+	return &bearer{tokens: tokens}
+}
+
+type bearer struct {
+	tokens map[string]Identity
+}
+
+func (a *bearer) Authenticate(r *http.Request) (Identity, error) {
+	token, ok := BearerToken(r)
+	if !ok {
+		return Identity{}, ErrUnauthenticated
+	}
+
+	for t, id := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return id, nil
+		}
+	}
+
+	return Identity{}, ErrInvalidCredentials
+}
+
+func (a *bearer) Challenge() string {
+	return "Bearer"
+}
+
+// Returns the bearer token of the "Authorization" header of the request.
+func BearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// Creates a [Authenticator] that tries each authenticator in order, returning
+// the first identity. Requests without credentials for any of them return
+// [ErrUnauthenticated].
+func Any(authenticators ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (Identity, error) {
+		for _, a := range authenticators {
+			id, err := a.Authenticate(r)
+			if errors.Is(err, ErrUnauthenticated) {
+				continue
+			}
+			return id, err
+		}
+		return Identity{}, ErrUnauthenticated
+	})
+}
+
+type contextKey struct{}
+
+// Returns a copy of the context with the identity, as done by [Require].
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// Returns the identity of the context of a request authenticated by [Require].
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(contextKey{}).(Identity)
+	return id, ok
+}
+
+type RequireOpts struct {
+	// Scopes that the identity needs to have, otherwise the request is
+	// responded with "403 Forbidden".
+	Scopes []string
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+// Creates a [http.Handler] that only calls next for requests authenticated by
+// the authenticator, with the [Identity] set on the request's context. Other
+// requests are responded with "401 Unauthorized", with the challenge of the
+// authenticator if it implements [Challenger].
+//
+// If the authenticator is nil, all requests are rejected, so a missing
+// configuration never exposes protected content.
+func Require(a Authenticator, next http.Handler, opts ...RequireOpts) http.Handler {
+	opt := RequireOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(next, "Protected handler should not be nil")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := opt.Logger.With(slog.String("path", r.URL.Path))
+
+		if a == nil {
+			log.Warn("No authenticator configured, rejecting request")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := a.Authenticate(r)
+		if err != nil {
+			log.Debug("Request not authenticated", slog.String("err", err.Error()))
+			if c, ok := a.(Challenger); ok {
+				w.Header().Set("WWW-Authenticate", c.Challenge())
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		for _, s := range opt.Scopes {
+			if !id.HasScope(s) {
+				log.Debug("Identity missing scope",
+					slog.String("subject", id.Subject), slog.String("scope", s))
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+	})
+}
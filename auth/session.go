@@ -0,0 +1,180 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// A [Authenticator] of signed session cookies, which keep the identity of users
+// that logged in through a login flow, such as the ones of the indieauth and
+// oidc packages, so they don't need to authenticate on every request.
+type Sessions interface {
+	Authenticator
+	// Sets the session cookie of the identity on the response.
+	Login(w http.ResponseWriter, id Identity) error
+	// Removes the session cookie, if any.
+	Logout(w http.ResponseWriter)
+}
+
+// Creates [Sessions] with cookies signed with the key, which should be at least
+// 32 random bytes and kept secret, since anyone with it can create sessions.
+//
+// Sessions aren't stored on the server, so they can't be revoked before they
+// expire, other than by changing the key.
+func NewSessions(key []byte, opts ...SessionsOpts) Sessions {
+	opt := SessionsOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Cookie == "" {
+		opt.Cookie = "blogo-session"
+	}
+	if opt.MaxAge == 0 {
+		opt.MaxAge = 7 * 24 * time.Hour
+	}
+	if opt.Path == "" {
+		opt.Path = "/"
+	}
+
+	return &sessions{
+		key:      key,
+		cookie:   opt.Cookie,
+		maxAge:   opt.MaxAge,
+		path:     opt.Path,
+		insecure: opt.Insecure,
+	}
+}
+
+type SessionsOpts struct {
+	// Name of the session cookie. Defaults to "blogo-session".
+	Cookie string
+	// Duration of sessions. Defaults to 7 days.
+	MaxAge time.Duration
+	// Path of the session cookie. Defaults to "/".
+	Path string
+	// Allows the cookie to be sent over plain HTTP, for local development. By
+	// default cookies are only sent over HTTPS.
+	Insecure bool
+}
+
+type sessions struct {
+	key      []byte
+	cookie   string
+	maxAge   time.Duration
+	path     string
+	insecure bool
+}
+
+// Contents of a session cookie.
+type session struct {
+	Subject string   `json:"sub"`
+	Name    string   `json:"name,omitempty"`
+	Scopes  []string `json:"scopes,omitempty"`
+	Expires int64    `json:"exp"`
+}
+
+func (s *sessions) Authenticate(r *http.Request) (Identity, error) {
+	c, err := r.Cookie(s.cookie)
+	if err != nil || c.Value == "" {
+		return Identity{}, ErrUnauthenticated
+	}
+
+	payload, sig, ok := strings.Cut(c.Value, ".")
+	if !ok {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	expected := s.sign(payload)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	var ses session
+	if err := json.Unmarshal(b, &ses); err != nil {
+		return Identity{}, ErrInvalidCredentials
+	}
+	if time.Now().Unix() > ses.Expires {
+		return Identity{}, errors.Join(ErrInvalidCredentials, errors.New("session expired"))
+	}
+
+	scopes := ses.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	return Identity{Subject: ses.Subject, Name: ses.Name, Scopes: scopes}, nil
+}
+
+func (s *sessions) Login(w http.ResponseWriter, id Identity) error {
+	expires := time.Now().Add(s.maxAge)
+
+	b, err := json.Marshal(session{
+		Subject: id.Subject,
+		Name:    id.Name,
+		Scopes:  id.Scopes,
+		Expires: expires.Unix(),
+	})
+	if err != nil {
+		return errors.Join(errors.New("failed to marshal session"), err)
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(b)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.cookie,
+		Value:    payload + "." + s.sign(payload),
+		Path:     s.path,
+		Expires:  expires,
+		MaxAge:   int(s.maxAge.Seconds()),
+		Secure:   !s.insecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+func (s *sessions) Logout(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.cookie,
+		Value:    "",
+		Path:     s.path,
+		MaxAge:   -1,
+		Secure:   !s.insecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (s *sessions) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	_, _ = mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,82 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"time"
+)
+
+// A cookie that binds the state of a login flow, such as the "state" parameter
+// of OAuth, to the browser that started it. Without it, anyone that finishes the
+// flow with their own account can send the callback URL to other users, logging
+// them in as the attacker (login CSRF).
+//
+// The cookie holds a hash of the state, so it isn't sent to the provider.
+type StateCookie struct {
+	// Name of the cookie.
+	Name string
+	// Path of the cookie, which should include the callback endpoint.
+	Path string
+	// Duration of the cookie, the time users have to finish logging in.
+	MaxAge time.Duration
+	// Allows the cookie to be sent over plain HTTP, for local development. By
+	// default cookies are only sent over HTTPS.
+	Insecure bool
+}
+
+// Sets the cookie of the state on the response.
+func (c StateCookie) Set(w http.ResponseWriter, state string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     c.Name,
+		Value:    hashState(state),
+		Path:     c.Path,
+		Expires:  time.Now().Add(c.MaxAge),
+		MaxAge:   int(c.MaxAge.Seconds()),
+		Secure:   !c.Insecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Reports if the cookie of the request is of the state, removing it so it can't
+// be used again.
+func (c StateCookie) Verify(w http.ResponseWriter, r *http.Request, state string) bool {
+	cookie, err := r.Cookie(c.Name)
+	if err != nil {
+		return false
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     c.Name,
+		Value:    "",
+		Path:     c.Path,
+		MaxAge:   -1,
+		Secure:   !c.Insecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return state != "" && subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(hashState(state))) == 1
+}
+
+func hashState(state string) string {
+	h := sha256.Sum256([]byte(state))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
@@ -0,0 +1,56 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/auth"
+)
+
+func TestStateCookie(t *testing.T) {
+	c := auth.StateCookie{Name: "state", Path: "/login", MaxAge: time.Minute}
+
+	w := httptest.NewRecorder()
+	c.Set(w, "abc")
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value == "abc" || !cookies[0].HttpOnly {
+		t.Fatalf("expected a HttpOnly cookie with the hash of the state, got %v", cookies)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		cookie bool
+		state  string
+		want   bool
+	}{
+		{name: "same state", cookie: true, state: "abc", want: true},
+		{name: "other state", cookie: true, state: "abd", want: false},
+		{name: "empty state", cookie: true, state: "", want: false},
+		{name: "no cookie", cookie: false, state: "abc", want: false},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/login/callback", nil)
+		if tc.cookie {
+			r.AddCookie(cookies[0])
+		}
+		if got := c.Verify(httptest.NewRecorder(), r, tc.state); got != tc.want {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,403 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package backup periodically archives the sources of the blog, and the
+// metadata of it's index, to a [Destination] such as a local directory or a S3
+// bucket, so the blog can be restored if the upstream content store, like a
+// forge, is lost. Old backups are deleted following a retention policy of the
+// number and age of backups to keep.
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/export/archive"
+	"forge.capytal.company/loreddev/blogo/index"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/schedule"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+// A stored backup.
+type Backup struct {
+	// Name of the backup on the destination.
+	Name string
+	// Time when the backup was made.
+	Time time.Time
+	// Size of the backup in bytes.
+	Size int64
+}
+
+// Where backups are stored, such as [Dir] and [S3].
+type Destination interface {
+	// Stores the backup with the name, reading it's contents from r.
+	Store(ctx context.Context, name string, r io.Reader) error
+	// Lists all stored backups. The time of the returned backups may be zero
+	// if the destination doesn't know it.
+	List(ctx context.Context) ([]Backup, error)
+	// Deletes the backup with the name.
+	Delete(ctx context.Context, name string) error
+}
+
+// Makes backups of the sources of a sourcer.
+type Scheduler interface {
+	// Makes a backup of the current sources and deletes the backups that are
+	// out of the retention policy.
+	Backup(ctx context.Context) (Backup, error)
+	// Makes a backup right away and then one at every interval, until the
+	// context is done. Failed backups are logged and don't stop the schedule.
+	Run(ctx context.Context) error
+}
+
+// Creates a [Scheduler] of backups of the file systems of the sourcer, stored
+// on the destination. Backups are named with the prefix and the time they were
+// made, so only backups with the prefix are subject to the retention policy.
+func New(sourcer plugin.Sourcer, dest Destination, opts ...Opts) Scheduler {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Interval == 0 {
+		opt.Interval = 24 * time.Hour
+	}
+	if opt.Keep == 0 {
+		opt.Keep = 7
+	}
+	if opt.Prefix == "" {
+		opt.Prefix = "blogo-"
+	}
+	if opt.Format == "" {
+		opt.Format = archive.TarGz
+	}
+	if opt.IndexName == "" {
+		opt.IndexName = ".blogo/index.json"
+	}
+	if opt.Now == nil {
+		opt.Now = time.Now
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer should not be nil")
+	opt.Assertions.NotNil(dest, "Destination should not be nil")
+
+	return &scheduler{
+		sourcer: sourcer,
+		dest:    dest,
+
+		interval:  opt.Interval,
+		keep:      opt.Keep,
+		maxAge:    opt.MaxAge,
+		prefix:    opt.Prefix,
+		format:    opt.Format,
+		filter:    opt.Filter,
+		index:     opt.Index,
+		indexName: opt.IndexName,
+		onBackup:  opt.OnBackup,
+		now:       opt.Now,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Interval between backups of [Scheduler.Run]. Defaults to 24 hours.
+	Interval time.Duration
+	// Number of backups to keep, deleting the oldest ones. Defaults to 7,
+	// negative values keep all backups.
+	Keep int
+	// Backups older than this are deleted, even if there are less than Keep
+	// backups. The newest backup is always kept. Zero disables it.
+	MaxAge time.Duration
+
+	// Prefix of the names of the backups. Defaults to "blogo-".
+	Prefix string
+	// Format of the archives of the backups. Defaults to [archive.TarGz].
+	Format archive.Format
+	// Filters which files are backed up, by their path. By default all are.
+	Filter func(path string) bool
+
+	// Index whose entries are saved on the backups, with their URL, title,
+	// dates and tags, so the metadata is kept even if the sources are later
+	// restored from somewhere else. Not saved if nil.
+	Index index.Provider
+	// Name of the file of the index on the archives. Defaults to ".blogo/index.json".
+	IndexName string
+
+	// Called after each backup, with the error of it.
+	OnBackup func(b Backup, err error)
+	// Clock used for the times of backups. Defaults to [time.Now].
+	Now func() time.Time
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type scheduler struct {
+	sourcer plugin.Sourcer
+	dest    Destination
+
+	interval  time.Duration
+	keep      int
+	maxAge    time.Duration
+	prefix    string
+	format    archive.Format
+	filter    func(path string) bool
+	index     index.Provider
+	indexName string
+	onBackup  func(b Backup, err error)
+	now       func() time.Time
+
+	// Serializes backups, so a backup triggered while the schedule is running
+	// doesn't race it's pruning.
+	mu sync.Mutex
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+// Format of the time on the names of the backups.
+const timeFormat = "20060102T150405Z"
+
+// Saved metadata of a indexed file.
+type indexEntry struct {
+	Path    string    `json:"path"`
+	URL     string    `json:"url"`
+	Title   string    `json:"title,omitempty"`
+	Summary string    `json:"summary,omitempty"`
+	Date    time.Time `json:"date"`
+	Updated time.Time `json:"updated"`
+	Tags    []string  `json:"tags,omitempty"`
+}
+
+func (s *scheduler) Backup(ctx context.Context) (Backup, error) {
+	s.assert.NotNil(ctx)
+	s.assert.NotNil(s.sourcer)
+	s.assert.NotNil(s.dest)
+	s.assert.NotNil(s.log)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := s.backup(ctx)
+	if s.onBackup != nil {
+		s.onBackup(b, err)
+	}
+	if err != nil {
+		s.log.Error("Failed to backup sources", slog.String("err", err.Error()))
+		return b, err
+	}
+
+	s.log.Info("Backed up sources",
+		slog.String("name", b.Name), slog.Int64("size", b.Size))
+
+	if err := s.prune(ctx, b); err != nil {
+		s.log.Error("Failed to delete old backups", slog.String("err", err.Error()))
+		return b, err
+	}
+
+	return b, nil
+}
+
+func (s *scheduler) backup(ctx context.Context) (Backup, error) {
+	fsys, err := s.sourcer.Source()
+	if err != nil {
+		return Backup{}, errors.Join(errors.New("failed to source files"), err)
+	}
+
+	now := s.now().UTC()
+	b := Backup{
+		Name: s.prefix + now.Format(timeFormat) + "." + string(s.format),
+		Time: now,
+	}
+
+	opt := archive.Opts{
+		Format:  s.format,
+		Filter:  s.filter,
+		ModTime: now,
+		Logger:  s.log,
+	}
+
+	if s.index != nil {
+		data, err := s.encodeIndex()
+		if err != nil {
+			return b, err
+		}
+		opt.Files = map[string][]byte{s.indexName: data}
+	}
+
+	// The archive is streamed to the destination, so the backup doesn't need
+	// to be kept in memory by destinations that don't need it's size first.
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(archive.WriteSources(pw, fsys, opt))
+	}()
+
+	r := &countingReader{r: pr}
+	err = s.dest.Store(ctx, b.Name, r)
+	_ = pr.CloseWithError(errors.New("backup aborted"))
+	if err != nil {
+		return b, errors.Join(fmt.Errorf("failed to store backup %q", b.Name), err)
+	}
+	b.Size = r.n
+
+	return b, nil
+}
+
+func (s *scheduler) encodeIndex() ([]byte, error) {
+	idx, err := s.index.Index()
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to get index"), err)
+	}
+
+	entries := idx.Entries()
+	es := make([]indexEntry, len(entries))
+	for i, e := range entries {
+		es[i] = indexEntry{
+			Path:    e.Path,
+			URL:     e.URL,
+			Title:   e.Title,
+			Summary: e.Summary,
+			Date:    e.Date,
+			Updated: e.Updated,
+			Tags:    e.Tags,
+		}
+	}
+
+	data, err := json.MarshalIndent(es, "", "\t")
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to encode index"), err)
+	}
+	return data, nil
+}
+
+// Deletes the backups out of the retention policy, other than the current one.
+func (s *scheduler) prune(ctx context.Context, current Backup) error {
+	bs, err := s.dest.List(ctx)
+	if err != nil {
+		return errors.Join(errors.New("failed to list backups"), err)
+	}
+
+	// Only backups named by the scheduler are considered, so other files on
+	// the destination are never deleted.
+	owned := make([]Backup, 0, len(bs))
+	for _, b := range bs {
+		t, ok := s.parseName(b.Name)
+		if !ok {
+			continue
+		}
+		b.Time = t
+		owned = append(owned, b)
+	}
+	slices.SortFunc(owned, func(a, b Backup) int {
+		return b.Time.Compare(a.Time)
+	})
+
+	now := s.now()
+	var errs []error
+	for i, b := range owned {
+		if b.Name == current.Name || i == 0 {
+			continue
+		}
+
+		expired := s.maxAge > 0 && now.Sub(b.Time) > s.maxAge
+		if !expired && (s.keep < 0 || i < s.keep) {
+			continue
+		}
+
+		if err := s.dest.Delete(ctx, b.Name); err != nil {
+			errs = append(errs, errors.Join(fmt.Errorf("failed to delete backup %q", b.Name), err))
+			continue
+		}
+		s.log.Debug("Deleted old backup", slog.String("name", b.Name))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Returns the time of the backup of the name, if it was named by the scheduler.
+func (s *scheduler) parseName(name string) (time.Time, bool) {
+	ext := "." + string(s.format)
+	if !strings.HasPrefix(name, s.prefix) || !strings.HasSuffix(name, ext) {
+		return time.Time{}, false
+	}
+
+	v := strings.TrimSuffix(strings.TrimPrefix(name, s.prefix), ext)
+	t, err := time.Parse(timeFormat, v)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func (s *scheduler) Run(ctx context.Context) error {
+	s.assert.NotNil(ctx)
+
+	t := time.NewTicker(s.interval)
+	defer t.Stop()
+
+	for {
+		// Errors are logged by Backup, the schedule continues on the next
+		// interval.
+		_, _ = s.Backup(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// Returns a [schedule.Job] named "backup" that makes backups with the scheduler
+// on the schedule, so backups can run on a [schedule.Scheduler] alongside the
+// other jobs of the application, instead of [Scheduler.Run].
+func Job(s Scheduler, sched schedule.Schedule) schedule.Job {
+	return schedule.Job{
+		Name:     "backup",
+		Schedule: sched,
+		Run: func(ctx context.Context) error {
+			_, err := s.Backup(ctx)
+			return err
+		},
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
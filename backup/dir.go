@@ -0,0 +1,120 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Creates a [Destination] that stores backups as files on the local directory,
+// creating it if it doesn't exist. Files are written to a temporary file first,
+// so a failed backup never leaves a partial file behind.
+func Dir(dir string) Destination {
+	return &dirDestination{dir: dir}
+}
+
+type dirDestination struct {
+	dir string
+}
+
+func (d *dirDestination) Store(ctx context.Context, name string, r io.Reader) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(d.dir, 0o755); err != nil {
+		return errors.Join(errors.New("failed to create directory"), err)
+	}
+
+	f, err := os.CreateTemp(d.dir, ".tmp-"+name+"-*")
+	if err != nil {
+		return errors.Join(errors.New("failed to create temporary file"), err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, &contextReader{ctx: ctx, r: r}); err != nil {
+		_ = f.Close()
+		return errors.Join(errors.New("failed to write file"), err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Join(errors.New("failed to close file"), err)
+	}
+
+	if err := os.Rename(f.Name(), filepath.Join(d.dir, name)); err != nil {
+		return errors.Join(errors.New("failed to rename file"), err)
+	}
+
+	return nil
+}
+
+func (d *dirDestination) List(ctx context.Context) ([]Backup, error) {
+	es, err := os.ReadDir(d.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Backup{}, nil
+	} else if err != nil {
+		return nil, errors.Join(errors.New("failed to read directory"), err)
+	}
+
+	bs := make([]Backup, 0, len(es))
+	for _, e := range es {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".tmp-") {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		bs = append(bs, Backup{Name: e.Name(), Time: info.ModTime(), Size: info.Size()})
+	}
+
+	return bs, nil
+}
+
+func (d *dirDestination) Delete(ctx context.Context, name string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(d.dir, name))
+}
+
+// Returns a error if the name isn't a single element of a path, so backups
+// can't be written outside of the destination.
+func validName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return errors.New("invalid backup name")
+	}
+	return nil
+}
+
+// Stops reading when the context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,339 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/httpclient"
+)
+
+// Creates a [Destination] that stores backups as objects of a S3 bucket, or of
+// any service compatible with it's API, such as MinIO or Cloudflare R2.
+// Requests are signed with AWS Signature Version 4 and use path-style URLs
+// (the bucket as the first element of the path), so custom endpoints don't
+// need wildcard DNS.
+//
+// Backups are read into memory before being uploaded, since the requests need
+// the hash and length of their body.
+func S3(bucket string, opts ...S3Opts) Destination {
+	opt := S3Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Region == "" {
+		opt.Region = "us-east-1"
+	}
+	if opt.Endpoint == "" {
+		opt.Endpoint = "https://s3." + opt.Region + ".amazonaws.com"
+	}
+	if opt.HTTPClient == nil {
+		opt.HTTPClient = httpclient.Default()
+	}
+	if opt.Now == nil {
+		opt.Now = time.Now
+	}
+
+	return &s3Destination{
+		endpoint: strings.TrimSuffix(opt.Endpoint, "/"),
+		bucket:   bucket,
+		prefix:   opt.Prefix,
+		region:   opt.Region,
+
+		accessKeyID:     opt.AccessKeyID,
+		secretAccessKey: opt.SecretAccessKey,
+		sessionToken:    opt.SessionToken,
+
+		client: opt.HTTPClient,
+		now:    opt.Now,
+	}
+}
+
+type S3Opts struct {
+	// Base URL of the API. Defaults to "https://s3.<region>.amazonaws.com".
+	Endpoint string
+	// Region of the bucket. Defaults to "us-east-1".
+	Region string
+	// Prefix of the keys of the objects, such as "backups/".
+	Prefix string
+
+	// Credentials of the requests, which can be resolved from the environment
+	// or files with the secrets package.
+	AccessKeyID     string
+	SecretAccessKey string
+	// Token of temporary credentials, sent as the "X-Amz-Security-Token" header.
+	SessionToken string
+
+	HTTPClient *http.Client
+	// Clock used to sign requests. Defaults to [time.Now].
+	Now func() time.Time
+}
+
+type s3Destination struct {
+	endpoint string
+	bucket   string
+	prefix   string
+	region   string
+
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+
+	client *http.Client
+	now    func() time.Time
+}
+
+func (d *s3Destination) Store(ctx context.Context, name string, r io.Reader) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return errors.Join(errors.New("failed to read backup"), err)
+	}
+
+	res, err := d.do(ctx, http.MethodPut, d.prefix+name, nil, body)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+
+	return nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (d *s3Destination) List(ctx context.Context) ([]Backup, error) {
+	bs := []Backup{}
+
+	token := ""
+	for {
+		q := url.Values{}
+		q.Set("list-type", "2")
+		if d.prefix != "" {
+			q.Set("prefix", d.prefix)
+		}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+
+		res, err := d.do(ctx, http.MethodGet, "", q, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result listBucketResult
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		_ = res.Body.Close()
+		if err != nil {
+			return nil, errors.Join(errors.New("failed to decode list of objects"), err)
+		}
+
+		for _, o := range result.Contents {
+			name := strings.TrimPrefix(o.Key, d.prefix)
+			if validName(name) != nil {
+				continue
+			}
+			bs = append(bs, Backup{Name: name, Time: o.LastModified, Size: o.Size})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return bs, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (d *s3Destination) Delete(ctx context.Context, name string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+
+	res, err := d.do(ctx, http.MethodDelete, d.prefix+name, nil, nil)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+
+	return nil
+}
+
+// Makes a signed request to the object of the key, or to the bucket if the key
+// is empty, returning a error if the response isn't successful.
+func (d *s3Destination) do(
+	ctx context.Context,
+	method, key string,
+	query url.Values,
+	body []byte,
+) (*http.Response, error) {
+	p := "/" + uriEncode(d.bucket, false)
+	if key != "" {
+		p += "/" + uriEncode(key, true)
+	}
+
+	u := d.endpoint + p
+	if q := canonicalQuery(query); q != "" {
+		u += "?" + q
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to create request"), err)
+	}
+
+	d.sign(req, body)
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to %s %q", method, key), err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		_ = res.Body.Close()
+		return nil, fmt.Errorf("failed to %s %q, bucket responded with %s: %s",
+			method, key, res.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return res, nil
+}
+
+// Signs the request with AWS Signature Version 4.
+func (d *s3Destination) sign(req *http.Request, body []byte) {
+	now := d.now().UTC()
+	date := now.Format("20060102")
+	amzDate := now.Format("20060102T150405Z")
+
+	hash := sha256.Sum256(body)
+	payload := hex.EncodeToString(hash[:])
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	if d.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", d.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	slices.Sort(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payload,
+	}, "\n")
+
+	scope := date + "/" + d.region + "/s3/aws4_request"
+	crHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(crHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+d.secretAccessKey), date)
+	key = hmacSHA256(key, d.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		d.accessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// Returns the query sorted by key and encoded as required by AWS Signature
+// Version 4.
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, uriEncode(k, false)+"="+uriEncode(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// Percent-encodes every byte of s other than the unreserved characters of RFC 3986,
+// and slashes if path is true.
+func uriEncode(s string, path bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && path:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -16,11 +16,17 @@
 package blogo
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"html/template"
 	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"forge.capytal.company/loreddev/blogo/audit"
 	"forge.capytal.company/loreddev/blogo/core"
 	"forge.capytal.company/loreddev/blogo/plugin"
 	"forge.capytal.company/loreddev/blogo/plugins"
@@ -53,6 +59,9 @@ func New(opts ...Opts) Blogo {
 	if opt.Assertions == nil {
 		opt.Assertions = tinyssert.NewDisabledAssertions()
 	}
+	if opt.Logger == nil && opt.LogHandler != nil {
+		opt.Logger = slog.New(opt.LogHandler)
+	}
 	if opt.Logger == nil {
 		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
 	}
@@ -127,6 +136,13 @@ func New(opts ...Opts) Blogo {
 		fallbackErrorHandler: opt.FallbackErrorHandler,
 		multiErrorHandler:    opt.MultiErrorHandler,
 
+		sourceOnInit:  opt.SourceOnInit,
+		errorReporter: opt.ErrorReporter,
+		serverOptions: opt.ServerOptions,
+		audit:         opt.Audit,
+		rendererPool:  opt.RendererPool,
+		httpClient:    opt.HTTPClient,
+
 		assert: opt.Assertions,
 		log:    opt.Logger,
 	}
@@ -141,8 +157,9 @@ type Blogo interface {
 	// Adds a new plugin to the engine.
 	//
 	// Implementations may accept any type of plugin interface. The default
-	// implementation accepts [plugin.Sourcer], [plugin.Renderer], [plugin.ErrorHandler],
-	// and [plugin.Group], ignoring any other plugins or nil values silently.
+	// implementation accepts [plugin.Sourcer], [plugin.Renderer], [plugin.ExpensiveRenderer],
+	// [plugin.ErrorHandler], [plugin.Middleware] and [plugin.Group], ignoring any other
+	// plugins or nil values silently.
 	Use(plugin.Plugin)
 	// Initialize the plugins or internal state if necessary.
 	//
@@ -197,6 +214,33 @@ type Opts struct {
 		plugin.WithPlugins
 	}
 
+	// Options of the core server, such as [core.WithSizeLimits] and [core.WithCache],
+	// applied after the options set by the other fields.
+	ServerOptions []core.ServerOption
+
+	// Reporter of errors that aren't recovered by the error handlers, and of panics
+	// while serving requests. See [core.ErrorReporter].
+	ErrorReporter core.ErrorReporter
+
+	// Source the files on Init, instead of on the first request. Init panics if
+	// sourcing fails, use [Builder] to get the error instead.
+	SourceOnInit bool
+
+	// Log where purges of the cache with Invalidate are recorded, with the
+	// "cache.purge" action. By default purges are only logged.
+	Audit audit.Log
+
+	// Options of the pools of the plugins that implement [plugin.ExpensiveRenderer],
+	// see [core.NewRendererPool]. The assertions and logger default to the ones
+	// of the engine.
+	RendererPool core.RendererPoolOpts
+
+	// Client of the outbound requests of plugins, passed to the ones that
+	// implement [plugin.HTTPClientAware], such as one created by New of the
+	// httpclient package. If nil, plugins use the client provided on their
+	// construction or the default one of the httpclient package.
+	HTTPClient *http.Client
+
 	// [tinyssert.Assertions] implementation used Assertions, by default
 	// uses [tinyssert.NewDisabledAssertions] to effectively disable assertions.
 	// Use this if to fail-fast on incorrect states. This is also passed to the
@@ -206,8 +250,12 @@ type Opts struct {
 	// Logger to be used to send error, warns and debug messages, useful for plugin
 	// development and debugging the pipeline of files. By default it uses a logger
 	// that writes to [io.Discard], effectively disabling logging. This is passed
-	// to the default built-in plugins on initialization.
+	// to the default built-in plugins on initialization, and to plugins that
+	// implement [plugin.LoggerAware].
 	Logger *slog.Logger
+	// Handler of the logger, used if Logger is nil, so any [slog.Handler]
+	// implementation can be used as the sink of the logs.
+	LogHandler slog.Handler
 }
 
 type blogo struct {
@@ -229,7 +277,17 @@ type blogo struct {
 		plugin.WithPlugins
 	}
 
-	server http.Handler
+	sourceOnInit  bool
+	errorReporter core.ErrorReporter
+	serverOptions []core.ServerOption
+	audit         audit.Log
+	rendererPool  core.RendererPoolOpts
+	pools         []core.RendererPool
+	httpClient    *http.Client
+
+	core    core.Server
+	server  http.Handler
+	metrics *core.Metrics
 
 	assert tinyssert.Assertions
 	log    *slog.Logger
@@ -249,11 +307,81 @@ func (b *blogo) Use(p plugin.Plugin) {
 		}
 	}
 
+	if p, ok := p.(plugin.LoggerAware); ok {
+		log.Debug("Plugin is logger aware, setting it's logger")
+		p.SetLogger(b.log.With(slog.String("plugin", p.Name())))
+	}
+
+	if p, ok := p.(plugin.HTTPClientAware); ok && b.httpClient != nil {
+		log.Debug("Plugin is HTTP client aware, setting it's client")
+		p.SetHTTPClient(b.httpClient)
+	}
+
 	if p != nil {
 		b.plugins = append(b.plugins, p)
 	}
 }
 
+// Removes cached renders of the core server, see [(core.Server).Invalidate]. Not
+// part of the [Blogo] interface, use a type assertion to access it.
+func (b *blogo) Invalidate(names ...string) int {
+	if b.core == nil {
+		return 0
+	}
+
+	n := b.core.Invalidate(names...)
+
+	audit.Record(context.Background(), b.audit, audit.Entry{
+		Action:  "cache.purge",
+		Target:  strings.Join(names, ", "),
+		Details: map[string]string{"removed": strconv.Itoa(n)},
+	}, b.log)
+
+	return n
+}
+
+// Returns the metrics registry of the engine (see [core.Metrics]), with the
+// counters of the core server and of the plugins that implement
+// [plugin.Instrumented], initializing the engine if needed. Serve it to expose
+// the counters as a debug endpoint. Not part of the [Blogo] interface, use a
+// type assertion to access it.
+func (b *blogo) Metrics() *core.Metrics {
+	if b.server == nil {
+		b.Init()
+	}
+	return b.metrics
+}
+
+// Writes the cached renders of the core server, see [(core.Server).ExportCache],
+// initializing the engine if needed. Not part of the [Blogo] interface, use a
+// type assertion to access it.
+func (b *blogo) ExportCache(w io.Writer) error {
+	if b.server == nil {
+		b.Init()
+	}
+	return b.core.ExportCache(w)
+}
+
+// Loads the renders exported by ExportCache into the core server, see
+// [(core.Server).ImportCache], initializing the engine if needed. Not part of
+// the [Blogo] interface, use a type assertion to access it.
+func (b *blogo) ImportCache(r io.Reader) (int, error) {
+	if b.server == nil {
+		b.Init()
+	}
+	return b.core.ImportCache(r)
+}
+
+// Renders the files of the paths with the core server, filling it's cache,
+// see [(core.Server).Prerender], initializing the engine if needed. Not part of
+// the [Blogo] interface, use a type assertion to access it.
+func (b *blogo) Prerender(ctx context.Context, paths ...string) (int, error) {
+	if b.server == nil {
+		b.Init()
+	}
+	return b.core.Prerender(ctx, paths...)
+}
+
 func (b *blogo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	b.assert.NotNil(b.log)
 	b.assert.NotNil(w)
@@ -273,6 +401,12 @@ func (b *blogo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (b *blogo) Init() {
+	if err := b.init(); err != nil {
+		panic(fmt.Sprintf("Failed to initialize Blogo due to error: %s", err.Error()))
+	}
+}
+
+func (b *blogo) init() error {
 	b.assert.NotNil(b.plugins, "Plugins needs to be not-nil")
 	b.assert.NotNil(b.log)
 
@@ -283,14 +417,88 @@ func (b *blogo) Init() {
 	renderer := b.initRenderer()
 	errorHandler := b.initErrorHandler()
 
+	for _, p := range b.pools {
+		if err := p.Warm(context.Background()); err != nil {
+			return errors.Join(fmt.Errorf("failed to initialize renderer %q", p.Name()), err)
+		}
+	}
+
 	log.Debug("Constructing Blogo server")
 
-	b.server = core.NewServer(sourcer, renderer, errorHandler, core.ServerOpts{
+	opts := append([]core.ServerOption{core.ServerOpts{
+		SourceOnInit:  b.sourceOnInit,
+		ErrorReporter: b.errorReporter,
+
 		Assertions: b.assert,
 		Logger:     b.log.WithGroup("server"),
-	})
+	}}, b.serverOptions...)
+
+	// Plugins are registered on the metrics registry of the options, or on one
+	// of the engine if there's none, so their counters can be served.
+	metrics := core.ResolveOptions(opts...).Metrics
+	if metrics == nil {
+		metrics = core.NewMetrics()
+		opts = append(opts, core.WithMetrics(metrics))
+	}
+	for _, p := range b.plugins {
+		if p, ok := p.(plugin.Instrumented); ok {
+			metrics.Register(p)
+		}
+	}
+	for _, p := range b.pools {
+		metrics.Register(p)
+	}
+	b.metrics = metrics
+
+	// The launch gate is applied before the middlewares, so their endpoints are
+	// also gated.
+	launch := core.ResolveOptions(opts...).Launch
+	if launch != nil {
+		opts = append(opts, core.ServerOptionFunc(func(o *core.ServerOpts) { o.Launch = nil }))
+	}
+
+	server, err := core.NewServerE(sourcer, renderer, errorHandler, opts...)
+	if err != nil {
+		return errors.Join(errors.New("failed to construct server"), err)
+	}
 
 	log.Debug("Server constructed")
+
+	b.core = server
+	b.server = b.initMiddlewares(server)
+
+	// Middlewares that reject requests, such as for rate limits, respond with
+	// the error handlers of the server, see core.Reject.
+	handler := b.server
+	b.server = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(core.WithRejecter(r.Context(), server)))
+	})
+	if launch != nil {
+		b.server = launch.Handler(b.server)
+	}
+
+	return nil
+}
+
+func (b *blogo) initMiddlewares(server http.Handler) http.Handler {
+	b.assert.NotNil(b.plugins, "Plugins needs to be not-nil")
+	b.assert.NotNil(server, "Server needs to be not-nil")
+	b.assert.NotNil(b.log)
+
+	log := b.log.With()
+	log.Debug("Initializing Blogo Middleware plugins")
+
+	// Middlewares are applied in reverse, so the first plugin added is the
+	// first to receive the request.
+	for i := len(b.plugins) - 1; i >= 0; i-- {
+		if m, ok := b.plugins[i].(plugin.Middleware); ok {
+			log.Debug("Adding Middleware", slog.String("middleware", m.Name()))
+
+			server = m.Middleware(server)
+		}
+	}
+
+	return server
 }
 
 func (b *blogo) initRenderer() plugin.Renderer {
@@ -303,8 +511,27 @@ func (b *blogo) initRenderer() plugin.Renderer {
 	log.Debug("Initializing Blogo Renderer plugins")
 
 	renderers := []plugin.Renderer{}
+	b.pools = []core.RendererPool{}
 
 	for _, p := range b.plugins {
+		// Expensive renderers are used through a pool of their instances, even
+		// if they are also a renderer themselves.
+		if r, ok := p.(plugin.ExpensiveRenderer); ok {
+			log.Debug("Adding pooled Renderer", slog.String("renderer", r.Name()))
+
+			opt := b.rendererPool
+			if opt.Assertions == nil {
+				opt.Assertions = b.assert
+			}
+			if opt.Logger == nil {
+				opt.Logger = b.log.WithGroup("pool")
+			}
+
+			pool := core.NewRendererPool(r, opt)
+			b.pools = append(b.pools, pool)
+			renderers = append(renderers, pool)
+			continue
+		}
 		if r, ok := p.(plugin.Renderer); ok {
 			log.Debug("Adding Renderer", slog.String("sourcer", r.Name()))
 
@@ -0,0 +1,354 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package bluegreen serves the content of one of multiple sourcers (such as the
+// "main" and "staging" branches of a repository), switching which one is live
+// without a new deployment, while the others can be previewed per request.
+package bluegreen
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+
+	"forge.capytal.company/loreddev/blogo/auth"
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-bluegreen-sourcer"
+
+// Returned when switching or previewing a target that doesn't exist.
+var ErrUnknownTarget = errors.New("unknown target")
+
+// The blue/green [plugin.Sourcer], which file system always serves the live
+// target, and is a [plugin.Middleware] that serves other targets to requests
+// with the preview header (see Opts.Header).
+type Switcher interface {
+	plugin.Sourcer
+	plugin.Middleware
+	// Returns the name of the live target.
+	Live() string
+	// Makes the target live. Returns [ErrUnknownTarget] if there's no target with
+	// the name, or the error of sourcing it if it wasn't sourced yet.
+	Switch(name string) error
+	// Returns the names of all targets, sorted.
+	Targets() []string
+}
+
+// Creates a [Switcher] of the targets, by their name. Source sources all targets,
+// only failing if the live one fails. Previews of targets that weren't sourced
+// are sourced on their first request.
+//
+// Opts.Live defaults to the first target in lexical order.
+func New(targets map[string]plugin.Sourcer, opts ...Opts) Switcher {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	names := make([]string, 0, len(targets))
+	for n := range targets {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+
+	if opt.Live == "" && len(names) > 0 {
+		opt.Live = names[0]
+	}
+	if opt.Header == "" {
+		opt.Header = "X-Blogo-Preview"
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(targets[opt.Live], "Live target should exist")
+
+	return &p{
+		targets:  targets,
+		names:    names,
+		live:     opt.Live,
+		header:   opt.Header,
+		onSwitch: opt.OnSwitch,
+
+		authenticator: opt.Authenticator,
+		scopes:        opt.Scopes,
+
+		files: map[string]fs.FS{},
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Name of the target that is live on construction. Defaults to the first
+	// target in lexical order.
+	Live string
+	// Header of requests that previews a target, which value is the name of the
+	// target. Defaults to "X-Blogo-Preview".
+	Header string
+	// Authenticator of preview requests. If nil, all previews are rejected, so
+	// unpublished content isn't exposed by mistake.
+	Authenticator auth.Authenticator
+	// Scopes that the identity needs to have to preview targets.
+	Scopes []string
+	// Called after the live target changes. Use it to invalidate the cached
+	// renders, such as with [(core.Server).Invalidate].
+	OnSwitch func(old, new string)
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	targets  map[string]plugin.Sourcer
+	names    []string
+	header   string
+	onSwitch func(old, new string)
+
+	authenticator auth.Authenticator
+	scopes        []string
+
+	mu    sync.RWMutex
+	live  string
+	files map[string]fs.FS
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Source() (fs.FS, error) {
+	p.assert.NotNil(p.targets)
+	p.assert.NotNil(p.log)
+
+	live := p.Live()
+
+	for _, name := range p.names {
+		log := p.log.With(slog.String("target", name))
+		log.Debug("Sourcing target")
+
+		if _, err := p.source(name); err != nil {
+			if name == live {
+				return nil, errors.Join(fmt.Errorf("failed to source live target %q", name), err)
+			}
+			log.Warn("Failed to source target", slog.String("err", err.Error()))
+		}
+	}
+
+	return &switchFS{p: p}, nil
+}
+
+// Sources the target, keeping it's file system.
+func (p *p) source(name string) (fs.FS, error) {
+	s, ok := p.targets[name]
+	if !ok {
+		return nil, ErrUnknownTarget
+	}
+
+	fsys, err := s.Source()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.files[name] = fsys
+	p.mu.Unlock()
+
+	return fsys, nil
+}
+
+// Returns the file system of the target, sourcing it if it wasn't sourced yet.
+func (p *p) target(name string) (fs.FS, error) {
+	p.mu.RLock()
+	fsys, ok := p.files[name]
+	p.mu.RUnlock()
+
+	if ok {
+		return fsys, nil
+	}
+	return p.source(name)
+}
+
+func (p *p) Live() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.live
+}
+
+func (p *p) Switch(name string) error {
+	if _, err := p.target(name); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	old := p.live
+	p.live = name
+	p.mu.Unlock()
+
+	if old == name {
+		return nil
+	}
+
+	p.log.Info("Switched live target", slog.String("from", old), slog.String("to", name))
+
+	if p.onSwitch != nil {
+		p.onSwitch(old, name)
+	}
+
+	return nil
+}
+
+func (p *p) Targets() []string {
+	return slices.Clone(p.names)
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	preview := auth.Require(p.authenticator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.servePreview(next, w, r)
+	}), auth.RequireOpts{
+		Scopes:     p.scopes,
+		Assertions: p.assert,
+		Logger:     p.log,
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Responses change with the preview header, so shared caches shouldn't
+		// serve previews to other requests.
+		w.Header().Add("Vary", p.header)
+
+		name := r.Header.Get(p.header)
+		if name == "" || name == p.Live() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		preview.ServeHTTP(w, r)
+	})
+}
+
+// Serves the request with the file system of the previewed target.
+func (p *p) servePreview(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	name := r.Header.Get(p.header)
+	log := p.log.With(slog.String("target", name), slog.String("path", r.URL.Path))
+
+	fsys, err := p.target(name)
+	if errors.Is(err, ErrUnknownTarget) {
+		http.Error(w, fmt.Sprintf("Unknown target %q", name), http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Error("Failed to source previewed target", slog.String("err", err.Error()))
+		http.Error(w, "Failed to source target", http.StatusBadGateway)
+		return
+	}
+
+	log.Debug("Serving preview")
+
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	next.ServeHTTP(w, r.WithContext(core.WithFiles(r.Context(), fsys)))
+}
+
+// File system that serves the live target.
+type switchFS struct {
+	p *p
+}
+
+func (fsys *switchFS) current() fs.FS {
+	fsys.p.mu.RLock()
+	defer fsys.p.mu.RUnlock()
+	return fsys.p.files[fsys.p.live]
+}
+
+func (fsys *switchFS) Open(name string) (fs.File, error) {
+	cur := fsys.current()
+	if cur == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return cur.Open(name)
+}
+
+func (fsys *switchFS) Metadata() metadata.Metadata {
+	if m, err := metadata.GetMetadata(fsys.current()); err == nil {
+		return m
+	}
+	return metadata.Map(map[string]any{})
+}
+
+// Status of the [Switcher], as responded by [NewHandler].
+type Status struct {
+	Live    string   `json:"live"`
+	Targets []string `json:"targets"`
+}
+
+// Creates a [http.Handler] of the admin API of the switcher. GET requests
+// respond with the JSON [Status], and POST requests with the "to" query
+// parameter switch the live target, responding with "404 Not Found" if there's
+// no target with the name.
+//
+// The handler doesn't authenticate requests, so it should be mounted behind
+// the authentication of the application.
+func NewHandler(s Switcher) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			err := s.Switch(r.URL.Query().Get("to"))
+			if errors.Is(err, ErrUnknownTarget) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			fallthrough
+
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(Status{Live: s.Live(), Targets: s.Targets()})
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
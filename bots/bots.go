@@ -0,0 +1,110 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bots provides the detection of requests done by bots, such as search
+// engine crawlers, link previewers and scrapers, based on their User-Agent, and
+// optionally verifying the crawlers of search engines by DNS, so the analytics
+// plugin can exclude them from view counts and expensive endpoints can be
+// protected from them.
+package bots
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var botUserAgent = regexp.MustCompile(
+	`(?i)bot|crawl|spider|slurp|fetch|scrape|preview|monitor|curl|wget|python-requests|go-http-client|headless|lighthouse`,
+)
+
+// Result of the detection of a request.
+type Result struct {
+	// If the request was probably done by a bot.
+	Bot bool
+	// Name of the known crawler that the User-Agent claims to be, such as
+	// "Googlebot", empty for other bots.
+	Name string
+	// If the request was verified to come from the known crawler, by the
+	// reverse and forward DNS lookups of it's IP address.
+	Verified bool
+}
+
+// A crawler of a search engine that can be verified by DNS: the reverse lookup
+// of the IP address of it's requests resolve to a host of one of it's domains,
+// which resolves back to the same address.
+type Crawler struct {
+	Name string
+	// Case-insensitive substring of the User-Agent of the crawler.
+	UserAgent string
+	// Domains of the hosts of the crawler, such as "googlebot.com".
+	Domains []string
+}
+
+// Crawlers verified by default, as documented by their search engines.
+var DefaultCrawlers = []Crawler{
+	{Name: "Googlebot", UserAgent: "googlebot", Domains: []string{"googlebot.com", "google.com", "googleusercontent.com"}},
+	{Name: "Bingbot", UserAgent: "bingbot", Domains: []string{"search.msn.com"}},
+	{Name: "Applebot", UserAgent: "applebot", Domains: []string{"applebot.apple.com"}},
+	{Name: "DuckDuckBot", UserAgent: "duckduckbot", Domains: []string{"duckduckgo.com"}},
+	{Name: "YandexBot", UserAgent: "yandex", Domains: []string{"yandex.ru", "yandex.net", "yandex.com"}},
+	{Name: "Baiduspider", UserAgent: "baiduspider", Domains: []string{"crawl.baidu.com"}},
+}
+
+// Reports if the request was probably done by a bot. Uses the result of the
+// middleware of [New] if it ran before, otherwise only the User-Agent heuristics
+// of [Detect].
+func IsBot(r *http.Request) bool {
+	if res, ok := FromContext(r.Context()); ok {
+		return res.Bot
+	}
+	return Detect(r).Bot
+}
+
+// Detects bots by the User-Agent of the request, without verifying known
+// crawlers. Requests without a User-Agent are also considered bots.
+func Detect(r *http.Request) Result {
+	res, _, _ := detect(DefaultCrawlers, r.UserAgent())
+	return res
+}
+
+// Detects bots by the User-Agent, returning the known crawler it claims to be.
+func detect(crawlers []Crawler, ua string) (Result, Crawler, bool) {
+	res := Result{Bot: ua == "" || botUserAgent.MatchString(ua)}
+
+	lower := strings.ToLower(ua)
+	for _, c := range crawlers {
+		if strings.Contains(lower, strings.ToLower(c.UserAgent)) {
+			res.Bot, res.Name = true, c.Name
+			return res, c, true
+		}
+	}
+	return res, Crawler{}, false
+}
+
+type resultKey struct{}
+
+// Returns a context with the result of the detection of it's request.
+func WithResult(ctx context.Context, res Result) context.Context {
+	return context.WithValue(ctx, resultKey{}, res)
+}
+
+// Returns the result set on the context by [WithResult], such as by the
+// middleware of [New].
+func FromContext(ctx context.Context) (Result, bool) {
+	res, ok := ctx.Value(resultKey{}).(Result)
+	return res, ok
+}
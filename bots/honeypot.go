@@ -0,0 +1,339 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bots
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/ratelimit"
+	"forge.capytal.company/loreddev/blogo/visibility"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const honeypotPluginName = "blogo-bots-honeypot-middleware"
+
+// Creates a [plugin.Middleware] that serves decoy paths, which are disallowed on
+// "/robots.txt" so well-behaved crawlers never request them, blocking the clients
+// that request them anyway from the site, or only from Opts.Protect, for
+// Opts.BlockDuration.
+//
+// The decoy pages are tarpitted: they are written slowly, and link to more decoy
+// pages, so scrapers waste their time on them instead of expensive endpoints.
+// The "Disallow" rules are appended to the robots.txt served by the next handler,
+// or served on their own if it doesn't serve one. Verified crawlers (see [New])
+// are never blocked.
+func NewHoneypot(opts ...HoneypotOpts) plugin.Middleware {
+	opt := HoneypotOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Prefix == "" {
+		opt.Prefix = "/.blogo/archive/"
+	}
+	if !strings.HasSuffix(opt.Prefix, "/") {
+		opt.Prefix += "/"
+	}
+	if opt.BlockDuration == 0 {
+		opt.BlockDuration = 24 * time.Hour
+	}
+	if opt.TarpitDuration == 0 {
+		opt.TarpitDuration = 30 * time.Second
+	}
+	if opt.MaxTarpits == 0 {
+		opt.MaxTarpits = 64
+	}
+	if opt.MaxBlocked == 0 {
+		opt.MaxBlocked = 16384
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &honeypot{
+		prefix:  opt.Prefix,
+		protect: opt.Protect,
+
+		blockDuration:  opt.BlockDuration,
+		tarpitDuration: opt.TarpitDuration,
+		maxTarpits:     int64(opt.MaxTarpits),
+		maxBlocked:     opt.MaxBlocked,
+
+		blocked: map[string]time.Time{},
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type HoneypotOpts struct {
+	// Prefix of the decoy paths. Defaults to "/.blogo/archive/", a path that
+	// looks like it has content worth scraping.
+	Prefix string
+	// Patterns of the paths that blocked clients are forbidden from, with the
+	// same syntax as [visibility.Match]. By default blocked clients are
+	// forbidden from the whole site.
+	Protect []string
+
+	// Duration that clients that requested a decoy path are blocked. Defaults
+	// to 24 hours.
+	BlockDuration time.Duration
+	// Duration that the response of each decoy page takes. Defaults to 30
+	// seconds. Negative durations disable the tarpit, responding immediately.
+	TarpitDuration time.Duration
+	// Max number of decoy pages being tarpitted at the same time, so the
+	// tarpit doesn't hold too many connections. Other requests to decoys are
+	// responded immediately. Defaults to 64.
+	MaxTarpits int
+	// Max number of blocked clients. When reached, the clients whose block
+	// expired are removed, and if none are, all clients are unblocked.
+	// Defaults to 16384.
+	MaxBlocked int
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type honeypot struct {
+	prefix  string
+	protect []string
+
+	blockDuration  time.Duration
+	tarpitDuration time.Duration
+	maxTarpits     int64
+	maxBlocked     int
+
+	tarpits atomic.Int64
+
+	mu      sync.Mutex
+	blocked map[string]time.Time
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (h *honeypot) Name() string {
+	return honeypotPluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (h *honeypot) SetLogger(logger *slog.Logger) {
+	if h.injectLogger {
+		h.log = logger
+	}
+}
+
+func (h *honeypot) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.assert.NotNil(h.log)
+
+		ip := ratelimit.ClientIP(r)
+		res, _ := FromContext(r.Context())
+
+		switch {
+		case r.URL.Path == "/robots.txt":
+			h.serveRobots(w, r, next)
+
+		case strings.HasPrefix(r.URL.Path, h.prefix):
+			if !res.Verified {
+				h.block(ip, r)
+			}
+			h.serveDecoy(w, r)
+
+		case h.isBlocked(ip) && h.protected(r.URL.Path):
+			h.log.Debug("Blocked client requested protected path",
+				slog.String("path", r.URL.Path), slog.String("ip", ip))
+			http.Error(w, "Forbidden", http.StatusForbidden)
+
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func (h *honeypot) protected(p string) bool {
+	if h.protect == nil {
+		return true
+	}
+	name := strings.Trim(p, "/")
+	return slices.ContainsFunc(h.protect, func(pattern string) bool {
+		return visibility.Match(pattern, name)
+	})
+}
+
+func (h *honeypot) block(ip string, r *http.Request) {
+	now := time.Now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.blocked[ip]; !ok && len(h.blocked) >= h.maxBlocked {
+		for k, until := range h.blocked {
+			if now.After(until) {
+				delete(h.blocked, k)
+			}
+		}
+		if len(h.blocked) >= h.maxBlocked {
+			h.blocked = map[string]time.Time{}
+		}
+	}
+
+	h.blocked[ip] = now.Add(h.blockDuration)
+
+	h.log.Info("Client requested decoy path, blocking it",
+		slog.String("ip", ip),
+		slog.String("path", r.URL.Path),
+		slog.String("user-agent", r.UserAgent()))
+}
+
+func (h *honeypot) isBlocked(ip string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	until, ok := h.blocked[ip]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(h.blocked, ip)
+		return false
+	}
+	return true
+}
+
+// Serves the robots.txt of the next handler, with the decoy prefix disallowed
+// for all user agents.
+func (h *honeypot) serveRobots(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	rw := &robotsWriter{header: http.Header{}, status: http.StatusOK}
+	next.ServeHTTP(rw, r)
+
+	rule := "User-agent: *\nDisallow: " + h.prefix + "\n"
+
+	body := rw.body.Bytes()
+	if rw.status != http.StatusOK {
+		rw.header = http.Header{}
+		body = nil
+	} else if len(body) > 0 {
+		rule = "\n" + rule
+		if !bytes.HasSuffix(body, []byte("\n")) {
+			rule = "\n" + rule
+		}
+	}
+
+	for k, v := range rw.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusOK)
+
+	_, _ = w.Write(body)
+	_, _ = io.WriteString(w, rule)
+}
+
+// Writes a page with links to more decoy pages, slowly if the tarpit isn't full.
+func (h *honeypot) serveDecoy(w http.ResponseWriter, r *http.Request) {
+	links := make([]string, 8)
+	for i := range links {
+		b := make([]byte, 6)
+		_, _ = rand.Read(b)
+		links[i] = h.prefix + hex.EncodeToString(b) + "/"
+	}
+
+	var page bytes.Buffer
+	page.WriteString("<!DOCTYPE html>\n<html><head><meta name=\"robots\" content=\"noindex\">")
+	page.WriteString("<title>Archive</title></head><body><ul>\n")
+	for _, l := range links {
+		fmt.Fprintf(&page, "<li><a href=%q>%s</a></li>\n", l, strings.TrimSuffix(strings.TrimPrefix(l, h.prefix), "/"))
+	}
+	page.WriteString("</ul></body></html>\n")
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if h.tarpitDuration < 0 || h.tarpits.Add(1) > h.maxTarpits {
+		if h.tarpitDuration >= 0 {
+			h.tarpits.Add(-1)
+		}
+		_, _ = w.Write(page.Bytes())
+		return
+	}
+	defer h.tarpits.Add(-1)
+
+	flusher, _ := w.(http.Flusher)
+	lines := bytes.SplitAfter(page.Bytes(), []byte("\n"))
+	interval := h.tarpitDuration / time.Duration(len(lines))
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for _, l := range lines {
+		if _, err := w.Write(l); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timer.C:
+			timer.Reset(interval)
+		}
+	}
+}
+
+// [http.ResponseWriter] that holds the robots.txt of the next handler.
+type robotsWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *robotsWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *robotsWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *robotsWriter) Write(p []byte) (int, error) {
+	return w.body.Write(p)
+}
@@ -0,0 +1,277 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bots
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/ratelimit"
+	"forge.capytal.company/loreddev/blogo/visibility"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-bots-middleware"
+
+// Resolver of the DNS lookups used to verify crawlers, implemented by [net.Resolver].
+type Resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// Creates a [plugin.Middleware] that detects the bots of each request, setting
+// the [Result] on it's context (see [FromContext]), so later middlewares, such
+// as the analytics plugin, can use it. It should be added before them.
+//
+// Requests of bots to Opts.Paths are responded with "403 Forbidden", except
+// verified crawlers if Opts.AllowVerified is set. Requests of other bots that
+// aren't verified are limited by Opts.Limiter, by IP address.
+func New(opts ...Opts) plugin.Middleware {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Crawlers == nil {
+		opt.Crawlers = DefaultCrawlers
+	}
+	if opt.Resolver == nil {
+		opt.Resolver = net.DefaultResolver
+	}
+	if opt.Timeout == 0 {
+		opt.Timeout = 2 * time.Second
+	}
+	if opt.CacheTTL == 0 {
+		opt.CacheTTL = 24 * time.Hour
+	}
+	if opt.CacheMaxEntries == 0 {
+		opt.CacheMaxEntries = 4096
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &p{
+		crawlers: opt.Crawlers,
+		verify:   opt.Verify,
+		resolver: opt.Resolver,
+		timeout:  opt.Timeout,
+
+		paths:         opt.Paths,
+		allowVerified: opt.AllowVerified,
+		limiter:       opt.Limiter,
+
+		cacheTTL:        opt.CacheTTL,
+		cacheMaxEntries: opt.CacheMaxEntries,
+		verified:        map[string]verification{},
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Known crawlers, which are verified if Verify is set. Defaults to
+	// [DefaultCrawlers].
+	Crawlers []Crawler
+	// Verifies the requests of known crawlers by DNS, so they can be told apart
+	// from bots that use their User-Agent. Requests of crawlers that fail the
+	// verification are still bots.
+	Verify bool
+	// Resolver used to verify crawlers. Defaults to [net.DefaultResolver].
+	Resolver Resolver
+	// Max duration of the DNS lookups of a verification. Defaults to 2 seconds.
+	Timeout time.Duration
+	// Duration that the verification of a IP address is cached. Defaults to 24 hours.
+	CacheTTL time.Duration
+	// Max number of cached verifications. When reached, the cache is cleared.
+	// Defaults to 4096.
+	CacheMaxEntries int
+
+	// Patterns of the paths that bots are forbidden from, such as expensive
+	// search endpoints, with the same syntax as [visibility.Match].
+	Paths []string
+	// Allows verified crawlers to request Paths.
+	AllowVerified bool
+	// Limiter of the requests of bots that aren't verified, by IP address. By
+	// default they aren't limited.
+	Limiter ratelimit.Limiter
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	crawlers []Crawler
+	verify   bool
+	resolver Resolver
+	timeout  time.Duration
+
+	paths         []string
+	allowVerified bool
+	limiter       ratelimit.Limiter
+
+	cacheTTL        time.Duration
+	cacheMaxEntries int
+	mu              sync.Mutex
+	verified        map[string]verification
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+type verification struct {
+	ok      bool
+	expires time.Time
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.log)
+
+		res := p.detect(r)
+		r = r.WithContext(WithResult(r.Context(), res))
+
+		if !res.Bot {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		name := strings.Trim(r.URL.Path, "/")
+		forbidden := slices.ContainsFunc(p.paths, func(pattern string) bool {
+			return visibility.Match(pattern, name)
+		})
+		if forbidden && !(res.Verified && p.allowVerified) {
+			p.log.Debug("Bot requested forbidden path",
+				slog.String("path", r.URL.Path), slog.String("user-agent", r.UserAgent()))
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		if !res.Verified && p.limiter != nil && !p.limiter.Allow(ratelimit.ClientIP(r)) {
+			p.log.Debug("Bot exceeded rate limit",
+				slog.String("path", r.URL.Path), slog.String("user-agent", r.UserAgent()))
+			core.Reject(w, r, core.RejectError{
+				Status:     http.StatusTooManyRequests,
+				RetryAfter: time.Minute,
+				Reason:     "Too many requests",
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (p *p) detect(r *http.Request) Result {
+	res, c, ok := detect(p.crawlers, r.UserAgent())
+	if ok && p.verify {
+		res.Verified = p.verifyCrawler(r.Context(), c, ratelimit.ClientIP(r))
+	}
+	return res
+}
+
+// Reports if the reverse DNS lookup of the IP address resolves to a host of
+// one of the domains of the crawler, and the host resolves back to the address.
+func (p *p) verifyCrawler(ctx context.Context, c Crawler, ip string) bool {
+	key := c.Name + " " + ip
+
+	p.mu.Lock()
+	v, ok := p.verified[key]
+	p.mu.Unlock()
+	if ok && time.Now().Before(v.expires) {
+		return v.ok
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	verified := false
+
+	hosts, err := p.resolver.LookupAddr(ctx, ip)
+	if err != nil {
+		p.log.Debug("Failed to look up address of crawler",
+			slog.String("crawler", c.Name), slog.String("ip", ip), slog.String("err", err.Error()))
+	}
+	for _, host := range hosts {
+		host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+		if !slices.ContainsFunc(c.Domains, func(d string) bool {
+			return host == d || strings.HasSuffix(host, "."+d)
+		}) {
+			continue
+		}
+
+		addrs, err := p.resolver.LookupHost(ctx, host)
+		if err != nil {
+			continue
+		}
+		if slices.Contains(addrs, ip) {
+			verified = true
+			break
+		}
+	}
+
+	// Lookups that timed out aren't cached, so a unavailable resolver doesn't
+	// mark crawlers as unverified for the whole TTL.
+	if ctx.Err() != nil {
+		return false
+	}
+
+	p.mu.Lock()
+	if len(p.verified) >= p.cacheMaxEntries {
+		p.verified = map[string]verification{}
+	}
+	p.verified[key] = verification{ok: verified, expires: time.Now().Add(p.cacheTTL)}
+	p.mu.Unlock()
+
+	if !verified {
+		p.log.Debug("Request of crawler is not verified",
+			slog.String("crawler", c.Name), slog.String("ip", ip))
+	}
+
+	return verified
+}
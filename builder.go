@@ -0,0 +1,211 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blogo
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+)
+
+var (
+	// A nil plugin was added to the [Builder].
+	ErrNilPlugin = errors.New("plugin is nil")
+	// A plugin that doesn't implement any of the plugin interfaces supported by
+	// [Blogo] was added to the [Builder].
+	ErrUnsupportedPlugin = errors.New("plugin does not implement any supported plugin interface")
+	// The options of the [Builder] can't be used together.
+	ErrIncompatibleOptions = errors.New("incompatible options")
+)
+
+// Error returned by [(*Builder).Build] for each invalid option of the configuration.
+// Use [errors.Is] with [ErrNilPlugin], [ErrUnsupportedPlugin] and [ErrIncompatibleOptions]
+// to check the reason.
+type ConfigError struct {
+	// Name of the option, for example "WithSourcer".
+	Option string
+	// Position of the plugin on the calls to the option, starting at 0, or -1
+	// if the error isn't about a specific plugin.
+	Index int
+	Err   error
+}
+
+func (e *ConfigError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("invalid %s option: %s", e.Option, e.Err.Error())
+	}
+	return fmt.Sprintf("invalid %s option #%d: %s", e.Option, e.Index, e.Err.Error())
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// Fluent builder of [Blogo] implementations, validating the configuration
+// before constructing it:
+//
+//	b, err := blogo.NewBuilder().
+//		WithSourcer(s).
+//		WithRenderer(r).
+//		WithMiddleware(m).
+//		Build()
+//
+// Plugins are added in the order of the calls, so middlewares are applied in the
+// same order as with [(Blogo).Use].
+type Builder struct {
+	opts    Opts
+	plugins []builderPlugin
+	counts  map[string]int
+}
+
+type builderPlugin struct {
+	option string
+	index  int
+	plugin plugin.Plugin
+}
+
+// Creates a new [Builder], with the options used by [New].
+func NewBuilder(opts ...Opts) *Builder {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	return &Builder{opts: opt, plugins: []builderPlugin{}, counts: map[string]int{}}
+}
+
+func (b *Builder) add(option string, p plugin.Plugin) *Builder {
+	b.plugins = append(b.plugins, builderPlugin{option: option, index: b.counts[option], plugin: p})
+	b.counts[option]++
+	return b
+}
+
+// Adds a [plugin.Sourcer].
+func (b *Builder) WithSourcer(s plugin.Sourcer) *Builder {
+	return b.add("WithSourcer", s)
+}
+
+// Adds a [plugin.Renderer].
+func (b *Builder) WithRenderer(r plugin.Renderer) *Builder {
+	return b.add("WithRenderer", r)
+}
+
+// Adds a [plugin.ErrorHandler].
+func (b *Builder) WithErrorHandler(h plugin.ErrorHandler) *Builder {
+	return b.add("WithErrorHandler", h)
+}
+
+// Adds a [plugin.Middleware].
+func (b *Builder) WithMiddleware(m plugin.Middleware) *Builder {
+	return b.add("WithMiddleware", m)
+}
+
+// Adds a plugin of any of the interfaces supported by [(Blogo).Use], including
+// [plugin.ExpensiveRenderer] and [plugin.Group].
+func (b *Builder) WithPlugin(p plugin.Plugin) *Builder {
+	return b.add("WithPlugin", p)
+}
+
+// Sources the files on Build, returning the error if it fails, instead of
+// sourcing them on the first request.
+func (b *Builder) WithSourceOnInit() *Builder {
+	b.opts.SourceOnInit = true
+	return b
+}
+
+// Validates the configuration, returning a error joining a [ConfigError] for each
+// invalid option, and constructs the [Blogo] implementation.
+func (b *Builder) Build() (Blogo, error) {
+	errs := []error{}
+	sourcers := 0
+
+	for _, p := range b.plugins {
+		if isNil(p.plugin) {
+			errs = append(errs, &ConfigError{Option: p.option, Index: p.index, Err: ErrNilPlugin})
+			continue
+		}
+		if !isSupported(p.plugin) {
+			errs = append(errs, &ConfigError{Option: p.option, Index: p.index, Err: ErrUnsupportedPlugin})
+			continue
+		}
+		sourcers += countSourcers(p.plugin)
+	}
+
+	if b.opts.SourceOnInit && sourcers == 0 {
+		errs = append(errs, &ConfigError{
+			Option: "WithSourceOnInit",
+			Index:  -1,
+			Err:    fmt.Errorf("%w: no sourcer to source files from", ErrIncompatibleOptions),
+		})
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	blogo := New(b.opts).(*blogo)
+	for _, p := range b.plugins {
+		blogo.Use(p.plugin)
+	}
+
+	if b.opts.SourceOnInit {
+		if err := blogo.init(); err != nil {
+			return nil, err
+		}
+	}
+
+	return blogo, nil
+}
+
+func isNil(p plugin.Plugin) bool {
+	if p == nil {
+		return true
+	}
+	v := reflect.ValueOf(p)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Interface, reflect.Chan:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+func isSupported(p plugin.Plugin) bool {
+	switch p.(type) {
+	case plugin.Sourcer, plugin.Renderer, plugin.ExpensiveRenderer, plugin.ErrorHandler,
+		plugin.Middleware, plugin.Group:
+		return true
+	default:
+		return false
+	}
+}
+
+func countSourcers(p plugin.Plugin) int {
+	n := 0
+	if _, ok := p.(plugin.Sourcer); ok {
+		n++
+	}
+	if g, ok := p.(plugin.Group); ok {
+		for _, p := range g.Plugins() {
+			if p != nil {
+				n += countSourcers(p)
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,84 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blogo_test
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"forge.capytal.company/loreddev/blogo"
+	"forge.capytal.company/loreddev/blogo/plugin"
+)
+
+type sourcer struct {
+	fsys fs.FS
+}
+
+func (s sourcer) Name() string {
+	return "test-sourcer"
+}
+
+func (s sourcer) Source() (fs.FS, error) {
+	return s.fsys, nil
+}
+
+type renderer struct{}
+
+func (r renderer) Name() string {
+	return "test-renderer"
+}
+
+func (r renderer) Render(src fs.File, w io.Writer) error {
+	if _, err := io.WriteString(w, "pooled: "); err != nil {
+		return err
+	}
+	_, err := io.Copy(w, src)
+	return err
+}
+
+type expensiveRenderer struct{}
+
+func (r expensiveRenderer) Name() string {
+	return "test-expensive-renderer"
+}
+
+func (r expensiveRenderer) NewRenderer(ctx context.Context) (plugin.Renderer, error) {
+	return renderer{}, nil
+}
+
+func TestBuilderExpensiveRenderer(t *testing.T) {
+	fsys := fstest.MapFS{"hello.txt": {Data: []byte("Hello, world")}}
+
+	b, err := blogo.NewBuilder().
+		WithSourcer(sourcer{fsys}).
+		WithPlugin(expensiveRenderer{}).
+		Build()
+	if err != nil {
+		t.Fatalf("failed to build with a plugin.ExpensiveRenderer: %s", err)
+	}
+
+	w := httptest.NewRecorder()
+	b.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello.txt", nil))
+
+	if body := w.Body.String(); body != "pooled: Hello, world" {
+		t.Fatalf("expected body of pooled renderer, got %q (status %d)", body, w.Code)
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package canonical provides the canonicalization of URL paths, so each page is
+// only served on one URL, instead of also being on duplicates such as "/Post",
+// "/post/" and "//post". A [Policy] defines the canonical form, and is shared by
+// the middleware created by [New], which redirects requests to it, and by the
+// plugins that generate URLs, such as the feed plugin, so the links they create
+// don't need to be redirected.
+package canonical
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-canonical-middleware"
+
+// Policy of the trailing slash of paths.
+type TrailingSlash int
+
+const (
+	// Keeps the trailing slash of paths as they are.
+	KeepSlash TrailingSlash = iota
+	// Adds a trailing slash to paths, except to ones of files with a extension
+	// such as "/feed.xml".
+	AddSlash
+	// Removes the trailing slash of paths.
+	RemoveSlash
+)
+
+// The canonical form of URL paths. The zero value doesn't change paths.
+type Policy struct {
+	// Lowercase paths. Only use it if the names of all served files are lowercase,
+	// otherwise they can't be requested.
+	Lowercase bool
+	// Policy of the trailing slash of paths. The root path "/" is never changed.
+	TrailingSlash TrailingSlash
+	// Removes the index names from the end of paths, so "/notes/index" becomes
+	// "/notes/", before the trailing slash policy is applied.
+	StripIndex bool
+	// Names removed by StripIndex. Defaults to "index" and "index.html".
+	IndexNames []string
+	// Replaces sequences of slashes with a single one, so "/notes//post" becomes
+	// "/notes/post".
+	CollapseSlashes bool
+}
+
+// Policy with lowercase paths, no trailing slashes, stripped index names and
+// collapsed slashes, such as "/notes/post".
+var Default = Policy{
+	Lowercase:       true,
+	TrailingSlash:   RemoveSlash,
+	StripIndex:      true,
+	CollapseSlashes: true,
+}
+
+// Returns the canonical form of the path. Paths are always absolute, starting
+// with a single slash, so relative paths are returned as "/" followed by the
+// path, and leading slashes are collapsed even if CollapseSlashes isn't set, so
+// redirects can't be made to other hosts with paths such as "//example.com".
+func (p Policy) Path(s string) string {
+	s = "/" + strings.TrimLeft(s, "/")
+
+	if p.CollapseSlashes {
+		s = collapse(s)
+	}
+	if p.Lowercase {
+		s = strings.ToLower(s)
+	}
+
+	if p.StripIndex {
+		names := p.IndexNames
+		if names == nil {
+			names = []string{"index", "index.html"}
+		}
+		dir, name := path.Split(s)
+		if slices.Contains(names, name) {
+			s = dir
+		}
+	}
+
+	if s == "/" {
+		return s
+	}
+
+	switch p.TrailingSlash {
+	case AddSlash:
+		if !strings.HasSuffix(s, "/") && path.Ext(s) == "" {
+			s += "/"
+		}
+	case RemoveSlash:
+		s = strings.TrimRight(s, "/")
+		if s == "" {
+			s = "/"
+		}
+	}
+
+	return s
+}
+
+// Returns the absolute URL of the path, in it's canonical form, on the base URL
+// such as "https://example.com".
+func (p Policy) URL(base, path string) string {
+	return strings.TrimSuffix(base, "/") + p.Path(path)
+}
+
+func collapse(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '/' && i > 0 && s[i-1] == '/' {
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Creates a [plugin.Middleware] that permanently redirects ("301 Moved Permanently")
+// GET and HEAD requests to the canonical form of their path, keeping the query.
+// Requests of other methods and of paths on Opts.Exclude are passed as they are.
+func New(policy Policy, opts ...Opts) plugin.Middleware {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &p{
+		policy:  policy,
+		exclude: opt.Exclude,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Prefixes of paths that aren't redirected, such as "/api/" for endpoints
+	// that don't follow the policy.
+	Exclude []string
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	policy  Policy
+	exclude []string
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	p.assert.NotNil(next)
+	p.assert.NotNil(p.log)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		for _, e := range p.exclude {
+			if strings.HasPrefix(r.URL.Path, e) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		canonical := p.policy.Path(r.URL.Path)
+		if canonical == r.URL.Path {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		u := url.URL{Path: canonical, RawQuery: r.URL.RawQuery}
+
+		p.log.Debug("Redirecting to canonical path",
+			slog.String("path", r.URL.Path), slog.String("canonical", canonical))
+
+		http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,149 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package changes provides helpers to detect what files changed between two
+// versions of a file system, so plugins that run after the source is refreshed
+// (such as search engine notifications and caches) can act only on the files
+// that actually changed.
+package changes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"time"
+)
+
+// The state of a file system at a given point in time, keyed by the path of
+// each regular file.
+type Snapshot map[string]Entry
+
+// The state of a file in a [Snapshot].
+type Entry struct {
+	ModTime time.Time
+	Size    int64
+}
+
+// Walks the file system, creating a [Snapshot] of all it's regular files.
+func Take(fsys fs.FS) (Snapshot, error) {
+	s := Snapshot{}
+
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		s[p] = Entry{ModTime: info.ModTime(), Size: info.Size()}
+
+		return nil
+	})
+	if err != nil {
+		return s, errors.Join(errors.New("failed to walk file system"), err)
+	}
+
+	return s, nil
+}
+
+// The files that changed between two [Snapshot]s. All paths are sorted.
+type Set struct {
+	Added    []string
+	Modified []string
+	Removed  []string
+}
+
+// Compares the old and new snapshots, returning what files were added, modified
+// and removed. Files are considered modified if their size or modification time
+// changed.
+func Diff(old, new Snapshot) Set {
+	s := Set{Added: []string{}, Modified: []string{}, Removed: []string{}}
+
+	for p, e := range new {
+		o, ok := old[p]
+		if !ok {
+			s.Added = append(s.Added, p)
+		} else if o.Size != e.Size || !o.ModTime.Equal(e.ModTime) {
+			s.Modified = append(s.Modified, p)
+		}
+	}
+	for p := range old {
+		if _, ok := new[p]; !ok {
+			s.Removed = append(s.Removed, p)
+		}
+	}
+
+	slices.Sort(s.Added)
+	slices.Sort(s.Modified)
+	slices.Sort(s.Removed)
+
+	return s
+}
+
+// Returns all the paths of the set, added, modified and removed, sorted.
+func (s Set) Paths() []string {
+	ps := make([]string, 0, len(s.Added)+len(s.Modified)+len(s.Removed))
+	ps = append(ps, s.Added...)
+	ps = append(ps, s.Modified...)
+	ps = append(ps, s.Removed...)
+	slices.Sort(ps)
+	return ps
+}
+
+// Reports if there aren't any changes in the set.
+func (s Set) Empty() bool {
+	return len(s.Added) == 0 && len(s.Modified) == 0 && len(s.Removed) == 0
+}
+
+// Returns a hash of the paths, sizes and modification times of all files of the
+// snapshot, which changes when any file is added, modified or removed.
+func (s Snapshot) Fingerprint() string {
+	paths := make([]string, 0, len(s))
+	for p := range s {
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+
+	h := sha256.New()
+	for _, p := range paths {
+		e := s[p]
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", p, e.Size, e.ModTime.UnixNano())
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Takes a [Snapshot] of the file system and returns it's fingerprint, used to
+// check if state persisted across restarts (such as caches) is still valid.
+//
+// File systems without modification times are only compared by their paths and
+// sizes, so persisting state of them should use a more precise fingerprint,
+// such as the commit of git-backed file systems.
+func Fingerprint(fsys fs.FS) (string, error) {
+	s, err := Take(fsys)
+	if err != nil {
+		return "", err
+	}
+	return s.Fingerprint(), nil
+}
@@ -0,0 +1,276 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package cluster coordinates multiple instances of the blog behind a load
+// balancer, so a refresh of the sources triggered on any instance, such as by the
+// webhook of a forge, refreshes all of them.
+//
+// Refreshes are broadcasted with a [Broker], such as the client of the redis
+// package, instead of electing a leader, since each instance needs to refresh
+// it's own file system anyway.
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/schedule"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+// Transport of messages between instances. Implemented by the client of the
+// redis package.
+type Broker interface {
+	// Publishes the message on the channel, to all subscribed instances.
+	Publish(ctx context.Context, channel string, msg []byte) error
+	// Calls the function with each message published on the channel, until the
+	// context is done.
+	Subscribe(ctx context.Context, channel string, f func(msg []byte)) error
+}
+
+// Something that can be refreshed, such as the sourcer of the snapshot package.
+type Refresher interface {
+	Refresh() error
+}
+
+// Type adapter to allow the use of ordinary functions as [Refresher] implementations.
+type RefresherFunc func() error
+
+func (f RefresherFunc) Refresh() error {
+	return f()
+}
+
+// Coordinates the refreshes of all instances.
+type Coordinator interface {
+	// Refreshes this instance and broadcasts the refresh to the other instances.
+	// The broadcast is sent even if the refresh of this instance fails, since
+	// the failure may be local.
+	Refresh() error
+	// Refreshes this instance on each refresh broadcasted by other instances,
+	// until the context is done.
+	Listen(ctx context.Context) error
+}
+
+// Creates a [Coordinator] of the refresher, broadcasting refreshes on the
+// channel of the broker. Refreshes requested while one is running are coalesced
+// into a single refresh after it, so bursts of webhooks don't pile up.
+func New(r Refresher, b Broker, opts ...Opts) Coordinator {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Channel == "" {
+		opt.Channel = "blogo:refresh"
+	}
+	if opt.Timeout == 0 {
+		opt.Timeout = 5 * time.Second
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(r, "Refresher should not be nil")
+	opt.Assertions.NotNil(b, "Broker should not be nil")
+
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	c := &coordinator{
+		refresher: r,
+		broker:    b,
+		channel:   opt.Channel,
+		timeout:   opt.Timeout,
+		onRefresh: opt.OnRefresh,
+
+		id: hex.EncodeToString(id),
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+	c.done = sync.NewCond(&c.mu)
+
+	return c
+}
+
+type Opts struct {
+	// Channel where refreshes are broadcasted. Defaults to "blogo:refresh".
+	Channel string
+	// Timeout of publishing broadcasts. Defaults to 5s.
+	Timeout time.Duration
+	// Called after each refresh of this instance, with the error of the refresh
+	// and if it was broadcasted by other instance.
+	OnRefresh func(err error, remote bool)
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type coordinator struct {
+	refresher Refresher
+	broker    Broker
+	channel   string
+	timeout   time.Duration
+	onRefresh func(err error, remote bool)
+
+	// Identifier of the instance, so it ignores it's own broadcasts.
+	id string
+
+	mu      sync.Mutex
+	running bool
+	pending bool
+	done    *sync.Cond
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+type message struct {
+	Origin string    `json:"origin"`
+	Time   time.Time `json:"time"`
+}
+
+func (c *coordinator) Refresh() error {
+	c.assert.NotNil(c.broker)
+	c.assert.NotNil(c.log)
+
+	err := c.refresh(false)
+
+	msg, merr := json.Marshal(message{Origin: c.id, Time: time.Now()})
+	if merr != nil {
+		return errors.Join(err, errors.New("failed to encode broadcast"), merr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	if perr := c.broker.Publish(ctx, c.channel, msg); perr != nil {
+		c.log.Error("Failed to broadcast refresh", slog.String("err", perr.Error()))
+		return errors.Join(err, errors.New("failed to broadcast refresh"), perr)
+	}
+
+	c.log.Debug("Broadcasted refresh", slog.String("channel", c.channel))
+
+	return err
+}
+
+func (c *coordinator) Listen(ctx context.Context) error {
+	c.assert.NotNil(ctx)
+	c.assert.NotNil(c.broker)
+
+	return c.broker.Subscribe(ctx, c.channel, func(msg []byte) {
+		var m message
+		if err := json.Unmarshal(msg, &m); err != nil {
+			c.log.Warn("Ignoring malformed broadcast", slog.String("err", err.Error()))
+			return
+		}
+		if m.Origin == c.id {
+			return
+		}
+
+		c.log.Debug("Refreshing on broadcast", slog.String("origin", m.Origin))
+
+		// Refreshes in the background, so the subscription isn't blocked by slow
+		// sourcers.
+		go func() { _ = c.refresh(true) }()
+	})
+}
+
+// Refreshes the refresher, coalescing calls made while a refresh is running
+// into a single refresh after it.
+func (c *coordinator) refresh(remote bool) error {
+	c.assert.NotNil(c.refresher)
+
+	c.mu.Lock()
+	if c.running {
+		if c.pending {
+			// Another call is already waiting to refresh after the current one,
+			// which will include the changes of this call.
+			c.mu.Unlock()
+			return nil
+		}
+		c.pending = true
+		for c.running {
+			c.done.Wait()
+		}
+		c.pending = false
+	}
+	c.running = true
+	c.mu.Unlock()
+
+	err := c.refresher.Refresh()
+	if err != nil {
+		c.log.Error("Failed to refresh", slog.Bool("remote", remote), slog.String("err", err.Error()))
+	} else {
+		c.log.Debug("Refreshed", slog.Bool("remote", remote))
+	}
+
+	c.mu.Lock()
+	c.running = false
+	c.done.Broadcast()
+	c.mu.Unlock()
+
+	if c.onRefresh != nil {
+		c.onRefresh(err, remote)
+	}
+
+	return err
+}
+
+// Creates a [http.Handler] that refreshes all instances on POST requests, such
+// as the webhooks of forges, responding with "204 No Content", or "502 Bad
+// Gateway" if the refresh failed.
+//
+// The handler doesn't authenticate requests, so it should be mounted behind
+// the authentication of the application, such as the auth package.
+func NewHandler(c Coordinator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := c.Refresh(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// Returns a [schedule.Job] named "refresh" that refreshes the refresher, such as
+// a [Coordinator] so the refresh reaches all instances, on the schedule. Useful
+// for sources without webhooks, which need to be polled for changes.
+func Job(r Refresher, s schedule.Schedule) schedule.Job {
+	return schedule.Job{
+		Name:     "refresh",
+		Schedule: s,
+		Run: func(ctx context.Context) error {
+			return r.Refresh()
+		},
+	}
+}
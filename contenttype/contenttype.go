@@ -0,0 +1,271 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package contenttype provides configurable content types, such as posts, pages,
+// notes and photos, each with their own layout, permalink pattern and visibility
+// rules, so different kinds of content can coexist on the same blog.
+//
+// The type of a file is detected by it's "type" metadata or by the directory it
+// is in. Use [Processor] with the frontmatter plugin to set the type, layout and
+// permalink on the metadata of files, and [Visibility] to apply the visibility
+// rules of each type. Types can declare the [Schema] of their frontmatter, which
+// is checked by the Schemas rule of the validate package, gives templates typed
+// values through [FuncMap] and is served as JSON Schema to editors through
+// [NewSchemaMiddleware].
+package contenttype
+
+import (
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/slug"
+	"forge.capytal.company/loreddev/blogo/visibility"
+)
+
+// Metadata keys set by [Processor].
+const (
+	TypeKey      = "type"
+	LayoutKey    = "layout"
+	PermalinkKey = "permalink"
+)
+
+// A kind of content.
+type Type struct {
+	// Name of the type, matched against the "type" metadata of files.
+	Name string
+	// Glob patterns of the paths of the files of this type, with the same syntax
+	// as [visibility.Match], for example "notes/**".
+	Patterns []string
+	// Name of the layout used to render the files of this type, set as the
+	// "layout" metadata of files that don't define one.
+	Layout string
+	// Pattern of the URL path of the files, see [Permalink]. Files are served
+	// from their path on the file system if empty.
+	Permalink string
+	// Targets that the files of this type are hidden from, for example pages
+	// are commonly hidden from feeds.
+	Exclude []visibility.Target
+	// Frontmatter of the files of this type. Files aren't checked if nil.
+	Schema *Schema
+}
+
+// Built-in content types, used if none are provided to [New].
+var DefaultTypes = []Type{
+	{
+		Name:      "post",
+		Patterns:  []string{"posts/**"},
+		Layout:    "post",
+		Permalink: "/posts/:slug",
+	},
+	{
+		Name:     "page",
+		Patterns: []string{"*"},
+		Layout:   "page",
+		Exclude:  []visibility.Target{visibility.Feed},
+	},
+	{
+		Name:      "note",
+		Patterns:  []string{"notes/**"},
+		Layout:    "note",
+		Permalink: "/notes/:year/:month/:day/:slug",
+	},
+	{
+		Name:     "photo",
+		Patterns: []string{"photos/**"},
+		Layout:   "photo",
+	},
+	{
+		Name:      "event",
+		Patterns:  []string{"events/**"},
+		Layout:    "event",
+		Permalink: "/events/:slug",
+		Schema: &Schema{Fields: []Field{
+			{Name: "start", Kind: KindTime, Required: true, Description: "Start of the event, a date for events of whole days."},
+			{Name: "end", Kind: KindTime, Description: "End of the event, the start if not defined."},
+			{Name: "location", Kind: KindString, Description: "Place or address of the event."},
+		}},
+	},
+}
+
+// A collection of content types.
+type Registry interface {
+	// Returns the type of the file at the path, preferring it's "type" metadata
+	// over the directory it's in.
+	Detect(path string, m metadata.Metadata) (Type, bool)
+	// Returns the type with the name.
+	Get(name string) (Type, bool)
+	// Returns all the types, in the order they are matched.
+	Types() []Type
+}
+
+// Creates a [Registry] of the types, which are matched by their patterns in the
+// order provided. Uses [DefaultTypes] if none are provided.
+func New(types ...Type) Registry {
+	if len(types) == 0 {
+		types = DefaultTypes
+	}
+	return &registry{types: types}
+}
+
+type registry struct {
+	types []Type
+}
+
+func (r *registry) Detect(p string, m metadata.Metadata) (Type, bool) {
+	if m != nil {
+		if name, err := metadata.GetTyped[string](m, TypeKey); err == nil {
+			if t, ok := r.Get(name); ok {
+				return t, true
+			}
+		}
+	}
+
+	p = strings.TrimPrefix(p, "/")
+	for _, t := range r.types {
+		if slices.ContainsFunc(t.Patterns, func(pattern string) bool {
+			return visibility.Match(pattern, p)
+		}) {
+			return t, true
+		}
+	}
+
+	return Type{}, false
+}
+
+func (r *registry) Get(name string) (Type, bool) {
+	i := slices.IndexFunc(r.types, func(t Type) bool { return t.Name == name })
+	if i == -1 {
+		return Type{}, false
+	}
+	return r.types[i], true
+}
+
+func (r *registry) Types() []Type {
+	return slices.Clone(r.types)
+}
+
+// Returns a function, to be used as a processor of the frontmatter plugin, that
+// sets the "type", "layout" and "permalink" metadata of files from their detected
+// type, if they aren't already defined. The schema of the type, if any, is
+// applied to the metadata (see [(Schema).Apply]).
+func Processor(r Registry, opts ...ProcessorOpts) func(name string, m map[string]any) {
+	opt := ProcessorOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Location == nil {
+		opt.Location = time.UTC
+	}
+
+	return func(name string, m map[string]any) {
+		t, ok := r.Detect(name, metadata.Map(m))
+		if !ok {
+			return
+		}
+
+		if t.Schema != nil {
+			t.Schema.ApplyIn(m, opt.Location)
+		}
+
+		setDefault(m, TypeKey, t.Name)
+		if t.Layout != "" {
+			setDefault(m, LayoutKey, t.Layout)
+		}
+		if t.Permalink != "" {
+			setDefault(m, PermalinkKey, Permalink(t.Permalink, name, metadata.Map(m), opt.Location))
+		}
+	}
+}
+
+// Options used by [Processor].
+type ProcessorOpts struct {
+	// Time zone of the site, used by the date placeholders of permalinks (see
+	// [Permalink]) and to parse times of the schema without one (see
+	// [(Schema).ApplyIn]). Defaults to [time.UTC].
+	Location *time.Location
+}
+
+func setDefault(m map[string]any, key string, v any) {
+	if _, ok := m[key]; !ok {
+		m[key] = v
+	}
+}
+
+// Wraps the rules, also hiding files from the targets excluded by their type.
+// Uses [visibility.Default] if rules is nil.
+func Visibility(r Registry, rules visibility.Rules) visibility.Rules {
+	if rules == nil {
+		rules = visibility.Default
+	}
+	return visibility.Func(func(p string, m metadata.Metadata, target visibility.Target) bool {
+		if t, ok := r.Detect(p, m); ok && slices.Contains(t.Exclude, target) {
+			return false
+		}
+		return rules.Visible(p, m, target)
+	})
+}
+
+// Expands the permalink pattern for the file at the path with the metadata.
+// The following placeholders are replaced:
+//
+//   - ":year", ":month" and ":day" with the "date" metadata of the file, on
+//     the location, or [time.UTC] if it's nil;
+//   - ":slug" with the "slug" metadata, or the file name without it's extension;
+//   - ":title" with the slugified "title" metadata;
+//   - ":section" with the top-level directory of the file;
+//   - ":path" with the path of the file without it's extension.
+func Permalink(pattern, p string, m metadata.Metadata, loc *time.Location) string {
+	p = strings.TrimPrefix(p, "/")
+	noExt := strings.TrimSuffix(p, path.Ext(p))
+
+	s := path.Base(noExt)
+	if v, err := metadata.GetTyped[string](m, "slug"); err == nil && v != "" {
+		s = v
+	}
+
+	title := s
+	if v, err := metadata.GetTyped[string](m, "title"); err == nil && v != "" {
+		title = slug.Default.Slugify(v)
+	}
+
+	section, _, _ := strings.Cut(p, "/")
+	if !strings.Contains(p, "/") {
+		section = ""
+	}
+
+	// Date placeholders are removed if the file doesn't have a date.
+	year, month, day := "", "", ""
+	if loc == nil {
+		loc = time.UTC
+	}
+	if v, err := metadata.GetTimeIn(m, "date", loc); err == nil {
+		v = v.In(loc)
+		year, month, day = v.Format("2006"), v.Format("01"), v.Format("02")
+	}
+
+	return path.Clean("/" + strings.NewReplacer(
+		":year", year,
+		":month", month,
+		":day", day,
+		":slug", s,
+		":title", title,
+		":section", section,
+		":path", noExt,
+	).Replace(pattern))
+}
@@ -0,0 +1,300 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contenttype
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const schemaMiddlewareName = "blogo-contenttype-schema-middleware"
+
+// Version of JSON Schema of the schemas, the one with the widest support by
+// editors.
+const jsonSchemaVersion = "http://json-schema.org/draft-07/schema#"
+
+// Matches the [metadata.TimeLayouts] that [KindTime] fields accept.
+const timePattern = `^\d{4}-\d{2}-\d{2}([T ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}:\d{2})?)?$`
+
+// Returns the JSON Schema (https://json-schema.org) of the frontmatter of the
+// files of the type, so editors can validate and autocomplete it. Fields of the
+// schema of the type are described by their kind, enum, default, description
+// and if they are required, alongside the "type", "layout" and "permalink"
+// metadata set by [Processor]. Other fields are allowed.
+func (t Type) JSONSchema() map[string]any {
+	s := map[string]any{
+		"$schema": jsonSchemaVersion,
+		"title":   "Frontmatter of " + t.Name,
+	}
+	for k, v := range t.definition() {
+		s[k] = v
+	}
+	return s
+}
+
+// Returns the JSON Schema of the frontmatter of the files of all types of the
+// registry. The schema of each type applies to the files that declare it on
+// their "type" metadata, since the schema can't know the path of files, so
+// editors should prefer the schemas of each type (see [(Type).JSONSchema])
+// matched by the patterns of the types.
+func JSONSchema(r Registry) map[string]any {
+	types := r.Types()
+
+	names := make([]string, len(types))
+	defs := make(map[string]any, len(types))
+	conds := make([]any, 0, len(types))
+	for i, t := range types {
+		names[i] = t.Name
+		defs[t.Name] = t.definition()
+		conds = append(conds, map[string]any{
+			"if": map[string]any{
+				"properties": map[string]any{TypeKey: map[string]any{"const": t.Name}},
+				"required":   []string{TypeKey},
+			},
+			"then": map[string]any{"$ref": "#/definitions/" + t.Name},
+		})
+	}
+
+	return map[string]any{
+		"$schema": jsonSchemaVersion,
+		"title":   "Frontmatter",
+		"type":    "object",
+		"properties": map[string]any{
+			TypeKey:      map[string]any{"type": "string", "enum": names, "description": "Content type of the file."},
+			LayoutKey:    map[string]any{"type": "string", "description": "Layout used to render the file."},
+			PermalinkKey: map[string]any{"type": "string", "description": "URL path of the file."},
+		},
+		"allOf":       conds,
+		"definitions": defs,
+	}
+}
+
+// Returns the JSON Schema of the type, without the keywords of the root of a
+// schema document.
+func (t Type) definition() map[string]any {
+	props := map[string]any{
+		TypeKey:      map[string]any{"type": "string", "const": t.Name, "description": "Content type of the file."},
+		LayoutKey:    map[string]any{"type": "string", "description": "Layout used to render the file."},
+		PermalinkKey: map[string]any{"type": "string", "description": "URL path of the file."},
+	}
+	if t.Layout != "" {
+		props[LayoutKey].(map[string]any)["default"] = t.Layout
+	}
+
+	required := []string{}
+	if t.Schema != nil {
+		for _, f := range t.Schema.Fields {
+			props[f.Name] = f.jsonSchema()
+			if f.Required && f.Default == nil {
+				required = append(required, f.Name)
+			}
+		}
+	}
+
+	d := map[string]any{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		d["required"] = required
+	}
+	return d
+}
+
+func (f Field) jsonSchema() map[string]any {
+	s := map[string]any{}
+
+	switch f.Kind {
+	case KindString:
+		s["type"] = "string"
+	case KindInt:
+		s["type"] = "integer"
+	case KindFloat:
+		s["type"] = "number"
+	case KindBool:
+		s["type"] = "boolean"
+	case KindTime:
+		s["type"] = "string"
+		s["pattern"] = timePattern
+	case KindStrings:
+		// A single string is a list of one.
+		item := map[string]any{"type": "string"}
+		if len(f.Enum) > 0 {
+			item["enum"] = f.Enum
+		}
+		s["anyOf"] = []any{item, map[string]any{"type": "array", "items": item}}
+	}
+
+	if len(f.Enum) > 0 && f.Kind != KindStrings {
+		s["enum"] = enumJSON(f.Kind, f.Enum)
+	}
+	if f.Default != nil {
+		s["default"] = f.Default
+	}
+	if f.Description != "" {
+		s["description"] = f.Description
+	}
+
+	return s
+}
+
+// Converts the values of the enum to the JSON values of the kind, since enums
+// of fields are compared as strings.
+func enumJSON(k Kind, enum []string) []any {
+	vs := make([]any, 0, len(enum))
+	for _, e := range enum {
+		var v any = e
+		switch k {
+		case KindInt:
+			if n, err := strconv.Atoi(e); err == nil {
+				v = n
+			}
+		case KindFloat:
+			if n, err := strconv.ParseFloat(e, 64); err == nil {
+				v = n
+			}
+		case KindBool:
+			if b, err := strconv.ParseBool(e); err == nil {
+				v = b
+			}
+		}
+		vs = append(vs, v)
+	}
+	return vs
+}
+
+// Creates a [plugin.Middleware] that serves the JSON Schemas of the frontmatter
+// of the types of the registry, so editors can validate and autocomplete the
+// frontmatter while authors write. The schema of all types (see [JSONSchema])
+// is served on Opts.Path followed by ".json", such as "/.blogo/schema.json",
+// and the schema of each type (see [(Type).JSONSchema]) on Opts.Path followed
+// by the name of the type, such as "/.blogo/schema/post.json".
+//
+// For example, with the YAML extension of VS Code, the schema of each type can
+// be associated with the paths of it's patterns on the "yaml.schemas" setting.
+func NewSchemaMiddleware(r Registry, opts ...SchemaOpts) plugin.Middleware {
+	opt := SchemaOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Path == "" {
+		opt.Path = "/.blogo/schema"
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(r, "Registry should not be nil")
+
+	return &schemaMiddleware{
+		registry: r,
+		path:     "/" + strings.Trim(opt.Path, "/"),
+		baseURL:  strings.TrimSuffix(opt.BaseURL, "/"),
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type SchemaOpts struct {
+	// Path that the schemas are served on. Defaults to "/.blogo/schema".
+	Path string
+	// Base URL of the blog, such as "https://example.com", used as the "$id"
+	// of the schemas. The schemas don't have a "$id" if empty.
+	BaseURL string
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type schemaMiddleware struct {
+	registry Registry
+	path     string
+	baseURL  string
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (m *schemaMiddleware) Name() string {
+	return schemaMiddlewareName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (m *schemaMiddleware) SetLogger(logger *slog.Logger) {
+	if m.injectLogger {
+		m.log = logger
+	}
+}
+
+func (m *schemaMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.assert.NotNil(m.registry)
+		m.assert.NotNil(m.log)
+
+		var s map[string]any
+		switch p := r.URL.Path; {
+		case p == m.path+".json":
+			s = JSONSchema(m.registry)
+		case strings.HasPrefix(p, m.path+"/") && strings.HasSuffix(p, ".json"):
+			name := strings.TrimSuffix(strings.TrimPrefix(p, m.path+"/"), ".json")
+			t, ok := m.registry.Get(name)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			s = t.JSONSchema()
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if m.baseURL != "" {
+			s["$id"] = m.baseURL + r.URL.Path
+		}
+
+		w.Header().Set("Content-Type", "application/schema+json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(s); err != nil {
+			m.log.Error("Failed to write JSON Schema", slog.String("err", err.Error()))
+		}
+	})
+}
@@ -0,0 +1,289 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contenttype
+
+import (
+	"fmt"
+	"html/template"
+	"slices"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugins/frontmatter"
+)
+
+// Kind of the value of a [Field].
+type Kind string
+
+const (
+	KindString  Kind = "string"
+	KindInt     Kind = "int"
+	KindFloat   Kind = "float"
+	KindBool    Kind = "bool"
+	KindTime    Kind = "time"
+	KindStrings Kind = "strings"
+)
+
+// Zero value of the kind, used for missing fields by [(Schema).Values].
+func (k Kind) zero() any {
+	switch k {
+	case KindString:
+		return ""
+	case KindInt:
+		return 0
+	case KindFloat:
+		return 0.0
+	case KindBool:
+		return false
+	case KindTime:
+		return time.Time{}
+	case KindStrings:
+		return []string{}
+	default:
+		return nil
+	}
+}
+
+// A field of the frontmatter of a content type.
+type Field struct {
+	Name string
+	// Kind of the value. Values are converted to the Go type of the kind:
+	// string, int, float64, bool, [time.Time] (parsed from strings in one of
+	// [metadata.TimeLayouts]) and []string (a single string being a list of
+	// one). Fields without a kind accept any value.
+	Kind Kind
+	// Reports the entries that don't have the field, or have it empty.
+	Required bool
+	// Values allowed for the field, or for each value of [KindStrings] fields.
+	// Any value is allowed if empty.
+	Enum []string
+	// Value set on files that don't have the field.
+	Default any
+	// Description of the field, shown by editors that use the JSON Schema of
+	// the type, see [(Schema).JSONSchema].
+	Description string
+}
+
+// Declaration of the frontmatter of a content type (see Type.Schema), used to
+// validate files (see Schemas of the validate package) and to give templates
+// values with the Go types of their kinds (see [FuncMap]).
+type Schema struct {
+	Fields []Field
+}
+
+// A field of a file that doesn't follow it's [Schema].
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// Checks the metadata against the schema, returning the errors of each field
+// that doesn't follow it.
+func (s Schema) Check(m metadata.Metadata) []FieldError {
+	errs := []FieldError{}
+	for _, f := range s.Fields {
+		v, err := metadata.Get(m, f.Name)
+		if err != nil || v == nil || v == "" {
+			if f.Required && f.Default == nil {
+				errs = append(errs, FieldError{
+					Field:   f.Name,
+					Message: fmt.Sprintf("missing required field %q", f.Name),
+				})
+			}
+			continue
+		}
+
+		c, err := convert(f.Kind, v, time.UTC)
+		if err != nil {
+			errs = append(errs, FieldError{
+				Field:   f.Name,
+				Message: fmt.Sprintf("field %q %s", f.Name, err.Error()),
+			})
+			continue
+		}
+
+		if len(f.Enum) == 0 {
+			continue
+		}
+		for _, e := range enumValues(c) {
+			if !slices.Contains(f.Enum, e) {
+				errs = append(errs, FieldError{
+					Field: f.Name,
+					Message: fmt.Sprintf("field %q should be one of %s, got %q",
+						f.Name, strings.Join(f.Enum, ", "), e),
+				})
+			}
+		}
+	}
+	return errs
+}
+
+// Sets the defaults of the missing fields and converts the values of the other
+// fields to the Go types of their kinds. Values that can't be converted are kept
+// unchanged, so they can be reported by [(Schema).Check]. Times without a time
+// zone are parsed in [time.UTC], see [(Schema).ApplyIn].
+func (s Schema) Apply(m map[string]any) {
+	s.ApplyIn(m, time.UTC)
+}
+
+// Same as [(Schema).Apply], but times without a time zone, such as "2025-03-01",
+// are parsed in the location.
+func (s Schema) ApplyIn(m map[string]any, loc *time.Location) {
+	for _, f := range s.Fields {
+		v, ok := m[f.Name]
+		if !ok || v == nil {
+			if f.Default != nil {
+				m[f.Name] = f.Default
+			}
+			continue
+		}
+		if c, err := convert(f.Kind, v, loc); err == nil {
+			m[f.Name] = c
+		}
+	}
+}
+
+// Returns the values of all fields of the schema, with the Go types of their
+// kinds, and the zero value of the kind for missing fields and values that
+// can't be converted, so templates don't need to handle missing values.
+func (s Schema) Values(m metadata.Metadata) map[string]any {
+	values := make(map[string]any, len(s.Fields))
+	for _, f := range s.Fields {
+		values[f.Name] = f.Kind.zero()
+		if f.Default != nil {
+			values[f.Name] = f.Default
+		}
+
+		v, err := metadata.Get(m, f.Name)
+		if err != nil || v == nil {
+			continue
+		}
+		if c, err := convert(f.Kind, v, time.UTC); err == nil {
+			values[f.Name] = c
+		}
+	}
+	return values
+}
+
+// Converts the value to the Go type of the kind, parsing times without a time
+// zone in the location.
+func convert(k Kind, v any, loc *time.Location) (any, error) {
+	switch k {
+	case "":
+		return v, nil
+	case KindString:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case KindInt:
+		switch n := v.(type) {
+		case int:
+			return n, nil
+		case int64:
+			return int(n), nil
+		case uint64:
+			return int(n), nil
+		case float64:
+			if n == float64(int(n)) {
+				return int(n), nil
+			}
+		}
+	case KindFloat:
+		switch n := v.(type) {
+		case float64:
+			return n, nil
+		case int:
+			return float64(n), nil
+		case int64:
+			return float64(n), nil
+		case uint64:
+			return float64(n), nil
+		}
+	case KindBool:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case KindTime:
+		if t, err := metadata.GetTimeIn(metadata.Map{"v": v}, "v", loc); err == nil {
+			return t, nil
+		}
+	case KindStrings:
+		switch l := v.(type) {
+		case string:
+			return []string{l}, nil
+		case []string:
+			return l, nil
+		case []any:
+			ss := make([]string, 0, len(l))
+			for _, v := range l {
+				s, ok := v.(string)
+				if !ok {
+					return nil, fmt.Errorf("should be a list of strings, got a %T on the list", v)
+				}
+				ss = append(ss, s)
+			}
+			return ss, nil
+		}
+	default:
+		return nil, fmt.Errorf("has unknown kind %q", k)
+	}
+	return nil, fmt.Errorf("should be a %s, got a %T", k, v)
+}
+
+// Returns the values of the converted value compared with the enum of it's field.
+func enumValues(v any) []string {
+	switch v := v.(type) {
+	case []string:
+		return v
+	case string:
+		return []string{v}
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+// Returns the schema of the file, from the type detected by the registry.
+func schemaOf(r Registry, p string, m metadata.Metadata) (*Schema, bool) {
+	t, ok := r.Detect(p, m)
+	if !ok || t.Schema == nil {
+		return nil, false
+	}
+	return t.Schema, true
+}
+
+// Returns the template functions of the types of the registry:
+//
+//   - "fields METADATA" returns the values of the fields of the schema of the
+//     type of the file of the metadata (see [(Schema).Values]), with their Go
+//     types, such as "{{(fields .Metadata).date.Year}}", or a empty map if
+//     the type doesn't have a schema.
+func FuncMap(r Registry) template.FuncMap {
+	return template.FuncMap{
+		"fields": func(m metadata.Metadata) map[string]any {
+			p, _ := metadata.GetTyped[string](m, frontmatter.PathKey)
+			s, ok := schemaOf(r, p, m)
+			if !ok {
+				return map[string]any{}
+			}
+			return s.Values(m)
+		},
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contenttype
+
+import (
+	"io"
+	"io/fs"
+	"log/slog"
+	"strings"
+
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const sourcerName = "blogo-contenttype-sourcer"
+
+// Creates a [plugin.Sourcer] that wraps the provided sourcer, so files are also
+// opened by their permalink, as set in their "permalink" metadata (see [Processor]).
+// The original paths of files can still be opened.
+//
+// Permalinks are served over the files at the same path. If more than one file
+// has the same permalink, the first one in [fs.WalkDir] order is served and the
+// collision is logged as a error. This is the same winner of URL collisions on
+// the index package.
+//
+// The wrapped sourcer should provide the metadata of files, for example by using
+// the frontmatter plugin.
+func NewSourcer(sourcer plugin.Sourcer, opts ...SourcerOpts) plugin.Sourcer {
+	opt := SourcerOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be wrapped should not be nil")
+
+	return &permalinkSourcer{
+		sourcer: sourcer,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type SourcerOpts struct {
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type permalinkSourcer struct {
+	sourcer plugin.Sourcer
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (s *permalinkSourcer) Name() string {
+	return sourcerName
+}
+
+func (s *permalinkSourcer) Source() (fs.FS, error) {
+	s.assert.NotNil(s.sourcer)
+	s.assert.NotNil(s.log)
+
+	fsys, err := s.sourcer.Source()
+	if err != nil {
+		return fsys, err
+	}
+
+	log := s.log.With(slog.String("sourcer", s.sourcer.Name()))
+	log.Debug("Indexing permalinks")
+
+	links := map[string]string{}
+
+	err = fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		m, err := metadata.GetMetadata(d)
+		if err != nil {
+			return nil
+		}
+
+		link, err := metadata.GetTyped[string](m, PermalinkKey)
+		if err != nil || link == "" {
+			return nil
+		}
+		link = strings.Trim(link, "/")
+
+		if other, ok := links[link]; ok {
+			log.Error("Permalink collision, file is shadowed",
+				slog.String("permalink", link), slog.String("file", p), slog.String("other", other))
+			return nil
+		}
+		links[link] = p
+
+		return nil
+	})
+	if err != nil {
+		log.Warn("Failed to index permalinks", slog.String("err", err.Error()))
+	}
+
+	return &permalinkFS{FS: fsys, links: links}, nil
+}
+
+type permalinkFS struct {
+	fs.FS
+	links map[string]string
+}
+
+func (fsys *permalinkFS) Metadata() metadata.Metadata {
+	if m, err := metadata.GetMetadata(fsys.FS); err == nil {
+		return m
+	}
+	return metadata.Map(map[string]any{})
+}
+
+func (fsys *permalinkFS) Open(name string) (fs.File, error) {
+	if p, ok := fsys.links[name]; ok {
+		return fsys.FS.Open(p)
+	}
+	return fsys.FS.Open(name)
+}
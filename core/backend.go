@@ -0,0 +1,145 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Shared store of rendered files, so multiple instances of the server behind a
+// load balancer render each file only once, and invalidations (see
+// [(Server).Invalidate]) on any instance are applied to all of them.
+//
+// Renders are also cached in memory on each instance, the backend is used on
+// misses of the in-memory cache. The redis package provides a implementation.
+type CacheBackend interface {
+	// Returns the value of the key, and false if there's no value.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Sets the value of the key, which expires after the TTL.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Removes the values of the keys.
+	Delete(ctx context.Context, keys ...string) error
+	// Removes all values.
+	Clear(ctx context.Context) error
+
+	// Sends the invalidated names to the other instances. A empty list means that
+	// all renders were invalidated.
+	Broadcast(ctx context.Context, names []string) error
+	// Calls the function with the names of each broadcast of other instances,
+	// until the context is done. Implementations should reconnect on failures,
+	// only returning when the context is done.
+	Listen(ctx context.Context, f func(names []string)) error
+}
+
+// Timeout of each operation on the [CacheBackend], so a unavailable backend
+// only delays requests instead of holding them.
+const cacheBackendTimeout = time.Second
+
+// Returns the render of the file from the backend, caching it in memory.
+func (srv *server) backendGet(ctx context.Context, name string, log *slog.Logger) ([]byte, http.Header, bool) {
+	ctx, cancel := context.WithTimeout(ctx, cacheBackendTimeout)
+	defer cancel()
+
+	v, ok, err := srv.backend.Get(ctx, name)
+	if err != nil {
+		log.Warn("Failed to get render from cache backend", slog.String("err", err.Error()))
+		return nil, nil, false
+	} else if !ok {
+		return nil, nil, false
+	}
+
+	var r renderCacheRecord
+	if err := gob.NewDecoder(bytes.NewReader(v)).Decode(&r); err != nil {
+		log.Warn("Failed to decode render from cache backend", slog.String("err", err.Error()))
+		return nil, nil, false
+	}
+	if !srv.cache.verify(name, r) {
+		// The render is treated as a miss, so it's rendered again and replaced
+		// on the backend.
+		log.Warn("Discarding render of cache backend with invalid signature")
+		return nil, nil, false
+	}
+	if time.Now().After(r.Expires) {
+		return nil, nil, false
+	}
+
+	srv.cache.setRecord(name, r)
+
+	return r.Body, r.Header, true
+}
+
+func (srv *server) backendSet(ctx context.Context, name string, r renderCacheRecord, log *slog.Logger) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(srv.cache.sign(name, r)); err != nil {
+		log.Warn("Failed to encode render for cache backend", slog.String("err", err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cacheBackendTimeout)
+	defer cancel()
+
+	if err := srv.backend.Set(ctx, name, buf.Bytes(), time.Until(r.Expires)); err != nil {
+		log.Warn("Failed to set render on cache backend", slog.String("err", err.Error()))
+	}
+}
+
+// Removes the invalidated renders from the backend. The names are also removed,
+// since other instances may have cached files this instance didn't.
+func (srv *server) backendInvalidate(names, removed []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cacheBackendTimeout)
+	defer cancel()
+
+	if len(names) == 0 {
+		return srv.backend.Clear(ctx)
+	}
+
+	keys := append(append([]string{}, names...), removed...)
+	return srv.backend.Delete(ctx, keys...)
+}
+
+// Applies the invalidations broadcasted by other instances, until the backend
+// stops listening.
+func (srv *server) listen() {
+	log := srv.log.With(slog.String("cache", "backend"))
+
+	err := srv.backend.Listen(context.Background(), func(names []string) {
+		removed := srv.cache.invalidate(names...)
+		log.Debug("Applied invalidation of other instance",
+			slog.Any("names", names), slog.Int("removed", len(removed)))
+
+		if len(names) == 0 || len(removed) == 0 {
+			return
+		}
+
+		// Renders of the dependents known by this instance may have been shared
+		// with the backend, so they are removed from it too.
+		ctx, cancel := context.WithTimeout(context.Background(), cacheBackendTimeout)
+		defer cancel()
+
+		if err := srv.backend.Delete(ctx, removed...); err != nil {
+			log.Warn("Failed to remove invalidated renders from cache backend", slog.String("err", err.Error()))
+		}
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Error("Stopped listening to invalidations of other instances", slog.String("err", err.Error()))
+	}
+}
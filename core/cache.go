@@ -0,0 +1,427 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// In-memory cache of rendered files, by their path, which also indexes the
+// dependencies of each file so they can be invalidated selectively.
+type renderCache struct {
+	ttl        time.Duration
+	maxEntries int
+	// Duration after their expiration that renders are kept to be served while
+	// the server is overloaded, see [Overload].
+	stale time.Duration
+
+	mu         sync.Mutex
+	entries    map[string]renderCacheEntry
+	dependents map[string]map[string]struct{}
+
+	// Key of the signatures of persisted renders, see ServerOpts.CacheKey.
+	key []byte
+
+	// Persistence of the cache, see ServerOpts.CacheFile.
+	file        string
+	fingerprint string
+	saveTimer   *time.Timer
+	onSaveError func(error)
+}
+
+type renderCacheEntry struct {
+	body    []byte
+	header  http.Header
+	deps    []string
+	expires time.Time
+}
+
+func newRenderCache(ttl time.Duration, maxEntries int) *renderCache {
+	return &renderCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    map[string]renderCacheEntry{},
+		dependents: map[string]map[string]struct{}{},
+	}
+}
+
+// Returns the body of the cached render of the file, and the headers set by
+// it's renderer (see [plugin.ResponseRenderer]).
+func (c *renderCache) get(name string) ([]byte, http.Header, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[name]
+	if !ok {
+		return nil, nil, false
+	}
+	if now := time.Now(); now.After(e.expires) {
+		if now.After(e.expires.Add(c.stale)) {
+			c.delete(name)
+		}
+		return nil, nil, false
+	}
+	return e.body, e.header, true
+}
+
+// Same as get, but also returns renders that expired less than the stale
+// duration ago.
+func (c *renderCache) getStale(name string) ([]byte, http.Header, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[name]
+	if !ok || time.Now().After(e.expires.Add(c.stale)) {
+		return nil, nil, false
+	}
+	return e.body, e.header, true
+}
+
+// Caches the render of the file, returning it's record.
+func (c *renderCache) set(name string, body []byte, header http.Header, deps []string) renderCacheRecord {
+	r := renderCacheRecord{Body: body, Header: header, Deps: deps, Expires: time.Now().Add(c.ttl)}
+	c.setRecord(name, r)
+	return r
+}
+
+// Caches the render of the file, keeping the expiration of the record, such as
+// renders of other instances taken from a [CacheBackend].
+func (c *renderCache) setRecord(name string, r renderCacheRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= c.maxEntries {
+		c.entries = map[string]renderCacheEntry{}
+		c.dependents = map[string]map[string]struct{}{}
+	}
+
+	c.delete(name)
+	c.add(name, renderCacheEntry{body: r.Body, header: r.Header, deps: r.Deps, expires: r.Expires})
+	c.scheduleSave()
+}
+
+// Must be called with the mutex locked.
+func (c *renderCache) add(name string, e renderCacheEntry) {
+	c.entries[name] = e
+	for _, d := range e.deps {
+		if c.dependents[d] == nil {
+			c.dependents[d] = map[string]struct{}{}
+		}
+		c.dependents[d][name] = struct{}{}
+	}
+}
+
+// Removes the cached files that are, or depend on, any of the names, returning
+// the names of the removed files. Removes all files if no names are provided.
+func (c *renderCache) invalidate(names ...string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	defer c.scheduleSave()
+
+	removed := []string{}
+
+	if len(names) == 0 {
+		for name := range c.entries {
+			removed = append(removed, name)
+		}
+		c.entries = map[string]renderCacheEntry{}
+		c.dependents = map[string]map[string]struct{}{}
+		return removed
+	}
+
+	for _, name := range names {
+		if _, ok := c.entries[name]; ok {
+			c.delete(name)
+			removed = append(removed, name)
+		}
+		for dependent := range c.dependents[name] {
+			if _, ok := c.entries[dependent]; ok {
+				c.delete(dependent)
+				removed = append(removed, dependent)
+			}
+		}
+	}
+	return removed
+}
+
+// Must be called with the mutex locked.
+func (c *renderCache) delete(name string) {
+	e, ok := c.entries[name]
+	if !ok {
+		return
+	}
+	delete(c.entries, name)
+
+	for _, d := range e.deps {
+		delete(c.dependents[d], name)
+		if len(c.dependents[d]) == 0 {
+			delete(c.dependents, d)
+		}
+	}
+}
+
+// Version of the format of the cache file, files of other versions are ignored.
+const renderCacheVersion = 2
+
+// Delay between a change in the cache and it being saved to the cache file, so
+// bursts of renders are saved only once.
+const renderCacheSaveDelay = 5 * time.Second
+
+type renderCacheFile struct {
+	Version     int
+	Fingerprint string
+	Entries     map[string]renderCacheRecord
+}
+
+// A cached render, as saved on the cache file and on [CacheBackend]s.
+type renderCacheRecord struct {
+	Body    []byte
+	Header  http.Header
+	Deps    []string
+	Expires time.Time
+
+	// HMAC-SHA256 of the other fields and the name of the file, see
+	// [(*renderCache).sign].
+	Signature []byte
+}
+
+// Signs the record of the render of the file, so it can be verified when it's
+// loaded from the cache file or a [CacheBackend].
+func (c *renderCache) sign(name string, r renderCacheRecord) renderCacheRecord {
+	r.Signature = c.signature(name, r)
+	return r
+}
+
+// Reports if the record of the render of the file has a valid signature, which
+// means it wasn't corrupted or tampered with since it was signed.
+func (c *renderCache) verify(name string, r renderCacheRecord) bool {
+	return len(r.Signature) > 0 && hmac.Equal(r.Signature, c.signature(name, r))
+}
+
+func (c *renderCache) signature(name string, r renderCacheRecord) []byte {
+	h := hmac.New(sha256.New, c.key)
+
+	// Each field is prefixed by it's length, so values can't be moved between
+	// fields without changing the signature.
+	field := func(v []byte) {
+		_ = binary.Write(h, binary.BigEndian, uint64(len(v)))
+		h.Write(v)
+	}
+
+	field([]byte(name))
+	field(r.Body)
+
+	keys := make([]string, 0, len(r.Header))
+	for k := range r.Header {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	_ = binary.Write(h, binary.BigEndian, uint64(len(keys)))
+	for _, k := range keys {
+		field([]byte(k))
+		_ = binary.Write(h, binary.BigEndian, uint64(len(r.Header[k])))
+		for _, v := range r.Header[k] {
+			field([]byte(v))
+		}
+	}
+
+	_ = binary.Write(h, binary.BigEndian, uint64(len(r.Deps)))
+	for _, d := range r.Deps {
+		field([]byte(d))
+	}
+
+	_ = binary.Write(h, binary.BigEndian, r.Expires.UnixNano())
+
+	return h.Sum(nil)
+}
+
+// Sets the fingerprint of the files being rendered. On the first call, the
+// entries of the cache file are loaded if it was saved with the same fingerprint,
+// returning the number of loaded entries and of entries discarded because their
+// signature is invalid. When the fingerprint changes, all entries are removed,
+// since they were rendered from other files.
+func (c *renderCache) restore(fingerprint string) (int, int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.fingerprint == fingerprint {
+		return 0, 0, nil
+	}
+
+	if c.fingerprint != "" {
+		c.fingerprint = fingerprint
+		c.entries = map[string]renderCacheEntry{}
+		c.dependents = map[string]map[string]struct{}{}
+		c.scheduleSave()
+		return 0, 0, nil
+	}
+	c.fingerprint = fingerprint
+
+	f, err := os.Open(c.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, errors.Join(errors.New("failed to open cache file"), err)
+	}
+	defer f.Close()
+
+	var data renderCacheFile
+	if err := gob.NewDecoder(f).Decode(&data); err != nil {
+		// The file is replaced on the next save, so a corrupted file is rebuilt
+		// from the new renders.
+		c.scheduleSave()
+		return 0, 0, errors.Join(errors.New("failed to decode cache file"), err)
+	}
+	if data.Version != renderCacheVersion || data.Fingerprint != fingerprint {
+		return 0, 0, nil
+	}
+
+	n, invalid := c.load(data.Entries, false)
+	if invalid > 0 {
+		c.scheduleSave()
+	}
+
+	return n, invalid, nil
+}
+
+// Adds the records that have valid signatures and haven't expired, returning
+// the number of added records and of records with invalid signatures. If
+// refresh is true, the expiration of the records is reset, instead of kept.
+// Must be called with the mutex locked.
+func (c *renderCache) load(records map[string]renderCacheRecord, refresh bool) (int, int) {
+	now := time.Now()
+	n, invalid := 0, 0
+	for name, e := range records {
+		if !c.verify(name, e) {
+			invalid++
+			continue
+		}
+		if refresh {
+			e.Expires = now.Add(c.ttl)
+		}
+		if now.After(e.Expires.Add(c.stale)) || len(c.entries) >= c.maxEntries {
+			continue
+		}
+		c.delete(name)
+		c.add(name, renderCacheEntry{body: e.Body, header: e.Header, deps: e.Deps, expires: e.Expires})
+		n++
+	}
+	return n, invalid
+}
+
+// Must be called with the mutex locked.
+func (c *renderCache) scheduleSave() {
+	if c.file == "" || c.fingerprint == "" || c.saveTimer != nil {
+		return
+	}
+	c.saveTimer = time.AfterFunc(renderCacheSaveDelay, func() {
+		if err := c.save(); err != nil && c.onSaveError != nil {
+			c.onSaveError(err)
+		}
+	})
+}
+
+// Writes the entries of the cache to the cache file. The file is replaced
+// atomically, so a crash while saving doesn't corrupt it.
+func (c *renderCache) save() error {
+	c.mu.Lock()
+	c.saveTimer = nil
+	data := c.snapshot()
+	c.mu.Unlock()
+
+	return writeFileAtomic(c.file, func(f *os.File) error {
+		return gob.NewEncoder(f).Encode(data)
+	})
+}
+
+// Returns the signed records of the entries, as saved on the cache file. Must be
+// called with the mutex locked.
+func (c *renderCache) snapshot() renderCacheFile {
+	data := renderCacheFile{
+		Version:     renderCacheVersion,
+		Fingerprint: c.fingerprint,
+		Entries:     make(map[string]renderCacheRecord, len(c.entries)),
+	}
+	for name, e := range c.entries {
+		data.Entries[name] = c.sign(name, renderCacheRecord{
+			Body: e.body, Header: e.header, Deps: e.deps, Expires: e.expires,
+		})
+	}
+	return data
+}
+
+// Writes the file by creating a temporary file on the same directory and renaming
+// it, so readers never see a partially written file.
+func writeFileAtomic(name string, write func(*os.File) error) error {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return errors.Join(errors.New("failed to create temporary file"), err)
+	}
+	defer os.Remove(f.Name())
+
+	if err := write(f); err != nil {
+		f.Close()
+		return errors.Join(errors.New("failed to write temporary file"), err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Join(errors.New("failed to close temporary file"), err)
+	}
+
+	if err := os.Rename(f.Name(), name); err != nil {
+		return errors.Join(errors.New("failed to replace file"), err)
+	}
+
+	return nil
+}
+
+// [http.ResponseWriter] that copies the body of successful responses, so they
+// can be cached.
+type cacheWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *cacheWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *cacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -16,67 +16,240 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"log/slog"
 	"net/http"
-	"strings"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"forge.capytal.company/loreddev/blogo/changes"
 	"forge.capytal.company/loreddev/blogo/plugin"
 	"forge.capytal.company/loreddev/x/tinyssert"
 )
 
 // Creates a implementation of [http.Handler] that maps the [(*http.Request).Path] to a file of the
-// same name in the file system provided by the sourcer. Use [Opts] to have more fine grained control
-// over some additional behaviour of the implementation.
+// same name in the file system provided by the sourcer. Use [ServerOpts] or the functional
+// [ServerOption]s to have more fine grained control over some additional behaviour of the
+// implementation.
+//
+// Panics if ServerOpts.SourceOnInit is set and sourcing fails, use [NewServerE] to
+// handle the error instead.
 func NewServer(
 	sourcer plugin.Sourcer,
 	renderer plugin.Renderer,
 	onerror plugin.ErrorHandler,
-	opts ...ServerOpts,
-) http.Handler {
-	opt := ServerOpts{}
-	if len(opts) > 0 {
-		opt = opts[0]
+	opts ...ServerOption,
+) Server {
+	srv, err := NewServerE(sourcer, renderer, onerror, opts...)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to source files on initialization due to error: %s",
+			err.Error(),
+		))
+	}
+	return srv
+}
+
+// Same as [NewServer], but returns the error of ServerOpts.SourceOnInit instead
+// of panicking. The returned server is still usable if there's a error, sourcing
+// the files on the first request or on [(Server).Start].
+func NewServerE(
+	sourcer plugin.Sourcer,
+	renderer plugin.Renderer,
+	onerror plugin.ErrorHandler,
+	opts ...ServerOption,
+) (Server, error) {
+	opt := ResolveOptions(opts...)
+	if opt.StartBackoff == 0 {
+		opt.StartBackoff = 100 * time.Millisecond
+	}
+	if opt.StartMaxBackoff == 0 {
+		opt.StartMaxBackoff = 30 * time.Second
+	}
+	if opt.CacheMaxEntries == 0 {
+		opt.CacheMaxEntries = 1024
 	}
 	if opt.Assertions == nil {
 		opt.Assertions = tinyssert.NewDisabledAssertions()
 	}
+	if opt.Logger == nil && opt.LogHandler != nil {
+		opt.Logger = slog.New(opt.LogHandler)
+	}
 	if opt.Logger == nil {
 		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
 	}
 
-	var filesystem fs.FS
+	srv := &server{
+		sourcer:  sourcer,
+		renderer: renderer,
+		onerror:  onerror,
+
+		stageHandlers: map[Stage]plugin.ErrorHandler{
+			StageSource:    opt.SourceErrorHandler,
+			StageOpen:      opt.OpenErrorHandler,
+			StageRender:    opt.RenderErrorHandler,
+			StageAdmission: opt.AdmissionErrorHandler,
+		},
+
+		backoff:    opt.StartBackoff,
+		maxBackoff: opt.StartMaxBackoff,
+
+		maxFileSize:   opt.MaxFileSize,
+		maxOutputSize: opt.MaxOutputSize,
+		renderTimeout: opt.RenderTimeout,
+
+		reporter: opt.ErrorReporter,
+		launch:   opt.Launch,
+		overload: opt.Overload,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+
+	if opt.Metrics != nil {
+		opt.Metrics.Register(&srv.metrics)
+		for _, p := range []plugin.Plugin{sourcer, renderer, onerror} {
+			if p, ok := p.(plugin.Instrumented); ok {
+				opt.Metrics.Register(p)
+			}
+		}
+	}
+
+	if opt.CacheTTL > 0 {
+		srv.cache = newRenderCache(opt.CacheTTL, opt.CacheMaxEntries)
+		srv.cache.file = opt.CacheFile
+		srv.cache.key = opt.CacheKey
+		if opt.Overload != nil {
+			opt.Overload.init()
+			srv.cache.stale = opt.Overload.Stale
+		}
+		srv.cache.onSaveError = func(err error) {
+			srv.log.Error("Failed to save cache file",
+				slog.String("file", opt.CacheFile), slog.String("err", err.Error()))
+		}
+	}
+	if srv.cache != nil && opt.CacheBackend != nil {
+		srv.backend = opt.CacheBackend
+		go srv.listen()
+	}
+	if opt.CacheFingerprint == nil {
+		opt.CacheFingerprint = changes.Fingerprint
+	}
+	srv.fingerprint = opt.CacheFingerprint
+	srv.cacheImport = opt.CacheImport
+
 	if opt.SourceOnInit {
 		fs, err := sourcer.Source()
 		if err != nil {
-			panic(fmt.Sprintf("Failed to source files on initialization due to error: %s",
-				err.Error(),
-			))
+			return srv, errors.Join(errors.New("failed to source files on initialization"), err)
 		}
-		filesystem = fs
+		srv.setFiles(fs)
 	}
 
-	return &server{
-		files: filesystem,
+	return srv, nil
+}
 
-		sourcer:  sourcer,
-		renderer: renderer,
-		onerror:  onerror,
+// The [http.Handler] implementation of [NewServer].
+type Server interface {
+	http.Handler
+	// Sources the files before the server goes live, retrying with exponential
+	// backoff (see ServerOpts.StartBackoff) until it succeeds or the context is
+	// done. While Start is sourcing the files, requests are responded with
+	// "503 Service Unavailable". Calling Start is optional, without it the files
+	// are sourced on the first request.
+	Start(ctx context.Context) error
+	// Reports if the files have been sourced and the server is ready to serve them.
+	Ready() bool
+	// Removes the cached renders (see ServerOpts.CacheTTL) of the files, and of
+	// the files that depend on them or on the named dependencies, returning the
+	// number of removed renders. Removes all renders if no names are provided.
+	// See [Dependencies] for how dependencies are recorded. With a
+	// ServerOpts.CacheBackend, the invalidation is also sent to all instances.
+	Invalidate(names ...string) int
 
-		assert: opt.Assertions,
-		log:    opt.Logger,
-	}
+	// Writes the cached renders (see ServerOpts.CacheTTL), signed with
+	// ServerOpts.CacheKey, alongside the fingerprint of the files they were
+	// rendered from, sourcing the files if needed.
+	ExportCache(w io.Writer) error
+	// Loads the renders written by ExportCache, returning the number of loaded
+	// renders, or [ErrCacheMismatch] if they were rendered from other files. The
+	// expiration of the renders is reset, and ones with invalid signatures are
+	// discarded. See also ServerOpts.CacheImport.
+	ImportCache(r io.Reader) (int, error)
+	// Renders the files of the paths, or all files and directories if none are
+	// provided, filling the cache, such as before ExportCache on CI. Returns the
+	// number of successful renders, logging the failures.
+	Prerender(ctx context.Context, paths ...string) (int, error)
+
+	// Responds to requests rejected before being served, such as by
+	// middlewares with rate limits, with the error handler of [StageAdmission].
+	// See [Reject].
+	Rejecter
 }
 
 // Options used in the construction of the server/[http.Handler] in [NewServer] to better
 // control additional behaviour of the implementation.
 type ServerOpts struct {
 	// Call [(plugin.Sourcer).Source] on construction of the implementation on [NewServer]?
-	// Panics if the it returns a error. By default sourcing of files is done on the first
-	// request.
+	// [NewServer] panics and [NewServerE] returns the error if it fails. By default
+	// sourcing of files is done on the first request.
 	SourceOnInit bool
+
+	// Initial delay between retries of [(Server).Start], doubled on each attempt.
+	// Defaults to 100ms.
+	StartBackoff time.Duration
+	// Max delay between retries of [(Server).Start]. Defaults to 30s.
+	StartMaxBackoff time.Duration
+
+	// Duration that rendered files are cached in memory, by their path. Only
+	// successful renders of GET requests are cached. By default files are
+	// rendered on every request. Use [(Server).Invalidate] to remove renders
+	// before they expire.
+	CacheTTL time.Duration
+	// Max number of cached files. When reached, the cache is cleared. Defaults to 1024.
+	CacheMaxEntries int
+	// Path of the file where the cached renders are saved, so they are reloaded
+	// when the server restarts and the files haven't changed (see CacheFingerprint),
+	// keeping the cache warm across deploys. Renders are saved a few seconds after
+	// the cache changes. By default the cache is only kept in memory.
+	CacheFile string
+	// Function used to check if the sourced files are the same ones that the
+	// renders of CacheFile were rendered from. Defaults to [changes.Fingerprint],
+	// which compares the paths, sizes and modification times of all files. File
+	// systems that don't provide modification times should use something more
+	// precise, such as the commit of the repository.
+	CacheFingerprint func(fs.FS) (string, error)
+	// Backend shared by multiple instances of the server, where renders are stored
+	// on misses of the in-memory cache, and invalidations are broadcasted to all
+	// instances. Only used if the cache is enabled with CacheTTL.
+	CacheBackend CacheBackend
+	// Key used to sign the renders saved on CacheFile and CacheBackend with
+	// HMAC-SHA256. Renders are verified when loaded, and the ones that were
+	// corrupted or tampered with are discarded and rendered again instead of
+	// served. Without a key, renders are still verified against corruption, but
+	// anyone who can write to the cache can forge them.
+	CacheKey []byte
+	// Path of a cache exported with [(Server).ExportCache], such as a artifact of
+	// the prerender of the site on CI, imported when the files are first sourced
+	// if it was rendered from the same files, so new deployments never serve
+	// with a cold cache. Missing files are ignored.
+	CacheImport string
+
+	// Error handlers used for the errors of each stage instead of the error handler
+	// passed to [NewServer], since recoveries of one stage rarely make sense on the
+	// others. Nil handlers default to the error handler of [NewServer].
+	SourceErrorHandler plugin.ErrorHandler
+	OpenErrorHandler   plugin.ErrorHandler
+	RenderErrorHandler plugin.ErrorHandler
+	// Error handler of rejected requests, see [RejectError]. Rejections that
+	// aren't handled are responded with [RespondRejected].
+	AdmissionErrorHandler plugin.ErrorHandler
+
 	// [tinyssert.Assertions] implementation used by server for it's Assertions, by default
 	// uses [tinyssert.NewDisabledAssertions] to effectively disable assertions. Use this
 	// if you want to the server to fail-fast on incorrect states.
@@ -85,15 +258,75 @@ type ServerOpts struct {
 	// and debugging the pipeline of files. By default it uses a logger that writes to [io.Discard],
 	// effectively disabling logging.
 	Logger *slog.Logger
+	// Handler of the logger, used if Logger is nil.
+	LogHandler slog.Handler
+
+	// Max size, in bytes, of the files served. Larger files are responded by the
+	// error handler with a [FileSizeError], protecting the server of accidentally
+	// committed giant files. By default there's no limit.
+	MaxFileSize int64
+	// Max size, in bytes, of the output of renders. When set, the output of
+	// renders is buffered, and renders that exceed it are responded by the error
+	// handler with a [OutputSizeError]. By default there's no limit.
+	MaxOutputSize int64
+
+	// Max duration of the render of a file. Renders that exceed it are aborted with
+	// a [RenderTimeoutError], so pathological files can't hold connections forever.
+	// By default renders don't have a timeout, only the cancellation of the request.
+	RenderTimeout time.Duration
+
+	// Reporter of errors that aren't recovered by the error handlers. If set, the
+	// server also recovers panics while serving requests, reporting them and
+	// responding with "500 Internal Server Error".
+	ErrorReporter ErrorReporter
+
+	// Registry where the counters of the server are registered, alongside the
+	// sourcer, renderer and error handler if they implement [plugin.Instrumented].
+	// Serve it to expose the counters as a debug endpoint. The default engine
+	// also registers all of it's plugins.
+	Metrics *Metrics
+
+	// Gate of the site before it's launch, which only lets through requests
+	// with it's token, of it's IP addresses and of feed validators, see
+	// [LaunchGate]. By default the site is open.
+	Launch *LaunchGate
+
+	// Degradation profile of the server when the concurrent renders exceed a
+	// threshold, serving stale renders of the cache, skipping expensive steps of
+	// renderers and shedding the remainder of requests, see [Overload]. By
+	// default renders aren't limited.
+	Overload *Overload
 }
 
 type server struct {
-	files fs.FS
+	files    fs.FS
+	filesMu  sync.RWMutex
+	starting atomic.Bool
+
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	cache       *renderCache
+	backend     CacheBackend
+	fingerprint func(fs.FS) (string, error)
+	cacheImport string
+	importOnce  sync.Once
+
+	maxFileSize   int64
+	maxOutputSize int64
+	renderTimeout time.Duration
+
+	reporter ErrorReporter
+	launch   *LaunchGate
+	overload *Overload
+	metrics  serverMetrics
 
 	sourcer  plugin.Sourcer
 	renderer plugin.Renderer
 	onerror  plugin.ErrorHandler
 
+	stageHandlers map[Stage]plugin.ErrorHandler
+
 	assert tinyssert.Assertions
 	log    *slog.Logger
 }
@@ -106,38 +339,463 @@ func (srv *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	log := srv.log.With(slog.String("path", r.URL.Path))
 	log.Debug("Serving endpoint")
 
-	if srv.files == nil {
-		err := srv.serveHTTPSource(w, r)
-		if err != nil {
+	srv.metrics.requests.Add(1)
+
+	start := time.Now()
+	stage := StageSource
+
+	path, perr := FilePath(r.URL.Path)
+	if nc := (NonCanonicalPathError{}); errors.As(perr, &nc) {
+		log.Debug("Redirecting to clean path", slog.String("clean", nc.Path))
+
+		u := nc.Path
+		if r.URL.RawQuery != "" {
+			u += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, u, http.StatusMovedPermanently)
+		return
+	} else if perr != nil {
+		log.Debug("Invalid path", slog.String("err", perr.Error()))
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if srv.reporter != nil {
+		tw := &trackingWriter{ResponseWriter: w}
+		w = tw
+
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			err := &PanicError{Value: v, Stack: debug.Stack()}
+			log.Error("Panic while serving request",
+				slog.String("err", err.Error()), slog.String("stage", string(stage)))
+
+			srv.reporter.Report(r.Context(), srv.serveError(stage, path, start, w, r, err))
+
+			if !tw.written {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+		}()
+	}
+
+	if srv.launch != nil && !srv.launch.allow(w, r) {
+		log.Debug("Request didn't pass the launch gate")
+		srv.launch.respond(w)
+		return
+	}
+
+	if srv.starting.Load() && !srv.Ready() {
+		log.Debug("Server is starting, files not sourced yet")
+
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Server is starting", http.StatusServiceUnavailable)
+		return
+	}
+
+	_, overridden := FilesFromContext(r.Context())
+	variant, hasVariant := CacheVariantFromContext(r.Context())
+
+	cacheKey := path
+	if hasVariant {
+		cacheKey = path + "?variant=" + variant
+	}
+
+	cacheable := srv.cache != nil && r.Method == http.MethodGet && (!overridden || hasVariant)
+	if cacheable {
+		body, header, ok := srv.cache.get(cacheKey)
+		if !ok && srv.backend != nil {
+			body, header, ok = srv.backendGet(r.Context(), cacheKey, log)
+		}
+		if !ok {
+			srv.metrics.cacheMisses.Add(1)
+		} else {
+			srv.metrics.cacheHits.Add(1)
+			log.Debug("Serving rendered file from cache")
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			if _, err := w.Write(body); err != nil {
+				log.Error("Failed to write cached file", slog.String("err", err.Error()))
+			}
 			return
 		}
 	}
 
-	path := strings.Trim(r.URL.Path, "/")
-	if path == "" || path == "/" {
-		path = "."
+	if srv.overload != nil {
+		degraded := srv.overload.Overloaded()
+		if cacheable && degraded {
+			if body, header, ok := srv.cache.getStale(cacheKey); ok {
+				srv.metrics.staleHits.Add(1)
+				log.Debug("Server overloaded, serving stale render from cache")
+				for k, v := range header {
+					w.Header()[k] = v
+				}
+				if _, err := w.Write(body); err != nil {
+					log.Error("Failed to write cached file", slog.String("err", err.Error()))
+				}
+				return
+			}
+		}
+
+		release, ok := srv.overload.acquire(r.Context())
+		if !ok {
+			srv.metrics.shed.Add(1)
+			log.Warn("Server overloaded, shedding request",
+				slog.Int("renders", srv.overload.Renders()))
+
+			srv.Reject(w, r, RejectError{
+				Status:     http.StatusServiceUnavailable,
+				RetryAfter: srv.overload.RetryAfter,
+				Reason:     "Server overloaded",
+			})
+			return
+		}
+		defer release()
+
+		if degraded {
+			// Degraded renders aren't cached, so the full render is done once
+			// the load goes down.
+			cacheable = false
+			r = r.WithContext(WithDegraded(r.Context()))
+		}
+	}
+
+	if !srv.Ready() && !overridden {
+		err := srv.serveHTTPSource(path, start, w, r)
+		if err != nil {
+			srv.metrics.failures.Add(1)
+			return
+		}
 	}
 
-	file, err := srv.serveHTTPOpenFile(path, w, r)
+	stage = StageOpen
+	file, err := srv.serveHTTPOpenFile(path, start, w, r)
 	if err != nil {
+		srv.metrics.failures.Add(1)
 		return
 	}
 
-	// Defers the closing of the file to prevent memory being held if a renderer
-	// does not properly closes the file.
-	defer file.Close()
+	// The file is closed by serveHTTPRender, to prevent memory being held if a
+	// renderer does not properly closes the file.
+
+	var cw *cacheWriter
+	var deps *Dependencies
+	if cacheable {
+		cw = &cacheWriter{ResponseWriter: w}
+		w = cw
+
+		var ctx context.Context
+		ctx, deps = WithDependencies(r.Context())
+		r = r.WithContext(ctx)
+	}
 
-	err = srv.serveHTTPRender(file, w, r)
+	stage = StageRender
+	r = r.WithContext(context.WithValue(r.Context(), pathKey{}, path))
+	res, err := srv.serveHTTPRender(path, start, file, w, r)
 	if err != nil {
+		srv.metrics.failures.Add(1)
 		return
 	}
+	srv.metrics.renders.Add(1)
+
+	if cw != nil && (cw.status == 0 || cw.status == http.StatusOK) {
+		if hasVariant {
+			// Variants are invalidated alongside the path.
+			deps.Add(path)
+		}
+		record := srv.cache.set(cacheKey, cw.body.Bytes(), res.header, deps.Names())
+		if srv.backend != nil {
+			srv.backendSet(r.Context(), cacheKey, record, log)
+		}
+	}
 
 	log.Debug("Finished serving endpoint")
 }
 
-func (srv *server) serveHTTPSource(w http.ResponseWriter, r *http.Request) error {
+func (srv *server) Start(ctx context.Context) error {
+	srv.assert.NotNil(ctx)
+	srv.assert.NotNil(srv.sourcer, "A sourcer needs to be available")
+	srv.assert.NotNil(srv.log)
+
+	if srv.Ready() {
+		return nil
+	}
+
+	srv.starting.Store(true)
+	defer srv.starting.Store(false)
+
+	log := srv.log.With(slog.String("sourcer", srv.sourcer.Name()))
+
+	backoff := srv.backoff
+	for attempt := 1; ; attempt++ {
+		log := log.With(slog.Int("attempt", attempt))
+		log.Debug("Sourcing files on start")
+
+		fs, err := srv.sourcer.Source()
+		if err == nil {
+			srv.setFiles(fs)
+			log.Debug("Files sourced, server is ready")
+			return nil
+		}
+
+		log.Warn("Failed to source files on start, retrying",
+			slog.String("err", err.Error()), slog.Duration("backoff", backoff))
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(errors.New("failed to source files on start"), err, ctx.Err())
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, srv.maxBackoff)
+	}
+}
+
+func (srv *server) Invalidate(names ...string) int {
+	if srv.cache == nil {
+		return 0
+	}
+
+	removed := srv.cache.invalidate(names...)
+	srv.log.Debug("Invalidated cached renders", slog.Any("names", names), slog.Int("removed", len(removed)))
+
+	if srv.backend != nil {
+		if err := srv.backendInvalidate(names, removed); err != nil {
+			srv.log.Warn("Failed to remove invalidated renders from cache backend",
+				slog.String("err", err.Error()))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), cacheBackendTimeout)
+		defer cancel()
+
+		if err := srv.backend.Broadcast(ctx, names); err != nil {
+			srv.log.Warn("Failed to broadcast invalidation to other instances",
+				slog.String("err", err.Error()))
+		}
+	}
+
+	return len(removed)
+}
+
+func (srv *server) Ready() bool {
+	srv.filesMu.RLock()
+	defer srv.filesMu.RUnlock()
+	return srv.files != nil
+}
+
+func (srv *server) getFiles() fs.FS {
+	srv.filesMu.RLock()
+	defer srv.filesMu.RUnlock()
+	return srv.files
+}
+
+func (srv *server) setFiles(fsys fs.FS) {
+	if srv.cache != nil && srv.cache.file != "" {
+		srv.restoreCache(fsys)
+	}
+	if srv.cache != nil && srv.cacheImport != "" {
+		srv.importOnce.Do(func() { srv.importCacheFile(fsys) })
+	}
+
+	srv.filesMu.Lock()
+	defer srv.filesMu.Unlock()
+	srv.files = fsys
+}
+
+// Loads the persisted renders of ServerOpts.CacheFile, if they were rendered
+// from the same files.
+func (srv *server) restoreCache(fsys fs.FS) {
+	log := srv.log.With(slog.String("file", srv.cache.file))
+
+	fingerprint, err := srv.fingerprint(fsys)
+	if err != nil {
+		log.Warn("Failed to fingerprint files, cache file not loaded", slog.String("err", err.Error()))
+		return
+	}
+
+	n, invalid, err := srv.cache.restore(fingerprint)
+	if err != nil {
+		log.Warn("Failed to load cache file", slog.String("err", err.Error()))
+		return
+	}
+	if invalid > 0 {
+		log.Warn("Discarded renders of cache file with invalid signatures", slog.Int("entries", invalid))
+	}
+
+	log.Debug("Cache restored", slog.String("fingerprint", fingerprint), slog.Int("entries", n))
+}
+
+// Creates the [ServeError] of a failure on the stage, passed to the error handler.
+func (srv *server) serveError(
+	stage Stage,
+	name string,
+	start time.Time,
+	w http.ResponseWriter,
+	r *http.Request,
+	err error,
+) ServeError {
+	return ServeError{
+		Res: w,
+		Req: r,
+		Err: err,
+
+		Path:    name,
+		Stage:   stage,
+		Elapsed: time.Since(start),
+		Logger: srv.log.With(
+			slog.String("path", r.URL.Path),
+			slog.String("filename", name),
+			slog.String("stage", string(stage)),
+		),
+	}
+}
+
+// Passes the error to the error handler, returning the [Recovery] if it is
+// applicable to the stage of the error, as reported by applicable.
+//
+// If the handler recovers with a [Responder], or doesn't recover with a
+// applicable value, the response is written (by the Responder or with the
+// fallback response if nothing was written yet) and ok is false, so the
+// caller should stop serving the request.
+//
+// Stages that may have written part of the response before the error, such as
+// renders, should pass a [trackingWriter] as the response of the error, marked
+// as written if they did, so nothing is written on top of it.
+func (srv *server) handleError(
+	serr ServeError,
+	applicable func(Recovery) bool,
+	log *slog.Logger,
+) (recovr Recovery, ok bool) {
+	srv.assert.NotNil(applicable)
+	srv.assert.NotNil(log)
+
+	onerror := srv.errorHandler(serr.Stage)
+	srv.assert.NotNil(onerror, "An error handler needs to be available in cases of errors")
+
+	w, ok := serr.Res.(*trackingWriter)
+	if !ok {
+		w = &trackingWriter{ResponseWriter: serr.Res}
+		serr.Res = w
+	}
+	partial := w.written
+
+	v, handled := onerror.Handle(serr)
+	if !handled {
+		log.Error("Failed to handle error with plugin")
+		srv.report(serr)
+
+		if partial {
+			log.Warn("Response was partially written before the error, not writing fallback response")
+			return Recovery{}, false
+		}
+		if w.written {
+			log.Warn("Error handler wrote to response without handling the error, not writing fallback response")
+			return Recovery{}, false
+		}
+
+		srv.respondFallback(w, serr.Stage, serr.Err)
+		return Recovery{}, false
+	}
+
+	recovr = toRecovery(v)
+
+	if recovr.Responder != nil {
+		if partial {
+			log.Warn("Response was partially written before the error, ignoring Responder of error handler")
+			srv.report(serr)
+			return Recovery{}, false
+		}
+		if w.written {
+			log.Warn("Error handler wrote to response and returned a Responder, ignoring Responder")
+			return Recovery{}, false
+		}
+
+		rec := newRecorder()
+		if err := recovr.Responder.Respond(rec, serr.Req); err != nil {
+			log.Error("Failed to respond with Responder of error handler", slog.String("err", err.Error()))
+			srv.report(serr)
+			srv.respondFallback(w, serr.Stage, serr.Err)
+			return Recovery{}, false
+		}
+
+		if err := rec.flush(w); err != nil {
+			log.Error("Failed to write response of error handler", slog.String("err", err.Error()))
+		}
+		return Recovery{}, false
+	}
+
+	if applicable(recovr) {
+		log.Debug("Recovering from error with value of error handler")
+		return recovr, true
+	}
+
+	if !recovr.empty() {
+		log.Warn("Recovery value of error handler is not applicable to stage, ignoring it")
+	}
+
+	if !w.written {
+		log.Debug("Error handler did not respond to request, writing fallback response")
+		srv.report(serr)
+		srv.respondFallback(w, serr.Stage, serr.Err)
+	}
+
+	return Recovery{}, false
+}
+
+// Reports the error to the [ErrorReporter], if any.
+func (srv *server) report(serr ServeError) {
+	if srv.reporter == nil {
+		return
+	}
+	srv.reporter.Report(serr.Req.Context(), serr)
+}
+
+// Returns the error handler of the stage, defaulting to the error handler of the server.
+func (srv *server) errorHandler(stage Stage) plugin.ErrorHandler {
+	if h, ok := srv.stageHandlers[stage]; ok && h != nil {
+		return h
+	}
+	return srv.onerror
+}
+
+func (srv *server) respondFallback(w http.ResponseWriter, stage Stage, err error) {
+	w.WriteHeader(http.StatusInternalServerError)
+	_, werr := w.Write([]byte(fmt.Sprintf(
+		"Failed to handle error %q with plugin %q",
+		err.Error(),
+		srv.errorHandler(stage).Name(),
+	)))
+	srv.assert.Nil(werr)
+}
+
+func hasSourcer(r Recovery) bool {
+	return r.Sourcer != nil
+}
+
+func hasRenderer(r Recovery) bool {
+	return r.Renderer != nil
+}
+
+// Used on errors that can only be recovered with a [Responder].
+func noRecovery(r Recovery) bool {
+	return false
+}
+
+func (srv *server) serveHTTPSource(
+	name string,
+	start time.Time,
+	w http.ResponseWriter,
+	r *http.Request,
+) error {
 	srv.assert.NotNil(srv.sourcer, "A sourcer needs to be available")
-	srv.assert.NotNil(srv.onerror, "An error handler needs to be available in cases of errors")
+	srv.assert.NotNil(srv.errorHandler(StageSource), "An error handler needs to be available in cases of errors")
 	srv.assert.NotNil(srv.log)
 	srv.assert.NotNil(w)
 	srv.assert.NotNil(r)
@@ -149,59 +807,50 @@ func (srv *server) serveHTTPSource(w http.ResponseWriter, r *http.Request) error
 	if err != nil {
 		log := log.With(
 			slog.String("err", err.Error()),
-			slog.String("errorhandler", srv.onerror.Name()),
+			slog.String("errorhandler", srv.errorHandler(StageSource).Name()),
 		)
 
 		log.Error(
 			"Failed to get file system, handling error to ErrorHandler",
 		)
 
-		recovr, ok := srv.onerror.Handle(&ServeError{
-			Res: w,
-			Req: r,
-			Err: &SourceError{
-				Sourcer: srv.sourcer,
-				Err:     err,
-			},
-		})
-
+		recovr, ok := srv.handleError(srv.serveError(StageSource, name, start, w, r, SourceError{
+			Sourcer: srv.sourcer,
+			Err:     err,
+		}), hasSourcer, log)
 		if !ok {
-			log.Error("Failed to handle error with plugin")
-
-			w.WriteHeader(http.StatusInternalServerError)
-			_, err = w.Write([]byte(fmt.Sprintf(
-				"Failed to handle error %q with plugin %q",
-				err.Error(),
-				srv.onerror.Name(),
-			)))
-			srv.assert.Nil(err)
-
 			return err
 		}
 
-		r, ok := recovr.(plugin.Sourcer)
+		log = log.With(slog.String("recovery", recovr.Sourcer.Name()))
 
-		if !ok {
+		fs, err = recovr.Sourcer.Source()
+		if err != nil {
+			log.Error("Failed to get file system with recovery sourcer", slog.String("err", err.Error()))
+			srv.report(srv.serveError(StageSource, name, start, w, r, SourceError{Sourcer: recovr.Sourcer, Err: err}))
+			srv.respondFallback(w, StageSource, err)
 			return err
 		}
-
-		fs, err = r.Source()
-		srv.assert.Nil(err)
 	}
 
-	srv.files = fs
+	srv.setFiles(fs)
 
 	return nil
 }
 
 func (srv *server) serveHTTPOpenFile(
 	name string,
+	start time.Time,
 	w http.ResponseWriter,
 	r *http.Request,
 ) (fs.File, error) {
 	srv.assert.NotZero(name, "Name of file should not be empty")
-	srv.assert.NotNil(srv.files, "A file system needs to be present to open a file")
-	srv.assert.NotNil(srv.onerror, "An error handler needs to be available in cases of errors")
+	files, ok := FilesFromContext(r.Context())
+	if !ok {
+		files = srv.getFiles()
+	}
+	srv.assert.NotNil(files, "A file system needs to be present to open a file")
+	srv.assert.NotNil(srv.errorHandler(StageOpen), "An error handler needs to be available in cases of errors")
 	srv.assert.NotNil(srv.log)
 	srv.assert.NotNil(w)
 	srv.assert.NotNil(r)
@@ -213,7 +862,22 @@ func (srv *server) serveHTTPOpenFile(
 	)
 	log.Debug("Opening file")
 
-	f, err := srv.files.Open(name)
+	if err := srv.checkFileSize(name, files); err != nil {
+		log := log.With(
+			slog.String("err", err.Error()),
+			slog.String("errorhandler", srv.errorHandler(StageOpen).Name()),
+		)
+		log.Warn("File exceeds max size, handling error to ErrorHandler")
+
+		srv.handleError(srv.serveError(StageOpen, name, start, w, r, SourceError{
+			Sourcer: srv.sourcer,
+			Err:     err,
+		}), noRecovery, log)
+
+		return nil, err
+	}
+
+	f, err := files.Open(name)
 
 	if err != nil || f == nil {
 		if err == nil && f == nil {
@@ -225,53 +889,55 @@ func (srv *server) serveHTTPOpenFile(
 
 		log := log.With(
 			slog.String("err", err.Error()),
-			slog.String("errorhandler", srv.onerror.Name()),
+			slog.String("errorhandler", srv.errorHandler(StageOpen).Name()),
 		)
 
 		log.Warn(
 			"Failed to open file, handling error to ErrorHandler",
 		)
 
-		recovr, ok := srv.onerror.Handle(ServeError{
-			Res: w,
-			Req: r,
-			Err: SourceError{
-				Sourcer: srv.sourcer,
-				Err:     err,
-			},
-		})
-
+		recovr, ok := srv.handleError(srv.serveError(StageOpen, name, start, w, r, SourceError{
+			Sourcer: srv.sourcer,
+			Err:     err,
+		}), hasSourcer, log)
 		if !ok {
-			log.Error("Failed to handle error with plugin")
-			w.WriteHeader(http.StatusInternalServerError)
-			_, err = w.Write([]byte(fmt.Sprintf(
-				"Failed to handle error %q with plugin %q",
-				err.Error(),
-				srv.onerror.Name(),
-			)))
-			srv.assert.Nil(err)
-
 			return nil, err
 		}
 
-		r, ok := recovr.(fs.FS)
+		log = log.With(slog.String("recovery", recovr.Sourcer.Name()))
 
-		if !ok {
+		fsys, serr := recovr.Sourcer.Source()
+		if serr == nil {
+			if err = srv.checkFileSize(name, fsys); err == nil {
+				f, err = fsys.Open(name)
+			}
+		} else {
+			err = serr
+		}
+		if err != nil || f == nil {
+			if err == nil {
+				err = errors.New("recovery file system returned a nil file")
+			}
+			log.Error("Failed to open file with recovery sourcer", slog.String("err", err.Error()))
+			srv.report(srv.serveError(StageOpen, name, start, w, r, SourceError{Sourcer: recovr.Sourcer, Err: err}))
+			srv.respondFallback(w, StageOpen, err)
 			return nil, err
 		}
-
-		f, err = r.Open(name)
-		srv.assert.Nil(err)
-
 	}
 
-	return f, err
+	return f, nil
 }
 
-func (srv *server) serveHTTPRender(file fs.File, w http.ResponseWriter, r *http.Request) error {
+func (srv *server) serveHTTPRender(
+	name string,
+	start time.Time,
+	file fs.File,
+	w http.ResponseWriter,
+	r *http.Request,
+) (*renderResponse, error) {
 	srv.assert.NotNil(file, "A file needs to be present to it to be rendered")
 	srv.assert.NotNil(srv.renderer, "A renderer needs to be present to render a file")
-	srv.assert.NotNil(srv.onerror, "An error handler needs to be available in cases of errors")
+	srv.assert.NotNil(srv.errorHandler(StageRender), "An error handler needs to be available in cases of errors")
 	srv.assert.NotNil(srv.log)
 	srv.assert.NotNil(w)
 	srv.assert.NotNil(r)
@@ -282,51 +948,111 @@ func (srv *server) serveHTTPRender(file fs.File, w http.ResponseWriter, r *http.
 	)
 	log.Debug("Rendering file")
 
-	err := srv.renderer.Render(file, w)
+	res := newRenderResponse(file)
+	rw := &responseWriter{ResponseWriter: w, res: res}
+
+	err := srv.renderLimited(plugin.WithResponse(r.Context(), res), srv.renderer, file, rw)
+
+	// Error of the last render of the file, which is closed once it isn't used
+	// by the render anymore.
+	fileErr := err
+	defer func() { closeRendered(file, fileErr) }()
+
 	if err != nil {
 		log := log.With(
 			slog.String("err", err.Error()),
-			slog.String("errorhandler", srv.onerror.Name()),
+			slog.String("errorhandler", srv.errorHandler(StageRender).Name()),
 		)
 
 		log.Error(
 			"Failed to render file, handling error to ErrorHandler",
 		)
 
-		recovr, ok := srv.onerror.Handle(ServeError{
-			Res: w,
-			Req: r,
-			Err: RenderError{
-				Renderer: srv.renderer,
-				File:     file,
-				Err:      err,
-			},
-		})
+		// The renderer may have written part of the response before failing.
+		tw := &trackingWriter{ResponseWriter: w, written: rw.flushed}
 
+		recovr, ok := srv.handleError(srv.serveError(StageRender, name, start, tw, r, RenderError{
+			Renderer: srv.renderer,
+			File:     file,
+			Err:      err,
+		}), hasRenderer, log)
 		if !ok {
-			log.Error("Failed to handle error with plugin")
+			return nil, err
+		}
 
-			w.WriteHeader(http.StatusInternalServerError)
-			_, err = w.Write([]byte(fmt.Sprintf(
-				"Failed to handle error %q with plugin %q",
-				err.Error(),
-				srv.onerror.Name(),
-			)))
-			srv.assert.Nil(err)
+		log = log.With(slog.String("recovery", recovr.Renderer.Name()))
 
-			return err
+		rfile, reopened := file, false
+		if _, abandoned := abandonedRender(err); abandoned {
+			// The failed renderer still reads the file after it's deadline, so
+			// the recovery renderer needs it's own copy of it.
+			f, oerr := srv.reopenFile(name, r)
+			if oerr != nil {
+				log.Error("Failed to open file for recovery renderer", slog.String("err", oerr.Error()))
+				srv.report(srv.serveError(StageRender, name, start, w, r, RenderError{
+					Renderer: recovr.Renderer,
+					File:     file,
+					Err:      oerr,
+				}))
+				if !rw.flushed {
+					srv.respondFallback(w, StageRender, oerr)
+				}
+				return nil, oerr
+			}
+			rfile, reopened = f, true
+		} else if s, ok := file.(io.Seeker); ok {
+			// The failed renderer may have read part of the file.
+			if _, err := s.Seek(0, io.SeekStart); err != nil {
+				log.Warn("Failed to seek file to start for recovery renderer",
+					slog.String("err", err.Error()))
+			}
 		}
 
-		r, ok := recovr.(plugin.Renderer)
+		// The status and headers of the failed render are discarded, unless
+		// it already wrote to the response.
+		res = newRenderResponse(rfile)
+		rw = &responseWriter{ResponseWriter: w, res: res, flushed: rw.flushed}
 
-		if !ok {
-			return err
+		err := srv.renderLimited(plugin.WithResponse(r.Context(), res), recovr.Renderer, rfile, rw)
+		if reopened {
+			defer closeRendered(rfile, err)
+		} else {
+			fileErr = err
+		}
+		if err != nil {
+			log.Error("Failed to render file with recovery renderer", slog.String("err", err.Error()))
+			srv.report(srv.serveError(StageRender, name, start, w, r, RenderError{
+				Renderer: recovr.Renderer,
+				File:     rfile,
+				Err:      err,
+			}))
+			if !rw.flushed {
+				srv.respondFallback(w, StageRender, err)
+			}
+			return nil, err
 		}
+	}
 
-		err = r.Render(file, w)
-		srv.assert.Nil(err)
+	// Renders that didn't write anything, such as redirects, still respond with
+	// their status and headers.
+	rw.flush()
 
-	}
+	return res, nil
+}
 
-	return nil
+// Opens the file again from the file system of the request, for renders that
+// can't use the file that was opened for the request.
+func (srv *server) reopenFile(name string, r *http.Request) (fs.File, error) {
+	files, ok := FilesFromContext(r.Context())
+	if !ok {
+		files = srv.getFiles()
+	}
+	f, err := files.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		return nil, errors.New("file system returned a nil file")
+	}
+	return f, nil
 }
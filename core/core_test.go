@@ -0,0 +1,200 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/plugins"
+)
+
+type sourcer struct {
+	fsys fs.FS
+}
+
+func (s sourcer) Name() string {
+	return "test-sourcer"
+}
+
+func (s sourcer) Source() (fs.FS, error) {
+	return s.fsys, nil
+}
+
+type renderer struct {
+	name   string
+	render func(src fs.File, w io.Writer) error
+}
+
+func (r renderer) Name() string {
+	return r.name
+}
+
+func (r renderer) Render(src fs.File, w io.Writer) error {
+	return r.render(src, w)
+}
+
+type errorHandler func(err error) (any, bool)
+
+func (h errorHandler) Name() string {
+	return "test-errorhandler"
+}
+
+func (h errorHandler) Handle(err error) (any, bool) {
+	return h(err)
+}
+
+func newServer(t *testing.T, r renderer, h errorHandler, opts ...core.ServerOption) core.Server {
+	t.Helper()
+	fsys := fstest.MapFS{"hello.md": {Data: []byte("Hello, world")}}
+	return core.NewServer(sourcer{fsys}, r, h, opts...)
+}
+
+func get(t *testing.T, srv http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestRecoveryRendererError(t *testing.T) {
+	failing := renderer{name: "failing", render: func(src fs.File, w io.Writer) error {
+		return errors.New("failed render")
+	}}
+	srv := newServer(t, failing, func(err error) (any, bool) {
+		return core.RecoverWithRenderer(failing), true
+	})
+
+	w := get(t, srv, "/hello.md")
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+	if w.Body.Len() == 0 {
+		t.Fatalf("expected fallback response, got empty body")
+	}
+}
+
+func TestPartialRenderResponder(t *testing.T) {
+	partial := renderer{name: "partial", render: func(src fs.File, w io.Writer) error {
+		_, _ = w.Write([]byte("PARTIAL"))
+		return errors.New("failed render")
+	}}
+	srv := newServer(t, partial, func(err error) (any, bool) {
+		return core.RecoverWithResponse(core.ResponderFunc(func(w http.ResponseWriter, r *http.Request) error {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, err := w.Write([]byte("ERRPAGE"))
+			return err
+		})), true
+	})
+
+	w := get(t, srv, "/hello.md")
+
+	if body := w.Body.String(); body != "PARTIAL" {
+		t.Fatalf("expected Responder to be skipped after a partial render, got body %q", body)
+	}
+}
+
+func TestRenderTimeoutRecovery(t *testing.T) {
+	done := make(chan struct{})
+	slow := renderer{name: "slow", render: func(src fs.File, w io.Writer) error {
+		defer close(done)
+		time.Sleep(50 * time.Millisecond)
+		_, err := io.ReadAll(src)
+		return err
+	}}
+	recovery := renderer{name: "recovery", render: func(src fs.File, w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	}}
+	srv := newServer(t, slow, func(err error) (any, bool) {
+		if !errors.As(err, new(*core.RenderTimeoutError)) {
+			t.Errorf("expected render timeout error, got %v", err)
+		}
+		return core.RecoverWithRenderer(recovery), true
+	}, core.WithRenderTimeout(10*time.Millisecond))
+
+	w := get(t, srv, "/hello.md")
+	<-done
+
+	if body := w.Body.String(); body != "Hello, world" {
+		t.Fatalf("expected body of recovery renderer, got %q", body)
+	}
+}
+
+// File system that records the names of the files opened on it.
+type openRecorder struct {
+	fstest.MapFS
+	opened []string
+}
+
+func (fsys *openRecorder) Open(name string) (fs.File, error) {
+	fsys.opened = append(fsys.opened, name)
+	return fsys.MapFS.Open(name)
+}
+
+func TestMaxFileSizeBeforeOpen(t *testing.T) {
+	fsys := &openRecorder{MapFS: fstest.MapFS{"hello.md": {Data: []byte("Hello, world")}}}
+	r := renderer{name: "copy", render: func(src fs.File, w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	}}
+	srv := core.NewServer(sourcer{fsys}, r, errorHandler(func(err error) (any, bool) {
+		if !errors.As(err, new(*core.FileSizeError)) {
+			t.Errorf("expected file size error, got %v", err)
+		}
+		return nil, false
+	}), core.WithSizeLimits(5, 0))
+
+	w := get(t, srv, "/hello.md")
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+	if len(fsys.opened) > 0 {
+		t.Fatalf("expected file larger than the max size to not be opened, opened %v", fsys.opened)
+	}
+}
+
+func TestStaticContentType(t *testing.T) {
+	for name, opts := range map[string][]core.ServerOption{
+		"cache":        {core.WithCache(time.Minute)},
+		"output limit": {core.WithCache(time.Minute), core.WithSizeLimits(0, 1<<20)},
+		"timeout":      {core.WithRenderTimeout(time.Second)},
+	} {
+		r := plugins.NewBufferedMultiRenderer()
+		r.Use(plugins.NewStaticRenderer())
+
+		fsys := fstest.MapFS{"style.css": {Data: []byte("body{}")}}
+		srv := core.NewServer(sourcer{fsys}, r, errorHandler(func(err error) (any, bool) {
+			return nil, false
+		}), opts...)
+
+		for i := range 2 {
+			w := get(t, srv, "/style.css")
+			if ct := w.Header().Get("Content-Type"); ct != "text/css; charset=utf-8" {
+				t.Fatalf("%s: expected CSS content type on request %d, got %q", name, i+1, ct)
+			}
+		}
+	}
+}
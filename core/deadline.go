@@ -0,0 +1,160 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+)
+
+// Error of a render that didn't finish before the deadline of ServerOpts.RenderTimeout,
+// passed to the error handler wrapped in a [RenderError].
+type RenderTimeoutError struct {
+	Renderer plugin.Renderer
+	Timeout  time.Duration
+	// Error of the context of the render, usually [context.DeadlineExceeded].
+	Err error
+
+	// Closed when the goroutine of a abandoned render returns, nil if the
+	// renderer returned before the error, see [abandonedRender].
+	done <-chan struct{}
+}
+
+func (e *RenderTimeoutError) Error() string {
+	return fmt.Sprintf("render with renderer %q exceeded timeout of %s", e.Renderer.Name(), e.Timeout)
+}
+
+func (e *RenderTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+var errRenderAborted = errors.New("render aborted, writes after the deadline are discarded")
+
+// Renders the file, aborting the render after ServerOpts.RenderTimeout.
+//
+// [plugin.ContextRenderer]s and [plugin.ResponseRenderer]s receive the context
+// with the deadline. Other renderers
+// are executed on their own goroutine, and their writes after the deadline are
+// discarded, so the request is responded even if the renderer never returns.
+//
+// Abandoned renders may still read the file, so callers shouldn't use it again,
+// and should close it with [closeRendered].
+func (srv *server) render(
+	ctx context.Context,
+	renderer plugin.Renderer,
+	file fs.File,
+	w io.Writer,
+) error {
+	if srv.renderTimeout <= 0 {
+		return plugin.RenderContext(ctx, renderer, file, w)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, srv.renderTimeout)
+	defer cancel()
+
+	timeoutErr := func() *RenderTimeoutError {
+		return &RenderTimeoutError{Renderer: renderer, Timeout: srv.renderTimeout, Err: ctx.Err()}
+	}
+
+	_, responds := renderer.(plugin.ResponseRenderer)
+	if _, ok := renderer.(plugin.ContextRenderer); ok || responds {
+		err := plugin.RenderContext(ctx, renderer, file, w)
+		if err != nil && ctx.Err() != nil {
+			return timeoutErr()
+		}
+		return err
+	}
+
+	dw := &deadlineWriter{w: w}
+	done := make(chan error, 1)
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		defer func() {
+			if v := recover(); v != nil {
+				done <- &PanicError{Value: v, Stack: debug.Stack()}
+			}
+		}()
+		done <- renderer.Render(file, dw)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		dw.abort()
+		err := timeoutErr()
+		err.done = finished
+		return err
+	}
+}
+
+// Returns the channel closed when the render of the error returns, if it was
+// abandoned after it's deadline and may still be using it's file.
+func abandonedRender(err error) (<-chan struct{}, bool) {
+	var terr *RenderTimeoutError
+	if errors.As(err, &terr) && terr.done != nil {
+		return terr.done, true
+	}
+	return nil, false
+}
+
+// Closes the file once the render that returned the error doesn't use it
+// anymore, after the goroutine of abandoned renders returns.
+func closeRendered(file fs.File, err error) {
+	done, ok := abandonedRender(err)
+	if !ok {
+		_ = file.Close()
+		return
+	}
+	go func() {
+		<-done
+		_ = file.Close()
+	}()
+}
+
+// [io.Writer] that discards writes after it's aborted, used so renders running
+// after their deadline don't write to the response.
+type deadlineWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	aborted bool
+}
+
+func (w *deadlineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.aborted {
+		return 0, errRenderAborted
+	}
+	return w.w.Write(p)
+}
+
+func (w *deadlineWriter) abort() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.aborted = true
+}
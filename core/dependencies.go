@@ -0,0 +1,80 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Dependencies of a render, such as templates, data files and includes, used to
+// invalidate only the cached files that depend on something that changed, see
+// [(Server).Invalidate].
+//
+// Files are identified by their path on the file system, and other dependencies
+// by names created with functions such as [TemplateDependency].
+type Dependencies struct {
+	mu    sync.Mutex
+	names map[string]struct{}
+}
+
+type dependenciesKey struct{}
+
+// Returns a context that records the dependencies added with [AddDependency].
+func WithDependencies(ctx context.Context) (context.Context, *Dependencies) {
+	d := &Dependencies{names: map[string]struct{}{}}
+	return context.WithValue(ctx, dependenciesKey{}, d), d
+}
+
+// Adds the names as dependencies of the render of the context. Renderers that
+// implement [plugin.ContextRenderer] should call it for everything they use
+// besides the rendered file itself. Does nothing if the context doesn't record
+// dependencies.
+func AddDependency(ctx context.Context, names ...string) {
+	d, ok := ctx.Value(dependenciesKey{}).(*Dependencies)
+	if !ok || d == nil {
+		return
+	}
+	d.Add(names...)
+}
+
+// Name of the dependency on the template or layout with the name.
+func TemplateDependency(name string) string {
+	return "template:" + name
+}
+
+// Adds the names as dependencies.
+func (d *Dependencies) Add(names ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, n := range names {
+		d.names[n] = struct{}{}
+	}
+}
+
+// Returns the names of the dependencies, sorted.
+func (d *Dependencies) Names() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	names := make([]string, 0, len(d.names))
+	for n := range d.names {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -18,15 +18,47 @@ package core
 import (
 	"fmt"
 	"io/fs"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"forge.capytal.company/loreddev/blogo/plugin"
 )
 
+// Stage of the pipeline of the server where a error happened.
+type Stage string
+
+const (
+	// Sourcing of the file system, with [plugin.Sourcer].
+	StageSource Stage = "source"
+	// Opening of the requested file on the file system.
+	StageOpen Stage = "open"
+	// Rendering of the file, with [plugin.Renderer].
+	StageRender Stage = "render"
+	// Admission of the request, which is rejected with a [RejectError] by the
+	// load shedding of ServerOpts.Overload or by middlewares with rate limits.
+	StageAdmission Stage = "admission"
+)
+
+// Error passed to [plugin.ErrorHandler] when the server fails to serve a request,
+// wrapping a [SourceError], [RenderError] or [RejectError].
 type ServeError struct {
+	// Response of the request. Error handlers should return a [Responder] instead
+	// of writing to it directly, see [Responder] for more information.
 	Res http.ResponseWriter
 	Req *http.Request
 	Err error
+
+	// Path of the file on the file system that the request resolved to, "." if
+	// it's the root directory.
+	Path string
+	// Stage of the pipeline that failed.
+	Stage Stage
+	// Time elapsed since the server started serving the request.
+	Elapsed time.Duration
+	// Logger of the request, with the request path, file path and stage as
+	// attributes, so handlers can log with the same context as the server.
+	Logger *slog.Logger
 }
 
 func (e ServeError) Error() string {
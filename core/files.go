@@ -0,0 +1,66 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"context"
+	"io/fs"
+)
+
+type filesKey struct{}
+
+// Returns a context that makes the server open the file of the request from the
+// file system, instead of the sourced one, so middlewares can render other
+// versions of the content (such as previous revisions) through the same renderers.
+//
+// Requests with a overridden file system are never cached, unless the context
+// also has a variant set by [WithCacheVariant].
+func WithFiles(ctx context.Context, fsys fs.FS) context.Context {
+	return context.WithValue(ctx, filesKey{}, fsys)
+}
+
+// Returns the file system set on the context by [WithFiles].
+func FilesFromContext(ctx context.Context) (fs.FS, bool) {
+	fsys, ok := ctx.Value(filesKey{}).(fs.FS)
+	return fsys, ok && fsys != nil
+}
+
+type cacheVariantKey struct{}
+
+// Returns a context that makes the server cache the render of the request
+// separately from the renders of other variants of the same path, such as
+// alternate versions of pages served by a overridden file system (see
+// [WithFiles]) that are still deterministic. Invalidating the path also
+// invalidates all it's variants.
+func WithCacheVariant(ctx context.Context, variant string) context.Context {
+	return context.WithValue(ctx, cacheVariantKey{}, variant)
+}
+
+// Returns the variant set on the context by [WithCacheVariant].
+func CacheVariantFromContext(ctx context.Context) (string, bool) {
+	v, ok := ctx.Value(cacheVariantKey{}).(string)
+	return v, ok && v != ""
+}
+
+type pathKey struct{}
+
+// Returns the path of the file being served on the request of the context,
+// which the server sets before rendering, so renderers can behave differently
+// by path without depending on the metadata of files.
+func PathFromContext(ctx context.Context) (string, bool) {
+	p, ok := ctx.Value(pathKey{}).(string)
+	return p, ok
+}
@@ -0,0 +1,141 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Gate of the site before it's launch, such as while staging a new blog on it's
+// final domain, see ServerOpts.Launch. Requests that don't pass the gate are
+// responded with "503 Service Unavailable" and are asked to not be indexed.
+type LaunchGate struct {
+	// Time when the site is launched, opening the gate for everyone. If zero,
+	// the gate is kept closed until it's removed from the options.
+	At time.Time
+
+	// Token that lets requests through, sent on the header, on the cookie, or
+	// as the query value of the same name as the cookie, which also sets the
+	// cookie, so the site can be previewed on browsers by opening a link such
+	// as "https://example.com/?blogo-launch=TOKEN". If empty, only AllowIPs
+	// and AllowUserAgents pass.
+	Token string
+	// Header of the token. Defaults to "X-Blogo-Launch-Token".
+	Header string
+	// Cookie and query value of the token. Defaults to "blogo-launch".
+	Cookie string
+
+	// IP addresses or CIDR ranges, such as "10.0.0.0/8", whose requests pass.
+	AllowIPs []string
+	// Case-insensitive substrings of the User-Agents whose requests pass, so
+	// feed validators can check the feeds before the launch. Defaults to
+	// [DefaultLaunchUserAgents]. Set to a empty slice to not allow any.
+	AllowUserAgents []string
+}
+
+// User agents of feed validators, allowed through the [LaunchGate] if no others
+// are provided.
+var DefaultLaunchUserAgents = []string{"FeedValidator", "W3C_Validator", "validator.w3.org"}
+
+// Returns a [http.Handler] that only calls next for requests that pass the gate,
+// used by the engine so the endpoints of middlewares are also gated.
+func (g *LaunchGate) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !g.allow(w, r) {
+			g.respond(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Reports if the request passes the gate, setting the cookie of the token if
+// it was provided by the query.
+func (g *LaunchGate) allow(w http.ResponseWriter, r *http.Request) bool {
+	if !g.At.IsZero() && !time.Now().Before(g.At) {
+		return true
+	}
+
+	header, cookie := g.Header, g.Cookie
+	if header == "" {
+		header = "X-Blogo-Launch-Token"
+	}
+	if cookie == "" {
+		cookie = "blogo-launch"
+	}
+
+	if g.Token != "" {
+		if g.validToken(r.Header.Get(header)) {
+			return true
+		}
+		if c, err := r.Cookie(cookie); err == nil && g.validToken(c.Value) {
+			return true
+		}
+		if g.validToken(r.URL.Query().Get(cookie)) {
+			http.SetCookie(w, &http.Cookie{
+				Name:     cookie,
+				Value:    g.Token,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteLaxMode,
+			})
+			return true
+		}
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if addr := net.ParseIP(ip); addr != nil {
+		for _, a := range g.AllowIPs {
+			if _, n, err := net.ParseCIDR(a); err == nil && n.Contains(addr) {
+				return true
+			} else if other := net.ParseIP(a); other != nil && other.Equal(addr) {
+				return true
+			}
+		}
+	}
+
+	uas := g.AllowUserAgents
+	if uas == nil {
+		uas = DefaultLaunchUserAgents
+	}
+	ua := strings.ToLower(r.UserAgent())
+	return ua != "" && slices.ContainsFunc(uas, func(s string) bool {
+		return strings.Contains(ua, strings.ToLower(s))
+	})
+}
+
+func (g *LaunchGate) validToken(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(g.Token)) == 1
+}
+
+func (g *LaunchGate) respond(w http.ResponseWriter) {
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Header().Set("Cache-Control", "no-store")
+	if !g.At.IsZero() {
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(g.At).Seconds())+1))
+	}
+	http.Error(w, "Not launched yet", http.StatusServiceUnavailable)
+}
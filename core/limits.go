@@ -0,0 +1,115 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+)
+
+// Error of a file larger than ServerOpts.MaxFileSize, passed to the error handler
+// wrapped in a [SourceError] on [StageOpen].
+type FileSizeError struct {
+	Name string
+	Size int64
+	Max  int64
+}
+
+func (e *FileSizeError) Error() string {
+	return fmt.Sprintf("file %q has %d bytes, more than the max of %d bytes", e.Name, e.Size, e.Max)
+}
+
+// Error of a render that outputs more than ServerOpts.MaxOutputSize, passed to
+// the error handler wrapped in a [RenderError].
+type OutputSizeError struct {
+	Renderer plugin.Renderer
+	Max      int64
+}
+
+func (e *OutputSizeError) Error() string {
+	return fmt.Sprintf("renderer %q output more than the max of %d bytes", e.Renderer.Name(), e.Max)
+}
+
+// Checks the size of the file against ServerOpts.MaxFileSize, before it is
+// opened, since file systems such as the frontmatter plugin read the contents
+// of files on Open. Directories are always allowed, since their size isn't the
+// size of their contents, and files that fail to be stat'ed are left for Open
+// to report.
+func (srv *server) checkFileSize(name string, files fs.FS) error {
+	if srv.maxFileSize <= 0 {
+		return nil
+	}
+
+	stat, err := fs.Stat(files, name)
+	if err != nil || stat.IsDir() {
+		return nil
+	}
+
+	if stat.Size() > srv.maxFileSize {
+		return &FileSizeError{Name: name, Size: stat.Size(), Max: srv.maxFileSize}
+	}
+	return nil
+}
+
+// Renders the file, limiting the output to ServerOpts.MaxOutputSize. When
+// limited, the output is buffered, so nothing is written to the response if the
+// limit is exceeded and the error handler can respond to the request.
+func (srv *server) renderLimited(
+	ctx context.Context,
+	renderer plugin.Renderer,
+	file fs.File,
+	w io.Writer,
+) error {
+	if srv.maxOutputSize <= 0 {
+		return srv.render(ctx, renderer, file, w)
+	}
+
+	buf := &limitBuffer{max: srv.maxOutputSize}
+
+	err := srv.render(ctx, renderer, file, buf)
+	if _, ok := abandonedRender(err); ok {
+		return err
+	}
+	if buf.exceeded {
+		return &OutputSizeError{Renderer: renderer, Max: srv.maxOutputSize}
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// Buffer that errors on writes after it has max bytes.
+type limitBuffer struct {
+	bytes.Buffer
+	max      int64
+	exceeded bool
+}
+
+func (b *limitBuffer) Write(p []byte) (int, error) {
+	if int64(b.Len()+len(p)) > b.max {
+		b.exceeded = true
+		return 0, fmt.Errorf("output exceeds max of %d bytes", b.max)
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,130 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+)
+
+const serverName = "blogo-core-server"
+
+// Registry of the counters of [plugin.Instrumented] plugins and of the server
+// itself, see ServerOpts.Metrics. The registry is a [http.Handler] that serves
+// the counters as JSON, meant to be used as a debug endpoint, so it should be
+// served behind authentication, such as Require of the auth package.
+type Metrics struct {
+	mu      sync.RWMutex
+	plugins []plugin.Instrumented
+}
+
+// Creates a empty [Metrics] registry.
+func NewMetrics() *Metrics {
+	return &Metrics{plugins: []plugin.Instrumented{}}
+}
+
+// Adds the plugins to the registry, ignoring the ones already registered.
+func (m *Metrics) Register(ps ...plugin.Instrumented) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, p := range ps {
+		if p != nil && !m.registered(p) {
+			m.plugins = append(m.plugins, p)
+		}
+	}
+}
+
+// Must be called with the mutex locked.
+func (m *Metrics) registered(p plugin.Instrumented) bool {
+	// Plugins that can't be compared, such as functions, are never considered
+	// duplicates, so comparing them doesn't panic.
+	if !reflect.TypeOf(p).Comparable() {
+		return false
+	}
+	for _, r := range m.plugins {
+		if reflect.TypeOf(r) == reflect.TypeOf(p) && r == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the current counters of the registered plugins, by the name of each
+// plugin. Plugins with the same name are suffixed by their position, such as
+// "blogo-gitea-sourcer#2".
+func (m *Metrics) Collect() map[string]map[string]int64 {
+	m.mu.RLock()
+	ps := append([]plugin.Instrumented{}, m.plugins...)
+	m.mu.RUnlock()
+
+	metrics := make(map[string]map[string]int64, len(ps))
+	seen := map[string]int{}
+	for _, p := range ps {
+		name := p.Name()
+		seen[name]++
+		if seen[name] > 1 {
+			name = fmt.Sprintf("%s#%d", name, seen[name])
+		}
+		metrics[name] = p.Metrics()
+	}
+	return metrics
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(m.Collect())
+}
+
+// Counters of the server, registered as a [plugin.Instrumented] on ServerOpts.Metrics.
+type serverMetrics struct {
+	requests    atomic.Int64
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
+	renders     atomic.Int64
+	failures    atomic.Int64
+	staleHits   atomic.Int64
+	shed        atomic.Int64
+}
+
+func (m *serverMetrics) Name() string {
+	return serverName
+}
+
+func (m *serverMetrics) Metrics() map[string]int64 {
+	return map[string]int64{
+		"requests":     m.requests.Load(),
+		"cache_hits":   m.cacheHits.Load(),
+		"cache_misses": m.cacheMisses.Load(),
+		"renders":      m.renders.Load(),
+		"failures":     m.failures.Load(),
+		"stale_hits":   m.staleHits.Load(),
+		"shed":         m.shed.Load(),
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"io/fs"
+	"log/slog"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+// Option of [NewServer]. Both [ServerOpts] and the functional options, such as
+// [WithLogger] and [WithSourceOnInit], implement it, so options can be combined:
+//
+//	core.NewServer(s, r, h, core.WithLogger(logger), core.WithCache(time.Minute))
+//
+// A [ServerOpts] value replaces all options applied before it, so it should be
+// the first option if used alongside functional options.
+type ServerOption interface {
+	apply(*ServerOpts)
+}
+
+func (o ServerOpts) apply(opts *ServerOpts) {
+	*opts = o
+}
+
+// Returns the [ServerOpts] resulting of applying the options, in order.
+func ResolveOptions(opts ...ServerOption) ServerOpts {
+	opt := ServerOpts{}
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&opt)
+		}
+	}
+	return opt
+}
+
+// Type adapter to allow the use of ordinary functions as [ServerOption] implementations.
+type ServerOptionFunc func(*ServerOpts)
+
+func (f ServerOptionFunc) apply(opts *ServerOpts) {
+	f(opts)
+}
+
+// Sets ServerOpts.Logger.
+func WithLogger(logger *slog.Logger) ServerOption {
+	return ServerOptionFunc(func(opts *ServerOpts) {
+		opts.Logger = logger
+	})
+}
+
+// Sets ServerOpts.LogHandler, so any [slog.Handler] can be used as the sink of
+// the logs of the server.
+func WithLogHandler(h slog.Handler) ServerOption {
+	return ServerOptionFunc(func(opts *ServerOpts) {
+		opts.LogHandler = h
+	})
+}
+
+// Sets ServerOpts.Assertions.
+func WithAssertions(assertions tinyssert.Assertions) ServerOption {
+	return ServerOptionFunc(func(opts *ServerOpts) {
+		opts.Assertions = assertions
+	})
+}
+
+// Sets ServerOpts.SourceOnInit.
+func WithSourceOnInit() ServerOption {
+	return ServerOptionFunc(func(opts *ServerOpts) {
+		opts.SourceOnInit = true
+	})
+}
+
+// Sets ServerOpts.StartBackoff and ServerOpts.StartMaxBackoff.
+func WithStartBackoff(initial, maxBackoff time.Duration) ServerOption {
+	return ServerOptionFunc(func(opts *ServerOpts) {
+		opts.StartBackoff = initial
+		opts.StartMaxBackoff = maxBackoff
+	})
+}
+
+// Sets the error handler of the stage, see ServerOpts.SourceErrorHandler.
+func WithStageErrorHandler(stage Stage, h plugin.ErrorHandler) ServerOption {
+	return ServerOptionFunc(func(opts *ServerOpts) {
+		switch stage {
+		case StageSource:
+			opts.SourceErrorHandler = h
+		case StageOpen:
+			opts.OpenErrorHandler = h
+		case StageRender:
+			opts.RenderErrorHandler = h
+		case StageAdmission:
+			opts.AdmissionErrorHandler = h
+		}
+	})
+}
+
+// Sets ServerOpts.CacheTTL and, optionally, ServerOpts.CacheMaxEntries.
+func WithCache(ttl time.Duration, maxEntries ...int) ServerOption {
+	return ServerOptionFunc(func(opts *ServerOpts) {
+		opts.CacheTTL = ttl
+		if len(maxEntries) > 0 {
+			opts.CacheMaxEntries = maxEntries[0]
+		}
+	})
+}
+
+// Sets ServerOpts.CacheFile and, optionally, ServerOpts.CacheFingerprint. The
+// cache also needs to be enabled with [WithCache].
+func WithCacheFile(name string, fingerprint ...func(fs.FS) (string, error)) ServerOption {
+	return ServerOptionFunc(func(opts *ServerOpts) {
+		opts.CacheFile = name
+		if len(fingerprint) > 0 {
+			opts.CacheFingerprint = fingerprint[0]
+		}
+	})
+}
+
+// Sets ServerOpts.CacheBackend. The cache also needs to be enabled with [WithCache].
+func WithCacheBackend(b CacheBackend) ServerOption {
+	return ServerOptionFunc(func(opts *ServerOpts) {
+		opts.CacheBackend = b
+	})
+}
+
+// Sets ServerOpts.CacheKey.
+func WithCacheKey(key []byte) ServerOption {
+	return ServerOptionFunc(func(opts *ServerOpts) {
+		opts.CacheKey = key
+	})
+}
+
+// Sets ServerOpts.ErrorReporter.
+func WithErrorReporter(r ErrorReporter) ServerOption {
+	return ServerOptionFunc(func(opts *ServerOpts) {
+		opts.ErrorReporter = r
+	})
+}
+
+// Sets ServerOpts.RenderTimeout.
+func WithRenderTimeout(d time.Duration) ServerOption {
+	return ServerOptionFunc(func(opts *ServerOpts) {
+		opts.RenderTimeout = d
+	})
+}
+
+// Sets ServerOpts.MaxFileSize and ServerOpts.MaxOutputSize. Zero values disable the limits.
+func WithSizeLimits(maxFileSize, maxOutputSize int64) ServerOption {
+	return ServerOptionFunc(func(opts *ServerOpts) {
+		opts.MaxFileSize = maxFileSize
+		opts.MaxOutputSize = maxOutputSize
+	})
+}
+
+// Sets ServerOpts.Metrics.
+func WithMetrics(m *Metrics) ServerOption {
+	return ServerOptionFunc(func(opts *ServerOpts) {
+		opts.Metrics = m
+	})
+}
+
+// Sets ServerOpts.CacheImport.
+func WithCacheImport(path string) ServerOption {
+	return ServerOptionFunc(func(opts *ServerOpts) {
+		opts.CacheImport = path
+	})
+}
+
+// Sets ServerOpts.Overload.
+func WithOverload(o *Overload) ServerOption {
+	return ServerOptionFunc(func(opts *ServerOpts) {
+		opts.Overload = o
+	})
+}
+
+// Sets ServerOpts.Launch.
+func WithLaunchGate(g LaunchGate) ServerOption {
+	return ServerOptionFunc(func(opts *ServerOpts) {
+		opts.Launch = &g
+	})
+}
@@ -0,0 +1,148 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Profile of the degradation of the server when overloaded, see
+// ServerOpts.Overload. Renders are counted while the file is sourced, opened
+// and rendered, responses from the cache aren't.
+//
+// When the concurrent renders reach Threshold, the server is degraded: expired
+// renders still in the cache are served instead of rendered again, and renders
+// are done with a context where [Degraded] reports true, so expensive steps
+// such as image processing and related posts can be skipped. Renders done while
+// degraded aren't cached. Renders past MaxRenders wait on a queue, and the
+// remainder is responded with "503 Service Unavailable" and Retry-After.
+//
+// The same profile can be shared by multiple servers and middlewares, such as
+// the images plugin, which check [(*Overload).Overloaded].
+type Overload struct {
+	// Number of concurrent renders at which the server is degraded.
+	Threshold int
+	// Max number of concurrent renders. Defaults to twice the Threshold.
+	MaxRenders int
+	// Max number of renders waiting for one of MaxRenders to finish. Defaults
+	// to MaxRenders, negative values respond the renders past MaxRenders
+	// immediately.
+	MaxQueue int
+	// Max duration that renders wait on the queue. Defaults to 5 seconds.
+	QueueTimeout time.Duration
+	// Duration after their expiration that renders are kept on the cache to be
+	// served while degraded. Defaults to 1 hour.
+	Stale time.Duration
+	// Duration sent as the Retry-After header. Defaults to 5 seconds.
+	RetryAfter time.Duration
+
+	once    sync.Once
+	slots   chan struct{}
+	renders atomic.Int64
+	queued  atomic.Int64
+}
+
+func (o *Overload) init() {
+	o.once.Do(func() {
+		if o.Threshold <= 0 {
+			o.Threshold = o.MaxRenders
+		}
+		if o.MaxRenders <= 0 {
+			o.MaxRenders = 2 * o.Threshold
+		}
+		if o.MaxRenders <= 0 {
+			o.Threshold, o.MaxRenders = 64, 128
+		}
+		if o.MaxQueue == 0 {
+			o.MaxQueue = o.MaxRenders
+		}
+		if o.QueueTimeout == 0 {
+			o.QueueTimeout = 5 * time.Second
+		}
+		if o.Stale == 0 {
+			o.Stale = time.Hour
+		}
+		if o.RetryAfter == 0 {
+			o.RetryAfter = 5 * time.Second
+		}
+		o.slots = make(chan struct{}, o.MaxRenders)
+	})
+}
+
+// Reports if the concurrent renders reached the Threshold.
+func (o *Overload) Overloaded() bool {
+	o.init()
+	return o.renders.Load() >= int64(o.Threshold)
+}
+
+// Returns the number of concurrent renders.
+func (o *Overload) Renders() int {
+	return int(o.renders.Load())
+}
+
+// Waits for one of the slots of renders, returning the function that releases
+// it, or false if the queue is full, the wait timed out or the context is done.
+func (o *Overload) acquire(ctx context.Context) (func(), bool) {
+	o.init()
+
+	release := func() {
+		o.renders.Add(-1)
+		<-o.slots
+	}
+
+	select {
+	case o.slots <- struct{}{}:
+		o.renders.Add(1)
+		return release, true
+	default:
+	}
+
+	if o.queued.Add(1) > int64(o.MaxQueue) {
+		o.queued.Add(-1)
+		return nil, false
+	}
+	defer o.queued.Add(-1)
+
+	t := time.NewTimer(o.QueueTimeout)
+	defer t.Stop()
+
+	select {
+	case o.slots <- struct{}{}:
+		o.renders.Add(1)
+		return release, true
+	case <-t.C:
+		return nil, false
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+type degradedKey struct{}
+
+// Reports if the render of the context is done while the server is overloaded,
+// see [Overload], in which case renderers should skip expensive steps.
+func Degraded(ctx context.Context) bool {
+	d, _ := ctx.Value(degradedKey{}).(bool)
+	return d
+}
+
+// Returns a context where [Degraded] reports true.
+func WithDegraded(ctx context.Context) context.Context {
+	return context.WithValue(ctx, degradedKey{}, true)
+}
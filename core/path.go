@@ -0,0 +1,79 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Returned by [FilePath] for URL paths that can't be the path of a file, such as
+// paths with invalid UTF-8, NUL bytes or backslashes, which some file systems
+// treat as separators.
+var ErrInvalidPath = errors.New("path is invalid")
+
+// Returned by [FilePath] for URL paths that have "." or ".." elements or repeated
+// slashes, which requests should be redirected from, so middlewares that match
+// paths (such as the bots and maintenance plugins) always see the clean path of
+// the file that is served.
+type NonCanonicalPathError struct {
+	// The clean URL path, with the leading and trailing slashes of the original.
+	Path string
+}
+
+func (e NonCanonicalPathError) Error() string {
+	return fmt.Sprintf("path is not canonical, clean path is %q", e.Path)
+}
+
+// Returns the path of the file on the file system of the sourcers that the URL
+// path resolves to, without the leading and trailing slashes, or "." if it's the
+// root directory. The returned path is always valid for [fs.FS.Open].
+//
+// URL paths come from clients and shouldn't be trusted, so the path is
+// cleaned before being used and, if the clean path differs from the original,
+// returns a [NonCanonicalPathError]. Returns [ErrInvalidPath] if the path can't
+// be the path of a file.
+func FilePath(urlPath string) (string, error) {
+	if strings.ContainsAny(urlPath, "\x00\\") {
+		return "", ErrInvalidPath
+	}
+
+	name := strings.Trim(urlPath, "/")
+
+	clean := strings.TrimPrefix(path.Clean("/"+name), "/")
+	if clean != "" && !fs.ValidPath(clean) {
+		return "", ErrInvalidPath
+	}
+
+	// Runs of leading or trailing slashes are trimmed above, so they are checked
+	// on the original path.
+	if clean != name || strings.HasPrefix(urlPath, "//") || strings.HasSuffix(urlPath, "//") {
+		p := "/" + clean
+		if clean != "" && strings.HasSuffix(urlPath, "/") {
+			p += "/"
+		}
+		return "", NonCanonicalPathError{Path: p}
+	}
+
+	if clean == "" {
+		clean = "."
+	}
+
+	return clean, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core_test
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"forge.capytal.company/loreddev/blogo/core"
+)
+
+func FuzzFilePath(f *testing.F) {
+	for _, s := range []string{
+		"/", "", "/posts/hello", "/posts/hello/", "//posts//hello", "/posts/../secret",
+		"/../../etc/passwd", "/./", "/a/./b/", "/posts\\..\\secret", "/a\x00b", "/…/ü",
+	} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, urlPath string) {
+		name, err := core.FilePath(urlPath)
+
+		var nc core.NonCanonicalPathError
+		switch {
+		case errors.As(err, &nc):
+			if !strings.HasPrefix(nc.Path, "/") || strings.HasPrefix(nc.Path, "//") {
+				t.Fatalf("Clean path %q of %q should have exactly one leading slash", nc.Path, urlPath)
+			}
+			if _, err := core.FilePath(nc.Path); err != nil {
+				t.Fatalf("Clean path %q of %q should be canonical, got error: %s", nc.Path, urlPath, err.Error())
+			}
+
+		case errors.Is(err, core.ErrInvalidPath):
+
+		case err != nil:
+			t.Fatalf("Unexpected error for %q: %s %v", urlPath, err.Error(), err)
+
+		default:
+			if !fs.ValidPath(name) {
+				t.Fatalf("Path %q of %q is not valid for fs.FS", name, urlPath)
+			}
+			if strings.ContainsAny(name, "\x00\\") {
+				t.Fatalf("Path %q of %q has NUL bytes or backslashes", name, urlPath)
+			}
+			if strings.HasPrefix(urlPath, "//") || strings.HasSuffix(urlPath, "//") {
+				t.Fatalf("Path %q with repeated slashes should be redirected", urlPath)
+			}
+			if name == "." && strings.Trim(urlPath, "/") != "" {
+				t.Fatalf("Only the root should resolve to the root directory, got %q", urlPath)
+			}
+			u := "/" + name
+			if name == "." {
+				u = "/"
+			}
+			if again, err := core.FilePath(u); err != nil || again != name {
+				t.Fatalf("Path %q of %q should resolve to itself, got %q and error %v", name, urlPath, again, err)
+			}
+		}
+	})
+}
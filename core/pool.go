@@ -0,0 +1,264 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+// A pool of the instances of a [plugin.ExpensiveRenderer], which is used as the
+// renderer itself.
+type RendererPool interface {
+	plugin.ResponseRenderer
+	plugin.ContextRenderer
+	plugin.Instrumented
+	// Creates instances until the pool has RendererPoolOpts.Warm idle instances,
+	// so the first renders don't wait for their initialization.
+	Warm(ctx context.Context) error
+	// Closes the idle instances that implement [io.Closer]. Later renders create
+	// new instances.
+	Close() error
+}
+
+// Creates a [RendererPool] of the instances of the renderer. Each render takes
+// a idle instance, or creates a new one if there are less than RendererPoolOpts.Size
+// instances, otherwise waits for a instance to be released or for the context to
+// be done. Instances that panic are discarded, since their state is unknown.
+//
+// The name of the pool is the name of the renderer, and it's counters are
+// "created", "failed" (failed initializations), "discarded" and "waits".
+func NewRendererPool(r plugin.ExpensiveRenderer, opts ...RendererPoolOpts) RendererPool {
+	opt := RendererPoolOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Size <= 0 {
+		opt.Size = runtime.GOMAXPROCS(0)
+	}
+	if opt.Warm == 0 {
+		opt.Warm = 1
+	}
+	opt.Warm = min(max(opt.Warm, 0), opt.Size)
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(r, "Expensive renderer should not be nil")
+
+	return &rendererPool{
+		renderer: r,
+		warm:     opt.Warm,
+
+		idle:  make(chan plugin.Renderer, opt.Size),
+		slots: make(chan struct{}, opt.Size),
+
+		assert: opt.Assertions,
+		log:    opt.Logger.With(slog.String("renderer", r.Name())),
+	}
+}
+
+type RendererPoolOpts struct {
+	// Max number of instances. Defaults to [runtime.GOMAXPROCS].
+	Size int
+	// Number of instances created by [(RendererPool).Warm]. Defaults to 1,
+	// negative values don't create any.
+	Warm int
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type rendererPool struct {
+	renderer plugin.ExpensiveRenderer
+	warm     int
+
+	idle chan plugin.Renderer
+	// Taken by each created instance, so there are at most Size instances.
+	slots chan struct{}
+
+	mu sync.Mutex
+
+	created   atomic.Int64
+	failed    atomic.Int64
+	discarded atomic.Int64
+	waits     atomic.Int64
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *rendererPool) Name() string {
+	return p.renderer.Name()
+}
+
+func (p *rendererPool) Render(src fs.File, w io.Writer) error {
+	return p.RenderContext(context.Background(), src, w)
+}
+
+func (p *rendererPool) RenderContext(ctx context.Context, src fs.File, w io.Writer) error {
+	return p.render(ctx, src, w)
+}
+
+func (p *rendererPool) RenderResponse(ctx context.Context, src fs.File, res plugin.Response, w io.Writer) error {
+	return p.render(plugin.WithResponse(ctx, res), src, w)
+}
+
+func (p *rendererPool) render(ctx context.Context, src fs.File, w io.Writer) error {
+	p.assert.NotNil(ctx)
+	p.assert.NotNil(src)
+	p.assert.NotNil(w)
+
+	r, err := p.acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	ok := false
+	defer func() {
+		if ok {
+			p.idle <- r
+			return
+		}
+		// The render panicked, so the instance is discarded and the panic
+		// is passed to the server.
+		p.discard(r)
+	}()
+
+	err = plugin.RenderContext(ctx, r, src, w)
+	ok = true
+	return err
+}
+
+// Returns a idle instance, creating one if the pool isn't full, or waiting for
+// one to be released.
+func (p *rendererPool) acquire(ctx context.Context) (plugin.Renderer, error) {
+	select {
+	case r := <-p.idle:
+		return r, nil
+	default:
+	}
+
+	select {
+	case r := <-p.idle:
+		return r, nil
+	case p.slots <- struct{}{}:
+		return p.create(ctx)
+	default:
+	}
+
+	p.waits.Add(1)
+	select {
+	case r := <-p.idle:
+		return r, nil
+	case p.slots <- struct{}{}:
+		return p.create(ctx)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Creates a instance on a slot that was already taken, releasing it if the
+// creation fails.
+func (p *rendererPool) create(ctx context.Context) (plugin.Renderer, error) {
+	p.log.Debug("Creating renderer instance")
+
+	r, err := p.renderer.NewRenderer(ctx)
+	if err == nil && r == nil {
+		err = errors.New("renderer returned a nil instance")
+	}
+	if err != nil {
+		<-p.slots
+		p.failed.Add(1)
+		p.log.Error("Failed to create renderer instance", slog.String("err", err.Error()))
+		return nil, errors.Join(errors.New("failed to create renderer instance"), err)
+	}
+
+	p.created.Add(1)
+	return r, nil
+}
+
+func (p *rendererPool) discard(r plugin.Renderer) {
+	p.discarded.Add(1)
+	p.log.Warn("Discarding renderer instance")
+	if c, ok := r.(io.Closer); ok {
+		_ = c.Close()
+	}
+	<-p.slots
+}
+
+func (p *rendererPool) Warm(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(p.idle) < p.warm {
+		select {
+		case p.slots <- struct{}{}:
+		default:
+			// The pool is full, the other instances are in use.
+			return nil
+		}
+		r, err := p.create(ctx)
+		if err != nil {
+			return err
+		}
+		p.idle <- r
+	}
+	return nil
+}
+
+func (p *rendererPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var errs []error
+	for {
+		select {
+		case r := <-p.idle:
+			if c, ok := r.(io.Closer); ok {
+				if err := c.Close(); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			<-p.slots
+		default:
+			return errors.Join(errs...)
+		}
+	}
+}
+
+func (p *rendererPool) Metrics() map[string]int64 {
+	return map[string]int64{
+		"created":   p.created.Load(),
+		"failed":    p.failed.Load(),
+		"discarded": p.discarded.Load(),
+		"waits":     p.waits.Load(),
+	}
+}
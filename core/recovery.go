@@ -0,0 +1,98 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"io/fs"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+)
+
+// Value returned by a [plugin.ErrorHandler] as it's recovery value, telling the
+// server how to continue serving the request. Use [RecoverWithSourcer],
+// [RecoverWithRenderer] and [RecoverWithResponse] to create it.
+//
+// Each stage of the server honors only the recoveries that make sense for it:
+//
+//   - [StageSource] and [StageOpen] recover with a Sourcer, used to source the
+//     file system (and open the requested file from it);
+//   - [StageRender] recovers with a Renderer, used to render the file again;
+//   - all stages recover with a Responder, which fully responds to the request.
+//
+// If a handler handles the error but doesn't return a applicable recovery, and
+// doesn't write to the response, the server responds with it's fallback response.
+type Recovery struct {
+	Sourcer   plugin.Sourcer
+	Renderer  plugin.Renderer
+	Responder Responder
+}
+
+// Recovers from a error on [StageSource] or [StageOpen] by using the sourcer.
+func RecoverWithSourcer(s plugin.Sourcer) Recovery {
+	return Recovery{Sourcer: s}
+}
+
+// Recovers from a error on [StageRender] by rendering the file with the renderer.
+func RecoverWithRenderer(r plugin.Renderer) Recovery {
+	return Recovery{Renderer: r}
+}
+
+// Recovers from a error on any stage by responding to the request with the responder.
+func RecoverWithResponse(r Responder) Recovery {
+	return Recovery{Responder: r}
+}
+
+func (r Recovery) empty() bool {
+	return r.Sourcer == nil && r.Renderer == nil && r.Responder == nil
+}
+
+// Converts the recovery value returned by error handlers to a [Recovery]. Values
+// that aren't a Recovery are still supported for compatibility with the previous
+// protocol, where handlers returned a [plugin.Sourcer], [fs.FS] or [plugin.Renderer]
+// directly.
+func toRecovery(v any) Recovery {
+	switch v := v.(type) {
+	case Recovery:
+		return v
+	case *Recovery:
+		if v != nil {
+			return *v
+		}
+	case Responder:
+		return RecoverWithResponse(v)
+	case plugin.Sourcer:
+		return RecoverWithSourcer(v)
+	case plugin.Renderer:
+		return RecoverWithRenderer(v)
+	case fs.FS:
+		return RecoverWithSourcer(fsSourcer{v})
+	}
+	return Recovery{}
+}
+
+const fsSourcerName = "blogo-recovered-sourcer"
+
+type fsSourcer struct {
+	fs.FS
+}
+
+func (s fsSourcer) Name() string {
+	return fsSourcerName
+}
+
+func (s fsSourcer) Source() (fs.FS, error) {
+	return s.FS, nil
+}
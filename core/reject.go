@@ -0,0 +1,195 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error of requests rejected before being served, such as by the load shedding
+// of ServerOpts.Overload or by middlewares with rate limits. It's passed to the
+// error handlers on [StageAdmission], wrapped in a [ServeError], so sites can
+// respond with their own error pages.
+type RejectError struct {
+	// Status code of the response, such as 429 or 503.
+	Status int
+	// Duration sent as the Retry-After header, if not zero.
+	RetryAfter time.Duration
+	// Message shown to the client, such as "Too many requests".
+	Reason string
+}
+
+func (e RejectError) Error() string {
+	return fmt.Sprintf("request rejected with status %d: %s", e.Status, e.Reason)
+}
+
+// Sets the Retry-After header of the response, rounding the duration up to
+// whole seconds.
+func (e RejectError) SetRetryAfter(h http.Header) {
+	if e.RetryAfter <= 0 {
+		return
+	}
+	h.Set("Retry-After", strconv.Itoa(e.retryAfterSeconds()))
+}
+
+func (e RejectError) retryAfterSeconds() int {
+	return int((e.RetryAfter + time.Second - 1) / time.Second)
+}
+
+// Responds to requests rejected before being served, see [Reject].
+type Rejecter interface {
+	Reject(w http.ResponseWriter, r *http.Request, err RejectError)
+}
+
+type rejecterKey struct{}
+
+// Returns a context where [Reject] uses the rejecter, such as the [Server] of
+// the engine, so middlewares in front of it respond with it's error handlers.
+func WithRejecter(ctx context.Context, rj Rejecter) context.Context {
+	return context.WithValue(ctx, rejecterKey{}, rj)
+}
+
+// Responds to the rejected request with the rejecter of it's context (see
+// [WithRejecter]), or with [RespondRejected] if there is none. Middlewares
+// that reject requests, such as for rate limits, should use it instead of
+// writing a bare status code.
+func Reject(w http.ResponseWriter, r *http.Request, err RejectError) {
+	if rj, ok := r.Context().Value(rejecterKey{}).(Rejecter); ok && rj != nil {
+		rj.Reject(w, r, err)
+		return
+	}
+	RespondRejected(w, r, err)
+}
+
+// Responds to the rejected request with it's status, Retry-After header and
+// reason, as JSON if the client prefers it over HTML, or as plain text.
+func RespondRejected(w http.ResponseWriter, r *http.Request, err RejectError) {
+	err.SetRetryAfter(w.Header())
+	w.Header().Set("Cache-Control", "no-store")
+
+	if PrefersJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(err.Status)
+		_ = json.NewEncoder(w).Encode(rejectionBody{
+			Error:      err.Reason,
+			Status:     err.Status,
+			RetryAfter: err.retryAfterSeconds(),
+		})
+		return
+	}
+
+	http.Error(w, err.Reason, err.Status)
+}
+
+// Body of the JSON responses of [RespondRejected].
+type rejectionBody struct {
+	Error  string `json:"error"`
+	Status int    `json:"status"`
+	// Seconds the client should wait before retrying, if known.
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+// Reports if the Accept header of the request prefers JSON over HTML, so
+// error responses can be sent in a format API clients understand.
+func PrefersJSON(r *http.Request) bool {
+	jsonQ, htmlQ := -1.0, -1.0
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+
+		switch {
+		case mt == "application/json" || strings.HasSuffix(mt, "+json"):
+			jsonQ = max(jsonQ, q)
+		case mt == "text/html" || mt == "application/xhtml+xml":
+			htmlQ = max(htmlQ, q)
+		}
+	}
+
+	return jsonQ > 0 && jsonQ > htmlQ
+}
+
+// Implements [Rejecter], passing the rejection to the error handler of
+// [StageAdmission], which can only recover with a [Responder], and responding
+// with [RespondRejected] if it isn't handled.
+func (srv *server) Reject(w http.ResponseWriter, r *http.Request, err RejectError) {
+	srv.assert.NotNil(w)
+	srv.assert.NotNil(r)
+	srv.assert.NotNil(srv.log)
+
+	log := srv.log.With(slog.String("path", r.URL.Path), slog.Int("status", err.Status))
+
+	name, perr := FilePath(r.URL.Path)
+	if perr != nil {
+		name = strings.Trim(r.URL.Path, "/")
+	}
+	serr := srv.serveError(StageAdmission, name, time.Now(), w, r, err)
+
+	tw := &trackingWriter{ResponseWriter: w}
+	serr.Res = tw
+
+	onerror := srv.errorHandler(StageAdmission)
+	v, handled := onerror.Handle(serr)
+	if !handled || tw.written {
+		if !tw.written {
+			log.Debug("Rejection not handled by error handler, writing default response")
+			RespondRejected(w, r, err)
+		}
+		return
+	}
+
+	recovr := toRecovery(v)
+	if recovr.Responder == nil {
+		log.Debug("Error handler did not respond to rejection, writing default response")
+		RespondRejected(w, r, err)
+		return
+	}
+
+	rec := newRecorder()
+	if rerr := recovr.Responder.Respond(rec, r); rerr != nil {
+		log.Error("Failed to respond rejection with Responder of error handler", slog.String("err", rerr.Error()))
+		RespondRejected(w, r, err)
+		return
+	}
+
+	// Rejections always keep their status and Retry-After, so clients and
+	// proxies know when to retry, even if the error page doesn't set them.
+	rec.status = err.Status
+	err.SetRetryAfter(rec.Header())
+	rec.Header().Set("Cache-Control", "no-store")
+
+	if ferr := rec.flush(w); ferr != nil {
+		log.Error("Failed to write response of error handler", slog.String("err", ferr.Error()))
+	}
+}
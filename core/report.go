@@ -0,0 +1,99 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// Hook invoked by the server for errors that aren't recovered by the error
+// handlers, and for panics while serving requests, so they can be sent to
+// error tracking services such as Sentry or Rollbar.
+//
+// The [ServeError] has the request, the stage and the file path of the error.
+// Panics are reported with a [*PanicError] as the error.
+type ErrorReporter interface {
+	Report(ctx context.Context, err ServeError)
+}
+
+// Type adapter to allow the use of ordinary functions as [ErrorReporter] implementations.
+type ErrorReporterFunc func(ctx context.Context, err ServeError)
+
+func (f ErrorReporterFunc) Report(ctx context.Context, err ServeError) {
+	f(ctx, err)
+}
+
+// Function that sends a error, with tags describing it, to a error tracking
+// service, the shape of the capture functions of most of their SDKs. For example,
+// with Sentry:
+//
+//	core.NewCaptureReporter(func(ctx context.Context, err error, tags map[string]string) {
+//		hub := sentry.GetHubFromContext(ctx)
+//		hub.WithScope(func(scope *sentry.Scope) {
+//			scope.SetTags(tags)
+//			hub.CaptureException(err)
+//		})
+//	})
+type CaptureFunc func(ctx context.Context, err error, tags map[string]string)
+
+// Creates a [ErrorReporter] that passes the wrapped error of reports to the capture
+// function, with the "path", "method", "filename", "stage", "elapsed" and "panic"
+// tags.
+func NewCaptureReporter(capture CaptureFunc) ErrorReporter {
+	return ErrorReporterFunc(func(ctx context.Context, serr ServeError) {
+		tags := map[string]string{
+			"filename": serr.Path,
+			"stage":    string(serr.Stage),
+			"elapsed":  serr.Elapsed.String(),
+		}
+		if serr.Req != nil {
+			tags["path"] = serr.Req.URL.Path
+			tags["method"] = serr.Req.Method
+		}
+
+		_, isPanic := serr.Err.(*PanicError)
+		tags["panic"] = strconv.FormatBool(isPanic)
+
+		err := serr.Err
+		if err == nil {
+			err = serr
+		}
+
+		capture(ctx, err, tags)
+	})
+}
+
+// Error of a recovered panic.
+type PanicError struct {
+	// Value passed to panic.
+	Value any
+	// Stack trace of the goroutine of the panic.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Returns the value of the panic if it's a error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
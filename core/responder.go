@@ -0,0 +1,161 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"io/fs"
+	"net/http"
+	"slices"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+)
+
+// Writes the response of a request. Error handlers should return a Responder as
+// their recovery value, instead of writing to [ServeError].Res directly, so the
+// server is the only component that writes the response: the Responder is
+// executed on a buffer, and if it fails, the server responds with it's fallback
+// without anything being written on top of a partial response.
+type Responder interface {
+	Respond(w http.ResponseWriter, r *http.Request) error
+}
+
+// Type adapter to allow the use of ordinary functions as [Responder] implementations.
+type ResponderFunc func(w http.ResponseWriter, r *http.Request) error
+
+func (f ResponderFunc) Respond(w http.ResponseWriter, r *http.Request) error {
+	return f(w, r)
+}
+
+// [http.ResponseWriter] that tracks if the response was written, so the server
+// knows if a error handler already responded.
+type trackingWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *trackingWriter) WriteHeader(status int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *trackingWriter) Write(p []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *trackingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Minimal [http.ResponseWriter] that holds the response in memory.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: http.Header{}, status: http.StatusOK}
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	return r.body.Write(p)
+}
+
+func (r *recorder) flush(w http.ResponseWriter) error {
+	for k, v := range r.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(r.status)
+	_, err := w.Write(r.body.Bytes())
+	return err
+}
+
+// Status and headers set by [plugin.ResponseRenderer]s, which are written to the
+// response before the first write of the render, see [responseWriter].
+type renderResponse struct {
+	header http.Header
+	status int
+}
+
+// Creates the response of the render of the file, with the headers of it's
+// information if it implements [plugin.FileInfo].
+func newRenderResponse(file fs.File) *renderResponse {
+	res := &renderResponse{header: http.Header{}}
+	if info, err := file.Stat(); err == nil {
+		if info, ok := info.(plugin.FileInfo); ok {
+			for k, v := range info.Header() {
+				res.header[http.CanonicalHeaderKey(k)] = slices.Clone(v)
+			}
+		}
+	}
+	return res
+}
+
+func (r *renderResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *renderResponse) SetStatus(code int) {
+	r.status = code
+}
+
+// [http.ResponseWriter] that writes the status and headers of the [renderResponse]
+// before the first write of the render, or when flushed if nothing was written.
+type responseWriter struct {
+	http.ResponseWriter
+	res     *renderResponse
+	flushed bool
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.flush()
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.res.status == 0 {
+		w.res.status = status
+	}
+	w.flush()
+}
+
+func (w *responseWriter) flush() {
+	if w.flushed {
+		return
+	}
+	w.flushed = true
+
+	for k, v := range w.res.header {
+		w.ResponseWriter.Header()[k] = v
+	}
+	if w.res.status != 0 {
+		w.ResponseWriter.WriteHeader(w.res.status)
+	}
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,229 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// Returned by [(Server).ImportCache] when the cache was rendered from other
+// files than the ones sourced by the server.
+var ErrCacheMismatch = errors.New("cache was rendered from other files")
+
+// Writes the cached renders of the cache of the file, with the fingerprint of
+// the files they were rendered from, so they can be imported by another server.
+func (c *renderCache) export(w io.Writer, fingerprint string) error {
+	c.mu.Lock()
+	data := c.snapshot()
+	c.mu.Unlock()
+
+	data.Fingerprint = fingerprint
+	return gob.NewEncoder(w).Encode(data)
+}
+
+// Loads the renders exported by export, if they were rendered from the files of
+// the fingerprint, returning the number of loaded renders and of renders with
+// invalid signatures. The expiration of the renders is reset, since the files
+// they were rendered from are the same.
+func (c *renderCache) importFrom(r io.Reader, fingerprint string) (int, int, error) {
+	var data renderCacheFile
+	if err := gob.NewDecoder(r).Decode(&data); err != nil {
+		return 0, 0, errors.Join(errors.New("failed to decode cache"), err)
+	}
+	if data.Version != renderCacheVersion {
+		return 0, 0, fmt.Errorf("unsupported version %d of cache", data.Version)
+	}
+	if data.Fingerprint != fingerprint {
+		return 0, 0, ErrCacheMismatch
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.fingerprint != "" && c.fingerprint != fingerprint {
+		return 0, 0, ErrCacheMismatch
+	}
+	c.fingerprint = fingerprint
+
+	n, invalid := c.load(data.Entries, true)
+	c.scheduleSave()
+
+	return n, invalid, nil
+}
+
+func (srv *server) ExportCache(w io.Writer) error {
+	if srv.cache == nil {
+		return errors.New("cache is disabled")
+	}
+
+	fsys, err := srv.sourcedFiles()
+	if err != nil {
+		return err
+	}
+
+	fingerprint, err := srv.fingerprint(fsys)
+	if err != nil {
+		return errors.Join(errors.New("failed to fingerprint files"), err)
+	}
+
+	if err := srv.cache.export(w, fingerprint); err != nil {
+		return errors.Join(errors.New("failed to export cache"), err)
+	}
+	return nil
+}
+
+func (srv *server) ImportCache(r io.Reader) (int, error) {
+	if srv.cache == nil {
+		return 0, errors.New("cache is disabled")
+	}
+
+	fsys, err := srv.sourcedFiles()
+	if err != nil {
+		return 0, err
+	}
+
+	return srv.importCache(r, fsys)
+}
+
+// Imports the cache exported by [(Server).ExportCache], if it was rendered from
+// the files.
+func (srv *server) importCache(r io.Reader, fsys fs.FS) (int, error) {
+	fingerprint, err := srv.fingerprint(fsys)
+	if err != nil {
+		return 0, errors.Join(errors.New("failed to fingerprint files"), err)
+	}
+
+	n, invalid, err := srv.cache.importFrom(r, fingerprint)
+	if err != nil {
+		return 0, errors.Join(errors.New("failed to import cache"), err)
+	}
+	if invalid > 0 {
+		srv.log.Warn("Discarded imported renders with invalid signatures", slog.Int("entries", invalid))
+	}
+
+	srv.log.Debug("Cache imported", slog.String("fingerprint", fingerprint), slog.Int("entries", n))
+
+	return n, nil
+}
+
+func (srv *server) Prerender(ctx context.Context, paths ...string) (int, error) {
+	fsys, err := srv.sourcedFiles()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(paths) == 0 {
+		err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			paths = append(paths, path)
+			return nil
+		})
+		if err != nil {
+			return 0, errors.Join(errors.New("failed to walk files"), err)
+		}
+	}
+
+	n := 0
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+
+		r, err := http.NewRequestWithContext(ctx, http.MethodGet, "/"+path, nil)
+		if err != nil {
+			return n, errors.Join(fmt.Errorf("failed to create request of %q", path), err)
+		}
+		if path == "." {
+			r.URL.Path = "/"
+		}
+
+		w := &discardWriter{header: http.Header{}, status: http.StatusOK}
+		srv.ServeHTTP(w, r)
+
+		if w.status != http.StatusOK {
+			srv.log.Warn("Failed to prerender file",
+				slog.String("path", path), slog.Int("status", w.status))
+			continue
+		}
+		n++
+	}
+
+	srv.log.Debug("Files prerendered", slog.Int("files", n), slog.Int("paths", len(paths)))
+
+	return n, nil
+}
+
+// Returns the sourced files, sourcing them if the server isn't ready yet.
+func (srv *server) sourcedFiles() (fs.FS, error) {
+	if fsys := srv.getFiles(); fsys != nil {
+		return fsys, nil
+	}
+
+	fsys, err := srv.sourcer.Source()
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to source files"), err)
+	}
+	srv.setFiles(fsys)
+
+	return fsys, nil
+}
+
+// Imports the cache of ServerOpts.CacheImport, when the files are first sourced.
+func (srv *server) importCacheFile(fsys fs.FS) {
+	log := srv.log.With(slog.String("file", srv.cacheImport))
+
+	f, err := os.Open(srv.cacheImport)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Debug("Cache import file doesn't exist, starting with a cold cache")
+		return
+	} else if err != nil {
+		log.Warn("Failed to open cache import file", slog.String("err", err.Error()))
+		return
+	}
+	defer f.Close()
+
+	if _, err := srv.importCache(f, fsys); err != nil {
+		log.Warn("Failed to import cache", slog.String("err", err.Error()))
+	}
+}
+
+// [http.ResponseWriter] that discards the body of prerenders.
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
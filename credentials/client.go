@@ -0,0 +1,143 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/httpclient"
+)
+
+// Returns a [Provider] of tokens from the OAuth 2.0 client credentials grant
+// (RFC 6749, section 4.4) of the token endpoint, such as the token endpoint of
+// a OpenID provider (see Discover of the oidc package) or of the security token
+// service of a cloud. Tokens are cached and refreshed before they expire, see
+// [Refreshing].
+func ClientCredentials(
+	tokenURL, clientID, clientSecret string,
+	opts ...ClientCredentialsOpts,
+) Provider {
+	opt := ClientCredentialsOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.HTTPClient == nil {
+		opt.HTTPClient = httpclient.Default()
+	}
+	if opt.Timeout == 0 {
+		opt.Timeout = 10 * time.Second
+	}
+
+	return Refreshing(&clientCredentials{
+		url:          tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+
+		scopes:   opt.Scopes,
+		audience: opt.Audience,
+
+		client:  opt.HTTPClient,
+		timeout: opt.Timeout,
+	}, opt.RefreshOpts)
+}
+
+type ClientCredentialsOpts struct {
+	// Scopes requested for the tokens.
+	Scopes []string
+	// Audience of the tokens, sent as the "audience" parameter, which some
+	// providers require.
+	Audience string
+
+	HTTPClient *http.Client
+	// Timeout of the requests to the token endpoint. Defaults to 10 seconds.
+	Timeout time.Duration
+
+	RefreshOpts RefreshOpts
+}
+
+type clientCredentials struct {
+	url          string
+	clientID     string
+	clientSecret string
+
+	scopes   []string
+	audience string
+
+	client  *http.Client
+	timeout time.Duration
+}
+
+func (c *clientCredentials) Token(ctx context.Context) (Token, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.scopes) > 0 {
+		form.Set("scope", strings.Join(c.scopes, " "))
+	}
+	if c.audience != "" {
+		form.Set("audience", c.audience)
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, c.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, errors.Join(errors.New("failed to create request"), err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.clientID), url.QueryEscape(c.clientSecret))
+
+	start := time.Now()
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return Token{}, errors.Join(errors.New("failed to send request"), err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return Token{}, fmt.Errorf("unexpected response status %q of token endpoint", res.Status)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&body); err != nil {
+		return Token{}, errors.Join(errors.New("failed to decode response"), err)
+	}
+	if body.AccessToken == "" {
+		return Token{}, errors.New("token endpoint didn't return a access token")
+	}
+
+	t := Token{Value: body.AccessToken}
+	if body.ExpiresIn > 0 {
+		// The expiry is counted from before the request, so latency doesn't make
+		// the token be used after it expires.
+		t.Expiry = start.Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+	return t, nil
+}
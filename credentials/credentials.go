@@ -0,0 +1,176 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package credentials provides the access tokens used by sourcers to access
+// their APIs, such as the Gitea sourcer, from static values, environment
+// variables, files and OAuth client credentials, refreshing tokens before they
+// expire so long-running servers don't start failing when they do.
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A access token.
+type Token struct {
+	Value string
+	// Time after which the token is no longer valid, zero if it doesn't expire.
+	Expiry time.Time
+}
+
+// Reports if the token is expired, or expires in less than the margin.
+func (t Token) Expired(margin time.Duration) bool {
+	return !t.Expiry.IsZero() && time.Until(t.Expiry) < margin
+}
+
+// Provider of the token used on requests. Implementations should be safe for
+// concurrent use.
+type Provider interface {
+	Token(ctx context.Context) (Token, error)
+}
+
+// Type adapter to allow the use of ordinary functions as [Provider] implementations.
+type ProviderFunc func(ctx context.Context) (Token, error)
+
+func (f ProviderFunc) Token(ctx context.Context) (Token, error) {
+	return f(ctx)
+}
+
+// Returns a [Provider] of the token, which never expires.
+func Static(token string) Provider {
+	return ProviderFunc(func(ctx context.Context) (Token, error) {
+		return Token{Value: token}, nil
+	})
+}
+
+// Returns a [Provider] of the token on the environment variable, read on every
+// call, so the variable can be changed while the server is running.
+func Env(name string) Provider {
+	return ProviderFunc(func(ctx context.Context) (Token, error) {
+		v := strings.TrimSpace(os.Getenv(name))
+		if v == "" {
+			return Token{}, fmt.Errorf("environment variable %q is not set", name)
+		}
+		return Token{Value: v}, nil
+	})
+}
+
+// Returns a [Provider] of the token on the file, such as a secret mounted by a
+// orchestrator, which is read again when it's modification time changes, so
+// rotated tokens are used without restarting the server.
+func File(name string) Provider {
+	return &file{name: name}
+}
+
+type file struct {
+	name string
+
+	mu      sync.Mutex
+	token   Token
+	modTime time.Time
+}
+
+func (f *file) Token(ctx context.Context) (Token, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.name)
+	if err != nil {
+		return Token{}, errors.Join(fmt.Errorf("failed to stat token file %q", f.name), err)
+	}
+	if f.token.Value != "" && info.ModTime().Equal(f.modTime) {
+		return f.token, nil
+	}
+
+	data, err := os.ReadFile(f.name)
+	if err != nil {
+		return Token{}, errors.Join(fmt.Errorf("failed to read token file %q", f.name), err)
+	}
+
+	v := strings.TrimSpace(string(data))
+	if v == "" {
+		return Token{}, fmt.Errorf("token file %q is empty", f.name)
+	}
+
+	f.token, f.modTime = Token{Value: v}, info.ModTime()
+	return f.token, nil
+}
+
+// Returns a [Provider] that caches the tokens of the provider, getting a new
+// one when the cached token is about to expire, see RefreshOpts.Margin. Tokens
+// without expiry are cached forever.
+//
+// If getting a new token fails while the cached token isn't expired yet, the
+// cached token is still returned, so a unavailable token endpoint doesn't make
+// requests fail before it needs to. Concurrent calls wait for the same refresh.
+func Refreshing(p Provider, opts ...RefreshOpts) Provider {
+	opt := RefreshOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Margin == 0 {
+		opt.Margin = time.Minute
+	}
+
+	return &refreshing{provider: p, margin: opt.Margin, onError: opt.OnError}
+}
+
+type RefreshOpts struct {
+	// Duration before the expiry of the token when it is refreshed. Defaults
+	// to one minute.
+	Margin time.Duration
+	// Called with the errors of refreshes that were ignored, because the
+	// cached token was still valid.
+	OnError func(error)
+}
+
+type refreshing struct {
+	provider Provider
+	margin   time.Duration
+	onError  func(error)
+
+	mu    sync.Mutex
+	token Token
+}
+
+func (r *refreshing) Token(ctx context.Context) (Token, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.token.Value != "" && !r.token.Expired(r.margin) {
+		return r.token, nil
+	}
+
+	t, err := r.provider.Token(ctx)
+	if err != nil {
+		if r.token.Value != "" && !r.token.Expired(0) {
+			if r.onError != nil {
+				r.onError(err)
+			}
+			return r.token, nil
+		}
+		return Token{}, errors.Join(errors.New("failed to refresh token"), err)
+	}
+
+	r.token = t
+	return t, nil
+}
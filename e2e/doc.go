@@ -0,0 +1,26 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package e2e has the end-to-end tests of blogo, that serve a fixture content
+// tree with the full engine and real plugins (the local sourcer, frontmatter,
+// content types, the index, markdown and feeds) and compare the responses with
+// golden files.
+//
+// The tests are both a regression protection for the core and a executable
+// documentation of a full setup, see the newSite function of the tests for
+// how the plugins are assembled. Golden files are updated with:
+//
+//	go test ./e2e -update
+package e2e
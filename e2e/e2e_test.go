@@ -0,0 +1,254 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e_test
+
+import (
+	"bytes"
+	"flag"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo"
+	"forge.capytal.company/loreddev/blogo/contenttype"
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/index"
+	"forge.capytal.company/loreddev/blogo/plugins"
+	"forge.capytal.company/loreddev/blogo/plugins/feed"
+	"forge.capytal.company/loreddev/blogo/plugins/frontmatter"
+	"forge.capytal.company/loreddev/blogo/plugins/markdown"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the end-to-end tests")
+
+var notFound = template.Must(template.New("not-found").Parse(`<h1>Not found</h1>
+<p>{{.Path}} doesn't exist.</p>
+`))
+
+// Assembles the fixture site the same way a blog would: files are sourced from
+// the content directory, have their frontmatter parsed and their permalinks set
+// by their content type, and are indexed, so the feeds can list them.
+func newSite(t *testing.T) (blogo.Blogo, *httptest.Server) {
+	t.Helper()
+
+	types := contenttype.New()
+
+	src := frontmatter.New(plugins.NewLocalSourcer("testdata/content"), frontmatter.Opts{
+		Processors: []frontmatter.Processor{contenttype.Processor(types)},
+	})
+	idx := index.New(contenttype.NewSourcer(src))
+
+	b := blogo.New(blogo.Opts{
+		ServerOptions: []core.ServerOption{core.WithCache(time.Hour)},
+		SourceOnInit:  true,
+	})
+
+	b.Use(idx)
+	b.Use(markdown.New(markdown.Opts{Footnotes: true}))
+	b.Use(feed.New(idx, feed.Opts{
+		Title:       "Fixture",
+		Description: "The fixture site of the end-to-end tests.",
+		BaseURL:     "https://example.com",
+		Visibility:  contenttype.Visibility(types, nil),
+	}))
+	b.Use(feed.New(idx, feed.Opts{
+		Format:      feed.Atom,
+		Title:       "Fixture",
+		Description: "The fixture site of the end-to-end tests.",
+		BaseURL:     "https://example.com",
+		Visibility:  contenttype.Visibility(types, nil),
+	}))
+
+	b.Use(plugins.NewNotFoundErrorHandler(*notFound))
+
+	b.Init()
+
+	srv := httptest.NewServer(b)
+	t.Cleanup(srv.Close)
+
+	return b, srv
+}
+
+func get(t *testing.T, srv *httptest.Server, path string) (int, []byte) {
+	t.Helper()
+
+	res, err := srv.Client().Get(srv.URL + path)
+	if err != nil {
+		t.Fatalf("Failed to request %q: %s %v", path, err.Error(), err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("Failed to read body of %q: %s %v", path, err.Error(), err)
+	}
+
+	return res.StatusCode, body
+}
+
+// Compares the contents with the golden file of the name, writing it instead
+// if the -update flag is set.
+func golden(t *testing.T, name string, contents []byte) {
+	t.Helper()
+
+	p := filepath.Join("testdata", "golden", name+".golden")
+
+	if *update {
+		if err := os.WriteFile(p, contents, 0o644); err != nil {
+			t.Fatalf("Failed to update golden file %q: %s %v", p, err.Error(), err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatalf("Failed to read golden file %q: %s %v", p, err.Error(), err)
+	}
+
+	if !bytes.Equal(contents, want) {
+		t.Fatalf("Contents differ from golden file %q, run with -update if the change is expected:\n%s",
+			p, contents)
+	}
+}
+
+func TestPages(t *testing.T) {
+	_, srv := newSite(t)
+
+	for _, tt := range []struct {
+		name string
+		path string
+	}{
+		{name: "post", path: "/posts/hello"},
+		{name: "post-path", path: "/posts/hello.md"},
+		{name: "page", path: "/about.md"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := get(t, srv, tt.path)
+			if status != http.StatusOK {
+				t.Fatalf("Expected status %d for %q, got %d: %s", http.StatusOK, tt.path, status, body)
+			}
+			golden(t, tt.name, body)
+		})
+	}
+}
+
+func TestFeeds(t *testing.T) {
+	_, srv := newSite(t)
+
+	for _, tt := range []struct {
+		name string
+		path string
+	}{
+		{name: "rss", path: "/feed.xml"},
+		{name: "atom", path: "/atom.xml"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := get(t, srv, tt.path)
+			if status != http.StatusOK {
+				t.Fatalf("Expected status %d for %q, got %d: %s", http.StatusOK, tt.path, status, body)
+			}
+			if bytes.Contains(body, []byte("Unfinished")) {
+				t.Fatalf("Draft should not be on the feed %q:\n%s", tt.path, body)
+			}
+			if bytes.Contains(body, []byte("About")) {
+				t.Fatalf("Pages should not be on the feed %q:\n%s", tt.path, body)
+			}
+			golden(t, tt.name, body)
+		})
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	_, srv := newSite(t)
+
+	status, body := get(t, srv, "/posts/does-not-exist")
+	if status != http.StatusNotFound {
+		t.Fatalf("Expected status %d for missing file, got %d: %s", http.StatusNotFound, status, body)
+	}
+	golden(t, "not-found", body)
+}
+
+func TestCache(t *testing.T) {
+	b, srv := newSite(t)
+
+	_, first := get(t, srv, "/posts/second")
+	_, second := get(t, srv, "/posts/second")
+
+	if !bytes.Equal(first, second) {
+		t.Fatalf("Cached response differs from the first one:\n%s\n%s", first, second)
+	}
+	if !strings.Contains(string(first), "Second Post") {
+		t.Fatalf("Unexpected response for the second post:\n%s", first)
+	}
+
+	metrics, ok := b.(interface{ Metrics() *core.Metrics })
+	if !ok {
+		t.Fatalf("Engine doesn't expose it's metrics")
+	}
+
+	m := metrics.Metrics().Collect()["blogo-core-server"]
+	if m["renders"] != 1 || m["cache_hits"] != 1 {
+		t.Fatalf("Expected 1 render and 1 cache hit, got %d renders and %d cache hits",
+			m["renders"], m["cache_hits"])
+	}
+}
+
+func TestPaths(t *testing.T) {
+	_, srv := newSite(t)
+
+	client := srv.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	for _, tt := range []struct {
+		path     string
+		status   int
+		location string
+	}{
+		{path: "/posts/../about.md", status: http.StatusMovedPermanently, location: "/about.md"},
+		{path: "/posts//hello?ref=feed", status: http.StatusMovedPermanently, location: "/posts/hello?ref=feed"},
+		{path: "/../../etc/passwd", status: http.StatusMovedPermanently, location: "/etc/passwd"},
+		{path: "/posts%5C..%5Cabout.md", status: http.StatusBadRequest},
+		{path: "/about.md%00", status: http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %s %v", err.Error(), err)
+		}
+		// Set as opaque so the client doesn't clean the path before sending it.
+		req.URL.Opaque = tt.path
+
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to request %q: %s %v", tt.path, err.Error(), err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != tt.status {
+			t.Fatalf("Expected status %d for %q, got %d", tt.status, tt.path, res.StatusCode)
+		}
+		if l := res.Header.Get("Location"); l != tt.location {
+			t.Fatalf("Expected location %q for %q, got %q", tt.location, tt.path, l)
+		}
+	}
+}
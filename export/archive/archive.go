@@ -0,0 +1,470 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package archive exports the whole blog as a zip or gzipped tar archive, with
+// either it's raw sources or the rendered static site, for backups and offline
+// mirrors. Archives can be written at build time with [WriteSources] and
+// [WriteSite], or served by the authenticated endpoint of [New].
+//
+// Archives written with [FixedTimestamps] are reproducible, byte-identical for
+// identical inputs, and [WriteSite] can verify that the renders of the site are
+// too with Opts.VerifyWith.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+// Format of a archive.
+type Format string
+
+const (
+	Zip   Format = "zip"
+	TarGz Format = "tar.gz"
+)
+
+// Media type of the archives of the format.
+func (f Format) MediaType() string {
+	if f == TarGz {
+		return "application/gzip"
+	}
+	return "application/zip"
+}
+
+// Policy of the modification times of the files on archives.
+type Timestamps int
+
+const (
+	// Files of the file system keep their modification time, and the other
+	// files have Opts.ModTime.
+	FileTimestamps Timestamps = iota
+	// All files have Opts.ModTime, in UTC and truncated to seconds, so archives
+	// of the same files are byte-identical regardless of when the files were
+	// checked out, for CDN diffs and signed releases.
+	FixedTimestamps
+)
+
+// Time of the files of archives with [FixedTimestamps] if Opts.ModTime isn't
+// set and there isn't a "SOURCE_DATE_EPOCH", the earliest time zip files support.
+var FixedModTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Returned by [WriteSite] if the renders of the site differ between builds,
+// see Opts.VerifyWith.
+type NotReproducibleError struct {
+	// Names of the files on the archive that differ, or are only on one of the
+	// builds, sorted.
+	Files []string
+}
+
+func (e *NotReproducibleError) Error() string {
+	return fmt.Sprintf("site is not reproducible, %d files differ between builds: %s",
+		len(e.Files), strings.Join(e.Files, ", "))
+}
+
+// Options used by [WriteSources] and [WriteSite].
+type Opts struct {
+	// Format of the archive. Defaults to [Zip].
+	Format Format
+	// Filters which files of the file system are added to the archive, or
+	// rendered, by their path. By default all files are.
+	Filter func(path string) bool
+	// Modification time of the files of the site, which don't have one.
+	// Defaults to the time of the "SOURCE_DATE_EPOCH" environment variable if
+	// set, as defined by https://reproducible-builds.org/specs/source-date-epoch,
+	// otherwise to [FixedModTime] with [FixedTimestamps] or the current time.
+	ModTime time.Time
+	// Policy of the modification times of the files. Defaults to [FileTimestamps].
+	Timestamps Timestamps
+	// Creates a new handler, such as a new engine with the same plugins, that
+	// [WriteSite] renders the site again with before writing the archive, failing
+	// with a [*NotReproducibleError] if any file differs, so non-deterministic
+	// renders are found before they are published. The handler of WriteSite
+	// can't be reused, since the engine caches renders. If nil, the site isn't
+	// verified.
+	VerifyWith func() (http.Handler, error)
+	// Additional files added to the archive after the ones of the file system,
+	// by their name, such as metadata of the archive.
+	Files map[string][]byte
+
+	// Host of the requests of the renders of [WriteSite]. Defaults to "localhost".
+	Host string
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+func (opt *Opts) defaults() {
+	if opt.Format == "" {
+		opt.Format = Zip
+	}
+	if opt.Filter == nil {
+		opt.Filter = func(string) bool { return true }
+	}
+	if opt.ModTime.IsZero() {
+		if t, ok := sourceDateEpoch(); ok {
+			opt.ModTime = t
+		} else if opt.Timestamps == FixedTimestamps {
+			opt.ModTime = FixedModTime
+		} else {
+			opt.ModTime = time.Now()
+		}
+	}
+	if opt.Timestamps == FixedTimestamps {
+		opt.ModTime = opt.ModTime.UTC().Truncate(time.Second)
+	}
+	if opt.Host == "" {
+		opt.Host = "localhost"
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+}
+
+// Writes the raw files of fsys as a archive to w.
+func WriteSources(w io.Writer, fsys fs.FS, opts ...Opts) error {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	opt.defaults()
+
+	opt.Assertions.NotNil(w)
+	opt.Assertions.NotNil(fsys)
+
+	aw, err := newWriter(w, opt.Format)
+	if err != nil {
+		return err
+	}
+
+	err = fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !opt.Filter(p) {
+			return nil
+		}
+
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return errors.Join(fmt.Errorf("failed to read file %q", p), err)
+		}
+
+		modTime := opt.ModTime
+		if opt.Timestamps == FileTimestamps {
+			if info, err := d.Info(); err == nil && !info.ModTime().IsZero() {
+				modTime = info.ModTime()
+			}
+		}
+
+		return aw.add(p, modTime, data)
+	})
+	if err != nil {
+		return errors.Join(errors.New("failed to archive files"), err)
+	}
+
+	if err := addFiles(aw, opt); err != nil {
+		return err
+	}
+
+	return aw.Close()
+}
+
+// Writes the static site rendered by the handler, such as the engine, as a
+// archive to w. Each file and directory of fsys is requested by it's path,
+// and the responses with "200 OK" are added to the archive. Directories, and
+// pages without a extension, are added as their "index.html" file, so the
+// archive can be served by any static file server.
+//
+// Files are added in lexical order, so with [FixedTimestamps] the archive is
+// byte-identical for identical inputs, as long as the renders are too, which
+// can be verified with Opts.VerifyWith.
+func WriteSite(ctx context.Context, w io.Writer, fsys fs.FS, handler http.Handler, opts ...Opts) error {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	opt.defaults()
+
+	opt.Assertions.NotNil(w)
+	opt.Assertions.NotNil(fsys)
+	opt.Assertions.NotNil(handler)
+
+	if opt.VerifyWith == nil {
+		aw, err := newWriter(w, opt.Format)
+		if err != nil {
+			return err
+		}
+		return writeSite(ctx, aw, fsys, handler, opt)
+	}
+
+	other, err := opt.VerifyWith()
+	if err != nil {
+		return errors.Join(errors.New("failed to create handler to verify site"), err)
+	}
+
+	var buf bytes.Buffer
+	aw, err := newWriter(&buf, opt.Format)
+	if err != nil {
+		return err
+	}
+
+	first := &digestWriter{writer: aw, digests: map[string][sha256.Size]byte{}}
+	if err := writeSite(ctx, first, fsys, handler, opt); err != nil {
+		return err
+	}
+	second := &digestWriter{digests: map[string][sha256.Size]byte{}}
+	if err := writeSite(ctx, second, fsys, other, opt); err != nil {
+		return errors.Join(errors.New("failed to render site to verify it"), err)
+	}
+
+	if files := first.diff(second); len(files) > 0 {
+		return &NotReproducibleError{Files: files}
+	}
+
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+func writeSite(ctx context.Context, aw writer, fsys fs.FS, handler http.Handler, opt Opts) error {
+	log := opt.Logger.With()
+
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !opt.Filter(p) {
+			if d.IsDir() && p != "." {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		u := "/" + p
+		if p == "." {
+			u = "/"
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return errors.Join(fmt.Errorf("failed to create request of %q", p), err)
+		}
+		req.Host = opt.Host
+		req.RemoteAddr = "127.0.0.1:0"
+		req.RequestURI = u
+
+		rec := &recorder{header: http.Header{}, status: http.StatusOK}
+		handler.ServeHTTP(rec, req)
+
+		if rec.status != http.StatusOK {
+			log.Debug("File not rendered, skipping it",
+				slog.String("path", p), slog.Int("status", rec.status))
+			return nil
+		}
+
+		return aw.add(siteName(p, d.IsDir(), rec.header), opt.ModTime, rec.body.Bytes())
+	})
+	if err != nil {
+		return errors.Join(errors.New("failed to archive site"), err)
+	}
+
+	if err := addFiles(aw, opt); err != nil {
+		return err
+	}
+
+	return aw.Close()
+}
+
+// Returns the time of the "SOURCE_DATE_EPOCH" environment variable, if set.
+func sourceDateEpoch() (time.Time, bool) {
+	v := os.Getenv("SOURCE_DATE_EPOCH")
+	if v == "" {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0).UTC(), true
+}
+
+// Adds the additional files of the options, sorted by name.
+func addFiles(aw writer, opt Opts) error {
+	names := make([]string, 0, len(opt.Files))
+	for n := range opt.Files {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+
+	for _, n := range names {
+		if err := aw.add(n, opt.ModTime, opt.Files[n]); err != nil {
+			return errors.Join(fmt.Errorf("failed to archive file %q", n), err)
+		}
+	}
+	return nil
+}
+
+// Returns the name of the render of the file on the archive.
+func siteName(p string, dir bool, h http.Header) string {
+	if dir {
+		return path.Join(p, "index.html")
+	}
+	if path.Ext(p) == "" && strings.HasPrefix(h.Get("Content-Type"), "text/html") {
+		return path.Join(p, "index.html")
+	}
+	return p
+}
+
+type writer interface {
+	add(name string, modTime time.Time, data []byte) error
+	Close() error
+}
+
+func newWriter(w io.Writer, f Format) (writer, error) {
+	switch f {
+	case Zip:
+		return &zipWriter{zip.NewWriter(w)}, nil
+	case TarGz:
+		gz := gzip.NewWriter(w)
+		return &tarWriter{tar.NewWriter(gz), gz}, nil
+	default:
+		return nil, fmt.Errorf("unsupported archive format %q", f)
+	}
+}
+
+// Writer that records the digests of the files added to it, used to compare
+// builds of the site. Files are only written if it wraps a writer.
+type digestWriter struct {
+	writer  writer
+	digests map[string][sha256.Size]byte
+}
+
+func (w *digestWriter) add(name string, modTime time.Time, data []byte) error {
+	w.digests[name] = sha256.Sum256(data)
+	if w.writer == nil {
+		return nil
+	}
+	return w.writer.add(name, modTime, data)
+}
+
+func (w *digestWriter) Close() error {
+	if w.writer == nil {
+		return nil
+	}
+	return w.writer.Close()
+}
+
+// Returns the names of the files that differ from the ones of the other writer,
+// sorted.
+func (w *digestWriter) diff(other *digestWriter) []string {
+	files := []string{}
+	for n, d := range w.digests {
+		if od, ok := other.digests[n]; !ok || od != d {
+			files = append(files, n)
+		}
+	}
+	for n := range other.digests {
+		if _, ok := w.digests[n]; !ok {
+			files = append(files, n)
+		}
+	}
+	slices.Sort(files)
+	return files
+}
+
+type zipWriter struct {
+	*zip.Writer
+}
+
+func (w *zipWriter) add(name string, modTime time.Time, data []byte) error {
+	f, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime})
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to create file %q", name), err)
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+type tarWriter struct {
+	*tar.Writer
+	gz *gzip.Writer
+}
+
+func (w *tarWriter) add(name string, modTime time.Time, data []byte) error {
+	err := w.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0o644,
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to create file %q", name), err)
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (w *tarWriter) Close() error {
+	return errors.Join(w.Writer.Close(), w.gz.Close())
+}
+
+// Minimal [http.ResponseWriter] that holds the renders of [WriteSite] in memory.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	wrote  bool
+}
+
+func (w *recorder) Header() http.Header {
+	return w.header
+}
+
+func (w *recorder) WriteHeader(status int) {
+	if !w.wrote {
+		w.status, w.wrote = status, true
+	}
+}
+
+func (w *recorder) Write(p []byte) (int, error) {
+	w.wrote = true
+	return w.body.Write(p)
+}
@@ -0,0 +1,263 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/auth"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-archive-sourcer"
+
+// Contents of the archives served by [New].
+type Contents string
+
+const (
+	// The raw sources, see [WriteSources].
+	Sources Contents = "sources"
+	// The rendered static site, see [WriteSite].
+	Site Contents = "site"
+)
+
+// The archive plugin, which wraps a [plugin.Sourcer] to keep it's last file
+// system and is a [plugin.Middleware] serving the archives.
+type Plugin interface {
+	plugin.Sourcer
+	plugin.Middleware
+}
+
+// Creates the archive [Plugin], wrapping the sourcer, which serves the archive
+// of the blog on "<Opts.Path>.zip" and "<Opts.Path>.tar.gz", such as
+// "/archive.zip". The "contents" query value selects the [Contents] of the
+// archive, defaulting to Opts.Contents. The site is rendered by the rest of the
+// engine, so the middleware should be added after the ones that change the site.
+//
+// All requests need to be authenticated by Opts.Authenticator, with the identity
+// having Opts.Scopes. If no authenticator is provided, all requests are rejected.
+func New(sourcer plugin.Sourcer, opts ...HandlerOpts) Plugin {
+	opt := HandlerOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Path == "" {
+		opt.Path = "/archive"
+	}
+	if opt.Contents == "" {
+		opt.Contents = Site
+	}
+	if opt.Scopes == nil {
+		opt.Scopes = []string{"admin"}
+	}
+	if opt.Name == "" {
+		opt.Name = "blog"
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be wrapped should not be nil")
+
+	return &p{
+		sourcer: sourcer,
+
+		path:          "/" + strings.Trim(opt.Path, "/"),
+		contents:      opt.Contents,
+		filter:        opt.Filter,
+		name:          opt.Name,
+		authenticator: opt.Authenticator,
+		scopes:        opt.Scopes,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type HandlerOpts struct {
+	// Path of the archives, without the extension. Defaults to "/archive".
+	Path string
+	// Default contents of the archives. Defaults to [Site].
+	Contents Contents
+	// Filters which files are archived, see Opts.Filter.
+	Filter func(path string) bool
+	// Prefix of the name of the downloaded files, followed by the contents and
+	// the date, such as "blog-site-2025-01-02.zip". Defaults to "blog".
+	Name string
+
+	// Authenticator of requests. If nil, all requests are rejected, so the
+	// sources can't be downloaded by mistake.
+	Authenticator auth.Authenticator
+	// Scopes that the identity needs to have. Defaults to "admin".
+	Scopes []string
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	sourcer plugin.Sourcer
+
+	path          string
+	contents      Contents
+	filter        func(string) bool
+	name          string
+	authenticator auth.Authenticator
+	scopes        []string
+
+	mu   sync.RWMutex
+	fsys fs.FS
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Source() (fs.FS, error) {
+	p.assert.NotNil(p.sourcer)
+
+	fsys, err := p.sourcer.Source()
+	if err != nil {
+		return fsys, err
+	}
+
+	p.mu.Lock()
+	p.fsys = fsys
+	p.mu.Unlock()
+
+	return fsys, nil
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var format Format
+		switch r.URL.Path {
+		case p.path + ".zip":
+			format = Zip
+		case p.path + ".tar.gz":
+			format = TarGz
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("X-Robots-Tag", "noindex")
+		w.Header().Set("Cache-Control", "no-store")
+
+		auth.Require(p.authenticator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p.serve(next, format, w, r)
+		}), auth.RequireOpts{
+			Scopes:     p.scopes,
+			Assertions: p.assert,
+			Logger:     p.log,
+		}).ServeHTTP(w, r)
+	})
+}
+
+func (p *p) serve(next http.Handler, format Format, w http.ResponseWriter, r *http.Request) {
+	p.assert.NotNil(p.log)
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	contents := p.contents
+	if c := Contents(r.URL.Query().Get("contents")); c != "" {
+		contents = c
+	}
+	if contents != Sources && contents != Site {
+		http.Error(w, fmt.Sprintf("Unknown archive contents %q", contents), http.StatusBadRequest)
+		return
+	}
+
+	p.mu.RLock()
+	fsys := p.fsys
+	p.mu.RUnlock()
+
+	if fsys == nil {
+		var err error
+		if fsys, err = p.Source(); err != nil {
+			p.log.Error("Failed to source files", slog.String("err", err.Error()))
+			http.Error(w, "Failed to source files", http.StatusBadGateway)
+			return
+		}
+	}
+
+	now := time.Now()
+	filename := fmt.Sprintf("%s-%s-%s.%s", p.name, contents, now.Format(time.DateOnly), format)
+
+	w.Header().Set("Content-Type", format.MediaType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	log := p.log.With(slog.String("contents", string(contents)), slog.String("format", string(format)))
+	log.Info("Serving archive of blog")
+
+	opt := Opts{
+		Format:  format,
+		Filter:  p.filter,
+		ModTime: now,
+		Host:    r.Host,
+
+		Assertions: p.assert,
+		Logger:     log,
+	}
+
+	// The archive is streamed, so errors after the first write can only be
+	// logged, leaving a truncated archive.
+	var err error
+	if contents == Sources {
+		err = WriteSources(w, fsys, opt)
+	} else {
+		err = WriteSite(r.Context(), w, fsys, next, opt)
+	}
+	if err != nil {
+		log.Error("Failed to write archive", slog.String("err", err.Error()))
+	}
+}
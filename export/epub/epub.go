@@ -0,0 +1,405 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package epub exports a collection of files of a [fs.FS], such as a series, a tag
+// or the whole blog, as a EPUB 3 book, with cover, table of contents and metadata.
+//
+// Chapters are rendered using a [plugin.Renderer], so the book has the same contents
+// as the ones served by the blog.
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+// Options used by [Export].
+type Opts struct {
+	// Title of the book. Defaults to "Untitled".
+	Title string
+	// Author of the book.
+	Author string
+	// Language of the book, as a BCP 47 tag. Defaults to "en".
+	Language string
+	// Unique identifier of the book. Defaults to a URN derived from the title.
+	Identifier string
+	// Path of a image in the file system used as the cover.
+	Cover string
+
+	// Extensions of the files used as chapters. Defaults to ".md".
+	Extensions []string
+	// Filters which files are chapters of the book, such as the ones of a specific
+	// series or tag, see [TagFilter] and [SeriesFilter]. By default all files are used.
+	Filter func(path string, m metadata.Metadata) bool
+
+	// Modification date of the book. Defaults to the most recent chapter date.
+	Modified time.Time
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+// Filters files that have the tag on their "tags" metadata.
+func TagFilter(tag string) func(string, metadata.Metadata) bool {
+	return func(_ string, m metadata.Metadata) bool {
+		tags, err := metadata.GetTyped[[]any](m, "tags")
+		if err != nil {
+			return false
+		}
+		return slices.ContainsFunc(tags, func(t any) bool {
+			return fmt.Sprint(t) == tag
+		})
+	}
+}
+
+// Filters files that have the series on their "series" metadata.
+func SeriesFilter(series string) func(string, metadata.Metadata) bool {
+	return func(_ string, m metadata.Metadata) bool {
+		s, err := metadata.GetTyped[string](m, "series")
+		return err == nil && s == series
+	}
+}
+
+type chapter struct {
+	path  string
+	id    string
+	file  string
+	title string
+	date  time.Time
+	order int
+	body  []byte
+}
+
+// Exports the files of fsys as a EPUB book written to w. Chapters are ordered by
+// their "series-order" metadata and then by their "date" metadata.
+func Export(w io.Writer, fsys fs.FS, renderer plugin.Renderer, opts ...Opts) error {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Title == "" {
+		opt.Title = "Untitled"
+	}
+	if opt.Language == "" {
+		opt.Language = "en"
+	}
+	if opt.Identifier == "" {
+		opt.Identifier = fmt.Sprintf("urn:blogo:%x", sha256.Sum256([]byte(opt.Title)))
+	}
+	if opt.Extensions == nil {
+		opt.Extensions = []string{".md"}
+	}
+	if opt.Filter == nil {
+		opt.Filter = func(string, metadata.Metadata) bool { return true }
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(w)
+	opt.Assertions.NotNil(fsys)
+	opt.Assertions.NotNil(renderer)
+
+	chapters, err := collect(fsys, renderer, opt)
+	if err != nil {
+		return err
+	}
+	if len(chapters) == 0 {
+		return errors.New("no chapters found to export")
+	}
+
+	if opt.Modified.IsZero() {
+		for _, c := range chapters {
+			if c.date.After(opt.Modified) {
+				opt.Modified = c.date
+			}
+		}
+	}
+
+	var cover []byte
+	if opt.Cover != "" {
+		cover, err = fs.ReadFile(fsys, opt.Cover)
+		if err != nil {
+			return errors.Join(fmt.Errorf("failed to read cover %q", opt.Cover), err)
+		}
+	}
+
+	return write(w, chapters, cover, opt)
+}
+
+func collect(fsys fs.FS, renderer plugin.Renderer, opt Opts) ([]chapter, error) {
+	log := opt.Logger.With()
+	chapters := []chapter{}
+
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !slices.Contains(opt.Extensions, path.Ext(p)) {
+			return nil
+		}
+
+		f, err := fsys.Open(p)
+		if err != nil {
+			return errors.Join(fmt.Errorf("failed to open file %q", p), err)
+		}
+		defer f.Close()
+
+		m, err := metadata.GetMetadata(f)
+		if err != nil {
+			m = metadata.Map(map[string]any{})
+		}
+
+		if !opt.Filter(p, m) {
+			return nil
+		}
+
+		log.Debug("Rendering chapter", slog.String("file", p))
+
+		var buf bytes.Buffer
+		if err := renderer.Render(f, &buf); err != nil {
+			return errors.Join(fmt.Errorf("failed to render file %q", p), err)
+		}
+
+		c := chapter{path: p, body: xhtml(buf.Bytes())}
+
+		c.title, _ = metadata.GetTyped[string](m, "title")
+		if c.title == "" {
+			c.title = strings.TrimSuffix(path.Base(p), path.Ext(p))
+		}
+		c.date, _ = metadata.GetTime(m, "date")
+		c.order, _ = metadata.GetTyped[int](m, "series-order")
+
+		chapters = append(chapters, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(chapters, func(a, b chapter) int {
+		return cmp.Or(
+			cmp.Compare(a.order, b.order),
+			a.date.Compare(b.date),
+			cmp.Compare(a.path, b.path),
+		)
+	})
+
+	for i := range chapters {
+		chapters[i].id = fmt.Sprintf("chapter-%03d", i+1)
+		chapters[i].file = chapters[i].id + ".xhtml"
+	}
+
+	return chapters, nil
+}
+
+var voidElementPattern = regexp.MustCompile(
+	`<(area|base|br|col|embed|hr|img|input|link|meta|source|track|wbr)(\s[^>]*?)?\s*/?>`,
+)
+
+// Converts the HTML output of renderers to XHTML, closing void elements.
+func xhtml(src []byte) []byte {
+	return voidElementPattern.ReplaceAll(src, []byte("<$1$2 />"))
+}
+
+func write(w io.Writer, chapters []chapter, cover []byte, opt Opts) error {
+	z := zip.NewWriter(w)
+
+	// The mimetype file needs to be the first one and not compressed.
+	mw, err := z.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mw, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	files := map[string][]byte{
+		"META-INF/container.xml": []byte(containerXML),
+		"OEBPS/nav.xhtml":        nav(chapters, opt),
+		"OEBPS/toc.ncx":          ncx(chapters, opt),
+	}
+
+	coverFile := ""
+	if cover != nil {
+		coverFile = "cover" + path.Ext(opt.Cover)
+		files["OEBPS/"+coverFile] = cover
+		files["OEBPS/cover.xhtml"] = page(opt.Title, opt.Language, []byte(fmt.Sprintf(
+			`<section epub:type="cover"><img src="%s" alt="%s" /></section>`,
+			escape(coverFile), escape(opt.Title),
+		)))
+	}
+
+	for _, c := range chapters {
+		files["OEBPS/"+c.file] = page(c.title, opt.Language, c.body)
+	}
+
+	files["OEBPS/content.opf"] = opf(chapters, coverFile, opt)
+
+	names := make([]string, 0, len(files))
+	for n := range files {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+
+	for _, n := range names {
+		fw, err := z.CreateHeader(&zip.FileHeader{
+			Name:     n,
+			Method:   zip.Deflate,
+			Modified: opt.Modified,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(files[n]); err != nil {
+			return errors.Join(fmt.Errorf("failed to write %q to book", n), err)
+		}
+	}
+
+	return z.Close()
+}
+
+const containerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+func page(title, lang string, body []byte) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="%s" lang="%s">
+<head><meta charset="UTF-8" /><title>%s</title></head>
+<body>
+`, escape(lang), escape(lang), escape(title))
+	b.Write(body)
+	b.WriteString("\n</body>\n</html>\n")
+
+	return b.Bytes()
+}
+
+func nav(chapters []chapter, opt Opts) []byte {
+	var b bytes.Buffer
+
+	b.WriteString(`<nav epub:type="toc" id="toc"><h1>Contents</h1><ol>`)
+	for _, c := range chapters {
+		fmt.Fprintf(&b, `<li><a href="%s">%s</a></li>`, escape(c.file), escape(c.title))
+	}
+	b.WriteString(`</ol></nav>`)
+
+	return page(opt.Title, opt.Language, b.Bytes())
+}
+
+func ncx(chapters []chapter, opt Opts) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+<head><meta name="dtb:uid" content="%s"/></head>
+<docTitle><text>%s</text></docTitle>
+<navMap>
+`, escape(opt.Identifier), escape(opt.Title))
+
+	for i, c := range chapters {
+		fmt.Fprintf(&b,
+			`<navPoint id="%s" playOrder="%d"><navLabel><text>%s</text></navLabel><content src="%s"/></navPoint>
+`, escape(c.id), i+1, escape(c.title), escape(c.file))
+	}
+
+	b.WriteString("</navMap>\n</ncx>\n")
+
+	return b.Bytes()
+}
+
+func opf(chapters []chapter, coverFile string, opt Opts) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="book-id">%s</dc:identifier>
+<dc:title>%s</dc:title>
+<dc:language>%s</dc:language>
+<meta property="dcterms:modified">%s</meta>
+`, escape(opt.Identifier), escape(opt.Title), escape(opt.Language),
+		opt.Modified.UTC().Format("2006-01-02T15:04:05Z"))
+
+	if opt.Author != "" {
+		fmt.Fprintf(&b, "<dc:creator>%s</dc:creator>\n", escape(opt.Author))
+	}
+	if coverFile != "" {
+		b.WriteString(`<meta name="cover" content="cover-image"/>` + "\n")
+	}
+
+	b.WriteString("</metadata>\n<manifest>\n")
+	b.WriteString(`<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>` + "\n")
+	b.WriteString(`<item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>` + "\n")
+
+	if coverFile != "" {
+		fmt.Fprintf(&b, `<item id="cover-image" href="%s" media-type="%s" properties="cover-image"/>
+<item id="cover" href="cover.xhtml" media-type="application/xhtml+xml"/>
+`, escape(coverFile), escape(mime.TypeByExtension(path.Ext(coverFile))))
+	}
+
+	for _, c := range chapters {
+		fmt.Fprintf(&b, `<item id="%s" href="%s" media-type="application/xhtml+xml"/>
+`, escape(c.id), escape(c.file))
+	}
+
+	b.WriteString("</manifest>\n<spine toc=\"ncx\">\n")
+	if coverFile != "" {
+		b.WriteString(`<itemref idref="cover" linear="no"/>` + "\n")
+	}
+	b.WriteString(`<itemref idref="nav"/>` + "\n")
+	for _, c := range chapters {
+		fmt.Fprintf(&b, `<itemref idref="%s"/>`+"\n", escape(c.id))
+	}
+	b.WriteString("</spine>\n</package>\n")
+
+	return b.Bytes()
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
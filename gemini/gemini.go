@@ -0,0 +1,379 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gemini provides a Gemini (https://geminiprotocol.net) server, which
+// serves the same content as the HTTP server, converted to gemtext (see
+// [Gemtext]), from the files and metadata of a [index.Provider], such as the
+// indexer used by the blog.
+//
+// The root of the capsule lists the entries of the index, newest first, and
+// each entry is served at it's URL. Other files of the file system, such as
+// images, are served as is.
+package gemini
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/index"
+	"forge.capytal.company/loreddev/blogo/visibility"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+// Media type of gemtext documents.
+const MediaType = "text/gemini"
+
+// A Gemini server.
+type Server interface {
+	// Serves connections accepted on the listener, which should be a TLS one,
+	// until it fails or the server is shut down.
+	Serve(l net.Listener) error
+	// Listens on the TCP address, such as ":1965", serving TLS connections with
+	// the certificate and key, which can be self-signed, as most Gemini clients
+	// trust certificates on first use.
+	ListenAndServeTLS(addr, certFile, keyFile string) error
+	// Closes the listeners, waiting for the active connections to finish until
+	// the context is done.
+	Shutdown(ctx context.Context) error
+}
+
+// Creates a [Server] of the files of the index of the provider.
+func New(provider index.Provider, opts ...Opts) Server {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Visibility == nil {
+		opt.Visibility = visibility.Default
+	}
+	if opt.Extensions == nil {
+		opt.Extensions = []string{".md"}
+	}
+	if opt.Timeout == 0 {
+		opt.Timeout = 30 * time.Second
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(provider, "Index provider should not be nil")
+
+	return &server{
+		provider: provider,
+
+		title:      opt.Title,
+		visibility: opt.Visibility,
+		extensions: opt.Extensions,
+		timeout:    opt.Timeout,
+
+		listeners: map[net.Listener]struct{}{},
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Title of the root of the capsule.
+	Title string
+	// Rules used to hide entries from the root of the capsule. Defaults to
+	// [visibility.Default].
+	Visibility visibility.Rules
+	// Extensions of the markdown files converted to gemtext. Defaults to ".md".
+	Extensions []string
+	// Max duration of each connection. Defaults to 30 seconds.
+	Timeout time.Duration
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+// Returned by [Server] methods after it's shut down.
+var ErrServerClosed = errors.New("gemini: server closed")
+
+type server struct {
+	provider index.Provider
+
+	title      string
+	visibility visibility.Rules
+	extensions []string
+	timeout    time.Duration
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	closed    bool
+	conns     sync.WaitGroup
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (s *server) ListenAndServeTLS(addr, certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return errors.Join(errors.New("failed to load certificate"), err)
+	}
+
+	l, err := tls.Listen("tcp", addr, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	})
+	if err != nil {
+		return errors.Join(errors.New("failed to listen"), err)
+	}
+
+	return s.Serve(l)
+}
+
+func (s *server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+	}()
+
+	s.log.Info("Serving Gemini", slog.String("addr", l.Addr().String()))
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		s.conns.Add(1)
+		go func() {
+			defer s.conns.Done()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+func (s *server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		_ = l.Close()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.conns.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// A response, with the status and meta of it's header.
+type response struct {
+	status int
+	meta   string
+	body   []byte
+}
+
+func (s *server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(s.timeout))
+
+	// Requests are a URL of at most 1024 bytes, followed by CRLF.
+	line, err := bufio.NewReaderSize(io.LimitReader(conn, 1026), 1026).ReadString('\n')
+	if err != nil {
+		s.write(conn, response{status: 59, meta: "Bad request"})
+		return
+	}
+
+	res := s.handle(strings.TrimRight(line, "\r\n"))
+
+	s.log.Debug("Served Gemini request",
+		slog.String("url", strings.TrimSpace(line)), slog.Int("status", res.status))
+
+	s.write(conn, res)
+}
+
+func (s *server) write(w io.Writer, res response) {
+	if _, err := fmt.Fprintf(w, "%d %s\r\n", res.status, res.meta); err != nil {
+		return
+	}
+	if res.status >= 20 && res.status <= 29 {
+		_, _ = w.Write(res.body)
+	}
+}
+
+func (s *server) handle(raw string) response {
+	s.assert.NotNil(s.provider)
+	s.assert.NotNil(s.log)
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "gemini" || u.User != nil {
+		return response{status: 59, meta: "Bad request"}
+	}
+
+	idx, err := s.provider.Index()
+	if err != nil {
+		s.log.Error("Failed to get index", slog.String("err", err.Error()))
+		return response{status: 40, meta: "Temporary failure"}
+	}
+
+	name := strings.Trim(path.Clean("/"+u.Path), "/")
+	if name == "" {
+		return s.list(idx, "", s.title)
+	}
+
+	if e, ok := idx.Lookup("/" + name); ok {
+		return s.entry(idx, e)
+	}
+
+	f, err := idx.FS().Open(name)
+	if err != nil {
+		return response{status: 51, meta: "Not found"}
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.IsDir() {
+		return s.list(idx, name, "")
+	}
+
+	body, err := io.ReadAll(f)
+	if err != nil {
+		s.log.Error("Failed to read file", slog.String("file", name), slog.String("err", err.Error()))
+		return response{status: 40, meta: "Temporary failure"}
+	}
+
+	return response{status: 20, meta: mediaType(name), body: body}
+}
+
+// Lists the entries of the directory, or all entries if dir is empty.
+func (s *server) list(idx index.Index, dir, title string) response {
+	var b strings.Builder
+
+	if title == "" && dir != "" {
+		title = path.Base(dir)
+	}
+	if title != "" {
+		b.WriteString("# " + title + "\n\n")
+	}
+
+	found := false
+	for _, e := range idx.Entries() {
+		if dir != "" && !strings.HasPrefix(e.Path, dir+"/") {
+			continue
+		}
+		if !s.visibility.Visible(e.Path, e.Metadata, visibility.Listing) {
+			continue
+		}
+		found = true
+
+		b.WriteString("=> " + e.URL + " ")
+		if !e.Date.IsZero() {
+			b.WriteString(e.Date.Format(time.DateOnly) + " ")
+		}
+		b.WriteString(entryTitle(e) + "\n")
+	}
+
+	if !found && dir != "" {
+		return response{status: 51, meta: "Not found"}
+	}
+
+	return response{status: 20, meta: MediaType + "; charset=utf-8", body: []byte(b.String())}
+}
+
+func (s *server) entry(idx index.Index, e index.Entry) response {
+	src, err := fs.ReadFile(idx.FS(), e.Path)
+	if err != nil {
+		s.log.Error("Failed to read file", slog.String("file", e.Path), slog.String("err", err.Error()))
+		return response{status: 40, meta: "Temporary failure"}
+	}
+
+	if !slices.Contains(s.extensions, path.Ext(e.Path)) {
+		return response{status: 20, meta: mediaType(e.Path), body: src}
+	}
+
+	var b strings.Builder
+
+	body := Gemtext(src)
+	if !strings.HasPrefix(string(body), "# ") {
+		b.WriteString("# " + entryTitle(e) + "\n\n")
+	}
+	if !e.Date.IsZero() {
+		b.WriteString(e.Date.Format(time.DateOnly) + "\n\n")
+	}
+	b.Write(body)
+	b.WriteString("\n\n=> / Home\n")
+
+	return response{status: 20, meta: MediaType + "; charset=utf-8", body: []byte(b.String())}
+}
+
+func entryTitle(e index.Entry) string {
+	if e.Title != "" {
+		return e.Title
+	}
+	return path.Base(e.URL)
+}
+
+func mediaType(name string) string {
+	if path.Ext(name) == ".gmi" {
+		return MediaType + "; charset=utf-8"
+	}
+	if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,185 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gemini
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+
+	"forge.capytal.company/loreddev/blogo/plugins/frontmatter"
+)
+
+// Converts the markdown body, with or without frontmatter, to gemtext
+// (https://geminiprotocol.net/docs/gemtext.gmi). Since gemtext only has links
+// on their own lines, the links of each paragraph, list and quote are listed
+// after it. Raw HTML is dropped.
+func Gemtext(markdown []byte) []byte {
+	src := frontmatter.Body(markdown)
+	doc := goldmark.DefaultParser().Parse(text.NewReader(src))
+
+	g := &gemtext{src: src}
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		g.block(n, "")
+	}
+
+	return bytes.TrimSpace(g.buf.Bytes())
+}
+
+type gemtext struct {
+	src   []byte
+	buf   bytes.Buffer
+	links []link
+}
+
+type link struct {
+	url   string
+	label string
+}
+
+func (g *gemtext) block(n ast.Node, quote string) {
+	switch n := n.(type) {
+	case *ast.Heading:
+		g.line(quote + strings.Repeat("#", min(n.Level, 3)) + " " + g.inline(n))
+		g.flushLinks()
+	case *ast.Paragraph, *ast.TextBlock:
+		// Paragraphs of only a image are written as the link to it.
+		if img, ok := n.FirstChild().(*ast.Image); ok && n.FirstChild() == n.LastChild() {
+			g.line("=> " + string(img.Destination) + " " + g.text(img))
+			return
+		}
+		g.line(quote + g.inline(n))
+		if quote == "" {
+			g.flushLinks()
+		}
+	case *ast.List:
+		for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+			g.listItem(item, quote)
+		}
+		g.buf.WriteString("\n")
+		if quote == "" {
+			g.flushLinks()
+		}
+	case *ast.Blockquote:
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			g.block(c, "> ")
+		}
+		g.flushLinks()
+	case *ast.FencedCodeBlock:
+		g.buf.WriteString("```" + string(n.Language(g.src)) + "\n")
+		g.codeLines(n)
+		g.line("```")
+	case *ast.CodeBlock:
+		g.buf.WriteString("```\n")
+		g.codeLines(n)
+		g.line("```")
+	case *ast.ThematicBreak:
+		g.line("-----")
+	}
+}
+
+func (g *gemtext) listItem(item ast.Node, quote string) {
+	texts := []string{}
+	for c := item.FirstChild(); c != nil; c = c.NextSibling() {
+		if l, ok := c.(*ast.List); ok {
+			// Gemtext lists can't be nested, so nested items are flattened.
+			if t := strings.Join(texts, " "); t != "" {
+				g.buf.WriteString(quote + "* " + t + "\n")
+			}
+			texts = []string{}
+			for i := l.FirstChild(); i != nil; i = i.NextSibling() {
+				g.listItem(i, quote)
+			}
+			continue
+		}
+		texts = append(texts, g.inline(c))
+	}
+	if t := strings.Join(texts, " "); t != "" {
+		g.buf.WriteString(quote + "* " + t + "\n")
+	}
+}
+
+func (g *gemtext) codeLines(n ast.Node) {
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		g.buf.Write(line.Value(g.src))
+	}
+}
+
+// Writes the line followed by a blank line.
+func (g *gemtext) line(s string) {
+	g.buf.WriteString(s + "\n\n")
+}
+
+func (g *gemtext) flushLinks() {
+	if len(g.links) == 0 {
+		return
+	}
+	for _, l := range g.links {
+		g.buf.WriteString("=> " + l.url)
+		if l.label != "" && l.label != l.url {
+			g.buf.WriteString(" " + l.label)
+		}
+		g.buf.WriteString("\n")
+	}
+	g.buf.WriteString("\n")
+	g.links = nil
+}
+
+// Returns the text of the inline children of the node, in a single line,
+// recording their links.
+func (g *gemtext) inline(n ast.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch c := c.(type) {
+		case *ast.Text:
+			b.Write(c.Segment.Value(g.src))
+			if c.SoftLineBreak() || c.HardLineBreak() {
+				b.WriteString(" ")
+			}
+		case *ast.String:
+			b.Write(c.Value)
+		case *ast.Link:
+			label := g.inline(c)
+			b.WriteString(label)
+			g.links = append(g.links, link{url: string(c.Destination), label: label})
+		case *ast.AutoLink:
+			u := string(c.URL(g.src))
+			b.WriteString(u)
+			g.links = append(g.links, link{url: u})
+		case *ast.Image:
+			alt := g.text(c)
+			b.WriteString(alt)
+			g.links = append(g.links, link{url: string(c.Destination), label: alt})
+		case *ast.RawHTML:
+		default:
+			b.WriteString(g.inline(c))
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// Returns the plain text of the node, without recording links.
+func (g *gemtext) text(n ast.Node) string {
+	links := g.links
+	s := g.inline(n)
+	g.links = links
+	return s
+}
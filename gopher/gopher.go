@@ -0,0 +1,421 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gopher provides a Gopher (RFC 1436) server, which serves the posts of
+// the blog as plain text (see [Text]), from the files and metadata of a
+// [index.Provider], such as the indexer used by the blog.
+//
+// The root selector is a generated gophermap, listing the sections of the blog
+// and it's entries, newest first. Each entry is served at it's URL, and other
+// files of the file system, such as images, are served as is.
+package gopher
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/index"
+	"forge.capytal.company/loreddev/blogo/plugins/terminal"
+	"forge.capytal.company/loreddev/blogo/visibility"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+// A Gopher server.
+type Server interface {
+	// Serves connections accepted on the listener, until it fails or the server
+	// is shut down.
+	Serve(l net.Listener) error
+	// Listens on the TCP address, such as ":70", serving connections.
+	ListenAndServe(addr string) error
+	// Closes the listeners, waiting for the active connections to finish until
+	// the context is done.
+	Shutdown(ctx context.Context) error
+}
+
+// Creates a [Server] of the files of the index of the provider.
+func New(provider index.Provider, opts ...Opts) Server {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Host == "" {
+		opt.Host = "localhost"
+	}
+	if opt.Port == 0 {
+		opt.Port = 70
+	}
+	if opt.Visibility == nil {
+		opt.Visibility = visibility.Default
+	}
+	if opt.Extensions == nil {
+		opt.Extensions = []string{".md"}
+	}
+	if opt.Width == 0 {
+		opt.Width = 70
+	}
+	if opt.Timeout == 0 {
+		opt.Timeout = 30 * time.Second
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(provider, "Index provider should not be nil")
+
+	return &server{
+		provider: provider,
+
+		host:       opt.Host,
+		port:       strconv.Itoa(opt.Port),
+		title:      opt.Title,
+		visibility: opt.Visibility,
+		extensions: opt.Extensions,
+		width:      opt.Width,
+		timeout:    opt.Timeout,
+
+		listeners: map[net.Listener]struct{}{},
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Host name and port of the server, used on the items of gophermaps, which
+	// need to be reachable by clients. Defaults to "localhost" and 70.
+	Host string
+	Port int
+
+	// Title of the root gophermap.
+	Title string
+	// Rules used to hide entries from the gophermaps. Defaults to
+	// [visibility.Default].
+	Visibility visibility.Rules
+	// Extensions of the markdown files converted to plain text. Defaults to ".md".
+	Extensions []string
+	// Max width of the lines of plain text. Defaults to 70.
+	Width int
+	// Max duration of each connection. Defaults to 30 seconds.
+	Timeout time.Duration
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+// Returned by [Server] methods after it's shut down.
+var ErrServerClosed = errors.New("gopher: server closed")
+
+type server struct {
+	provider index.Provider
+
+	host       string
+	port       string
+	title      string
+	visibility visibility.Rules
+	extensions []string
+	width      int
+	timeout    time.Duration
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	closed    bool
+	conns     sync.WaitGroup
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (s *server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Join(errors.New("failed to listen"), err)
+	}
+	return s.Serve(l)
+}
+
+func (s *server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+	}()
+
+	s.log.Info("Serving Gopher", slog.String("addr", l.Addr().String()))
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		s.conns.Add(1)
+		go func() {
+			defer s.conns.Done()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+func (s *server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		_ = l.Close()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.conns.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(s.timeout))
+
+	line, err := bufio.NewReaderSize(io.LimitReader(conn, 1026), 1026).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+
+	// Search requests have the query after a tab, which isn't supported.
+	selector, _, _ := strings.Cut(strings.TrimRight(line, "\r\n"), "\t")
+
+	s.log.Debug("Serving Gopher request", slog.String("selector", selector))
+
+	if _, err := conn.Write(s.handle(selector)); err != nil {
+		s.log.Debug("Failed to write response", slog.String("err", err.Error()))
+	}
+}
+
+func (s *server) handle(selector string) []byte {
+	s.assert.NotNil(s.provider)
+	s.assert.NotNil(s.log)
+
+	idx, err := s.provider.Index()
+	if err != nil {
+		s.log.Error("Failed to get index", slog.String("err", err.Error()))
+		return s.errorMenu("Temporary failure")
+	}
+
+	name := strings.Trim(path.Clean("/"+selector), "/")
+	if name == "" {
+		return s.menu(idx, "", s.title)
+	}
+
+	if e, ok := idx.Lookup("/" + name); ok {
+		return s.entry(idx, e)
+	}
+
+	f, err := idx.FS().Open(name)
+	if err != nil {
+		return s.errorMenu("Not found")
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.IsDir() {
+		return s.menu(idx, name, "")
+	}
+
+	body, err := io.ReadAll(f)
+	if err != nil {
+		s.log.Error("Failed to read file", slog.String("file", name), slog.String("err", err.Error()))
+		return s.errorMenu("Temporary failure")
+	}
+
+	return body
+}
+
+// Returns the gophermap of the entries of the directory, or all entries and the
+// sections of the blog if dir is empty.
+func (s *server) menu(idx index.Index, dir, title string) []byte {
+	var b strings.Builder
+
+	if title == "" && dir != "" {
+		title = path.Base(dir)
+	}
+	if title != "" {
+		s.item(&b, 'i', title, "")
+		s.item(&b, 'i', "", "")
+	}
+
+	entries := []index.Entry{}
+	for _, e := range idx.Entries() {
+		if dir != "" && !strings.HasPrefix(e.Path, dir+"/") {
+			continue
+		}
+		if s.visibility.Visible(e.Path, e.Metadata, visibility.Listing) {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == 0 && dir != "" {
+		return s.errorMenu("Not found")
+	}
+
+	if dir == "" {
+		sections := []string{}
+		for _, e := range entries {
+			if section, _, ok := strings.Cut(e.Path, "/"); ok && !slices.Contains(sections, section) {
+				sections = append(sections, section)
+			}
+		}
+		slices.Sort(sections)
+
+		for _, section := range sections {
+			s.item(&b, '1', section+"/", "/"+section)
+		}
+		if len(sections) > 0 {
+			s.item(&b, 'i', "", "")
+		}
+	}
+
+	for _, e := range entries {
+		display := entryTitle(e)
+		if !e.Date.IsZero() {
+			display = e.Date.Format(time.DateOnly) + " " + display
+		}
+		s.item(&b, itemType(e.Path, s.extensions), display, e.URL)
+	}
+
+	b.WriteString(".\r\n")
+	return []byte(b.String())
+}
+
+func (s *server) entry(idx index.Index, e index.Entry) []byte {
+	src, err := fs.ReadFile(idx.FS(), e.Path)
+	if err != nil {
+		s.log.Error("Failed to read file", slog.String("file", e.Path), slog.String("err", err.Error()))
+		return s.errorMenu("Temporary failure")
+	}
+
+	if !slices.Contains(s.extensions, path.Ext(e.Path)) {
+		return src
+	}
+
+	var b strings.Builder
+
+	title := entryTitle(e)
+	b.WriteString(title + "\n" + strings.Repeat("=", len([]rune(title))) + "\n\n")
+	if !e.Date.IsZero() {
+		b.WriteString(e.Date.Format(time.DateOnly) + "\n\n")
+	}
+	b.Write(Text(src, s.width))
+	b.WriteString("\n")
+
+	// Text files end on a line with a single dot, so lines starting with one
+	// are escaped with another.
+	var out strings.Builder
+	for _, line := range strings.Split(b.String(), "\n") {
+		if strings.HasPrefix(line, ".") {
+			line = "." + line
+		}
+		out.WriteString(line + "\r\n")
+	}
+	out.WriteString(".\r\n")
+
+	return []byte(out.String())
+}
+
+func (s *server) item(b *strings.Builder, t byte, display, selector string) {
+	host, port := s.host, s.port
+	if t == 'i' {
+		selector, host, port = "", "error.host", "1"
+	}
+	display = strings.NewReplacer("\t", " ", "\r", "", "\n", " ").Replace(display)
+	b.WriteString(string(t) + display + "\t" + selector + "\t" + host + "\t" + port + "\r\n")
+}
+
+func (s *server) errorMenu(msg string) []byte {
+	var b strings.Builder
+	b.WriteString("3" + msg + "\t\terror.host\t1\r\n")
+	b.WriteString(".\r\n")
+	return []byte(b.String())
+}
+
+// Converts the markdown body, with or without frontmatter, to plain text with
+// lines of at most width characters, as in [terminal.Text].
+func Text(markdown []byte, width int) []byte {
+	return terminal.Text(markdown, terminal.TextOpts{Width: width})
+}
+
+func entryTitle(e index.Entry) string {
+	if e.Title != "" {
+		return e.Title
+	}
+	return path.Base(e.URL)
+}
+
+// Returns the item type of the file at the path.
+func itemType(name string, extensions []string) byte {
+	ext := strings.ToLower(path.Ext(name))
+	switch {
+	case slices.Contains(extensions, ext), ext == ".txt":
+		return '0'
+	case ext == ".gif":
+		return 'g'
+	case ext == ".png", ext == ".jpg", ext == ".jpeg", ext == ".webp":
+		return 'I'
+	case ext == ".html", ext == ".htm":
+		return 'h'
+	default:
+		return '9'
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// Kind of a [DiffOp].
+type DiffKind int
+
+const (
+	DiffEqual DiffKind = iota
+	DiffInsert
+	DiffDelete
+)
+
+// A run of text of a word-level diff.
+type DiffOp struct {
+	Kind DiffKind
+	Text string
+}
+
+var diffToken = regexp.MustCompile(`\s+|[\p{L}\p{N}_]+|.`)
+
+// Maximum edit distance computed by [DiffWords], texts that differ more than it
+// are diffed as a full replacement.
+const maxDiffDistance = 4096
+
+// Computes the word-level diff between the old and new texts, splitting them in
+// words, whitespace and punctuation, using the Myers' algorithm. Adjacent runs
+// of the same kind are merged.
+func DiffWords(old, new string) []DiffOp {
+	a := diffToken.FindAllString(old, -1)
+	b := diffToken.FindAllString(new, -1)
+
+	ops := []DiffOp{}
+	add := func(k DiffKind, t string) {
+		if n := len(ops); n > 0 && ops[n-1].Kind == k {
+			ops[n-1].Text += t
+			return
+		}
+		ops = append(ops, DiffOp{Kind: k, Text: t})
+	}
+
+	// Common prefix and suffix are trimmed, since most revisions only change a
+	// small part of the text.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	if prefix > 0 {
+		add(DiffEqual, strings.Join(a[:prefix], ""))
+	}
+	for _, op := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		add(op.Kind, op.Text)
+	}
+	if suffix > 0 {
+		add(DiffEqual, strings.Join(a[len(a)-suffix:], ""))
+	}
+
+	return ops
+}
+
+func myers(a, b []string) []DiffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	if max > maxDiffDistance {
+		max = maxDiffDistance
+	}
+
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	trace := [][]int{}
+
+	found := false
+	for d := 0; d <= max && !found; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	if !found {
+		ops := []DiffOp{}
+		if n > 0 {
+			ops = append(ops, DiffOp{Kind: DiffDelete, Text: strings.Join(a, "")})
+		}
+		if m > 0 {
+			ops = append(ops, DiffOp{Kind: DiffInsert, Text: strings.Join(b, "")})
+		}
+		return ops
+	}
+
+	// Backtracks the trace from the end, collecting the operations in reverse.
+	ops := []DiffOp{}
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, DiffOp{Kind: DiffEqual, Text: a[x-1]})
+			x, y = x-1, y-1
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, DiffOp{Kind: DiffInsert, Text: b[y-1]})
+			} else {
+				ops = append(ops, DiffOp{Kind: DiffDelete, Text: a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+
+	return ops
+}
+
+// Renders the diff as HTML, with insertions inside "<ins>" and deletions inside
+// "<del>" elements. The text is escaped.
+func DiffHTML(ops []DiffOp) template.HTML {
+	var b strings.Builder
+	for _, op := range ops {
+		text := template.HTMLEscapeString(op.Text)
+		switch op.Kind {
+		case DiffInsert:
+			b.WriteString("<ins>" + text + "</ins>")
+		case DiffDelete:
+			b.WriteString("<del>" + text + "</del>")
+		default:
+			b.WriteString(text)
+		}
+	}
+	return template.HTML(b.String())
+}
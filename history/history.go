@@ -0,0 +1,526 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package history provides the revision history of the content of the blog, for
+// git-backed sourcers that implement [History], such as the gitea plugin.
+//
+// Use [New] to wrap the sourcer, so files have their last commit, edit URL and
+// URL to open a issue about them on their metadata (see [EditURLKey],
+// [IssueURLKey], [UpdatedKey], [AuthorKey] and [CommitKey]) and templates can
+// show the provenance of posts and ask for suggestions without extra
+// configuration.
+package history
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-history-sourcer"
+
+const (
+	// Metadata key of the URL to edit the file on the forge of the repository.
+	EditURLKey = "git.edit-url"
+	// Metadata key of the URL to open a issue about the file on the forge of the
+	// repository.
+	IssueURLKey = "git.issue-url"
+	// Metadata key of the [time.Time] of the last commit of the file.
+	UpdatedKey = "git.updated"
+	// Metadata key of the [Author] of the last commit of the file.
+	AuthorKey = "git.author"
+	// Metadata key of the last [Commit] of the file.
+	CommitKey = "git.commit"
+)
+
+// The author of a [Commit].
+type Author struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// How a file was changed by a [Commit].
+type Change string
+
+const (
+	Added    Change = "added"
+	Modified Change = "modified"
+	Removed  Change = "removed"
+	Renamed  Change = "renamed"
+)
+
+// A file changed by a [Commit].
+type File struct {
+	Path   string `json:"path"`
+	Change Change `json:"change"`
+}
+
+// A commit of the content repository.
+type Commit struct {
+	SHA     string    `json:"sha"`
+	Message string    `json:"message"`
+	Author  Author    `json:"author"`
+	Date    time.Time `json:"date"`
+	// URL of the commit on the forge of the repository, if any.
+	URL string `json:"url,omitempty"`
+
+	// Files changed by the commit, may be empty if the implementation doesn't
+	// provide them.
+	Files []File `json:"files"`
+	// Number of lines added and deleted by the commit.
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+
+	// Verification of the signature of the commit, nil if the implementation
+	// doesn't verify commits.
+	Verification *Verification `json:"verification,omitempty"`
+}
+
+// The verification of the signature of a [Commit], as reported by the forge.
+type Verification struct {
+	Verified bool `json:"verified"`
+	// Reason of the verification result, such as "unsigned" or the key that
+	// signed the commit.
+	Reason string `json:"reason"`
+	Signer Author `json:"signer"`
+}
+
+// Returns the first line of the commit message.
+func (c Commit) Subject() string {
+	s, _, _ := strings.Cut(c.Message, "\n")
+	return strings.TrimSpace(s)
+}
+
+// Returns the abbreviated SHA of the commit.
+func (c Commit) Short() string {
+	if len(c.SHA) > 7 {
+		return c.SHA[:7]
+	}
+	return c.SHA
+}
+
+// Revision history of a file system, implemented by git-backed sourcers.
+type History interface {
+	// Returns the commits that changed the file at the path, or all commits if
+	// the path is empty or ".", from newest to oldest. Limit is the maximum number
+	// of commits returned, zero or negative values use the default of the
+	// implementation.
+	Commits(ctx context.Context, path string, limit int) ([]Commit, error)
+}
+
+// Sourcers that can create URLs to edit files on the forge of their repository.
+type EditLinker interface {
+	EditURL(path string) string
+}
+
+// Type adapter to allow the use of ordinary functions as [EditLinker] implementations.
+type EditURLFunc func(path string) string
+
+func (f EditURLFunc) EditURL(path string) string {
+	return f(path)
+}
+
+// Creates a [EditLinker] of the GitHub web editor of the repository.
+func GitHubEditURL(owner, repo, branch string) EditLinker {
+	return ForgeEditURL("https://github.com/"+owner+"/"+repo+"/edit/"+branch, "")
+}
+
+// Creates a [EditLinker] of the web editor of a Forgejo or Gitea repository,
+// with the URL of the instance, such as "https://codeberg.org".
+func ForgejoEditURL(baseURL, owner, repo, branch string) EditLinker {
+	return ForgeEditURL(strings.TrimSuffix(baseURL, "/")+"/"+owner+"/"+repo+"/_edit/"+branch, "")
+}
+
+// Creates a [EditLinker] that joins the URL prefix with the path of the file,
+// and the suffix, for forges that don't have a specific constructor.
+func ForgeEditURL(prefix, suffix string) EditLinker {
+	return EditURLFunc(func(p string) string {
+		segments := strings.Split(strings.Trim(p, "/"), "/")
+		for i, s := range segments {
+			segments[i] = url.PathEscape(s)
+		}
+		return strings.TrimSuffix(prefix, "/") + "/" + strings.Join(segments, "/") + suffix
+	})
+}
+
+// Sourcers that can create URLs to open issues about files on the forge of their
+// repository, with the path of the file prefilled.
+type IssueLinker interface {
+	IssueURL(path string) string
+}
+
+// Type adapter to allow the use of ordinary functions as [IssueLinker] implementations.
+type IssueURLFunc func(path string) string
+
+func (f IssueURLFunc) IssueURL(path string) string {
+	return f(path)
+}
+
+// Creates a [IssueLinker] of the new issue form of the GitHub repository.
+func GitHubIssueURL(owner, repo string) IssueLinker {
+	return ForgeIssueURL("https://github.com/" + owner + "/" + repo + "/issues/new")
+}
+
+// Creates a [IssueLinker] of the new issue form of a Forgejo or Gitea
+// repository, with the URL of the instance, such as "https://codeberg.org".
+func ForgejoIssueURL(baseURL, owner, repo string) IssueLinker {
+	return ForgeIssueURL(strings.TrimSuffix(baseURL, "/") + "/" + owner + "/" + repo + "/issues/new")
+}
+
+// Creates a [IssueLinker] that prefills the "title" and "body" query parameters
+// of the URL of the new issue form with the path of the file, which GitHub,
+// Forgejo and Gitea support.
+func ForgeIssueURL(newIssueURL string) IssueLinker {
+	return IssueURLFunc(func(p string) string {
+		p = strings.Trim(p, "/")
+
+		q := url.Values{}
+		q.Set("title", "About "+p)
+		q.Set("body", "File: `"+p+"`\n\n")
+
+		sep := "?"
+		if strings.Contains(newIssueURL, "?") {
+			sep = "&"
+		}
+		return newIssueURL + sep + q.Encode()
+	})
+}
+
+// The history plugin, which wraps a [plugin.Sourcer] adding the history of files
+// to their metadata.
+type Plugin interface {
+	plugin.Sourcer
+	// Returns the template functions:
+	//
+	//   - "editURL PATH" returns the URL to edit the file, or a empty string;
+	//   - "issueURL PATH" returns the URL to open a issue about the file, or a
+	//     empty string;
+	//   - "lastCommit PATH" returns the last [Commit] of the file, or nil;
+	//   - "commits PATH [N]" returns the last N commits of the file.
+	FuncMap() template.FuncMap
+}
+
+// Creates the history [Plugin], wrapping the sourcer. Opts.History, Opts.EditURL
+// and Opts.IssueURL default to the sourcer, if it implements [History],
+// [EditLinker] and [IssueLinker] respectively.
+//
+// The history of files is only requested when their metadata keys are first
+// accessed, and is cached until the next call to Source, so files that don't
+// show their history don't make requests to the repository.
+func New(sourcer plugin.Sourcer, opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.History == nil {
+		if h, ok := sourcer.(History); ok {
+			opt.History = h
+		}
+	}
+	if opt.EditURL == nil {
+		if e, ok := sourcer.(EditLinker); ok {
+			opt.EditURL = e
+		}
+	}
+	if opt.IssueURL == nil {
+		if i, ok := sourcer.(IssueLinker); ok {
+			opt.IssueURL = i
+		}
+	}
+	if opt.Extensions == nil {
+		opt.Extensions = []string{".md"}
+	}
+	if opt.Timeout == 0 {
+		opt.Timeout = 10 * time.Second
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be wrapped should not be nil")
+
+	return &p{
+		sourcer:    sourcer,
+		history:    opt.History,
+		editURL:    opt.EditURL,
+		issueURL:   opt.IssueURL,
+		extensions: opt.Extensions,
+		timeout:    opt.Timeout,
+
+		commits: map[string]*Commit{},
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// History of the files. Defaults to the sourcer, if it implements [History].
+	History History
+	// Edit URLs of the files. Defaults to the sourcer, if it implements [EditLinker].
+	EditURL EditLinker
+	// URLs to open issues about the files. Defaults to the sourcer, if it
+	// implements [IssueLinker].
+	IssueURL IssueLinker
+	// Extensions of the files that have their history on their metadata.
+	// Defaults to ".md".
+	Extensions []string
+	// Timeout of the requests of the history of a file. Defaults to 10 seconds.
+	Timeout time.Duration
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	sourcer    plugin.Sourcer
+	history    History
+	editURL    EditLinker
+	issueURL   IssueLinker
+	extensions []string
+	timeout    time.Duration
+
+	mu      sync.Mutex
+	commits map[string]*Commit
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Source() (fs.FS, error) {
+	p.assert.NotNil(p.sourcer)
+	p.assert.NotNil(p.log)
+
+	fsys, err := p.sourcer.Source()
+	if err != nil {
+		return fsys, err
+	}
+
+	p.mu.Lock()
+	p.commits = map[string]*Commit{}
+	p.mu.Unlock()
+
+	return &historyFS{FS: fsys, p: p}, nil
+}
+
+func (p *p) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"editURL":  p.edit,
+		"issueURL": p.issue,
+		"lastCommit": func(name string) *Commit {
+			return p.lastCommit(name)
+		},
+		"commits": func(name string, n ...int) []Commit {
+			limit := 0
+			if len(n) > 0 {
+				limit = n[0]
+			}
+			cs, err := p.commitsOf(name, limit)
+			if err != nil {
+				p.log.Warn("Failed to get commits of file",
+					slog.String("file", name), slog.String("err", err.Error()))
+				return []Commit{}
+			}
+			return cs
+		},
+	}
+}
+
+func (p *p) edit(name string) string {
+	if p.editURL == nil {
+		return ""
+	}
+	return p.editURL.EditURL(strings.TrimPrefix(name, "/"))
+}
+
+func (p *p) issue(name string) string {
+	if p.issueURL == nil {
+		return ""
+	}
+	return p.issueURL.IssueURL(strings.TrimPrefix(name, "/"))
+}
+
+// Returns the last commit of the file, cached until the next call to Source.
+// Returns nil if there isn't any history or it failed to be requested.
+func (p *p) lastCommit(name string) *Commit {
+	name = strings.TrimPrefix(name, "/")
+
+	p.mu.Lock()
+	c, ok := p.commits[name]
+	p.mu.Unlock()
+	if ok {
+		return c
+	}
+
+	cs, err := p.commitsOf(name, 1)
+	if err != nil {
+		p.log.Warn("Failed to get last commit of file",
+			slog.String("file", name), slog.String("err", err.Error()))
+	}
+	if len(cs) > 0 {
+		c = &cs[0]
+	}
+
+	p.mu.Lock()
+	p.commits[name] = c
+	p.mu.Unlock()
+
+	return c
+}
+
+func (p *p) commitsOf(name string, limit int) ([]Commit, error) {
+	if p.history == nil {
+		return []Commit{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	cs, err := p.history.Commits(ctx, strings.TrimPrefix(name, "/"), limit)
+	if err != nil {
+		return []Commit{}, errors.Join(errors.New("failed to get commits"), err)
+	}
+	return cs, nil
+}
+
+type historyFS struct {
+	fs.FS
+	p *p
+}
+
+func (fsys *historyFS) Metadata() metadata.Metadata {
+	if m, err := metadata.GetMetadata(fsys.FS); err == nil {
+		return m
+	}
+	return metadata.Map(map[string]any{})
+}
+
+func (fsys *historyFS) Open(name string) (fs.File, error) {
+	f, err := fsys.FS.Open(name)
+	if err != nil || !slices.Contains(fsys.p.extensions, path.Ext(name)) {
+		return f, err
+	}
+
+	var m metadata.Metadata = &historyMetadata{p: fsys.p, path: name}
+	if fm, err := metadata.GetMetadata(f); err == nil {
+		m = metadata.Join(fm, m)
+	}
+
+	if _, ok := f.(io.Seeker); ok {
+		return &seekerFile{file{File: f, metadata: m}}, nil
+	}
+	return &file{File: f, metadata: m}, nil
+}
+
+type file struct {
+	fs.File
+	metadata metadata.Metadata
+}
+
+func (f *file) Metadata() metadata.Metadata {
+	return f.metadata
+}
+
+// Keeps files that implement [io.Seeker] seekable, so renderers can read them
+// more than once.
+type seekerFile struct {
+	file
+}
+
+func (f *seekerFile) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+// Metadata of the history of a file, which requests the last commit of the file
+// on the first access of one of the history keys.
+type historyMetadata struct {
+	p    *p
+	path string
+}
+
+func (m *historyMetadata) Get(key string) (any, error) {
+	switch key {
+	case EditURLKey:
+		if u := m.p.edit(m.path); u != "" {
+			return u, nil
+		}
+		return nil, metadata.ErrNotFound
+	case IssueURLKey:
+		if u := m.p.issue(m.path); u != "" {
+			return u, nil
+		}
+		return nil, metadata.ErrNotFound
+	case UpdatedKey, AuthorKey, CommitKey:
+	default:
+		return nil, metadata.ErrNotFound
+	}
+
+	c := m.p.lastCommit(m.path)
+	if c == nil {
+		return nil, metadata.ErrNotFound
+	}
+
+	switch key {
+	case UpdatedKey:
+		return c.Date, nil
+	case AuthorKey:
+		return c.Author, nil
+	default:
+		return *c, nil
+	}
+}
+
+func (m *historyMetadata) Set(key string, v any) error {
+	return metadata.ErrImmutable
+}
+
+func (m *historyMetadata) Delete(key string) error {
+	return metadata.ErrImmutable
+}
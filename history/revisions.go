@@ -0,0 +1,518 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/index"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/visibility"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const revisionsPluginName = "blogo-history-revisions-middleware"
+
+// Metadata key of the [Commit] of the revision of files opened from a previous
+// revision, so layouts can show that the content is outdated.
+const RevisionKey = "git.revision"
+
+// A [History] that can also open the file system at a previous revision.
+type Revisions interface {
+	History
+	// Returns the file system at the revision, such as the SHA of a commit.
+	At(ctx context.Context, rev string) (fs.FS, error)
+}
+
+// Creates a [plugin.Middleware] that serves previous revisions of posts at
+// "<url>?rev=<sha>", a listing of the revisions at "<url>?history", and the
+// word-level diff of the source of two revisions at "<url>?diff=<sha>&to=<sha>"
+// (to defaults to the latest revision).
+//
+// Revisions are rendered through the same renderers of the engine (see
+// [core.WithFiles]), with the [RevisionKey] on the metadata of files and a
+// banner inserted at the start of the body of HTML responses. Only commits that
+// changed the post can be viewed, and only for posts that are visible on
+// [visibility.Listing] on the current index.
+//
+// Since file systems of revisions don't go through the sourcer of the engine,
+// Opts.Sourcer should wrap them with the same metadata sourcers, such as the
+// frontmatter plugin.
+func NewRevisions(r Revisions, provider index.Provider, opts ...RevisionsOpts) plugin.Middleware {
+	opt := RevisionsOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Sourcer == nil {
+		opt.Sourcer = func(s plugin.Sourcer) plugin.Sourcer { return s }
+	}
+	if opt.Banner == nil {
+		opt.Banner = DefaultBanner
+	}
+	if opt.HistoryTemplate == nil {
+		opt.HistoryTemplate = DefaultHistoryTemplate
+	}
+	if opt.DiffTemplate == nil {
+		opt.DiffTemplate = DefaultDiffTemplate
+	}
+	if opt.Limit == 0 {
+		opt.Limit = 50
+	}
+	if opt.Visibility == nil {
+		opt.Visibility = visibility.Default
+	}
+	if opt.Timeout == 0 {
+		opt.Timeout = 10 * time.Second
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(r, "Revisions should not be nil")
+	opt.Assertions.NotNil(provider, "Index provider should not be nil")
+
+	return &revisions{
+		revisions: r,
+		provider:  provider,
+
+		sourcer:    opt.Sourcer,
+		banner:     opt.Banner,
+		history:    opt.HistoryTemplate,
+		diff:       opt.DiffTemplate,
+		limit:      opt.Limit,
+		visibility: opt.Visibility,
+		timeout:    opt.Timeout,
+
+		files: map[string]fs.FS{},
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type RevisionsOpts struct {
+	// Wraps the sourcer of the file systems of revisions, so their files have the
+	// same metadata as the current ones. Defaults to no wrapping.
+	Sourcer func(plugin.Sourcer) plugin.Sourcer
+
+	// Template of the banner inserted on revisions, executed with [RevisionInfo].
+	// Defaults to [DefaultBanner].
+	Banner *template.Template
+	// Template of the listing of revisions, executed with [RevisionInfo].
+	// Defaults to [DefaultHistoryTemplate].
+	HistoryTemplate *template.Template
+	// Template of the diff between revisions, executed with [DiffInfo].
+	// Defaults to [DefaultDiffTemplate].
+	DiffTemplate *template.Template
+
+	// Max number of revisions of a post. Defaults to 50.
+	Limit int
+	// Rules of the posts that have their revisions served. Defaults to [visibility.Default].
+	Visibility visibility.Rules
+	// Timeout of the requests to the history. Defaults to 10 seconds.
+	Timeout time.Duration
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+// Information passed to the templates of [NewRevisions].
+type RevisionInfo struct {
+	Entry index.Entry
+	// The commit of the revision being viewed, the zero value on the listing.
+	Revision Commit
+	// The commits that changed the post, from newest to oldest.
+	Commits []Commit
+}
+
+// Information passed to the diff template of [NewRevisions].
+type DiffInfo struct {
+	Entry index.Entry
+	From  Commit
+	To    Commit
+	// The diff of the source of the post, see [DiffHTML].
+	Diff template.HTML
+}
+
+// The default banner of revisions.
+var DefaultBanner = template.Must(template.New("revision-banner").Parse(
+	`<aside class="revision-banner" role="note">You are viewing a previous version of this page, ` +
+		`from <time datetime="{{.Revision.Date.Format "2006-01-02T15:04:05Z07:00"}}">{{.Revision.Date.Format "2006-01-02"}}</time> ` +
+		`({{.Revision.Short}}). <a href="{{.Entry.URL}}">See the current version</a> or ` +
+		`<a href="{{.Entry.URL}}?history">all versions</a>.</aside>`,
+))
+
+// The default listing of revisions.
+var DefaultHistoryTemplate = template.Must(template.New("revision-history").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>History of {{or .Entry.Title .Entry.URL}}</title>
+</head>
+<body>
+<h1>History of <a href="{{.Entry.URL}}">{{or .Entry.Title .Entry.URL}}</a></h1>
+<ol>
+{{- range .Commits}}
+<li><a href="{{$.Entry.URL}}?rev={{.SHA}}">{{.Short}}</a>
+<time datetime="{{.Date.Format "2006-01-02T15:04:05Z07:00"}}">{{.Date.Format "2006-01-02"}}</time>
+{{.Subject}}{{with .Author.Name}} by {{.}}{{end}}
+<a href="{{$.Entry.URL}}?diff={{.SHA}}">compare with current</a></li>
+{{- end}}
+</ol>
+</body>
+</html>
+`))
+
+// The default diff between revisions.
+var DefaultDiffTemplate = template.Must(template.New("revision-diff").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Changes of {{or .Entry.Title .Entry.URL}}</title>
+<style>ins { background: #dfd; } del { background: #fdd; }</style>
+</head>
+<body>
+<h1>Changes of <a href="{{.Entry.URL}}">{{or .Entry.Title .Entry.URL}}</a></h1>
+<p>From <a href="{{.Entry.URL}}?rev={{.From.SHA}}">{{.From.Short}}</a> ({{.From.Date.Format "2006-01-02"}})
+to <a href="{{.Entry.URL}}?rev={{.To.SHA}}">{{.To.Short}}</a> ({{.To.Date.Format "2006-01-02"}}).
+<a href="{{.Entry.URL}}?history">All versions</a>.</p>
+<pre style="white-space: pre-wrap">{{.Diff}}</pre>
+</body>
+</html>
+`))
+
+var revPattern = regexp.MustCompile(`^[0-9a-fA-F]{4,64}$`)
+
+type revisions struct {
+	revisions Revisions
+	provider  index.Provider
+
+	sourcer    func(plugin.Sourcer) plugin.Sourcer
+	banner     *template.Template
+	history    *template.Template
+	diff       *template.Template
+	limit      int
+	visibility visibility.Rules
+	timeout    time.Duration
+
+	mu    sync.Mutex
+	files map[string]fs.FS
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *revisions) Name() string {
+	return revisionsPluginName
+}
+
+func (p *revisions) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if !q.Has("rev") && !q.Has("history") && !q.Has("diff") || r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		log := p.log.With(slog.String("path", r.URL.Path))
+
+		idx, err := p.provider.Index()
+		if err != nil {
+			log.Error("Failed to get index for revisions", slog.String("err", err.Error()))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		entry, ok := idx.Lookup(r.URL.Path)
+		if !ok || !p.visibility.Visible(entry.Path, entry.Metadata, visibility.Listing) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), p.timeout)
+		defer cancel()
+
+		commits, err := p.revisions.Commits(ctx, entry.Path, p.limit)
+		if err != nil {
+			log.Error("Failed to get commits of post", slog.String("err", err.Error()))
+			http.Error(w, "Failed to get history", http.StatusBadGateway)
+			return
+		}
+
+		info := RevisionInfo{Entry: entry, Commits: commits}
+
+		if q.Has("diff") {
+			p.serveDiff(ctx, w, r, entry, commits)
+			return
+		}
+
+		if !q.Has("rev") {
+			p.execute(w, p.history, info)
+			return
+		}
+
+		rev, ok := findCommit(commits, q.Get("rev"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		info.Revision = rev
+
+		fsys, err := p.at(ctx, info.Revision)
+		if err != nil {
+			log.Error("Failed to open revision", slog.String("rev", rev.SHA), slog.String("err", err.Error()))
+			http.Error(w, "Failed to open revision", http.StatusBadGateway)
+			return
+		}
+
+		var banner bytes.Buffer
+		if err := p.banner.Execute(&banner, info); err != nil {
+			log.Error("Failed to execute revision banner", slog.String("err", err.Error()))
+		}
+
+		bw := &bannerWriter{ResponseWriter: w}
+		w.Header().Set("X-Robots-Tag", "noindex")
+
+		next.ServeHTTP(bw, r.WithContext(core.WithFiles(r.Context(), fsys)))
+
+		if err := bw.flush(banner.Bytes()); err != nil {
+			log.Error("Failed to write revision", slog.String("err", err.Error()))
+		}
+	})
+}
+
+func (p *revisions) serveDiff(
+	ctx context.Context,
+	w http.ResponseWriter,
+	r *http.Request,
+	entry index.Entry,
+	commits []Commit,
+) {
+	log := p.log.With(slog.String("path", r.URL.Path))
+
+	from, ok := findCommit(commits, r.URL.Query().Get("diff"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	to := commits[0]
+	if rev := r.URL.Query().Get("to"); rev != "" {
+		if to, ok = findCommit(commits, rev); !ok {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	sources := make([]string, 2)
+	for i, c := range []Commit{from, to} {
+		fsys, err := p.at(ctx, c)
+		if err != nil {
+			log.Error("Failed to open revision", slog.String("rev", c.SHA), slog.String("err", err.Error()))
+			http.Error(w, "Failed to open revision", http.StatusBadGateway)
+			return
+		}
+
+		b, err := fs.ReadFile(fsys, entry.Path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Error("Failed to read revision", slog.String("rev", c.SHA), slog.String("err", err.Error()))
+			http.Error(w, "Failed to read revision", http.StatusBadGateway)
+			return
+		}
+		sources[i] = string(b)
+	}
+
+	p.execute(w, p.diff, DiffInfo{
+		Entry: entry,
+		From:  from,
+		To:    to,
+		Diff:  DiffHTML(DiffWords(sources[0], sources[1])),
+	})
+}
+
+func (p *revisions) execute(w http.ResponseWriter, templt *template.Template, data any) {
+	var buf bytes.Buffer
+	if err := templt.Execute(&buf, data); err != nil {
+		p.log.Error("Failed to execute template",
+			slog.String("template", templt.Name()), slog.String("err", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	_, _ = buf.WriteTo(w)
+}
+
+// Returns the commit which SHA starts with the revision.
+func findCommit(commits []Commit, rev string) (Commit, bool) {
+	rev = strings.ToLower(rev)
+	if !revPattern.MatchString(rev) {
+		return Commit{}, false
+	}
+	for _, c := range commits {
+		if strings.HasPrefix(strings.ToLower(c.SHA), rev) {
+			return c, true
+		}
+	}
+	return Commit{}, false
+}
+
+// Returns the file system of the revision, cached by it's SHA since revisions
+// never change.
+func (p *revisions) at(ctx context.Context, c Commit) (fs.FS, error) {
+	p.mu.Lock()
+	fsys, ok := p.files[c.SHA]
+	p.mu.Unlock()
+	if ok {
+		return fsys, nil
+	}
+
+	fsys, err := p.revisions.At(ctx, c.SHA)
+	if err != nil {
+		return nil, err
+	}
+
+	fsys, err = p.sourcer(&revisionSourcer{fsys: &revisionFS{FS: fsys, commit: c}}).Source()
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to source revision"), err)
+	}
+
+	p.mu.Lock()
+	if len(p.files) >= 32 {
+		p.files = map[string]fs.FS{}
+	}
+	p.files[c.SHA] = fsys
+	p.mu.Unlock()
+
+	return fsys, nil
+}
+
+type revisionSourcer struct {
+	fsys fs.FS
+}
+
+func (s *revisionSourcer) Name() string {
+	return revisionsPluginName
+}
+
+func (s *revisionSourcer) Source() (fs.FS, error) {
+	return s.fsys, nil
+}
+
+type revisionFS struct {
+	fs.FS
+	commit Commit
+}
+
+func (fsys *revisionFS) Metadata() metadata.Metadata {
+	return metadata.Map(map[string]any{RevisionKey: fsys.commit})
+}
+
+func (fsys *revisionFS) Open(name string) (fs.File, error) {
+	f, err := fsys.FS.Open(name)
+	if err != nil {
+		return f, err
+	}
+
+	var m metadata.Metadata = metadata.Map(map[string]any{RevisionKey: fsys.commit})
+	if fm, err := metadata.GetMetadata(f); err == nil {
+		m = metadata.Join(m, fm)
+	}
+
+	if _, ok := f.(fs.ReadDirFile); ok {
+		return f, nil
+	}
+	if _, ok := f.(io.Seeker); ok {
+		return &seekerFile{file{File: f, metadata: m}}, nil
+	}
+	return &file{File: f, metadata: m}, nil
+}
+
+// Buffers HTML responses, to insert the banner after the opening body tag.
+// Other responses are written directly.
+type bannerWriter struct {
+	http.ResponseWriter
+	status int
+	html   bool
+	buf    bytes.Buffer
+	header bool
+}
+
+func (w *bannerWriter) WriteHeader(status int) {
+	if w.header {
+		return
+	}
+	w.header, w.status = true, status
+
+	w.html = strings.HasPrefix(w.Header().Get("Content-Type"), "text/html")
+	if !w.html {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *bannerWriter) Write(p []byte) (int, error) {
+	if !w.header {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.html {
+		return w.ResponseWriter.Write(p)
+	}
+	return w.buf.Write(p)
+}
+
+var bodyTag = regexp.MustCompile(`(?i)<body[^>]*>`)
+
+func (w *bannerWriter) flush(banner []byte) error {
+	if !w.html {
+		return nil
+	}
+
+	body := w.buf.Bytes()
+	if loc := bodyTag.FindIndex(body); loc != nil {
+		body = append(body[:loc[1]:loc[1]], append(banner, body[loc[1]:]...)...)
+	} else {
+		body = append(banner, body...)
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(w.status)
+
+	_, err := w.ResponseWriter.Write(body)
+	return err
+}
@@ -0,0 +1,240 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpclient provides the outbound HTTP client shared by the built-in
+// plugins and integrations that make requests to remote servers, such as the
+// gitea sourcer, oEmbed, pings and logins, so operators can control the egress
+// of the blog (timeouts, proxy, retries, user agent and TLS) in one place.
+//
+// Plugins that aren't given a client on construction use [Default], which can
+// be replaced with [SetDefault] before they are constructed. The default engine
+// also passes it's client, see Opts.HTTPClient of the blogo package, to plugins
+// that implement HTTPClientAware of the plugin package.
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// User agent of requests of clients created by [New], if Opts.UserAgent isn't set.
+const DefaultUserAgent = "blogo (+https://forge.capytal.company/loreddev/blogo)"
+
+var defaultClient atomic.Pointer[http.Client]
+
+// Returns the client set with [SetDefault], or a client created by [New] with
+// the default options.
+func Default() *http.Client {
+	if c := defaultClient.Load(); c != nil {
+		return c
+	}
+	c := New()
+	if defaultClient.CompareAndSwap(nil, c) {
+		return c
+	}
+	return defaultClient.Load()
+}
+
+// Sets the client returned by [Default]. Plugins get the default client on
+// construction, so it should be set before they are constructed. Setting it to
+// nil restores the client created by [New].
+func SetDefault(c *http.Client) {
+	defaultClient.Store(c)
+}
+
+// Creates a [http.Client] with the options, which retries idempotent requests
+// that fail with network errors or temporary statuses ("429 Too Many Requests",
+// "502 Bad Gateway", "503 Service Unavailable" and "504 Gateway Timeout"),
+// waiting the Retry-After of the response if it has one, and sets the user
+// agent of requests that don't have one.
+func New(opts ...Opts) *http.Client {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Timeout == 0 {
+		opt.Timeout = 30 * time.Second
+	} else if opt.Timeout < 0 {
+		opt.Timeout = 0
+	}
+	if opt.Retries == 0 {
+		opt.Retries = 2
+	} else if opt.Retries < 0 {
+		opt.Retries = 0
+	}
+	if opt.Backoff == 0 {
+		opt.Backoff = 500 * time.Millisecond
+	}
+	if opt.MaxBackoff == 0 {
+		opt.MaxBackoff = 10 * time.Second
+	}
+	if opt.UserAgent == "" {
+		opt.UserAgent = DefaultUserAgent
+	}
+
+	base := opt.Transport
+	if base == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		if opt.Proxy != nil {
+			t.Proxy = opt.Proxy
+		}
+		if opt.TLS != nil {
+			t.TLSClientConfig = opt.TLS.Clone()
+		}
+		base = t
+	}
+
+	return &http.Client{
+		Timeout: opt.Timeout,
+		Transport: &transport{
+			base:       base,
+			userAgent:  opt.UserAgent,
+			retries:    opt.Retries,
+			backoff:    opt.Backoff,
+			maxBackoff: opt.MaxBackoff,
+		},
+	}
+}
+
+type Opts struct {
+	// Timeout of each request, including retries and reading the body. Defaults
+	// to 30 seconds, negative values disable the timeout.
+	Timeout time.Duration
+	// Proxy of requests, see [http.Transport]. Defaults to [http.ProxyFromEnvironment].
+	Proxy func(*http.Request) (*url.URL, error)
+	// TLS configuration of requests, such as additional root certificates or
+	// a minimum version. By default the configuration of [http.DefaultTransport]
+	// is used.
+	TLS *tls.Config
+
+	// Number of times failed idempotent requests are retried. Defaults to 2,
+	// negative values disable retries.
+	Retries int
+	// Wait before the first retry, doubled on each retry. Defaults to 500 milliseconds.
+	Backoff time.Duration
+	// Max wait before a retry, including the Retry-After of responses. Defaults
+	// to 10 seconds.
+	MaxBackoff time.Duration
+
+	// User agent of requests that don't set one. Defaults to [DefaultUserAgent].
+	UserAgent string
+
+	// Transport that requests are sent with, instead of a transport with Proxy
+	// and TLS, such as one of tests.
+	Transport http.RoundTripper
+}
+
+type transport struct {
+	base       http.RoundTripper
+	userAgent  string
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+
+	retries := t.retries
+	if !idempotent(req) {
+		retries = 0
+	}
+
+	backoff := t.backoff
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, errors.Join(errors.New("failed to get body to retry request"), err)
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		res, err := t.base.RoundTrip(req)
+		if attempt >= retries || !retryable(res, err) {
+			return res, err
+		}
+
+		wait := backoff
+		if res != nil {
+			if d, ok := retryAfter(res.Header.Get("Retry-After")); ok {
+				wait = d
+			}
+			// The body is drained so the connection can be reused.
+			_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+			_ = res.Body.Close()
+		}
+		wait = min(wait, t.maxBackoff)
+		backoff *= 2
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Reports if the request can be sent again, because it's method is idempotent
+// and it's body, if any, can be read again.
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func retryable(res *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// Parses the Retry-After header, in seconds or as a HTTP date.
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if sec, err := strconv.Atoi(v); err == nil && sec >= 0 {
+		return time.Duration(sec) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,247 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+import (
+	"encoding/gob"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/metadata"
+)
+
+// Keys of the metadata of entries saved on the cache file of the index (see
+// Opts.CacheFile), used if Opts.CacheKeys is nil. Other keys are read from the
+// file system when first used.
+var DefaultCacheKeys = []string{
+	"title", "summary", "date", "updated", "tags", "permalink",
+	"draft", "exclude", "noindex", "unlisted", "pinned", "featured",
+	"layout", "type",
+}
+
+// Version of the format of the cache file, files of other versions are ignored.
+const cacheVersion = 1
+
+func init() {
+	gob.Register([]any{})
+	gob.Register(map[string]any{})
+	gob.Register(time.Time{})
+}
+
+type cacheFile struct {
+	Version     int
+	Fingerprint string
+	Entries     []cacheEntry
+}
+
+type cacheEntry struct {
+	Path     string
+	URL      string
+	Title    string
+	Summary  string
+	Date     time.Time
+	Updated  time.Time
+	Tags     []string
+	Metadata map[string]any
+	// Keys of the metadata that were saved or that the file doesn't have.
+	Keys []string
+}
+
+// Loads the index saved on the file, if it was built from files with the same
+// fingerprint. Returns nil if the file doesn't exist or is outdated. Dates are
+// moved to the location, since only their offsets are saved.
+func loadIndex(name, fingerprint string, fsys fs.FS, loc *time.Location) (Index, error) {
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Join(errors.New("failed to open cache file"), err)
+	}
+	defer f.Close()
+
+	var data cacheFile
+	if err := gob.NewDecoder(f).Decode(&data); err != nil {
+		return nil, errors.Join(errors.New("failed to decode cache file"), err)
+	}
+	if data.Version != cacheVersion || data.Fingerprint != fingerprint {
+		return nil, nil
+	}
+
+	entries := make([]Entry, 0, len(data.Entries))
+	for _, e := range data.Entries {
+		entries = append(entries, Entry{
+			Path:     e.Path,
+			URL:      e.URL,
+			Title:    e.Title,
+			Summary:  e.Summary,
+			Date:     e.Date.In(loc),
+			Updated:  e.Updated.In(loc),
+			Tags:     e.Tags,
+			Metadata: newCachedMetadata(fsys, e),
+		})
+	}
+
+	return newIndex(fsys, entries), nil
+}
+
+// Saves the entries of the index on the file, with the values of the keys
+// of their metadata that can be encoded.
+func saveIndex(name, fingerprint string, idx Index, keys []string) error {
+	data := cacheFile{Version: cacheVersion, Fingerprint: fingerprint}
+
+	for _, e := range idx.Entries() {
+		data.Entries = append(data.Entries, newCacheEntry(e, keys))
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return errors.Join(errors.New("failed to create temporary file"), err)
+	}
+	defer os.Remove(f.Name())
+
+	if err := gob.NewEncoder(f).Encode(data); err != nil {
+		f.Close()
+		return errors.Join(errors.New("failed to encode cache file"), err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Join(errors.New("failed to close temporary file"), err)
+	}
+
+	if err := os.Rename(f.Name(), name); err != nil {
+		return errors.Join(errors.New("failed to replace cache file"), err)
+	}
+
+	return nil
+}
+
+// Creates the saved form of the entry, with the values of the keys of it's
+// metadata that can be encoded.
+func newCacheEntry(e Entry, keys []string) cacheEntry {
+	m := map[string]any{}
+	known := []string{}
+	for _, k := range keys {
+		v, err := metadata.Get(e.Metadata, k)
+		if errors.Is(err, metadata.ErrNotFound) {
+			known = append(known, k)
+		} else if err == nil && encodable(v) {
+			m[k] = v
+			known = append(known, k)
+		}
+	}
+	return cacheEntry{
+		Path:     e.Path,
+		URL:      e.URL,
+		Title:    e.Title,
+		Summary:  e.Summary,
+		Date:     e.Date,
+		Updated:  e.Updated,
+		Tags:     e.Tags,
+		Metadata: m,
+		Keys:     known,
+	}
+}
+
+// Reports if the value can be saved on the cache file with it's type intact.
+// Other values, such as the maps of YAML frontmatter, are read from the file
+// system instead.
+func encodable(v any) bool {
+	switch v := v.(type) {
+	case string, bool, int, int64, uint64, float64, time.Time:
+		return true
+	case []any:
+		return !slices.ContainsFunc(v, func(v any) bool { return !encodable(v) })
+	case map[string]any:
+		for _, v := range v {
+			if !encodable(v) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// Metadata of a entry loaded from the cache file. Keys that weren't saved are
+// read from the metadata of the file when first used.
+type cachedMetadata struct {
+	values metadata.Map
+	known  map[string]struct{}
+
+	fsys fs.FS
+	path string
+
+	once sync.Once
+	file metadata.Metadata
+}
+
+func newCachedMetadata(fsys fs.FS, e cacheEntry) *cachedMetadata {
+	m := &cachedMetadata{
+		values: metadata.Map(map[string]any{}),
+		known:  make(map[string]struct{}, len(e.Keys)),
+		fsys:   fsys,
+		path:   e.Path,
+	}
+	for k, v := range e.Metadata {
+		m.values[k] = v
+	}
+	for _, k := range e.Keys {
+		m.known[k] = struct{}{}
+	}
+	return m
+}
+
+func (m *cachedMetadata) Get(key string) (any, error) {
+	if v, err := m.values.Get(key); err == nil {
+		return v, nil
+	}
+	if _, ok := m.known[key]; ok {
+		return nil, metadata.ErrNotFound
+	}
+	return m.load().Get(key)
+}
+
+func (m *cachedMetadata) Set(key string, v any) error {
+	m.known[key] = struct{}{}
+	return m.values.Set(key, v)
+}
+
+func (m *cachedMetadata) Delete(key string) error {
+	m.known[key] = struct{}{}
+	return m.values.Delete(key)
+}
+
+func (m *cachedMetadata) load() metadata.Metadata {
+	m.once.Do(func() {
+		m.file = metadata.Map(map[string]any{})
+
+		f, err := m.fsys.Open(m.path)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+
+		if fm, err := metadata.GetMetadata(f); err == nil {
+			m.file = fm
+		}
+	})
+	return m.file
+}
@@ -0,0 +1,545 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package index provides a in-memory index of the content of the blog, built from
+// the metadata of files, which is shared by the subsystems that need to know about
+// all posts, such as feeds, sitemaps, search and navigation.
+//
+// Use [New] to wrap the sourcer of the blog, so the index is rebuilt every time
+// the files are sourced.
+package index
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/changes"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-index-sourcer"
+
+// A indexed file.
+type Entry struct {
+	// Path of the file on the file system.
+	Path string
+	// URL path of the file, from it's "permalink" metadata, or it's path without
+	// the extension.
+	URL string
+
+	Title   string
+	Summary string
+	Date    time.Time
+	Updated time.Time
+	Tags    []string
+
+	Metadata metadata.Metadata
+}
+
+// A index of files, sorted by date, from newest to oldest.
+type Index interface {
+	// Returns all entries of the index.
+	Entries() []Entry
+	// Returns the entry of the file at the path.
+	Get(path string) (Entry, bool)
+	// Returns the entry with the URL. If more than one file has the URL, returns
+	// the winner of the [Collision].
+	Lookup(url string) (Entry, bool)
+	// Returns the URLs used by more than one file, sorted by URL.
+	Collisions() []Collision
+	// The file system that the index was built from.
+	FS() fs.FS
+}
+
+// A URL used by more than one file, for example when the "permalink" of a file
+// is the same as the path of another. Only the winner is served, so the other
+// files are shadowed and should have their URL changed.
+//
+// The winner is resolved with the same rule of the contenttype sourcer, so the
+// indexed file is the one served: files with a "permalink" win over the file
+// at the path, and between them the first one in [fs.WalkDir] order wins.
+type Collision struct {
+	URL    string
+	Winner string
+	// Paths of all files with the URL, from the winner to the last shadowed file.
+	Paths []string
+}
+
+func (c Collision) Error() string {
+	return fmt.Sprintf("URL %q is used by %d files (%s), only %q is served",
+		c.URL, len(c.Paths), strings.Join(c.Paths, ", "), c.Winner)
+}
+
+// Options used by [Build].
+type BuildOpts struct {
+	// Extensions of the files that are indexed. Defaults to ".md".
+	Extensions []string
+	// Time zone of the site, which dates of entries are on and which dates
+	// without one, such as "2025-03-01", are parsed in. Defaults to [time.UTC].
+	Location *time.Location
+}
+
+// Walks the file system, creating a [Index] of the files with the extensions.
+// The metadata of files is taken from the directory entries, so the file system
+// should provide it, for example by using the frontmatter plugin.
+func Build(fsys fs.FS, opts ...BuildOpts) (Index, error) {
+	opt := BuildOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Extensions == nil {
+		opt.Extensions = []string{".md"}
+	}
+	if opt.Location == nil {
+		opt.Location = time.UTC
+	}
+
+	entries := []Entry{}
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !slices.Contains(opt.Extensions, path.Ext(p)) {
+			return nil
+		}
+
+		var m metadata.Metadata = metadata.Map(map[string]any{})
+		if dm, err := metadata.GetMetadata(d); err == nil {
+			m = dm
+		}
+
+		entries = append(entries, newEntry(p, m, opt.Location))
+
+		return nil
+	})
+
+	idx := newIndex(fsys, entries)
+	if err != nil {
+		return idx, errors.Join(errors.New("failed to walk file system"), err)
+	}
+
+	return idx, nil
+}
+
+// Creates the index of the entries, sorting them and resolving their URLs.
+func newIndex(fsys fs.FS, entries []Entry) *index {
+	idx := &index{
+		fsys:       fsys,
+		entries:    entries,
+		paths:      map[string]int{},
+		urls:       map[string]int{},
+		collisions: []Collision{},
+		taxonomies: map[string]*taxonomyCache{},
+	}
+
+	slices.SortStableFunc(idx.entries, func(a, b Entry) int {
+		if c := b.Date.Compare(a.Date); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+	urls := map[string][]string{}
+	for i, e := range idx.entries {
+		idx.paths[e.Path] = i
+		urls[e.URL] = append(urls[e.URL], e.Path)
+	}
+
+	for url, ps := range urls {
+		slices.SortFunc(ps, func(a, b string) int {
+			return compareWinner(idx.entries[idx.paths[a]], idx.entries[idx.paths[b]])
+		})
+		idx.urls[url] = idx.paths[ps[0]]
+		if len(ps) > 1 {
+			idx.collisions = append(idx.collisions, Collision{URL: url, Winner: ps[0], Paths: ps})
+		}
+	}
+	slices.SortFunc(idx.collisions, func(a, b Collision) int {
+		return strings.Compare(a.URL, b.URL)
+	})
+
+	return idx
+}
+
+// Compares the entries with the same URL, so the winner of the [Collision] is
+// sorted first. Entries with a permalink win over the file at the path, the
+// same as the contenttype sourcer, which only falls back to the path when no
+// permalink matches. Between them, the first in [fs.WalkDir] order wins, since
+// the sourcer keeps the first permalink it walks.
+func compareWinner(a, b Entry) int {
+	ap, bp := hasPermalink(a), hasPermalink(b)
+	if ap != bp {
+		if ap {
+			return -1
+		}
+		return 1
+	}
+	return slices.Compare(strings.Split(a.Path, "/"), strings.Split(b.Path, "/"))
+}
+
+func hasPermalink(e Entry) bool {
+	if e.Metadata == nil {
+		return false
+	}
+	v, err := metadata.GetTyped[string](e.Metadata, "permalink")
+	return err == nil && v != ""
+}
+
+// Returns the URL of the file at the path, without a permalink.
+func pathURL(p string) string {
+	return "/" + strings.TrimSuffix(p, path.Ext(p))
+}
+
+// Creates the [Entry] of the file at the path, with the values of it's metadata.
+// Dates are on [time.UTC], see BuildOpts.Location to use the time zone of the site.
+func NewEntry(p string, m metadata.Metadata) Entry {
+	return newEntry(p, m, time.UTC)
+}
+
+func newEntry(p string, m metadata.Metadata, loc *time.Location) Entry {
+	e := Entry{
+		Path:     p,
+		URL:      pathURL(p),
+		Tags:     []string{},
+		Metadata: m,
+	}
+
+	if v, err := metadata.GetTyped[string](m, "permalink"); err == nil && v != "" {
+		e.URL = "/" + strings.TrimPrefix(v, "/")
+	}
+
+	e.Title, _ = metadata.GetTyped[string](m, "title")
+	e.Summary, _ = metadata.GetTyped[string](m, "summary")
+	// Dates are on the time zone of the site, so archives and feeds don't
+	// depend on the offsets written on each file.
+	if d, err := metadata.GetTimeIn(m, "date", loc); err == nil {
+		e.Date = d.In(loc)
+	}
+	if d, err := metadata.GetTimeIn(m, "updated", loc); err == nil {
+		e.Updated = d.In(loc)
+	}
+
+	if tags, err := metadata.GetTyped[[]any](m, "tags"); err == nil {
+		for _, t := range tags {
+			e.Tags = append(e.Tags, fmt.Sprint(t))
+		}
+	}
+
+	return e
+}
+
+type index struct {
+	fsys       fs.FS
+	entries    []Entry
+	paths      map[string]int
+	urls       map[string]int
+	collisions []Collision
+
+	// Taxonomies built on first use, see [Term].
+	taxonomies   map[string]*taxonomyCache
+	taxonomiesMu sync.Mutex
+}
+
+func (idx *index) Entries() []Entry {
+	return slices.Clone(idx.entries)
+}
+
+func (idx *index) Get(p string) (Entry, bool) {
+	i, ok := idx.paths[p]
+	if !ok {
+		return Entry{}, false
+	}
+	return idx.entries[i], true
+}
+
+func (idx *index) Lookup(url string) (Entry, bool) {
+	i, ok := idx.urls["/"+strings.Trim(url, "/")]
+	if !ok {
+		return Entry{}, false
+	}
+	return idx.entries[i], true
+}
+
+func (idx *index) Collisions() []Collision {
+	return slices.Clone(idx.collisions)
+}
+
+func (idx *index) FS() fs.FS {
+	return idx.fsys
+}
+
+// Source of the index of the blog, such as [Indexer].
+type Provider interface {
+	Index() (Index, error)
+}
+
+// A [plugin.Sourcer] that keeps a [Index] of the files it sources.
+type Indexer interface {
+	plugin.Sourcer
+	// Returns the index of the last sourced file system, sourcing the files if
+	// they weren't sourced yet.
+	Index() (Index, error)
+}
+
+// Creates a [Indexer] that wraps the sourcer, rebuilding the index every time
+// the files are sourced.
+func New(sourcer plugin.Sourcer, opts ...Opts) Indexer {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be wrapped should not be nil")
+
+	if opt.CacheKeys == nil {
+		opt.CacheKeys = DefaultCacheKeys
+	}
+	if opt.CacheFingerprint == nil {
+		opt.CacheFingerprint = changes.Fingerprint
+	}
+	if opt.SpillDir == "" {
+		opt.SpillDir = os.TempDir()
+	}
+	if opt.Location == nil {
+		opt.Location = time.UTC
+	}
+
+	return &indexer{
+		sourcer:   sourcer,
+		buildOpts: BuildOpts{Extensions: opt.Extensions, Location: opt.Location},
+
+		cacheFile:        opt.CacheFile,
+		cacheKeys:        opt.CacheKeys,
+		cacheFingerprint: opt.CacheFingerprint,
+
+		memoryBudget: opt.MemoryBudget,
+		spillDir:     opt.SpillDir,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Extensions of the files that are indexed. Defaults to ".md".
+	Extensions []string
+	// Time zone of the site, see BuildOpts.Location. Defaults to [time.UTC].
+	Location *time.Location
+
+	// Path of the file where the index is saved, so it's reloaded instead of
+	// rebuilt when the sourced files haven't changed (see CacheFingerprint), such
+	// as on restarts. By default the index is rebuilt every time.
+	CacheFile string
+	// Keys of the metadata of entries saved on CacheFile. Loaded entries read other
+	// keys from the file system when they are first used. Defaults to [DefaultCacheKeys].
+	CacheKeys []string
+	// Function used to check if the sourced files are the same ones the index of
+	// CacheFile was built from. Defaults to [changes.Fingerprint].
+	CacheFingerprint func(fs.FS) (string, error)
+
+	// Approximate number of bytes of metadata of entries kept in memory, so
+	// sites with tens of thousands of posts don't hold all of it in RAM. When
+	// the metadata of the index exceeds it, the values of CacheKeys are spilled
+	// to a file on SpillDir and read back when first used, keeping only the
+	// fields of [Entry] and the most recently used metadata in memory. Other
+	// keys, and the bodies of the files, are read from the file system when
+	// used. By default all metadata is kept in memory.
+	MemoryBudget int64
+	// Directory of the spill file of MemoryBudget. Defaults to [os.TempDir].
+	SpillDir string
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type indexer struct {
+	sourcer   plugin.Sourcer
+	buildOpts BuildOpts
+
+	cacheFile        string
+	cacheKeys        []string
+	cacheFingerprint func(fs.FS) (string, error)
+
+	memoryBudget int64
+	spillDir     string
+
+	mu    sync.RWMutex
+	index Index
+	spill *spillFile
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (i *indexer) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (i *indexer) SetLogger(logger *slog.Logger) {
+	if i.injectLogger {
+		i.log = logger
+	}
+}
+
+func (i *indexer) Source() (fs.FS, error) {
+	i.assert.NotNil(i.sourcer)
+	i.assert.NotNil(i.log)
+
+	fsys, err := i.sourcer.Source()
+	if err != nil {
+		return fsys, err
+	}
+
+	log := i.log.With(slog.String("sourcer", i.sourcer.Name()))
+
+	fingerprint := i.fingerprint(fsys, log)
+
+	idx := i.load(fingerprint, fsys, log)
+	if idx == nil {
+		log.Debug("Building index")
+
+		idx, err = Build(fsys, i.buildOpts)
+		if err != nil {
+			log.Warn("Failed to build complete index", slog.String("err", err.Error()))
+		} else {
+			i.save(fingerprint, idx, log)
+		}
+	}
+
+	var sf *spillFile
+	if i.memoryBudget > 0 {
+		idx, sf, err = spill(idx, i.spillDir, i.cacheKeys, i.memoryBudget)
+		if err != nil {
+			log.Warn("Failed to spill index metadata, keeping it in memory", slog.String("err", err.Error()))
+		} else if sf != nil {
+			log.Debug("Index metadata exceeds memory budget, spilled to file",
+				slog.String("file", sf.file.Name()))
+		}
+	}
+
+	i.mu.Lock()
+	old := i.spill
+	i.index = idx
+	i.spill = sf
+	i.mu.Unlock()
+
+	if old != nil {
+		old.close()
+	}
+
+	for _, c := range idx.Collisions() {
+		log.Error("URL collision, files are shadowed",
+			slog.String("url", c.URL),
+			slog.String("winner", c.Winner),
+			slog.String("paths", strings.Join(c.Paths, ", ")))
+	}
+
+	log.Debug("Index built", slog.Int("entries", len(idx.Entries())))
+
+	return fsys, nil
+}
+
+// Returns the fingerprint of the files, or a empty string if the index isn't
+// persisted or the fingerprint fails.
+func (i *indexer) fingerprint(fsys fs.FS, log *slog.Logger) string {
+	if i.cacheFile == "" {
+		return ""
+	}
+
+	fingerprint, err := i.cacheFingerprint(fsys)
+	if err != nil {
+		log.Warn("Failed to fingerprint files, cache file not used",
+			slog.String("file", i.cacheFile), slog.String("err", err.Error()))
+		return ""
+	}
+
+	return fingerprint
+}
+
+// Loads the index of the cache file, if it was built from files with the fingerprint.
+func (i *indexer) load(fingerprint string, fsys fs.FS, log *slog.Logger) Index {
+	if fingerprint == "" {
+		return nil
+	}
+
+	log = log.With(slog.String("file", i.cacheFile))
+
+	idx, err := loadIndex(i.cacheFile, fingerprint, fsys, i.buildOpts.Location)
+	if err != nil {
+		log.Warn("Failed to load cache file", slog.String("err", err.Error()))
+		return nil
+	}
+	if idx != nil {
+		log.Debug("Index loaded from cache file", slog.String("fingerprint", fingerprint))
+	}
+
+	return idx
+}
+
+func (i *indexer) save(fingerprint string, idx Index, log *slog.Logger) {
+	if fingerprint == "" {
+		return
+	}
+
+	err := saveIndex(i.cacheFile, fingerprint, idx, i.cacheKeys)
+	if err != nil {
+		log.Error("Failed to save cache file",
+			slog.String("file", i.cacheFile), slog.String("err", err.Error()))
+	}
+}
+
+func (i *indexer) Index() (Index, error) {
+	i.mu.RLock()
+	idx := i.index
+	i.mu.RUnlock()
+
+	if idx != nil {
+		return idx, nil
+	}
+
+	if _, err := i.Source(); err != nil {
+		return nil, errors.Join(errors.New("failed to source files to index"), err)
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.index, nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index_test
+
+import (
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/contenttype"
+	"forge.capytal.company/loreddev/blogo/index"
+	"forge.capytal.company/loreddev/blogo/plugins/frontmatter"
+)
+
+type sourcer struct {
+	fsys fs.FS
+}
+
+func (s sourcer) Name() string {
+	return "test-sourcer"
+}
+
+func (s sourcer) Source() (fs.FS, error) {
+	return s.fsys, nil
+}
+
+func TestCollisionWinner(t *testing.T) {
+	fsys, err := contenttype.NewSourcer(frontmatter.New(sourcer{fstest.MapFS{
+		"about.md":       {Data: []byte("about")},
+		"pages/about.md": {Data: []byte("---\npermalink: /about\n---\npermalink")},
+		"a-b.md":         {Data: []byte("---\npermalink: /dup\n---\nsecond")},
+		"a/b.md":         {Data: []byte("---\npermalink: /dup\n---\nfirst")},
+	}})).Source()
+	if err != nil {
+		t.Fatalf("failed to source files: %s", err)
+	}
+
+	idx, err := index.Build(fsys)
+	if err != nil {
+		t.Fatalf("failed to build index: %s", err)
+	}
+
+	for _, c := range []struct {
+		url    string
+		winner string
+		body   string
+	}{
+		{url: "/about", winner: "pages/about.md", body: "permalink"},
+		{url: "/dup", winner: "a/b.md", body: "first"},
+	} {
+		e, ok := idx.Lookup(c.url)
+		if !ok {
+			t.Fatalf("expected entry of URL %q", c.url)
+		}
+		if e.Path != c.winner {
+			t.Fatalf("expected %q to win URL %q, got %q", c.winner, c.url, e.Path)
+		}
+
+		f, err := fsys.Open(c.url[1:])
+		if err != nil {
+			t.Fatalf("failed to open %q: %s", c.url, err)
+		}
+		b, err := io.ReadAll(f)
+		_ = f.Close()
+		if err != nil {
+			t.Fatalf("failed to read %q: %s", c.url, err)
+		}
+		if !strings.HasSuffix(string(b), c.body) {
+			t.Fatalf("expected sourcer to serve %q on %q, got %q", c.body, c.url, b)
+		}
+	}
+
+	if cs := idx.Collisions(); len(cs) != 2 {
+		t.Fatalf("expected 2 collisions, got %d", len(cs))
+	}
+}
+
+func TestBuildLocation(t *testing.T) {
+	fsys, err := frontmatter.New(sourcer{fstest.MapFS{
+		"post.md": {Data: []byte("---\ndate: 2025-03-01 10:00\n---\n")},
+	}}).Source()
+	if err != nil {
+		t.Fatalf("failed to source files: %s", err)
+	}
+
+	for _, name := range []string{"UTC", "America/Sao_Paulo", "Asia/Tokyo"} {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Skipf("time zone database not available: %s", err)
+		}
+
+		idx, err := index.Build(fsys, index.BuildOpts{Location: loc})
+		if err != nil {
+			t.Fatalf("failed to build index: %s", err)
+		}
+
+		e, _ := idx.Get("post.md")
+		if want := time.Date(2025, 3, 1, 10, 0, 0, 0, loc); !e.Date.Equal(want) || e.Date.Location() != loc {
+			t.Fatalf("expected date %s on %s, got %s", want, name, e.Date)
+		}
+	}
+}
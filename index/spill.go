@@ -0,0 +1,187 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/gob"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+
+	"forge.capytal.company/loreddev/blogo/metadata"
+)
+
+// File where the metadata of the entries of a index is spilled when it doesn't
+// fit on the memory budget (see Opts.MemoryBudget). Metadata is read back when
+// first used, keeping only the most recently used entries in memory.
+type spillFile struct {
+	file   *os.File
+	budget int64
+
+	mu     sync.Mutex
+	loaded *list.List
+	used   int64
+}
+
+// Writes the metadata of the entries of the index to a file on the directory,
+// returning a index whose entries load it lazily. If the metadata of the index
+// fits on the budget, the index is returned unchanged, without a spill file.
+func spill(idx Index, dir string, keys []string, budget int64) (Index, *spillFile, error) {
+	f, err := os.CreateTemp(dir, "blogo-index-*.spill")
+	if err != nil {
+		return idx, nil, errors.Join(errors.New("failed to create spill file"), err)
+	}
+
+	s := &spillFile{file: f, budget: budget, loaded: list.New()}
+
+	entries := idx.Entries()
+	spilled := make([]Entry, len(entries))
+
+	var off int64
+	var buf bytes.Buffer
+	for i, e := range entries {
+		buf.Reset()
+		if err := gob.NewEncoder(&buf).Encode(newCacheEntry(e, keys)); err != nil {
+			s.close()
+			return idx, nil, errors.Join(errors.New("failed to encode entry"), err)
+		}
+		if _, err := f.Write(buf.Bytes()); err != nil {
+			s.close()
+			return idx, nil, errors.Join(errors.New("failed to write spill file"), err)
+		}
+
+		e.Metadata = &spilledMetadata{
+			spill: s,
+			fsys:  idx.FS(),
+			path:  e.Path,
+
+			off:  off,
+			size: int64(buf.Len()),
+		}
+		spilled[i] = e
+
+		off += int64(buf.Len())
+	}
+
+	if off <= budget {
+		s.close()
+		return idx, nil, nil
+	}
+
+	return newIndex(idx.FS(), spilled), s, nil
+}
+
+// Loads the metadata of the entry, evicting the least recently used ones if
+// the budget is exceeded.
+func (s *spillFile) load(m *spilledMetadata) *cachedMetadata {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m.loaded != nil {
+		s.loaded.MoveToFront(m.elem)
+		return m.loaded
+	}
+
+	var e cacheEntry
+	data := make([]byte, m.size)
+	if _, err := s.file.ReadAt(data, m.off); err != nil {
+		// The spill file is closed once the index is replaced, entries of the
+		// old index that are still in use read their metadata from the files.
+		return newCachedMetadata(m.fsys, cacheEntry{Path: m.path})
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&e); err != nil {
+		return newCachedMetadata(m.fsys, cacheEntry{Path: m.path})
+	}
+
+	m.loaded = newCachedMetadata(m.fsys, e)
+	m.elem = s.loaded.PushFront(m)
+	s.used += m.size
+
+	for s.used > s.budget && s.loaded.Len() > 1 {
+		old := s.loaded.Remove(s.loaded.Back()).(*spilledMetadata)
+		old.loaded, old.elem = nil, nil
+		s.used -= old.size
+	}
+
+	return m.loaded
+}
+
+// Closes and removes the spill file.
+func (s *spillFile) close() {
+	_ = s.file.Close()
+	_ = os.Remove(s.file.Name())
+}
+
+// Metadata of a entry spilled to a [spillFile]. Values set or deleted are kept
+// in memory, so they aren't lost when the entry is evicted.
+type spilledMetadata struct {
+	spill *spillFile
+	fsys  fs.FS
+	path  string
+
+	off  int64
+	size int64
+
+	// Guarded by the mutex of the spill file.
+	loaded *cachedMetadata
+	elem   *list.Element
+
+	mu      sync.Mutex
+	changed map[string]any
+	deleted map[string]struct{}
+}
+
+func (m *spilledMetadata) Get(key string) (any, error) {
+	m.mu.Lock()
+	if _, ok := m.deleted[key]; ok {
+		m.mu.Unlock()
+		return nil, metadata.ErrNotFound
+	}
+	if v, ok := m.changed[key]; ok {
+		m.mu.Unlock()
+		return v, nil
+	}
+	m.mu.Unlock()
+
+	return m.spill.load(m).Get(key)
+}
+
+func (m *spilledMetadata) Set(key string, v any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.changed == nil {
+		m.changed = map[string]any{}
+	}
+	m.changed[key] = v
+	delete(m.deleted, key)
+	return nil
+}
+
+func (m *spilledMetadata) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.deleted == nil {
+		m.deleted = map[string]struct{}{}
+	}
+	m.deleted[key] = struct{}{}
+	delete(m.changed, key)
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// A classification of the entries of a index by terms, such as their tags or
+// the years of their dates.
+type Taxonomy struct {
+	// Name of the taxonomy, taxonomies with the same name share their cache.
+	Name string
+	// Returns the terms of the entry. Terms are compared as returned, so they
+	// should be normalized, for example lowercased.
+	Terms func(Entry) []string
+}
+
+var (
+	// Taxonomy of the tags of entries, lowercased.
+	Tags = Taxonomy{Name: "tags", Terms: func(e Entry) []string {
+		ts := make([]string, 0, len(e.Tags))
+		for _, t := range e.Tags {
+			if t := strings.ToLower(strings.TrimSpace(t)); t != "" && !slices.Contains(ts, t) {
+				ts = append(ts, t)
+			}
+		}
+		return ts
+	}}
+	// Taxonomy of the years of the dates of entries, such as "2025".
+	Years = Taxonomy{Name: "years", Terms: func(e Entry) []string {
+		if e.Date.IsZero() {
+			return nil
+		}
+		return []string{e.Date.Format("2006")}
+	}}
+	// Taxonomy of the months of the dates of entries, such as "2025/03".
+	Months = Taxonomy{Name: "months", Terms: func(e Entry) []string {
+		if e.Date.IsZero() {
+			return nil
+		}
+		return []string{e.Date.Format("2006/01")}
+	}}
+)
+
+// A term of a taxonomy and it's number of entries, see [Terms].
+type TermCount struct {
+	Term  string
+	Count int
+}
+
+// Returns the entries of the index with the term of the taxonomy, in the order
+// of the index. Instead of building the taxonomy for all terms when the index
+// is built, the entries of each term are found on the first lookup of the term
+// and cached with the index, so building the index takes the same time however
+// many terms the site has.
+func Term(idx Index, t Taxonomy, term string) []Entry {
+	c := taxonomyOf(idx, t)
+
+	c.mu.Lock()
+	is, ok := c.terms[term]
+	if !ok && !c.complete {
+		is = c.find(term)
+		// Terms without entries aren't cached, so lookups of arbitrary terms,
+		// such as the ones of requested URLs, don't grow the cache.
+		if len(is) > 0 {
+			c.terms[term] = is
+		}
+	}
+	c.mu.Unlock()
+
+	es := make([]Entry, len(is))
+	for i, j := range is {
+		es[i] = c.entries[j]
+	}
+	return es
+}
+
+// Returns all terms of the taxonomy on the index with their number of entries,
+// sorted by term. The whole taxonomy is built on the first call and cached with
+// the index, so later calls to [Term] don't need to search the index.
+func Terms(idx Index, t Taxonomy) []TermCount {
+	c := taxonomyOf(idx, t)
+
+	c.mu.Lock()
+	if !c.complete {
+		c.build()
+	}
+	counts := make([]TermCount, 0, len(c.terms))
+	for term, is := range c.terms {
+		if len(is) > 0 {
+			counts = append(counts, TermCount{Term: term, Count: len(is)})
+		}
+	}
+	c.mu.Unlock()
+
+	slices.SortFunc(counts, func(a, b TermCount) int {
+		return strings.Compare(a.Term, b.Term)
+	})
+	return counts
+}
+
+// Cache of the terms of a taxonomy on a index, by the positions of their entries.
+type taxonomyCache struct {
+	entries []Entry
+	termsOf func(Entry) []string
+
+	mu       sync.Mutex
+	terms    map[string][]int
+	complete bool
+}
+
+// Returns the cache of the taxonomy of the index. Index implementations other
+// than the ones of this package don't keep the cache, so it's rebuilt on each call.
+func taxonomyOf(idx Index, t Taxonomy) *taxonomyCache {
+	i, ok := idx.(*index)
+	if !ok {
+		return newTaxonomyCache(idx.Entries(), t)
+	}
+
+	i.taxonomiesMu.Lock()
+	defer i.taxonomiesMu.Unlock()
+
+	if c, ok := i.taxonomies[t.Name]; ok {
+		return c
+	}
+	c := newTaxonomyCache(i.entries, t)
+	i.taxonomies[t.Name] = c
+	return c
+}
+
+func newTaxonomyCache(entries []Entry, t Taxonomy) *taxonomyCache {
+	return &taxonomyCache{entries: entries, termsOf: t.Terms, terms: map[string][]int{}}
+}
+
+// Returns the positions of the entries with the term. Must be called with the
+// mutex locked.
+func (c *taxonomyCache) find(term string) []int {
+	is := []int{}
+	for i, e := range c.entries {
+		if slices.Contains(c.termsOf(e), term) {
+			is = append(is, i)
+		}
+	}
+	return is
+}
+
+// Finds the entries of all terms. Must be called with the mutex locked.
+func (c *taxonomyCache) build() {
+	terms := map[string][]int{}
+	for i, e := range c.entries {
+		for _, t := range c.termsOf(e) {
+			if !slices.Contains(terms[t], i) {
+				terms[t] = append(terms[t], i)
+			}
+		}
+	}
+	c.terms = terms
+	c.complete = true
+}
@@ -0,0 +1,187 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indieauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"forge.capytal.company/loreddev/blogo/httpclient"
+)
+
+// Endpoints of the IndieAuth server of a profile URL.
+type Endpoints struct {
+	// Issuer identifier of the server, only known if discovered from it's metadata.
+	Issuer        string
+	Authorization string
+	Token         string
+}
+
+// Discovers the endpoints of the IndieAuth server of the profile URL, from it's
+// "indieauth-metadata" link, or the legacy "authorization_endpoint" and
+// "token_endpoint" links, on the "Link" header or the "<link>" elements of the
+// page (https://indieauth.spec.indieweb.org/#discovery-by-clients).
+func Discover(ctx context.Context, client *http.Client, me string) (Endpoints, error) {
+	if client == nil {
+		client = httpclient.Default()
+	}
+
+	links, base, err := fetchLinks(ctx, client, Canonical(me))
+	if err != nil {
+		return Endpoints{}, err
+	}
+
+	if m, ok := links["indieauth-metadata"]; ok {
+		e, err := fetchMetadata(ctx, client, resolve(base, m))
+		if err != nil {
+			return Endpoints{}, err
+		}
+		return e, nil
+	}
+
+	e := Endpoints{}
+	if a, ok := links["authorization_endpoint"]; ok {
+		e.Authorization = resolve(base, a)
+	}
+	if t, ok := links["token_endpoint"]; ok {
+		e.Token = resolve(base, t)
+	}
+	if e.Authorization == "" && e.Token == "" {
+		return Endpoints{}, fmt.Errorf("no IndieAuth endpoints found on %q", me)
+	}
+
+	return e, nil
+}
+
+func fetchMetadata(ctx context.Context, client *http.Client, u string) (Endpoints, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return Endpoints{}, errors.Join(errors.New("failed to create request"), err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return Endpoints{}, errors.Join(errors.New("failed to fetch server metadata"), err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return Endpoints{}, fmt.Errorf("unexpected response status %q of server metadata", res.Status)
+	}
+
+	var m struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 64<<10)).Decode(&m); err != nil {
+		return Endpoints{}, errors.Join(errors.New("failed to decode server metadata"), err)
+	}
+
+	return Endpoints{
+		Issuer:        m.Issuer,
+		Authorization: m.AuthorizationEndpoint,
+		Token:         m.TokenEndpoint,
+	}, nil
+}
+
+var discoveredRels = []string{"indieauth-metadata", "authorization_endpoint", "token_endpoint"}
+
+var (
+	htmlLinkPattern = regexp.MustCompile(`(?is)<link\s[^>]*>`)
+	htmlAttrPattern = regexp.MustCompile(`(?is)([a-z-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+)
+
+// Returns the href of the first link of each of the discovered rels, from the
+// "Link" header of the page at the URL and then from it's HTML, with the final
+// URL of the page (after redirects) that relative links are resolved against.
+func fetchLinks(ctx context.Context, client *http.Client, u string) (map[string]string, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, nil, errors.Join(errors.New("failed to create request"), err)
+	}
+	req.Header.Set("Accept", "text/html")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, nil, errors.Join(errors.New("failed to fetch profile URL"), err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, nil, fmt.Errorf("unexpected response status %q of profile URL", res.Status)
+	}
+
+	links := map[string]string{}
+	add := func(rels, href string) {
+		for _, rel := range strings.Fields(strings.ToLower(rels)) {
+			if _, ok := links[rel]; !ok && slices.Contains(discoveredRels, rel) {
+				links[rel] = href
+			}
+		}
+	}
+
+	for _, h := range res.Header.Values("Link") {
+		for _, l := range strings.Split(h, ",") {
+			href, params, ok := strings.Cut(strings.TrimSpace(l), ";")
+			if !ok || !strings.HasPrefix(href, "<") || !strings.HasSuffix(href, ">") {
+				continue
+			}
+			for _, p := range strings.Split(params, ";") {
+				k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+				if strings.EqualFold(k, "rel") {
+					add(strings.Trim(v, `"`), strings.Trim(href, "<>"))
+				}
+			}
+		}
+	}
+
+	if strings.Contains(res.Header.Get("Content-Type"), "html") {
+		b, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+		if err != nil {
+			return nil, nil, errors.Join(errors.New("failed to read profile page"), err)
+		}
+		for _, l := range htmlLinkPattern.FindAll(b, -1) {
+			attrs := map[string]string{}
+			for _, a := range htmlAttrPattern.FindAllSubmatch(l, -1) {
+				attrs[strings.ToLower(string(a[1]))] = html.UnescapeString(strings.Trim(string(a[2]), `"'`))
+			}
+			if attrs["rel"] != "" && attrs["href"] != "" {
+				add(attrs["rel"], attrs["href"])
+			}
+		}
+	}
+
+	return links, res.Request.URL, nil
+}
+
+func resolve(base *url.URL, ref string) string {
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
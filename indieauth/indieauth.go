@@ -0,0 +1,251 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package indieauth provides the IndieAuth (https://indieauth.spec.indieweb.org)
+// authentication of protected areas of the blog: a [auth.Authenticator] of
+// access tokens, so endpoints such as Micropub can be used by clients authorized
+// by the website of the owner of the blog, and a login flow for browsers that
+// keeps users logged in with [auth.Sessions].
+package indieauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/auth"
+	"forge.capytal.company/loreddev/blogo/httpclient"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+// Creates a [auth.Authenticator] that verifies the bearer tokens of requests on
+// the token endpoint, which responds with the "me" URL of the user that authorized
+// the token and it's scopes.
+//
+// Identities have the "me" URL as subject and the scopes of the token. If Opts.Me
+// is set, tokens of other users are rejected with [auth.ErrInvalidCredentials].
+//
+// If the token endpoint is empty, it's discovered from the first of Opts.Me (see
+// [Discover]) on the first request.
+func NewTokenVerifier(tokenEndpoint string, opts ...Opts) auth.Authenticator {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.HTTPClient == nil {
+		opt.HTTPClient = httpclient.Default()
+	}
+	if opt.Timeout == 0 {
+		opt.Timeout = 10 * time.Second
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotZero(tokenEndpoint+strings.Join(opt.Me, ""),
+		"Token endpoint should be provided or discovered from Opts.Me")
+
+	me := make([]string, 0, len(opt.Me))
+	for _, m := range opt.Me {
+		me = append(me, Canonical(m))
+	}
+
+	return &tokenVerifier{
+		endpoint: tokenEndpoint,
+		me:       me,
+
+		client:  opt.HTTPClient,
+		timeout: opt.Timeout,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Profile URLs of the users allowed to authenticate, such as
+	// "https://example.com/". If empty, tokens of any user are accepted.
+	Me []string
+
+	// Client used to verify tokens. Defaults to [httpclient.Default].
+	HTTPClient *http.Client
+	// Timeout of each verification. Defaults to 10 seconds.
+	Timeout time.Duration
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type tokenVerifier struct {
+	endpointMu sync.Mutex
+	endpoint   string
+	me         []string
+
+	client  *http.Client
+	timeout time.Duration
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+// Response of the token endpoint to a verification request.
+type tokenInfo struct {
+	Active   *bool  `json:"active"`
+	Me       string `json:"me"`
+	ClientID string `json:"client_id"`
+	Scope    string `json:"scope"`
+}
+
+func (a *tokenVerifier) Authenticate(r *http.Request) (auth.Identity, error) {
+	a.assert.NotNil(a.client)
+	a.assert.NotNil(a.log)
+
+	token, ok := auth.BearerToken(r)
+	if !ok {
+		return auth.Identity{}, auth.ErrUnauthenticated
+	}
+
+	info, err := a.verify(r.Context(), token)
+	if err != nil {
+		return auth.Identity{}, err
+	}
+
+	if info.Me == "" || (info.Active != nil && !*info.Active) {
+		return auth.Identity{}, auth.ErrInvalidCredentials
+	}
+
+	me := Canonical(info.Me)
+	if len(a.me) > 0 && !slices.Contains(a.me, me) {
+		a.log.Debug("Token of user not allowed", slog.String("me", me))
+		return auth.Identity{}, auth.ErrInvalidCredentials
+	}
+
+	return auth.Identity{Subject: me, Name: me, Scopes: strings.Fields(info.Scope)}, nil
+}
+
+func (a *tokenVerifier) Challenge() string {
+	return "Bearer"
+}
+
+func (a *tokenVerifier) verify(ctx context.Context, token string) (tokenInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	endpoint, err := a.tokenEndpoint(ctx)
+	if err != nil {
+		return tokenInfo{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return tokenInfo{}, errors.Join(errors.New("failed to create request"), err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return tokenInfo{}, errors.Join(errors.New("failed to verify token"), err)
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusUnauthorized ||
+		res.StatusCode == http.StatusForbidden ||
+		res.StatusCode == http.StatusBadRequest:
+		return tokenInfo{}, auth.ErrInvalidCredentials
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		return tokenInfo{}, fmt.Errorf("unexpected response status %q of token endpoint", res.Status)
+	}
+
+	info := tokenInfo{}
+	body := io.LimitReader(res.Body, 64<<10)
+	if strings.HasPrefix(res.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		// Older token endpoints respond with form encoded values.
+		b, err := io.ReadAll(body)
+		if err != nil {
+			return tokenInfo{}, errors.Join(errors.New("failed to read token information"), err)
+		}
+		v, err := url.ParseQuery(string(b))
+		if err != nil {
+			return tokenInfo{}, errors.Join(errors.New("failed to parse token information"), err)
+		}
+		info.Me, info.ClientID, info.Scope = v.Get("me"), v.Get("client_id"), v.Get("scope")
+	} else if err := json.NewDecoder(body).Decode(&info); err != nil {
+		return tokenInfo{}, errors.Join(errors.New("failed to decode token information"), err)
+	}
+
+	return info, nil
+}
+
+// Returns the token endpoint, discovering it from the first allowed user if it
+// wasn't provided.
+func (a *tokenVerifier) tokenEndpoint(ctx context.Context) (string, error) {
+	a.endpointMu.Lock()
+	defer a.endpointMu.Unlock()
+
+	if a.endpoint != "" {
+		return a.endpoint, nil
+	}
+	if len(a.me) == 0 {
+		return "", errors.New("no token endpoint to verify tokens")
+	}
+
+	e, err := Discover(ctx, a.client, a.me[0])
+	if err != nil {
+		return "", errors.Join(errors.New("failed to discover token endpoint"), err)
+	}
+	if e.Token == "" {
+		return "", fmt.Errorf("no token endpoint found on %q", a.me[0])
+	}
+
+	a.log.Debug("Token endpoint discovered", slog.String("endpoint", e.Token))
+	a.endpoint = e.Token
+
+	return a.endpoint, nil
+}
+
+// Returns the canonical form of a profile URL, with a "https" scheme if it
+// doesn't have one, lowercase host and a "/" path if it doesn't have a path.
+func Canonical(me string) string {
+	if !strings.Contains(me, "://") {
+		me = "https://" + me
+	}
+
+	u, err := url.Parse(me)
+	if err != nil {
+		return me
+	}
+
+	u.Host = strings.ToLower(u.Host)
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	return u.String()
+}
@@ -0,0 +1,481 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indieauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/auth"
+	"forge.capytal.company/loreddev/blogo/httpclient"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const loginPluginName = "blogo-indieauth-login-middleware"
+
+// Creates a [plugin.Middleware] that logs users in with their website, through
+// the IndieAuth authorization code flow, keeping their identity on the sessions.
+// Protected endpoints, such as the admin package ones, should then use the
+// sessions as their authenticator, or [auth.Any] of the sessions and a token
+// verifier ([NewTokenVerifier]) to also accept access tokens.
+//
+// The middleware serves, on Opts.Path:
+//
+//   - GET "<path>/login?me=URL&to=PATH" starts the login with the profile URL,
+//     showing a form asking for it if not provided, and redirecting back to the
+//     path of the blog after the login;
+//   - GET "<path>/callback" finishes the login, redirect there by the
+//     authorization endpoint;
+//   - POST "<path>/logout" removes the session.
+//
+// Logins are bound to the browser that started them by a [auth.StateCookie],
+// and at most 1024 of them can wait for the callback at once.
+//
+// The client ID is the URL of the blog, such as "https://example.com/", and the
+// redirect URL of the login is on it's host. Only the users with the profile URLs
+// of me can log in, with the identity having the profile URL as subject and
+// Opts.Scopes.
+func NewLogin(sessions auth.Sessions, clientID string, me []string, opt ...LoginOpts) plugin.Middleware {
+	opts := LoginOpts{}
+	if len(opt) > 0 {
+		opts = opt[0]
+	}
+
+	if opts.Path == "" {
+		opts.Path = "/.blogo/indieauth"
+	}
+	if opts.Scopes == nil {
+		opts.Scopes = []string{"admin"}
+	}
+	injectHTTPClient := opts.HTTPClient == nil
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = httpclient.Default()
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	if opts.Assertions == nil {
+		opts.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opts.Logger == nil
+	if opts.Logger == nil {
+		opts.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opts.Assertions.NotNil(sessions, "Sessions should not be nil")
+	opts.Assertions.NotZero(clientID, "Client ID should not be empty")
+	opts.Assertions.NotZero(len(me), "Allowed users should not be empty")
+
+	path := "/" + strings.Trim(opts.Path, "/")
+
+	redirect := path + "/callback"
+	if u, err := url.Parse(clientID); err == nil {
+		redirect = u.Scheme + "://" + u.Host + redirect
+	}
+
+	allowed := make([]string, 0, len(me))
+	for _, m := range me {
+		allowed = append(allowed, Canonical(m))
+	}
+
+	return &login{
+		sessions: sessions,
+
+		path:     path,
+		clientID: clientID,
+		redirect: redirect,
+		me:       allowed,
+		scopes:   opts.Scopes,
+
+		client:  opts.HTTPClient,
+		timeout: opts.Timeout,
+
+		pending: map[string]pendingLogin{},
+
+		injectLogger:     injectLogger,
+		injectHTTPClient: injectHTTPClient,
+
+		assert: opts.Assertions,
+		log:    opts.Logger,
+	}
+}
+
+type LoginOpts struct {
+	// Path of the login endpoints. Defaults to "/.blogo/indieauth".
+	Path string
+	// Scopes of the identities of users that log in. Defaults to "admin".
+	Scopes []string
+
+	// Client used to discover and call the endpoints of users. Defaults to
+	// [httpclient.Default].
+	HTTPClient *http.Client
+	// Timeout of each request to the endpoints of users. Defaults to 10 seconds.
+	Timeout time.Duration
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type login struct {
+	sessions auth.Sessions
+
+	path     string
+	clientID string
+	redirect string
+	me       []string
+	scopes   []string
+
+	client  *http.Client
+	timeout time.Duration
+
+	pendingMu sync.Mutex
+	pending   map[string]pendingLogin
+
+	injectLogger     bool
+	injectHTTPClient bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+// A login that was started and waits for the callback, by it's state.
+type pendingLogin struct {
+	me        string
+	endpoints Endpoints
+	verifier  string
+	to        string
+	expires   time.Time
+}
+
+// Time that users have to finish logging in.
+const pendingLoginTimeout = 10 * time.Minute
+
+// Max number of logins waiting for the callback, since they are started by
+// unauthenticated requests.
+const maxPendingLogins = 1024
+
+// Returns the cookie that binds the state of logins to the browser that started
+// them, sent over HTTPS if the callback is.
+func (l *login) stateCookie() auth.StateCookie {
+	return auth.StateCookie{
+		Name:     "blogo-indieauth-state",
+		Path:     l.path,
+		MaxAge:   pendingLoginTimeout,
+		Insecure: !strings.HasPrefix(l.redirect, "https://"),
+	}
+}
+
+func (l *login) Name() string {
+	return loginPluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (l *login) SetLogger(logger *slog.Logger) {
+	if l.injectLogger {
+		l.log = logger
+	}
+}
+
+// Implements [plugin.HTTPClientAware], using the client of the engine if no
+// client was provided on construction.
+func (l *login) SetHTTPClient(client *http.Client) {
+	if l.injectHTTPClient {
+		l.client = client
+	}
+}
+
+func (l *login) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.assert.NotNil(l.sessions)
+		l.assert.NotNil(l.log)
+
+		if !strings.HasPrefix(r.URL.Path, l.path+"/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+
+		switch strings.TrimPrefix(r.URL.Path, l.path) {
+		case "/login":
+			l.serveLogin(w, r)
+		case "/callback":
+			l.serveCallback(w, r)
+		case "/logout":
+			if r.Method != http.MethodPost {
+				w.Header().Set("Allow", "POST")
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			l.sessions.Logout(w)
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+var loginTemplate = template.Must(template.New("indieauth-login").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Log in</title>
+</head>
+<body>
+<h1>Log in</h1>
+{{- with .Error}}
+<p role="alert"><strong>{{.}}</strong></p>
+{{- end}}
+<form method="get" action="{{.Action}}">
+<input type="hidden" name="to" value="{{.To}}">
+<p><label>Your website <input type="url" name="me" value="{{.Me}}" placeholder="https://example.com/" required></label></p>
+<p><button type="submit">Log in</button></p>
+</form>
+</body>
+</html>
+`))
+
+func (l *login) serveLogin(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	to := q.Get("to")
+	if !strings.HasPrefix(to, "/") || strings.HasPrefix(to, "//") {
+		to = "/"
+	}
+
+	form := func(status int, me, msg string) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		err := loginTemplate.Execute(w, map[string]string{
+			"Action": l.path + "/login", "To": to, "Me": me, "Error": msg,
+		})
+		if err != nil {
+			l.log.Error("Failed to execute login template", slog.String("err", err.Error()))
+		}
+	}
+
+	if q.Get("me") == "" {
+		form(http.StatusOK, "", "")
+		return
+	}
+
+	me := Canonical(q.Get("me"))
+	log := l.log.With(slog.String("me", me))
+
+	if !slices.Contains(l.me, me) {
+		log.Debug("User not allowed to log in")
+		form(http.StatusForbidden, me, "This website can't log in")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), l.timeout)
+	defer cancel()
+
+	endpoints, err := Discover(ctx, l.client, me)
+	if err == nil && endpoints.Authorization == "" {
+		err = errors.New("no authorization endpoint")
+	}
+	if err != nil {
+		log.Warn("Failed to discover IndieAuth endpoints", slog.String("err", err.Error()))
+		form(http.StatusBadGateway, me, "Failed to find the authorization endpoint of the website")
+		return
+	}
+
+	state, verifier := randomString(), randomString()
+	challenge := sha256.Sum256([]byte(verifier))
+
+	l.pendingMu.Lock()
+	now := time.Now()
+	for s, p := range l.pending {
+		if now.After(p.expires) {
+			delete(l.pending, s)
+		}
+	}
+	if len(l.pending) >= maxPendingLogins {
+		l.pendingMu.Unlock()
+		log.Warn("Too many pending logins, rejecting login")
+		w.Header().Set("Retry-After", "60")
+		form(http.StatusServiceUnavailable, me, "Too many logins in progress, try again later")
+		return
+	}
+	l.pending[state] = pendingLogin{
+		me:        me,
+		endpoints: endpoints,
+		verifier:  verifier,
+		to:        to,
+		expires:   now.Add(pendingLoginTimeout),
+	}
+	l.pendingMu.Unlock()
+
+	u, err := url.Parse(endpoints.Authorization)
+	if err != nil {
+		log.Warn("Invalid authorization endpoint", slog.String("err", err.Error()))
+		form(http.StatusBadGateway, me, "Invalid authorization endpoint of the website")
+		return
+	}
+
+	l.stateCookie().Set(w, state)
+
+	v := u.Query()
+	v.Set("response_type", "code")
+	v.Set("client_id", l.clientID)
+	v.Set("redirect_uri", l.redirect)
+	v.Set("state", state)
+	v.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	v.Set("code_challenge_method", "S256")
+	v.Set("me", me)
+	u.RawQuery = v.Encode()
+
+	log.Debug("Redirecting to authorization endpoint")
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func (l *login) serveCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	l.pendingMu.Lock()
+	p, ok := l.pending[q.Get("state")]
+	delete(l.pending, q.Get("state"))
+	l.pendingMu.Unlock()
+
+	if !ok || time.Now().After(p.expires) {
+		http.Error(w, "Unknown or expired login, try again", http.StatusBadRequest)
+		return
+	}
+
+	log := l.log.With(slog.String("me", p.me))
+
+	// The login should be finished on the same browser that started it.
+	if !l.stateCookie().Verify(w, r, q.Get("state")) {
+		log.Warn("State of callback isn't of the browser that started the login")
+		http.Error(w, "Login wasn't started on this browser, try again", http.StatusBadRequest)
+		return
+	}
+
+	if e := q.Get("error"); e != "" {
+		log.Debug("Login denied", slog.String("error", e))
+		http.Error(w, "Login denied", http.StatusForbidden)
+		return
+	}
+	if iss := q.Get("iss"); p.endpoints.Issuer != "" && iss != p.endpoints.Issuer {
+		log.Warn("Issuer of callback doesn't match", slog.String("iss", iss))
+		http.Error(w, "Invalid issuer", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), l.timeout)
+	defer cancel()
+
+	me, err := l.redeem(ctx, p, q.Get("code"))
+	if err != nil {
+		log.Warn("Failed to redeem authorization code", slog.String("err", err.Error()))
+		http.Error(w, "Failed to verify login", http.StatusBadGateway)
+		return
+	}
+
+	if !slices.Contains(l.me, me) {
+		log.Warn("Authorization endpoint returned user not allowed", slog.String("returned", me))
+		http.Error(w, "User not allowed", http.StatusForbidden)
+		return
+	}
+	if me != p.me {
+		// The authorization endpoint needs to be the one of the returned user,
+		// otherwise any server could claim any identity.
+		e, err := Discover(ctx, l.client, me)
+		if err != nil || e.Authorization != p.endpoints.Authorization {
+			log.Warn("Returned user has other authorization endpoint", slog.String("returned", me))
+			http.Error(w, "Failed to verify login", http.StatusForbidden)
+			return
+		}
+	}
+
+	if err := l.sessions.Login(w, auth.Identity{Subject: me, Name: me, Scopes: l.scopes}); err != nil {
+		log.Error("Failed to create session", slog.String("err", err.Error()))
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("User logged in")
+
+	http.Redirect(w, r, p.to, http.StatusSeeOther)
+}
+
+// Redeems the authorization code on the authorization endpoint, returning the
+// canonical profile URL of the user.
+func (l *login) redeem(ctx context.Context, p pendingLogin, code string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {l.clientID},
+		"redirect_uri":  {l.redirect},
+		"code_verifier": {p.verifier},
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, p.endpoints.Authorization, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", errors.Join(errors.New("failed to create request"), err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := l.client.Do(req)
+	if err != nil {
+		return "", errors.Join(errors.New("failed to send request"), err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return "", fmt.Errorf("unexpected response status %q of authorization endpoint", res.Status)
+	}
+
+	var body struct {
+		Me string `json:"me"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 64<<10)).Decode(&body); err != nil {
+		return "", errors.Join(errors.New("failed to decode response"), err)
+	}
+	if body.Me == "" {
+		return "", errors.New("authorization endpoint didn't return the user")
+	}
+
+	return Canonical(body.Me), nil
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,161 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Creates a [Store] that persists the values on a single JSON file, for small
+// sites that don't want to manage a database. The file is loaded on creation
+// and rewritten atomically on every change, so writes are slower than the ones
+// of database stores as the number of values grows.
+func File(name string) (Store, error) {
+	s := &file{name: name, mem: &memory{entries: map[string]memoryEntry{}}}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type file struct {
+	name string
+
+	mu  sync.Mutex
+	mem *memory
+}
+
+// A entry of the file.
+type fileEntry struct {
+	Value   []byte    `json:"value"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// Version of the format of the store file. Files of newer versions aren't
+// loaded, and files of older versions are converted when loaded.
+const fileVersion = 1
+
+type fileData struct {
+	Version int                  `json:"version"`
+	Entries map[string]fileEntry `json:"entries"`
+}
+
+func (s *file) load() error {
+	data, err := os.ReadFile(s.name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return errors.Join(errors.New("failed to read store file"), err)
+	}
+
+	var d fileData
+	if err := json.Unmarshal(data, &d); err != nil {
+		return errors.Join(errors.New("failed to decode store file"), err)
+	}
+	// Files without a version were written before the format was versioned,
+	// and have the same format as the first version.
+	if d.Version > fileVersion {
+		return errors.Join(fmt.Errorf("version %d of store file is newer than %d", d.Version, fileVersion),
+			ErrNewerVersion)
+	}
+
+	for k, e := range d.Entries {
+		s.mem.entries[k] = memoryEntry{value: e.Value, expires: e.Expires}
+	}
+	return nil
+}
+
+// Writes the values to the file by writing a temporary file on the same
+// directory and renaming it, so a crash while saving doesn't corrupt it.
+// Must be called with the mutex locked.
+func (s *file) save() error {
+	now := time.Now()
+
+	s.mem.mu.Lock()
+	d := fileData{Version: fileVersion, Entries: make(map[string]fileEntry, len(s.mem.entries))}
+	for k, e := range s.mem.entries {
+		if e.expired(now) {
+			continue
+		}
+		d.Entries[k] = fileEntry{Value: e.value, Expires: e.expires}
+	}
+	s.mem.mu.Unlock()
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		return errors.Join(errors.New("failed to encode store file"), err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.name), ".tmp-"+filepath.Base(s.name)+"-*")
+	if err != nil {
+		return errors.Join(errors.New("failed to create temporary store file"), err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return errors.Join(errors.New("failed to write store file"), err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Join(errors.New("failed to write store file"), err)
+	}
+	if err := os.Rename(f.Name(), s.name); err != nil {
+		return errors.Join(errors.New("failed to replace store file"), err)
+	}
+	return nil
+}
+
+func (s *file) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return s.mem.Get(ctx, key)
+}
+
+func (s *file) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_ = s.mem.Set(ctx, key, value, ttl)
+	return s.save()
+}
+
+func (s *file) Delete(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_ = s.mem.Delete(ctx, keys...)
+	return s.save()
+}
+
+func (s *file) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, err := s.mem.Incr(ctx, key, delta)
+	if err != nil {
+		return 0, err
+	}
+	return n, s.save()
+}
+
+func (s *file) List(ctx context.Context, prefix string, limit int) ([]Entry, error) {
+	return s.mem.List(ctx, prefix, limit)
+}
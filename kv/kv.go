@@ -0,0 +1,177 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kv provides a key-value [Store] with expiration and listing by prefix,
+// the single persistence abstraction shared by the stateful plugins, such as
+// reactions and analytics, so a blog configures one storage for all of them.
+//
+// Stores of databases are provided by [SQL] (such as SQLite), [File] and
+// [Redis], and [Memory] keeps values in memory, for tests and small sites that
+// don't need their state persisted.
+//
+// Changes of the data and schemas of stores between versions of blogo are
+// applied once with [Migrate] and [MigrateSQL], which refuse data migrated by
+// newer versions, so downgrades don't corrupt it.
+package kv
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Storage of values by key. Keys are namespaced by the plugins with prefixes,
+// such as "reactions:".
+type Store interface {
+	// Returns the value of the key, and false if it doesn't exist or expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Sets the value of the key, which expires after the TTL. Values with zero
+	// or negative TTLs don't expire.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Removes the keys, ignoring the ones that don't exist.
+	Delete(ctx context.Context, keys ...string) error
+	// Atomically adds the delta to the integer value of the key, which is stored
+	// as it's decimal representation, returning the new value. Keys that don't
+	// exist start at zero and don't expire.
+	Incr(ctx context.Context, key string, delta int64) (int64, error)
+	// Returns up to limit entries whose keys have the prefix, sorted by their
+	// keys. Zero or negative limits return all entries.
+	List(ctx context.Context, prefix string, limit int) ([]Entry, error)
+}
+
+// A key and it's value, returned by [(Store).List].
+type Entry struct {
+	Key   string
+	Value []byte
+}
+
+// Returned by [(Store).Incr] when the value of the key isn't a integer.
+var ErrNotInteger = errors.New("value is not a integer")
+
+// Creates a [Store] that holds the values in memory, which are lost on restarts.
+func Memory() Store {
+	return &memory{entries: map[string]memoryEntry{}}
+}
+
+type memory struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+func (s *memory) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || e.expired(time.Now()) {
+		return nil, false, nil
+	}
+	return slices.Clone(e.value), true, nil
+}
+
+func (s *memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := memoryEntry{value: slices.Clone(value)}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	s.entries[key] = e
+
+	return nil
+}
+
+func (s *memory) Delete(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range keys {
+		delete(s.entries, k)
+	}
+	return nil
+}
+
+func (s *memory) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || e.expired(time.Now()) {
+		e = memoryEntry{}
+	}
+
+	n, err := incr(e.value, delta)
+	if err != nil {
+		return 0, err
+	}
+
+	e.value = []byte(strconv.FormatInt(n, 10))
+	s.entries[key] = e
+
+	return n, nil
+}
+
+func (s *memory) List(ctx context.Context, prefix string, limit int) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	keys := slices.Sorted(maps.Keys(s.entries))
+
+	entries := []Entry{}
+	for _, k := range keys {
+		if limit > 0 && len(entries) >= limit {
+			break
+		}
+
+		e := s.entries[k]
+		if e.expired(now) {
+			delete(s.entries, k)
+			continue
+		}
+		if strings.HasPrefix(k, prefix) {
+			entries = append(entries, Entry{Key: k, Value: slices.Clone(e.value)})
+		}
+	}
+
+	return entries, nil
+}
+
+// Returns the integer value plus the delta. Empty values are zero.
+func incr(value []byte, delta int64) (int64, error) {
+	if len(value) == 0 {
+		return delta, nil
+	}
+	n, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, ErrNotInteger
+	}
+	return n + delta, nil
+}
@@ -0,0 +1,189 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// A versioned change of the data of a [Store], such as renaming the keys of a
+// plugin, applied once by [Migrate].
+type Migration struct {
+	// Version of the data after the migration. Versions start at 1 and are
+	// applied in ascending order.
+	Version int
+	Name    string
+	Up      func(ctx context.Context, s Store) error
+}
+
+// A versioned change of the schema of a SQL database, applied once by
+// [MigrateSQL] inside a transaction.
+type SQLMigration struct {
+	// Version of the schema after the migration. Versions start at 1 and are
+	// applied in ascending order.
+	Version int
+	Name    string
+	Up      func(ctx context.Context, tx *sql.Tx) error
+}
+
+// Returned when the data was migrated by a newer version of blogo than the one
+// running, such as after a downgrade, since older code can corrupt data of
+// formats it doesn't know.
+var ErrNewerVersion = errors.New("data was migrated by a newer version")
+
+// Applies the migrations of the scope, such as the name of a plugin, that
+// weren't applied yet on the store, recording the applied version on the
+// "migrations:SCOPE" key. Returns [ErrNewerVersion] if the recorded version is
+// newer than the last migration.
+//
+// Stores can't apply migrations atomically, so a failed migration is applied
+// again on the next call, and should be written to be safe to repeat.
+func Migrate(ctx context.Context, s Store, scope string, migrations []Migration) error {
+	ms := slices.Clone(migrations)
+	slices.SortFunc(ms, func(a, b Migration) int { return a.Version - b.Version })
+
+	key := "migrations:" + scope
+
+	current := 0
+	if v, ok, err := s.Get(ctx, key); err != nil {
+		return errors.Join(errors.New("failed to get migrated version"), err)
+	} else if ok {
+		var r migrationRecord
+		if err := json.Unmarshal(v, &r); err != nil {
+			return errors.Join(errors.New("failed to decode migrated version"), err)
+		}
+		current = r.Version
+	}
+
+	if len(ms) > 0 && current > ms[len(ms)-1].Version {
+		return errors.Join(fmt.Errorf("version %d of %q is newer than %d", current, scope, ms[len(ms)-1].Version),
+			ErrNewerVersion)
+	}
+
+	for _, m := range ms {
+		if m.Version <= current {
+			continue
+		}
+
+		if err := m.Up(ctx, s); err != nil {
+			return errors.Join(fmt.Errorf("failed to apply migration %d %q of %q", m.Version, m.Name, scope), err)
+		}
+
+		v, err := json.Marshal(migrationRecord{Version: m.Version, Name: m.Name, Applied: time.Now()})
+		if err != nil {
+			return errors.Join(errors.New("failed to encode migrated version"), err)
+		}
+		if err := s.Set(ctx, key, v, 0); err != nil {
+			return errors.Join(errors.New("failed to set migrated version"), err)
+		}
+
+		current = m.Version
+	}
+
+	return nil
+}
+
+type migrationRecord struct {
+	Version int       `json:"version"`
+	Name    string    `json:"name"`
+	Applied time.Time `json:"applied"`
+}
+
+// Applies the migrations of the scope, such as the name of the table of a
+// store, that weren't applied yet on the database, each in it's own
+// transaction alongside the record of it's version on the "blogo_migrations"
+// table, so failed migrations don't leave the schema half changed. Returns
+// [ErrNewerVersion] if the recorded version is newer than the last migration.
+func MigrateSQL(ctx context.Context, db *sql.DB, scope string, migrations []SQLMigration) error {
+	ms := slices.Clone(migrations)
+	slices.SortFunc(ms, func(a, b SQLMigration) int { return a.Version - b.Version })
+
+	_, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS blogo_migrations ("+
+		"scope TEXT NOT NULL, version INTEGER NOT NULL, name TEXT NOT NULL, applied INTEGER NOT NULL, "+
+		"PRIMARY KEY (scope, version))")
+	if err != nil {
+		return errors.Join(errors.New("failed to create migrations table"), err)
+	}
+
+	var current sql.NullInt64
+	err = db.QueryRowContext(ctx,
+		"SELECT MAX(version) FROM blogo_migrations WHERE scope = ?", scope,
+	).Scan(&current)
+	if err != nil {
+		return errors.Join(errors.New("failed to query migrated version"), err)
+	}
+
+	if len(ms) > 0 && int(current.Int64) > ms[len(ms)-1].Version {
+		return errors.Join(fmt.Errorf("version %d of %q is newer than %d", current.Int64, scope, ms[len(ms)-1].Version),
+			ErrNewerVersion)
+	}
+
+	for _, m := range ms {
+		if int64(m.Version) <= current.Int64 {
+			continue
+		}
+		if err := applySQL(ctx, db, scope, m); err != nil {
+			return errors.Join(fmt.Errorf("failed to apply migration %d %q of %q", m.Version, m.Name, scope), err)
+		}
+	}
+
+	return nil
+}
+
+func applySQL(ctx context.Context, db *sql.DB, scope string, m SQLMigration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Join(errors.New("failed to begin transaction"), err)
+	}
+	defer tx.Rollback()
+
+	if err := m.Up(ctx, tx); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO blogo_migrations (scope, version, name, applied) VALUES (?, ?, ?, ?)",
+		scope, m.Version, m.Name, time.Now().UnixMilli(),
+	)
+	if err != nil {
+		return errors.Join(errors.New("failed to record migration"), err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Join(errors.New("failed to commit transaction"), err)
+	}
+	return nil
+}
+
+// Returns a [SQLMigration] that executes the statement, with all "{table}"
+// replaced by the table name.
+func SQLStatement(version int, name, table, statement string) SQLMigration {
+	return SQLMigration{
+		Version: version,
+		Name:    name,
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, strings.ReplaceAll(statement, "{table}", table))
+			return err
+		},
+	}
+}
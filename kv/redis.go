@@ -0,0 +1,181 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/redis"
+)
+
+// Creates a [Store] that persists the values on a Redis server, such as the one
+// of the cache backend, with the keys prefixed by the prefix, which defaults to
+// "blogo:kv:" if empty. Listing scans the keys with SCAN, so it doesn't block
+// the server, but is slower than the listing of other stores on large databases.
+func Redis(c redis.Client, prefix ...string) Store {
+	p := "blogo:kv:"
+	if len(prefix) > 0 && prefix[0] != "" {
+		p = prefix[0]
+	}
+	return &redisStore{client: c, prefix: p}
+}
+
+type redisStore struct {
+	client redis.Client
+	prefix string
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	v, err := s.client.Do(ctx, "GET", s.prefix+key)
+	if err != nil {
+		return nil, false, errors.Join(errors.New("failed to get value"), err)
+	}
+	if v == nil {
+		return nil, false, nil
+	}
+
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, false, errors.New("unexpected reply to GET")
+	}
+	return b, true, nil
+}
+
+func (s *redisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", s.prefix + key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+
+	if _, err := s.client.Do(ctx, args...); err != nil {
+		return errors.Join(errors.New("failed to set value"), err)
+	}
+	return nil
+}
+
+func (s *redisStore) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	args := make([]string, 0, len(keys)+1)
+	args = append(args, "DEL")
+	for _, k := range keys {
+		args = append(args, s.prefix+k)
+	}
+
+	if _, err := s.client.Do(ctx, args...); err != nil {
+		return errors.Join(errors.New("failed to delete values"), err)
+	}
+	return nil
+}
+
+func (s *redisStore) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	v, err := s.client.Do(ctx, "INCRBY", s.prefix+key, strconv.FormatInt(delta, 10))
+
+	var rerr redis.Error
+	if errors.As(err, &rerr) && strings.Contains(string(rerr), "not an integer") {
+		return 0, ErrNotInteger
+	} else if err != nil {
+		return 0, errors.Join(errors.New("failed to increment value"), err)
+	}
+
+	n, ok := v.(int64)
+	if !ok {
+		return 0, errors.New("unexpected reply to INCRBY")
+	}
+	return n, nil
+}
+
+func (s *redisStore) List(ctx context.Context, prefix string, limit int) ([]Entry, error) {
+	keys := []string{}
+
+	cursor := "0"
+	for {
+		v, err := s.client.Do(ctx, "SCAN", cursor, "MATCH", escapeGlob(s.prefix+prefix)+"*", "COUNT", "100")
+		if err != nil {
+			return nil, errors.Join(errors.New("failed to scan keys"), err)
+		}
+
+		reply, ok := v.([]any)
+		if !ok || len(reply) != 2 {
+			return nil, errors.New("unexpected reply to SCAN")
+		}
+		next, _ := reply[0].([]byte)
+		ks, _ := reply[1].([]any)
+
+		for _, k := range ks {
+			if k, ok := k.([]byte); ok {
+				keys = append(keys, string(k))
+			}
+		}
+
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			break
+		}
+	}
+
+	// SCAN may return the same key more than once.
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	entries := make([]Entry, 0, len(keys))
+	if len(keys) == 0 {
+		return entries, nil
+	}
+
+	v, err := s.client.Do(ctx, append([]string{"MGET"}, keys...)...)
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to get values"), err)
+	}
+	values, ok := v.([]any)
+	if !ok || len(values) != len(keys) {
+		return nil, errors.New("unexpected reply to MGET")
+	}
+
+	for i, k := range keys {
+		// Keys that expired between the scan and MGET are nil.
+		value, ok := values[i].([]byte)
+		if !ok {
+			continue
+		}
+		entries = append(entries, Entry{Key: strings.TrimPrefix(k, s.prefix), Value: value})
+	}
+
+	return entries, nil
+}
+
+// Escapes the characters of the glob patterns of SCAN.
+func escapeGlob(s string) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', ']', '\\':
+			b = append(b, '\\')
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
@@ -0,0 +1,194 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Creates a [Store] that persists the values on a SQL database, such as SQLite,
+// creating and migrating the table with [MigrateSQL]. The table name defaults to
+// "blogo_kv" if empty. Queries use "?" placeholders and "ON CONFLICT" upserts,
+// supported by SQLite and compatible databases.
+func SQL(db *sql.DB, table ...string) Store {
+	t := "blogo_kv"
+	if len(table) > 0 && table[0] != "" {
+		t = table[0]
+	}
+	return &sqlStore{db: db, table: t}
+}
+
+type sqlStore struct {
+	db    *sql.DB
+	table string
+	once  sync.Once
+	err   error
+}
+
+func (s *sqlStore) init(ctx context.Context) error {
+	s.once.Do(func() {
+		if err := MigrateSQL(ctx, s.db, s.table, sqlMigrations(s.table)); err != nil {
+			s.err = errors.Join(errors.New("failed to migrate key-value table"), err)
+		}
+	})
+	return s.err
+}
+
+// Migrations of the schema of the table of the SQL store.
+func sqlMigrations(table string) []SQLMigration {
+	return []SQLMigration{
+		SQLStatement(1, "create table", table,
+			"CREATE TABLE IF NOT EXISTS {table} (key TEXT PRIMARY KEY, value BLOB NOT NULL, expires INTEGER)"),
+		SQLStatement(2, "index expiration", table,
+			"CREATE INDEX IF NOT EXISTS {table}_expires ON {table} (expires)"),
+	}
+}
+
+// Returns the expiration of the TTL in Unix milliseconds, or nil if it doesn't
+// expire.
+func expiresAt(ttl time.Duration) any {
+	if ttl <= 0 {
+		return nil
+	}
+	return time.Now().Add(ttl).UnixMilli()
+}
+
+func (s *sqlStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if err := s.init(ctx); err != nil {
+		return nil, false, err
+	}
+
+	var value []byte
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT value FROM %s WHERE key = ? AND (expires IS NULL OR expires > ?)", s.table,
+	), key, time.Now().UnixMilli()).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, errors.Join(errors.New("failed to query value"), err)
+	}
+
+	return value, true, nil
+}
+
+func (s *sqlStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := s.init(ctx); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (key, value, expires) VALUES (?, ?, ?) "+
+			"ON CONFLICT(key) DO UPDATE SET value = excluded.value, expires = excluded.expires",
+		s.table,
+	), key, value, expiresAt(ttl))
+	if err != nil {
+		return errors.Join(errors.New("failed to set value"), err)
+	}
+
+	return nil
+}
+
+func (s *sqlStore) Delete(ctx context.Context, keys ...string) error {
+	if err := s.init(ctx); err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		if _, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE key = ?", s.table), k); err != nil {
+			return errors.Join(errors.New("failed to delete value"), err)
+		}
+	}
+
+	return nil
+}
+
+func (s *sqlStore) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	if err := s.init(ctx); err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.Join(errors.New("failed to begin transaction"), err)
+	}
+	defer tx.Rollback()
+
+	var value []byte
+	err = tx.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT value FROM %s WHERE key = ? AND (expires IS NULL OR expires > ?)", s.table,
+	), key, time.Now().UnixMilli()).Scan(&value)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, errors.Join(errors.New("failed to query value"), err)
+	}
+
+	n, err := incr(value, delta)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (key, value, expires) VALUES (?, ?, NULL) "+
+			"ON CONFLICT(key) DO UPDATE SET value = excluded.value, expires = excluded.expires",
+		s.table,
+	), key, []byte(strconv.FormatInt(n, 10)))
+	if err != nil {
+		return 0, errors.Join(errors.New("failed to set value"), err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Join(errors.New("failed to commit transaction"), err)
+	}
+
+	return n, nil
+}
+
+func (s *sqlStore) List(ctx context.Context, prefix string, limit int) ([]Entry, error) {
+	if err := s.init(ctx); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT key, value FROM %s WHERE substr(key, 1, ?) = ? AND (expires IS NULL OR expires > ?) "+
+			"ORDER BY key LIMIT ?",
+		s.table,
+	), utf8.RuneCountInString(prefix), prefix, time.Now().UnixMilli(), limit)
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to query values"), err)
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.Key, &e.Value); err != nil {
+			return nil, errors.Join(errors.New("failed to scan values"), err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
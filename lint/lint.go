@@ -0,0 +1,380 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lint provides style checks of the source files of the blog, such as
+// heading levels, long lines, images without alternative text, bare URLs,
+// frontmatter that doesn't follow the schema of it's content type and file
+// names that aren't slugs, with automatic fixes for the rules that are safe to
+// fix. Optional rules check the terminology and spelling of posts with user
+// provided terms and dictionaries, see [Terminology] and [Spelling].
+//
+// Unlike the validate package, which checks the indexed content that is served,
+// rules check the Markdown source of each file, so it is meant to be the "lint"
+// command of the application, run locally or in CI (rules can also be run on
+// the validation pass with [ValidationRule]):
+//
+//	if os.Args[1] == "lint" {
+//		report := lint.Run(ctx, sourcer, lint.Opts{Fix: slices.Contains(os.Args, "-fix")})
+//		for _, i := range report.Issues {
+//			fmt.Println(i)
+//		}
+//		os.Exit(report.ExitCode())
+//	}
+package lint
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/plugins/frontmatter"
+	"forge.capytal.company/loreddev/blogo/validate"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+// A source file being linted.
+type File struct {
+	// Path of the file on the file system.
+	Path string
+	// Contents of the file, with it's frontmatter.
+	Source []byte
+	// Frontmatter of the file, empty if it doesn't have one or if it's invalid.
+	Metadata map[string]any
+	// Line of the source where the body starts, after the frontmatter.
+	BodyLine int
+}
+
+// Creates the [File] of the source at the path, returning a error if the
+// frontmatter is invalid, alongside the file with empty metadata.
+func NewFile(p string, src []byte) (File, error) {
+	f := File{Path: p, Source: src, Metadata: map[string]any{}, BodyLine: 1}
+
+	body := frontmatter.Body(src)
+	f.BodyLine += bytes.Count(src[:len(src)-len(body)], []byte("\n"))
+
+	m, err := frontmatter.Parse(src)
+	if err != nil {
+		return f, err
+	}
+	f.Metadata = m
+
+	return f, nil
+}
+
+// Returns the body of the file, without the frontmatter.
+func (f File) Body() []byte {
+	return f.Source[len(f.Source)-len(frontmatter.Body(f.Source)):]
+}
+
+// Returns the source of the file with the body replaced.
+func (f File) WithBody(body []byte) []byte {
+	head := f.Source[:len(f.Source)-len(frontmatter.Body(f.Source))]
+	return append(slices.Clip(head), body...)
+}
+
+// A problem found on a file by a [Rule].
+type Issue struct {
+	// Path of the file on the file system.
+	Path string `json:"path"`
+	// Line of the issue on the source of the file, or 0 if it's about the whole file.
+	Line     int               `json:"line,omitempty"`
+	Rule     string            `json:"rule"`
+	Severity validate.Severity `json:"severity"`
+	Message  string            `json:"message"`
+	// Reports if the issue is fixed by the rule, see [Fixer].
+	Fixable bool `json:"fixable,omitempty"`
+}
+
+func (i Issue) String() string {
+	p := i.Path
+	if i.Line > 0 {
+		p = fmt.Sprintf("%s:%d", p, i.Line)
+	}
+	return fmt.Sprintf("%s: %s (%s %s)", p, i.Message, i.Rule, i.Severity)
+}
+
+// A lint rule, which checks a source file.
+type Rule interface {
+	Name() string
+	Check(f File) []Issue
+}
+
+// A [Rule] that can fix the issues it finds. Fixes should be safe, changing only
+// the style of the source and not how the file is rendered.
+type Fixer interface {
+	Rule
+	// Returns the source of the file with the issues of the rule fixed.
+	Fix(f File) []byte
+}
+
+type rule struct {
+	name  string
+	check func(f File) []Issue
+}
+
+func (r rule) Name() string {
+	return r.name
+}
+
+func (r rule) Check(f File) []Issue {
+	return r.check(f)
+}
+
+type fixer struct {
+	rule
+	fix func(f File) []byte
+}
+
+func (r fixer) Fix(f File) []byte {
+	return r.fix(f)
+}
+
+// Creates a [Rule] from a function, setting the path, name and [validate.SeverityError]
+// on all issues that don't have them.
+func NewRule(name string, check func(f File) []Issue) Rule {
+	return rule{name: name, check: func(f File) []Issue {
+		issues := check(f)
+		for i := range issues {
+			if issues[i].Path == "" {
+				issues[i].Path = f.Path
+			}
+			if issues[i].Rule == "" {
+				issues[i].Rule = name
+			}
+			if issues[i].Severity == "" {
+				issues[i].Severity = validate.SeverityError
+			}
+		}
+		return issues
+	}}
+}
+
+// Creates a [Fixer] from the functions, as [NewRule], also marking all issues
+// as fixable.
+func NewFixer(name string, check func(f File) []Issue, fix func(f File) []byte) Fixer {
+	r := NewRule(name, func(f File) []Issue {
+		issues := check(f)
+		for i := range issues {
+			issues[i].Fixable = true
+		}
+		return issues
+	}).(rule)
+	return fixer{rule: r, fix: fix}
+}
+
+// Wraps the rule, reporting all of it's issues as [validate.SeverityWarning],
+// so they don't fail the lint. Fixers are still fixed.
+func AsWarning(r Rule) Rule {
+	w := rule{name: r.Name(), check: func(f File) []Issue {
+		issues := r.Check(f)
+		for i := range issues {
+			issues[i].Severity = validate.SeverityWarning
+		}
+		return issues
+	}}
+	if fx, ok := r.(Fixer); ok {
+		return fixer{rule: w, fix: fx.Fix}
+	}
+	return w
+}
+
+// Checks the source of the file at the path with the rules, which default to
+// [DefaultRules]. Invalid frontmatter is reported as a issue of the
+// "frontmatter" rule.
+func Check(p string, src []byte, rules ...Rule) []Issue {
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+
+	f, err := NewFile(p, src)
+
+	issues := []Issue{}
+	if err != nil {
+		issues = append(issues, Issue{
+			Path:     p,
+			Line:     1,
+			Rule:     "frontmatter",
+			Severity: validate.SeverityError,
+			Message:  err.Error(),
+		})
+	}
+	for _, r := range rules {
+		issues = append(issues, r.Check(f)...)
+	}
+
+	slices.SortStableFunc(issues, func(a, b Issue) int { return a.Line - b.Line })
+	return issues
+}
+
+// Returns the source of the file at the path with the issues of the [Fixer]
+// rules fixed, applying each fixer to the result of the previous one.
+func Fix(p string, src []byte, rules ...Rule) []byte {
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+
+	for _, r := range rules {
+		fx, ok := r.(Fixer)
+		if !ok {
+			continue
+		}
+		f, err := NewFile(p, src)
+		if err != nil {
+			// Fixes of the body should not change invalid frontmatter.
+			f.Metadata = map[string]any{}
+		}
+		src = fx.Fix(f)
+	}
+	return src
+}
+
+// Results of a lint.
+type Report struct {
+	// Issues of all files, sorted by path and line. If files were fixed, only
+	// the issues that remain after fixing them.
+	Issues []Issue `json:"issues"`
+	// Paths of the files changed by fixes.
+	Fixed []string `json:"fixed"`
+	// Number of issues with [validate.SeverityError].
+	Errors int `json:"errors"`
+	// Number of issues with [validate.SeverityWarning].
+	Warnings int `json:"warnings"`
+	// Error which prevented the lint, such as a failure to source the files.
+	Err error `json:"-"`
+}
+
+// Returns the exit code of lint commands, 1 if the lint failed or any issue
+// is a error, and 0 otherwise.
+func (r Report) ExitCode() int {
+	if r.Err != nil || r.Errors > 0 {
+		return 1
+	}
+	return 0
+}
+
+type Opts struct {
+	// Rules used to check the files. Defaults to [DefaultRules].
+	Rules []Rule
+	// Extensions of the files that are linted. Defaults to ".md" and ".markdown".
+	Extensions []string
+
+	// Fixes the issues of [Fixer] rules, writing the fixed files to the sourcer,
+	// which needs to be a [plugin.WritableSourcer].
+	Fix bool
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+// Sources the files and checks every file with the extensions, returning the
+// [Report] of the lint. The sourcer should provide the files as they are written,
+// so it shouldn't be wrapped by the frontmatter plugin, which removes the
+// frontmatter of files.
+func Run(ctx context.Context, sourcer plugin.Sourcer, opts ...Opts) Report {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if len(opt.Rules) == 0 {
+		opt.Rules = DefaultRules()
+	}
+	if len(opt.Extensions) == 0 {
+		opt.Extensions = []string{".md", ".markdown"}
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be linted should not be nil")
+
+	report := Report{Issues: []Issue{}, Fixed: []string{}}
+
+	var writable plugin.WritableSourcer
+	if opt.Fix {
+		w, ok := sourcer.(plugin.WritableSourcer)
+		if !ok {
+			report.Err = errors.New("sourcer isn't writable, files can't be fixed")
+			return report
+		}
+		writable = w
+	}
+
+	fsys, err := sourcer.Source()
+	if err != nil {
+		report.Err = errors.Join(errors.New("failed to source files"), err)
+		return report
+	}
+
+	err = fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || !slices.Contains(opt.Extensions, strings.ToLower(path.Ext(p))) {
+			return nil
+		}
+
+		log := opt.Logger.With(slog.String("path", p))
+
+		src, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return errors.Join(fmt.Errorf("failed to read file %q", p), err)
+		}
+
+		if writable != nil {
+			if fixed := Fix(p, src, opt.Rules...); !bytes.Equal(fixed, src) {
+				log.Debug("Fixing file")
+				if err := writable.Update(ctx, p, fixed); err != nil {
+					return errors.Join(fmt.Errorf("failed to write fixed file %q", p), err)
+				}
+				report.Fixed = append(report.Fixed, p)
+				src = fixed
+			}
+		}
+
+		for _, i := range Check(p, src, opt.Rules...) {
+			if i.Severity == validate.SeverityError {
+				report.Errors++
+				log.Error(i.Message, slog.Int("line", i.Line), slog.String("rule", i.Rule))
+			} else {
+				report.Warnings++
+				log.Warn(i.Message, slog.Int("line", i.Line), slog.String("rule", i.Rule))
+			}
+			report.Issues = append(report.Issues, i)
+		}
+
+		return nil
+	})
+	if err != nil {
+		report.Err = errors.Join(errors.New("failed to lint files"), err)
+	}
+
+	return report
+}
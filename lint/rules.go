@@ -0,0 +1,421 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"forge.capytal.company/loreddev/blogo/contenttype"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/slug"
+)
+
+// The default rules: [HeadingJumps], [AltText] and [FileNames] with [slug.Default],
+// with [LineLength] of 120, [BareURLs], [TrailingWhitespace] and [FinalNewline]
+// as warnings. [Schemas] needs the registry of content types, so it isn't a
+// default rule.
+func DefaultRules() []Rule {
+	return []Rule{
+		HeadingJumps(),
+		AltText(),
+		FileNames(slug.Default),
+		AsWarning(LineLength(120)),
+		AsWarning(BareURLs()),
+		AsWarning(TrailingWhitespace()),
+		AsWarning(FinalNewline()),
+	}
+}
+
+var atxHeading = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]|$)`)
+
+// Creates a [Rule] that fails for headings that are more than one level deeper
+// than the previous heading, such as a h4 after a h2, which breaks the outline
+// of the page for readers and assistive technologies.
+func HeadingJumps() Rule {
+	return NewRule("heading-jumps", func(f File) []Issue {
+		issues := []Issue{}
+		prev := 0
+		for _, l := range lines(f) {
+			if l.code {
+				continue
+			}
+			m := atxHeading.FindStringSubmatch(l.text)
+			if m == nil {
+				continue
+			}
+			level := len(m[1])
+			if prev > 0 && level > prev+1 {
+				issues = append(issues, Issue{
+					Line:    l.n,
+					Message: fmt.Sprintf("heading level jumps from h%d to h%d", prev, level),
+				})
+			}
+			prev = level
+		}
+		return issues
+	})
+}
+
+// Creates a [Rule] that fails for lines longer than max characters. Lines of
+// code blocks, tables and lines with URLs are skipped, since they can't be
+// wrapped.
+func LineLength(max int) Rule {
+	return NewRule("line-length", func(f File) []Issue {
+		issues := []Issue{}
+		for _, l := range lines(f) {
+			if l.code || strings.HasPrefix(strings.TrimSpace(l.text), "|") || strings.Contains(l.text, "://") {
+				continue
+			}
+			if n := utf8.RuneCountInString(l.text); n > max {
+				issues = append(issues, Issue{
+					Line:    l.n,
+					Message: fmt.Sprintf("line is %d characters long, more than %d", n, max),
+				})
+			}
+		}
+		return issues
+	})
+}
+
+var (
+	markdownImageWithoutAlt = regexp.MustCompile(`!\[\s*\][(\[]`)
+	htmlImageTag            = regexp.MustCompile(`(?i)<img\b[^>]*>`)
+	htmlAlt                 = regexp.MustCompile(`(?i)\salt\s*=`)
+)
+
+// Creates a [Rule] that fails for images without alternative text, in Markdown
+// syntax with a empty description or in HTML without a "alt" attribute. HTML
+// images with a empty "alt" attribute are decorative, so they are allowed.
+func AltText() Rule {
+	return NewRule("alt-text", func(f File) []Issue {
+		issues := []Issue{}
+		for _, l := range lines(f) {
+			if l.code {
+				continue
+			}
+			text := blankCodeSpans(l.text)
+
+			n := len(markdownImageWithoutAlt.FindAllStringIndex(text, -1))
+			for _, tag := range htmlImageTag.FindAllString(text, -1) {
+				if !htmlAlt.MatchString(tag) {
+					n++
+				}
+			}
+			for range n {
+				issues = append(issues, Issue{Line: l.n, Message: "image without alternative text"})
+			}
+		}
+		return issues
+	})
+}
+
+var (
+	bareURL = regexp.MustCompile(`https?://[^\s<>]+`)
+	// Parts of lines where URLs aren't bare: links, images, autolinks, HTML tags
+	// and reference definitions.
+	linkedURL = regexp.MustCompile(`\[[^\]]*\]\([^)]*\)|\[[^\]]*\]\[[^\]]*\]|<[^>]+>|^ {0,3}\[[^\]]+\]:.*$`)
+)
+
+// Creates a [Fixer] that fails for URLs that aren't links, which are only
+// linked by some Markdown renderers, fixing them by turning them into
+// autolinks, such as "<https://example.com>". Lines of HTML blocks are skipped.
+func BareURLs() Fixer {
+	return NewFixer("bare-urls", func(f File) []Issue {
+		issues := []Issue{}
+		for _, l := range lines(f) {
+			for _, u := range bareURLs(l) {
+				issues = append(issues, Issue{
+					Line:    l.n,
+					Message: fmt.Sprintf("bare URL %q should be a link", l.text[u[0]:u[1]]),
+				})
+			}
+		}
+		return issues
+	}, func(f File) []byte {
+		return f.WithBody(mapLines(f, func(l line) string {
+			us := bareURLs(l)
+			for i := len(us) - 1; i >= 0; i-- {
+				u := us[i]
+				l.text = l.text[:u[0]] + "<" + l.text[u[0]:u[1]] + ">" + l.text[u[1]:]
+			}
+			return l.text
+		}))
+	})
+}
+
+// Returns the ranges of the bare URLs of the line.
+func bareURLs(l line) [][2]int {
+	if l.code || strings.HasPrefix(strings.TrimSpace(l.text), "<") {
+		return nil
+	}
+
+	text := linkedURL.ReplaceAllStringFunc(blankCodeSpans(l.text), func(s string) string {
+		return strings.Repeat(" ", len(s))
+	})
+
+	us := [][2]int{}
+	for _, m := range bareURL.FindAllStringIndex(text, -1) {
+		end := m[1]
+		for end > m[0] {
+			u := text[m[0]:end]
+			c := u[len(u)-1]
+			if strings.IndexByte(`.,;:!?'"*_`, c) >= 0 ||
+				(c == ')' && strings.Count(u, "(") < strings.Count(u, ")")) {
+				end--
+				continue
+			}
+			break
+		}
+		us = append(us, [2]int{m[0], end})
+	}
+	return us
+}
+
+var trailingWhitespace = regexp.MustCompile(`[ \t]+$`)
+
+// Creates a [Fixer] that fails for lines ending with whitespace, fixing them
+// by removing it. Two spaces after text are a hard line break, so they are
+// kept, and lines of code blocks are skipped.
+func TrailingWhitespace() Fixer {
+	return NewFixer("trailing-whitespace", func(f File) []Issue {
+		issues := []Issue{}
+		for _, l := range lines(f) {
+			if t := trimTrailing(l); t != l.text {
+				issues = append(issues, Issue{Line: l.n, Message: "line ends with whitespace"})
+			}
+		}
+		return issues
+	}, func(f File) []byte {
+		return f.WithBody(mapLines(f, trimTrailing))
+	})
+}
+
+func trimTrailing(l line) string {
+	if l.code {
+		return l.text
+	}
+	ws := trailingWhitespace.FindString(l.text)
+	if ws == "" {
+		return l.text
+	}
+	text := l.text[:len(l.text)-len(ws)]
+	if text != "" && strings.HasPrefix(ws, "  ") && !strings.Contains(ws, "\t") {
+		return text + "  "
+	}
+	return text
+}
+
+// Creates a [Fixer] that fails for files that don't end with exactly one new
+// line, fixing them by adding or removing the new lines at the end.
+func FinalNewline() Fixer {
+	newline := func(f File) string {
+		if strings.Contains(string(f.Source), "\r\n") {
+			return "\r\n"
+		}
+		return "\n"
+	}
+	fixed := func(f File) []byte {
+		src := strings.TrimRight(string(f.Source), "\r\n")
+		if src == "" {
+			return []byte{}
+		}
+		return []byte(src + newline(f))
+	}
+	return NewFixer("final-newline", func(f File) []Issue {
+		if string(fixed(f)) == string(f.Source) {
+			return []Issue{}
+		}
+		return []Issue{{Message: "file should end with a single new line"}}
+	}, fixed)
+}
+
+// Creates a [Rule] that fails for files whose frontmatter doesn't follow the
+// schema of their content type (see [contenttype.Schema]), such as missing
+// required fields, values of the wrong kind or outside of the enum of the
+// field, reported on the line of the field.
+func Schemas(r contenttype.Registry) Rule {
+	return NewRule("schema", func(f File) []Issue {
+		issues := []Issue{}
+		t, ok := r.Detect(f.Path, metadata.Map(f.Metadata))
+		if !ok || t.Schema == nil {
+			return issues
+		}
+		for _, err := range t.Schema.Check(metadata.Map(f.Metadata)) {
+			issues = append(issues, Issue{
+				Line:    fieldLine(f, err.Field),
+				Message: fmt.Sprintf("%s (type %q)", err.Message, t.Name),
+			})
+		}
+		return issues
+	})
+}
+
+// Returns the line of the key on the frontmatter of the file, or the first
+// line if it isn't there.
+func fieldLine(f File, key string) int {
+	for i, l := range strings.Split(string(f.Source), "\n") {
+		if i+1 >= f.BodyLine {
+			break
+		}
+		if strings.HasPrefix(l, key+":") {
+			return i + 1
+		}
+	}
+	return 1
+}
+
+// Creates a [Rule] that fails for files whose name, without the extension, or
+// whose "slug" metadata isn't a slug of the slugifier, so the URLs of the blog
+// are consistent. Names starting with "_", such as the section files of the
+// frontmatter plugin, are skipped.
+func FileNames(s slug.Slugifier) Rule {
+	return NewRule("file-names", func(f File) []Issue {
+		issues := []Issue{}
+
+		name := path.Base(f.Path)
+		name = strings.TrimSuffix(name, path.Ext(name))
+		if !strings.HasPrefix(name, "_") {
+			if sl := s.Slugify(name); sl != name {
+				issues = append(issues, Issue{
+					Message: fmt.Sprintf("file name %q should be a slug, such as %q", name, sl),
+				})
+			}
+		}
+
+		if v, ok := f.Metadata["slug"].(string); ok && v != "" {
+			if sl := s.Slugify(v); sl != v {
+				issues = append(issues, Issue{
+					Line:    fieldLine(f, "slug"),
+					Message: fmt.Sprintf("slug %q should be a slug, such as %q", v, sl),
+				})
+			}
+		}
+
+		return issues
+	})
+}
+
+// A line of the body of a file.
+type line struct {
+	// Number of the line on the source of the file.
+	n int
+	// Text of the line, without the line ending.
+	text string
+	// Reports if the line is part of a code block, including the fences.
+	code bool
+}
+
+var listItem = regexp.MustCompile(`^\s*([-*+]|\d+[.)])\s`)
+
+// Returns the lines of the body of the file.
+func lines(f File) []line {
+	raw := strings.Split(string(f.Body()), "\n")
+	ls := make([]line, len(raw))
+
+	fence := ""
+	// State of the previous lines, to tell indented code blocks, which start
+	// after a blank line, from the continuation of list items.
+	blank, list, indented := true, false, false
+	for i, r := range raw {
+		l := line{n: f.BodyLine + i, text: strings.TrimSuffix(r, "\r")}
+		trimmed := strings.TrimLeft(l.text, " ")
+		indent := len(l.text) - len(trimmed)
+		isBlank := strings.TrimSpace(l.text) == ""
+		isIndented := indent >= 4 || strings.HasPrefix(l.text, "\t")
+
+		switch {
+		case fence != "":
+			l.code = true
+			if indent < 4 && strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]+" \t") == "" {
+				fence = ""
+			}
+		case indent < 4 && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")):
+			l.code = true
+			fence = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, trimmed[:1]))]
+		case isIndented && !isBlank && (blank || indented) && !list:
+			l.code = true
+			indented = true
+		}
+
+		if !isBlank && !l.code {
+			indented = false
+			if !isIndented {
+				list = listItem.MatchString(l.text)
+			}
+		}
+		blank = isBlank
+
+		ls[i] = l
+	}
+
+	return ls
+}
+
+// Returns the body of the file with each line replaced by the result of the
+// function, keeping the line endings.
+func mapLines(f File, fn func(l line) string) []byte {
+	raw := strings.Split(string(f.Body()), "\n")
+	for i, l := range lines(f) {
+		cr := strings.HasSuffix(raw[i], "\r")
+		raw[i] = fn(l)
+		if cr {
+			raw[i] += "\r"
+		}
+	}
+	return []byte(strings.Join(raw, "\n"))
+}
+
+// Replaces the code spans of the text with spaces, keeping the positions of
+// the rest of the text.
+func blankCodeSpans(text string) string {
+	b := []byte(text)
+	for i := 0; i < len(b); {
+		if b[i] != '`' {
+			i++
+			continue
+		}
+		start := i
+		for i < len(b) && b[i] == '`' {
+			i++
+		}
+		run := string(b[start:i])
+
+		end := strings.Index(string(b[i:]), run)
+		for end >= 0 && i+end+len(run) < len(b) && b[i+end+len(run)] == '`' {
+			// The closing run should have the same length as the opening one.
+			next := strings.Index(string(b[i+end+len(run):]), run)
+			if next < 0 {
+				end = -1
+				break
+			}
+			end += len(run) + next
+		}
+		if end < 0 {
+			continue
+		}
+
+		stop := i + end + len(run)
+		for j := start; j < stop; j++ {
+			b[j] = ' '
+		}
+		i = stop
+	}
+	return string(b)
+}
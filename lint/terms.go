@@ -0,0 +1,281 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"forge.capytal.company/loreddev/blogo/index"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/validate"
+)
+
+// Frontmatter fields checked by [Terminology] and [Spelling], alongside the body.
+var ProseFields = []string{"title", "summary", "description"}
+
+// Creates a [Fixer] that fails for the terms that should be written as their
+// value, such as "Javascript" for "JavaScript", fixing them by replacing them.
+// Terms are matched as whole words ignoring case, except for occurrences
+// already written as their value, on the body and the [ProseFields] of the
+// frontmatter. Code, URLs and HTML tags are skipped.
+func Terminology(terms map[string]string) Fixer {
+	type term struct {
+		re   *regexp.Regexp
+		want string
+	}
+	ts := make([]term, 0, len(terms))
+	for _, k := range slices.Sorted(maps.Keys(terms)) {
+		words := strings.Fields(k)
+		for i := range words {
+			words[i] = regexp.QuoteMeta(words[i])
+		}
+		ts = append(ts, term{
+			re:   regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}_])(` + strings.Join(words, `\s+`) + `)(?:$|[^\p{L}\p{N}_])`),
+			want: terms[k],
+		})
+	}
+
+	// Returns the ranges of the terms on the prose of a line, and their values.
+	find := func(text string) (ranges [][2]int, want []string) {
+		for _, t := range ts {
+			for i := 0; i < len(text); {
+				m := t.re.FindStringSubmatchIndex(text[i:])
+				if m == nil {
+					break
+				}
+				start, end := i+m[2], i+m[3]
+				if text[start:end] != t.want {
+					ranges, want = append(ranges, [2]int{start, end}), append(want, t.want)
+				}
+				i = end
+			}
+		}
+		return ranges, want
+	}
+
+	return NewFixer("terminology", func(f File) []Issue {
+		issues := []Issue{}
+		for _, l := range proseLines(f) {
+			ranges, want := find(l.text)
+			for i, r := range ranges {
+				issues = append(issues, Issue{
+					Line:    l.n,
+					Message: fmt.Sprintf("%q should be written as %q", l.text[r[0]:r[1]], want[i]),
+				})
+			}
+		}
+		return issues
+	}, func(f File) []byte {
+		src := strings.Split(string(f.Source), "\n")
+		for _, l := range proseLines(f) {
+			ranges, want := find(l.text)
+			// Replaced from the end, so the ranges of the other terms don't move.
+			order := make([]int, len(ranges))
+			for i := range order {
+				order[i] = i
+			}
+			slices.SortFunc(order, func(a, b int) int { return ranges[b][0] - ranges[a][0] })
+
+			text := src[l.n-1]
+			last := len(text) + 1
+			for _, i := range order {
+				r := ranges[i]
+				if r[1] > last {
+					// Overlaps a term that was already replaced.
+					continue
+				}
+				text = text[:r[0]] + want[i] + text[r[1]:]
+				last = r[0]
+			}
+			src[l.n-1] = text
+		}
+		return []byte(strings.Join(src, "\n"))
+	})
+}
+
+// A set of words, lowercased, used by [Spelling].
+type Dictionary map[string]struct{}
+
+// Creates a [Dictionary] of the words.
+func NewDictionary(words ...string) Dictionary {
+	d := Dictionary{}
+	d.Add(words...)
+	return d
+}
+
+// Reads a [Dictionary] of one word per line, such as "/usr/share/dict/words".
+// Blank lines and lines starting with "#" are ignored.
+func ReadDictionary(r io.Reader) (Dictionary, error) {
+	d := Dictionary{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if w := strings.TrimSpace(s.Text()); w != "" && !strings.HasPrefix(w, "#") {
+			d.Add(w)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Join(errors.New("failed to read dictionary"), err)
+	}
+	return d, nil
+}
+
+// Adds the words to the dictionary.
+func (d Dictionary) Add(words ...string) {
+	for _, w := range words {
+		d[strings.ToLower(w)] = struct{}{}
+	}
+}
+
+// Reports if the word is on the dictionary, ignoring case and possessives.
+func (d Dictionary) Contains(word string) bool {
+	w := strings.ToLower(strings.ReplaceAll(word, "’", "'"))
+	if _, ok := d[w]; ok {
+		return true
+	}
+	if base, ok := strings.CutSuffix(w, "'s"); ok {
+		_, ok = d[base]
+		return ok
+	}
+	return false
+}
+
+var word = regexp.MustCompile(`\p{L}+(?:['’]\p{L}+)*`)
+
+// Creates a [Rule] that fails for the words that aren't on the dictionaries,
+// on the body and the [ProseFields] of the frontmatter, so the dictionaries
+// should have a full word list alongside the words of the blog, such as names
+// and jargon. Words in all caps, such as acronyms, and words of a single
+// letter are skipped, as are code, URLs and HTML tags.
+func Spelling(dicts ...Dictionary) Rule {
+	return NewRule("spelling", func(f File) []Issue {
+		issues := []Issue{}
+		for _, l := range proseLines(f) {
+			seen := map[string]bool{}
+			for _, w := range word.FindAllString(l.text, -1) {
+				base := strings.TrimSuffix(strings.TrimSuffix(w, "'s"), "’s")
+				if seen[w] || utf8.RuneCountInString(base) < 2 || isUpper(base) {
+					continue
+				}
+				seen[w] = true
+
+				if !slices.ContainsFunc(dicts, func(d Dictionary) bool { return d.Contains(w) }) {
+					issues = append(issues, Issue{Line: l.n, Message: fmt.Sprintf("unknown word %q", w)})
+				}
+			}
+		}
+		return issues
+	})
+}
+
+func isUpper(w string) bool {
+	for _, r := range w {
+		if unicode.IsLetter(r) && !unicode.IsUpper(r) {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	proseField = regexp.MustCompile(`^(\w+):[ \t]*`)
+	// Parts of lines that aren't prose: destinations of links and images,
+	// HTML tags and reference definitions.
+	notProse = regexp.MustCompile(`\]\([^)]*\)|<[^>]+>|^ {0,3}\[[^\]]+\]:.*$`)
+)
+
+// Returns the prose of the lines of the file, with the code spans, URLs and
+// other parts that aren't prose replaced by spaces, so the positions of words
+// on the text are the same as on the source. Includes the lines of the
+// [ProseFields] of the frontmatter.
+func proseLines(f File) []line {
+	ls := []line{}
+
+	for i, text := range strings.Split(string(f.Source), "\n") {
+		if i+1 >= f.BodyLine {
+			break
+		}
+		text = strings.TrimSuffix(text, "\r")
+		m := proseField.FindStringSubmatch(text)
+		if m == nil || !slices.Contains(ProseFields, m[1]) {
+			continue
+		}
+		ls = append(ls, line{n: i + 1, text: strings.Repeat(" ", len(m[0])) + text[len(m[0]):]})
+	}
+
+	for _, l := range lines(f) {
+		if l.code || strings.HasPrefix(strings.TrimSpace(l.text), "<") {
+			continue
+		}
+		text := blankCodeSpans(l.text)
+		text = notProse.ReplaceAllStringFunc(text, blank)
+		text = bareURL.ReplaceAllStringFunc(text, blank)
+		ls = append(ls, line{n: l.n, text: text})
+	}
+
+	return ls
+}
+
+func blank(s string) string {
+	return strings.Repeat(" ", len(s))
+}
+
+// Creates a [validate.Rule] that checks the entries of the index with the lint
+// rules, so rules such as [Terminology] and [Spelling] are also run on the
+// validation pass. The index only has the bodies of the files, so issues are
+// reported on the lines of the body.
+func ValidationRule(rules ...Rule) validate.Rule {
+	return validate.NewRule("lint", func(idx index.Index) []validate.Issue {
+		issues := []validate.Issue{}
+		for _, e := range idx.Entries() {
+			contents, err := fs.ReadFile(idx.FS(), e.Path)
+			if err != nil {
+				issues = append(issues, validate.Issue{Path: e.Path, Message: "failed to read file: " + err.Error()})
+				continue
+			}
+
+			f := File{Path: e.Path, Source: contents, Metadata: map[string]any{}, BodyLine: 1}
+			if m, ok := e.Metadata.(metadata.Map); ok {
+				f.Metadata = m
+			}
+
+			for _, r := range rules {
+				for _, i := range r.Check(f) {
+					msg := i.Message
+					if i.Line > 0 {
+						msg = fmt.Sprintf("line %d: %s", i.Line, msg)
+					}
+					issues = append(issues, validate.Issue{
+						Path:     e.Path,
+						Rule:     i.Rule,
+						Severity: i.Severity,
+						Message:  msg,
+					})
+				}
+			}
+		}
+		return issues
+	})
+}
@@ -0,0 +1,370 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package maintenance provides a maintenance mode for the blog, which can be
+// toggled by admins while the server is running. While enabled, requests are
+// responded with "503 Service Unavailable" and a page rendered from the source
+// file system, except the admin and health endpoints.
+package maintenance
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/audit"
+	"forge.capytal.company/loreddev/blogo/auth"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/visibility"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-maintenance-middleware"
+
+// The maintenance mode, which is a [plugin.Middleware] that can be toggled with
+// Enable and Disable, or by the requests of admins to it's endpoint.
+type Plugin interface {
+	plugin.Middleware
+	// Enables the maintenance mode, with the message shown on the fallback
+	// page, used if the page of the source file system can't be rendered.
+	Enable(message string)
+	Disable()
+	// Reports if the maintenance mode is enabled, and it's message.
+	Enabled() (enabled bool, message string)
+}
+
+// Creates the maintenance mode [Plugin].
+//
+// While enabled, requests are responded with "503 Service Unavailable" and the
+// Retry-After header, with the render of Opts.Page by the rest of the engine, or
+// a plain text page if it fails, except the requests to Opts.Allow and of users
+// authenticated by Opts.Authenticator, so admins can still see the site.
+//
+// The mode is toggled by POST requests to Opts.Path, authenticated by
+// Opts.Authenticator with Opts.Scopes, with the "enabled" form value set to
+// "true" or "false" and the optional "message" value. GET requests respond with
+// the current state as JSON.
+func New(opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Path == "" {
+		opt.Path = "/.blogo/maintenance"
+	}
+	if opt.Page == "" {
+		opt.Page = "/maintenance"
+	}
+	if opt.Allow == nil {
+		opt.Allow = []string{".blogo/**", "healthz", "readyz", "robots.txt"}
+	}
+	if opt.Scopes == nil {
+		opt.Scopes = []string{"admin"}
+	}
+	if opt.RetryAfter == 0 {
+		opt.RetryAfter = 5 * time.Minute
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &p{
+		path:          "/" + strings.Trim(opt.Path, "/"),
+		page:          "/" + strings.Trim(opt.Page, "/"),
+		allow:         opt.Allow,
+		authenticator: opt.Authenticator,
+		scopes:        opt.Scopes,
+		retryAfter:    opt.RetryAfter,
+		audit:         opt.Audit,
+
+		enabled: opt.Enabled,
+		message: opt.Message,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Path of the endpoint that toggles the maintenance mode. Defaults to
+	// "/.blogo/maintenance".
+	Path string
+	// Path of the page served while in maintenance, rendered by the rest of
+	// the engine, such as a "maintenance.md" file if the path renderer maps
+	// it. Defaults to "/maintenance".
+	Page string
+	// Patterns of the paths that are still served while in maintenance, with
+	// the same syntax as [visibility.Match]. Defaults to the endpoints under
+	// ".blogo", such as the admin, "healthz", "readyz" and "robots.txt".
+	Allow []string
+
+	// Authenticator of the requests to Path. Requests of users authenticated by
+	// it, with Scopes, also bypass the maintenance mode. If nil, the mode can
+	// only be toggled with the methods of [Plugin].
+	Authenticator auth.Authenticator
+	// Scopes that the identity needs to have. Defaults to "admin".
+	Scopes []string
+
+	// Duration sent as the Retry-After header. Defaults to 5 minutes.
+	RetryAfter time.Duration
+
+	// Log where toggles of the mode by requests to Path are recorded, with the
+	// "maintenance.enable" and "maintenance.disable" actions.
+	Audit audit.Log
+
+	// Starts with the maintenance mode enabled, with the message.
+	Enabled bool
+	Message string
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	path          string
+	page          string
+	allow         []string
+	authenticator auth.Authenticator
+	scopes        []string
+	retryAfter    time.Duration
+	audit         audit.Log
+
+	mu      sync.RWMutex
+	enabled bool
+	message string
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Enable(message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.enabled, p.message = true, message
+	p.log.Info("Maintenance mode enabled", slog.String("message", message))
+}
+
+func (p *p) Disable() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.enabled, p.message = false, ""
+	p.log.Info("Maintenance mode disabled")
+}
+
+func (p *p) Enabled() (bool, string) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.enabled, p.message
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	toggle := auth.Require(p.authenticator, http.HandlerFunc(p.serveToggle), auth.RequireOpts{
+		Scopes:     p.scopes,
+		Assertions: p.assert,
+		Logger:     p.log,
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.log)
+
+		if r.URL.Path == p.path {
+			w.Header().Set("Cache-Control", "no-store")
+			toggle.ServeHTTP(w, r)
+			return
+		}
+
+		enabled, message := p.Enabled()
+		if !enabled || p.allowed(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p.serveMaintenance(next, w, r, message)
+	})
+}
+
+func (p *p) allowed(r *http.Request) bool {
+	name := strings.Trim(r.URL.Path, "/")
+	if slices.ContainsFunc(p.allow, func(pattern string) bool {
+		return visibility.Match(pattern, name)
+	}) {
+		return true
+	}
+
+	if p.authenticator == nil {
+		return false
+	}
+	id, err := p.authenticator.Authenticate(r)
+	if err != nil {
+		return false
+	}
+	for _, s := range p.scopes {
+		if !id.HasScope(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// Responds with the render of the maintenance page by the next handler, or the
+// fallback page if it isn't rendered.
+func (p *p) serveMaintenance(next http.Handler, w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(p.retryAfter.Seconds())))
+	w.Header().Set("Cache-Control", "no-store")
+
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		pr := r.Clone(r.Context())
+		pr.Method = http.MethodGet
+		pr.URL.Path, pr.URL.RawPath = p.page, ""
+		pr.URL.RawQuery = ""
+		pr.RequestURI = pr.URL.RequestURI()
+
+		rec := &recorder{header: http.Header{}, status: http.StatusOK}
+		next.ServeHTTP(rec, pr)
+
+		if rec.status == http.StatusOK {
+			for k, v := range rec.header {
+				if k == "Content-Length" || k == "Cache-Control" {
+					continue
+				}
+				w.Header()[k] = v
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			if r.Method != http.MethodHead {
+				_, _ = w.Write(rec.body.Bytes())
+			}
+			return
+		}
+
+		p.log.Warn("Failed to render maintenance page, using fallback",
+			slog.String("page", p.page), slog.Int("status", rec.status))
+	}
+
+	if message == "" {
+		message = "The site is under maintenance, please try again later."
+	}
+	http.Error(w, message, http.StatusServiceUnavailable)
+}
+
+type state struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+}
+
+func (p *p) serveToggle(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPost:
+		if !sameOrigin(r) {
+			http.Error(w, "Cross-origin request", http.StatusForbidden)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form", http.StatusBadRequest)
+			return
+		}
+
+		enabled, err := strconv.ParseBool(r.PostForm.Get("enabled"))
+		if err != nil {
+			http.Error(w, `The "enabled" value should be "true" or "false"`, http.StatusBadRequest)
+			return
+		}
+
+		if enabled {
+			p.Enable(r.PostForm.Get("message"))
+			e := audit.FromRequest(r, "maintenance.enable", "")
+			e.Details = map[string]string{"message": r.PostForm.Get("message")}
+			audit.Record(r.Context(), p.audit, e, p.log)
+		} else {
+			p.Disable()
+			audit.Record(r.Context(), p.audit, audit.FromRequest(r, "maintenance.disable", ""), p.log)
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	enabled, message := p.Enabled()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(state{Enabled: enabled, Message: message})
+}
+
+// Reports if the request was sent by a page of the same host, so other websites
+// can't make the browser of a authenticated user toggle the maintenance mode.
+// Requests without "Origin" or "Referer" headers, such as the ones of
+// non-browser clients, are accepted.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// [http.ResponseWriter] that holds the render of the maintenance page.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recorder) Header() http.Header {
+	return w.header
+}
+
+func (w *recorder) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *recorder) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
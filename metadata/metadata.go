@@ -21,8 +21,10 @@
 package metadata
 
 import (
+	"context"
 	"errors"
 	"reflect"
+	"sync"
 )
 
 var (
@@ -181,6 +183,37 @@ func GetMetadata(m any) (Metadata, error) {
 	return data, nil
 }
 
+// Gets the metadata of the values with [GetMetadata] concurrently, with up to
+// the given number of values at once, so values that lazily load and cache
+// their metadata, such as the directory entries of the frontmatter plugin, load
+// it in parallel instead of one by one when it's later used. This is critical
+// for sourcers with high latency per file, such as the ones of forges. Stops
+// starting new loads once the context is done.
+func Prefetch[T any](ctx context.Context, vs []T, concurrency int) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for _, v := range vs {
+		if ctx.Err() != nil {
+			break
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			_, _ = GetMetadata(v)
+		}()
+	}
+
+	wg.Wait()
+}
+
 // Types may implement this interface to add [Metadata] to their objects that can
 // be easily accessed via [Get], [Set], [Delete] and [GetMetadata].
 type WithMetadata interface {
@@ -0,0 +1,77 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata
+
+import (
+	"time"
+)
+
+// Layouts accepted by [GetTime] and [ParseTime] when the value is a string. Set
+// it on the start of the program to accept the date formats of the site, before
+// the files are sourced.
+var TimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	time.DateOnly,
+}
+
+// Parses the string using the [TimeLayouts], in [time.UTC] if it doesn't have a
+// time zone. See [ParseTimeIn] to use the time zone of the site.
+func ParseTime(s string) (time.Time, error) {
+	return ParseTimeIn(s, time.UTC)
+}
+
+// Parses the string using the [TimeLayouts], in the location if it doesn't have
+// a time zone, such as "2025-03-01". Uses [time.UTC] if the location is nil.
+func ParseTimeIn(s string, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	for _, l := range TimeLayouts {
+		if t, err := time.ParseInLocation(l, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrInvalidType
+}
+
+// Gets a value from a [Metadata] or [WithMetadata] objects as a [time.Time]. If the
+// value is a string, it is parsed with [ParseTime].
+//
+// If the value is not a [time.Time] or a string in one of the layouts, returns [ErrInvalidType].
+func GetTime(m any, key string) (time.Time, error) {
+	return GetTimeIn(m, key, time.UTC)
+}
+
+// Same as [GetTime], but strings are parsed with [ParseTimeIn] on the location.
+func GetTimeIn(m any, key string, loc *time.Location) (time.Time, error) {
+	v, err := Get(m, key)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return ParseTimeIn(v, loc)
+	}
+
+	return time.Time{}, ErrInvalidType
+}
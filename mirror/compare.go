@@ -0,0 +1,260 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/history"
+)
+
+// A mirrored request, with the responses of both pipelines.
+type Sample struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	// Path and query of the request.
+	URL  string `json:"url"`
+	Host string `json:"host"`
+
+	Primary   Response `json:"primary"`
+	Candidate Response `json:"candidate"`
+}
+
+// A response recorded on a [Sample].
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+	// If the body was cut at Opts.MaxBody.
+	Truncated bool          `json:"truncated,omitempty"`
+	Elapsed   time.Duration `json:"elapsed"`
+	// Error of the candidate, such as a panic or a timeout.
+	Err string `json:"err,omitempty"`
+}
+
+// Returns a function for Opts.OnSample that writes the samples to w as JSON, one
+// per line, which can be read back with [ReadSamples]. Errors of the writer are
+// ignored.
+func WriteJSON(w io.Writer) func(Sample) {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(s Sample) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = enc.Encode(s)
+	}
+}
+
+// Reads the samples written by [WriteJSON].
+func ReadSamples(r io.Reader) ([]Sample, error) {
+	samples := []Sample{}
+	dec := json.NewDecoder(r)
+	for {
+		var s Sample
+		if err := dec.Decode(&s); errors.Is(err, io.EOF) {
+			return samples, nil
+		} else if err != nil {
+			return samples, errors.Join(fmt.Errorf("failed to decode sample %d", len(samples)+1), err)
+		}
+		samples = append(samples, s)
+	}
+}
+
+// Headers ignored by [Compare] if CompareOpts.IgnoreHeaders is nil, since they
+// change on every response.
+var DefaultIgnoreHeaders = []string{
+	"Age", "Content-Length", "Date", "Etag", "Expires", "Last-Modified",
+	"Server-Timing", "Set-Cookie", "X-Request-Id",
+}
+
+type CompareOpts struct {
+	// Headers that aren't compared. Defaults to [DefaultIgnoreHeaders].
+	IgnoreHeaders []string
+	// Normalizes the bodies before they are compared, for example removing
+	// nonces, timestamps or the version of the assets. The content type of the
+	// response is also provided.
+	Normalize func(body []byte, contentType string) []byte
+}
+
+// A difference between the responses of a [Sample].
+type Difference struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+
+	// Status of the primary and candidate responses, if they differ.
+	Status [2]int `json:"status"`
+	// Names of the headers that differ.
+	Headers []string `json:"headers,omitempty"`
+	// If the bodies differ.
+	Body bool `json:"body,omitempty"`
+	// Word-level diff of the bodies, if they differ and are text, which can be
+	// rendered with [history.DiffHTML].
+	BodyDiff []history.DiffOp `json:"body_diff,omitempty"`
+	// Error of the candidate.
+	Err string `json:"err,omitempty"`
+}
+
+func (d Difference) String() string {
+	parts := []string{}
+	if d.Err != "" {
+		parts = append(parts, "candidate failed: "+d.Err)
+	}
+	if d.Status != [2]int{} {
+		parts = append(parts, fmt.Sprintf("status %d != %d", d.Status[0], d.Status[1]))
+	}
+	if len(d.Headers) > 0 {
+		parts = append(parts, "headers "+strings.Join(d.Headers, ", "))
+	}
+	if d.Body {
+		changes := 0
+		for _, op := range d.BodyDiff {
+			if op.Kind != history.DiffEqual {
+				changes++
+			}
+		}
+		if changes > 0 {
+			parts = append(parts, fmt.Sprintf("body (%d changes)", changes))
+		} else {
+			parts = append(parts, "body")
+		}
+	}
+	return fmt.Sprintf("%s %s: %s", d.Method, d.URL, strings.Join(parts, "; "))
+}
+
+// Results of a comparison.
+type Report struct {
+	// Number of compared samples.
+	Samples int `json:"samples"`
+	// Number of samples with matching responses.
+	Matching    int          `json:"matching"`
+	Differences []Difference `json:"differences"`
+}
+
+// Returns the exit code of comparison commands, 1 if any sample has a
+// difference and 0 otherwise.
+func (r Report) ExitCode() int {
+	if len(r.Differences) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// Compares the responses of the primary and candidate pipelines of each sample,
+// reporting the ones with different status, headers or bodies. Samples with
+// truncated bodies only have the compared part of their bodies diffed.
+func Compare(samples []Sample, opts ...CompareOpts) Report {
+	opt := CompareOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.IgnoreHeaders == nil {
+		opt.IgnoreHeaders = DefaultIgnoreHeaders
+	}
+
+	ignored := map[string]bool{}
+	for _, h := range opt.IgnoreHeaders {
+		ignored[http.CanonicalHeaderKey(h)] = true
+	}
+
+	r := Report{Samples: len(samples), Differences: []Difference{}}
+
+	for _, s := range samples {
+		d, ok := compare(s, ignored, opt.Normalize)
+		if !ok {
+			r.Matching++
+			continue
+		}
+		r.Differences = append(r.Differences, d)
+	}
+
+	return r
+}
+
+func compare(s Sample, ignored map[string]bool, normalize func([]byte, string) []byte) (Difference, bool) {
+	a, b := s.Primary, s.Candidate
+	d := Difference{Method: s.Method, URL: s.URL}
+
+	// The response of a candidate that failed isn't complete, so only the error
+	// is reported.
+	if b.Err != "" {
+		d.Err = b.Err
+		return d, true
+	}
+
+	if a.Status != b.Status {
+		d.Status = [2]int{a.Status, b.Status}
+	}
+
+	for _, k := range headerNames(a.Header, b.Header) {
+		if ignored[k] {
+			continue
+		}
+		if !slices.Equal(a.Header.Values(k), b.Header.Values(k)) {
+			d.Headers = append(d.Headers, k)
+		}
+	}
+
+	ab, bb := a.Body, b.Body
+	if a.Truncated || b.Truncated {
+		n := min(len(ab), len(bb))
+		ab, bb = ab[:n], bb[:n]
+	}
+	if normalize != nil {
+		ab = normalize(ab, a.Header.Get("Content-Type"))
+		bb = normalize(bb, b.Header.Get("Content-Type"))
+	}
+	if string(ab) != string(bb) {
+		d.Body = true
+		if text(a.Header.Get("Content-Type")) && text(b.Header.Get("Content-Type")) {
+			d.BodyDiff = history.DiffWords(string(ab), string(bb))
+		}
+	}
+
+	return d, d.Status != [2]int{} || len(d.Headers) > 0 || d.Body
+}
+
+// Returns the canonical names of the headers of both responses, sorted.
+func headerNames(a, b http.Header) []string {
+	names := []string{}
+	for _, h := range []http.Header{a, b} {
+		for k := range h {
+			if k = http.CanonicalHeaderKey(k); !slices.Contains(names, k) {
+				names = append(names, k)
+			}
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Reports if the content type is text, so the body can be diffed by words.
+func text(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(t, "text/") || strings.HasSuffix(t, "+xml") || strings.HasSuffix(t, "+json") ||
+		t == "application/json" || t == "application/xml" || t == "application/javascript"
+}
@@ -0,0 +1,332 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mirror provides the mirroring of a sample of production requests to a
+// second pipeline, such as a blog with a new renderer or theme, recording the
+// responses of both so they can be diffed offline with [Compare]. It's meant to
+// validate major migrations against real traffic before the cutover:
+//
+//	f, _ := os.Create("mirror.jsonl")
+//	blog.Use(mirror.New(candidate, mirror.Opts{Rate: 0.05, OnSample: mirror.WriteJSON(f)}))
+//
+//	// Later, offline:
+//	samples, _ := mirror.ReadSamples(f)
+//	report := mirror.Compare(samples)
+//	for _, d := range report.Differences {
+//		fmt.Println(d)
+//	}
+//
+// Visitors are always served by the primary pipeline, the candidate is called
+// in the background after the response and it's errors and panics are only
+// recorded on the samples.
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-mirror-middleware"
+
+// The mirror plugin, which is a [plugin.Middleware] that mirrors requests to the
+// candidate pipeline and a [plugin.Instrumented] plugin, with the number of
+// mirrored requests and of the ones that were dropped.
+type Plugin interface {
+	plugin.Middleware
+	plugin.Instrumented
+}
+
+// Creates the mirror [Plugin], which sends Opts.Rate of the requests accepted by
+// Opts.Filter to the candidate handler, calling Opts.OnSample with the responses
+// of both pipelines.
+//
+// The candidate shouldn't have side effects, such as writing files or sending
+// webmentions, since it's called with requests of real visitors.
+func New(candidate http.Handler, opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Rate == 0 {
+		opt.Rate = 0.01
+	}
+	if opt.Filter == nil {
+		opt.Filter = DefaultFilter
+	}
+	if opt.Concurrency <= 0 {
+		opt.Concurrency = 4
+	}
+	if opt.MaxBody == 0 {
+		opt.MaxBody = 1 << 20
+	}
+	if opt.Timeout == 0 {
+		opt.Timeout = 10 * time.Second
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(candidate, "Candidate handler should not be nil")
+
+	return &p{
+		candidate: candidate,
+		rate:      opt.Rate,
+		filter:    opt.Filter,
+		sem:       make(chan struct{}, opt.Concurrency),
+		maxBody:   opt.MaxBody,
+		timeout:   opt.Timeout,
+		onSample:  opt.OnSample,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Fraction of the requests accepted by Filter that are mirrored, from 0 to 1.
+	// Defaults to 0.01, so 1% of the requests are mirrored.
+	Rate float64
+	// Reports if the request can be mirrored. Defaults to [DefaultFilter].
+	Filter func(r *http.Request) bool
+	// Max number of requests handled by the candidate at the same time, mirrored
+	// requests above it are dropped. Defaults to 4.
+	Concurrency int
+	// Max size of the bodies of responses recorded on samples, requests with a
+	// larger response on the primary pipeline aren't mirrored. Defaults to 1 MiB.
+	MaxBody int64
+	// Timeout of the candidate handler. Defaults to 10 seconds.
+	Timeout time.Duration
+	// Called with the sample of every mirrored request, from the goroutine of the
+	// candidate, for example to write it to a file with [WriteJSON].
+	OnSample func(Sample)
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+// The default filter of mirrored requests, which only accepts "GET" and "HEAD"
+// requests without the "Authorization" header, so samples don't have private
+// content and the candidate isn't asked to change anything.
+func DefaultFilter(r *http.Request) bool {
+	return (r.Method == http.MethodGet || r.Method == http.MethodHead) &&
+		r.Header.Get("Authorization") == ""
+}
+
+type p struct {
+	candidate http.Handler
+	rate      float64
+	filter    func(r *http.Request) bool
+	sem       chan struct{}
+	maxBody   int64
+	timeout   time.Duration
+	onSample  func(Sample)
+
+	mirrored atomic.Int64
+	dropped  atomic.Int64
+	tooLarge atomic.Int64
+	panics   atomic.Int64
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+// Implements [plugin.Instrumented], with the number of mirrored requests, of
+// requests dropped because the candidate was busy or the response was too
+// large, and of panics of the candidate.
+func (p *p) Metrics() map[string]int64 {
+	return map[string]int64{
+		"mirrored":  p.mirrored.Load(),
+		"dropped":   p.dropped.Load(),
+		"too_large": p.tooLarge.Load(),
+		"panics":    p.panics.Load(),
+	}
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.log)
+
+		if !p.filter(r) || rand.Float64() >= p.rate {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &captureWriter{ResponseWriter: w, max: p.maxBody}
+		start := time.Now()
+		next.ServeHTTP(cw, r)
+
+		primary := cw.response()
+		primary.Elapsed = time.Since(start)
+
+		if cw.overflow {
+			p.tooLarge.Add(1)
+			return
+		}
+
+		select {
+		case p.sem <- struct{}{}:
+		default:
+			p.dropped.Add(1)
+			p.log.Debug("Candidate busy, dropping mirrored request", slog.String("path", r.URL.Path))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+		req := r.Clone(ctx)
+		req.Body = http.NoBody
+
+		sample := Sample{
+			Time:    start,
+			Method:  r.Method,
+			URL:     r.URL.RequestURI(),
+			Host:    r.Host,
+			Primary: primary,
+		}
+
+		go func() {
+			defer func() { <-p.sem }()
+			defer cancel()
+
+			sample.Candidate = p.serveCandidate(req)
+			p.mirrored.Add(1)
+
+			if p.onSample != nil {
+				p.onSample(sample)
+			}
+		}()
+	})
+}
+
+func (p *p) serveCandidate(r *http.Request) (res Response) {
+	w := httptest.NewRecorder()
+	start := time.Now()
+
+	defer func() {
+		if v := recover(); v != nil {
+			p.panics.Add(1)
+			p.log.Error("Candidate panicked on mirrored request",
+				slog.String("path", r.URL.Path), slog.Any("panic", v))
+			res = Response{Err: fmt.Sprintf("panic: %v", v), Elapsed: time.Since(start)}
+		}
+	}()
+
+	p.candidate.ServeHTTP(w, r)
+
+	res = Response{
+		Status:  w.Code,
+		Header:  w.Header().Clone(),
+		Body:    w.Body.Bytes(),
+		Elapsed: time.Since(start),
+	}
+	if err := r.Context().Err(); err != nil {
+		res.Err = err.Error()
+	}
+	if int64(len(res.Body)) > p.maxBody {
+		res.Body = res.Body[:p.maxBody]
+		res.Truncated = true
+	}
+
+	return res
+}
+
+// [http.ResponseWriter] that records the response written to the visitor, up to
+// the max size of the body.
+type captureWriter struct {
+	http.ResponseWriter
+	max int64
+
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *captureWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.header = w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+		w.header = w.Header().Clone()
+		if w.header.Get("Content-Type") == "" && w.header.Get("Transfer-Encoding") == "" {
+			// Same as the server, which would detect it after the handler.
+			w.header.Set("Content-Type", http.DetectContentType(b))
+		}
+	}
+
+	if !w.overflow {
+		if int64(w.body.Len()+len(b)) > w.max {
+			w.overflow = true
+			w.body.Reset()
+		} else {
+			w.body.Write(b)
+		}
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *captureWriter) response() Response {
+	res := Response{Status: w.status, Header: w.header, Body: bytes.Clone(w.body.Bytes())}
+	if res.Status == 0 {
+		res.Status = http.StatusOK
+	}
+	if res.Header == nil {
+		res.Header = w.Header().Clone()
+	}
+	return res
+}
@@ -0,0 +1,301 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mount
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/metadata"
+)
+
+// File system that serves the current file system of each mount.
+type mountFS struct {
+	p *p
+}
+
+// Returns the file system and the name of the file on it. Files that aren't in
+// any mount are served by the root mount, if there's one.
+func (fsys *mountFS) resolve(op, name string) (fs.FS, string, error) {
+	if !fs.ValidPath(name) {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	prefix, rest, _ := strings.Cut(name, "/")
+	if m, ok := fsys.p.mounts[prefix]; ok && prefix != "" {
+		if rest == "" {
+			rest = "."
+		}
+		if cur := m.current(); cur != nil {
+			return cur, rest, nil
+		}
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	if m, ok := fsys.p.mounts[""]; ok {
+		if cur := m.current(); cur != nil {
+			return cur, name, nil
+		}
+	}
+
+	return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+func (fsys *mountFS) Open(name string) (fs.File, error) {
+	if name == "." {
+		return fsys.root()
+	}
+
+	f, sub, err := fsys.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := f.Open(sub)
+	if err != nil {
+		return nil, fixErr(err, name)
+	}
+	if d, ok := file.(fs.ReadDirFile); ok && sub == "." {
+		return &mountDir{ReadDirFile: d, name: name}, nil
+	}
+	return file, nil
+}
+
+// Implements [fs.ReadDirFS], using the fast path of the mounted file systems.
+func (fsys *mountFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name == "." {
+		d, err := fsys.root()
+		if err != nil {
+			return nil, err
+		}
+		defer d.Close()
+		return d.ReadDir(-1)
+	}
+
+	f, sub, err := fsys.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	es, err := fs.ReadDir(f, sub)
+	return es, fixErr(err, name)
+}
+
+// Implements [fs.ReadFileFS], using the fast path of the mounted file systems.
+func (fsys *mountFS) ReadFile(name string) ([]byte, error) {
+	f, sub, err := fsys.resolve("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := fs.ReadFile(f, sub)
+	return b, fixErr(err, name)
+}
+
+// Implements [fs.StatFS], using the fast path of the mounted file systems.
+func (fsys *mountFS) Stat(name string) (fs.FileInfo, error) {
+	if name == "." {
+		d, err := fsys.root()
+		if err != nil {
+			return nil, err
+		}
+		defer d.Close()
+		return d.Stat()
+	}
+
+	f, sub, err := fsys.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := fs.Stat(f, sub)
+	if err == nil && sub == "." {
+		info = dirInfo{name: path.Base(name), modTime: info.ModTime()}
+	}
+	return info, fixErr(err, name)
+}
+
+func (fsys *mountFS) Metadata() metadata.Metadata {
+	if m, ok := fsys.p.mounts[""]; ok {
+		if m, err := metadata.GetMetadata(m.current()); err == nil {
+			return m
+		}
+	}
+	return metadata.Map(map[string]any{})
+}
+
+// Returns the root directory, with the entries of the root mount and a
+// directory for each of the other mounts that have a file system.
+func (fsys *mountFS) root() (*rootDir, error) {
+	d := &rootDir{info: dirInfo{name: "."}}
+
+	if m, ok := fsys.p.mounts[""]; ok && m.current() != nil {
+		f, err := m.current().Open(".")
+		if err != nil {
+			return nil, err
+		}
+		rd, ok := f.(fs.ReadDirFile)
+		if !ok {
+			_ = f.Close()
+			return nil, &fs.PathError{Op: "readdir", Path: ".", Err: errors.New("not implemented")}
+		}
+		d.file = rd
+
+		es, err := rd.ReadDir(-1)
+		if err != nil && !errors.Is(err, io.EOF) {
+			_ = f.Close()
+			return nil, err
+		}
+		for _, e := range es {
+			// Mounts shadow the directories of the root mount with the same name.
+			if _, ok := fsys.p.mounts[e.Name()]; !ok {
+				d.entries = append(d.entries, e)
+			}
+		}
+		if info, err := rd.Stat(); err == nil {
+			d.info.modTime = info.ModTime()
+		}
+	}
+
+	for prefix, m := range fsys.p.mounts {
+		if prefix == "" || m.current() == nil {
+			continue
+		}
+		info := dirInfo{name: prefix}
+		if i, err := fs.Stat(m.current(), "."); err == nil {
+			info.modTime = i.ModTime()
+		}
+		d.entries = append(d.entries, fs.FileInfoToDirEntry(info))
+	}
+
+	slices.SortFunc(d.entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	return d, nil
+}
+
+// Root directory of the mounts. Implements [metadata.WithMetadata] with the
+// metadata of the root directory of the root mount.
+type rootDir struct {
+	info    dirInfo
+	file    fs.ReadDirFile
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *rootDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *rootDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: ".", Err: errors.New("is a directory")}
+}
+
+func (d *rootDir) Close() error {
+	if d.file != nil {
+		return d.file.Close()
+	}
+	return nil
+}
+
+func (d *rootDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return slices.Clone(rest), nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(rest))
+	d.offset += n
+	return slices.Clone(rest[:n]), nil
+}
+
+func (d *rootDir) Metadata() metadata.Metadata {
+	if d.file != nil {
+		if m, err := metadata.GetMetadata(d.file); err == nil {
+			return m
+		}
+	}
+	return metadata.Map(map[string]any{})
+}
+
+// Root directory of a mount, named after it's prefix instead of ".".
+type mountDir struct {
+	fs.ReadDirFile
+	name string
+}
+
+func (d *mountDir) Stat() (fs.FileInfo, error) {
+	info, err := d.ReadDirFile.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return dirInfo{name: d.name, modTime: info.ModTime()}, nil
+}
+
+func (d *mountDir) Metadata() metadata.Metadata {
+	if m, err := metadata.GetMetadata(d.ReadDirFile); err == nil {
+		return m
+	}
+	return metadata.Map(map[string]any{})
+}
+
+type dirInfo struct {
+	name    string
+	modTime time.Time
+}
+
+func (i dirInfo) Name() string {
+	return i.name
+}
+
+func (i dirInfo) Size() int64 {
+	return 0
+}
+
+func (i dirInfo) Mode() fs.FileMode {
+	return fs.ModeDir | 0o555
+}
+
+func (i dirInfo) ModTime() time.Time {
+	return i.modTime
+}
+
+func (i dirInfo) IsDir() bool {
+	return true
+}
+
+func (i dirInfo) Sys() any {
+	return nil
+}
+
+// Replaces the path of errors of the mounted file systems with the name on the
+// mount file system, like [fs.Sub].
+func fixErr(err error, name string) error {
+	var e *fs.PathError
+	if errors.As(err, &e) {
+		return &fs.PathError{Op: e.Op, Path: name, Err: e.Err}
+	}
+	return err
+}
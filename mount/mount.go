@@ -0,0 +1,287 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mount provides a [plugin.Sourcer] that mounts the file systems of
+// other sourcers at prefixes, such as a repository of documentation at "docs"
+// and one of posts at "blog", so a single server serves both. Each mount can be
+// scoped to a directory of it's file system, like [fs.Sub], and is refreshed
+// independently of the others, with [(Sourcer).Refresh] or on it's own schedule
+// with the jobs of [(Sourcer).Jobs].
+//
+// Mounted file systems are joined as they are, so plugins that wrap file systems,
+// such as the frontmatter plugin, should wrap the mount sourcer, so the paths
+// of their files include the prefixes:
+//
+//	mounts := mount.New([]mount.Mount{
+//		{Prefix: "docs", Sourcer: docs, Dir: "content", Refresh: schedule.Every(time.Minute)},
+//		{Prefix: "blog", Sourcer: blog},
+//	})
+//	b.Use(frontmatter.New(mounts))
+//
+//	s := schedule.New(mounts.Jobs())
+//	go s.Run(ctx)
+package mount
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/schedule"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-mount-sourcer"
+
+// Returned by [(Sourcer).Refresh] if there isn't a mount with the prefix.
+var ErrUnknownMount = errors.New("unknown mount")
+
+// A file system mounted by the [Sourcer].
+type Mount struct {
+	// Name of the directory where the file system is mounted, such as "docs".
+	// Prefixes are a single path element, leading and trailing slashes are
+	// ignored. The empty prefix mounts the file system at the root, serving
+	// the files that aren't in any other mount.
+	Prefix  string
+	Sourcer plugin.Sourcer
+	// Directory of the file system that is mounted, instead of it's root.
+	Dir string
+	// Schedule of the refreshes of the mount, see [(Sourcer).Jobs]. Mounts
+	// without a schedule are only refreshed with [(Sourcer).Refresh].
+	Refresh schedule.Schedule
+}
+
+// The mount [plugin.Sourcer], which file system always serves the current file
+// system of each mount, so refreshes take effect without sourcing again.
+type Sourcer interface {
+	plugin.Sourcer
+	plugin.Instrumented
+	// Sources the file system of the mount with the prefix again, swapping it
+	// in if it succeeds. The previous file system is kept if it fails. Returns
+	// [ErrUnknownMount] if there isn't a mount with the prefix.
+	Refresh(prefix string) error
+	// Returns a [schedule.Job] for each mount with a Refresh schedule, named
+	// "refresh:" followed by the path of the mount, such as "refresh:/docs".
+	Jobs() []schedule.Job
+}
+
+// Creates the mount [Sourcer]. Mounts with invalid or duplicated prefixes are
+// logged and ignored. Source sources the mounts that weren't sourced yet, and
+// returns a error if any of them fails, unless Opts.SkipOnSourceError is set,
+// in which case the mount serves no files until it's refreshed.
+func New(mounts []Mount, opts ...Opts) Sourcer {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	p := &p{
+		mounts: map[string]*mount{},
+
+		skipOnSourceError: opt.SkipOnSourceError,
+		onRefresh:         opt.OnRefresh,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+
+	for _, m := range mounts {
+		opt.Assertions.NotNil(m.Sourcer, "Sourcer of mount should not be nil")
+
+		prefix := strings.Trim(m.Prefix, "/")
+		log := p.log.With(slog.String("prefix", prefix))
+
+		if m.Sourcer == nil || prefix != "" && (!fs.ValidPath(prefix) || strings.Contains(prefix, "/")) {
+			log.Error("Invalid mount, skipping it")
+			continue
+		}
+		if _, ok := p.mounts[prefix]; ok {
+			log.Error("Duplicated prefix, skipping mount")
+			continue
+		}
+		if m.Dir != "" && !fs.ValidPath(m.Dir) {
+			log.Error("Invalid directory of mount, skipping it", slog.String("dir", m.Dir))
+			continue
+		}
+
+		p.mounts[prefix] = &mount{
+			prefix:   prefix,
+			sourcer:  m.Sourcer,
+			dir:      m.Dir,
+			schedule: m.Refresh,
+		}
+	}
+
+	return p
+}
+
+type Opts struct {
+	// Serve the other mounts if one fails to be sourced, instead of returning
+	// a error on Source.
+	SkipOnSourceError bool
+	// Called after each refresh of a mount, with the error if it failed. Useful
+	// to invalidate the cached renders of the server.
+	OnRefresh func(prefix string, err error)
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	mounts map[string]*mount
+
+	skipOnSourceError bool
+	onRefresh         func(prefix string, err error)
+
+	refreshes atomic.Int64
+	failures  atomic.Int64
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+type mount struct {
+	prefix   string
+	sourcer  plugin.Sourcer
+	dir      string
+	schedule schedule.Schedule
+
+	// Serializes refreshes, so a slow source doesn't swap out a newer one.
+	refreshMu sync.Mutex
+
+	mu   sync.RWMutex
+	fsys fs.FS
+}
+
+func (m *mount) current() fs.FS {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.fsys
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+func (p *p) Source() (fs.FS, error) {
+	p.assert.NotNil(p.mounts)
+	p.assert.NotNil(p.log)
+
+	errs := []error{}
+	for _, prefix := range slices.Sorted(maps.Keys(p.mounts)) {
+		m := p.mounts[prefix]
+		if m.current() != nil {
+			continue
+		}
+		if err := p.refresh(m); err != nil {
+			errs = append(errs, fmt.Errorf("mount %q: %w", "/"+prefix, err))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		if !p.skipOnSourceError {
+			return nil, errors.Join(errors.New("failed to source mounts"), err)
+		}
+		p.log.Warn("Failed to source mounts, skipping them", slog.String("err", err.Error()))
+	}
+
+	return &mountFS{p: p}, nil
+}
+
+func (p *p) Refresh(prefix string) error {
+	m, ok := p.mounts[strings.Trim(prefix, "/")]
+	if !ok {
+		return ErrUnknownMount
+	}
+	return p.refresh(m)
+}
+
+func (p *p) refresh(m *mount) error {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+
+	log := p.log.With(slog.String("prefix", m.prefix), slog.String("sourcer", m.sourcer.Name()))
+	log.Debug("Refreshing mount")
+
+	p.refreshes.Add(1)
+
+	fsys, err := m.sourcer.Source()
+	if err == nil && m.dir != "" {
+		fsys, err = fs.Sub(fsys, m.dir)
+	}
+	if err != nil {
+		p.failures.Add(1)
+		log.Warn("Failed to refresh mount, keeping current file system", slog.String("err", err.Error()))
+
+		err = errors.Join(errors.New("failed to source file system"), err)
+		if p.onRefresh != nil {
+			p.onRefresh(m.prefix, err)
+		}
+		return err
+	}
+
+	m.mu.Lock()
+	m.fsys = fsys
+	m.mu.Unlock()
+
+	log.Debug("Mount refreshed")
+
+	if p.onRefresh != nil {
+		p.onRefresh(m.prefix, nil)
+	}
+
+	return nil
+}
+
+func (p *p) Jobs() []schedule.Job {
+	jobs := []schedule.Job{}
+	for _, prefix := range slices.Sorted(maps.Keys(p.mounts)) {
+		m := p.mounts[prefix]
+		if m.schedule == nil {
+			continue
+		}
+		jobs = append(jobs, schedule.Job{
+			Name:     "refresh:/" + prefix,
+			Schedule: m.schedule,
+			Run: func(ctx context.Context) error {
+				return p.refresh(m)
+			},
+		})
+	}
+	return jobs
+}
+
+func (p *p) Metrics() map[string]int64 {
+	return map[string]int64{
+		"refreshes": p.refreshes.Load(),
+		"failures":  p.failures.Load(),
+	}
+}
@@ -0,0 +1,527 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/auth"
+	"forge.capytal.company/loreddev/blogo/httpclient"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const loginPluginName = "blogo-oidc-login-middleware"
+
+// Creates a [plugin.Middleware] that logs users in with the OpenID provider of
+// the issuer URL, through the authorization code flow, keeping their identity on
+// the sessions. Protected endpoints, such as the admin package ones, should then
+// use the sessions as their authenticator.
+//
+// The middleware serves, on Opts.Path:
+//
+//   - GET "<path>/login?to=PATH" redirects to the provider, and back to the path
+//     of the blog after the login;
+//   - GET "<path>/callback" finishes the login, redirect there by the provider,
+//     and should be registered as the redirect URL of the client;
+//   - POST "<path>/logout" removes the session.
+//
+// Logins are bound to the browser that started them by a [auth.StateCookie],
+// and at most 1024 of them can wait for the callback at once.
+//
+// Identities have the "sub" claim of the user as subject, their name, username
+// or email as name, and Opts.Scopes with the scopes of their groups (see
+// Opts.Groups).
+func NewLogin(
+	sessions auth.Sessions,
+	issuer, clientID, clientSecret string,
+	opts ...LoginOpts,
+) plugin.Middleware {
+	opt := LoginOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Path == "" {
+		opt.Path = "/.blogo/oidc"
+	}
+	if opt.OAuthScopes == nil {
+		opt.OAuthScopes = []string{"openid", "profile", "email"}
+	}
+	if !slices.Contains(opt.OAuthScopes, "openid") {
+		opt.OAuthScopes = append([]string{"openid"}, opt.OAuthScopes...)
+	}
+	if opt.Scopes == nil {
+		opt.Scopes = []string{}
+	}
+	if opt.GroupsClaim == "" {
+		opt.GroupsClaim = "groups"
+	}
+	injectHTTPClient := opt.HTTPClient == nil
+	if opt.HTTPClient == nil {
+		opt.HTTPClient = httpclient.Default()
+	}
+	if opt.Timeout == 0 {
+		opt.Timeout = 10 * time.Second
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sessions, "Sessions should not be nil")
+	opt.Assertions.NotZero(issuer, "Issuer should not be empty")
+	opt.Assertions.NotZero(clientID, "Client ID should not be empty")
+
+	return &login{
+		sessions: sessions,
+
+		issuer:       issuer,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+
+		path:        "/" + strings.Trim(opt.Path, "/"),
+		redirect:    opt.RedirectURL,
+		oauthScopes: opt.OAuthScopes,
+		scopes:      opt.Scopes,
+		groupsClaim: opt.GroupsClaim,
+		groups:      opt.Groups,
+		domains:     opt.Domains,
+		allow:       opt.Allow,
+
+		client:  opt.HTTPClient,
+		timeout: opt.Timeout,
+
+		pending: map[string]pendingLogin{},
+
+		injectLogger:     injectLogger,
+		injectHTTPClient: injectHTTPClient,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type LoginOpts struct {
+	// Path of the login endpoints. Defaults to "/.blogo/oidc".
+	Path string
+	// URL of the callback endpoint, as registered on the provider, such as
+	// "https://example.com/.blogo/oidc/callback". Defaults to the callback
+	// endpoint on the host of the login request, over HTTPS.
+	RedirectURL string
+
+	// Scopes requested to the provider. Defaults to "openid", "profile" and
+	// "email", with "openid" always being requested.
+	OAuthScopes []string
+
+	// Scopes of the identities of all users that log in. Defaults to none, so
+	// users are only authenticated, such as for members-only content.
+	Scopes []string
+	// Claim with the groups of the user. Defaults to "groups".
+	GroupsClaim string
+	// Additional scopes of the identities of users on each group, such as
+	// "editors": {"admin"}.
+	Groups map[string][]string
+
+	// Email domains of the users allowed to log in, such as "example.com". Users
+	// need a verified email on one of the domains. Defaults to allowing all users
+	// of the provider.
+	Domains []string
+	// Reports if the user of the claims is allowed to log in, called after
+	// checking Opts.Domains. Defaults to allowing all users.
+	Allow func(Claims) bool
+
+	// Client used to call the endpoints of the provider. Defaults to
+	// [httpclient.Default].
+	HTTPClient *http.Client
+	// Timeout of each request to the endpoints of the provider. Defaults to 10
+	// seconds.
+	Timeout time.Duration
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type login struct {
+	sessions auth.Sessions
+
+	issuer       string
+	clientID     string
+	clientSecret string
+
+	path        string
+	redirect    string
+	oauthScopes []string
+	scopes      []string
+	groupsClaim string
+	groups      map[string][]string
+	domains     []string
+	allow       func(Claims) bool
+
+	client  *http.Client
+	timeout time.Duration
+
+	providerMu sync.Mutex
+	provider   *Provider
+
+	pendingMu sync.Mutex
+	pending   map[string]pendingLogin
+
+	injectLogger     bool
+	injectHTTPClient bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+// A login that was started and waits for the callback, by it's state.
+type pendingLogin struct {
+	nonce    string
+	verifier string
+	redirect string
+	to       string
+	expires  time.Time
+}
+
+// Time that users have to finish logging in.
+const pendingLoginTimeout = 10 * time.Minute
+
+// Max number of logins waiting for the callback, since they are started by
+// unauthenticated requests.
+const maxPendingLogins = 1024
+
+// Returns the cookie that binds the state of logins to the browser that started
+// them, sent over HTTPS if the callback is.
+func (l *login) stateCookie(redirect string) auth.StateCookie {
+	return auth.StateCookie{
+		Name:     "blogo-oidc-state",
+		Path:     l.path,
+		MaxAge:   pendingLoginTimeout,
+		Insecure: !strings.HasPrefix(redirect, "https://"),
+	}
+}
+
+func (l *login) Name() string {
+	return loginPluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (l *login) SetLogger(logger *slog.Logger) {
+	if l.injectLogger {
+		l.log = logger
+	}
+}
+
+// Implements [plugin.HTTPClientAware], using the client of the engine if no
+// client was provided on construction.
+func (l *login) SetHTTPClient(client *http.Client) {
+	if l.injectHTTPClient {
+		l.client = client
+	}
+}
+
+func (l *login) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.assert.NotNil(l.sessions)
+		l.assert.NotNil(l.log)
+
+		if !strings.HasPrefix(r.URL.Path, l.path+"/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+
+		switch strings.TrimPrefix(r.URL.Path, l.path) {
+		case "/login":
+			l.serveLogin(w, r)
+		case "/callback":
+			l.serveCallback(w, r)
+		case "/logout":
+			if r.Method != http.MethodPost {
+				w.Header().Set("Allow", "POST")
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			l.sessions.Logout(w)
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// Returns the endpoints of the provider, discovering them on the first call.
+func (l *login) endpoints(ctx context.Context) (Provider, error) {
+	l.providerMu.Lock()
+	defer l.providerMu.Unlock()
+
+	if l.provider != nil {
+		return *l.provider, nil
+	}
+
+	p, err := Discover(ctx, l.client, l.issuer)
+	if err != nil {
+		return Provider{}, err
+	}
+	l.provider = &p
+
+	return p, nil
+}
+
+func (l *login) serveLogin(w http.ResponseWriter, r *http.Request) {
+	to := r.URL.Query().Get("to")
+	if !strings.HasPrefix(to, "/") || strings.HasPrefix(to, "//") {
+		to = "/"
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), l.timeout)
+	defer cancel()
+
+	p, err := l.endpoints(ctx)
+	if err != nil {
+		l.log.Warn("Failed to discover OpenID provider", slog.String("err", err.Error()))
+		http.Error(w, "Failed to reach the identity provider", http.StatusBadGateway)
+		return
+	}
+
+	u, err := url.Parse(p.Authorization)
+	if err != nil {
+		l.log.Warn("Invalid authorization endpoint", slog.String("err", err.Error()))
+		http.Error(w, "Invalid authorization endpoint of the identity provider", http.StatusBadGateway)
+		return
+	}
+
+	redirect := l.redirect
+	if redirect == "" {
+		redirect = "https://" + r.Host + l.path + "/callback"
+	}
+
+	state, nonce, verifier := randomString(), randomString(), randomString()
+	challenge := sha256.Sum256([]byte(verifier))
+
+	l.pendingMu.Lock()
+	now := time.Now()
+	for s, p := range l.pending {
+		if now.After(p.expires) {
+			delete(l.pending, s)
+		}
+	}
+	if len(l.pending) >= maxPendingLogins {
+		l.pendingMu.Unlock()
+		l.log.Warn("Too many pending logins, rejecting login")
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "Too many logins in progress, try again later", http.StatusServiceUnavailable)
+		return
+	}
+	l.pending[state] = pendingLogin{
+		nonce:    nonce,
+		verifier: verifier,
+		redirect: redirect,
+		to:       to,
+		expires:  now.Add(pendingLoginTimeout),
+	}
+	l.pendingMu.Unlock()
+
+	l.stateCookie(redirect).Set(w, state)
+
+	v := u.Query()
+	v.Set("response_type", "code")
+	v.Set("client_id", l.clientID)
+	v.Set("redirect_uri", redirect)
+	v.Set("scope", strings.Join(l.oauthScopes, " "))
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+	v.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	v.Set("code_challenge_method", "S256")
+	u.RawQuery = v.Encode()
+
+	l.log.Debug("Redirecting to authorization endpoint")
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func (l *login) serveCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	l.pendingMu.Lock()
+	p, ok := l.pending[q.Get("state")]
+	delete(l.pending, q.Get("state"))
+	l.pendingMu.Unlock()
+
+	if !ok || time.Now().After(p.expires) {
+		http.Error(w, "Unknown or expired login, try again", http.StatusBadRequest)
+		return
+	}
+
+	// The login should be finished on the same browser that started it.
+	if !l.stateCookie(p.redirect).Verify(w, r, q.Get("state")) {
+		l.log.Warn("State of callback isn't of the browser that started the login")
+		http.Error(w, "Login wasn't started on this browser, try again", http.StatusBadRequest)
+		return
+	}
+
+	if e := q.Get("error"); e != "" {
+		l.log.Debug("Login denied", slog.String("error", e))
+		http.Error(w, "Login denied", http.StatusForbidden)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), l.timeout)
+	defer cancel()
+
+	provider, err := l.endpoints(ctx)
+	if err != nil {
+		l.log.Warn("Failed to discover OpenID provider", slog.String("err", err.Error()))
+		http.Error(w, "Failed to reach the identity provider", http.StatusBadGateway)
+		return
+	}
+	if iss := q.Get("iss"); iss != "" && iss != provider.Issuer {
+		l.log.Warn("Issuer of callback doesn't match", slog.String("iss", iss))
+		http.Error(w, "Invalid issuer", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := l.redeem(ctx, provider, p, q.Get("code"))
+	if err != nil {
+		l.log.Warn("Failed to redeem authorization code", slog.String("err", err.Error()))
+		http.Error(w, "Failed to verify login", http.StatusBadGateway)
+		return
+	}
+
+	log := l.log.With(slog.String("sub", claims.String("sub")))
+
+	if !l.allowed(claims) {
+		log.Warn("User not allowed to log in", slog.String("email", claims.String("email")))
+		http.Error(w, "User not allowed", http.StatusForbidden)
+		return
+	}
+
+	if err := l.sessions.Login(w, l.identity(claims)); err != nil {
+		log.Error("Failed to create session", slog.String("err", err.Error()))
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("User logged in")
+
+	http.Redirect(w, r, p.to, http.StatusSeeOther)
+}
+
+// Redeems the authorization code on the token endpoint, returning the claims of
+// the ID token.
+func (l *login) redeem(ctx context.Context, provider Provider, p pendingLogin, code string) (Claims, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirect},
+		"code_verifier": {p.verifier},
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, provider.Token, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to create request"), err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(l.clientID), url.QueryEscape(l.clientSecret))
+
+	res, err := l.client.Do(req)
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to send request"), err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected response status %q of token endpoint", res.Status)
+	}
+
+	var body struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&body); err != nil {
+		return nil, errors.Join(errors.New("failed to decode response"), err)
+	}
+	if body.IDToken == "" {
+		return nil, errors.New("token endpoint didn't return a ID token")
+	}
+
+	return parseIDToken(body.IDToken, provider.Issuer, l.clientID, p.nonce, time.Now())
+}
+
+func (l *login) allowed(c Claims) bool {
+	if len(l.domains) > 0 {
+		// Unverified emails could be set to any address by the user.
+		if v, ok := c["email_verified"].(bool); !ok || !v {
+			return false
+		}
+		_, domain, _ := strings.Cut(c.String("email"), "@")
+		if !slices.ContainsFunc(l.domains, func(d string) bool { return strings.EqualFold(d, domain) }) {
+			return false
+		}
+	}
+	if l.allow != nil && !l.allow(c) {
+		return false
+	}
+	return true
+}
+
+func (l *login) identity(c Claims) auth.Identity {
+	name := c.String("name")
+	if name == "" {
+		name = c.String("preferred_username")
+	}
+	if name == "" {
+		name = c.String("email")
+	}
+
+	scopes := slices.Clone(l.scopes)
+	for _, g := range c.Strings(l.groupsClaim) {
+		for _, s := range l.groups[g] {
+			if !slices.Contains(scopes, s) {
+				scopes = append(scopes, s)
+			}
+		}
+	}
+
+	return auth.Identity{Subject: c.String("sub"), Name: name, Scopes: scopes}
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
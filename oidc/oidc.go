@@ -0,0 +1,162 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package oidc provides the OpenID Connect (https://openid.net/connect) login
+// of protected areas of the blog, such as members-only sections and the admin
+// package endpoints, so they can use the existing identity provider of a
+// organization. Users that log in are kept logged in with [auth.Sessions].
+package oidc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/httpclient"
+)
+
+// Endpoints of a OpenID provider, from it's discovery document.
+type Provider struct {
+	Issuer        string `json:"issuer"`
+	Authorization string `json:"authorization_endpoint"`
+	Token         string `json:"token_endpoint"`
+	UserInfo      string `json:"userinfo_endpoint"`
+}
+
+// Discovers the endpoints of the OpenID provider of the issuer URL, from it's
+// "/.well-known/openid-configuration" document
+// (https://openid.net/specs/openid-connect-discovery-1_0.html).
+func Discover(ctx context.Context, client *http.Client, issuer string) (Provider, error) {
+	if client == nil {
+		client = httpclient.Default()
+	}
+
+	u := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return Provider{}, errors.Join(errors.New("failed to create request"), err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return Provider{}, errors.Join(errors.New("failed to send request"), err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return Provider{}, fmt.Errorf("unexpected response status %q of discovery document", res.Status)
+	}
+
+	var p Provider
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&p); err != nil {
+		return Provider{}, errors.Join(errors.New("failed to decode discovery document"), err)
+	}
+
+	if p.Issuer != strings.TrimSuffix(issuer, "/") && p.Issuer != issuer {
+		return Provider{}, fmt.Errorf("issuer %q of discovery document doesn't match %q", p.Issuer, issuer)
+	}
+	if p.Authorization == "" || p.Token == "" {
+		return Provider{}, errors.New("discovery document doesn't have authorization and token endpoints")
+	}
+
+	return p, nil
+}
+
+// Claims of the ID token of a user, such as "sub", "email" and "groups".
+type Claims map[string]any
+
+// Returns the string value of the claim, or a empty string if it isn't one.
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Returns the string values of the claim, which can be a list or a single
+// string, such as "aud" and "groups".
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		s := make([]string, 0, len(v))
+		for _, e := range v {
+			if e, ok := e.(string); ok {
+				s = append(s, e)
+			}
+		}
+		return s
+	default:
+		return []string{}
+	}
+}
+
+// Returns the time of a numeric date claim, such as "exp".
+func (c Claims) Time(name string) (time.Time, bool) {
+	f, ok := c[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+// Decodes and validates the claims of the ID token.
+//
+// The signature of the token isn't verified: it is only used when received
+// directly from the token endpoint of the provider, over TLS, which the
+// specification allows to be used in place of the signature
+// (https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation).
+func parseIDToken(token, issuer, clientID, nonce string, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed ID token")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to decode ID token"), err)
+	}
+
+	var c Claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, errors.Join(errors.New("failed to decode ID token claims"), err)
+	}
+
+	if c.String("iss") != issuer {
+		return nil, fmt.Errorf("issuer %q of ID token doesn't match %q", c.String("iss"), issuer)
+	}
+	if !slices.Contains(c.Strings("aud"), clientID) {
+		return nil, errors.New("ID token isn't for this client")
+	}
+	if exp, ok := c.Time("exp"); !ok || now.After(exp) {
+		return nil, errors.New("ID token expired")
+	}
+	if c.String("nonce") != nonce {
+		return nil, errors.New("nonce of ID token doesn't match")
+	}
+	if c.String("sub") == "" {
+		return nil, errors.New("ID token doesn't have a subject")
+	}
+
+	return c, nil
+}
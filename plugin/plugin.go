@@ -16,8 +16,11 @@
 package plugin
 
 import (
+	"context"
 	"io"
 	"io/fs"
+	"log/slog"
+	"net/http"
 )
 
 type Plugin interface {
@@ -34,12 +37,173 @@ type Renderer interface {
 	Render(src fs.File, out io.Writer) error
 }
 
+// Renderers that accept a context, which carries the deadline of the render and
+// is canceled when the request is, so long renders can be aborted. Implementations
+// should return the error of the context if it's done before the render finishes.
+type ContextRenderer interface {
+	Renderer
+	RenderContext(ctx context.Context, src fs.File, out io.Writer) error
+}
+
+// Renderers that are expensive to initialize, such as ones that parse templates,
+// compile WASM modules or load image codecs, and whose instances can't be used by
+// concurrent renders. Instead of initializing on each render, the default engine
+// keeps a pool of instances created by NewRenderer, initializing the first ones
+// on Init, see NewRendererPool of the core package.
+type ExpensiveRenderer interface {
+	Plugin
+	// Creates a initialized instance of the renderer, which is only used by one
+	// render at a time.
+	NewRenderer(ctx context.Context) (Renderer, error)
+}
+
+// Renderers that set the status code and headers of the response of the files
+// they render, such as redirects or "410 Gone" for expired posts, since renders
+// are otherwise responded with "200 OK". The status and headers need to be set
+// before anything is written to out.
+type ResponseRenderer interface {
+	Renderer
+	RenderResponse(ctx context.Context, src fs.File, res Response, out io.Writer) error
+}
+
+// Status code and headers of the response of a render, see [ResponseRenderer].
+type Response interface {
+	Header() http.Header
+	SetStatus(code int)
+}
+
+type responseKey struct{}
+
+// Returns a context that carries the response of the render, used by
+// [RenderContext] to call [ResponseRenderer]s. Set by the default server.
+func WithResponse(ctx context.Context, res Response) context.Context {
+	return context.WithValue(ctx, responseKey{}, res)
+}
+
+// Returns the response set on the context by [WithResponse].
+func ResponseFromContext(ctx context.Context) (Response, bool) {
+	res, ok := ctx.Value(responseKey{}).(Response)
+	return res, ok && res != nil
+}
+
+// Renders the file with the renderer, using RenderResponse if it implements
+// [ResponseRenderer] or RenderContext if it implements [ContextRenderer], so
+// wrapping renderers can forward the context and response. If the context has
+// no response (see [WithResponse]), the status and headers set by
+// ResponseRenderers are discarded.
+func RenderContext(ctx context.Context, r Renderer, src fs.File, out io.Writer) error {
+	if r, ok := r.(ResponseRenderer); ok {
+		res, ok := ResponseFromContext(ctx)
+		if !ok {
+			res = &discardResponse{header: http.Header{}}
+		}
+		return r.RenderResponse(ctx, src, res, out)
+	}
+	if r, ok := r.(ContextRenderer); ok {
+		return r.RenderContext(ctx, src, out)
+	}
+	return r.Render(src, out)
+}
+
+type discardResponse struct {
+	header http.Header
+}
+
+func (r *discardResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *discardResponse) SetStatus(int) {}
+
 type Sourcer interface {
 	Plugin
 	Source() (fs.FS, error)
 }
 
+// Information of sourced files that carry headers for the responses of the
+// files, such as the Content-Type and Cache-Control of the objects of a bucket,
+// returned by the Stat method of the files. The default server sets the headers
+// on the response before rendering the file, so renderers can still override
+// them (see [ResponseRenderer]).
+//
+// File systems that wrap files should keep the information of the files they
+// wrap, so the headers aren't lost.
+type FileInfo interface {
+	fs.FileInfo
+	Header() http.Header
+}
+
+// Sourcers that can write files back to where they source them from, such as a
+// directory, a git repository or a bucket, used by plugins that publish content,
+// such as Micropub endpoints and editors.
+//
+// Names are paths of the file system returned by Source, valid by [fs.ValidPath].
+// Changes may only be seen on the next call to Source. Implemented by the local
+// sourcer of the plugins package and the gitea sourcer.
+type WritableSourcer interface {
+	Sourcer
+	// Creates the file with the contents, returning a error that wraps
+	// [fs.ErrExist] if it already exists.
+	Create(ctx context.Context, name string, data []byte) error
+	// Replaces the contents of the file, returning a error that wraps
+	// [fs.ErrNotExist] if it doesn't exist.
+	Update(ctx context.Context, name string, data []byte) error
+	// Deletes the file, returning a error that wraps [fs.ErrNotExist] if it
+	// doesn't exist.
+	Delete(ctx context.Context, name string) error
+}
+
+// Plugins that handle the errors of the engine, such as files not found or
+// failed renders.
+//
+// Handle reports if the error was handled, and can return a recovery value which
+// tells the engine how to continue serving the request. The default server
+// accepts the values of core.RecoverWithSourcer, core.RecoverWithRenderer and
+// core.RecoverWithResponse.
 type ErrorHandler interface {
 	Plugin
 	Handle(error) (recovr any, handled bool)
 }
+
+// Plugins that wrap the [http.Handler] of the engine, so they can add endpoints,
+// or change requests and responses before and after they reach the core server.
+//
+// Middlewares should call next for any request they don't handle.
+type Middleware interface {
+	Plugin
+	Middleware(next http.Handler) http.Handler
+}
+
+// Plugins that accept the logger of the engine, so they log through the sink
+// configured by the host. The default engine calls SetLogger when the plugin is
+// added, with a logger that has the name of the plugin as the "plugin" attribute.
+//
+// Implementations should keep using the logger provided on their construction,
+// if any, ignoring the logger of the engine.
+type LoggerAware interface {
+	Plugin
+	SetLogger(*slog.Logger)
+}
+
+// Plugins that make requests to remote servers and accept the HTTP client of the
+// engine, so hosts control the egress of all plugins in one place (see the
+// httpclient package). The default engine calls SetHTTPClient when the plugin
+// is added, if a client was provided to it.
+//
+// Implementations should keep using the client provided on their construction,
+// if any, ignoring the client of the engine.
+type HTTPClientAware interface {
+	Plugin
+	SetHTTPClient(*http.Client)
+}
+
+// Plugins that expose internal counters, such as calls to a API, fetched bytes
+// or cache hits, so hosts can monitor them. The default engine registers them
+// on the metrics registry of the core server, see ServerOpts.Metrics of the
+// core package.
+type Instrumented interface {
+	Plugin
+	// Returns the current values of the counters, by their name, such as
+	// "api_calls". Counters should only increase.
+	Metrics() map[string]int64
+}
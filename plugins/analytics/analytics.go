@@ -0,0 +1,245 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package analytics provides a privacy-friendly server-side analytics
+// [plugin.Middleware], which records page views without any client-side scripts
+// or cookies and sends them to a [Sink].
+//
+// Visitors are never identified directly: the IP address and User-Agent of
+// requests are hashed together with a salt that rotates daily, so page views
+// of the same visitor can only be correlated in the same day.
+package analytics
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/plugins/variant"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-analytics-middleware"
+
+// A page view recorded by the analytics middleware.
+type Event struct {
+	Time time.Time
+	// Host of the request.
+	Host string
+	// Path of the request, without the query.
+	Path string
+	// Referrer of the request, without it's query, if any.
+	Referrer string
+	// Hash of the visitor's IP address and User-Agent with a daily salt.
+	VisitorHash string
+	// Variants of the A/B experiments served on the request, by experiment, if
+	// the variant plugin is used.
+	Variants variant.Variants
+}
+
+// Destination of the recorded [Event]s, such as a analytics service or a local
+// database.
+type Sink interface {
+	Record(ctx context.Context, e Event) error
+}
+
+// Type adapter to allow the use of ordinary functions as [Sink] implementations.
+type SinkFunc func(ctx context.Context, e Event) error
+
+func (f SinkFunc) Record(ctx context.Context, e Event) error {
+	return f(ctx, e)
+}
+
+// Creates a [plugin.Middleware] that records every successful GET request that
+// it receives as a [Event] on the sinks provided.
+//
+// Events are recorded in the background, so they don't delay responses. If the
+// sinks can't keep up, new events are dropped.
+func New(sinks []Sink, opts ...Opts) plugin.Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Filter == nil {
+		opt.Filter = func(r *http.Request) bool { return !IsBot(r) }
+	}
+	if opt.BufferSize == 0 {
+		opt.BufferSize = 256
+	}
+	if opt.Timeout == 0 {
+		opt.Timeout = 10 * time.Second
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sinks, "Analytics sinks should not be nil")
+
+	return &p{
+		sinks:   sinks,
+		filter:  opt.Filter,
+		timeout: opt.Timeout,
+
+		events: make(chan Event, opt.BufferSize),
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Reports if a request should be recorded. By default all successful GET
+	// requests that aren't from bots (see [IsBot]) are recorded.
+	Filter func(r *http.Request) bool
+	// Number of events that can wait to be recorded. Defaults to 256.
+	BufferSize int
+	// Max duration of a call to [Sink.Record]. Defaults to 10 seconds.
+	Timeout time.Duration
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	sinks   []Sink
+	filter  func(r *http.Request) bool
+	timeout time.Duration
+
+	events chan Event
+	once   sync.Once
+
+	saltMu  sync.Mutex
+	salt    []byte
+	saltDay string
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	p.once.Do(func() { go p.worker() })
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.filter)
+		p.assert.NotNil(p.events)
+
+		// Records the variants selected by the variant plugin, even if it's
+		// middleware runs after this one.
+		r = r.WithContext(variant.Track(r.Context()))
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		if r.Method != http.MethodGet || sw.status != http.StatusOK || !p.filter(r) {
+			return
+		}
+
+		e := Event{
+			Time:        time.Now(),
+			Host:        r.Host,
+			Path:        r.URL.Path,
+			Referrer:    referrer(r),
+			VisitorHash: p.visitorHash(r),
+			Variants:    variant.FromContext(r.Context()),
+		}
+
+		select {
+		case p.events <- e:
+		default:
+			p.log.Warn("Analytics buffer is full, dropping event", slog.String("path", e.Path))
+		}
+	})
+}
+
+func (p *p) worker() {
+	for e := range p.events {
+		for _, s := range p.sinks {
+			ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+			if err := s.Record(ctx, e); err != nil {
+				p.log.Warn("Failed to record analytics event",
+					slog.String("path", e.Path), slog.String("err", err.Error()))
+			}
+			cancel()
+		}
+	}
+}
+
+func (p *p) visitorHash(r *http.Request) string {
+	day := time.Now().UTC().Format(time.DateOnly)
+
+	p.saltMu.Lock()
+	if p.saltDay != day {
+		p.salt = make([]byte, 32)
+		_, _ = rand.Read(p.salt)
+		p.saltDay = day
+	}
+	salt := p.salt
+	p.saltMu.Unlock()
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(r.Host))
+	h.Write([]byte(ip))
+	h.Write([]byte(r.UserAgent()))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func referrer(r *http.Request) string {
+	ref, _, _ := strings.Cut(r.Referer(), "?")
+	return ref
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
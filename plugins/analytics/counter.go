@@ -0,0 +1,263 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/bots"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const apiPluginName = "blogo-analytics-api-middleware"
+
+// Number of views of a path.
+type Count struct {
+	Path  string `json:"path"`
+	Views int64  `json:"views"`
+}
+
+// Source of view counts recorded by a [Sink].
+type Counter interface {
+	// Returns the number of views of the path, zero if it was never viewed.
+	Views(ctx context.Context, path string) (int64, error)
+	// Returns the n most viewed paths, in descending order of views.
+	Popular(ctx context.Context, n int) ([]Count, error)
+}
+
+// A [Sink] that also counts the recorded events, such as [NewSQLSink].
+type Store interface {
+	Sink
+	Counter
+}
+
+// Reports if the request was probably done by a bot, such as search engine
+// crawlers, link previewers and command line clients, based on it's User-Agent.
+// Requests without a User-Agent are also considered bots. Uses the detection of
+// the middleware of the bots package, if it's added before the analytics one,
+// see [bots.IsBot].
+func IsBot(r *http.Request) bool {
+	return bots.IsBot(r)
+}
+
+// Wraps the counter, caching it's results for the specified duration, so
+// templates and API requests don't query the underlying store on every render.
+func NewCachedCounter(c Counter, d time.Duration) Counter {
+	return &cachedCounter{
+		counter:  c,
+		duration: d,
+		views:    map[string]cachedValue[int64]{},
+		popular:  map[int]cachedValue[[]Count]{},
+	}
+}
+
+type cachedCounter struct {
+	counter  Counter
+	duration time.Duration
+
+	mu      sync.Mutex
+	views   map[string]cachedValue[int64]
+	popular map[int]cachedValue[[]Count]
+}
+
+type cachedValue[T any] struct {
+	value   T
+	expires time.Time
+}
+
+func (c *cachedCounter) Views(ctx context.Context, path string) (int64, error) {
+	c.mu.Lock()
+	v, ok := c.views[path]
+	c.mu.Unlock()
+	if ok && time.Now().Before(v.expires) {
+		return v.value, nil
+	}
+
+	views, err := c.counter.Views(ctx, path)
+	if err != nil {
+		return views, err
+	}
+
+	c.mu.Lock()
+	c.views[path] = cachedValue[int64]{value: views, expires: time.Now().Add(c.duration)}
+	c.mu.Unlock()
+
+	return views, nil
+}
+
+func (c *cachedCounter) Popular(ctx context.Context, n int) ([]Count, error) {
+	c.mu.Lock()
+	v, ok := c.popular[n]
+	c.mu.Unlock()
+	if ok && time.Now().Before(v.expires) {
+		return v.value, nil
+	}
+
+	popular, err := c.counter.Popular(ctx, n)
+	if err != nil {
+		return popular, err
+	}
+
+	c.mu.Lock()
+	c.popular[n] = cachedValue[[]Count]{value: popular, expires: time.Now().Add(c.duration)}
+	c.mu.Unlock()
+
+	return popular, nil
+}
+
+// Returns template functions to access the view counts of the counter:
+//
+//   - "views PATH" returns the number of views of the path;
+//   - "popular N" returns the N most viewed paths as [Count] values.
+//
+// Paths without a leading slash, such as the file path of a post, are converted
+// to their URL path by removing their extension. Errors are ignored and return
+// zero values, so a unavailable store doesn't break rendering of pages.
+func FuncMap(c Counter) template.FuncMap {
+	return template.FuncMap{
+		"views": func(p string) int64 {
+			v, _ := c.Views(context.Background(), URLPath(p))
+			return v
+		},
+		"popular": func(n int) []Count {
+			v, err := c.Popular(context.Background(), n)
+			if err != nil {
+				return []Count{}
+			}
+			return v
+		},
+	}
+}
+
+// Converts a file path, such as "posts/hello.md", to the URL path that it is
+// served on, "/posts/hello". Paths that already start with "/" are returned as is.
+func URLPath(p string) string {
+	if strings.HasPrefix(p, "/") {
+		return p
+	}
+	return "/" + strings.TrimSuffix(p, path.Ext(p))
+}
+
+// Creates a [plugin.Middleware] that serves the view counts of the counter as
+// JSON on the following endpoints, relative to APIOpts.Prefix:
+//
+//   - "views?path=PATH" returns a [Count] of the path;
+//   - "popular?limit=N" returns the N (defaults to 10, max 100) most viewed paths.
+func NewAPI(c Counter, opts ...APIOpts) plugin.Plugin {
+	opt := APIOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Prefix == "" {
+		opt.Prefix = "/api/"
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(c, "Counter should not be nil")
+
+	return &api{
+		counter: c,
+		prefix:  "/" + strings.Trim(opt.Prefix, "/") + "/",
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type APIOpts struct {
+	// Path prefix of the API endpoints. Defaults to "/api/".
+	Prefix string
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type api struct {
+	counter Counter
+	prefix  string
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (a *api) Name() string {
+	return apiPluginName
+}
+
+func (a *api) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.assert.NotNil(a.counter)
+		a.assert.NotNil(a.log)
+
+		var v any
+		var err error
+
+		switch r.URL.Path {
+		case a.prefix + "views":
+			p := r.URL.Query().Get("path")
+			if p == "" {
+				http.Error(w, "Missing path parameter", http.StatusBadRequest)
+				return
+			}
+			p = URLPath(p)
+
+			var views int64
+			views, err = a.counter.Views(r.Context(), p)
+			v = Count{Path: p, Views: views}
+
+		case a.prefix + "popular":
+			limit := 10
+			if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+				limit = min(l, 100)
+			}
+			v, err = a.counter.Popular(r.Context(), limit)
+
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err != nil {
+			a.log.Error("Failed to query view counts",
+				slog.String("path", r.URL.Path), slog.String("err", err.Error()))
+			http.Error(w, "Failed to query view counts", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			a.log.Error("Failed to write view counts", slog.String("err", err.Error()))
+		}
+	})
+}
@@ -0,0 +1,278 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analytics
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"forge.capytal.company/loreddev/blogo/httpclient"
+	"forge.capytal.company/loreddev/blogo/kv"
+)
+
+// Creates a [Sink] that sends events to the Plausible Events API. Endpoint
+// defaults to "https://plausible.io/api/event" if empty, and domain is the site
+// domain as configured on Plausible.
+//
+// The visitor hash is sent as the User-Agent of the event, so Plausible can
+// count unique visitors without receiving the real User-Agent or IP address.
+// Variants of the event are sent as custom properties.
+func NewPlausibleSink(endpoint, domain string, client ...*http.Client) Sink {
+	if endpoint == "" {
+		endpoint = "https://plausible.io/api/event"
+	}
+	c := httpclient.Default()
+	if len(client) > 0 && client[0] != nil {
+		c = client[0]
+	}
+
+	return SinkFunc(func(ctx context.Context, e Event) error {
+		event := map[string]any{
+			"name":     "pageview",
+			"domain":   domain,
+			"url":      "https://" + e.Host + e.Path,
+			"referrer": e.Referrer,
+		}
+		if len(e.Variants) > 0 {
+			event["props"] = e.Variants
+		}
+		return postJSON(ctx, c, endpoint, e.VisitorHash, event)
+	})
+}
+
+// Creates a [Sink] that sends events to the Umami API of the provided
+// instance URL, such as "https://cloud.umami.is", for the website ID.
+//
+// As with [NewPlausibleSink], the visitor hash is sent as the User-Agent, and
+// variants of the event are sent as it's data.
+func NewUmamiSink(instance, websiteID string, client ...*http.Client) Sink {
+	endpoint := strings.TrimSuffix(instance, "/") + "/api/send"
+	c := httpclient.Default()
+	if len(client) > 0 && client[0] != nil {
+		c = client[0]
+	}
+
+	return SinkFunc(func(ctx context.Context, e Event) error {
+		payload := map[string]any{
+			"website":  websiteID,
+			"hostname": e.Host,
+			"url":      e.Path,
+			"referrer": e.Referrer,
+		}
+		if len(e.Variants) > 0 {
+			payload["data"] = e.Variants
+		}
+		return postJSON(ctx, c, endpoint, e.VisitorHash, map[string]any{
+			"type":    "event",
+			"payload": payload,
+		})
+	})
+}
+
+func postJSON(ctx context.Context, c *http.Client, endpoint, ua string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return errors.Join(errors.New("failed to marshal event"), err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.Join(errors.New("failed to create request"), err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", ua)
+
+	res, err := c.Do(req)
+	if err != nil {
+		return errors.Join(errors.New("failed to send event"), err)
+	}
+	defer res.Body.Close()
+
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %q", res.Status)
+	}
+
+	return nil
+}
+
+// Creates a [Store] that counts views per path on a local SQL database, such as
+// SQLite, creating and migrating the table with [kv.MigrateSQL]. The database
+// driver is chosen by the caller, so this package doesn't depend on any
+// specific driver.
+//
+// The table has the "path" and "views" columns, and it's name defaults to
+// "blogo_views" if empty.
+func NewSQLSink(db *sql.DB, table ...string) Store {
+	t := "blogo_views"
+	if len(table) > 0 && table[0] != "" {
+		t = table[0]
+	}
+	return &sqlSink{db: db, table: t}
+}
+
+type sqlSink struct {
+	db    *sql.DB
+	table string
+	once  sync.Once
+	err   error
+}
+
+func (s *sqlSink) init(ctx context.Context) error {
+	s.once.Do(func() {
+		err := kv.MigrateSQL(ctx, s.db, s.table, []kv.SQLMigration{
+			kv.SQLStatement(1, "create table", s.table,
+				"CREATE TABLE IF NOT EXISTS {table} (path TEXT PRIMARY KEY, views INTEGER NOT NULL DEFAULT 0)"),
+		})
+		if err != nil {
+			s.err = errors.Join(errors.New("failed to migrate views table"), err)
+		}
+	})
+	return s.err
+}
+
+func (s *sqlSink) Record(ctx context.Context, e Event) error {
+	if err := s.init(ctx); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (path, views) VALUES (?, 1) "+
+			"ON CONFLICT(path) DO UPDATE SET views = views + 1",
+		s.table,
+	), e.Path)
+	if err != nil {
+		return errors.Join(errors.New("failed to increment views"), err)
+	}
+
+	return nil
+}
+
+func (s *sqlSink) Views(ctx context.Context, path string) (int64, error) {
+	if err := s.init(ctx); err != nil {
+		return 0, err
+	}
+
+	var views int64
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT views FROM %s WHERE path = ?", s.table,
+	), path).Scan(&views)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	} else if err != nil {
+		return 0, errors.Join(errors.New("failed to query views"), err)
+	}
+
+	return views, nil
+}
+
+func (s *sqlSink) Popular(ctx context.Context, n int) ([]Count, error) {
+	if err := s.init(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT path, views FROM %s ORDER BY views DESC, path ASC LIMIT ?", s.table,
+	), n)
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to query popular paths"), err)
+	}
+	defer rows.Close()
+
+	cs := []Count{}
+	for rows.Next() {
+		var c Count
+		if err := rows.Scan(&c.Path, &c.Views); err != nil {
+			return nil, errors.Join(errors.New("failed to scan popular paths"), err)
+		}
+		cs = append(cs, c)
+	}
+
+	return cs, rows.Err()
+}
+
+// Creates a [Store] that counts the views of each path on the key-value store,
+// with the keys "analytics:views:PATH". Finding the popular paths lists all
+// counts, so it should be wrapped with [NewCachedCounter] on large sites.
+func NewKVSink(s kv.Store) Store {
+	return &kvSink{store: s}
+}
+
+type kvSink struct {
+	store kv.Store
+}
+
+const kvViewsPrefix = "analytics:views:"
+
+func (s *kvSink) Record(ctx context.Context, e Event) error {
+	if _, err := s.store.Incr(ctx, kvViewsPrefix+e.Path, 1); err != nil {
+		return errors.Join(errors.New("failed to increment views"), err)
+	}
+	return nil
+}
+
+func (s *kvSink) Views(ctx context.Context, path string) (int64, error) {
+	v, ok, err := s.store.Get(ctx, kvViewsPrefix+path)
+	if err != nil {
+		return 0, errors.Join(errors.New("failed to get views"), err)
+	}
+	if !ok {
+		return 0, nil
+	}
+
+	views, err := strconv.ParseInt(string(v), 10, 64)
+	if err != nil {
+		return 0, errors.Join(errors.New("malformed views count"), err)
+	}
+	return views, nil
+}
+
+func (s *kvSink) Popular(ctx context.Context, n int) ([]Count, error) {
+	entries, err := s.store.List(ctx, kvViewsPrefix, 0)
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to list views"), err)
+	}
+
+	cs := make([]Count, 0, len(entries))
+	for _, e := range entries {
+		views, err := strconv.ParseInt(string(e.Value), 10, 64)
+		if err != nil {
+			continue
+		}
+		cs = append(cs, Count{Path: strings.TrimPrefix(e.Key, kvViewsPrefix), Views: views})
+	}
+
+	slices.SortStableFunc(cs, func(a, b Count) int {
+		return cmp.Compare(b.Views, a.Views)
+	})
+	if n >= 0 && len(cs) > n {
+		cs = cs[:n]
+	}
+
+	return cs, nil
+}
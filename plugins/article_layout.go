@@ -0,0 +1,211 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins
+
+import (
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const articleLayoutName = "blogo-articlelayout-renderer"
+
+// Stylesheet used by [NewArticleLayout] when [ArticleLayoutOpts].PrintStyles is enabled.
+const DefaultPrintStylesheet = `@media print {
+  nav, header nav, footer, aside, .anchor, .embed, form, button { display: none !important; }
+  body { font: 12pt/1.5 Georgia, serif; color: #000; background: #fff; margin: 0; }
+  article { max-width: none; }
+  a { color: inherit; text-decoration: underline; }
+  article a[href^="http"]::after { content: " (" attr(href) ")"; font-size: 90%; }
+  pre, blockquote, figure, img, table { page-break-inside: avoid; }
+  h1, h2, h3, h4 { page-break-after: avoid; }
+  img { max-width: 100% !important; }
+}`
+
+var articleLayoutTemplate = template.Must(template.New("article").Parse(
+	`{{- if .Document}}<!DOCTYPE html>
+<html lang="{{.Language}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+{{- if .Summary}}
+<meta name="description" content="{{.Summary}}">
+{{- end}}
+{{- range .Stylesheets}}
+<link rel="stylesheet" href="{{.}}">
+{{- end}}
+{{- if .PrintStyles}}
+<style media="print">{{.PrintStylesheet}}</style>
+{{- end}}
+</head>
+<body>
+<main>
+{{end -}}
+<article itemscope itemtype="https://schema.org/BlogPosting">
+<header>
+{{- if .Title}}
+<h1 itemprop="headline">{{.Title}}</h1>
+{{- end}}
+{{- if or (not .Date.IsZero) .Author}}
+<p class="byline">
+{{- if not .Date.IsZero}}<time itemprop="datePublished" datetime="{{.Date.Format "2006-01-02T15:04:05Z07:00"}}">{{.Date.Format "January 2, 2006"}}</time>{{end}}
+{{- if .Author}} <span itemprop="author" itemscope itemtype="https://schema.org/Person"><span itemprop="name">{{.Author}}</span></span>{{end -}}
+</p>
+{{- end}}
+{{- if not .Updated.IsZero}}
+<p class="updated">Updated <time itemprop="dateModified" datetime="{{.Updated.Format "2006-01-02T15:04:05Z07:00"}}">{{.Updated.Format "January 2, 2006"}}</time></p>
+{{- end}}
+</header>
+<div itemprop="articleBody">
+{{.Content}}
+</div>
+</article>
+{{- if .Document}}
+</main>
+</body>
+</html>
+{{- end}}
+`))
+
+// Creates a [plugin.Renderer] that transforms already rendered HTML, wrapping it in a
+// semantic and minimal layout: a article element with the title, publish date and
+// author, annotated with schema.org microdata, which is what reader modes and print
+// views expect. Values are taken from the "title", "date", "updated" and "author"
+// metadata of the file.
+//
+// Themes can toggle this layout instead of their own, or use it as the inner
+// layout when [ArticleLayoutOpts].Document is false.
+//
+// This renderer is intended to be used after other renderers in a [FoldingRenderer].
+func NewArticleLayout(opts ...ArticleLayoutOpts) plugin.Renderer {
+	opt := ArticleLayoutOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Language == "" {
+		opt.Language = "en"
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &articleLayout{
+		document:    opt.Document,
+		language:    opt.Language,
+		stylesheets: opt.Stylesheets,
+		printStyles: opt.PrintStyles,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type ArticleLayoutOpts struct {
+	// Emits a full HTML document, instead of just the article element.
+	Document bool
+	// Language of the document. Defaults to "en".
+	Language string
+	// URLs of stylesheets linked on the document.
+	Stylesheets []string
+	// Adds [DefaultPrintStylesheet] to the document.
+	PrintStyles bool
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+// Information passed to the template of [NewArticleLayout].
+type ArticleLayoutInfo struct {
+	Document        bool
+	Language        string
+	Stylesheets     []string
+	PrintStyles     bool
+	PrintStylesheet template.CSS
+
+	Title   string
+	Summary string
+	Author  string
+	Date    time.Time
+	Updated time.Time
+
+	Content template.HTML
+}
+
+type articleLayout struct {
+	document    bool
+	language    string
+	stylesheets []string
+	printStyles bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (r *articleLayout) Name() string {
+	return articleLayoutName
+}
+
+func (r *articleLayout) Render(src fs.File, w io.Writer) error {
+	r.assert.NotNil(src)
+	r.assert.NotNil(w)
+	r.assert.NotNil(r.log)
+
+	if _, ok := src.(fs.ReadDirFile); ok {
+		return errors.New("does not support directories")
+	}
+
+	contents, err := io.ReadAll(src)
+	if err != nil {
+		return errors.Join(errors.New("failed to read file contents"), err)
+	}
+
+	m := getMetadataOrEmpty(src)
+
+	info := ArticleLayoutInfo{
+		Document:        r.document,
+		Language:        r.language,
+		Stylesheets:     r.stylesheets,
+		PrintStyles:     r.printStyles,
+		PrintStylesheet: template.CSS(DefaultPrintStylesheet),
+
+		Content: template.HTML(contents),
+	}
+
+	info.Title, _ = metadata.GetTyped[string](m, "title")
+	info.Summary, _ = metadata.GetTyped[string](m, "summary")
+	info.Author, _ = metadata.GetTyped[string](m, "author")
+	info.Date, _ = metadata.GetTime(m, "date")
+	info.Updated, _ = metadata.GetTime(m, "updated")
+
+	if err := articleLayoutTemplate.Execute(w, info); err != nil {
+		return errors.Join(errors.New("failed to execute article layout"), err)
+	}
+
+	return nil
+}
@@ -17,11 +17,15 @@ package plugins
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"io/fs"
 	"log/slog"
+	"net/http"
+	"slices"
 
+	"forge.capytal.company/loreddev/blogo/metadata"
 	"forge.capytal.company/loreddev/blogo/plugin"
 	"forge.capytal.company/loreddev/x/tinyssert"
 )
@@ -93,6 +97,10 @@ func (r *bufferedMultiRenderer) Use(p plugin.Plugin) {
 }
 
 func (r *bufferedMultiRenderer) Render(src fs.File, w io.Writer) error {
+	return r.RenderContext(context.Background(), src, w)
+}
+
+func (r *bufferedMultiRenderer) RenderContext(ctx context.Context, src fs.File, w io.Writer) error {
 	r.assert.NotNil(r.plugins, "Plugins slice needs to be not-nil")
 	r.assert.NotNil(r.log)
 
@@ -103,13 +111,22 @@ func (r *bufferedMultiRenderer) Render(src fs.File, w io.Writer) error {
 
 	var buf bytes.Buffer
 
+	// Status and headers set by renderers are also buffered, so the ones of
+	// failed renderers don't leak into the response.
+	res, hasRes := plugin.ResponseFromContext(ctx)
+	bres := newBufferedResponse(res, hasRes)
+	ctx = plugin.WithResponse(ctx, bres)
+
 	for _, p := range r.plugins {
 		log := log.With(slog.String("plugin", p.Name()))
 		log.Debug("Trying to render with plugin")
 
-		err := p.Render(bf, &buf)
+		err := plugin.RenderContext(ctx, p, bf, &buf)
 		if err == nil {
 			log.Debug("Successfully rendered with plugin")
+			if hasRes {
+				bres.apply(res)
+			}
 			break
 		}
 
@@ -121,6 +138,7 @@ func (r *bufferedMultiRenderer) Render(src fs.File, w io.Writer) error {
 		}
 
 		buf.Reset()
+		bres.reset(res, hasRes)
 	}
 
 	log.Debug("Copying response to final writer")
@@ -132,6 +150,52 @@ func (r *bufferedMultiRenderer) Render(src fs.File, w io.Writer) error {
 	return nil
 }
 
+// [plugin.Response] passed to the renderers, which keeps the status and headers
+// they set until one of them succeeds, see [(*bufferedResponse).apply].
+type bufferedResponse struct {
+	header http.Header
+	status int
+}
+
+// Creates the buffered response, with the headers of the response if the render
+// has one.
+func newBufferedResponse(res plugin.Response, ok bool) *bufferedResponse {
+	r := &bufferedResponse{}
+	r.reset(res, ok)
+	return r
+}
+
+func (r *bufferedResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *bufferedResponse) SetStatus(code int) {
+	r.status = code
+}
+
+// Discards the status and headers set, going back to the ones of the response.
+func (r *bufferedResponse) reset(res plugin.Response, ok bool) {
+	r.header = http.Header{}
+	if ok {
+		r.header = res.Header().Clone()
+	}
+	r.status = 0
+}
+
+// Sets the status and headers on the response.
+func (r *bufferedResponse) apply(res plugin.Response) {
+	h := res.Header()
+	for k := range h {
+		delete(h, k)
+	}
+	for k, v := range r.header {
+		h[k] = slices.Clone(v)
+	}
+	if r.status != 0 {
+		res.SetStatus(r.status)
+	}
+}
+
 func newBufferedFile(src fs.File) bufferedFile {
 	var buf bytes.Buffer
 	r := io.TeeReader(src, &buf)
@@ -178,6 +242,10 @@ func (f *bufFile) Stat() (fs.FileInfo, error) {
 	return f.file.Stat()
 }
 
+func (f *bufFile) Metadata() metadata.Metadata {
+	return getMetadataOrEmpty(f.file)
+}
+
 func (f *bufFile) Reset() error {
 	_, err := io.ReadAll(f.reader)
 	if err != nil {
@@ -216,6 +284,10 @@ func (f *bufDirFile) Stat() (fs.FileInfo, error) {
 	return f.file.Stat()
 }
 
+func (f *bufDirFile) Metadata() metadata.Metadata {
+	return getMetadataOrEmpty(f.file)
+}
+
 func (f *bufDirFile) ReadDir(n int) ([]fs.DirEntry, error) {
 	start, end := f.n, f.n+n
 
@@ -0,0 +1,349 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package calendar provides a [plugin.Middleware] that serves a iCalendar
+// (RFC 5545) feed of the posts and events of the blog, built from a [index.Index],
+// so announcements can be subscribed to from calendar applications.
+//
+// Entries with a "start" metadata, such as the ones of the "event" content type,
+// are events from their start to their "end", at their "location". Start and
+// end dates without a time, such as "2025-03-01", are events of whole days.
+// Other entries are events of the whole day of their date, unless
+// Opts.EventsOnly is set.
+package calendar
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/index"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/visibility"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-calendar-middleware"
+
+const (
+	StartKey    = "start"
+	EndKey      = "end"
+	LocationKey = "location"
+)
+
+// Creates a [plugin.Middleware] that serves the calendar of the entries of the
+// index on Opts.Path. Entries are filtered by the visibility rules with
+// [visibility.Feed], and by Opts.Filter if provided.
+func New(provider index.Provider, opts ...Opts) plugin.Middleware {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Path == "" {
+		opt.Path = "/calendar.ics"
+	}
+	if opt.Filter == nil {
+		opt.Filter = func(e index.Entry) bool { return true }
+	}
+	if opt.Visibility == nil {
+		opt.Visibility = visibility.Default
+	}
+	if opt.Now == nil {
+		opt.Now = time.Now
+	}
+	if opt.Location == nil {
+		opt.Location = time.UTC
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(provider, "Index provider should not be nil")
+
+	host := "blogo"
+	if u, err := url.Parse(opt.BaseURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+
+	return &p{
+		provider: provider,
+
+		path:       opt.Path,
+		name:       opt.Name,
+		baseURL:    strings.TrimSuffix(opt.BaseURL, "/"),
+		host:       host,
+		eventsOnly: opt.EventsOnly,
+		limit:      opt.Limit,
+		filter:     opt.Filter,
+		visibility: opt.Visibility,
+		now:        opt.Now,
+		location:   opt.Location,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Path that the calendar is served on. Defaults to "/calendar.ics".
+	Path string
+
+	// Name of the calendar shown by calendar applications.
+	Name string
+	// Base URL of the blog, used to create absolute links, for example
+	// "https://example.com". It's host is also used on the identifiers of
+	// the events.
+	BaseURL string
+
+	// Only include entries with a "start" metadata.
+	EventsOnly bool
+	// Max number of entries on the calendar. By default all entries are.
+	Limit int
+	// Reports if a entry should be on the calendar. By default all entries are.
+	Filter func(index.Entry) bool
+	// Rules used to hide entries from the calendar. Defaults to [visibility.Default].
+	Visibility visibility.Rules
+
+	// Returns the current time, used as the timestamp of entries without a
+	// date. Defaults to [time.Now].
+	Now func() time.Time
+	// Time zone of the site, which times of events without one are parsed in
+	// and is advertised to calendar applications. It should be the same as the
+	// one of the index. Defaults to [time.UTC].
+	Location *time.Location
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	provider index.Provider
+
+	path       string
+	name       string
+	baseURL    string
+	host       string
+	eventsOnly bool
+	limit      int
+	filter     func(index.Entry) bool
+	visibility visibility.Rules
+	now        func() time.Time
+	location   *time.Location
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.provider)
+		p.assert.NotNil(p.log)
+
+		if r.URL.Path != p.path {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		idx, err := p.provider.Index()
+		if err != nil {
+			p.log.Error("Failed to get index for calendar", slog.String("err", err.Error()))
+			http.Error(w, "Failed to generate calendar", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		if err := p.write(w, idx); err != nil {
+			p.log.Error("Failed to write calendar", slog.String("err", err.Error()))
+		}
+	})
+}
+
+// A event of the calendar.
+type event struct {
+	entry index.Entry
+
+	start, end time.Time
+	allDay     bool
+	location   string
+}
+
+func (p *p) events(idx index.Index) []event {
+	events := []event{}
+	for _, e := range idx.Entries() {
+		if p.limit > 0 && len(events) >= p.limit {
+			break
+		}
+		if !p.visibility.Visible(e.Path, e.Metadata, visibility.Feed) || !p.filter(e) {
+			continue
+		}
+
+		ev, ok := newEvent(e, p.location)
+		if !ok && (p.eventsOnly || e.Date.IsZero()) {
+			continue
+		}
+		if !ok {
+			ev = event{entry: e, start: e.Date, end: e.Date, allDay: true}
+		}
+
+		events = append(events, ev)
+	}
+	return events
+}
+
+// Returns the event of the entry, if it has a valid "start" metadata, with the
+// times on the location.
+func newEvent(e index.Entry, loc *time.Location) (event, bool) {
+	start, allDay, ok := eventTime(e.Metadata, StartKey, loc)
+	if !ok {
+		return event{}, false
+	}
+
+	ev := event{entry: e, start: start, end: start, allDay: allDay}
+	if end, endAllDay, ok := eventTime(e.Metadata, EndKey, loc); ok && !end.Before(start) {
+		ev.end = end
+		ev.allDay = allDay && endAllDay
+	}
+	ev.location, _ = metadata.GetTyped[string](e.Metadata, LocationKey)
+
+	return ev, true
+}
+
+// Returns the time of the metadata on the location, and if it's a date without
+// a time.
+func eventTime(m metadata.Metadata, key string, loc *time.Location) (time.Time, bool, bool) {
+	t, err := metadata.GetTimeIn(m, key, loc)
+	if err != nil {
+		return time.Time{}, false, false
+	}
+	t = t.In(loc)
+
+	s, _ := metadata.GetTyped[string](m, key)
+	_, err = time.Parse(time.DateOnly, strings.TrimSpace(s))
+	return t, err == nil, true
+}
+
+func (p *p) write(w io.Writer, idx index.Index) error {
+	c := &writer{w: w}
+
+	c.line("BEGIN", "VCALENDAR")
+	c.line("VERSION", "2.0")
+	c.line("PRODID", "-//Lored.dev//Blogo//EN")
+	c.line("CALSCALE", "GREGORIAN")
+	c.line("METHOD", "PUBLISH")
+	if p.name != "" {
+		c.line("X-WR-CALNAME", escape(p.name))
+	}
+	c.line("X-WR-TIMEZONE", p.location.String())
+
+	now := p.now()
+	for _, ev := range p.events(idx) {
+		e := ev.entry
+
+		stamp := e.Updated
+		if stamp.IsZero() {
+			stamp = e.Date
+		}
+		if stamp.IsZero() {
+			stamp = now
+		}
+
+		c.line("BEGIN", "VEVENT")
+		c.line("UID", escape(e.Path+"@"+p.host))
+		c.line("DTSTAMP", stamp.UTC().Format(utcLayout))
+		if ev.allDay {
+			c.line("DTSTART;VALUE=DATE", ev.start.Format(dateLayout))
+			// The end of all day events is exclusive.
+			c.line("DTEND;VALUE=DATE", ev.end.AddDate(0, 0, 1).Format(dateLayout))
+		} else {
+			c.line("DTSTART", ev.start.UTC().Format(utcLayout))
+			c.line("DTEND", ev.end.UTC().Format(utcLayout))
+		}
+
+		title := e.Title
+		if title == "" {
+			title = e.URL
+		}
+		c.line("SUMMARY", escape(title))
+		if e.Summary != "" {
+			c.line("DESCRIPTION", escape(e.Summary))
+		}
+		if ev.location != "" {
+			c.line("LOCATION", escape(ev.location))
+		}
+		c.line("URL", p.baseURL+e.URL)
+		for _, t := range e.Tags {
+			c.line("CATEGORIES", escape(t))
+		}
+		c.line("END", "VEVENT")
+	}
+
+	c.line("END", "VCALENDAR")
+
+	return c.err
+}
+
+const (
+	utcLayout  = "20060102T150405Z"
+	dateLayout = "20060102"
+)
+
+// Writes content lines, folded at 75 octets and ended with CRLF as required by
+// RFC 5545.
+type writer struct {
+	w   io.Writer
+	err error
+}
+
+func (c *writer) line(name, value string) {
+	if c.err != nil {
+		return
+	}
+
+	l := name + ":" + value
+
+	var b strings.Builder
+	n := 0
+	for _, r := range l {
+		size := len(string(r))
+		if n+size > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	b.WriteString("\r\n")
+
+	_, c.err = io.WriteString(c.w, b.String())
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
@@ -0,0 +1,393 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package changelog provides a [plugin.Middleware] that serves a "what's new"
+// page and a Atom feed of the changes of the blog, generated from the commit
+// history of the content repository (see [history.History]).
+package changelog
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/history"
+	"forge.capytal.company/loreddev/blogo/index"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/visibility"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-changelog-middleware"
+
+// A commit that added or updated posts of the blog.
+type Entry struct {
+	Commit history.Commit
+	// Posts added by the commit.
+	Added []index.Entry
+	// Posts modified or renamed by the commit.
+	Updated []index.Entry
+}
+
+// Returns the diff summary of the commit, such as "+12 −3".
+func (e Entry) DiffSummary() string {
+	return fmt.Sprintf("+%d −%d", e.Commit.Additions, e.Commit.Deletions)
+}
+
+// Information passed to the template of the changelog page.
+type Info struct {
+	Title   string
+	FeedURL string
+	Entries []Entry
+}
+
+// The changelog plugin.
+type Plugin interface {
+	plugin.Middleware
+	// Returns the template functions:
+	//
+	//   - "changelog [N]" returns the last N entries of the changelog.
+	FuncMap() template.FuncMap
+}
+
+// Creates the changelog [Plugin], serving the page on Opts.Path and the feed on
+// Opts.FeedPath. Only commits that change files of the index are shown, so the
+// history needs to provide the files of commits, and posts not visible on
+// [visibility.Feed] (such as drafts) are omitted.
+//
+// The history is requested at most once every Opts.TTL.
+func New(h history.History, provider index.Provider, opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Path == "" {
+		opt.Path = "/changelog"
+	}
+	if opt.FeedPath == "" {
+		opt.FeedPath = "/changelog.xml"
+	}
+	if opt.Title == "" {
+		opt.Title = "Changelog"
+	}
+	if opt.Template == nil {
+		opt.Template = DefaultTemplate
+	}
+	if opt.Limit == 0 {
+		opt.Limit = 50
+	}
+	if opt.TTL == 0 {
+		opt.TTL = 5 * time.Minute
+	}
+	if opt.Visibility == nil {
+		opt.Visibility = visibility.Default
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(h, "History should not be nil")
+	opt.Assertions.NotNil(provider, "Index provider should not be nil")
+
+	return &p{
+		history:  h,
+		provider: provider,
+
+		path:       opt.Path,
+		feedPath:   opt.FeedPath,
+		title:      opt.Title,
+		baseURL:    strings.TrimSuffix(opt.BaseURL, "/"),
+		templt:     opt.Template,
+		limit:      opt.Limit,
+		ttl:        opt.TTL,
+		visibility: opt.Visibility,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Path that the page is served on. Defaults to "/changelog".
+	Path string
+	// Path that the Atom feed is served on. Defaults to "/changelog.xml".
+	FeedPath string
+	// Title of the page and feed. Defaults to "Changelog".
+	Title string
+	// Base URL of the blog, used to create absolute links on the feed, for
+	// example "https://example.com".
+	BaseURL string
+	// Template of the page, executed with a [Info] value. Defaults to [DefaultTemplate].
+	Template *template.Template
+
+	// Number of commits requested from the history. Defaults to 50.
+	Limit int
+	// Duration the changelog is cached for. Defaults to 5 minutes.
+	TTL time.Duration
+	// Rules used to hide posts from the changelog. Defaults to [visibility.Default].
+	Visibility visibility.Rules
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+// The default template of the changelog page.
+var DefaultTemplate = template.Must(template.New("changelog").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<link rel="alternate" type="application/atom+xml" href="{{.FeedURL}}">
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<ol>
+{{- range .Entries}}
+<li>
+<time datetime="{{.Commit.Date.Format "2006-01-02T15:04:05Z07:00"}}">{{.Commit.Date.Format "2006-01-02"}}</time>
+{{- range .Added}} <a href="{{.URL}}">{{or .Title .URL}}</a> (new){{end}}
+{{- range .Updated}} <a href="{{.URL}}">{{or .Title .URL}}</a> (updated){{end}}
+<small>{{.Commit.Subject}} {{.DiffSummary}}</small>
+</li>
+{{- end}}
+</ol>
+</body>
+</html>
+`))
+
+type p struct {
+	history  history.History
+	provider index.Provider
+
+	path       string
+	feedPath   string
+	title      string
+	baseURL    string
+	templt     *template.Template
+	limit      int
+	ttl        time.Duration
+	visibility visibility.Rules
+
+	mu      sync.Mutex
+	entries []Entry
+	expires time.Time
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != p.path && r.URL.Path != p.feedPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		log := p.log.With(slog.String("path", r.URL.Path))
+
+		entries, err := p.changelog(r.Context())
+		if err != nil {
+			log.Error("Failed to generate changelog", slog.String("err", err.Error()))
+			http.Error(w, "Failed to generate changelog", http.StatusInternalServerError)
+			return
+		}
+
+		if r.URL.Path == p.feedPath {
+			w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+			_, _ = io.WriteString(w, xml.Header)
+
+			enc := xml.NewEncoder(w)
+			enc.Indent("", "  ")
+			if err := enc.Encode(p.atom(entries)); err != nil {
+				log.Error("Failed to write changelog feed", slog.String("err", err.Error()))
+			}
+			return
+		}
+
+		var buf bytes.Buffer
+		err = p.templt.Execute(&buf, Info{Title: p.title, FeedURL: p.feedPath, Entries: entries})
+		if err != nil {
+			log.Error("Failed to execute changelog template", slog.String("err", err.Error()))
+			http.Error(w, "Failed to generate changelog", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = buf.WriteTo(w)
+	})
+}
+
+func (p *p) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"changelog": func(n ...int) []Entry {
+			entries, err := p.changelog(context.Background())
+			if err != nil {
+				p.log.Warn("Failed to generate changelog", slog.String("err", err.Error()))
+				return []Entry{}
+			}
+			if len(n) > 0 && n[0] >= 0 && n[0] < len(entries) {
+				entries = entries[:n[0]]
+			}
+			return entries
+		},
+	}
+}
+
+// Returns the entries of the changelog, from newest to oldest, cached for the
+// TTL of the plugin.
+func (p *p) changelog(ctx context.Context) ([]Entry, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.entries != nil && time.Now().Before(p.expires) {
+		return p.entries, nil
+	}
+
+	idx, err := p.provider.Index()
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to get index"), err)
+	}
+
+	commits, err := p.history.Commits(ctx, "", p.limit)
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to get commits"), err)
+	}
+
+	entries := make([]Entry, 0, len(commits))
+	for _, c := range commits {
+		e := Entry{Commit: c, Added: []index.Entry{}, Updated: []index.Entry{}}
+
+		for _, f := range c.Files {
+			post, ok := idx.Get(f.Path)
+			if !ok || !p.visibility.Visible(post.Path, post.Metadata, visibility.Feed) {
+				continue
+			}
+			switch f.Change {
+			case history.Added:
+				e.Added = append(e.Added, post)
+			case history.Modified, history.Renamed:
+				e.Updated = append(e.Updated, post)
+			}
+		}
+
+		if len(e.Added)+len(e.Updated) > 0 {
+			entries = append(entries, e)
+		}
+	}
+
+	p.entries, p.expires = entries, time.Now().Add(p.ttl)
+
+	return entries, nil
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Summary string      `xml:"summary,omitempty"`
+}
+
+func (p *p) atom(entries []Entry) atomFeed {
+	f := atomFeed{
+		ID:    p.baseURL + p.path,
+		Title: p.title,
+		Links: []atomLink{
+			{Href: p.baseURL + p.path},
+			{Href: p.baseURL + p.feedPath, Rel: "self", Type: "application/atom+xml"},
+		},
+		Updated: time.Time{}.Format(time.RFC3339),
+		Entries: make([]atomEntry, 0, len(entries)),
+	}
+	if len(entries) > 0 {
+		f.Updated = entries[0].Commit.Date.Format(time.RFC3339)
+	}
+
+	for _, e := range entries {
+		titles := []string{}
+		for _, a := range e.Added {
+			titles = append(titles, "New: "+or(a.Title, a.URL))
+		}
+		for _, u := range e.Updated {
+			titles = append(titles, "Updated: "+or(u.Title, u.URL))
+		}
+
+		link := e.Commit.URL
+		if posts := slices.Concat(e.Added, e.Updated); len(posts) > 0 {
+			link = p.baseURL + posts[0].URL
+		}
+
+		entry := atomEntry{
+			ID:      p.baseURL + p.path + "#" + e.Commit.SHA,
+			Title:   strings.Join(titles, ", "),
+			Updated: e.Commit.Date.Format(time.RFC3339),
+			Link:    atomLink{Href: link},
+			Summary: e.Commit.Subject() + " (" + e.DiffSummary() + ")",
+		}
+		if e.Commit.Author.Name != "" {
+			entry.Author = &atomAuthor{Name: e.Commit.Author.Name}
+		}
+
+		f.Entries = append(f.Entries, entry)
+	}
+
+	return f
+}
+
+func or(a, b string) string {
+	if a != "" {
+		return a
+	}
+	return b
+}
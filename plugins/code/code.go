@@ -0,0 +1,218 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package code provides the rendering of source code files, such as ".go" or
+// ".py" files, as highlighted HTML with anchors for each line, so snippets and
+// small projects can be hosted alongside posts.
+//
+// Adding "?raw=1" to the URL of a file serves it's plain text instead.
+package code
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-code-renderer"
+
+// The code plugin, which is a [plugin.ResponseRenderer] of source files and a
+// [plugin.Middleware] that marks the requests of raw views.
+type Plugin interface {
+	plugin.ResponseRenderer
+	plugin.Middleware
+}
+
+// Creates the code [Plugin]. Files with the extensions of Opts.Languages are
+// rendered as a "code-file" div, with the name of the file, a link to the raw
+// view and a "code" pre element, where each line is a "line" span with the
+// "L<n>" id, so lines can be linked as "#L12". Other files return a error, so
+// it can be used alongside other renderers in a [plugins.MultiRenderer].
+//
+// The middleware marks requests with "?raw=1", which are then rendered as
+// "text/plain". Since they aren't HTML, the renderer shouldn't be followed by
+// renderers that transform HTML, such as the layout renderer, on a
+// [plugins.FoldingRenderer].
+func New(opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Languages == nil {
+		opt.Languages = DefaultLanguages
+	}
+	if opt.MaxSize == 0 {
+		opt.MaxSize = 1 << 20
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	languages := map[string]Language{}
+	for _, l := range opt.Languages {
+		for _, ext := range l.Extensions {
+			languages[strings.ToLower(ext)] = l
+		}
+	}
+
+	return &p{
+		languages: languages,
+		maxSize:   opt.MaxSize,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Languages of the files that are rendered. Defaults to [DefaultLanguages].
+	Languages []Language
+	// Max size of the files that are highlighted, in bytes. Larger files are
+	// rendered without highlighting. Defaults to 1 MiB.
+	MaxSize int64
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	languages map[string]Language
+	maxSize   int64
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+type rawKey struct{}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw := r.URL.Query().Get("raw")
+		if raw != "1" && raw != "true" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, ok := p.language(r.URL.Path); !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), rawKey{}, true)
+
+		// The raw view is cached separately from the highlighted one.
+		variant, _ := core.CacheVariantFromContext(ctx)
+		ctx = core.WithCacheVariant(ctx, strings.TrimPrefix(variant+"&raw=1", "&"))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (p *p) Render(src fs.File, w io.Writer) error {
+	return plugin.RenderContext(context.Background(), p, src, w)
+}
+
+func (p *p) RenderResponse(ctx context.Context, src fs.File, res plugin.Response, w io.Writer) error {
+	p.assert.NotNil(src)
+	p.assert.NotNil(res)
+	p.assert.NotNil(w)
+	p.assert.NotNil(p.languages)
+	p.assert.NotNil(p.log)
+
+	stat, err := src.Stat()
+	if err != nil || stat.IsDir() {
+		return errors.New("does not support file, code renderer only renders source files")
+	}
+	lang, ok := p.language(stat.Name())
+	if !ok {
+		return errors.New("does not support file, code renderer only renders source files")
+	}
+
+	log := p.log.With(slog.String("file", stat.Name()), slog.String("language", lang.Name))
+
+	if raw, _ := ctx.Value(rawKey{}).(bool); raw {
+		log.Debug("Rendering raw source file")
+
+		res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		res.Header().Set("X-Content-Type-Options", "nosniff")
+
+		_, err := io.Copy(w, src)
+		return err
+	}
+
+	contents, err := io.ReadAll(src)
+	if err != nil {
+		return errors.Join(errors.New("failed to read source file"), err)
+	}
+
+	var tokens []token
+	if int64(len(contents)) > p.maxSize {
+		log.Debug("Source file larger than max size, rendering without highlighting")
+		tokens = []token{{text: string(contents)}}
+	} else {
+		log.Debug("Rendering source file")
+		tokens = lang.tokenize(string(contents))
+	}
+
+	var b strings.Builder
+
+	b.WriteString(`<div class="code-file">` + "\n")
+	fmt.Fprintf(&b, `<div class="code-header"><span class="code-name">%s</span> <a class="code-raw" href="%s?raw=1">Raw</a></div>`+"\n",
+		html.EscapeString(stat.Name()), html.EscapeString(url.PathEscape(stat.Name())))
+	fmt.Fprintf(&b, `<pre class="code"><code class="language-%s">`, html.EscapeString(lang.Name))
+	writeLines(&b, tokens)
+	b.WriteString("</code></pre>\n</div>\n")
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+func (p *p) language(name string) (Language, bool) {
+	l, ok := p.languages[strings.ToLower(path.Ext(name))]
+	return l, ok
+}
@@ -0,0 +1,360 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package code
+
+import (
+	"html"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A programming language highlighted by the [New] plugin. The highlighting is
+// lexical: comments, strings, numbers and keywords are wrapped in spans with
+// the "comment", "string", "number" and "keyword" classes respectively.
+type Language struct {
+	Name string
+	// Extensions of the files of the language, such as ".go".
+	Extensions []string
+
+	Keywords []string
+	// Prefixes of comments that end on the end of the line, such as "//".
+	LineComments []string
+	// Start and end of block comments, such as "/*" and "*/".
+	BlockComment [2]string
+	// Characters that start strings ending on the same character, where
+	// backslashes escape the next character.
+	Quotes string
+	// Characters that start strings without escapes, which can span lines, such
+	// as "`" on Go.
+	RawQuotes string
+	// Delimiters of strings that can span lines, such as `"""` on Python.
+	LongQuotes []string
+}
+
+var cStyleComments = [2]string{"/*", "*/"}
+
+// Languages supported by default by [New].
+var DefaultLanguages = []Language{
+	{
+		Name:       "go",
+		Extensions: []string{".go"},
+		Keywords: []string{
+			"break", "case", "chan", "const", "continue", "default", "defer", "else",
+			"fallthrough", "for", "func", "go", "goto", "if", "import", "interface",
+			"map", "package", "range", "return", "select", "struct", "switch", "type",
+			"var", "nil", "true", "false", "iota",
+		},
+		LineComments: []string{"//"},
+		BlockComment: cStyleComments,
+		Quotes:       `"'`,
+		RawQuotes:    "`",
+	},
+	{
+		Name:       "python",
+		Extensions: []string{".py"},
+		Keywords: []string{
+			"and", "as", "assert", "async", "await", "break", "class", "continue",
+			"def", "del", "elif", "else", "except", "finally", "for", "from", "global",
+			"if", "import", "in", "is", "lambda", "nonlocal", "not", "or", "pass",
+			"raise", "return", "try", "while", "with", "yield", "None", "True", "False",
+		},
+		LineComments: []string{"#"},
+		Quotes:       `"'`,
+		LongQuotes:   []string{`"""`, `'''`},
+	},
+	{
+		Name:       "javascript",
+		Extensions: []string{".js", ".mjs", ".cjs", ".jsx", ".ts", ".tsx"},
+		Keywords: []string{
+			"async", "await", "break", "case", "catch", "class", "const", "continue",
+			"default", "delete", "do", "else", "export", "extends", "finally", "for",
+			"from", "function", "if", "import", "in", "instanceof", "interface", "let",
+			"new", "of", "return", "switch", "this", "throw", "try", "type", "typeof",
+			"var", "void", "while", "yield", "null", "undefined", "true", "false",
+		},
+		LineComments: []string{"//"},
+		BlockComment: cStyleComments,
+		Quotes:       `"'`,
+		RawQuotes:    "`",
+	},
+	{
+		Name:       "rust",
+		Extensions: []string{".rs"},
+		Keywords: []string{
+			"as", "async", "await", "break", "const", "continue", "crate", "dyn",
+			"else", "enum", "extern", "fn", "for", "if", "impl", "in", "let", "loop",
+			"match", "mod", "move", "mut", "pub", "ref", "return", "self", "Self",
+			"static", "struct", "super", "trait", "type", "unsafe", "use", "where",
+			"while", "true", "false",
+		},
+		LineComments: []string{"//"},
+		BlockComment: cStyleComments,
+		Quotes:       `"`,
+	},
+	{
+		Name:       "c",
+		Extensions: []string{".c", ".h", ".cc", ".cpp", ".hpp"},
+		Keywords: []string{
+			"auto", "break", "case", "char", "class", "const", "continue", "default",
+			"delete", "do", "double", "else", "enum", "extern", "float", "for", "goto",
+			"if", "inline", "int", "long", "namespace", "new", "private", "protected",
+			"public", "register", "return", "short", "signed", "sizeof", "static",
+			"struct", "switch", "template", "typedef", "union", "unsigned", "using",
+			"virtual", "void", "volatile", "while", "true", "false", "nullptr", "NULL",
+		},
+		LineComments: []string{"//"},
+		BlockComment: cStyleComments,
+		Quotes:       `"'`,
+	},
+	{
+		Name:       "java",
+		Extensions: []string{".java", ".kt"},
+		Keywords: []string{
+			"abstract", "break", "case", "catch", "class", "continue", "default",
+			"do", "else", "enum", "extends", "final", "finally", "for", "fun", "if",
+			"implements", "import", "instanceof", "interface", "new", "package",
+			"private", "protected", "public", "return", "static", "super", "switch",
+			"this", "throw", "throws", "try", "val", "var", "void", "while", "null",
+			"true", "false",
+		},
+		LineComments: []string{"//"},
+		BlockComment: cStyleComments,
+		Quotes:       `"'`,
+	},
+	{
+		Name:       "ruby",
+		Extensions: []string{".rb"},
+		Keywords: []string{
+			"alias", "and", "begin", "break", "case", "class", "def", "do", "else",
+			"elsif", "end", "ensure", "for", "if", "in", "module", "next", "nil", "not",
+			"or", "redo", "rescue", "retry", "return", "self", "super", "then", "unless",
+			"until", "when", "while", "yield", "true", "false",
+		},
+		LineComments: []string{"#"},
+		Quotes:       `"'`,
+	},
+	{
+		Name:       "shell",
+		Extensions: []string{".sh", ".bash", ".zsh"},
+		Keywords: []string{
+			"case", "do", "done", "elif", "else", "esac", "export", "fi", "for",
+			"function", "if", "in", "local", "return", "then", "until", "while",
+		},
+		LineComments: []string{"#"},
+		Quotes:       `"`,
+		RawQuotes:    "'",
+	},
+	{
+		Name:       "lua",
+		Extensions: []string{".lua"},
+		Keywords: []string{
+			"and", "break", "do", "else", "elseif", "end", "false", "for", "function",
+			"goto", "if", "in", "local", "nil", "not", "or", "repeat", "return", "then",
+			"true", "until", "while",
+		},
+		LineComments: []string{"--"},
+		Quotes:       `"'`,
+	},
+	{
+		Name:       "nix",
+		Extensions: []string{".nix"},
+		Keywords: []string{
+			"assert", "else", "if", "in", "inherit", "let", "or", "rec", "then", "with",
+			"true", "false", "null",
+		},
+		LineComments: []string{"#"},
+		BlockComment: cStyleComments,
+		Quotes:       `"`,
+		LongQuotes:   []string{"''"},
+	},
+}
+
+type token struct {
+	class string
+	text  string
+}
+
+// Splits the source on tokens of the language. Text that isn't highlighted
+// has a empty class.
+func (l Language) tokenize(src string) []token {
+	tokens := []token{}
+	plain := 0
+
+	emit := func(start, end int, class string) {
+		if plain < start {
+			tokens = append(tokens, token{text: src[plain:start]})
+		}
+		tokens = append(tokens, token{class: class, text: src[start:end]})
+		plain = end
+	}
+
+	for i := 0; i < len(src); {
+		rest := src[i:]
+
+		if open := l.BlockComment[0]; open != "" && strings.HasPrefix(rest, open) {
+			end := strings.Index(rest[len(open):], l.BlockComment[1])
+			if end == -1 {
+				end = len(rest)
+			} else {
+				end += len(open) + len(l.BlockComment[1])
+			}
+			emit(i, i+end, "comment")
+			i += end
+			continue
+		}
+
+		if slices.ContainsFunc(l.LineComments, func(c string) bool { return strings.HasPrefix(rest, c) }) {
+			end := strings.IndexByte(rest, '\n')
+			if end == -1 {
+				end = len(rest)
+			}
+			emit(i, i+end, "comment")
+			i += end
+			continue
+		}
+
+		if q, ok := l.longQuote(rest); ok {
+			end := strings.Index(rest[len(q):], q)
+			if end == -1 {
+				end = len(rest)
+			} else {
+				end += 2 * len(q)
+			}
+			emit(i, i+end, "string")
+			i += end
+			continue
+		}
+
+		c := src[i]
+
+		switch {
+		case strings.IndexByte(l.RawQuotes, c) != -1:
+			end := strings.IndexByte(rest[1:], c)
+			if end == -1 {
+				end = len(rest)
+			} else {
+				end += 2
+			}
+			emit(i, i+end, "string")
+			i += end
+
+		case strings.IndexByte(l.Quotes, c) != -1:
+			end := 1
+			for end < len(rest) && rest[end] != c && rest[end] != '\n' {
+				if rest[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(rest))
+			emit(i, i+end, "string")
+			i += end
+
+		case isDigit(c) && (i == 0 || !isIdent(src[i-1])):
+			end := 1
+			for end < len(rest) && (isIdent(rest[end]) || rest[end] == '.') {
+				end++
+			}
+			emit(i, i+end, "number")
+			i += end
+
+		case isIdent(c):
+			end := 1
+			for end < len(rest) && isIdent(rest[end]) {
+				end++
+			}
+			if (i == 0 || !isIdent(src[i-1])) && slices.Contains(l.Keywords, rest[:end]) {
+				emit(i, i+end, "keyword")
+			}
+			i += end
+
+		default:
+			i++
+		}
+	}
+
+	if plain < len(src) {
+		tokens = append(tokens, token{text: src[plain:]})
+	}
+
+	return tokens
+}
+
+func (l Language) longQuote(s string) (string, bool) {
+	for _, q := range l.LongQuotes {
+		if strings.HasPrefix(s, q) {
+			return q, true
+		}
+	}
+	return "", false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Reports if the byte can be part of a identifier. Bytes of multi-byte
+// characters are considered part of identifiers, so they are never split.
+func isIdent(c byte) bool {
+	return c == '_' || isDigit(c) || (c|0x20 >= 'a' && c|0x20 <= 'z') || c >= 0x80
+}
+
+// Writes the tokens as HTML, with a span for each line that has the "L<n>" id
+// and a link to itself. Tokens that span lines are closed and opened again on
+// each line, so lines are always valid HTML.
+func writeLines(b *strings.Builder, tokens []token) {
+	n := 1
+	startLine := func() {
+		b.WriteString(`<span class="line" id="L`)
+		b.WriteString(strconv.Itoa(n))
+		b.WriteString(`"><a class="line-number" href="#L`)
+		b.WriteString(strconv.Itoa(n))
+		b.WriteString(`" aria-hidden="true">`)
+		b.WriteString(strconv.Itoa(n))
+		b.WriteString(`</a>`)
+	}
+	writeText := func(class, text string) {
+		if text == "" {
+			return
+		}
+		if class == "" {
+			b.WriteString(html.EscapeString(text))
+			return
+		}
+		b.WriteString(`<span class="` + class + `">`)
+		b.WriteString(html.EscapeString(text))
+		b.WriteString(`</span>`)
+	}
+
+	startLine()
+	for i, t := range tokens {
+		lines := strings.Split(t.text, "\n")
+		for j, l := range lines {
+			if j > 0 {
+				b.WriteString("</span>\n")
+				n++
+				if i == len(tokens)-1 && j == len(lines)-1 && l == "" {
+					// Don't start a empty line after the final newline.
+					return
+				}
+				startLine()
+			}
+			writeText(t.class, l)
+		}
+	}
+	b.WriteString("</span>\n")
+}
@@ -0,0 +1,296 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package contact provides a form-handling endpoint for contact forms, with spam
+// protection by honeypot fields and optional captcha verification (such as
+// hCaptcha), pluggable delivery of messages (such as SMTP and webhooks) and a
+// template partial to render the form.
+package contact
+
+import (
+	"context"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/ratelimit"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-contact-middleware"
+
+// A message sent through the contact form.
+type Message struct {
+	Name    string
+	Email   string
+	Subject string
+	Body    string
+
+	Time time.Time
+	// Page from where the form was sent, if known.
+	Referrer string
+}
+
+// Delivers messages to the owner of the blog.
+type Deliverer interface {
+	Deliver(ctx context.Context, m Message) error
+}
+
+// Type adapter to allow the use of ordinary functions as [Deliverer] implementations.
+type DelivererFunc func(ctx context.Context, m Message) error
+
+func (f DelivererFunc) Deliver(ctx context.Context, m Message) error {
+	return f(ctx, m)
+}
+
+// Verifies the captcha response token sent by the form.
+type Verifier interface {
+	Verify(ctx context.Context, token, remoteIP string) error
+}
+
+// The contact plugin, which is a [plugin.Middleware] serving the endpoint and
+// provides template functions to render the form.
+type Plugin interface {
+	plugin.Middleware
+	// Returns the template functions:
+	//
+	//   - "contactForm" renders the contact form, with the honeypot field and the
+	//     captcha widget if a CaptchaSiteKey is provided.
+	FuncMap() template.FuncMap
+}
+
+// Creates the contact form [Plugin], which accepts POST requests on Opts.Path
+// with the "name", "email", "subject" and "message" form values, delivering them
+// with the deliverer. After the message is sent, the client is redirected back
+// to the page of the form with the "sent" query parameter set to "1", or to
+// "0" if it failed.
+//
+// Requests that fill the honeypot field are silently ignored, appearing
+// successful to the bots that sent them.
+func New(deliverer Deliverer, opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Path == "" {
+		opt.Path = "/api/contact"
+	}
+	if opt.HoneypotField == "" {
+		opt.HoneypotField = "website"
+	}
+	if opt.MaxMessageSize == 0 {
+		opt.MaxMessageSize = 16 << 10
+	}
+	if opt.Limiter == nil {
+		opt.Limiter = ratelimit.NewFixedWindow(5, time.Hour)
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(deliverer, "Message deliverer should not be nil")
+
+	return &p{
+		deliverer: deliverer,
+
+		path:           opt.Path,
+		honeypot:       opt.HoneypotField,
+		verifier:       opt.Verifier,
+		captchaSiteKey: opt.CaptchaSiteKey,
+		maxSize:        opt.MaxMessageSize,
+		limiter:        opt.Limiter,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Path of the endpoint. Defaults to "/api/contact".
+	Path string
+	// Name of the hidden field that should be left empty by humans. Defaults to "website".
+	HoneypotField string
+
+	// Verifies captcha responses, such as [NewHCaptchaVerifier]. By default
+	// there isn't any captcha verification.
+	Verifier Verifier
+	// Public site key of hCaptcha, used to render it's widget on the form.
+	CaptchaSiteKey string
+
+	// Max size of the request body, in bytes. Defaults to 16KiB.
+	MaxMessageSize int64
+	// Limits messages by client IP. Defaults to 5 messages per hour.
+	Limiter ratelimit.Limiter
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	deliverer Deliverer
+
+	path           string
+	honeypot       string
+	verifier       Verifier
+	captchaSiteKey string
+	maxSize        int64
+	limiter        ratelimit.Limiter
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.deliverer)
+		p.assert.NotNil(p.limiter)
+		p.assert.NotNil(p.log)
+
+		if r.URL.Path != p.path {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, p.maxSize)
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form", http.StatusBadRequest)
+			return
+		}
+
+		log := p.log.With(slog.String("referrer", r.Referer()))
+
+		if r.PostForm.Get(p.honeypot) != "" {
+			log.Debug("Honeypot field filled, ignoring message")
+			p.redirect(w, r, true)
+			return
+		}
+
+		if !p.limiter.Allow(ratelimit.ClientIP(r)) {
+			log.Debug("Contact form rate limited")
+			core.Reject(w, r, core.RejectError{
+				Status: http.StatusTooManyRequests,
+				Reason: "Too many messages, try again later",
+			})
+			return
+		}
+
+		if p.verifier != nil {
+			token := r.PostForm.Get("h-captcha-response")
+			if err := p.verifier.Verify(r.Context(), token, ratelimit.ClientIP(r)); err != nil {
+				log.Debug("Captcha verification failed", slog.String("err", err.Error()))
+				http.Error(w, "Captcha verification failed", http.StatusForbidden)
+				return
+			}
+		}
+
+		m := Message{
+			Name:     strings.TrimSpace(r.PostForm.Get("name")),
+			Email:    strings.TrimSpace(r.PostForm.Get("email")),
+			Subject:  strings.TrimSpace(r.PostForm.Get("subject")),
+			Body:     strings.TrimSpace(r.PostForm.Get("message")),
+			Time:     time.Now(),
+			Referrer: r.Referer(),
+		}
+
+		if m.Body == "" {
+			http.Error(w, "Message should not be empty", http.StatusBadRequest)
+			return
+		}
+		if m.Email != "" {
+			if _, err := mail.ParseAddress(m.Email); err != nil {
+				http.Error(w, "Invalid email address", http.StatusBadRequest)
+				return
+			}
+		}
+
+		if err := p.deliverer.Deliver(r.Context(), m); err != nil {
+			log.Error("Failed to deliver contact message", slog.String("err", err.Error()))
+			p.redirect(w, r, false)
+			return
+		}
+
+		log.Debug("Contact message delivered")
+		p.redirect(w, r, true)
+	})
+}
+
+func (p *p) redirect(w http.ResponseWriter, r *http.Request, sent bool) {
+	// Only redirect to pages of the same host, to not be used as a open redirect.
+	back := &url.URL{Path: "/"}
+	if ref, err := url.Parse(r.Referer()); err == nil && ref.Host == r.Host {
+		back = &url.URL{Path: ref.Path, RawQuery: ref.RawQuery}
+	}
+
+	q := back.Query()
+	if sent {
+		q.Set("sent", "1")
+	} else {
+		q.Set("sent", "0")
+	}
+	back.RawQuery = q.Encode()
+
+	http.Redirect(w, r, back.String(), http.StatusSeeOther)
+}
+
+var formTemplate = template.Must(template.New("contact").Parse(
+	`<form class="contact-form" method="post" action="{{.Action}}">` +
+		`<label>Name <input type="text" name="name" autocomplete="name"></label>` +
+		`<label>Email <input type="email" name="email" autocomplete="email"></label>` +
+		`<label>Subject <input type="text" name="subject"></label>` +
+		`<label>Message <textarea name="message" required></textarea></label>` +
+		`<div aria-hidden="true" style="position:absolute;left:-10000px">` +
+		`<label>Leave this field empty <input type="text" name="{{.Honeypot}}" tabindex="-1" autocomplete="off"></label>` +
+		`</div>` +
+		`{{if .SiteKey}}<div class="h-captcha" data-sitekey="{{.SiteKey}}"></div>` +
+		`<script src="https://js.hcaptcha.com/1/api.js" async defer></script>{{end}}` +
+		`<button type="submit">Send</button>` +
+		`</form>`,
+))
+
+func (p *p) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"contactForm": func() (template.HTML, error) {
+			var b strings.Builder
+			err := formTemplate.Execute(&b, map[string]any{
+				"Action":   p.path,
+				"Honeypot": p.honeypot,
+				"SiteKey":  p.captchaSiteKey,
+			})
+			return template.HTML(b.String()), err
+		},
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contact
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/httpclient"
+)
+
+// Creates a [Deliverer] that sends messages as emails through the SMTP server
+// at addr ("host:port"), from the from address to the to addresses. The email
+// of the sender, if present, is used as the Reply-To of the email.
+func NewSMTPDeliverer(addr string, auth smtp.Auth, from string, to ...string) Deliverer {
+	return DelivererFunc(func(ctx context.Context, m Message) error {
+		subject := m.Subject
+		if subject == "" {
+			subject = "Contact form message"
+		}
+		if m.Name != "" {
+			subject = fmt.Sprintf("%s (from %s)", subject, m.Name)
+		}
+
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "From: %s\r\n", from)
+		fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+		if m.Email != "" {
+			fmt.Fprintf(&b, "Reply-To: %s\r\n", headerValue(m.Email))
+		}
+		fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", headerValue(subject)))
+		fmt.Fprintf(&b, "Date: %s\r\n", m.Time.Format(time.RFC1123Z))
+		b.WriteString("MIME-Version: 1.0\r\n")
+		b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+		b.WriteString(m.Body)
+		if m.Referrer != "" {
+			fmt.Fprintf(&b, "\r\n\r\n--\r\nSent from %s\r\n", m.Referrer)
+		}
+
+		if err := smtp.SendMail(addr, auth, from, to, b.Bytes()); err != nil {
+			return errors.Join(errors.New("failed to send email"), err)
+		}
+		return nil
+	})
+}
+
+// Removes line breaks, preventing header injection on emails.
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
+
+// Creates a [Deliverer] that sends messages as JSON POST requests to the
+// provided URL, such as chat or automation services webhooks.
+func NewWebhookDeliverer(endpoint string, client ...*http.Client) Deliverer {
+	c := httpclient.Default()
+	if len(client) > 0 && client[0] != nil {
+		c = client[0]
+	}
+
+	return DelivererFunc(func(ctx context.Context, m Message) error {
+		body, err := json.Marshal(map[string]any{
+			"name":     m.Name,
+			"email":    m.Email,
+			"subject":  m.Subject,
+			"message":  m.Body,
+			"time":     m.Time.Format(time.RFC3339),
+			"referrer": m.Referrer,
+		})
+		if err != nil {
+			return errors.Join(errors.New("failed to marshal message"), err)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return errors.Join(errors.New("failed to create request"), err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		res, err := c.Do(req)
+		if err != nil {
+			return errors.Join(errors.New("failed to send webhook"), err)
+		}
+		defer res.Body.Close()
+
+		_, _ = io.Copy(io.Discard, res.Body)
+
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			return fmt.Errorf("unexpected webhook response status %q", res.Status)
+		}
+		return nil
+	})
+}
+
+// Creates a [Verifier] that verifies hCaptcha responses with the secret key.
+func NewHCaptchaVerifier(secret string, client ...*http.Client) Verifier {
+	c := httpclient.Default()
+	if len(client) > 0 && client[0] != nil {
+		c = client[0]
+	}
+	return &hCaptcha{secret: secret, client: c}
+}
+
+type hCaptcha struct {
+	secret string
+	client *http.Client
+}
+
+func (v *hCaptcha) Verify(ctx context.Context, token, remoteIP string) error {
+	if token == "" {
+		return errors.New("missing captcha response")
+	}
+
+	form := url.Values{"secret": {v.secret}, "response": {token}, "remoteip": {remoteIP}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		"https://api.hcaptcha.com/siteverify", strings.NewReader(form.Encode()))
+	if err != nil {
+		return errors.Join(errors.New("failed to create request"), err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := v.client.Do(req)
+	if err != nil {
+		return errors.Join(errors.New("failed to verify captcha"), err)
+	}
+	defer res.Body.Close()
+
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&result); err != nil {
+		return errors.Join(errors.New("failed to decode captcha verification"), err)
+	}
+
+	if !result.Success {
+		return fmt.Errorf("captcha verification failed: %s", strings.Join(result.ErrorCodes, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,439 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const (
+	csvTableName = "blogo-csvtable-renderer"
+	csvEmbedName = "blogo-csvembed-renderer"
+)
+
+// Script that makes the tables with the "data-sortable" attribute sortable by
+// clicking their headers, comparing cells numerically when possible.
+const csvTableSortScript = `<script>(function(){` +
+	`const c=new Intl.Collator(undefined,{numeric:true});` +
+	`for(const t of document.querySelectorAll("table[data-sortable]:not([data-sortable-init])")){` +
+	`t.setAttribute("data-sortable-init","");` +
+	`const hs=t.querySelectorAll("thead th");` +
+	`hs.forEach((th,i)=>th.addEventListener("click",()=>{` +
+	`const d=th.getAttribute("aria-sort")==="ascending"?-1:1,b=t.tBodies[0];` +
+	`hs.forEach(h=>h.removeAttribute("aria-sort"));` +
+	`th.setAttribute("aria-sort",d>0?"ascending":"descending");` +
+	`[...b.rows].sort((x,y)=>d*c.compare(x.cells[i]?.textContent??"",y.cells[i]?.textContent??"")).forEach(r=>b.append(r));` +
+	`}));` +
+	`}` +
+	`})();</script>`
+
+// Creates a [plugin.Renderer] that renders ".csv" and ".tsv" files as HTML tables,
+// returning a error for any other file, so it can be used alongside other
+// renderers in a [MultiRenderer].
+//
+// The first record is used as the header of the table, unless [CSVTableOpts].NoHeader
+// is set. Tables can be sorted by clicking their headers, using a small inline
+// script, unless [CSVTableOpts].DisableSorting is set.
+func NewCSVTable(opts ...CSVTableOpts) plugin.Renderer {
+	opt := CSVTableOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	opt = opt.defaults()
+
+	return &csvTable{
+		opts: opt,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type CSVTableOpts struct {
+	// Max size of the files, in bytes. Larger files fail to be rendered. Defaults
+	// to 1 MiB.
+	MaxSize int64
+	// Max number of rows of the tables, further rows are omitted. Defaults to
+	// 1000, negative values don't limit the number of rows.
+	MaxRows int
+
+	// Don't use the first record as the header of the table.
+	NoHeader bool
+	// Don't add the script that makes tables sortable.
+	DisableSorting bool
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+func (opt CSVTableOpts) defaults() CSVTableOpts {
+	if opt.MaxSize == 0 {
+		opt.MaxSize = 1 << 20
+	}
+	if opt.MaxRows == 0 {
+		opt.MaxRows = 1000
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return opt
+}
+
+type csvTable struct {
+	opts CSVTableOpts
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (r *csvTable) Name() string {
+	return csvTableName
+}
+
+func (r *csvTable) Render(src fs.File, w io.Writer) error {
+	r.assert.NotNil(src)
+	r.assert.NotNil(w)
+	r.assert.NotNil(r.log)
+
+	stat, err := src.Stat()
+	if err != nil || stat.IsDir() || !isCSVFile(stat.Name()) {
+		return errors.New("does not support file, csv table renderer only renders .csv and .tsv files")
+	}
+
+	r.log.Debug("Rendering CSV table", slog.String("file", stat.Name()))
+
+	table, err := renderCSVTable(src, stat.Name(), "", r.opts)
+	if err != nil {
+		return err
+	}
+	if !r.opts.DisableSorting {
+		table += csvTableSortScript
+	}
+
+	_, err = io.WriteString(w, table)
+	return err
+}
+
+// Creates a [plugin.Renderer] that transforms already rendered HTML, replacing
+// paragraphs that only have a "csv" shortcode with the table of the CSV or TSV
+// file on the file system:
+//
+//	{{< csv "data/results.csv" >}}
+//	{{< csv "data/results.tsv" caption="Results of 2025" >}}
+//
+// Paths are relative to the root of the file system, and only ".csv" and ".tsv"
+// files can be embedded. The same limits of
+// [NewCSVTable] apply, and shortcodes of files that fail to be rendered are
+// left untouched. Rendered tables are cached in memory until the modification
+// time or size of the file changes.
+//
+// This renderer is intended to be used after other renderers in a [FoldingRenderer],
+// and before the [NewTypographer] renderer, which replaces the quotes of the shortcode.
+func NewCSVEmbed(fsys fs.FS, opts ...CSVTableOpts) plugin.Renderer {
+	opt := CSVTableOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	opt = opt.defaults()
+
+	return &csvEmbed{
+		fs:   fsys,
+		opts: opt,
+
+		cache: map[string]csvEmbedCacheEntry{},
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type csvEmbed struct {
+	fs   fs.FS
+	opts CSVTableOpts
+
+	cache   map[string]csvEmbedCacheEntry
+	cacheMu sync.Mutex
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+type csvEmbedCacheEntry struct {
+	caption string
+	modTime time.Time
+	size    int64
+	html    string
+}
+
+var csvShortcodePattern = regexp.MustCompile(`^\{\{<\s*csv\s+(.*?)\s*>\}\}$`)
+
+var csvShortcodeArgPattern = regexp.MustCompile(`(?:(\w+)=)?("(?:[^"\\]|\\.)*"|\S+)`)
+
+func (r *csvEmbed) Name() string {
+	return csvEmbedName
+}
+
+func (r *csvEmbed) Render(src fs.File, w io.Writer) error {
+	return r.RenderContext(context.Background(), src, w)
+}
+
+func (r *csvEmbed) RenderContext(ctx context.Context, src fs.File, w io.Writer) error {
+	r.assert.NotNil(src)
+	r.assert.NotNil(w)
+	r.assert.NotNil(r.fs)
+	r.assert.NotNil(r.cache)
+	r.assert.NotNil(r.log)
+
+	if _, ok := src.(fs.ReadDirFile); ok {
+		return errors.New("does not support directories")
+	}
+
+	contents, err := io.ReadAll(src)
+	if err != nil {
+		return errors.Join(errors.New("failed to read file contents"), err)
+	}
+
+	tokens := tokenizeHTML(contents)
+	embedded := false
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+
+		if tok.Kind == htmlStartTagToken && tok.Tag == "p" {
+			if name, caption, end, ok := csvShortcodeParagraph(tokens, i); ok {
+				core.AddDependency(ctx, name)
+
+				table, err := r.table(name, caption)
+				if err == nil {
+					if _, err := io.WriteString(w, table); err != nil {
+						return err
+					}
+					embedded = true
+					i = end
+					continue
+				}
+				r.log.Warn("Failed to embed CSV table",
+					slog.String("file", name), slog.String("err", err.Error()))
+			}
+		}
+
+		if _, err := w.Write(tok.Raw); err != nil {
+			return err
+		}
+	}
+
+	if embedded && !r.opts.DisableSorting {
+		if _, err := io.WriteString(w, csvTableSortScript); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *csvEmbed) table(name, caption string) (string, error) {
+	if !isCSVFile(name) {
+		return "", errors.New("file is not a .csv or .tsv file")
+	}
+
+	stat, err := fs.Stat(r.fs, name)
+	if err != nil {
+		return "", errors.Join(errors.New("failed to stat file"), err)
+	}
+	if stat.IsDir() {
+		return "", errors.New("file is a directory")
+	}
+	if stat.Size() > r.opts.MaxSize {
+		return "", fmt.Errorf("file is larger than the max size of %d bytes", r.opts.MaxSize)
+	}
+
+	r.cacheMu.Lock()
+	e, ok := r.cache[name]
+	r.cacheMu.Unlock()
+	if ok && e.caption == caption && e.modTime.Equal(stat.ModTime()) && e.size == stat.Size() {
+		return e.html, nil
+	}
+
+	f, err := r.fs.Open(name)
+	if err != nil {
+		return "", errors.Join(errors.New("failed to open file"), err)
+	}
+	defer f.Close()
+
+	table, err := renderCSVTable(f, name, caption, r.opts)
+	if err != nil {
+		return "", err
+	}
+
+	r.cacheMu.Lock()
+	r.cache[name] = csvEmbedCacheEntry{
+		caption: caption,
+		modTime: stat.ModTime(),
+		size:    stat.Size(),
+		html:    table,
+	}
+	r.cacheMu.Unlock()
+
+	return table, nil
+}
+
+// Checks if the paragraph starting at tokens[start] only has a "csv" shortcode,
+// returning the path and caption of it and the index of the paragraph's end tag.
+func csvShortcodeParagraph(tokens []htmlToken, start int) (string, string, int, bool) {
+	text := ""
+
+	for i := start + 1; i < len(tokens); i++ {
+		tok := tokens[i]
+
+		switch {
+		case tok.Kind == htmlEndTagToken && tok.Tag == "p":
+			m := csvShortcodePattern.FindStringSubmatch(strings.TrimSpace(text))
+			if m == nil {
+				return "", "", 0, false
+			}
+
+			name, caption := "", ""
+			for _, arg := range csvShortcodeArgPattern.FindAllStringSubmatch(m[1], -1) {
+				v := arg[2]
+				if u, err := strconv.Unquote(v); err == nil {
+					v = u
+				}
+				switch arg[1] {
+				case "":
+					name = v
+				case "caption":
+					caption = v
+				}
+			}
+
+			name = path.Clean(strings.TrimPrefix(name, "/"))
+			if !fs.ValidPath(name) || name == "." {
+				return "", "", 0, false
+			}
+			return name, caption, i, true
+
+		case tok.Kind == htmlTextToken:
+			text += html.UnescapeString(string(tok.Raw))
+
+		default:
+			return "", "", 0, false
+		}
+	}
+
+	return "", "", 0, false
+}
+
+func isCSVFile(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".csv" || ext == ".tsv"
+}
+
+func renderCSVTable(src io.Reader, name, caption string, opts CSVTableOpts) (string, error) {
+	contents, err := io.ReadAll(io.LimitReader(src, opts.MaxSize+1))
+	if err != nil {
+		return "", errors.Join(errors.New("failed to read file contents"), err)
+	}
+	if int64(len(contents)) > opts.MaxSize {
+		return "", fmt.Errorf("file is larger than the max size of %d bytes", opts.MaxSize)
+	}
+
+	reader := csv.NewReader(bytes.NewReader(contents))
+	reader.FieldsPerRecord = -1
+	if strings.ToLower(path.Ext(name)) == ".tsv" {
+		reader.Comma = '\t'
+		reader.LazyQuotes = true
+	}
+
+	var b strings.Builder
+
+	b.WriteString(`<table class="csv-table"`)
+	if !opts.DisableSorting {
+		b.WriteString(` data-sortable`)
+	}
+	b.WriteString(">\n")
+	if caption != "" {
+		fmt.Fprintf(&b, "<caption>%s</caption>\n", html.EscapeString(caption))
+	}
+
+	rows := 0
+	header := !opts.NoHeader
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return "", errors.Join(errors.New("failed to parse CSV file"), err)
+		}
+
+		if header {
+			b.WriteString("<thead>\n<tr>")
+			for _, f := range record {
+				fmt.Fprintf(&b, `<th scope="col">%s</th>`, html.EscapeString(f))
+			}
+			b.WriteString("</tr>\n</thead>\n<tbody>\n")
+			header = false
+			continue
+		}
+		if rows == 0 && opts.NoHeader {
+			b.WriteString("<tbody>\n")
+		}
+
+		if opts.MaxRows > 0 && rows >= opts.MaxRows {
+			opts.Logger.Warn("CSV file has more rows than the max, omitting the rest",
+				slog.String("file", name), slog.Int("max", opts.MaxRows))
+			break
+		}
+
+		b.WriteString("<tr>")
+		for _, f := range record {
+			fmt.Fprintf(&b, "<td>%s</td>", html.EscapeString(f))
+		}
+		b.WriteString("</tr>\n")
+		rows++
+	}
+
+	if rows == 0 && (opts.NoHeader || header) {
+		b.WriteString("<tbody>\n")
+	}
+	b.WriteString("</tbody>\n</table>\n")
+
+	return b.String(), nil
+}
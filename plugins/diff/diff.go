@@ -0,0 +1,285 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diff provides the rendering of patches (".diff" and ".patch" files)
+// as colored HTML diffs, on the unified or side-by-side views, so posts can
+// reference patches stored alongside the content.
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-diff-renderer"
+
+// Extensions of the files rendered by default.
+var DefaultExtensions = []string{".diff", ".patch"}
+
+// How the changes of a file are shown.
+type View int
+
+const (
+	// A single column, with deleted lines followed by the lines added in their place.
+	Unified View = iota
+	// Two columns, with the old version of the file on the left and the new on the right.
+	SideBySide
+)
+
+// Creates a [plugin.Renderer] of patches on the unified diff format, such as
+// the ones created by "git diff" and "git format-patch", returning a error for
+// files without the extensions of Opts.Extensions, so it can be used alongside
+// other renderers in a [plugins.MultiRenderer].
+//
+// Each file of the patch is rendered as a "diff-file" section with it's name
+// and a "diff" table, where rows have the classes "hunk", "context", "added",
+// "deleted" or "note" ("change" on the side-by-side view, for rows pairing
+// deleted and added lines) and cells of line numbers have the "line-number" class.
+// The message of the patch, if any, is rendered as a "diff-message" pre.
+func New(opts ...Opts) plugin.Renderer {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Extensions == nil {
+		opt.Extensions = DefaultExtensions
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &p{
+		extensions: opt.Extensions,
+		view:       opt.View,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Extensions of the rendered files. Defaults to [DefaultExtensions].
+	Extensions []string
+	// View of the changes. Defaults to [Unified].
+	View View
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	extensions []string
+	view       View
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Render(src fs.File, w io.Writer) error {
+	p.assert.NotNil(src)
+	p.assert.NotNil(w)
+	p.assert.NotNil(p.log)
+
+	stat, err := src.Stat()
+	if err != nil || stat.IsDir() || !p.supports(stat.Name()) {
+		return errors.New("does not support file, diff renderer only renders patches")
+	}
+
+	contents, err := io.ReadAll(src)
+	if err != nil {
+		return errors.Join(errors.New("failed to read patch"), err)
+	}
+
+	patch := parse(string(contents))
+	if len(patch.Files) == 0 {
+		return errors.New("does not support file, file has no changes on the unified diff format")
+	}
+
+	p.log.Debug("Rendering patch", slog.String("file", stat.Name()), slog.Int("files", len(patch.Files)))
+
+	var b strings.Builder
+
+	view := "unified"
+	if p.view == SideBySide {
+		view = "side-by-side"
+	}
+	fmt.Fprintf(&b, `<div class="diff-patch %s">`+"\n", view)
+
+	if patch.Message != "" {
+		fmt.Fprintf(&b, `<pre class="diff-message">%s</pre>`+"\n", html.EscapeString(patch.Message))
+	}
+
+	for _, f := range patch.Files {
+		fmt.Fprintf(&b, `<section class="diff-file">`+"\n"+`<h2 class="diff-name">%s</h2>`+"\n",
+			html.EscapeString(f.name()))
+
+		for _, e := range f.Extended {
+			if !strings.HasPrefix(e, "index ") {
+				fmt.Fprintf(&b, `<p class="diff-extended">%s</p>`+"\n", html.EscapeString(e))
+			}
+		}
+
+		if len(f.Hunks) > 0 {
+			b.WriteString(`<table class="diff">` + "\n")
+			for _, h := range f.Hunks {
+				if p.view == SideBySide {
+					sideBySideHunk(&b, h)
+				} else {
+					unifiedHunk(&b, h)
+				}
+			}
+			b.WriteString("</table>\n")
+		}
+
+		b.WriteString("</section>\n")
+	}
+
+	b.WriteString("</div>\n")
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+func (p *p) supports(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range p.extensions {
+		if strings.ToLower(e) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+var lineClasses = map[lineKind]string{
+	contextLine: "context",
+	addedLine:   "added",
+	deletedLine: "deleted",
+	noteLine:    "note",
+}
+
+func unifiedHunk(b *strings.Builder, h hunk) {
+	fmt.Fprintf(b, `<tr class="hunk"><td colspan="3">%s</td></tr>`+"\n", html.EscapeString(h.Header))
+
+	oldLine, newLine := h.OldLine, h.NewLine
+	for _, l := range h.Lines {
+		oldN, newN := "", ""
+		switch l.Kind {
+		case contextLine:
+			oldN, newN = strconv.Itoa(oldLine), strconv.Itoa(newLine)
+			oldLine++
+			newLine++
+		case deletedLine:
+			oldN = strconv.Itoa(oldLine)
+			oldLine++
+		case addedLine:
+			newN = strconv.Itoa(newLine)
+			newLine++
+		}
+
+		fmt.Fprintf(b, `<tr class="%s"><td class="line-number">%s</td><td class="line-number">%s</td>%s</tr>`+"\n",
+			lineClasses[l.Kind], oldN, newN, codeCell(l))
+	}
+}
+
+// Renders the hunk on two columns, pairing the deleted lines of each change
+// with the lines added after them.
+func sideBySideHunk(b *strings.Builder, h hunk) {
+	fmt.Fprintf(b, `<tr class="hunk"><td colspan="4">%s</td></tr>`+"\n", html.EscapeString(h.Header))
+
+	oldLine, newLine := h.OldLine, h.NewLine
+	for i := 0; i < len(h.Lines); {
+		l := h.Lines[i]
+
+		if l.Kind == contextLine || l.Kind == noteLine {
+			left, right := strconv.Itoa(oldLine), strconv.Itoa(newLine)
+			if l.Kind == noteLine {
+				left, right = "", ""
+			} else {
+				oldLine++
+				newLine++
+			}
+			fmt.Fprintf(b, `<tr class="%s"><td class="line-number">%s</td>%s<td class="line-number">%s</td>%s</tr>`+"\n",
+				lineClasses[l.Kind], left, codeCell(l), right, codeCell(l))
+			i++
+			continue
+		}
+
+		deleted, added := []line{}, []line{}
+		for ; i < len(h.Lines) && h.Lines[i].Kind == deletedLine; i++ {
+			deleted = append(deleted, h.Lines[i])
+		}
+		for ; i < len(h.Lines) && h.Lines[i].Kind == addedLine; i++ {
+			added = append(added, h.Lines[i])
+		}
+
+		for j := range max(len(deleted), len(added)) {
+			b.WriteString(`<tr class="change">`)
+			if j < len(deleted) {
+				fmt.Fprintf(b, `<td class="line-number">%d</td>%s`, oldLine, codeCell(deleted[j]))
+				oldLine++
+			} else {
+				b.WriteString(`<td class="line-number"></td><td class="empty"></td>`)
+			}
+			if j < len(added) {
+				fmt.Fprintf(b, `<td class="line-number">%d</td>%s`, newLine, codeCell(added[j]))
+				newLine++
+			} else {
+				b.WriteString(`<td class="line-number"></td><td class="empty"></td>`)
+			}
+			b.WriteString("</tr>\n")
+		}
+	}
+}
+
+func codeCell(l line) string {
+	marker := string(l.Kind)
+	if l.Kind == noteLine {
+		return `<td class="code note">` + html.EscapeString(marker+" "+l.Text) + `</td>`
+	}
+	return `<td class="code ` + lineClasses[l.Kind] + `"><span class="marker">` + marker +
+		`</span>` + html.EscapeString(l.Text) + `</td>`
+}
@@ -0,0 +1,213 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A parsed patch, on the unified diff format, as produced by "git diff" and
+// "git format-patch".
+type patch struct {
+	// Text before the first file, such as the headers and message of the
+	// commits of "git format-patch".
+	Message string
+	Files   []file
+}
+
+type file struct {
+	OldName string
+	NewName string
+	// Lines between the start of the file and it's first hunk, such as
+	// "new file mode 100644" and "Binary files differ".
+	Extended []string
+	Hunks    []hunk
+}
+
+// Returns the name shown on the header of the file.
+func (f file) name() string {
+	switch {
+	case f.OldName == f.NewName || f.OldName == "":
+		return f.NewName
+	case f.NewName == "":
+		return f.OldName
+	default:
+		return f.OldName + " → " + f.NewName
+	}
+}
+
+type hunk struct {
+	Header   string
+	OldLine  int
+	OldCount int
+	NewLine  int
+	NewCount int
+	Lines    []line
+
+	oldSeen int
+	newSeen int
+}
+
+type lineKind byte
+
+const (
+	contextLine lineKind = ' '
+	addedLine   lineKind = '+'
+	deletedLine lineKind = '-'
+	noteLine    lineKind = '\\'
+)
+
+type line struct {
+	Kind lineKind
+	Text string
+}
+
+func parse(src string) patch {
+	p := patch{}
+	var f *file
+	var h *hunk
+
+	message := []string{}
+
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+
+		switch {
+		case strings.HasPrefix(l, "diff "):
+			p.Files = append(p.Files, file{})
+			f, h = &p.Files[len(p.Files)-1], nil
+			if a, b, ok := gitNames(l); ok {
+				f.OldName, f.NewName = a, b
+			}
+
+		case strings.HasPrefix(l, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") &&
+			(h == nil || !h.remaining()):
+			if f == nil || len(f.Hunks) > 0 {
+				p.Files = append(p.Files, file{})
+				f = &p.Files[len(p.Files)-1]
+			}
+			h = nil
+			f.OldName = fileName(l[4:], "a/")
+			f.NewName = fileName(lines[i+1][4:], "b/")
+			i++
+
+		case strings.HasPrefix(l, "@@ ") && f != nil:
+			f.Hunks = append(f.Hunks, parseHunkHeader(l))
+			h = &f.Hunks[len(f.Hunks)-1]
+
+		case h != nil && h.remaining() && len(l) > 0 && strings.ContainsRune(" +-", rune(l[0])):
+			h.add(line{Kind: lineKind(l[0]), Text: l[1:]})
+
+		case h != nil && h.remaining() && l == "":
+			// Some tools strip the trailing space of empty context lines.
+			h.add(line{Kind: contextLine})
+
+		case h != nil && strings.HasPrefix(l, "\\"):
+			h.Lines = append(h.Lines, line{Kind: noteLine, Text: strings.TrimSpace(l[1:])})
+
+		case f != nil && len(f.Hunks) == 0:
+			f.Extended = append(f.Extended, l)
+
+		case f == nil:
+			message = append(message, l)
+
+		default:
+			// Text after the hunks, such as the signature of "git format-patch",
+			// which isn't rendered.
+			h = nil
+		}
+	}
+
+	p.Message = strings.TrimRight(strings.Join(message, "\n"), "\n")
+
+	return p
+}
+
+// Reports if the hunk doesn't have all the lines counted on it's header yet, so
+// lines such as "--- a" are deleted lines and not the start of another file.
+func (h *hunk) remaining() bool {
+	return h.oldSeen < h.OldCount || h.newSeen < h.NewCount
+}
+
+func (h *hunk) add(l line) {
+	if l.Kind != addedLine {
+		h.oldSeen++
+	}
+	if l.Kind != deletedLine {
+		h.newSeen++
+	}
+	h.Lines = append(h.Lines, l)
+}
+
+// Parses the names of "diff --git a/<old> b/<new>" lines.
+func gitNames(l string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(l, "diff --git ")
+	if !ok {
+		return "", "", false
+	}
+	a, b, ok := strings.Cut(rest, " b/")
+	if !ok {
+		return "", "", false
+	}
+	return strings.TrimPrefix(a, "a/"), b, true
+}
+
+func fileName(s, prefix string) string {
+	if i := strings.IndexByte(s, '\t'); i != -1 {
+		s = s[:i]
+	}
+	if s == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(s, prefix)
+}
+
+// Parses "@@ -<old>[,<count>] +<new>[,<count>] @@ <section>" lines.
+func parseHunkHeader(l string) hunk {
+	h := hunk{Header: l, OldLine: 1, OldCount: 1, NewLine: 1, NewCount: 1}
+
+	fields := strings.Fields(l)
+	for _, f := range fields[1:] {
+		if f == "@@" {
+			break
+		}
+		start, count, hasCount := strings.Cut(f[1:], ",")
+		n, err := strconv.Atoi(start)
+		if err != nil {
+			continue
+		}
+		c := 1
+		if hasCount {
+			if c, err = strconv.Atoi(count); err != nil {
+				continue
+			}
+		}
+		switch f[0] {
+		case '-':
+			h.OldLine, h.OldCount = n, c
+		case '+':
+			h.NewLine, h.NewCount = n, c
+		}
+	}
+
+	return h
+}
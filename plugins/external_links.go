@@ -0,0 +1,217 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const externalLinksName = "blogo-externallinks-renderer"
+
+// Creates a [plugin.Renderer] that transforms already rendered HTML, decorating
+// links to external sites with the "rel" and "target" attributes, and optionally
+// a icon, configured by [ExternalLinksOpts].
+//
+// A link is considered external if it is a absolute URL with a host not present
+// on [ExternalLinksOpts].Hosts. Relative links are always internal.
+//
+// This renderer is intended to be used after other renderers in a [FoldingRenderer].
+func NewExternalLinks(opts ...ExternalLinksOpts) plugin.Renderer {
+	opt := ExternalLinksOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Rel == nil {
+		opt.Rel = []string{"noopener", "noreferrer"}
+	}
+
+	// Hosts of links are compared lowercased.
+	hosts := make([]string, len(opt.Hosts))
+	for i, h := range opt.Hosts {
+		hosts[i] = strings.ToLower(h)
+	}
+	nofollowDomains := make([]string, len(opt.NofollowDomains))
+	for i, d := range opt.NofollowDomains {
+		nofollowDomains[i] = strings.ToLower(strings.TrimPrefix(d, "."))
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &externalLinks{
+		hosts:           hosts,
+		rel:             opt.Rel,
+		target:          opt.Target,
+		icon:            opt.Icon,
+		nofollowDomains: nofollowDomains,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type ExternalLinksOpts struct {
+	// Hosts of the site itself, links to them are not considered external.
+	Hosts []string
+
+	// Values added to the "rel" attribute of external links. Defaults to
+	// "noopener" and "noreferrer", use a empty non-nil slice to not add any.
+	Rel []string
+	// Value of the "target" attribute of external links, such as "_blank".
+	// Empty values do not add the attribute.
+	Target string
+	// Raw HTML appended to the end of the contents of external links, such as
+	// a icon or a screen reader only text.
+	Icon string
+
+	// Domains which links should have "rel=nofollow". Subdomains of the
+	// specified domains are also matched.
+	NofollowDomains []string
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type externalLinks struct {
+	hosts           []string
+	rel             []string
+	target          string
+	icon            string
+	nofollowDomains []string
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (r *externalLinks) Name() string {
+	return externalLinksName
+}
+
+func (r *externalLinks) Render(src fs.File, w io.Writer) error {
+	r.assert.NotNil(src)
+	r.assert.NotNil(w)
+	r.assert.NotNil(r.log)
+
+	if _, ok := src.(fs.ReadDirFile); ok {
+		return errors.New("does not support directories")
+	}
+
+	contents, err := io.ReadAll(src)
+	if err != nil {
+		return errors.Join(errors.New("failed to read file contents"), err)
+	}
+
+	// Depth of external links, so the icon is added just at the end of them.
+	external := []bool{}
+
+	for _, tok := range tokenizeHTML(contents) {
+		raw := tok.Raw
+
+		switch {
+		case tok.Kind == htmlStartTagToken && tok.Tag == "a":
+			attrs := parseHTMLAttrs(tok)
+			href, _ := getHTMLAttr(attrs, "href")
+
+			host, ok := r.externalHost(href)
+			external = append(external, ok)
+
+			if ok {
+				raw = renderHTMLStartTag("a", r.decorate(attrs, host), false)
+			}
+
+		case tok.Kind == htmlEndTagToken && tok.Tag == "a" && len(external) > 0:
+			if external[len(external)-1] && r.icon != "" {
+				if _, err := io.WriteString(w, r.icon); err != nil {
+					return err
+				}
+			}
+			external = external[:len(external)-1]
+		}
+
+		if _, err := w.Write(raw); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *externalLinks) externalHost(href string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return "", false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if slices.Contains(r.hosts, host) {
+		return "", false
+	}
+
+	return host, true
+}
+
+func (r *externalLinks) decorate(attrs []htmlAttr, host string) []htmlAttr {
+	rel := []string{}
+	if v, ok := getHTMLAttr(attrs, "rel"); ok {
+		rel = strings.Fields(v)
+	}
+
+	add := slices.Clone(r.rel)
+	if r.nofollow(host) {
+		add = append(add, "nofollow")
+	}
+
+	for _, v := range add {
+		if !slices.Contains(rel, v) {
+			rel = append(rel, v)
+		}
+	}
+
+	if len(rel) > 0 {
+		attrs = setHTMLAttr(attrs, "rel", strings.Join(rel, " "))
+	}
+	if r.target != "" {
+		attrs = setHTMLAttr(attrs, "target", r.target)
+	}
+
+	return attrs
+}
+
+func (r *externalLinks) nofollow(host string) bool {
+	for _, d := range r.nofollowDomains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"forge.capytal.company/loreddev/blogo/plugins"
+)
+
+func TestExternalLinksHostsCase(t *testing.T) {
+	fsys := fstest.MapFS{"index.html": {Data: []byte(
+		`<a href="https://example.com/a">a</a><a href="https://other.com/b">b</a>`,
+	)}}
+	f, err := fsys.Open("index.html")
+	if err != nil {
+		t.Fatalf("failed to open file: %s", err)
+	}
+
+	r := plugins.NewExternalLinks(plugins.ExternalLinksOpts{
+		Hosts:           []string{"Example.COM"},
+		NofollowDomains: []string{".Other.com"},
+	})
+
+	var buf bytes.Buffer
+	if err := r.Render(f, &buf); err != nil {
+		t.Fatalf("failed to render: %s", err)
+	}
+
+	internal, external, _ := strings.Cut(buf.String(), "</a>")
+	if strings.Contains(internal, "rel=") {
+		t.Fatalf("expected link to host of the site to be internal, got %q", internal)
+	}
+	if !strings.Contains(external, "nofollow") {
+		t.Fatalf("expected link to nofollow domain to have nofollow, got %q", external)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package feed
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// Returned by [Duration] when the format of the audio isn't supported.
+var ErrUnsupportedAudio = errors.New("unsupported audio format")
+
+// Reads the duration of a MP3 or MPEG-4 audio file (such as ".m4a"), with the size
+// in bytes. MPEG-4 files need to implement [io.ReaderAt], since their metadata
+// can be at the end of the file.
+//
+// The duration of MP3 files is read from their Xing/Info header if present,
+// otherwise it's estimated from the bitrate of the first frame.
+func Duration(r io.Reader, size int64, ext string) (time.Duration, error) {
+	switch strings.ToLower(ext) {
+	case ".mp3":
+		return mp3Duration(r, size)
+	case ".m4a", ".mp4", ".m4b", ".aac":
+		ra, ok := r.(io.ReaderAt)
+		if !ok {
+			return 0, errors.New("MPEG-4 audio file does not implement io.ReaderAt")
+		}
+		return mp4Duration(ra, size)
+	default:
+		return 0, ErrUnsupportedAudio
+	}
+}
+
+var (
+	mp3Bitrates = [2][16]int{
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	}
+	mp3SampleRates = [4][3]int{
+		{11025, 12000, 8000},  // MPEG 2.5
+		{},                    // Reserved
+		{22050, 24000, 16000}, // MPEG 2
+		{44100, 48000, 32000}, // MPEG 1
+	}
+)
+
+func mp3Duration(r io.Reader, size int64) (time.Duration, error) {
+	header := make([]byte, 128<<10)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return 0, err
+	}
+	header = header[:n]
+
+	offset := 0
+	if len(header) >= 10 && bytes.HasPrefix(header, []byte("ID3")) {
+		// ID3v2 tag sizes are synchsafe integers, 7 bits per byte.
+		s := int(header[6])<<21 | int(header[7])<<14 | int(header[8])<<7 | int(header[9])
+		offset = 10 + s
+	}
+
+	for ; offset+4 <= len(header); offset++ {
+		if header[offset] == 0xFF && header[offset+1]&0xE0 == 0xE0 {
+			break
+		}
+	}
+	if offset+4 > len(header) {
+		return 0, errors.New("failed to find MP3 frame")
+	}
+
+	h := binary.BigEndian.Uint32(header[offset:])
+	version := (h >> 19) & 3
+	layer := (h >> 17) & 3
+	bitrateIndex := (h >> 12) & 0xF
+	rateIndex := (h >> 10) & 3
+	mono := (h>>6)&3 == 3
+
+	if version == 1 || layer != 1 || rateIndex == 3 {
+		return 0, ErrUnsupportedAudio
+	}
+
+	mpeg1 := version == 3
+	sampleRate := mp3SampleRates[version][rateIndex]
+	samplesPerFrame := 576
+	bitrates := mp3Bitrates[1]
+	if mpeg1 {
+		samplesPerFrame, bitrates = 1152, mp3Bitrates[0]
+	}
+
+	sideInfo := 17
+	switch {
+	case mpeg1 && !mono:
+		sideInfo = 32
+	case !mpeg1 && mono:
+		sideInfo = 9
+	}
+
+	if x := offset + 4 + sideInfo; x+12 <= len(header) {
+		tag := string(header[x : x+4])
+		flags := binary.BigEndian.Uint32(header[x+4:])
+		if (tag == "Xing" || tag == "Info") && flags&1 == 1 {
+			frames := binary.BigEndian.Uint32(header[x+8:])
+			return time.Duration(frames) * time.Duration(samplesPerFrame) * time.Second /
+				time.Duration(sampleRate), nil
+		}
+	}
+
+	bitrate := bitrates[bitrateIndex] * 1000
+	if bitrate == 0 {
+		return 0, errors.New("free format MP3 files are not supported")
+	}
+
+	return time.Duration(size-int64(offset)) * 8 * time.Second / time.Duration(bitrate), nil
+}
+
+func mp4Duration(r io.ReaderAt, size int64) (time.Duration, error) {
+	moov, moovSize, err := findAtom(r, 0, size, "moov")
+	if err != nil {
+		return 0, err
+	}
+	mvhd, _, err := findAtom(r, moov, moovSize, "mvhd")
+	if err != nil {
+		return 0, err
+	}
+
+	b := make([]byte, 32)
+	if _, err := r.ReadAt(b, mvhd); err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+
+	var timescale, duration uint64
+	if b[0] == 1 {
+		timescale = uint64(binary.BigEndian.Uint32(b[20:]))
+		duration = binary.BigEndian.Uint64(b[24:])
+	} else {
+		timescale = uint64(binary.BigEndian.Uint32(b[12:]))
+		duration = uint64(binary.BigEndian.Uint32(b[16:]))
+	}
+	if timescale == 0 {
+		return 0, errors.New("invalid MPEG-4 timescale")
+	}
+
+	return time.Duration(duration * uint64(time.Second) / timescale), nil
+}
+
+// Returns the offset and size of the contents of the first atom of the type,
+// between the start offset and the end.
+func findAtom(r io.ReaderAt, start, length int64, typ string) (int64, int64, error) {
+	b := make([]byte, 16)
+	for offset := start; offset+8 <= start+length; {
+		if _, err := r.ReadAt(b[:8], offset); err != nil {
+			return 0, 0, err
+		}
+
+		size := int64(binary.BigEndian.Uint32(b))
+		header := int64(8)
+		if size == 1 {
+			if _, err := r.ReadAt(b[8:16], offset+8); err != nil {
+				return 0, 0, err
+			}
+			size = int64(binary.BigEndian.Uint64(b[8:]))
+			header = 16
+		} else if size == 0 {
+			size = start + length - offset
+		}
+		if size < header {
+			return 0, 0, errors.New("invalid MPEG-4 atom size")
+		}
+
+		if string(b[4:8]) == typ {
+			return offset + header, size - header, nil
+		}
+
+		offset += size
+	}
+
+	return 0, 0, errors.New("MPEG-4 atom " + typ + " not found")
+}
@@ -0,0 +1,549 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package feed provides a [plugin.Middleware] that serves RSS and Atom feeds of
+// the posts of the blog, built from a [index.Index], including podcast feeds of
+// posts that reference audio files.
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/canonical"
+	"forge.capytal.company/loreddev/blogo/index"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/slug"
+	"forge.capytal.company/loreddev/blogo/visibility"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-feed-middleware"
+
+// Format of a feed.
+type Format int
+
+const (
+	RSS Format = iota
+	Atom
+)
+
+// Creates a [plugin.Middleware] that serves a feed of the entries of the index on
+// Opts.Path. Entries are filtered by the visibility rules with [visibility.Feed],
+// and by Opts.Filter if provided.
+//
+// If Opts.TagFeeds or Opts.SectionFeeds are set, feeds of only the entries with
+// a tag or on a directory are also served, on the tag or directory path followed
+// by the file name of Opts.Path, such as "/tags/go/feed.xml" and "/notes/feed.xml".
+// These feeds use the same filters and visibility rules of the main feed.
+//
+// If Opts.Hub is set, feeds link to the WebSub hub (https://www.w3.org/TR/websub),
+// on the feed itself and on the "Link" header, so subscribers can be notified
+// of updates by the hub. The hub needs to be notified when feeds change, see
+// the ping package.
+func New(provider index.Provider, opts ...Opts) plugin.Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Path == "" {
+		if opt.Format == Atom {
+			opt.Path = "/atom.xml"
+		} else {
+			opt.Path = "/feed.xml"
+		}
+	}
+	if opt.TagsPath == "" {
+		opt.TagsPath = "/tags"
+	}
+	if opt.Slugifier == nil {
+		opt.Slugifier = slug.Default
+	}
+	if opt.Limit == 0 {
+		opt.Limit = 20
+	}
+	if opt.Filter == nil {
+		opt.Filter = func(e index.Entry) bool { return true }
+	}
+	if opt.Visibility == nil {
+		opt.Visibility = visibility.Default
+	}
+	if opt.Podcast != nil {
+		opt.Format = RSS
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(provider, "Index provider should not be nil")
+
+	return &p{
+		provider: provider,
+
+		path:        opt.Path,
+		format:      opt.Format,
+		title:       opt.Title,
+		description: opt.Description,
+		baseURL:     strings.TrimSuffix(opt.BaseURL, "/"),
+		canonical:   opt.Canonical,
+		language:    opt.Language,
+		author:      opt.Author,
+		limit:       opt.Limit,
+		filter:      opt.Filter,
+		visibility:  opt.Visibility,
+		podcast:     opt.Podcast,
+		hub:         opt.Hub,
+
+		tagFeeds:     opt.TagFeeds,
+		tagsPath:     "/" + strings.Trim(opt.TagsPath, "/"),
+		slugifier:    opt.Slugifier,
+		sectionFeeds: opt.SectionFeeds,
+
+		durations: map[string]durationEntry{},
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Path that the feed is served on. Defaults to "/feed.xml" for RSS and
+	// "/atom.xml" for Atom feeds.
+	Path string
+	// Format of the feed. Defaults to [RSS], podcast feeds are always RSS.
+	Format Format
+
+	Title       string
+	Description string
+	// Base URL of the blog, used to create absolute links, for example
+	// "https://example.com".
+	BaseURL  string
+	Language string
+	Author   string
+	// Canonical form of the links to the entries and pages of the feeds, which
+	// should be the same of the canonical middleware if it's used. By default
+	// links are not changed.
+	Canonical canonical.Policy
+
+	// Serves feeds of the entries with each tag, on Opts.TagsPath followed by
+	// the tag and the file name of Opts.Path, such as "/tags/go/feed.xml".
+	TagFeeds bool
+	// Path of tag feeds. Defaults to "/tags".
+	TagsPath string
+	// Slugifier of the tags on the paths of tag feeds, so "/tags/open-source/feed.xml"
+	// is the feed of the "Open Source" tag. Tags are also matched as they are,
+	// lowercased. Defaults to [slug.Default].
+	Slugifier slug.Slugifier
+	// Serves feeds of the entries on each directory, on the directory path
+	// followed by the file name of Opts.Path, such as "/notes/feed.xml".
+	SectionFeeds bool
+
+	// Max number of entries on the feed. Defaults to 20, negative values
+	// disable the limit.
+	Limit int
+	// Reports if a entry should be on the feed. By default all entries are.
+	Filter func(index.Entry) bool
+	// Rules used to hide entries from the feed. Defaults to [visibility.Default].
+	Visibility visibility.Rules
+
+	// URL of the WebSub hub that feeds are published to, such as
+	// "https://pubsubhubbub.appspot.com". If empty, feeds don't link to a hub.
+	Hub string
+
+	// Makes the feed a podcast feed, see [Podcast].
+	Podcast *Podcast
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	provider index.Provider
+
+	path        string
+	format      Format
+	title       string
+	description string
+	baseURL     string
+	language    string
+	author      string
+	canonical   canonical.Policy
+	limit       int
+	filter      func(index.Entry) bool
+	visibility  visibility.Rules
+	podcast     *Podcast
+	hub         string
+
+	tagFeeds     bool
+	tagsPath     string
+	slugifier    slug.Slugifier
+	sectionFeeds bool
+
+	durationsMu sync.Mutex
+	durations   map[string]durationEntry
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.provider)
+		p.assert.NotNil(p.log)
+
+		s, ok := p.scope(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		log := p.log.With(slog.String("path", r.URL.Path))
+
+		idx, err := p.provider.Index()
+		if err != nil {
+			log.Error("Failed to get index for feed", slog.String("err", err.Error()))
+			http.Error(w, "Failed to generate feed", http.StatusInternalServerError)
+			return
+		}
+
+		entries := p.entries(idx, s)
+		if s.path != p.path && len(entries) == 0 {
+			log.Debug("No entries on scoped feed, skipping it")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var v any
+		contentType := "application/rss+xml; charset=utf-8"
+		switch {
+		case p.podcast != nil:
+			v = p.podcastRSS(idx, s, entries)
+		case p.format == Atom:
+			v = p.atom(s, entries)
+			contentType = "application/atom+xml; charset=utf-8"
+		default:
+			v = p.rss(s, entries)
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		if p.hub != "" {
+			w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"hub\"", p.hub))
+			w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"self\"", p.url(s.path)))
+		}
+		_, _ = io.WriteString(w, xml.Header)
+
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			log.Error("Failed to write feed", slog.String("err", err.Error()))
+		}
+	})
+}
+
+// The subset of entries of a feed, either the main feed or a tag or section feed.
+type scope struct {
+	// Path that the feed is served on.
+	path string
+	// Path of the page of the subset, such as "/tags/go/" or "/notes/".
+	link  string
+	title string
+	// Reports if a entry is on the subset, nil on the main feed.
+	filter func(index.Entry) bool
+	// Tag of the entries of the subset, looked up on the [index.Tags] taxonomy.
+	tag string
+}
+
+// Returns the scope of the feed served on the URL path, if any.
+func (p *p) scope(u string) (scope, bool) {
+	if u == p.path {
+		return scope{path: p.path, link: "/", title: p.title}, true
+	}
+	if (!p.tagFeeds && !p.sectionFeeds) || path.Base(u) != path.Base(p.path) {
+		return scope{}, false
+	}
+
+	dir := path.Dir(u)
+
+	if tag, ok := strings.CutPrefix(dir, p.tagsPath+"/"); p.tagFeeds && ok && !strings.Contains(tag, "/") {
+		return scope{
+			path:  u,
+			link:  dir + "/",
+			title: scopedTitle(p.title, tag),
+			tag:   strings.ToLower(tag),
+		}, true
+	}
+
+	if section := strings.TrimPrefix(dir, "/"); p.sectionFeeds && section != "" && section != "." {
+		return scope{
+			path:  u,
+			link:  dir + "/",
+			title: scopedTitle(p.title, path.Base(section)),
+			filter: func(e index.Entry) bool {
+				return strings.HasPrefix(e.Path, section+"/")
+			},
+		}, true
+	}
+
+	return scope{}, false
+}
+
+func scopedTitle(title, name string) string {
+	if title == "" {
+		return name
+	}
+	return title + " - " + name
+}
+
+// Returns the entries with the tag, or with the tags which slug is the tag, in
+// the order of the index.
+func (p *p) tagged(idx index.Index, tag string) []index.Entry {
+	if es := index.Term(idx, index.Tags, tag); len(es) > 0 {
+		return es
+	}
+
+	paths := map[string]struct{}{}
+	for _, tc := range index.Terms(idx, index.Tags) {
+		if p.slugifier.Slugify(tc.Term) != tag {
+			continue
+		}
+		for _, e := range index.Term(idx, index.Tags, tc.Term) {
+			paths[e.Path] = struct{}{}
+		}
+	}
+
+	entries := []index.Entry{}
+	for _, e := range idx.Entries() {
+		if _, ok := paths[e.Path]; ok {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func (p *p) entries(idx index.Index, s scope) []index.Entry {
+	candidates := idx.Entries()
+	if s.tag != "" {
+		candidates = p.tagged(idx, s.tag)
+	}
+
+	entries := []index.Entry{}
+	for _, e := range candidates {
+		if p.limit > 0 && len(entries) >= p.limit {
+			break
+		}
+		if !p.visibility.Visible(e.Path, e.Metadata, visibility.Feed) || !p.filter(e) {
+			continue
+		}
+		if s.filter != nil && !s.filter(e) {
+			continue
+		}
+		if p.podcast != nil && audioPath(e) == "" {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func (p *p) url(u string) string {
+	return p.baseURL + u
+}
+
+// Returns the absolute URL of the page, in the canonical form.
+func (p *p) link(u string) string {
+	return p.canonical.URL(p.baseURL, u)
+}
+
+func (p *p) updated(entries []index.Entry) time.Time {
+	t := time.Time{}
+	for _, e := range entries {
+		for _, d := range []time.Time{e.Date, e.Updated} {
+			if d.After(t) {
+				t = d
+			}
+		}
+	}
+	return t
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	ITunes  string     `xml:"xmlns:itunes,attr,omitempty"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string     `xml:"title"`
+	Link          string     `xml:"link"`
+	Description   string     `xml:"description"`
+	Language      string     `xml:"language,omitempty"`
+	LastBuildDate string     `xml:"lastBuildDate,omitempty"`
+	Links         []atomLink `xml:"atom:link"`
+	Items         []rssItem  `xml:"item"`
+
+	podcastChannel
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	GUID        rssGUID       `xml:"guid"`
+	PubDate     string        `xml:"pubDate,omitempty"`
+	Description string        `xml:"description,omitempty"`
+	Categories  []string      `xml:"category,omitempty"`
+	Enclosure   *rssEnclosure `xml:"enclosure,omitempty"`
+
+	podcastItem
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+func (p *p) rss(s scope, entries []index.Entry) rssFeed {
+	f := rssFeed{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       s.title,
+			Link:        p.link(s.link),
+			Description: p.description,
+			Language:    p.language,
+			Links: []atomLink{
+				{Href: p.url(s.path), Rel: "self", Type: "application/rss+xml"},
+			},
+			Items: make([]rssItem, 0, len(entries)),
+		},
+	}
+	if p.hub != "" {
+		f.Channel.Links = append(f.Channel.Links, atomLink{Href: p.hub, Rel: "hub"})
+	}
+	if u := p.updated(entries); !u.IsZero() {
+		f.Channel.LastBuildDate = u.Format(time.RFC1123Z)
+	}
+
+	for _, e := range entries {
+		item := rssItem{
+			Title:       e.Title,
+			Link:        p.link(e.URL),
+			GUID:        rssGUID{IsPermaLink: true, Value: p.link(e.URL)},
+			Description: e.Summary,
+			Categories:  e.Tags,
+		}
+		if !e.Date.IsZero() {
+			item.PubDate = e.Date.Format(time.RFC1123Z)
+		}
+		f.Channel.Items = append(f.Channel.Items, item)
+	}
+
+	return f
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Link       atomLink       `xml:"link"`
+	Summary    string         `xml:"summary,omitempty"`
+	Categories []atomCategory `xml:"category,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+func (p *p) atom(s scope, entries []index.Entry) atomFeed {
+	f := atomFeed{
+		ID:    p.link(s.link),
+		Title: s.title,
+		Links: []atomLink{
+			{Href: p.link(s.link)},
+			{Href: p.url(s.path), Rel: "self", Type: "application/atom+xml"},
+		},
+		Updated: p.updated(entries).Format(time.RFC3339),
+		Entries: make([]atomEntry, 0, len(entries)),
+	}
+	if p.hub != "" {
+		f.Links = append(f.Links, atomLink{Href: p.hub, Rel: "hub"})
+	}
+	if p.author != "" {
+		f.Author = &atomAuthor{Name: p.author}
+	}
+
+	for _, e := range entries {
+		updated := e.Updated
+		if updated.IsZero() {
+			updated = e.Date
+		}
+
+		entry := atomEntry{
+			ID:      p.link(e.URL),
+			Title:   e.Title,
+			Updated: updated.Format(time.RFC3339),
+			Link:    atomLink{Href: p.link(e.URL)},
+			Summary: e.Summary,
+		}
+		if !e.Date.IsZero() {
+			entry.Published = e.Date.Format(time.RFC3339)
+		}
+		for _, t := range e.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: t})
+		}
+
+		f.Entries = append(f.Entries, entry)
+	}
+
+	return f
+}
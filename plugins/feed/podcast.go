@@ -0,0 +1,199 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package feed
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/index"
+	"forge.capytal.company/loreddev/blogo/metadata"
+)
+
+// Metadata key of the audio file of a post, as a path on the file system (relative
+// to the post, or absolute if starting with "/") or a external URL.
+const AudioKey = "audio"
+
+// Metadata key of the duration of the audio of a post, such as "1:02:03" or
+// "45:00". If not present, the duration is read from the audio file.
+const DurationKey = "duration"
+
+// Information of a podcast feed, which only includes the posts that have a audio
+// file, set by their [AudioKey] metadata, as episodes.
+type Podcast struct {
+	Author     string
+	OwnerName  string
+	OwnerEmail string
+	// URL of the cover art of the podcast.
+	Image      string
+	Categories []string
+	Explicit   bool
+	// Either "episodic" (the default) or "serial".
+	Type string
+}
+
+const itunesNamespace = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+
+type podcastChannel struct {
+	ITunesAuthor     string           `xml:"itunes:author,omitempty"`
+	ITunesOwner      *itunesOwner     `xml:"itunes:owner,omitempty"`
+	ITunesImage      *itunesImage     `xml:"itunes:image,omitempty"`
+	ITunesCategories []itunesCategory `xml:"itunes:category,omitempty"`
+	ITunesExplicit   string           `xml:"itunes:explicit,omitempty"`
+	ITunesType       string           `xml:"itunes:type,omitempty"`
+}
+
+type podcastItem struct {
+	ITunesDuration string `xml:"itunes:duration,omitempty"`
+	ITunesExplicit string `xml:"itunes:explicit,omitempty"`
+	ITunesEpisode  int    `xml:"itunes:episode,omitempty"`
+}
+
+type itunesOwner struct {
+	Name  string `xml:"itunes:name,omitempty"`
+	Email string `xml:"itunes:email,omitempty"`
+}
+
+type itunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type itunesCategory struct {
+	Text string `xml:"text,attr"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type durationEntry struct {
+	modTime  time.Time
+	duration time.Duration
+}
+
+func (p *p) podcastRSS(idx index.Index, s scope, entries []index.Entry) rssFeed {
+	f := p.rss(s, entries)
+	f.ITunes = itunesNamespace
+
+	c := &f.Channel
+	c.ITunesAuthor = p.podcast.Author
+	if p.podcast.OwnerName != "" || p.podcast.OwnerEmail != "" {
+		c.ITunesOwner = &itunesOwner{Name: p.podcast.OwnerName, Email: p.podcast.OwnerEmail}
+	}
+	if p.podcast.Image != "" {
+		c.ITunesImage = &itunesImage{Href: p.podcast.Image}
+	}
+	for _, cat := range p.podcast.Categories {
+		c.ITunesCategories = append(c.ITunesCategories, itunesCategory{Text: cat})
+	}
+	c.ITunesExplicit = fmt.Sprint(p.podcast.Explicit)
+	c.ITunesType = p.podcast.Type
+	if c.ITunesType == "" {
+		c.ITunesType = "episodic"
+	}
+
+	for i, e := range entries {
+		item := &c.Items[i]
+		audio := audioPath(e)
+
+		if strings.HasPrefix(audio, "http://") || strings.HasPrefix(audio, "https://") {
+			item.Enclosure = &rssEnclosure{URL: audio, Type: mime.TypeByExtension(path.Ext(audio))}
+		} else {
+			item.Enclosure, item.ITunesDuration = p.enclosure(idx.FS(), e, audio)
+		}
+
+		if d, err := metadata.GetTyped[string](e.Metadata, DurationKey); err == nil && d != "" {
+			item.ITunesDuration = d
+		}
+		if explicit, err := metadata.GetTyped[bool](e.Metadata, "explicit"); err == nil {
+			item.ITunesExplicit = fmt.Sprint(explicit)
+		}
+		if episode, err := metadata.GetTyped[int](e.Metadata, "episode"); err == nil {
+			item.ITunesEpisode = episode
+		}
+	}
+
+	return f
+}
+
+func audioPath(e index.Entry) string {
+	a, err := metadata.GetTyped[string](e.Metadata, AudioKey)
+	if err != nil {
+		return ""
+	}
+	return a
+}
+
+func (p *p) enclosure(fsys fs.FS, e index.Entry, audio string) (*rssEnclosure, string) {
+	name := strings.TrimPrefix(audio, "/")
+	if !strings.HasPrefix(audio, "/") {
+		name = path.Join(path.Dir(e.Path), audio)
+	}
+
+	enc := &rssEnclosure{URL: p.url("/" + name), Type: mime.TypeByExtension(path.Ext(name))}
+	if enc.Type == "" {
+		enc.Type = "audio/mpeg"
+	}
+
+	log := p.log.With(slog.String("post", e.Path), slog.String("audio", name))
+
+	// The file is only opened if it's duration isn't cached, so file systems
+	// that implement fs.StatFS don't need to open it on each feed.
+	stat, err := fs.Stat(fsys, name)
+	if err != nil {
+		log.Warn("Failed to stat audio file of episode", slog.String("err", err.Error()))
+		return enc, ""
+	}
+	enc.Length = stat.Size()
+
+	p.durationsMu.Lock()
+	cached, ok := p.durations[name]
+	p.durationsMu.Unlock()
+	if ok && cached.modTime.Equal(stat.ModTime()) {
+		return enc, formatDuration(cached.duration)
+	}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		log.Warn("Failed to open audio file of episode", slog.String("err", err.Error()))
+		return enc, ""
+	}
+	defer f.Close()
+
+	d, err := Duration(f, stat.Size(), path.Ext(name))
+	if err != nil {
+		log.Debug("Failed to read duration of audio file", slog.String("err", err.Error()))
+		return enc, ""
+	}
+
+	p.durationsMu.Lock()
+	p.durations[name] = durationEntry{modTime: stat.ModTime(), duration: d}
+	p.durationsMu.Unlock()
+
+	return enc, formatDuration(d)
+}
+
+func formatDuration(d time.Duration) string {
+	s := int(d.Round(time.Second).Seconds())
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+}
@@ -0,0 +1,436 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package flags provides request-level feature flags, so new features of the
+// theme, experimental renderers or routes can be rolled out to a fraction of the
+// visitors before being enabled for everyone.
+//
+// The flags of each request are available to:
+//
+//   - middlewares and renderers that implement [plugin.ContextRenderer], with
+//     [FromContext] and [Enabled];
+//   - templates, with the "flag.<name>" metadata of files, or the "flag" function
+//     of the plugin's FuncMap.
+package flags
+
+import (
+	"context"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-flags-sourcer"
+
+// Prefix of the metadata keys with the state of each flag.
+const KeyPrefix = "flag."
+
+// A feature that can be enabled for some requests.
+type Flag struct {
+	Name string
+	// Enables the flag for all visitors.
+	Enabled bool
+	// Percentage of visitors, from 0 to 100, that have the flag enabled if it
+	// isn't enabled for everyone.
+	Percent int
+}
+
+// Reports if the flag is enabled for a new visitor.
+func (f Flag) roll() bool {
+	if f.Enabled || f.Percent >= 100 {
+		return true
+	}
+	if f.Percent <= 0 {
+		return false
+	}
+	return rand.IntN(100) < f.Percent
+}
+
+// The state of the flags of a request, by their name.
+type Flags map[string]bool
+
+// Reports if the flag is enabled. Unknown flags are disabled.
+func (f Flags) Enabled(name string) bool {
+	return f[name]
+}
+
+// Encodes the flags as a URL query, sorted by name, such as "lite=off&theme=on".
+func (f Flags) String() string {
+	q := url.Values{}
+	for n, v := range f {
+		if v {
+			q.Set(n, "on")
+		} else {
+			q.Set(n, "off")
+		}
+	}
+	return q.Encode()
+}
+
+type flagsKey struct{}
+
+// Returns the flags of the request of the context, set by the middleware of the
+// plugin.
+func FromContext(ctx context.Context) Flags {
+	f, ok := ctx.Value(flagsKey{}).(Flags)
+	if !ok {
+		return Flags{}
+	}
+	return maps.Clone(f)
+}
+
+// Reports if the flag is enabled for the request of the context.
+func Enabled(ctx context.Context, name string) bool {
+	f, _ := ctx.Value(flagsKey{}).(Flags)
+	return f.Enabled(name)
+}
+
+// The feature flags plugin, which wraps a [plugin.Sourcer] to keep it's last
+// file system and is a [plugin.Middleware] that sets the flags of requests.
+type Plugin interface {
+	plugin.Sourcer
+	plugin.Middleware
+
+	// Functions for templates:
+	//
+	//   - "flag METADATA NAME" reports if the flag is enabled on the render of the
+	//     file of the metadata.
+	FuncMap() template.FuncMap
+}
+
+// Creates the feature flags [Plugin] of the flags, wrapping the sourcer.
+//
+// The state of each flag is taken from the header of Opts.Header (for example
+// "X-Blogo-Flags: theme=on&lite=off", useful to test features), then the cookie
+// of Opts.Cookie, and otherwise is enabled for the percentage of visitors of the
+// flag. Assignments are kept on the cookie, so visitors see the same features on
+// every visit, and can opt in or out of features by changing it.
+//
+// Renders of each combination of flags are cached separately (see
+// [core.WithCacheVariant]).
+func New(sourcer plugin.Sourcer, flags []Flag, opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Header == "" {
+		opt.Header = "X-Blogo-Flags"
+	}
+	if opt.Cookie == "" {
+		opt.Cookie = "blogo-flags"
+	}
+	if opt.CookieMaxAge == 0 {
+		opt.CookieMaxAge = 30 * 24 * time.Hour
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be wrapped should not be nil")
+
+	flags = slices.DeleteFunc(slices.Clone(flags), func(f Flag) bool {
+		return f.Name == ""
+	})
+
+	return &p{
+		sourcer: sourcer,
+		flags:   flags,
+
+		header:       opt.Header,
+		cookie:       opt.Cookie,
+		cookieMaxAge: opt.CookieMaxAge,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Header of requests that overrides flags, in the same format as the cookie.
+	// Defaults to "X-Blogo-Flags".
+	Header string
+	// Cookie where the flags of visitors are kept, as a URL query of the flags
+	// and "on" or "off". Defaults to "blogo-flags".
+	Cookie string
+	// Max age of the cookie. Defaults to 30 days.
+	CookieMaxAge time.Duration
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	sourcer plugin.Sourcer
+	flags   []Flag
+
+	header       string
+	cookie       string
+	cookieMaxAge time.Duration
+
+	mu   sync.RWMutex
+	fsys fs.FS
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Source() (fs.FS, error) {
+	p.assert.NotNil(p.sourcer)
+
+	fsys, err := p.sourcer.Source()
+	if err != nil {
+		return fsys, err
+	}
+
+	p.mu.Lock()
+	p.fsys = fsys
+	p.mu.Unlock()
+
+	return fsys, nil
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(p.flags) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		state := p.selectFlags(w, r)
+
+		ctx := context.WithValue(r.Context(), flagsKey{}, state)
+
+		// Renders without any flag enabled are the same as the default ones.
+		if enabled := state.enabled(); len(enabled) > 0 {
+			p.mu.RLock()
+			fsys := p.fsys
+			p.mu.RUnlock()
+
+			if fsys != nil {
+				ctx = core.WithFiles(ctx, &flagsFS{FS: fsys, flags: state})
+			}
+			ctx = core.WithCacheVariant(ctx, cacheVariant(ctx, enabled))
+		}
+
+		w.Header().Add("Vary", "Cookie")
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (p *p) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"flag": func(m metadata.Metadata, name string) bool {
+			v, err := metadata.GetTyped[bool](m, KeyPrefix+name)
+			return err == nil && v
+		},
+	}
+}
+
+// Returns the flags of the request, assigning the flags of new visitors and
+// updating the cookie.
+func (p *p) selectFlags(w http.ResponseWriter, r *http.Request) Flags {
+	forced := parseFlags(r.Header.Get(p.header))
+
+	kept := Flags{}
+	if c, err := r.Cookie(p.cookie); err == nil {
+		kept = parseFlags(c.Value)
+	}
+
+	state := Flags{}
+	assigned := false
+	for _, f := range p.flags {
+		if v, ok := forced[f.Name]; ok {
+			state[f.Name] = v
+			continue
+		}
+		if f.Enabled {
+			state[f.Name] = true
+			continue
+		}
+		if v, ok := kept[f.Name]; ok {
+			state[f.Name] = v
+			continue
+		}
+
+		v := f.roll()
+
+		p.log.Debug("Assigned flag", slog.String("flag", f.Name), slog.Bool("enabled", v))
+
+		state[f.Name] = v
+		kept[f.Name] = v
+		assigned = true
+	}
+
+	if assigned {
+		http.SetCookie(w, &http.Cookie{
+			Name:     p.cookie,
+			Value:    kept.String(),
+			Path:     "/",
+			MaxAge:   int(p.cookieMaxAge.Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	return state
+}
+
+// Returns the names of the enabled flags, sorted.
+func (f Flags) enabled() []string {
+	names := []string{}
+	for n, v := range f {
+		if v {
+			names = append(names, n)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func parseFlags(s string) Flags {
+	f := Flags{}
+	if s == "" {
+		return f
+	}
+
+	q, err := url.ParseQuery(s)
+	if err != nil {
+		return f
+	}
+	for n := range q {
+		switch strings.ToLower(q.Get(n)) {
+		case "on", "1", "true":
+			f[n] = true
+		case "off", "0", "false":
+			f[n] = false
+		}
+	}
+	return f
+}
+
+// Returns the cache variant of the enabled flags, keeping the variant already
+// set on the context, if any, such as by the variant plugin.
+func cacheVariant(ctx context.Context, enabled []string) string {
+	v := "flags=" + strings.Join(enabled, ",")
+	if prev, ok := core.CacheVariantFromContext(ctx); ok {
+		return prev + "&" + v
+	}
+	return v
+}
+
+// File system that sets the state of the flags on the metadata of files.
+type flagsFS struct {
+	fs.FS
+	flags Flags
+}
+
+func (fsys *flagsFS) Metadata() metadata.Metadata {
+	if m, err := metadata.GetMetadata(fsys.FS); err == nil {
+		return m
+	}
+	return metadata.Map(map[string]any{})
+}
+
+func (fsys *flagsFS) Open(name string) (fs.File, error) {
+	f, err := fsys.FS.Open(name)
+	if err != nil {
+		return f, err
+	}
+
+	var fm metadata.Metadata = metadata.Map(map[string]any{})
+	if m, err := metadata.GetMetadata(f); err == nil {
+		fm = m
+	}
+
+	values := metadata.Map(make(map[string]any, len(fsys.flags)))
+	for n, v := range fsys.flags {
+		values[KeyPrefix+n] = v
+	}
+	m := metadata.Join(values, fm)
+
+	if d, ok := f.(fs.ReadDirFile); ok {
+		return &dirFile{ReadDirFile: d, metadata: m}, nil
+	}
+	if _, ok := f.(io.Seeker); ok {
+		return &seekerFile{file{File: f, metadata: m}}, nil
+	}
+	return &file{File: f, metadata: m}, nil
+}
+
+type file struct {
+	fs.File
+	metadata metadata.Metadata
+}
+
+func (f *file) Metadata() metadata.Metadata {
+	return f.metadata
+}
+
+// Keeps files that implement [io.Seeker] seekable, so renderers can read them
+// more than once.
+type seekerFile struct {
+	file
+}
+
+func (f *seekerFile) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+// Keeps directories readable, so listings can also depend on the flags.
+type dirFile struct {
+	fs.ReadDirFile
+	metadata metadata.Metadata
+}
+
+func (f *dirFile) Metadata() metadata.Metadata {
+	return f.metadata
+}
@@ -17,11 +17,13 @@ package plugins
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/fs"
 	"log/slog"
 
+	"forge.capytal.company/loreddev/blogo/metadata"
 	"forge.capytal.company/loreddev/blogo/plugin"
 	"forge.capytal.company/loreddev/x/tinyssert"
 )
@@ -90,6 +92,10 @@ func (r *foldingRenderer) Use(p plugin.Plugin) {
 }
 
 func (r *foldingRenderer) Render(src fs.File, w io.Writer) error {
+	return r.RenderContext(context.Background(), src, w)
+}
+
+func (r *foldingRenderer) RenderContext(ctx context.Context, src fs.File, w io.Writer) error {
 	r.assert.NotNil(r.plugins)
 	r.assert.NotNil(r.log)
 	r.assert.NotNil(src)
@@ -118,7 +124,7 @@ func (r *foldingRenderer) Render(src fs.File, w io.Writer) error {
 
 		log.Debug("Rendering with plugin")
 
-		err := p.Render(f, f)
+		err := plugin.RenderContext(ctx, p, f, f)
 		if err != nil {
 			log.Error("Failed to render with plugin", slog.String("err", err.Error()))
 			return err
@@ -157,6 +163,10 @@ func newFoldignFile(f fs.File) (*foldingFile, error) {
 	return &foldingFile{File: f, read: &r, writer: &w}, nil
 }
 
+func (f *foldingFile) Metadata() metadata.Metadata {
+	return getMetadataOrEmpty(f.File)
+}
+
 func (f *foldingFile) Close() error {
 	return nil
 }
@@ -0,0 +1,88 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frontmatter
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// Metadata key of the [Breadcrumb] trail of the file, set on all files which
+// frontmatter is parsed and on directories.
+const BreadcrumbsKey = "frontmatter.breadcrumbs"
+
+// A item of the trail of sections of a file, such as "Docs > Guides > Install".
+type Breadcrumb struct {
+	// Title of the section or file, from it's "title" metadata, or it's name.
+	Name string
+	// URL path of the section or file, from it's "permalink" metadata, or it's path
+	// without the extension.
+	URL string
+	// Path of the directory or file on the file system.
+	Path string
+}
+
+// Returns the trail of the sections of the directory, from the outermost, not
+// including the root of the file system. Sections are titled by the "title"
+// of their section files (see Opts.SectionFiles).
+func (fsys *frontmatterFS) trail(dir string) []Breadcrumb {
+	fsys.assert.NotNil(fsys.trails)
+
+	if dir == "." || dir == "" {
+		return []Breadcrumb{}
+	}
+
+	fsys.trailsMu.Lock()
+	t, ok := fsys.trails[dir]
+	fsys.trailsMu.Unlock()
+	if ok {
+		return t
+	}
+
+	own, _ := fsys.section(dir)
+	t = append(slices.Clone(fsys.trail(path.Dir(dir))), newBreadcrumb(dir, own))
+
+	fsys.trailsMu.Lock()
+	fsys.trails[dir] = t
+	fsys.trailsMu.Unlock()
+
+	return t
+}
+
+// Returns the trail of the file, ending with the file itself. Section files
+// end with their section instead, since they represent it.
+func (fsys *frontmatterFS) breadcrumbs(name string, m map[string]any) []Breadcrumb {
+	if slices.Contains(fsys.sectionFiles, path.Base(name)) {
+		return fsys.trail(path.Dir(name))
+	}
+	return append(slices.Clone(fsys.trail(path.Dir(name))), newBreadcrumb(name, m))
+}
+
+func newBreadcrumb(name string, m map[string]any) Breadcrumb {
+	b := Breadcrumb{
+		Name: strings.TrimSuffix(path.Base(name), path.Ext(name)),
+		URL:  "/" + strings.TrimSuffix(name, path.Ext(name)),
+		Path: name,
+	}
+	if t, ok := m["title"].(string); ok && t != "" {
+		b.Name = t
+	}
+	if p, ok := m["permalink"].(string); ok && p != "" {
+		b.URL = "/" + strings.TrimPrefix(p, "/")
+	}
+	return b
+}
@@ -0,0 +1,131 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frontmatter
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"path"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Key of the frontmatter of section index files that holds the values applied
+// to everything beneath the section.
+const CascadeKey = "cascade"
+
+// Default section files, see Opts.SectionFiles.
+var DefaultSectionFiles = []string{"_blogo.yaml", "_index.md"}
+
+// Returns the values cascaded to the files of the directory, joining the values
+// of all it's ancestors, with the nearest sections taking precedence.
+func (fsys *frontmatterFS) cascade(dir string) map[string]any {
+	fsys.assert.NotNil(fsys.cascades)
+
+	fsys.cascadesMu.Lock()
+	m, ok := fsys.cascades[dir]
+	fsys.cascadesMu.Unlock()
+	if ok {
+		return m
+	}
+
+	m = map[string]any{}
+	if dir != "." {
+		maps.Copy(m, fsys.cascade(path.Dir(dir)))
+	}
+	_, c := fsys.section(dir)
+	maps.Copy(m, c)
+
+	fsys.cascadesMu.Lock()
+	fsys.cascades[dir] = m
+	fsys.cascadesMu.Unlock()
+
+	return m
+}
+
+// Reads the section files of the directory, returning the metadata of the
+// section itself and the values cascaded to it's descendants.
+//
+// The whole contents of "_blogo.yaml" files are cascaded, while "_index.md" files
+// only cascade their [CascadeKey] value, the rest of their frontmatter being the
+// metadata of the section.
+func (fsys *frontmatterFS) section(dir string) (own map[string]any, cascade map[string]any) {
+	own, cascade = map[string]any{}, map[string]any{}
+
+	for _, name := range fsys.sectionFiles {
+		p := path.Join(dir, name)
+
+		contents, err := fs.ReadFile(fsys.FS, p)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			fsys.log.Warn("Failed to read section file",
+				slog.String("file", p), slog.String("err", err.Error()))
+			continue
+		}
+
+		var m map[string]any
+		if path.Ext(name) == ".yaml" || path.Ext(name) == ".yml" {
+			m = map[string]any{}
+			err = yaml.Unmarshal(contents, &m)
+			m = map[string]any{CascadeKey: m}
+		} else {
+			m, err = Parse(contents)
+		}
+		if err != nil {
+			fsys.log.Warn("Failed to parse section file",
+				slog.String("file", p), slog.String("err", err.Error()))
+			continue
+		}
+
+		if c, ok := stringKeys(m[CascadeKey]).(map[string]any); ok {
+			maps.Copy(cascade, c)
+		}
+		delete(m, CascadeKey)
+		maps.Copy(own, m)
+	}
+
+	return own, cascade
+}
+
+// Converts the map[any]any values decoded by YAML to map[string]any, recursively.
+func stringKeys(v any) any {
+	switch v := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = stringKeys(e)
+		}
+		return m
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[k] = stringKeys(e)
+		}
+		return m
+	case []any:
+		s := make([]any, len(v))
+		for i, e := range v {
+			s[i] = stringKeys(e)
+		}
+		return s
+	default:
+		return v
+	}
+}
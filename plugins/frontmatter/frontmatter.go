@@ -0,0 +1,556 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package frontmatter provides a [plugin.Sourcer] wrapper that parses the YAML
+// frontmatter of files and exposes it as [metadata.Metadata] of said files.
+//
+// This is the "metadata pass" of the pipeline: plugins that need information
+// about files, such as listings and feeds, should read the values from the file's
+// metadata instead of parsing the frontmatter themselves.
+package frontmatter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path"
+	"slices"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-frontmatter-sourcer"
+
+// Metadata key of the path of the file on the file system, set on all files
+// which frontmatter is parsed.
+const PathKey = "frontmatter.path"
+
+// Max size of the files which frontmatter is parsed, used if Opts.MaxFileSize
+// is zero.
+const DefaultMaxFileSize = 32 << 20
+
+var (
+	delimiter     = []byte("---")
+	lineSeparator = []byte("\n")
+)
+
+// Creates a new [plugin.Sourcer] that wraps the provided sourcer, parsing the frontmatter
+// of the files that have one of the extensions provided in [Opts].
+//
+// Values of the frontmatter are set directly on the metadata of the file, with the same
+// keys as found in the YAML header, so a file with "pinned: true" can be queried with
+// metadata.GetTyped[bool](file, "pinned").
+//
+// Directories opened by the file system return entries that also implement
+// [metadata.WithMetadata], so renderers of directories can access the frontmatter
+// of their children.
+//
+// Directories can have section files (see Opts.SectionFiles) that set default
+// values, such as layouts and visibility flags, for everything beneath them.
+// Values set by the files themselves always take precedence over cascaded ones.
+// The "title" of section files also names the sections on the [Breadcrumb] trail
+// of files, set as the [BreadcrumbsKey] metadata.
+func New(sourcer plugin.Sourcer, opts ...Opts) plugin.Sourcer {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Extensions == nil {
+		opt.Extensions = []string{".md"}
+	}
+	if opt.SummaryMarker == "" {
+		opt.SummaryMarker = DefaultSummaryMarker
+	}
+	if opt.SummaryWords == 0 {
+		opt.SummaryWords = DefaultSummaryWords
+	}
+	if opt.SectionFiles == nil {
+		opt.SectionFiles = DefaultSectionFiles
+	}
+	if opt.MaxFileSize == 0 {
+		opt.MaxFileSize = DefaultMaxFileSize
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be wrapped should not be nil")
+
+	return &p{
+		sourcer:    sourcer,
+		extensions: opt.Extensions,
+		parsers:    opt.Parsers,
+
+		summaryMarker: opt.SummaryMarker,
+		summaryWords:  opt.SummaryWords,
+		sectionFiles:  opt.SectionFiles,
+		processors:    opt.Processors,
+		maxFileSize:   opt.MaxFileSize,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+// Function that can change the metadata map of the file at name, before it is
+// exposed to other plugins.
+type Processor func(name string, m map[string]any)
+
+// Options used in the construction of the frontmatter sourcer in [New].
+type Opts struct {
+	// File extensions that should have their frontmatter parsed. Defaults to ".md".
+	Extensions []string
+	// Parsers of the frontmatter of files by their extension, used instead of
+	// the YAML header for formats that can't have one, such as Jupyter notebooks
+	// (see the ipynb package). The extensions also need to be on Extensions.
+	Parsers map[string]Parser
+
+	// Marker that separates the summary of a post from the rest of it's body, used
+	// if the frontmatter doesn't have a "summary" field. Defaults to [DefaultSummaryMarker].
+	SummaryMarker string
+	// Number of words of the body used as summary if the frontmatter doesn't have
+	// a "summary" field and the body doesn't have a SummaryMarker. Defaults to
+	// [DefaultSummaryWords], negative values disable the fallback.
+	SummaryWords int
+
+	// Files of directories that define the metadata of the directory itself and
+	// values cascaded to everything beneath it. The whole contents of YAML files
+	// are cascaded, while Markdown files only cascade their [CascadeKey] value,
+	// the rest of their frontmatter being the metadata of the directory.
+	// Defaults to [DefaultSectionFiles], a empty slice disables sections.
+	SectionFiles []string
+
+	// Functions called with the metadata of each parsed file, after the cascade of
+	// sections is applied, so other packages can derive values from it.
+	Processors []Processor
+
+	// Max size of the files which frontmatter is parsed, since their contents
+	// are read to memory. Opening larger files fails with a [core.FileSizeError],
+	// without reading more than the limit. Defaults to [DefaultMaxFileSize],
+	// negative values disable the limit.
+	MaxFileSize int64
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	sourcer    plugin.Sourcer
+	extensions []string
+	parsers    map[string]Parser
+
+	summaryMarker string
+	summaryWords  int
+	sectionFiles  []string
+	processors    []Processor
+	maxFileSize   int64
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Source() (fs.FS, error) {
+	p.assert.NotNil(p.sourcer)
+	p.assert.NotNil(p.log)
+
+	fsys, err := p.sourcer.Source()
+	if err != nil {
+		return fsys, err
+	}
+
+	return &frontmatterFS{
+		FS:         fsys,
+		extensions: p.extensions,
+		parsers:    p.parsers,
+
+		summaryMarker: p.summaryMarker,
+		summaryWords:  p.summaryWords,
+		sectionFiles:  p.sectionFiles,
+		processors:    p.processors,
+		maxFileSize:   p.maxFileSize,
+
+		cascades: map[string]map[string]any{},
+		trails:   map[string][]Breadcrumb{},
+
+		assert: p.assert,
+		log:    p.log,
+	}, nil
+}
+
+type frontmatterFS struct {
+	fs.FS
+	extensions []string
+	parsers    map[string]Parser
+
+	summaryMarker string
+	summaryWords  int
+	sectionFiles  []string
+	processors    []Processor
+	maxFileSize   int64
+
+	cascades   map[string]map[string]any
+	cascadesMu sync.Mutex
+
+	trails   map[string][]Breadcrumb
+	trailsMu sync.Mutex
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (fsys *frontmatterFS) Metadata() metadata.Metadata {
+	if m, err := metadata.GetMetadata(fsys.FS); err == nil {
+		return m
+	}
+	return metadata.Map(map[string]any{})
+}
+
+func (fsys *frontmatterFS) Open(name string) (fs.File, error) {
+	fsys.assert.NotNil(fsys.FS)
+	fsys.assert.NotNil(fsys.log)
+
+	f, err := fsys.FS.Open(name)
+	if err != nil {
+		return f, err
+	}
+
+	// Files of some file systems, such as [os.DirFS], implement fs.ReadDirFile
+	// even if they aren't directories.
+	if d, ok := f.(fs.ReadDirFile); ok && isDir(f) {
+		return &dirFile{ReadDirFile: d, fsys: fsys, path: name}, nil
+	}
+
+	if !slices.Contains(fsys.extensions, path.Ext(name)) {
+		return f, nil
+	}
+
+	contents, err := fsys.read(name, f)
+	if err != nil {
+		_ = f.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	var md metadata.Metadata = metadata.Map(fsys.parse(name, contents))
+	if fm, err := metadata.GetMetadata(f); err == nil {
+		md = metadata.Join(md, fm)
+	}
+
+	return &file{
+		File:     f,
+		reader:   bytes.NewReader(contents),
+		metadata: md,
+	}, nil
+}
+
+func isDir(f fs.File) bool {
+	stat, err := f.Stat()
+	return err != nil || stat.IsDir()
+}
+
+// Reads the contents of the file, up to the max file size.
+func (fsys *frontmatterFS) read(name string, f fs.File) ([]byte, error) {
+	if fsys.maxFileSize < 0 {
+		contents, err := io.ReadAll(f)
+		if err != nil {
+			return nil, errors.Join(errors.New("failed to read file contents"), err)
+		}
+		return contents, nil
+	}
+
+	if stat, err := f.Stat(); err == nil && stat.Size() > fsys.maxFileSize {
+		return nil, &core.FileSizeError{Name: name, Size: stat.Size(), Max: fsys.maxFileSize}
+	}
+
+	// The size of the file may not be known, such as on file systems of
+	// archives, so reads are also limited.
+	contents, err := io.ReadAll(io.LimitReader(f, fsys.maxFileSize+1))
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to read file contents"), err)
+	}
+	if int64(len(contents)) > fsys.maxFileSize {
+		return nil, &core.FileSizeError{Name: name, Size: int64(len(contents)), Max: fsys.maxFileSize}
+	}
+	return contents, nil
+}
+
+// Implements [fs.ReadDirFS], so walking the file system uses the fast path of
+// the underlying file system if it has one.
+func (fsys *frontmatterFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	es, err := fs.ReadDir(fsys.FS, name)
+
+	entries := make([]fs.DirEntry, len(es))
+	for i, e := range es {
+		entries[i] = &dirEntry{DirEntry: e, fsys: fsys, path: path.Join(name, e.Name())}
+	}
+
+	return entries, err
+}
+
+// Implements [fs.ReadFileFS]. The contents of files aren't changed by the
+// frontmatter, so they are read directly from the underlying file system, up
+// to the max file size.
+func (fsys *frontmatterFS) ReadFile(name string) ([]byte, error) {
+	if fsys.maxFileSize < 0 {
+		return fs.ReadFile(fsys.FS, name)
+	}
+
+	f, err := fsys.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	contents, err := fsys.read(name, f)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return contents, nil
+}
+
+// Implements [fs.StatFS].
+func (fsys *frontmatterFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(fsys.FS, name)
+}
+
+// Returns the metadata of the file with the contents, parsing it's frontmatter
+// and applying the summary, cascades and processors.
+func (fsys *frontmatterFS) parse(name string, contents []byte) map[string]any {
+	log := fsys.log.With(slog.String("file", name))
+	log.Debug("Parsing frontmatter of file")
+
+	parse := fsys.parsers[path.Ext(name)]
+	if parse == nil {
+		parse = parseYAML
+	}
+
+	m, body, err := parse(contents)
+	if err != nil {
+		log.Warn("Failed to parse frontmatter, ignoring it", slog.String("err", err.Error()))
+		m = map[string]any{}
+	}
+	if m == nil {
+		m = map[string]any{}
+	}
+
+	if _, ok := m[SummaryKey]; !ok {
+		if summary, ok := Summary(body, fsys.summaryMarker, fsys.summaryWords); ok {
+			m[SummaryKey] = summary
+		}
+	}
+
+	for k, v := range fsys.cascade(path.Dir(name)) {
+		if _, ok := m[k]; !ok {
+			m[k] = v
+		}
+	}
+
+	m[PathKey] = name
+
+	for _, process := range fsys.processors {
+		process(name, m)
+	}
+
+	m[BreadcrumbsKey] = fsys.breadcrumbs(name, m)
+
+	return m
+}
+
+// Parser of the frontmatter of files of other formats, such as notebooks, returning
+// the metadata and the text of the body used for the summary.
+type Parser func(contents []byte) (m map[string]any, body []byte, err error)
+
+func parseYAML(contents []byte) (map[string]any, []byte, error) {
+	m, err := Parse(contents)
+	return m, Body(contents), err
+}
+
+// Parses the YAML frontmatter of the contents, delimited by "---" lines at the start
+// of the file. Returns a empty map if there isn't any frontmatter.
+func Parse(contents []byte) (map[string]any, error) {
+	m := map[string]any{}
+
+	header, _, ok := split(contents)
+	if !ok {
+		return m, nil
+	}
+
+	if err := yaml.Unmarshal(header, &m); err != nil {
+		return map[string]any{}, errors.Join(errors.New("failed to parse YAML frontmatter"), err)
+	}
+	// Headers that are a null value, such as "~", make the map nil.
+	if m == nil {
+		m = map[string]any{}
+	}
+
+	return m, nil
+}
+
+// Returns the body of the contents, without the frontmatter header if present.
+func Body(contents []byte) []byte {
+	_, body, ok := split(contents)
+	if !ok {
+		return contents
+	}
+	return body
+}
+
+func split(contents []byte) (header []byte, body []byte, ok bool) {
+	contents = bytes.TrimPrefix(contents, []byte("\ufeff"))
+
+	first, rest, found := bytes.Cut(contents, lineSeparator)
+	if !found || !bytes.Equal(bytes.TrimSpace(first), delimiter) {
+		return nil, contents, false
+	}
+
+	for i := 0; i < len(rest); {
+		line, next, found := bytes.Cut(rest[i:], lineSeparator)
+		if bytes.Equal(bytes.TrimSpace(line), delimiter) {
+			return rest[:i], next, true
+		}
+		if !found {
+			break
+		}
+		i += len(line) + len(lineSeparator)
+	}
+
+	return nil, contents, false
+}
+
+type file struct {
+	fs.File
+	reader   *bytes.Reader
+	metadata metadata.Metadata
+}
+
+func (f *file) Metadata() metadata.Metadata {
+	return f.metadata
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	return f.reader.Read(p)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	return f.reader.Seek(offset, whence)
+}
+
+type dirFile struct {
+	fs.ReadDirFile
+	fsys *frontmatterFS
+	path string
+}
+
+func (f *dirFile) Metadata() metadata.Metadata {
+	own, _ := f.fsys.section(f.path)
+	for k, v := range f.fsys.cascade(path.Dir(f.path)) {
+		if _, ok := own[k]; !ok && f.path != "." {
+			own[k] = v
+		}
+	}
+
+	own[BreadcrumbsKey] = f.fsys.trail(f.path)
+
+	var m metadata.Metadata = metadata.Map(own)
+	if fm, err := metadata.GetMetadata(f.ReadDirFile); err == nil {
+		m = metadata.Join(m, fm)
+	}
+	return m
+}
+
+func (f *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	es, err := f.ReadDirFile.ReadDir(n)
+
+	entries := make([]fs.DirEntry, len(es))
+	for i, e := range es {
+		entries[i] = &dirEntry{DirEntry: e, fsys: f.fsys, path: path.Join(f.path, e.Name())}
+	}
+
+	return entries, err
+}
+
+// Implements [fs.DirEntry] and [metadata.WithMetadata], lazily reading the
+// file on the first call to Metadata to parse it's frontmatter. Renderers of
+// directories can also use Path and Open to access the file of the entry.
+type dirEntry struct {
+	fs.DirEntry
+	fsys *frontmatterFS
+	path string
+
+	once     sync.Once
+	metadata metadata.Metadata
+}
+
+func (e *dirEntry) Path() string {
+	return e.path
+}
+
+func (e *dirEntry) Open() (fs.File, error) {
+	return e.fsys.Open(e.path)
+}
+
+func (e *dirEntry) Metadata() metadata.Metadata {
+	e.once.Do(func() {
+		e.metadata = metadata.Map(map[string]any{})
+
+		if e.IsDir() {
+			return
+		}
+
+		// Files are opened the same way as by Open, so the metadata and the
+		// limit of the file size are the same.
+		f, err := e.fsys.Open(e.path)
+		if err != nil {
+			e.fsys.log.Warn("Failed to open directory entry for metadata",
+				slog.String("file", e.path), slog.String("err", err.Error()))
+			return
+		}
+		defer f.Close()
+
+		if m, err := metadata.GetMetadata(f); err == nil {
+			e.metadata = m
+		}
+	})
+	return e.metadata
+}
@@ -0,0 +1,112 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frontmatter_test
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugins/frontmatter"
+)
+
+type sourcer struct {
+	fsys fs.FS
+}
+
+func (s sourcer) Name() string {
+	return "test-sourcer"
+}
+
+func (s sourcer) Source() (fs.FS, error) {
+	return s.fsys, nil
+}
+
+func TestMaxFileSize(t *testing.T) {
+	fsys, err := frontmatter.New(sourcer{fstest.MapFS{
+		"small.md": {Data: []byte("---\ntitle: Small\n---\n")},
+		"large.md": {Data: append([]byte("---\nlarge: true\n---\n"), bytes.Repeat([]byte("a"), 64)...)},
+	}}, frontmatter.Opts{MaxFileSize: 32}).Source()
+	if err != nil {
+		t.Fatalf("failed to source files: %s", err)
+	}
+
+	f, err := fsys.Open("small.md")
+	if err != nil {
+		t.Fatalf("failed to open file smaller than the max size: %s", err)
+	}
+	_ = f.Close()
+
+	if _, err := fsys.Open("large.md"); !errors.As(err, new(*core.FileSizeError)) {
+		t.Fatalf("expected file size error opening file larger than the max size, got %v", err)
+	}
+
+	if _, err := fs.ReadFile(fsys, "large.md"); !errors.As(err, new(*core.FileSizeError)) {
+		t.Fatalf("expected file size error reading file larger than the max size, got %v", err)
+	}
+
+	es, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatalf("failed to read directory: %s", err)
+	}
+	for _, e := range es {
+		m, err := metadata.GetMetadata(e)
+		if err != nil {
+			t.Fatalf("expected metadata of directory entry %q: %s", e.Name(), err)
+		}
+		title, _ := metadata.GetTyped[string](m, "title")
+		if e.Name() == "small.md" && title != "Small" {
+			t.Fatalf("expected title of small file from it's directory entry, got %q", title)
+		}
+		if _, err := metadata.Get(m, "large"); e.Name() == "large.md" && err == nil {
+			t.Fatalf("expected no metadata of file larger than the max size")
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, s := range []string{
+		"",
+		"---\ntitle: Hello\n---\n# Hello\n",
+		"\ufeff---\r\ntitle: Hello\r\n---\r\nBody",
+		"---\n---\n",
+		"---\ntitle: [unclosed\n---\n",
+		"---\n&a [*a, *a]\n---\n",
+		"---\ntitle: Hello\n",
+		"# No frontmatter\n---\n",
+	} {
+		f.Add([]byte(s))
+	}
+
+	f.Fuzz(func(t *testing.T, contents []byte) {
+		m, err := frontmatter.Parse(contents)
+		if m == nil {
+			t.Fatalf("Parse should never return a nil map, error: %v", err)
+		}
+		if err != nil && len(m) != 0 {
+			t.Fatalf("Parse should return a empty map on errors, got %v", m)
+		}
+
+		body := frontmatter.Body(contents)
+		if !bytes.HasSuffix(contents, body) {
+			t.Fatalf("Body should be a suffix of the contents, got %q of %q", body, contents)
+		}
+	})
+}
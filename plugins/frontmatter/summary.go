@@ -0,0 +1,82 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frontmatter
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Metadata key of the summary of a file. Set from the frontmatter "summary" field
+	// or computed from the file's body.
+	SummaryKey = "summary"
+
+	DefaultSummaryMarker = "<!--more-->"
+	DefaultSummaryWords  = 50
+)
+
+var (
+	summaryImagePattern    = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	summaryLinkPattern     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	summaryTagPattern      = regexp.MustCompile(`<[^>]*>`)
+	summaryHeadingPattern  = regexp.MustCompile(`(?m)^\s{0,3}#{1,6}\s+`)
+	summaryListPattern     = regexp.MustCompile(`(?m)^\s*(?:[-*+>]|\d+\.)\s+`)
+	summaryEmphasisPattern = regexp.MustCompile("[*_`~]+")
+)
+
+// Computes the summary of a markdown body. If the body has the marker, the contents
+// before it are used, otherwise the first n words are used, adding a ellipsis if the
+// body is longer than that. Returns false if there is no summary, such as empty bodies
+// or when n is negative and there's no marker.
+//
+// The returned summary is plain text, with the markdown syntax and HTML tags stripped,
+// so it can be used directly on listings, feeds and meta descriptions.
+func Summary(body []byte, marker string, n int) (string, bool) {
+	if marker != "" {
+		if before, _, found := bytes.Cut(body, []byte(marker)); found {
+			s := plainText(before)
+			return s, s != ""
+		}
+	}
+
+	if n < 0 {
+		return "", false
+	}
+
+	words := strings.Fields(plainText(body))
+	if len(words) == 0 {
+		return "", false
+	}
+
+	if len(words) <= n {
+		return strings.Join(words, " "), true
+	}
+
+	return strings.Join(words[:n], " ") + "…", true
+}
+
+func plainText(src []byte) string {
+	src = summaryImagePattern.ReplaceAll(src, nil)
+	src = summaryLinkPattern.ReplaceAll(src, []byte("$1"))
+	src = summaryTagPattern.ReplaceAll(src, nil)
+	src = summaryHeadingPattern.ReplaceAll(src, nil)
+	src = summaryListPattern.ReplaceAll(src, nil)
+	src = summaryEmphasisPattern.ReplaceAll(src, nil)
+
+	return strings.Join(strings.Fields(string(src)), " ")
+}
@@ -0,0 +1,167 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gallery
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Returned when the image doesn't have EXIF data.
+var ErrNoEXIF = errors.New("image does not have EXIF data")
+
+// Subset of the EXIF data of images, used to create captions.
+type EXIF struct {
+	Description string
+	Artist      string
+	Make        string
+	Model       string
+	// When the picture was taken, or when the image was last changed if the
+	// original date isn't present.
+	Date time.Time
+}
+
+const (
+	tagDescription      = 0x010E
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagDateTime         = 0x0132
+	tagArtist           = 0x013B
+	tagExifIFD          = 0x8769
+	tagDateTimeOriginal = 0x9003
+)
+
+// Reads the EXIF data of a JPEG image.
+func ReadEXIF(jpeg []byte) (EXIF, error) {
+	if len(jpeg) < 4 || jpeg[0] != 0xFF || jpeg[1] != 0xD8 {
+		return EXIF{}, ErrNoEXIF
+	}
+
+	for i := 2; i+4 <= len(jpeg); {
+		if jpeg[i] != 0xFF {
+			return EXIF{}, ErrNoEXIF
+		}
+
+		marker := jpeg[i+1]
+		if marker == 0xDA || marker == 0xD9 { // Start of scan or end of image.
+			break
+		}
+
+		size := int(binary.BigEndian.Uint16(jpeg[i+2:]))
+		if size < 2 || i+2+size > len(jpeg) {
+			break
+		}
+		segment := jpeg[i+4 : i+2+size]
+
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return parseTIFF(segment[6:])
+		}
+
+		i += 2 + size
+	}
+
+	return EXIF{}, ErrNoEXIF
+}
+
+func parseTIFF(b []byte) (EXIF, error) {
+	if len(b) < 8 {
+		return EXIF{}, ErrNoEXIF
+	}
+
+	var order binary.ByteOrder
+	switch string(b[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return EXIF{}, errors.New("invalid TIFF header")
+	}
+
+	e := EXIF{}
+	var original, modified string
+
+	var readIFD func(offset uint32, depth int)
+	readIFD = func(offset uint32, depth int) {
+		if depth > 2 || int(offset)+2 > len(b) {
+			return
+		}
+
+		n := int(order.Uint16(b[offset:]))
+		for i := 0; i < n; i++ {
+			entry := int(offset) + 2 + i*12
+			if entry+12 > len(b) {
+				return
+			}
+
+			tag := order.Uint16(b[entry:])
+			typ := order.Uint16(b[entry+2:])
+			count := order.Uint32(b[entry+4:])
+			value := b[entry+8 : entry+12]
+
+			if tag == tagExifIFD {
+				readIFD(order.Uint32(value), depth+1)
+				continue
+			}
+			if typ != 2 { // Only ASCII values are used.
+				continue
+			}
+
+			s := ascii(b, order, count, value)
+			switch tag {
+			case tagDescription:
+				e.Description = s
+			case tagArtist:
+				e.Artist = s
+			case tagMake:
+				e.Make = s
+			case tagModel:
+				e.Model = s
+			case tagDateTime:
+				modified = s
+			case tagDateTimeOriginal:
+				original = s
+			}
+		}
+	}
+	readIFD(order.Uint32(b[4:]), 0)
+
+	for _, d := range []string{original, modified} {
+		if t, err := time.Parse("2006:01:02 15:04:05", d); err == nil {
+			e.Date = t
+			break
+		}
+	}
+
+	return e, nil
+}
+
+func ascii(b []byte, order binary.ByteOrder, count uint32, value []byte) string {
+	var s []byte
+	if count <= 4 {
+		s = value[:count]
+	} else {
+		offset := order.Uint32(value)
+		if uint64(offset)+uint64(count) > uint64(len(b)) {
+			return ""
+		}
+		s = b[offset : offset+count]
+	}
+	return strings.TrimSpace(strings.TrimRight(string(s), "\x00"))
+}
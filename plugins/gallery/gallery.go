@@ -0,0 +1,286 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gallery provides a [plugin.Renderer] that renders directories of images
+// as gallery pages, with thumbnails, captions and dates taken from the EXIF data
+// of the images, and markup ready to be used by lightbox scripts.
+package gallery
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/plugins/images"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-gallery-renderer"
+
+// Default extensions of images shown on galleries.
+var DefaultExtensions = []string{".jpg", ".jpeg", ".png", ".gif"}
+
+// Max number of bytes read from each image to get it's EXIF data and dimensions.
+const headerSize = 512 << 10
+
+var defaultTemplate = template.Must(template.New("gallery").Parse(
+	`<section class="gallery">
+{{- if .Title}}
+<h1>{{.Title}}</h1>
+{{- end}}
+{{- range .Images}}
+<figure class="gallery-item">
+<a href="{{.URL}}" data-lightbox="gallery"{{if .Caption}} data-caption="{{.Caption}}"{{end}}>
+<img src="{{.ThumbnailURL}}" alt="{{.Caption}}" loading="lazy"{{if .Width}} width="{{.Width}}" height="{{.Height}}"{{end}}>
+</a>
+{{- if or .Caption (not .Date.IsZero)}}
+<figcaption>
+{{- if .Caption}}{{.Caption}}{{end}}
+{{- if not .Date.IsZero}} <time datetime="{{.Date.Format "2006-01-02T15:04:05"}}">{{.Date.Format "January 2, 2006"}}</time>{{end -}}
+</figcaption>
+{{- end}}
+</figure>
+{{- end}}
+</section>
+`))
+
+// Creates a [plugin.Renderer] that renders directories as galleries, if their
+// "type" metadata is "gallery", or if all of their files are images. Any other
+// file or directory returns a error, so it can be used alongside other renderers
+// in a [MultiRenderer].
+//
+// The template is executed with a [Info] value.
+//
+// Images are read from the entries of the directory, which need to be able to
+// be opened, such as the ones provided by the frontmatter plugin.
+func New(opts ...Opts) plugin.Renderer {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Extensions == nil {
+		opt.Extensions = DefaultExtensions
+	}
+	if opt.ThumbnailWidth == 0 {
+		opt.ThumbnailWidth = 320
+	}
+	if opt.ThumbnailURL == nil {
+		opt.ThumbnailURL = images.URL
+	}
+	if opt.Template == nil {
+		opt.Template = defaultTemplate
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &p{
+		extensions:     opt.Extensions,
+		thumbnailWidth: opt.ThumbnailWidth,
+		thumbnailURL:   opt.ThumbnailURL,
+		templt:         opt.Template,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Extensions of the files considered images. Defaults to [DefaultExtensions].
+	Extensions []string
+	// Width of the thumbnails. Defaults to 320.
+	ThumbnailWidth int
+	// Returns the URL of the thumbnail of a image. Defaults to [images.URL],
+	// which is served by the images plugin.
+	ThumbnailURL func(path string, width int) string
+	// Template used to render galleries.
+	Template *template.Template
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+// Information passed to the gallery template.
+type Info struct {
+	Name     string
+	Title    string
+	Images   []Image
+	Metadata metadata.Metadata
+}
+
+// A image of a gallery.
+type Image struct {
+	Name         string
+	URL          string
+	ThumbnailURL string
+	// Caption of the image, from it's EXIF description, or it's file name.
+	Caption string
+	// Date of the image, from it's EXIF data.
+	Date time.Time
+	// Dimensions of the image, zero if they couldn't be read.
+	Width, Height int
+	EXIF          EXIF
+}
+
+type p struct {
+	extensions     []string
+	thumbnailWidth int
+	thumbnailURL   func(string, int) string
+	templt         *template.Template
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+func (p *p) Render(src fs.File, w io.Writer) error {
+	p.assert.NotNil(src)
+	p.assert.NotNil(w)
+	p.assert.NotNil(p.templt)
+	p.assert.NotNil(p.log)
+
+	d, ok := src.(fs.ReadDirFile)
+	if !ok {
+		return errors.New("does not support file, gallery renderer only renders directories")
+	}
+
+	stat, err := d.Stat()
+	if err != nil {
+		return errors.Join(errors.New("failed to stat directory"), err)
+	}
+
+	es, err := d.ReadDir(-1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return errors.Join(errors.New("failed to read directory entries"), err)
+	}
+
+	var md metadata.Metadata = metadata.Map(map[string]any{})
+	if dm, err := metadata.GetMetadata(src); err == nil {
+		md = dm
+	}
+
+	isGallery := false
+	if t, err := metadata.GetTyped[string](md, "type"); err == nil {
+		isGallery = t == "gallery"
+	}
+
+	imgs := []fs.DirEntry{}
+	for _, e := range es {
+		if e.IsDir() || strings.HasPrefix(e.Name(), "_") || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if slices.Contains(p.extensions, strings.ToLower(path.Ext(e.Name()))) {
+			imgs = append(imgs, e)
+		} else if !isGallery {
+			return errors.New("does not support directory, not all files are images")
+		}
+	}
+	if len(imgs) == 0 {
+		return errors.New("does not support directory, directory has no images")
+	}
+
+	log := p.log.With(slog.String("directory", stat.Name()))
+	log.Debug("Rendering gallery")
+
+	info := Info{Name: stat.Name(), Images: make([]Image, 0, len(imgs)), Metadata: md}
+	info.Title, _ = metadata.GetTyped[string](md, "title")
+
+	for _, e := range imgs {
+		info.Images = append(info.Images, p.image(e, log))
+	}
+
+	slices.SortStableFunc(info.Images, func(a, b Image) int {
+		if c := a.Date.Compare(b.Date); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	if err := p.templt.Execute(w, info); err != nil {
+		return errors.Join(errors.New("failed to execute gallery template"), err)
+	}
+
+	return nil
+}
+
+func (p *p) image(e fs.DirEntry, log *slog.Logger) Image {
+	url := e.Name()
+	if pe, ok := e.(interface{ Path() string }); ok {
+		url = "/" + pe.Path()
+	}
+
+	img := Image{
+		Name:         e.Name(),
+		URL:          url,
+		ThumbnailURL: p.thumbnailURL(url, p.thumbnailWidth),
+		Caption:      strings.TrimSuffix(e.Name(), path.Ext(e.Name())),
+	}
+
+	header, err := p.readHeader(e)
+	if err != nil {
+		log.Debug("Failed to read image", slog.String("image", e.Name()), slog.String("err", err.Error()))
+		return img
+	}
+
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(header)); err == nil {
+		img.Width, img.Height = cfg.Width, cfg.Height
+	}
+
+	if exif, err := ReadEXIF(header); err == nil {
+		img.EXIF = exif
+		img.Date = exif.Date
+		if exif.Description != "" {
+			img.Caption = exif.Description
+		}
+	}
+
+	return img
+}
+
+func (p *p) readHeader(e fs.DirEntry) ([]byte, error) {
+	o, ok := e.(interface{ Open() (fs.File, error) })
+	if !ok {
+		return nil, errors.New("directory entry can't be opened")
+	}
+
+	f, err := o.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(io.LimitReader(f, headerSize))
+}
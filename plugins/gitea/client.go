@@ -38,12 +38,22 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sync/atomic"
 	"time"
+
+	"forge.capytal.company/loreddev/blogo/credentials"
 )
 
 type client struct {
-	endpoint string
-	http     *http.Client
+	endpoint    string
+	http        *http.Client
+	token       string
+	credentials credentials.Provider
+
+	// Counters of the requests to the API, see [(*p).Metrics].
+	calls    atomic.Int64
+	failures atomic.Int64
+	fetched  atomic.Int64
 }
 
 func newClient(endpoint string, http *http.Client) *client {
@@ -125,6 +135,57 @@ func (c *client) GetSingleCommit(user, repo, commitID string) (*commit, *http.Re
 	return commit, res, err
 }
 
+func (c *client) ListCommits(
+	owner, repo, ref, filepath string,
+	limit int,
+) ([]*commitResponse, *http.Response, error) {
+	q := url.Values{}
+	q.Set("stat", "true")
+	q.Set("files", "true")
+	q.Set("verification", "true")
+	if ref != "" {
+		q.Set("sha", ref)
+	}
+	if filepath != "" && filepath != "." {
+		q.Set("path", filepath)
+	}
+	if limit > 0 {
+		q.Set("limit", fmt.Sprint(limit))
+	}
+
+	data, res, err := c.get(fmt.Sprintf("/repos/%s/%s/commits?%s", owner, repo, q.Encode()))
+	if err != nil {
+		return []*commitResponse{}, res, err
+	}
+
+	commits := make([]*commitResponse, 0)
+	if err := json.Unmarshal(data, &commits); err != nil {
+		return []*commitResponse{}, res, errors.Join(
+			errors.New("failed to parse JSON response from API"),
+			err,
+		)
+	}
+
+	return commits, res, nil
+}
+
+func (c *client) GetRepository(owner, repo string) (*repositoryResponse, *http.Response, error) {
+	data, res, err := c.get(fmt.Sprintf("/repos/%s/%s", owner, repo))
+	if err != nil {
+		return &repositoryResponse{}, res, err
+	}
+
+	r := new(repositoryResponse)
+	if err := json.Unmarshal(data, r); err != nil {
+		return &repositoryResponse{}, res, errors.Join(
+			errors.New("failed to parse JSON response from API"),
+			err,
+		)
+	}
+
+	return r, res, nil
+}
+
 func (c *client) GetFileReader(
 	owner, repo, ref, filepath string,
 	resolveLFS ...bool,
@@ -152,6 +213,35 @@ func (c *client) GetFileReader(
 	)
 }
 
+type fileOptions struct {
+	// NOTE: base64 encoded, not used on deletions
+	Content string `json:"content,omitempty"`
+	Message string `json:"message,omitempty"`
+	Branch  string `json:"branch,omitempty"`
+	// NOTE: required for updates and deletions
+	SHA string `json:"sha,omitempty"`
+}
+
+// Creates (with the POST method), updates (PUT) or deletes (DELETE) the file,
+// committing the change to the branch.
+func (c *client) ChangeFile(
+	method, owner, repo, filepath string,
+	opts fileOptions,
+) (*http.Response, error) {
+	body, err := json.Marshal(opts)
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to marshal request body"), err)
+	}
+
+	_, res, err := c.send(
+		method,
+		fmt.Sprintf("/repos/%s/%s/contents/%s", owner, repo, filepath),
+		bytes.NewReader(body),
+	)
+
+	return res, err
+}
+
 func (c *client) get(path string) ([]byte, *http.Response, error) {
 	body, res, err := c.getResponseReader(path)
 	if err != nil {
@@ -167,8 +257,71 @@ func (c *client) get(path string) ([]byte, *http.Response, error) {
 	return data, res, err
 }
 
+func (c *client) send(method, path string, body io.Reader) ([]byte, *http.Response, error) {
+	req, err := http.NewRequest(method, c.endpoint+path, body)
+	if err != nil {
+		return nil, nil, errors.Join(errors.New("failed to create request"), err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, nil, errors.Join(errors.New("failed to request"), err)
+	}
+	defer res.Body.Close()
+
+	if data, err := statusCodeToErr(res); err != nil {
+		return data, res, err
+	}
+
+	data, err := io.ReadAll(res.Body)
+	return data, res, err
+}
+
+func (c *client) do(req *http.Request) (*http.Response, error) {
+	token := c.token
+	if c.credentials != nil {
+		t, err := c.credentials.Token(req.Context())
+		if err != nil {
+			return nil, errors.Join(errors.New("failed to get access token"), err)
+		}
+		token = t.Value
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+
+	c.calls.Add(1)
+	res, err := c.http.Do(req)
+	if err != nil || res.StatusCode/100 != 2 {
+		c.failures.Add(1)
+	}
+	if err == nil {
+		res.Body = &countingBody{ReadCloser: res.Body, n: &c.fetched}
+	}
+	return res, err
+}
+
+// Body of a response that adds the number of read bytes to the counter.
+type countingBody struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
+
 func (c *client) getResponseReader(path string) (io.ReadCloser, *http.Response, error) {
-	res, err := c.http.Get(c.endpoint + path)
+	req, err := http.NewRequest(http.MethodGet, c.endpoint+path, nil)
+	if err != nil {
+		return nil, nil, errors.Join(errors.New("failed to create request"), err)
+	}
+
+	res, err := c.do(req)
 	if err != nil {
 		return nil, nil, errors.Join(errors.New("failed to request"), err)
 	}
@@ -247,3 +400,47 @@ type commit struct {
 	SHA     string    `json:"sha"`
 	Created time.Time `json:"created"`
 }
+
+type commitResponse struct {
+	URL     string `json:"url"`
+	SHA     string `json:"sha"`
+	HTMLURL string `json:"html_url"`
+
+	Commit struct {
+		Message string `json:"message"`
+		Author  struct {
+			Name  string    `json:"name"`
+			Email string    `json:"email"`
+			Date  time.Time `json:"date"`
+		} `json:"author"`
+
+		// NOTE: populated just when the "verification" query parameter is true
+		Verification *struct {
+			Verified bool   `json:"verified"`
+			Reason   string `json:"reason"`
+			Signer   *struct {
+				Name  string `json:"name"`
+				Email string `json:"email"`
+			} `json:"signer"`
+		} `json:"verification"`
+	} `json:"commit"`
+
+	// NOTE: populated just when the "files" query parameter is true
+	Files []struct {
+		Filename string `json:"filename"`
+		// NOTE: can be "added", "modified", "removed" or "renamed"
+		Status string `json:"status"`
+	} `json:"files"`
+
+	// NOTE: populated just when the "stat" query parameter is true
+	Stats *struct {
+		Total     int `json:"total"`
+		Additions int `json:"additions"`
+		Deletions int `json:"deletions"`
+	} `json:"stats"`
+}
+
+type repositoryResponse struct {
+	DefaultBranch string `json:"default_branch"`
+	HTMLURL       string `json:"html_url"`
+}
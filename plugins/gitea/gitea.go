@@ -16,12 +16,18 @@
 package gitea
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 
+	"forge.capytal.company/loreddev/blogo/credentials"
+	"forge.capytal.company/loreddev/blogo/history"
+	"forge.capytal.company/loreddev/blogo/httpclient"
 	"forge.capytal.company/loreddev/blogo/plugin"
 )
 
@@ -33,11 +39,29 @@ type p struct {
 	owner string
 	repo  string
 	ref   string
+
+	commitMessage func(action, path string) string
+
+	web        string
+	branch     string
+	branchOnce sync.Once
+
+	injectHTTPClient bool
 }
 
 type Opts struct {
 	HTTPClient *http.Client
 	Ref        string
+
+	// Access token used on requests to the API, needed to write files and to
+	// source private repositories.
+	Token string
+	// Provider of the access token, used instead of Token so tokens that expire
+	// are refreshed, see the credentials package.
+	Credentials credentials.Provider
+	// Returns the message of the commits of writes, where action is "create",
+	// "update" or "delete". Defaults to messages such as "Create posts/hello.md".
+	CommitMessage func(action, path string) string
 }
 
 func New(owner, repo, apiUrl string, opts ...Opts) plugin.Plugin {
@@ -46,8 +70,9 @@ func New(owner, repo, apiUrl string, opts ...Opts) plugin.Plugin {
 		opt = opts[0]
 	}
 
+	injectHTTPClient := opt.HTTPClient == nil
 	if opt.HTTPClient == nil {
-		opt.HTTPClient = http.DefaultClient
+		opt.HTTPClient = httpclient.Default()
 	}
 
 	u, err := url.Parse(apiUrl)
@@ -68,7 +93,13 @@ func New(owner, repo, apiUrl string, opts ...Opts) plugin.Plugin {
 		u.Path = strings.TrimSuffix(u.Path, "/api/v1")
 	}
 
+	if opt.CommitMessage == nil {
+		opt.CommitMessage = defaultCommitMessage
+	}
+
 	client := newClient(u.String(), opt.HTTPClient)
+	client.token = opt.Token
+	client.credentials = opt.Credentials
 
 	return &p{
 		client: client,
@@ -76,6 +107,12 @@ func New(owner, repo, apiUrl string, opts ...Opts) plugin.Plugin {
 		owner: owner,
 		repo:  repo,
 		ref:   opt.Ref,
+
+		commitMessage: opt.CommitMessage,
+
+		web: u.Scheme + "://" + u.Host,
+
+		injectHTTPClient: injectHTTPClient,
 	}
 }
 
@@ -83,6 +120,104 @@ func (p *p) Name() string {
 	return pluginName
 }
 
+// Implements [plugin.HTTPClientAware], using the client of the engine if no
+// client was provided on construction.
+func (p *p) SetHTTPClient(client *http.Client) {
+	if p.injectHTTPClient {
+		p.client.http = client
+	}
+}
+
 func (p *p) Source() (fs.FS, error) {
 	return newRepositoryFS(p.owner, p.repo, p.ref, p.client), nil
 }
+
+// Implements [plugin.Instrumented], with the number of requests to the API, of
+// failed requests and of fetched bytes.
+func (p *p) Metrics() map[string]int64 {
+	return map[string]int64{
+		"api_calls":     p.client.calls.Load(),
+		"api_errors":    p.client.failures.Load(),
+		"bytes_fetched": p.client.fetched.Load(),
+	}
+}
+
+// Implements [history.History], listing the commits of the ref of the repository
+// that changed the file at path.
+func (p *p) Commits(ctx context.Context, path string, limit int) ([]history.Commit, error) {
+	if err := ctx.Err(); err != nil {
+		return []history.Commit{}, err
+	}
+
+	list, _, err := p.client.ListCommits(p.owner, p.repo, p.ref, path, limit)
+	if err != nil {
+		return []history.Commit{}, errors.Join(errors.New("failed to list commits"), err)
+	}
+
+	commits := make([]history.Commit, len(list))
+	for i, c := range list {
+		commits[i] = history.Commit{
+			SHA:     c.SHA,
+			Message: c.Commit.Message,
+			Author: history.Author{
+				Name:  c.Commit.Author.Name,
+				Email: c.Commit.Author.Email,
+			},
+			Date:  c.Commit.Author.Date,
+			URL:   c.HTMLURL,
+			Files: make([]history.File, len(c.Files)),
+		}
+		for j, f := range c.Files {
+			commits[i].Files[j] = history.File{Path: f.Filename, Change: history.Change(f.Status)}
+		}
+		if c.Stats != nil {
+			commits[i].Additions, commits[i].Deletions = c.Stats.Additions, c.Stats.Deletions
+		}
+		if v := c.Commit.Verification; v != nil {
+			commits[i].Verification = &history.Verification{Verified: v.Verified, Reason: v.Reason}
+			if v.Signer != nil {
+				commits[i].Verification.Signer = history.Author{Name: v.Signer.Name, Email: v.Signer.Email}
+			}
+		}
+	}
+
+	return commits, nil
+}
+
+// Implements [history.EditLinker], returning the URL of the web editor of the
+// file on the branch of Opts.Ref, or the default branch of the repository.
+func (p *p) EditURL(path string) string {
+	return history.ForgejoEditURL(p.web, p.owner, p.repo, p.defaultBranch()).EditURL(path)
+}
+
+// Implements [history.IssueLinker], returning the URL of the new issue form of
+// the repository, with the path of the file prefilled.
+func (p *p) IssueURL(path string) string {
+	return history.ForgejoIssueURL(p.web, p.owner, p.repo).IssueURL(path)
+}
+
+// Returns the branch of Opts.Ref, or the default branch of the repository.
+func (p *p) defaultBranch() string {
+	p.branchOnce.Do(func() {
+		p.branch = p.ref
+		if p.branch != "" {
+			return
+		}
+		if r, _, err := p.client.GetRepository(p.owner, p.repo); err == nil {
+			p.branch = r.DefaultBranch
+		}
+		if p.branch == "" {
+			p.branch = "main"
+		}
+	})
+	return p.branch
+}
+
+// Implements [history.Revisions], returning the file system of the repository
+// at the revision.
+func (p *p) At(ctx context.Context, rev string) (fs.FS, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return newRepositoryFS(p.owner, p.repo, rev, p.client), nil
+}
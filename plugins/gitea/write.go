@@ -0,0 +1,116 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitea
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// Implements [plugin.WritableSourcer], committing the new file to the branch of
+// Opts.Ref, or the default branch of the repository. Needs a Opts.Token with
+// write access to the repository.
+func (p *p) Create(ctx context.Context, name string, data []byte) error {
+	if _, err := p.sha(ctx, name); err == nil {
+		return &fs.PathError{Op: "create", Path: name, Err: fs.ErrExist}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	_, err := p.client.ChangeFile(http.MethodPost, p.owner, p.repo, name, fileOptions{
+		Content: base64.StdEncoding.EncodeToString(data),
+		Message: p.commitMessage("create", name),
+		Branch:  p.defaultBranch(),
+	})
+	if err != nil {
+		return errors.Join(errors.New("failed to create file"), err)
+	}
+
+	return nil
+}
+
+// Implements [plugin.WritableSourcer], committing the changed file to the branch
+// of Opts.Ref, or the default branch of the repository.
+func (p *p) Update(ctx context.Context, name string, data []byte) error {
+	sha, err := p.sha(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.client.ChangeFile(http.MethodPut, p.owner, p.repo, name, fileOptions{
+		Content: base64.StdEncoding.EncodeToString(data),
+		Message: p.commitMessage("update", name),
+		Branch:  p.defaultBranch(),
+		SHA:     sha,
+	})
+	if err != nil {
+		return errors.Join(errors.New("failed to update file"), err)
+	}
+
+	return nil
+}
+
+// Implements [plugin.WritableSourcer], committing the deletion of the file to
+// the branch of Opts.Ref, or the default branch of the repository.
+func (p *p) Delete(ctx context.Context, name string) error {
+	sha, err := p.sha(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.client.ChangeFile(http.MethodDelete, p.owner, p.repo, name, fileOptions{
+		Message: p.commitMessage("delete", name),
+		Branch:  p.defaultBranch(),
+		SHA:     sha,
+	})
+	if err != nil {
+		return errors.Join(errors.New("failed to delete file"), err)
+	}
+
+	return nil
+}
+
+// Returns the blob SHA of the file on the branch, which the API needs to change
+// the file, or a error wrapping [fs.ErrNotExist] if it doesn't exist.
+func (p *p) sha(ctx context.Context, name string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if !fs.ValidPath(name) || name == "." {
+		return "", &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+
+	file, res, err := p.client.GetContents(p.owner, p.repo, p.defaultBranch(), name)
+	if res != nil && res.StatusCode == http.StatusNotFound {
+		return "", &fs.PathError{Op: "write", Path: name, Err: fs.ErrNotExist}
+	} else if err != nil {
+		return "", errors.Join(errors.New("failed to get file contents"), err)
+	}
+
+	if file.Type != "file" {
+		return "", &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+
+	return file.SHA, nil
+}
+
+func defaultCommitMessage(action, path string) string {
+	return strings.ToUpper(action[:1]) + action[1:] + " " + path
+}
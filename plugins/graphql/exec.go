@@ -0,0 +1,393 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// A object type of the schema.
+type objectType struct {
+	name   string
+	fields map[string]*fieldDef
+}
+
+// A field of a object type. Fields whose type is a object, or a list of objects,
+// have typ set and return either nil, the source value of the object or a []any
+// of source values. Other fields return values that are encoded as JSON.
+type fieldDef struct {
+	typ *objectType
+	// Names of the arguments that the field accepts.
+	args    []string
+	resolve func(req *request, src any, args map[string]any) (any, error)
+}
+
+// A error of a query, see https://spec.graphql.org/October2021/#sec-Errors.
+type queryError struct {
+	Message   string     `json:"message"`
+	Locations []location `json:"locations,omitempty"`
+	Path      []any      `json:"path,omitempty"`
+}
+
+func (err *queryError) Error() string {
+	return err.Message
+}
+
+type location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Execution of a operation.
+type request struct {
+	ctx       context.Context
+	doc       *document
+	variables map[string]any
+	maxDepth  int
+
+	errors []*queryError
+}
+
+// Executes the operation of the document with the name, or the only operation
+// if name is empty, starting on the object type of the root value. Errors of
+// the document, such as a unknown operation, are returned as err, errors of
+// fields are returned with the data.
+func execute(
+	ctx context.Context,
+	query *objectType,
+	root any,
+	doc *document,
+	name string,
+	variables map[string]any,
+	maxDepth int,
+) (data any, errs []*queryError, err error) {
+	var op *operation
+	for _, o := range doc.operations {
+		if name == "" && op != nil {
+			return nil, nil, errors.New("operation name is required when the document has multiple operations")
+		}
+		if name == "" || o.name == name {
+			op = o
+		}
+	}
+	if op == nil {
+		return nil, nil, fmt.Errorf("unknown operation %q", name)
+	}
+	if op.kind != "query" {
+		return nil, nil, fmt.Errorf("%s operations are not supported", op.kind)
+	}
+
+	req := &request{
+		ctx:       ctx,
+		doc:       doc,
+		variables: map[string]any{},
+		maxDepth:  maxDepth,
+	}
+	for _, v := range op.variables {
+		if provided, ok := variables[v.name]; ok {
+			req.variables[v.name] = normalize(provided)
+			continue
+		}
+		def, err := req.value(v.def)
+		if err != nil {
+			return nil, nil, err
+		}
+		req.variables[v.name] = def
+	}
+
+	data = req.selectionSet(query, root, op.selection, nil, 1)
+	return data, req.errors, nil
+}
+
+// Ordered JSON object of the results of a selection set.
+type object []member
+
+type member struct {
+	key   string
+	value any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, err := json.Marshal(m.key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// Fields with the same response key, which are merged into a single result.
+type fieldGroup struct {
+	key    string
+	fields []*field
+	sel    selection
+}
+
+func (req *request) selectionSet(typ *objectType, src any, sels []selection, path []any, depth int) object {
+	groups := req.collect(typ, sels, map[string]bool{}, nil)
+
+	o := make(object, 0, len(groups))
+	for _, g := range groups {
+		fpath := append(slices.Clip(path), g.key)
+		o = append(o, member{key: g.key, value: req.field(typ, src, g, fpath, depth)})
+	}
+	return o
+}
+
+// Collects the fields of the selection set that apply to the object type,
+// expanding fragments, see https://spec.graphql.org/October2021/#CollectFields().
+func (req *request) collect(
+	typ *objectType,
+	sels []selection,
+	visited map[string]bool,
+	groups []*fieldGroup,
+) []*fieldGroup {
+	for _, sel := range sels {
+		if !req.included(sel) {
+			continue
+		}
+
+		switch {
+		case sel.field != nil:
+			key := sel.field.key()
+			i := slices.IndexFunc(groups, func(g *fieldGroup) bool { return g.key == key })
+			if i < 0 {
+				groups = append(groups, &fieldGroup{key: key, sel: sel})
+				i = len(groups) - 1
+			}
+			groups[i].fields = append(groups[i].fields, sel.field)
+
+		case sel.spread != "":
+			if visited[sel.spread] {
+				continue
+			}
+			visited[sel.spread] = true
+
+			f, ok := req.doc.fragments[sel.spread]
+			if !ok {
+				req.fail(sel, nil, fmt.Sprintf("unknown fragment %q", sel.spread))
+				continue
+			}
+			if f.on != typ.name {
+				continue
+			}
+			groups = req.collect(typ, f.selection, visited, groups)
+
+		case sel.inline != nil:
+			if sel.inline.on != "" && sel.inline.on != typ.name {
+				continue
+			}
+			groups = req.collect(typ, sel.inline.selection, visited, groups)
+		}
+	}
+	return groups
+}
+
+// Reports if the selection isn't excluded by the @skip or @include directives.
+func (req *request) included(sel selection) bool {
+	for _, d := range sel.directives {
+		if d.name != "skip" && d.name != "include" {
+			continue
+		}
+		v, err := req.value(d.args["if"])
+		if err != nil {
+			req.fail(sel, nil, err.Error())
+			return false
+		}
+		b, ok := v.(bool)
+		if !ok {
+			req.fail(sel, nil, fmt.Sprintf(`argument "if" of @%s should be a boolean`, d.name))
+			return false
+		}
+		if (d.name == "skip") == b {
+			return false
+		}
+	}
+	return true
+}
+
+func (req *request) field(typ *objectType, src any, g *fieldGroup, path []any, depth int) any {
+	f := g.fields[0]
+
+	if f.name == "__typename" {
+		return typ.name
+	}
+	if depth > req.maxDepth {
+		req.fail(g.sel, path, fmt.Sprintf("query exceeds the max depth of %d", req.maxDepth))
+		return nil
+	}
+
+	def, ok := typ.fields[f.name]
+	if !ok {
+		req.fail(g.sel, path, fmt.Sprintf("unknown field %q on type %q", f.name, typ.name))
+		return nil
+	}
+
+	args := make(map[string]any, len(f.args))
+	for name, arg := range f.args {
+		if !slices.Contains(def.args, name) {
+			req.fail(g.sel, path, fmt.Sprintf("unknown argument %q on field %q", name, f.name))
+			return nil
+		}
+		v, err := req.value(arg)
+		if err != nil {
+			req.fail(g.sel, path, err.Error())
+			return nil
+		}
+		if v != nil {
+			args[name] = v
+		}
+	}
+
+	if err := req.ctx.Err(); err != nil {
+		req.fail(g.sel, path, err.Error())
+		return nil
+	}
+
+	v, err := def.resolve(req, src, args)
+	if err != nil {
+		req.fail(g.sel, path, err.Error())
+		return nil
+	}
+
+	if def.typ == nil {
+		if len(f.selection) > 0 {
+			req.fail(g.sel, path, fmt.Sprintf("field %q doesn't have sub-fields", f.name))
+			return nil
+		}
+		return v
+	}
+
+	var sels []selection
+	for _, f := range g.fields {
+		sels = append(sels, f.selection...)
+	}
+	if len(sels) == 0 {
+		req.fail(g.sel, path, fmt.Sprintf("field %q of type %q must have a selection of sub-fields", f.name, def.typ.name))
+		return nil
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		l := make([]any, len(v))
+		for i, item := range v {
+			if item != nil {
+				l[i] = req.selectionSet(def.typ, item, sels, append(slices.Clip(path), i), depth+1)
+			}
+		}
+		return l
+	default:
+		return req.selectionSet(def.typ, v, sels, path, depth+1)
+	}
+}
+
+// Returns the Go value of the argument value, replacing variables. Enums are
+// returned as strings.
+func (req *request) value(v value) (any, error) {
+	if v.variable != "" {
+		vv, ok := req.variables[v.variable]
+		if !ok {
+			return nil, fmt.Errorf("variable %q is not defined by the operation", v.variable)
+		}
+		return vv, nil
+	}
+
+	switch l := v.literal.(type) {
+	case enum:
+		return string(l), nil
+	case []value:
+		vs := make([]any, len(l))
+		for i, item := range l {
+			vv, err := req.value(item)
+			if err != nil {
+				return nil, err
+			}
+			vs[i] = vv
+		}
+		return vs, nil
+	case map[string]value:
+		m := make(map[string]any, len(l))
+		for k, item := range l {
+			vv, err := req.value(item)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = vv
+		}
+		return m, nil
+	default:
+		return l, nil
+	}
+}
+
+func (req *request) fail(sel selection, path []any, msg string) {
+	err := &queryError{Message: msg, Path: path}
+	if sel.line > 0 {
+		err.Locations = []location{{Line: sel.line, Column: sel.col}}
+	}
+	req.errors = append(req.errors, err)
+}
+
+// Converts the values of JSON decoded variables to the types of literals, so
+// numbers decoded as [json.Number] are int64 if they are integers.
+func normalize(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case float64:
+		if v == float64(int64(v)) {
+			return int64(v)
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = normalize(v[i])
+		}
+		return v
+	case map[string]any:
+		for k := range v {
+			v[k] = normalize(v[k])
+		}
+		return v
+	default:
+		return v
+	}
+}
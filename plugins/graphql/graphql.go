@@ -0,0 +1,901 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package graphql provides a [plugin.Middleware] that serves a read-only GraphQL
+// API of the posts of the blog, and of their tags, authors and series, built
+// from a [index.Index], for frontends that render the blog themselves.
+//
+// The API implements the subset of GraphQL needed by queries: operations,
+// variables, aliases, fragments and the @skip and @include directives.
+// Introspection isn't supported, the schema is served on Opts.SchemaPath in
+// the schema definition language instead, see [Schema].
+package graphql
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/index"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/slug"
+	"forge.capytal.company/loreddev/blogo/visibility"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-graphql-middleware"
+
+// Schema of the API, in the GraphQL schema definition language.
+const Schema = `type Query {
+  posts` + postArgsSchema + `: PostPage!
+  post(path: String, url: String): Post
+  tags(sort: TermSort = NAME, first: Int): [Tag!]!
+  tag(name: String!): Tag
+  authors(sort: TermSort = NAME, first: Int): [Author!]!
+  author(name: String!): Author
+  allSeries(sort: TermSort = NAME, first: Int): [Series!]!
+  series(name: String!): Series
+}
+
+enum PostSort {
+  DATE_DESC
+  DATE_ASC
+  UPDATED_DESC
+  TITLE_ASC
+  TITLE_DESC
+  SERIES_ORDER
+}
+
+enum TermSort {
+  NAME
+  COUNT
+}
+
+type PostPage {
+  totalCount: Int!
+  hasNextPage: Boolean!
+  endCursor: String
+  nodes: [Post!]!
+}
+
+type Post {
+  path: String!
+  url: String!
+  title: String!
+  summary: String!
+  "Date of the post, as a RFC 3339 timestamp."
+  date: String
+  updated: String
+  tags: [String!]!
+  author: String
+  series: String
+  seriesOrder: Int
+  "Value of the key of the metadata of the post."
+  meta(key: String!): JSON
+}
+
+type Tag {
+  name: String!
+  "Slug of the name, used on URLs such as the ones of tag feeds."
+  slug: String!
+  count: Int!
+  posts` + postArgsSchema + `: PostPage!
+}
+
+type Author {
+  name: String!
+  "Slug of the name, used on URLs such as the ones of tag feeds."
+  slug: String!
+  count: Int!
+  posts` + postArgsSchema + `: PostPage!
+}
+
+type Series {
+  name: String!
+  "Slug of the name, used on URLs such as the ones of tag feeds."
+  slug: String!
+  count: Int!
+  posts` + postArgsSchema + `: PostPage!
+}
+
+"Any JSON value."
+scalar JSON
+`
+
+const postArgsSchema = `(
+    tag: String
+    author: String
+    series: String
+    "Prefix of the URLs of the posts, such as \"notes\"."
+    section: String
+    since: String
+    until: String
+    search: String
+    sort: PostSort = DATE_DESC
+    first: Int = 20
+    after: String
+  )`
+
+// Creates a [plugin.Middleware] that serves the GraphQL API on Opts.Path, with
+// the entries of the index that are visible on [visibility.Listing].
+//
+// Queries are accepted as GET requests, with the "query", "variables" and
+// "operationName" parameters, and as POST requests with a JSON body or a
+// "application/graphql" body, see https://graphql.org/learn/serving-over-http.
+//
+// The posts field, and the posts field of tags, authors and series, returns a
+// page of posts filtered by the tag, author, series, section (the URL prefix),
+// since and until (dates such as "2025-03-01" or timestamps, on Opts.Location
+// if they don't have a time zone; until includes the whole day of dates)
+// and search (a case insensitive match of the title or summary, of entries
+// also visible on [visibility.Search]) arguments, sorted by the sort argument.
+// Pages have at most the number of posts of the first argument, and the next
+// page is queried with the endCursor of the page as the after argument.
+func New(provider index.Provider, opts ...Opts) plugin.Middleware {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Path == "" {
+		opt.Path = "/graphql"
+	}
+	if opt.SchemaPath == "" {
+		opt.SchemaPath = strings.TrimSuffix(opt.Path, "/") + "/schema.graphql"
+	}
+	if opt.Visibility == nil {
+		opt.Visibility = visibility.Default
+	}
+	if opt.MaxDepth <= 0 {
+		opt.MaxDepth = 10
+	}
+	if opt.MaxFirst <= 0 {
+		opt.MaxFirst = 100
+	}
+	if opt.Slugifier == nil {
+		opt.Slugifier = slug.Default
+	}
+	if opt.Location == nil {
+		opt.Location = time.UTC
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(provider, "Index provider should not be nil")
+
+	p := &p{
+		provider: provider,
+
+		path:       opt.Path,
+		schemaPath: opt.SchemaPath,
+		visibility: opt.Visibility,
+		maxDepth:   opt.MaxDepth,
+		maxFirst:   opt.MaxFirst,
+		slugifier:  opt.Slugifier,
+		location:   opt.Location,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+	p.query = p.schema()
+
+	return p
+}
+
+type Opts struct {
+	// Path that the API is served on. Defaults to "/graphql".
+	Path string
+	// Path that the schema is served on. Defaults to Opts.Path followed by
+	// "/schema.graphql".
+	SchemaPath string
+
+	// Rules used to hide entries from the API. Defaults to [visibility.Default].
+	Visibility visibility.Rules
+
+	// Max depth of the selection sets of queries. Defaults to 10.
+	MaxDepth int
+	// Max value of the first argument of paginated fields. Defaults to 100.
+	MaxFirst int
+	// Slugifier of the slug field of tags, authors and series. Defaults to
+	// [slug.Default].
+	Slugifier slug.Slugifier
+	// Time zone of the site, which the since and until arguments are parsed
+	// in. Defaults to [time.UTC].
+	Location *time.Location
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	provider index.Provider
+
+	path       string
+	schemaPath string
+	visibility visibility.Rules
+	maxDepth   int
+	maxFirst   int
+	slugifier  slug.Slugifier
+	location   *time.Location
+
+	query *objectType
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.provider)
+		p.assert.NotNil(p.log)
+
+		switch r.URL.Path {
+		case p.path:
+			p.serveQuery(w, r)
+		case p.schemaPath:
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				w.Header().Set("Allow", "GET, HEAD")
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = io.WriteString(w, Schema)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+type params struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+type response struct {
+	Data   any           `json:"data,omitempty"`
+	Errors []*queryError `json:"errors,omitempty"`
+}
+
+func (p *p) serveQuery(w http.ResponseWriter, r *http.Request) {
+	log := p.log.With(slog.String("path", r.URL.Path))
+
+	var ps params
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		q := r.URL.Query()
+		ps.Query, ps.OperationName = q.Get("query"), q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			dec := json.NewDecoder(strings.NewReader(v))
+			dec.UseNumber()
+			if err := dec.Decode(&ps.Variables); err != nil {
+				p.writeError(w, http.StatusBadRequest, "invalid variables: "+err.Error())
+				return
+			}
+		}
+
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+		t, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if t == "application/graphql" {
+			b, err := io.ReadAll(r.Body)
+			if err != nil {
+				p.writeError(w, http.StatusBadRequest, "failed to read query")
+				return
+			}
+			ps.Query = string(b)
+			break
+		}
+
+		dec := json.NewDecoder(r.Body)
+		dec.UseNumber()
+		if err := dec.Decode(&ps); err != nil {
+			p.writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if strings.TrimSpace(ps.Query) == "" {
+		p.writeError(w, http.StatusBadRequest, "missing query")
+		return
+	}
+
+	doc, err := parse(ps.Query)
+	if err != nil {
+		// Syntax errors are reported with their location, as the other errors.
+		var serr *syntaxError
+		if errors.As(err, &serr) {
+			p.writeResponse(w, http.StatusBadRequest, response{Errors: []*queryError{{
+				Message:   serr.msg,
+				Locations: []location{{Line: serr.line, Column: serr.col}},
+			}}})
+			return
+		}
+		p.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	idx, err := p.provider.Index()
+	if err != nil {
+		log.Error("Failed to get index for GraphQL query", slog.String("err", err.Error()))
+		p.writeError(w, http.StatusInternalServerError, "failed to get index")
+		return
+	}
+
+	log.Debug("Executing GraphQL query", slog.String("operation", ps.OperationName))
+
+	data, errs, err := execute(r.Context(), p.query, &root{p: p, idx: idx}, doc, ps.OperationName, ps.Variables, p.maxDepth)
+	if err != nil {
+		p.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	p.writeResponse(w, http.StatusOK, response{Data: data, Errors: errs})
+}
+
+func (p *p) writeError(w http.ResponseWriter, status int, msg string) {
+	p.writeResponse(w, status, response{Errors: []*queryError{{Message: msg}}})
+}
+
+func (p *p) writeResponse(w http.ResponseWriter, status int, res response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		p.log.Error("Failed to write GraphQL response", slog.String("err", err.Error()))
+	}
+}
+
+// Root value of queries.
+type root struct {
+	p   *p
+	idx index.Index
+
+	entries []index.Entry
+}
+
+// Returns the visible entries of the index, in the order of the index.
+func (r *root) visible() []index.Entry {
+	if r.entries == nil {
+		r.entries = r.p.filter(r.idx.Entries())
+	}
+	return r.entries
+}
+
+func (p *p) filter(entries []index.Entry) []index.Entry {
+	es := make([]index.Entry, 0, len(entries))
+	for _, e := range entries {
+		if p.visibility.Visible(e.Path, e.Metadata, visibility.Listing) {
+			es = append(es, e)
+		}
+	}
+	return es
+}
+
+// A tag, author or series, with it's visible entries.
+type term struct {
+	name    string
+	entries []index.Entry
+}
+
+var (
+	authors = index.Taxonomy{Name: "authors", Terms: func(e index.Entry) []string {
+		return stringTerm(e.Metadata, "author")
+	}}
+	series = index.Taxonomy{Name: "series", Terms: func(e index.Entry) []string {
+		return stringTerm(e.Metadata, "series")
+	}}
+)
+
+func stringTerm(m metadata.Metadata, key string) []string {
+	if m == nil {
+		return nil
+	}
+	v, err := metadata.GetTyped[string](m, key)
+	if err != nil || strings.TrimSpace(v) == "" {
+		return nil
+	}
+	return []string{strings.TrimSpace(v)}
+}
+
+var postArgs = []string{"tag", "author", "series", "section", "since", "until", "search", "sort", "first", "after"}
+
+func (p *p) schema() *objectType {
+	post := &objectType{name: "Post", fields: map[string]*fieldDef{
+		"path":    entryField(func(e index.Entry) any { return e.Path }),
+		"url":     entryField(func(e index.Entry) any { return e.URL }),
+		"title":   entryField(func(e index.Entry) any { return e.Title }),
+		"summary": entryField(func(e index.Entry) any { return e.Summary }),
+		"date":    entryField(func(e index.Entry) any { return timestamp(e.Date) }),
+		"updated": entryField(func(e index.Entry) any { return timestamp(e.Updated) }),
+		"tags": entryField(func(e index.Entry) any {
+			if e.Tags == nil {
+				return []string{}
+			}
+			return e.Tags
+		}),
+		"author": entryField(func(e index.Entry) any { return first(stringTerm(e.Metadata, "author")) }),
+		"series": entryField(func(e index.Entry) any { return first(stringTerm(e.Metadata, "series")) }),
+		"seriesOrder": entryField(func(e index.Entry) any {
+			if e.Metadata == nil {
+				return nil
+			}
+			o, err := metadata.GetTyped[int](e.Metadata, "series-order")
+			if err != nil {
+				return nil
+			}
+			return o
+		}),
+		"meta": {args: []string{"key"}, resolve: func(req *request, src any, args map[string]any) (any, error) {
+			key, err := stringArg(args, "key")
+			if err != nil {
+				return nil, err
+			}
+			if key == "" {
+				return nil, errors.New(`argument "key" is required`)
+			}
+			m := src.(index.Entry).Metadata
+			if m == nil {
+				return nil, nil
+			}
+			v, err := m.Get(key)
+			if err != nil {
+				return nil, nil
+			}
+			return jsonValue(v), nil
+		}},
+	}}
+
+	page := &objectType{name: "PostPage", fields: map[string]*fieldDef{
+		"totalCount":  pageField(func(pg *postPage) any { return pg.total }),
+		"hasNextPage": pageField(func(pg *postPage) any { return pg.next }),
+		"endCursor": pageField(func(pg *postPage) any {
+			if len(pg.nodes) == 0 {
+				return nil
+			}
+			return cursor(pg.start + len(pg.nodes) - 1)
+		}),
+		"nodes": {typ: post, resolve: func(req *request, src any, args map[string]any) (any, error) {
+			nodes := src.(*postPage).nodes
+			l := make([]any, len(nodes))
+			for i, e := range nodes {
+				l[i] = e
+			}
+			return l, nil
+		}},
+	}}
+
+	termType := func(name string) *objectType {
+		return &objectType{name: name, fields: map[string]*fieldDef{
+			"name": {resolve: func(req *request, src any, args map[string]any) (any, error) { return src.(*term).name, nil }},
+			"slug": {resolve: func(req *request, src any, args map[string]any) (any, error) {
+				return p.slugifier.Slugify(src.(*term).name), nil
+			}},
+			"count": {resolve: func(req *request, src any, args map[string]any) (any, error) { return len(src.(*term).entries), nil }},
+			"posts": {typ: page, args: postArgs, resolve: func(req *request, src any, args map[string]any) (any, error) {
+				return p.posts(src.(*term).entries, args)
+			}},
+		}}
+	}
+	tag, author, serie := termType("Tag"), termType("Author"), termType("Series")
+
+	return &objectType{name: "Query", fields: map[string]*fieldDef{
+		"posts": {typ: page, args: postArgs, resolve: func(req *request, src any, args map[string]any) (any, error) {
+			return p.posts(src.(*root).visible(), args)
+		}},
+		"post": {typ: post, args: []string{"path", "url"}, resolve: func(req *request, src any, args map[string]any) (any, error) {
+			r := src.(*root)
+
+			path, err := stringArg(args, "path")
+			if err != nil {
+				return nil, err
+			}
+			url, err := stringArg(args, "url")
+			if err != nil {
+				return nil, err
+			}
+
+			var e index.Entry
+			var ok bool
+			switch {
+			case path != "":
+				e, ok = r.idx.Get(strings.TrimPrefix(path, "/"))
+			case url != "":
+				e, ok = r.idx.Lookup(url)
+			default:
+				return nil, errors.New(`argument "path" or "url" is required`)
+			}
+			if !ok || !p.visibility.Visible(e.Path, e.Metadata, visibility.Listing) {
+				return nil, nil
+			}
+			return e, nil
+		}},
+		"tags":      p.termsField(tag, index.Tags),
+		"tag":       p.termField(tag, index.Tags),
+		"authors":   p.termsField(author, authors),
+		"author":    p.termField(author, authors),
+		"allSeries": p.termsField(serie, series),
+		"series":    p.termField(serie, series),
+	}}
+}
+
+func entryField(f func(index.Entry) any) *fieldDef {
+	return &fieldDef{resolve: func(req *request, src any, args map[string]any) (any, error) {
+		return f(src.(index.Entry)), nil
+	}}
+}
+
+func pageField(f func(*postPage) any) *fieldDef {
+	return &fieldDef{resolve: func(req *request, src any, args map[string]any) (any, error) {
+		return f(src.(*postPage)), nil
+	}}
+}
+
+// Returns the field of the list of terms of the taxonomy, with their visible entries.
+func (p *p) termsField(typ *objectType, t index.Taxonomy) *fieldDef {
+	return &fieldDef{typ: typ, args: []string{"sort", "first"}, resolve: func(req *request, src any, args map[string]any) (any, error) {
+		r := src.(*root)
+
+		sort, err := stringArg(args, "sort")
+		if err != nil {
+			return nil, err
+		}
+		n, err := intArg(args, "first", -1)
+		if err != nil {
+			return nil, err
+		}
+
+		terms := []*term{}
+		for _, tc := range index.Terms(r.idx, t) {
+			if es := p.filter(index.Term(r.idx, t, tc.Term)); len(es) > 0 {
+				terms = append(terms, &term{name: tc.Term, entries: es})
+			}
+		}
+
+		switch sort {
+		case "", "NAME":
+		case "COUNT":
+			slices.SortStableFunc(terms, func(a, b *term) int {
+				return cmp.Compare(len(b.entries), len(a.entries))
+			})
+		default:
+			return nil, fmt.Errorf("unknown sort %q", sort)
+		}
+
+		if n >= 0 && n < len(terms) {
+			terms = terms[:n]
+		}
+
+		l := make([]any, len(terms))
+		for i, t := range terms {
+			l[i] = t
+		}
+		return l, nil
+	}}
+}
+
+// Returns the field of a term of the taxonomy by it's name, or nil if it
+// doesn't have visible entries.
+func (p *p) termField(typ *objectType, t index.Taxonomy) *fieldDef {
+	return &fieldDef{typ: typ, args: []string{"name"}, resolve: func(req *request, src any, args map[string]any) (any, error) {
+		r := src.(*root)
+
+		name, err := stringArg(args, "name")
+		if err != nil {
+			return nil, err
+		}
+		if t.Name == index.Tags.Name {
+			name = strings.ToLower(name)
+		}
+		name = strings.TrimSpace(name)
+
+		es := p.filter(index.Term(r.idx, t, name))
+		if len(es) == 0 {
+			return nil, nil
+		}
+		return &term{name: name, entries: es}, nil
+	}}
+}
+
+// A page of the results of a posts field.
+type postPage struct {
+	total int
+	start int
+	next  bool
+	nodes []index.Entry
+}
+
+// Filters, sorts and paginates the entries by the arguments of a posts field.
+func (p *p) posts(entries []index.Entry, args map[string]any) (*postPage, error) {
+	var filters []func(index.Entry) bool
+
+	for _, f := range []struct {
+		arg string
+		t   index.Taxonomy
+	}{{"tag", index.Tags}, {"author", authors}, {"series", series}} {
+		v, err := stringArg(args, f.arg)
+		if err != nil {
+			return nil, err
+		}
+		if v == "" {
+			continue
+		}
+		if f.t.Name == index.Tags.Name {
+			v = strings.ToLower(v)
+		}
+		v, terms := strings.TrimSpace(v), f.t.Terms
+		filters = append(filters, func(e index.Entry) bool { return slices.Contains(terms(e), v) })
+	}
+
+	section, err := stringArg(args, "section")
+	if err != nil {
+		return nil, err
+	}
+	if section = strings.Trim(section, "/"); section != "" {
+		prefix := "/" + section + "/"
+		filters = append(filters, func(e index.Entry) bool {
+			return strings.HasPrefix("/"+strings.TrimPrefix(e.URL, "/"), prefix)
+		})
+	}
+
+	since, err := timeArg(args, "since", false, p.location)
+	if err != nil {
+		return nil, err
+	}
+	if !since.IsZero() {
+		filters = append(filters, func(e index.Entry) bool { return !e.Date.Before(since) })
+	}
+	until, err := timeArg(args, "until", true, p.location)
+	if err != nil {
+		return nil, err
+	}
+	if !until.IsZero() {
+		filters = append(filters, func(e index.Entry) bool { return !e.Date.IsZero() && !e.Date.After(until) })
+	}
+
+	search, err := stringArg(args, "search")
+	if err != nil {
+		return nil, err
+	}
+	if search = strings.ToLower(strings.TrimSpace(search)); search != "" {
+		filters = append(filters, func(e index.Entry) bool {
+			if !p.visibility.Visible(e.Path, e.Metadata, visibility.Search) {
+				return false
+			}
+			return strings.Contains(strings.ToLower(e.Title), search) ||
+				strings.Contains(strings.ToLower(e.Summary), search)
+		})
+	}
+
+	es := make([]index.Entry, 0, len(entries))
+	for _, e := range entries {
+		if !slices.ContainsFunc(filters, func(f func(index.Entry) bool) bool { return !f(e) }) {
+			es = append(es, e)
+		}
+	}
+
+	sort, err := stringArg(args, "sort")
+	if err != nil {
+		return nil, err
+	}
+	switch sort {
+	case "", "DATE_DESC":
+		// The index is already sorted from newest to oldest.
+	case "DATE_ASC":
+		slices.Reverse(es)
+	case "UPDATED_DESC":
+		slices.SortStableFunc(es, func(a, b index.Entry) int {
+			return lastUpdate(b).Compare(lastUpdate(a))
+		})
+	case "TITLE_ASC", "TITLE_DESC":
+		slices.SortStableFunc(es, func(a, b index.Entry) int {
+			c := strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+			if sort == "TITLE_DESC" {
+				return -c
+			}
+			return c
+		})
+	case "SERIES_ORDER":
+		slices.SortStableFunc(es, func(a, b index.Entry) int {
+			oa, _ := metadata.GetTyped[int](a.Metadata, "series-order")
+			ob, _ := metadata.GetTyped[int](b.Metadata, "series-order")
+			return cmp.Compare(oa, ob)
+		})
+	default:
+		return nil, fmt.Errorf("unknown sort %q", sort)
+	}
+
+	n, err := intArg(args, "first", 20)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, errors.New(`argument "first" can't be negative`)
+	}
+	if n > p.maxFirst {
+		return nil, fmt.Errorf(`argument "first" can't be more than %d`, p.maxFirst)
+	}
+
+	start := 0
+	after, err := stringArg(args, "after")
+	if err != nil {
+		return nil, err
+	}
+	if after != "" {
+		i, err := parseCursor(after)
+		if err != nil {
+			return nil, err
+		}
+		start = i + 1
+	}
+	start = min(start, len(es))
+	end := min(start+n, len(es))
+
+	return &postPage{total: len(es), start: start, next: end < len(es), nodes: es[start:end]}, nil
+}
+
+func lastUpdate(e index.Entry) time.Time {
+	if e.Updated.After(e.Date) {
+		return e.Updated
+	}
+	return e.Date
+}
+
+// Cursors are opaque to clients, so the position they encode can change
+// without breaking them.
+func cursor(i int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("post:" + strconv.Itoa(i)))
+}
+
+func parseCursor(c string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+	i, err := strconv.Atoi(strings.TrimPrefix(string(b), "post:"))
+	if err != nil || i < 0 || !strings.HasPrefix(string(b), "post:") {
+		return 0, errors.New("invalid cursor")
+	}
+	return i, nil
+}
+
+func stringArg(args map[string]any, name string) (string, error) {
+	v, ok := args[name]
+	if !ok {
+		return "", nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("argument %q should be a string", name)
+	}
+	return s, nil
+}
+
+func intArg(args map[string]any, name string, def int) (int, error) {
+	v, ok := args[name]
+	if !ok {
+		return def, nil
+	}
+	n, ok := v.(int64)
+	if !ok || n != int64(int(n)) {
+		return 0, fmt.Errorf("argument %q should be a integer", name)
+	}
+	return int(n), nil
+}
+
+// Parses a time argument as a date or a timestamp, in one of the layouts of
+// [metadata.TimeLayouts], on the location if it doesn't have a time zone. If
+// end is true, dates are parsed as the end of the day.
+func timeArg(args map[string]any, name string, end bool, loc *time.Location) (time.Time, error) {
+	s, err := stringArg(args, name)
+	if err != nil || s == "" {
+		return time.Time{}, err
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, loc); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		return t, nil
+	}
+	t, err := metadata.ParseTimeIn(s, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("argument %q should be a date or timestamp", name)
+	}
+	return t, nil
+}
+
+func timestamp(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Format(time.RFC3339)
+}
+
+func first(s []string) any {
+	if len(s) == 0 {
+		return nil
+	}
+	return s[0]
+}
+
+// Converts values of metadata, such as YAML maps with non-string keys, to values
+// that can be encoded as JSON.
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, item := range v {
+			m[fmt.Sprint(k)] = jsonValue(item)
+		}
+		return m
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, item := range v {
+			m[k] = jsonValue(item)
+		}
+		return m
+	case []any:
+		l := make([]any, len(v))
+		for i, item := range v {
+			l[i] = jsonValue(item)
+		}
+		return l
+	case nil, string, bool, int, int64, uint64, float64, time.Time, []string:
+		return v
+	default:
+		if _, err := json.Marshal(v); err != nil {
+			return fmt.Sprint(v)
+		}
+		return v
+	}
+}
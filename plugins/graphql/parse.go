@@ -0,0 +1,633 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A parsed query document.
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	kind      string
+	name      string
+	variables []variable
+	selection []selection
+}
+
+type variable struct {
+	name string
+	def  value
+}
+
+type fragment struct {
+	name      string
+	on        string
+	selection []selection
+}
+
+// A field, fragment spread or inline fragment of a selection set.
+type selection struct {
+	// Field, if it's a field.
+	field *field
+	// Name of the fragment, if it's a fragment spread.
+	spread string
+	// Inline fragment, if it's neither.
+	inline *fragment
+
+	directives []directive
+	line, col  int
+}
+
+type field struct {
+	alias     string
+	name      string
+	args      map[string]value
+	selection []selection
+}
+
+func (f *field) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type directive struct {
+	name string
+	args map[string]value
+}
+
+// A literal or variable of a argument.
+type value struct {
+	// Name of the variable, if it's a variable reference.
+	variable string
+	// Go value of the literal: nil, string, int64, float64, bool, enum, []value
+	// or map[string]value.
+	literal any
+}
+
+// Value of a enum literal, such as DATE_DESC.
+type enum string
+
+// Error of the syntax of a query, at it's location.
+type syntaxError struct {
+	msg       string
+	line, col int
+}
+
+func (err *syntaxError) Error() string {
+	return fmt.Sprintf("syntax error at %d:%d: %s", err.line, err.col, err.msg)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind      tokenKind
+	value     string
+	line, col int
+}
+
+type parser struct {
+	src       string
+	pos       int
+	line, col int
+	tok       token
+	depth     int
+}
+
+// Max nesting of selection sets and values while parsing, so deeply nested
+// queries can't exhaust the stack.
+const maxParseDepth = 64
+
+// Parses the query document.
+func parse(src string) (doc *document, err error) {
+	p := &parser{src: src, line: 1, col: 1}
+
+	defer func() {
+		if v := recover(); v != nil {
+			serr, ok := v.(*syntaxError)
+			if !ok {
+				panic(v)
+			}
+			doc, err = nil, serr
+		}
+	}()
+
+	p.next()
+
+	doc = &document{fragments: map[string]*fragment{}}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.is(tokenPunct, "{"):
+			doc.operations = append(doc.operations, &operation{kind: "query", selection: p.selectionSet()})
+		case p.is(tokenName, "query"), p.is(tokenName, "mutation"), p.is(tokenName, "subscription"):
+			doc.operations = append(doc.operations, p.operation())
+		case p.is(tokenName, "fragment"):
+			f := p.fragmentDefinition()
+			if _, ok := doc.fragments[f.name]; ok {
+				p.fail(fmt.Sprintf("fragment %q is defined more than once", f.name))
+			}
+			doc.fragments[f.name] = f
+		default:
+			p.fail(fmt.Sprintf("unexpected %q", p.tok.value))
+		}
+	}
+
+	if len(doc.operations) == 0 {
+		p.fail("document has no operations")
+	}
+
+	return doc, nil
+}
+
+func (p *parser) fail(msg string) {
+	panic(&syntaxError{msg: msg, line: p.tok.line, col: p.tok.col})
+}
+
+func (p *parser) is(kind tokenKind, v string) bool {
+	return p.tok.kind == kind && p.tok.value == v
+}
+
+func (p *parser) expect(kind tokenKind, v string) token {
+	t := p.tok
+	if t.kind != kind || (v != "" && t.value != v) {
+		if v == "" {
+			v = [...]string{"end of query", "punctuator", "name", "integer", "float", "string"}[kind]
+		}
+		p.fail(fmt.Sprintf("expected %s, found %q", v, t.value))
+	}
+	p.next()
+	return t
+}
+
+func (p *parser) name() string {
+	return p.expect(tokenName, "").value
+}
+
+func (p *parser) operation() *operation {
+	op := &operation{kind: p.name()}
+	if p.tok.kind == tokenName {
+		op.name = p.name()
+	}
+
+	if p.is(tokenPunct, "(") {
+		p.next()
+		for !p.is(tokenPunct, ")") {
+			p.expect(tokenPunct, "$")
+			v := variable{name: p.name()}
+			p.expect(tokenPunct, ":")
+			p.typeRef()
+			if p.is(tokenPunct, "=") {
+				p.next()
+				v.def = p.value(true)
+			}
+			p.directives()
+			op.variables = append(op.variables, v)
+		}
+		p.next()
+	}
+
+	p.directives()
+	op.selection = p.selectionSet()
+	return op
+}
+
+// Skips a type reference, such as "[String!]!", since the types of variables
+// are coerced by the fields that use them.
+func (p *parser) typeRef() {
+	if p.is(tokenPunct, "[") {
+		p.next()
+		p.typeRef()
+		p.expect(tokenPunct, "]")
+	} else {
+		p.name()
+	}
+	if p.is(tokenPunct, "!") {
+		p.next()
+	}
+}
+
+func (p *parser) fragmentDefinition() *fragment {
+	p.next()
+	f := &fragment{name: p.name()}
+	if f.name == "on" {
+		p.fail(`fragments can't be named "on"`)
+	}
+	p.expect(tokenName, "on")
+	f.on = p.name()
+	p.directives()
+	f.selection = p.selectionSet()
+	return f
+}
+
+func (p *parser) selectionSet() []selection {
+	p.depth++
+	if p.depth > maxParseDepth {
+		p.fail("query is nested too deeply")
+	}
+	defer func() { p.depth-- }()
+
+	p.expect(tokenPunct, "{")
+
+	s := []selection{}
+	for !p.is(tokenPunct, "}") {
+		if p.tok.kind == tokenEOF {
+			p.fail(`expected "}"`)
+		}
+		s = append(s, p.selection())
+	}
+	p.next()
+
+	return s
+}
+
+func (p *parser) selection() selection {
+	sel := selection{line: p.tok.line, col: p.tok.col}
+
+	if p.is(tokenPunct, "...") {
+		p.next()
+		if p.tok.kind == tokenName && p.tok.value != "on" {
+			sel.spread = p.name()
+			sel.directives = p.directives()
+			return sel
+		}
+
+		f := &fragment{}
+		if p.is(tokenName, "on") {
+			p.next()
+			f.on = p.name()
+		}
+		sel.directives = p.directives()
+		f.selection = p.selectionSet()
+		sel.inline = f
+		return sel
+	}
+
+	f := &field{name: p.name()}
+	if p.is(tokenPunct, ":") {
+		p.next()
+		f.alias, f.name = f.name, p.name()
+	}
+	f.args = p.arguments()
+	sel.directives = p.directives()
+	if p.is(tokenPunct, "{") {
+		f.selection = p.selectionSet()
+	}
+
+	sel.field = f
+	return sel
+}
+
+func (p *parser) arguments() map[string]value {
+	args := map[string]value{}
+	if !p.is(tokenPunct, "(") {
+		return args
+	}
+	p.next()
+	for !p.is(tokenPunct, ")") {
+		name := p.name()
+		p.expect(tokenPunct, ":")
+		if _, ok := args[name]; ok {
+			p.fail(fmt.Sprintf("argument %q is provided more than once", name))
+		}
+		args[name] = p.value(false)
+	}
+	p.next()
+	return args
+}
+
+func (p *parser) directives() []directive {
+	ds := []directive{}
+	for p.is(tokenPunct, "@") {
+		p.next()
+		ds = append(ds, directive{name: p.name(), args: p.arguments()})
+	}
+	return ds
+}
+
+// Parses a value. Constant values, such as the defaults of variables, can't
+// reference variables.
+func (p *parser) value(constant bool) value {
+	p.depth++
+	if p.depth > maxParseDepth {
+		p.fail("value is nested too deeply")
+	}
+	defer func() { p.depth-- }()
+
+	t := p.tok
+	switch t.kind {
+	case tokenPunct:
+		switch t.value {
+		case "$":
+			if constant {
+				p.fail("variables can't be used on constant values")
+			}
+			p.next()
+			return value{variable: p.name()}
+		case "[":
+			p.next()
+			vs := []value{}
+			for !p.is(tokenPunct, "]") {
+				if p.tok.kind == tokenEOF {
+					p.fail(`expected "]"`)
+				}
+				vs = append(vs, p.value(constant))
+			}
+			p.next()
+			return value{literal: vs}
+		case "{":
+			p.next()
+			m := map[string]value{}
+			for !p.is(tokenPunct, "}") {
+				name := p.name()
+				p.expect(tokenPunct, ":")
+				m[name] = p.value(constant)
+			}
+			p.next()
+			return value{literal: m}
+		}
+	case tokenInt:
+		p.next()
+		n, err := strconv.ParseInt(t.value, 10, 64)
+		if err != nil {
+			p.fail(fmt.Sprintf("invalid integer %q", t.value))
+		}
+		return value{literal: n}
+	case tokenFloat:
+		p.next()
+		f, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			p.fail(fmt.Sprintf("invalid float %q", t.value))
+		}
+		return value{literal: f}
+	case tokenString:
+		p.next()
+		return value{literal: t.value}
+	case tokenName:
+		p.next()
+		switch t.value {
+		case "true":
+			return value{literal: true}
+		case "false":
+			return value{literal: false}
+		case "null":
+			return value{literal: nil}
+		}
+		return value{literal: enum(t.value)}
+	}
+
+	p.fail(fmt.Sprintf("unexpected %q", t.value))
+	return value{}
+}
+
+// Reads the next token into p.tok.
+func (p *parser) next() {
+	p.skipIgnored()
+
+	p.tok = token{line: p.line, col: p.col}
+	if p.pos >= len(p.src) {
+		p.tok.kind = tokenEOF
+		return
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.tok.kind, p.tok.value = tokenPunct, "..."
+		p.advance(3)
+	case strings.IndexByte("!$&()*:=@[]{}|", c) >= 0:
+		p.tok.kind, p.tok.value = tokenPunct, string(c)
+		p.advance(1)
+	case c == '_' || isLetter(c):
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.advance(1)
+		}
+		p.tok.kind, p.tok.value = tokenName, p.src[start:p.pos]
+	case c == '-' || isDigit(c):
+		p.number()
+	case c == '"':
+		p.string()
+	default:
+		p.fail(fmt.Sprintf("unexpected character %q", c))
+	}
+}
+
+func (p *parser) number() {
+	start := p.pos
+	p.tok.kind = tokenInt
+
+	if p.peek() == '-' {
+		p.advance(1)
+	}
+	if !isDigit(p.peek()) {
+		p.fail("invalid number")
+	}
+	for isDigit(p.peek()) {
+		p.advance(1)
+	}
+	if p.peek() == '.' {
+		p.tok.kind = tokenFloat
+		p.advance(1)
+		if !isDigit(p.peek()) {
+			p.fail("invalid number")
+		}
+		for isDigit(p.peek()) {
+			p.advance(1)
+		}
+	}
+	if p.peek() == 'e' || p.peek() == 'E' {
+		p.tok.kind = tokenFloat
+		p.advance(1)
+		if p.peek() == '+' || p.peek() == '-' {
+			p.advance(1)
+		}
+		if !isDigit(p.peek()) {
+			p.fail("invalid number")
+		}
+		for isDigit(p.peek()) {
+			p.advance(1)
+		}
+	}
+
+	p.tok.value = p.src[start:p.pos]
+}
+
+func (p *parser) string() {
+	p.tok.kind = tokenString
+
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		p.advance(3)
+		end := strings.Index(p.src[p.pos:], `"""`)
+		if end < 0 {
+			p.fail("unterminated block string")
+		}
+		raw := p.src[p.pos : p.pos+end]
+		p.advance(end + 3)
+		p.tok.value = blockString(strings.ReplaceAll(raw, `\"""`, `"""`))
+		return
+	}
+
+	p.advance(1)
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			p.fail("unterminated string")
+		}
+
+		c := p.src[p.pos]
+		if c == '"' {
+			p.advance(1)
+			break
+		}
+		if c != '\\' {
+			r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			b.WriteRune(r)
+			p.advance(size)
+			continue
+		}
+
+		if p.pos+1 >= len(p.src) {
+			p.fail("unterminated string")
+		}
+		switch e := p.src[p.pos+1]; e {
+		case '"', '\\', '/':
+			b.WriteByte(e)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if p.pos+6 > len(p.src) {
+				p.fail("invalid unicode escape")
+			}
+			n, err := strconv.ParseUint(p.src[p.pos+2:p.pos+6], 16, 32)
+			if err != nil {
+				p.fail("invalid unicode escape")
+			}
+			b.WriteRune(rune(n))
+			p.advance(4)
+		default:
+			p.fail(fmt.Sprintf("invalid escape %q", e))
+		}
+		p.advance(2)
+	}
+
+	p.tok.value = b.String()
+}
+
+// Removes the common indentation and the leading and trailing blank lines of
+// a block string.
+func blockString(raw string) string {
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+
+	indent := -1
+	for _, l := range lines[1:] {
+		trimmed := strings.TrimLeft(l, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(l) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = strings.TrimLeft(lines[i], " \t")
+			}
+		}
+	}
+
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Skips whitespace, commas and comments.
+func (p *parser) skipIgnored() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; c {
+		case ' ', '\t', ',', '\r', '\n':
+			p.advance(1)
+		case '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.advance(1)
+			}
+		default:
+			if strings.HasPrefix(p.src[p.pos:], "\uFEFF") {
+				p.advance(3)
+				continue
+			}
+			return
+		}
+	}
+}
+
+func (p *parser) advance(n int) {
+	for i := 0; i < n && p.pos < len(p.src); i++ {
+		if p.src[p.pos] == '\n' {
+			p.line, p.col = p.line+1, 1
+		} else {
+			p.col++
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) peek() byte {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,197 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins
+
+import (
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/visibility"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const headersSourcerName = "blogo-headers-sourcer"
+
+// Headers set on the responses of the files that match the pattern, which has
+// the same syntax as [visibility.Match], for example "assets/**".
+type HeaderRule struct {
+	Pattern string
+	Header  http.Header
+}
+
+// Creates a [plugin.Sourcer] that wraps the file system of the sourcer, attaching
+// the headers of the rules that match each file to it's information (see
+// [plugin.FileInfo]), so the server sets them on the response, such as
+// Cache-Control for static assets. Headers of later rules, and of the wrapped
+// file system itself, take precedence.
+func NewHeadersSourcer(
+	sourcer plugin.Sourcer,
+	rules []HeaderRule,
+	opts ...HeadersSourcerOpts,
+) plugin.Sourcer {
+	opt := HeadersSourcerOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be wrapped should not be nil")
+
+	return &headersSourcer{
+		sourcer: sourcer,
+		rules:   rules,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type HeadersSourcerOpts struct {
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type headersSourcer struct {
+	sourcer plugin.Sourcer
+	rules   []HeaderRule
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (s *headersSourcer) Name() string {
+	return headersSourcerName
+}
+
+func (s *headersSourcer) Source() (fs.FS, error) {
+	s.assert.NotNil(s.sourcer)
+
+	fsys, err := s.sourcer.Source()
+	if err != nil {
+		return fsys, err
+	}
+
+	return &headersFS{FS: fsys, rules: s.rules}, nil
+}
+
+type headersFS struct {
+	fs.FS
+	rules []HeaderRule
+}
+
+func (fsys *headersFS) Metadata() metadata.Metadata {
+	if m, err := metadata.GetMetadata(fsys.FS); err == nil {
+		return m
+	}
+	return metadata.Map(map[string]any{})
+}
+
+func (fsys *headersFS) Open(name string) (fs.File, error) {
+	f, err := fsys.FS.Open(name)
+	if err != nil {
+		return f, err
+	}
+
+	h := http.Header{}
+	for _, r := range fsys.rules {
+		if !visibility.Match(r.Pattern, name) {
+			continue
+		}
+		for k, v := range r.Header {
+			h[http.CanonicalHeaderKey(k)] = v
+		}
+	}
+	if len(h) == 0 {
+		return f, nil
+	}
+
+	hf := headersFile{File: f, header: h}
+	switch f := f.(type) {
+	case fs.ReadDirFile:
+		return &headersDirFile{headersFile: hf, dir: f}, nil
+	case io.Seeker:
+		return &headersSeekerFile{headersFile: hf}, nil
+	default:
+		return &hf, nil
+	}
+}
+
+type headersFile struct {
+	fs.File
+	header http.Header
+}
+
+func (f *headersFile) Stat() (fs.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return info, err
+	}
+
+	h := f.header.Clone()
+	if info, ok := info.(plugin.FileInfo); ok {
+		for k, v := range info.Header() {
+			h[http.CanonicalHeaderKey(k)] = v
+		}
+	}
+
+	return &headersFileInfo{FileInfo: info, header: h}, nil
+}
+
+func (f *headersFile) Metadata() metadata.Metadata {
+	if m, err := metadata.GetMetadata(f.File); err == nil {
+		return m
+	}
+	return metadata.Map(map[string]any{})
+}
+
+// Keeps files that implement [io.Seeker] seekable, so renderers can read them
+// more than once.
+type headersSeekerFile struct {
+	headersFile
+}
+
+func (f *headersSeekerFile) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+type headersDirFile struct {
+	headersFile
+	dir fs.ReadDirFile
+}
+
+func (f *headersDirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	return f.dir.ReadDir(n)
+}
+
+// Implements [plugin.FileInfo].
+type headersFileInfo struct {
+	fs.FileInfo
+	header http.Header
+}
+
+func (i *headersFileInfo) Header() http.Header {
+	return i.header
+}
@@ -0,0 +1,275 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins
+
+import (
+	"bytes"
+	"html"
+	"slices"
+	"strings"
+)
+
+// Helpers used by the plugins that transform already rendered HTML, such as
+// [NewTypographer]. It isn't a complete HTML parser, only a tokenizer good enough
+// to differentiate text from tags on the output of the built-in renderers.
+
+type htmlTokenKind int
+
+const (
+	htmlTextToken htmlTokenKind = iota
+	htmlStartTagToken
+	htmlEndTagToken
+	htmlSelfClosingTagToken
+	htmlCommentToken
+	htmlDoctypeToken
+)
+
+type htmlToken struct {
+	Kind htmlTokenKind
+	// Lowercase name of the tag, empty on text, comment and doctype tokens.
+	Tag string
+	// Raw bytes of the token, as found on the source.
+	Raw []byte
+}
+
+// Elements which contents are not HTML, and should be treated as raw text until
+// their end tag.
+var htmlRawTextElements = []string{"script", "style", "textarea", "title"}
+
+func tokenizeHTML(src []byte) []htmlToken {
+	tokens := []htmlToken{}
+
+	for len(src) > 0 {
+		i := bytes.IndexByte(src, '<')
+		if i == -1 {
+			tokens = append(tokens, htmlToken{Kind: htmlTextToken, Raw: src})
+			break
+		}
+		if i > 0 {
+			tokens = append(tokens, htmlToken{Kind: htmlTextToken, Raw: src[:i]})
+			src = src[i:]
+		}
+
+		tok, n := readHTMLTag(src)
+		tokens = append(tokens, tok)
+		src = src[n:]
+
+		if tok.Kind != htmlStartTagToken || !slices.Contains(htmlRawTextElements, tok.Tag) {
+			continue
+		}
+
+		end := indexFold(src, []byte("</"+tok.Tag))
+		if end == -1 {
+			end = len(src)
+		}
+		if end > 0 {
+			tokens = append(tokens, htmlToken{Kind: htmlTextToken, Raw: src[:end]})
+		}
+		src = src[end:]
+	}
+
+	return tokens
+}
+
+func readHTMLTag(src []byte) (htmlToken, int) {
+	if bytes.HasPrefix(src, []byte("<!--")) {
+		end := bytes.Index(src[4:], []byte("-->"))
+		if end == -1 {
+			return htmlToken{Kind: htmlCommentToken, Raw: src}, len(src)
+		}
+		n := 4 + end + 3
+		return htmlToken{Kind: htmlCommentToken, Raw: src[:n]}, n
+	}
+
+	if bytes.HasPrefix(src, []byte("<!")) || bytes.HasPrefix(src, []byte("<?")) {
+		end := bytes.IndexByte(src, '>')
+		if end == -1 {
+			return htmlToken{Kind: htmlDoctypeToken, Raw: src}, len(src)
+		}
+		return htmlToken{Kind: htmlDoctypeToken, Raw: src[:end+1]}, end + 1
+	}
+
+	kind := htmlStartTagToken
+	start := 1
+	if len(src) > 1 && src[1] == '/' {
+		kind = htmlEndTagToken
+		start = 2
+	}
+
+	nameEnd := start
+	for nameEnd < len(src) && isHTMLNameByte(src[nameEnd]) {
+		nameEnd++
+	}
+	if nameEnd == start {
+		// Not a tag, just a lone "<" character on the text.
+		return htmlToken{Kind: htmlTextToken, Raw: src[:1]}, 1
+	}
+
+	end := findHTMLTagEnd(src, nameEnd)
+	if end == -1 {
+		return htmlToken{Kind: htmlTextToken, Raw: src}, len(src)
+	}
+
+	raw := src[:end+1]
+	if kind == htmlStartTagToken && bytes.HasSuffix(raw, []byte("/>")) {
+		kind = htmlSelfClosingTagToken
+	}
+
+	return htmlToken{
+		Kind: kind,
+		Tag:  strings.ToLower(string(src[start:nameEnd])),
+		Raw:  raw,
+	}, end + 1
+}
+
+// Finds the closing ">" of a tag, ignoring the ones inside quoted attribute values.
+func findHTMLTagEnd(src []byte, from int) int {
+	var quote byte
+	for i := from; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i
+		}
+	}
+	return -1
+}
+
+func isHTMLNameByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' ||
+		c == ':'
+}
+
+type htmlAttr struct {
+	Key string
+	Val string
+	// If the attribute has a value or is just a boolean attribute, such as "disabled".
+	HasVal bool
+}
+
+// Parses the attributes of a start tag token. Values are unescaped.
+func parseHTMLAttrs(tok htmlToken) []htmlAttr {
+	attrs := []htmlAttr{}
+
+	raw := tok.Raw
+	raw = bytes.TrimPrefix(raw, []byte("<"))
+	raw = bytes.TrimSuffix(raw, []byte(">"))
+	raw = bytes.TrimSuffix(raw, []byte("/"))
+	raw = raw[min(len(tok.Tag), len(raw)):]
+
+	for {
+		raw = bytes.TrimLeft(raw, " \t\n\r\f/")
+		if len(raw) == 0 {
+			break
+		}
+
+		i := bytes.IndexAny(raw, " \t\n\r\f=")
+		if i == -1 {
+			attrs = append(attrs, htmlAttr{Key: strings.ToLower(string(raw))})
+			break
+		}
+
+		key := strings.ToLower(string(raw[:i]))
+		raw = bytes.TrimLeft(raw[i:], " \t\n\r\f")
+
+		if len(raw) == 0 || raw[0] != '=' {
+			attrs = append(attrs, htmlAttr{Key: key})
+			continue
+		}
+
+		raw = bytes.TrimLeft(raw[1:], " \t\n\r\f")
+
+		var val []byte
+		if len(raw) > 0 && (raw[0] == '"' || raw[0] == '\'') {
+			end := bytes.IndexByte(raw[1:], raw[0])
+			if end == -1 {
+				val, raw = raw[1:], nil
+			} else {
+				val, raw = raw[1:end+1], raw[end+2:]
+			}
+		} else {
+			end := bytes.IndexAny(raw, " \t\n\r\f")
+			if end == -1 {
+				val, raw = raw, nil
+			} else {
+				val, raw = raw[:end], raw[end:]
+			}
+		}
+
+		attrs = append(attrs, htmlAttr{Key: key, Val: html.UnescapeString(string(val)), HasVal: true})
+	}
+
+	return attrs
+}
+
+func getHTMLAttr(attrs []htmlAttr, key string) (string, bool) {
+	for _, a := range attrs {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func setHTMLAttr(attrs []htmlAttr, key, val string) []htmlAttr {
+	for i, a := range attrs {
+		if a.Key == key {
+			attrs[i].Val, attrs[i].HasVal = val, true
+			return attrs
+		}
+	}
+	return append(attrs, htmlAttr{Key: key, Val: val, HasVal: true})
+}
+
+func renderHTMLStartTag(tag string, attrs []htmlAttr, selfClosing bool) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte('<')
+	buf.WriteString(tag)
+
+	for _, a := range attrs {
+		buf.WriteByte(' ')
+		buf.WriteString(a.Key)
+		if a.HasVal {
+			buf.WriteString(`="`)
+			buf.WriteString(html.EscapeString(a.Val))
+			buf.WriteByte('"')
+		}
+	}
+
+	if selfClosing {
+		buf.WriteString(" /")
+	}
+	buf.WriteByte('>')
+
+	return buf.Bytes()
+}
+
+// Escapes just the characters needed to be escaped on text nodes.
+func escapeHTMLText(s string) string {
+	return htmlTextEscaper.Replace(s)
+}
+
+var htmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func indexFold(s, sep []byte) int {
+	return bytes.Index(bytes.ToLower(s), bytes.ToLower(sep))
+}
@@ -0,0 +1,359 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package images provides a image pipeline [plugin.Middleware], which serves
+// resized versions of the images served by the blog, such as "photo.jpg?w=320",
+// used for thumbnails and responsive images.
+//
+// Themes can request the variants they need with the template functions of the
+// plugin, such as {{image "photo.jpg" 800}} or {{srcset "photo.jpg"}}.
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-images-middleware"
+
+// Widths that can be requested if none are provided in [Opts].
+var DefaultWidths = []int{160, 320, 640, 1024, 1600}
+
+// Formats that resized images can be encoded to, requested with the format
+// query parameter, such as "photo.png?w=320&f=jpeg".
+var Formats = []string{"jpeg", "png", "gif"}
+
+// The image pipeline plugin, which is a [plugin.Middleware] serving resized
+// images and provides template functions to build their URLs.
+type Plugin interface {
+	plugin.Middleware
+	// Returns the template functions:
+	//
+	//   - "image PATH WIDTH [FORMAT]" returns the URL of the variant of the image
+	//     with the smallest allowed width that is equal or larger than WIDTH, so
+	//     themes don't need to know the exact widths of Opts.Widths;
+	//   - "srcset PATH [FORMAT]" returns a srcset attribute value with all the
+	//     allowed widths of the image.
+	FuncMap() template.FuncMap
+}
+
+// Returns the URL of the resized version of the image at the path, as served by
+// the middleware with the default query parameter.
+func URL(path string, width int) string {
+	return fmt.Sprintf("%s?w=%d", path, width)
+}
+
+// Creates a [plugin.Middleware] that resizes the images served by the next
+// handler when the width query parameter is present, keeping their aspect ratio.
+// Images are never upscaled.
+//
+// Only the widths on Opts.Widths can be requested, so clients can't generate an
+// unbounded number of variants. Resized images are cached in memory.
+//
+// The format query parameter converts the image to one of [Formats], otherwise
+// resized images keep the format of the original.
+func New(opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.QueryParam == "" {
+		opt.QueryParam = "w"
+	}
+	if opt.FormatParam == "" {
+		opt.FormatParam = "f"
+	}
+	if opt.Widths == nil {
+		opt.Widths = DefaultWidths
+	}
+	if opt.Quality == 0 {
+		opt.Quality = 80
+	}
+	if opt.MaxCacheEntries == 0 {
+		opt.MaxCacheEntries = 512
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &p{
+		queryParam:  opt.QueryParam,
+		formatParam: opt.FormatParam,
+		widths:      opt.Widths,
+		quality:     opt.Quality,
+		maxEntries:  opt.MaxCacheEntries,
+		overload:    opt.Overload,
+
+		cache: map[string]cacheEntry{},
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Name of the query parameter of the width. Defaults to "w".
+	QueryParam string
+	// Name of the query parameter of the format. Defaults to "f".
+	FormatParam string
+	// Widths that can be requested. Defaults to [DefaultWidths].
+	Widths []int
+	// Quality of resized JPEG images. Defaults to 80.
+	Quality int
+	// Max number of resized images cached. When reached, the cache is cleared.
+	// Defaults to 512.
+	MaxCacheEntries int
+	// Profile of the server overload. While it's overloaded, images that aren't
+	// cached yet are served in their original size instead of resized.
+	Overload *core.Overload
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	queryParam  string
+	formatParam string
+	widths      []int
+	quality     int
+	maxEntries  int
+	overload    *core.Overload
+
+	cacheMu sync.Mutex
+	cache   map[string]cacheEntry
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+type cacheEntry struct {
+	contentType string
+	body        []byte
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.cache)
+		p.assert.NotNil(p.log)
+
+		q := r.URL.Query().Get(p.queryParam)
+		if q == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		width, err := strconv.Atoi(q)
+		if err != nil || !slices.Contains(p.widths, width) {
+			http.Error(w, "Invalid image width", http.StatusBadRequest)
+			return
+		}
+
+		format := r.URL.Query().Get(p.formatParam)
+		if format != "" && !slices.Contains(Formats, format) {
+			http.Error(w, "Invalid image format", http.StatusBadRequest)
+			return
+		}
+
+		log := p.log.With(
+			slog.String("path", r.URL.Path),
+			slog.Int("width", width),
+			slog.String("format", format),
+		)
+		key := fmt.Sprintf("%d %s %s", width, format, r.URL.Path)
+
+		p.cacheMu.Lock()
+		e, ok := p.cache[key]
+		p.cacheMu.Unlock()
+
+		if !ok {
+			rec := newRecorder()
+
+			// The original image is requested without the width parameter, so
+			// other middlewares and the server don't see it.
+			req := r.Clone(r.Context())
+			values := req.URL.Query()
+			values.Del(p.queryParam)
+			values.Del(p.formatParam)
+			req.URL = &url.URL{Path: r.URL.Path, RawQuery: values.Encode()}
+			next.ServeHTTP(rec, req)
+
+			if rec.status != http.StatusOK {
+				rec.flush(w)
+				return
+			}
+
+			if p.overload != nil && p.overload.Overloaded() {
+				log.Debug("Server overloaded, serving original image")
+				rec.flush(w)
+				return
+			}
+
+			log.Debug("Resizing image")
+
+			e, err = p.resize(rec.body.Bytes(), width, format)
+			if err != nil {
+				log.Debug("Failed to resize image, serving original", slog.String("err", err.Error()))
+				rec.flush(w)
+				return
+			}
+
+			p.cacheMu.Lock()
+			if len(p.cache) >= p.maxEntries {
+				p.cache = map[string]cacheEntry{}
+			}
+			p.cache[key] = e
+			p.cacheMu.Unlock()
+		}
+
+		w.Header().Set("Content-Type", e.contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(e.body)))
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		if _, err := w.Write(e.body); err != nil {
+			log.Error("Failed to write resized image", slog.String("err", err.Error()))
+		}
+	})
+}
+
+func (p *p) resize(src []byte, width int, format string) (cacheEntry, error) {
+	img, original, err := image.Decode(bytes.NewReader(src))
+	if err != nil {
+		return cacheEntry{}, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	if format == "" {
+		format = original
+	}
+
+	if img.Bounds().Dx() > width {
+		img = Resize(img, width)
+	}
+
+	var buf bytes.Buffer
+	var contentType string
+
+	switch format {
+	case "png":
+		contentType = "image/png"
+		err = png.Encode(&buf, img)
+	case "gif":
+		contentType = "image/gif"
+		err = gif.Encode(&buf, img, nil)
+	default:
+		contentType = "image/jpeg"
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: p.quality})
+	}
+	if err != nil {
+		return cacheEntry{}, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	return cacheEntry{contentType: contentType, body: buf.Bytes()}, nil
+}
+
+func (p *p) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"image": func(path string, width int, format ...string) string {
+			return p.url(path, p.width(width), format...)
+		},
+		"srcset": func(path string, format ...string) string {
+			srcset := make([]string, len(p.widths))
+			for i, w := range p.widths {
+				srcset[i] = fmt.Sprintf("%s %dw", p.url(path, w, format...), w)
+			}
+			return strings.Join(srcset, ", ")
+		},
+	}
+}
+
+// Returns the smallest allowed width that is equal or larger than the width,
+// or the largest allowed width if there isn't any.
+func (p *p) width(width int) int {
+	best, largest := 0, 0
+	for _, w := range p.widths {
+		if w >= width && (best == 0 || w < best) {
+			best = w
+		}
+		largest = max(largest, w)
+	}
+	if best == 0 {
+		return largest
+	}
+	return best
+}
+
+func (p *p) url(path string, width int, format ...string) string {
+	values := url.Values{}
+	values.Set(p.queryParam, strconv.Itoa(width))
+	if len(format) > 0 && format[0] != "" {
+		values.Set(p.formatParam, format[0])
+	}
+	return path + "?" + values.Encode()
+}
+
+// Minimal [http.ResponseWriter] that holds the response in memory.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: http.Header{}, status: http.StatusOK}
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	return r.body.Write(p)
+}
+
+func (r *recorder) flush(w http.ResponseWriter) {
+	for k, v := range r.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(r.status)
+	_, _ = w.Write(r.body.Bytes())
+}
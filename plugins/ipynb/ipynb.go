@@ -0,0 +1,329 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ipynb provides a [plugin.Renderer] of Jupyter notebooks (".ipynb"
+// files), rendering their markdown cells, code cells and outputs, including
+// images, as HTML, so notebooks can be published directly as posts.
+//
+// The metadata of notebooks can be parsed by the frontmatter plugin with
+// [Frontmatter].
+package ipynb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-ipynb-renderer"
+
+// Creates a [plugin.Renderer] of the files with the ".ipynb" extension, returning
+// a error for other files, so it can be used alongside other renderers.
+//
+// Notebooks are rendered as a "notebook" div with a div for each cell, with
+// the "cell" class and the class of the type of the cell. Code cells have
+// their source, on a "language-<language>" code block, followed by a "outputs"
+// div. Raw cells aren't rendered. Images of outputs and attachments are
+// embedded as data URIs.
+func New(opts ...Opts) plugin.Renderer {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Markdown == nil {
+		rendererOpts := []goldmark.Option{goldmark.WithExtensions(extension.GFM)}
+		if opt.AllowHTML {
+			rendererOpts = append(rendererOpts, goldmark.WithRendererOptions(gmhtml.WithUnsafe()))
+		}
+		opt.Markdown = goldmark.New(rendererOpts...)
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &p{
+		markdown:    opt.Markdown,
+		allowHTML:   opt.AllowHTML,
+		hideInputs:  opt.HideInputs,
+		hideOutputs: opt.HideOutputs,
+		prompts:     opt.Prompts,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Renderer of markdown cells and outputs. Defaults to goldmark with the
+	// GFM extensions.
+	Markdown goldmark.Markdown
+	// Renders HTML outputs, such as the tables of data frames, and raw HTML on
+	// markdown cells. Only enable it for trusted notebooks, otherwise outputs
+	// are rendered from their plain text.
+	AllowHTML bool
+
+	// Don't render the source of code cells, only their outputs.
+	HideInputs bool
+	// Don't render the outputs of code cells.
+	HideOutputs bool
+	// Renders the execution count of code cells, such as "In [3]:".
+	Prompts bool
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	markdown    goldmark.Markdown
+	allowHTML   bool
+	hideInputs  bool
+	hideOutputs bool
+	prompts     bool
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Render(src fs.File, w io.Writer) error {
+	p.assert.NotNil(src)
+	p.assert.NotNil(w)
+	p.assert.NotNil(p.markdown)
+	p.assert.NotNil(p.log)
+
+	stat, err := src.Stat()
+	if err != nil || stat.IsDir() || !strings.HasSuffix(stat.Name(), ".ipynb") {
+		return errors.New("does not support file, ipynb renderer only renders notebooks")
+	}
+
+	contents, err := io.ReadAll(src)
+	if err != nil {
+		return errors.Join(errors.New("failed to read notebook"), err)
+	}
+
+	nb, err := parse(contents)
+	if err != nil {
+		return err
+	}
+
+	p.log.Debug("Rendering notebook", slog.String("file", stat.Name()), slog.Int("cells", len(nb.Cells)))
+
+	var b bytes.Buffer
+	b.WriteString(`<div class="notebook">` + "\n")
+	for _, c := range nb.Cells {
+		if err := p.cell(&b, nb, c); err != nil {
+			return err
+		}
+	}
+	b.WriteString("</div>\n")
+
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+func (p *p) cell(b *bytes.Buffer, nb notebook, c cell) error {
+	switch c.Type {
+	case "markdown":
+		b.WriteString(`<div class="cell markdown">` + "\n")
+		if err := p.markdown.Convert([]byte(attachments(string(c.Source), c.Attachments)), b); err != nil {
+			return errors.Join(errors.New("failed to render markdown cell"), err)
+		}
+		b.WriteString("</div>\n")
+
+	case "code":
+		if p.hideInputs && (p.hideOutputs || len(c.Outputs) == 0) {
+			return nil
+		}
+
+		b.WriteString(`<div class="cell code">` + "\n")
+		if p.prompts {
+			count := " "
+			if c.ExecutionCount != nil {
+				count = fmt.Sprint(*c.ExecutionCount)
+			}
+			fmt.Fprintf(b, `<div class="prompt">In [%s]:</div>`+"\n", count)
+		}
+		if !p.hideInputs {
+			class := ""
+			if l := nb.language(); l != "" {
+				class = ` class="language-` + html.EscapeString(l) + `"`
+			}
+			fmt.Fprintf(b, "<pre><code%s>%s</code></pre>\n", class, html.EscapeString(string(c.Source)))
+		}
+		if !p.hideOutputs && len(c.Outputs) > 0 {
+			b.WriteString(`<div class="outputs">` + "\n")
+			for _, o := range c.Outputs {
+				if err := p.output(b, o); err != nil {
+					return err
+				}
+			}
+			b.WriteString("</div>\n")
+		}
+		b.WriteString("</div>\n")
+	}
+
+	return nil
+}
+
+var ansi = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+func (p *p) output(b *bytes.Buffer, o output) error {
+	switch o.Type {
+	case "stream":
+		fmt.Fprintf(b, `<div class="output stream %s"><pre>%s</pre></div>`+"\n",
+			html.EscapeString(o.Name), html.EscapeString(ansi.ReplaceAllString(string(o.Text), "")))
+
+	case "error":
+		text := o.Ename + ": " + o.Evalue
+		if len(o.Traceback) > 0 {
+			text = strings.Join(o.Traceback, "\n")
+		}
+		fmt.Fprintf(b, `<div class="output error"><pre>%s</pre></div>`+"\n",
+			html.EscapeString(ansi.ReplaceAllString(text, "")))
+
+	case "display_data", "execute_result":
+		return p.data(b, o.Data)
+	}
+
+	return nil
+}
+
+// Renders the richest representation of the data that is supported.
+func (p *p) data(b *bytes.Buffer, data map[string]json.RawMessage) error {
+	text := func(mime string) (string, bool) {
+		raw, ok := data[mime]
+		if !ok {
+			return "", false
+		}
+		var m multiline
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return "", false
+		}
+		return string(m), true
+	}
+
+	if s, ok := text("text/html"); ok && p.allowHTML {
+		b.WriteString(`<div class="output html">` + s + "</div>\n")
+		return nil
+	}
+	if s, ok := text("image/svg+xml"); ok {
+		image(b, "image/svg+xml", []byte(s))
+		return nil
+	}
+	for _, mime := range []string{"image/png", "image/jpeg", "image/gif", "image/webp"} {
+		if s, ok := text(mime); ok {
+			// Images are decoded and encoded again, so only valid base64 is
+			// written to the attribute.
+			img, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+			if err != nil {
+				p.log.Warn("Invalid image on notebook output", slog.String("mime", mime))
+				continue
+			}
+			image(b, mime, img)
+			return nil
+		}
+	}
+	if s, ok := text("text/markdown"); ok {
+		b.WriteString(`<div class="output markdown">` + "\n")
+		if err := p.markdown.Convert([]byte(s), b); err != nil {
+			return errors.Join(errors.New("failed to render markdown output"), err)
+		}
+		b.WriteString("</div>\n")
+		return nil
+	}
+	if s, ok := text("text/plain"); ok {
+		fmt.Fprintf(b, `<div class="output text"><pre>%s</pre></div>`+"\n",
+			html.EscapeString(ansi.ReplaceAllString(s, "")))
+	}
+
+	return nil
+}
+
+func image(b *bytes.Buffer, mime string, img []byte) {
+	fmt.Fprintf(b, `<div class="output image"><img src="%s" alt=""></div>`+"\n", dataURI(mime, img))
+}
+
+func dataURI(mime string, data []byte) string {
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// Replaces the references to the attachments of the cell, such as
+// "attachment:plot.png", with their data URIs. Longer names are replaced first,
+// so names that are prefixes of others don't replace part of their references,
+// and the media types of each attachment are tried in order, so the render is
+// the same for the same notebook.
+func attachments(source string, as map[string]map[string]string) string {
+	names := slices.Collect(maps.Keys(as))
+	slices.SortFunc(names, func(a, b string) int {
+		if len(a) != len(b) {
+			return len(b) - len(a)
+		}
+		return strings.Compare(a, b)
+	})
+
+	for _, name := range names {
+		data := as[name]
+		for _, mime := range slices.Sorted(maps.Keys(data)) {
+			if !strings.HasPrefix(mime, "image/") {
+				continue
+			}
+			img, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(data[mime]), ""))
+			if err != nil {
+				continue
+			}
+			source = strings.ReplaceAll(source, "attachment:"+name, dataURI(mime, img))
+			break
+		}
+	}
+	return source
+}
@@ -0,0 +1,168 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipynb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"forge.capytal.company/loreddev/blogo/plugins/frontmatter"
+)
+
+// A Jupyter notebook, on the nbformat 4 JSON format.
+type notebook struct {
+	Cells    []cell `json:"cells"`
+	Metadata struct {
+		Title   string `json:"title"`
+		Authors []struct {
+			Name string `json:"name"`
+		} `json:"authors"`
+		Kernelspec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+	Format int `json:"nbformat"`
+}
+
+type cell struct {
+	Type           string                       `json:"cell_type"`
+	Source         multiline                    `json:"source"`
+	Outputs        []output                     `json:"outputs"`
+	ExecutionCount *int                         `json:"execution_count"`
+	Attachments    map[string]map[string]string `json:"attachments"`
+}
+
+type output struct {
+	Type string `json:"output_type"`
+
+	// Of "stream" outputs.
+	Name string    `json:"name"`
+	Text multiline `json:"text"`
+
+	// Of "display_data" and "execute_result" outputs, by MIME type.
+	Data map[string]json.RawMessage `json:"data"`
+
+	// Of "error" outputs.
+	Ename     string   `json:"ename"`
+	Evalue    string   `json:"evalue"`
+	Traceback []string `json:"traceback"`
+}
+
+// Text of notebooks, which is a string or a list of lines.
+type multiline string
+
+func (m *multiline) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*m = multiline(s)
+		return nil
+	}
+
+	var lines []string
+	if err := json.Unmarshal(b, &lines); err != nil {
+		return errors.New("text should be a string or a list of strings")
+	}
+	*m = multiline(strings.Join(lines, ""))
+	return nil
+}
+
+func parse(contents []byte) (notebook, error) {
+	var nb notebook
+	if err := json.Unmarshal(contents, &nb); err != nil {
+		return notebook{}, errors.Join(errors.New("failed to parse notebook"), err)
+	}
+	if nb.Format != 4 {
+		return notebook{}, errors.New("unsupported notebook format, only nbformat 4 is supported")
+	}
+	return nb, nil
+}
+
+// Returns the language of the code cells.
+func (nb notebook) language() string {
+	if nb.Metadata.LanguageInfo.Name != "" {
+		return nb.Metadata.LanguageInfo.Name
+	}
+	return nb.Metadata.Kernelspec.Language
+}
+
+// Reports if the cell is a raw cell with a YAML frontmatter, such as the first
+// cell of notebooks of Quarto and Jupytext.
+func (c cell) isFrontmatter() bool {
+	return c.Type == "raw" && strings.HasPrefix(strings.TrimSpace(string(c.Source)), "---")
+}
+
+// Implements [frontmatter.Parser] for notebooks, so their metadata can be used
+// by the frontmatter plugin:
+//
+//	frontmatter.New(sourcer, frontmatter.Opts{
+//		Extensions: []string{".md", ".ipynb"},
+//		Parsers:    map[string]frontmatter.Parser{".ipynb": ipynb.Frontmatter},
+//	})
+//
+// The metadata is the YAML frontmatter of the first cell, if it's a raw cell
+// that starts with "---". The "title" and "author" values default to the title
+// and first author of the metadata of the notebook, and the title to the first
+// heading of the markdown cells. The body is the text of the markdown cells.
+func Frontmatter(contents []byte) (map[string]any, []byte, error) {
+	nb, err := parse(contents)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m := map[string]any{}
+	if len(nb.Cells) > 0 && nb.Cells[0].isFrontmatter() {
+		m, err = frontmatter.Parse([]byte(nb.Cells[0].Source))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var body bytes.Buffer
+	for _, c := range nb.Cells {
+		if c.Type == "markdown" {
+			body.WriteString(string(c.Source))
+			body.WriteString("\n\n")
+		}
+	}
+
+	if _, ok := m["title"]; !ok && nb.Metadata.Title != "" {
+		m["title"] = nb.Metadata.Title
+	}
+	if _, ok := m["title"]; !ok {
+		if h, ok := firstHeading(body.String()); ok {
+			m["title"] = h
+		}
+	}
+	if _, ok := m["author"]; !ok && len(nb.Metadata.Authors) > 0 && nb.Metadata.Authors[0].Name != "" {
+		m["author"] = nb.Metadata.Authors[0].Name
+	}
+
+	return m, body.Bytes(), nil
+}
+
+func firstHeading(md string) (string, bool) {
+	for _, l := range strings.Split(md, "\n") {
+		if h, ok := strings.CutPrefix(strings.TrimSpace(l), "# "); ok && strings.TrimSpace(h) != "" {
+			return strings.TrimSpace(h), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,42 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipynb_test
+
+import (
+	"testing"
+
+	"forge.capytal.company/loreddev/blogo/plugins/ipynb"
+)
+
+func FuzzFrontmatter(f *testing.F) {
+	for _, s := range []string{
+		`{"nbformat": 4, "metadata": {"title": "Notebook"}, "cells": []}`,
+		`{"nbformat": 4, "cells": [{"cell_type": "raw", "source": "---\ntitle: Raw\n---"}]}`,
+		`{"nbformat": 4, "cells": [{"cell_type": "raw", "source": ["---\n", "~\n", "---"]}]}`,
+		`{"nbformat": 4, "cells": [{"cell_type": "markdown", "source": ["# Heading\n", "text"]}]}`,
+		`{"nbformat": 3}`,
+		`{"cells": [null]}`,
+	} {
+		f.Add([]byte(s))
+	}
+
+	f.Fuzz(func(t *testing.T, contents []byte) {
+		m, _, err := ipynb.Frontmatter(contents)
+		if err == nil && m == nil {
+			t.Fatalf("Frontmatter should return a map if the notebook is valid")
+		}
+	})
+}
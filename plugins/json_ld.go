@@ -0,0 +1,287 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/canonical"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/plugins/frontmatter"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const jsonLDName = "blogo-jsonld-renderer"
+
+// Metadata key that [NewJSONLD] uses to get the path of the file, which is set by
+// the frontmatter plugin.
+const jsonLDPathKey = "frontmatter.path"
+
+// Creates a [plugin.Renderer] that transforms already rendered HTML, injecting
+// schema.org structured data as JSON-LD, generated from the metadata of the file
+// ("title", "summary", "date", "updated", "author", "image" and "tags").
+//
+// The script is injected before the end of the head element, or at the start of
+// the output if there isn't one.
+//
+// This renderer is intended to be used after other renderers in a [FoldingRenderer].
+func NewJSONLD(opts ...JSONLDOpts) plugin.Renderer {
+	opt := JSONLDOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.DefaultType == "" {
+		opt.DefaultType = "BlogPosting"
+	}
+	if opt.Types == nil {
+		opt.Types = map[string]string{}
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &jsonLD{
+		baseURL:     strings.TrimSuffix(opt.BaseURL, "/"),
+		canonical:   opt.Canonical,
+		siteName:    opt.SiteName,
+		language:    opt.Language,
+		defaultType: opt.DefaultType,
+		types:       opt.Types,
+		breadcrumbs: opt.Breadcrumbs,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type JSONLDOpts struct {
+	// Base URL of the site, used to create absolute URLs of the pages.
+	BaseURL string
+	// Canonical form of the URLs of the pages, see the canonical package. By
+	// default URLs are not changed.
+	Canonical canonical.Policy
+	// Name of the site, used as the publisher and the root of breadcrumbs.
+	SiteName string
+	// Language of the content, as a BCP 47 tag.
+	Language string
+
+	// The schema.org type used for files. Defaults to "BlogPosting".
+	DefaultType string
+	// Maps content types (the "type" metadata of files) to schema.org types,
+	// for example "page" to "WebPage" and "note" to "SocialMediaPosting".
+	// Content types with a empty string value don't have structured data injected.
+	Types map[string]string
+
+	// Also inject a BreadcrumbList, from the trail of sections set by the
+	// frontmatter plugin, or generated from the path of the file.
+	Breadcrumbs bool
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type jsonLD struct {
+	baseURL     string
+	canonical   canonical.Policy
+	siteName    string
+	language    string
+	defaultType string
+	types       map[string]string
+	breadcrumbs bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (r *jsonLD) Name() string {
+	return jsonLDName
+}
+
+func (r *jsonLD) Render(src fs.File, w io.Writer) error {
+	r.assert.NotNil(src)
+	r.assert.NotNil(w)
+	r.assert.NotNil(r.types)
+	r.assert.NotNil(r.log)
+
+	if _, ok := src.(fs.ReadDirFile); ok {
+		return errors.New("does not support directories")
+	}
+
+	contents, err := io.ReadAll(src)
+	if err != nil {
+		return errors.Join(errors.New("failed to read file contents"), err)
+	}
+
+	m := getMetadataOrEmpty(src)
+
+	schemaType := r.defaultType
+	if t, err := metadata.GetTyped[string](m, "type"); err == nil {
+		if st, ok := r.types[t]; ok {
+			schemaType = st
+		}
+	}
+
+	if schemaType == "" {
+		_, err := w.Write(contents)
+		return err
+	}
+
+	var scripts bytes.Buffer
+
+	if err := writeJSONLD(&scripts, r.document(schemaType, m)); err != nil {
+		return err
+	}
+	if p, err := metadata.GetTyped[string](m, jsonLDPathKey); err == nil && r.breadcrumbs {
+		if err := writeJSONLD(&scripts, r.breadcrumbList(p, m)); err != nil {
+			return err
+		}
+	}
+
+	i := indexFold(contents, []byte("</head>"))
+	if i == -1 {
+		i = 0
+	}
+
+	for _, b := range [][]byte{contents[:i], scripts.Bytes(), contents[i:]} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *jsonLD) document(schemaType string, m metadata.Metadata) map[string]any {
+	doc := map[string]any{
+		"@context": "https://schema.org",
+		"@type":    schemaType,
+	}
+
+	if v, err := metadata.GetTyped[string](m, "title"); err == nil {
+		doc["headline"] = v
+	}
+	if v, err := metadata.GetTyped[string](m, "summary"); err == nil {
+		doc["description"] = v
+	}
+	if v, err := metadata.GetTime(m, "date"); err == nil {
+		doc["datePublished"] = v.Format(time.RFC3339)
+	}
+	if v, err := metadata.GetTime(m, "updated"); err == nil {
+		doc["dateModified"] = v.Format(time.RFC3339)
+	}
+	if v, err := metadata.GetTyped[string](m, "author"); err == nil {
+		doc["author"] = map[string]any{"@type": "Person", "name": v}
+	}
+	if v, err := metadata.GetTyped[string](m, "image"); err == nil {
+		doc["image"] = r.url(v)
+	}
+	if v, err := metadata.GetTyped[[]any](m, "tags"); err == nil {
+		tags := make([]string, len(v))
+		for i, t := range v {
+			tags[i] = fmt.Sprint(t)
+		}
+		doc["keywords"] = strings.Join(tags, ", ")
+	}
+	if p, err := metadata.GetTyped[string](m, jsonLDPathKey); err == nil {
+		doc["url"] = r.canonical.URL(r.baseURL, strings.TrimSuffix(p, path.Ext(p)))
+		doc["mainEntityOfPage"] = doc["url"]
+	}
+	if r.language != "" {
+		doc["inLanguage"] = r.language
+	}
+	if r.siteName != "" {
+		doc["publisher"] = map[string]any{"@type": "Organization", "name": r.siteName}
+	}
+
+	return doc
+}
+
+func (r *jsonLD) breadcrumbList(p string, m metadata.Metadata) map[string]any {
+	items := []map[string]any{}
+
+	add := func(name, u string) {
+		items = append(items, map[string]any{
+			"@type":    "ListItem",
+			"position": len(items) + 1,
+			"name":     name,
+			"item":     r.url(u),
+		})
+	}
+
+	root := r.siteName
+	if root == "" {
+		root = "Home"
+	}
+	add(root, "/")
+
+	if trail, err := metadata.GetTyped[[]frontmatter.Breadcrumb](m, frontmatter.BreadcrumbsKey); err == nil {
+		for _, b := range trail {
+			add(b.Name, b.URL)
+		}
+	} else {
+		segments := strings.Split(strings.TrimSuffix(p, path.Ext(p)), "/")
+		for i, s := range segments {
+			u := "/" + strings.Join(segments[:i+1], "/")
+			if i == len(segments)-1 {
+				if t, err := metadata.GetTyped[string](m, "title"); err == nil {
+					s = t
+				}
+			}
+			add(s, u)
+		}
+	}
+
+	return map[string]any{
+		"@context":        "https://schema.org",
+		"@type":           "BreadcrumbList",
+		"itemListElement": items,
+	}
+}
+
+func (r *jsonLD) url(p string) string {
+	if u, err := url.Parse(p); err == nil && u.IsAbs() {
+		return p
+	}
+	return r.baseURL + "/" + strings.TrimPrefix(p, "/")
+}
+
+func writeJSONLD(w io.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.Join(errors.New("failed to marshal JSON-LD"), err)
+	}
+
+	// json.Marshal already escapes "<", ">" and "&", so the contents can't close
+	// the script element.
+	_, err = fmt.Fprintf(w, `<script type="application/ld+json">%s</script>`+"\n", b)
+	return err
+}
@@ -0,0 +1,167 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const layoutRendererName = "blogo-layout-renderer"
+
+// Creates a [plugin.Renderer] that transforms already rendered HTML, wrapping it in
+// the layout template named by the "layout" metadata of the file, such as the ones
+// set by content types (see the contenttype package). Templates are executed with
+// a [LayoutRendererInfo] value.
+//
+// Files without a layout, or with a layout that isn't on the map, use the default
+// layout or are written untouched if there isn't one.
+//
+// This renderer is intended to be used after other renderers in a [FoldingRenderer].
+func NewLayoutRenderer(
+	layouts map[string]*template.Template,
+	opts ...LayoutRendererOpts,
+) plugin.Renderer {
+	opt := LayoutRendererOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &layoutRenderer{
+		layouts: layouts,
+		def:     opt.Default,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type LayoutRendererOpts struct {
+	// Name of the layout used by files without one.
+	Default string
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+// Information passed to the layouts of [NewLayoutRenderer].
+type LayoutRendererInfo struct {
+	Name     string
+	Layout   string
+	Content  template.HTML
+	Metadata metadata.Metadata
+}
+
+type layoutRenderer struct {
+	layouts map[string]*template.Template
+	def     string
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (r *layoutRenderer) Name() string {
+	return layoutRendererName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (r *layoutRenderer) SetLogger(logger *slog.Logger) {
+	if r.injectLogger {
+		r.log = logger
+	}
+}
+
+func (r *layoutRenderer) Render(src fs.File, w io.Writer) error {
+	return r.RenderContext(context.Background(), src, w)
+}
+
+func (r *layoutRenderer) RenderContext(ctx context.Context, src fs.File, w io.Writer) error {
+	r.assert.NotNil(src)
+	r.assert.NotNil(w)
+	r.assert.NotNil(r.layouts)
+	r.assert.NotNil(r.log)
+
+	if _, ok := src.(fs.ReadDirFile); ok {
+		return errors.New("does not support directories")
+	}
+
+	stat, err := src.Stat()
+	if err != nil {
+		return errors.Join(errors.New("failed to stat file"), err)
+	}
+
+	contents, err := io.ReadAll(src)
+	if err != nil {
+		return errors.Join(errors.New("failed to read file contents"), err)
+	}
+
+	m := getMetadataOrEmpty(src)
+
+	name, err := metadata.GetTyped[string](m, "layout")
+	if err != nil || name == "" {
+		name = r.def
+	}
+
+	log := r.log.With(slog.String("file", stat.Name()), slog.String("layout", name))
+
+	layout, ok := r.layouts[name]
+	if !ok {
+		layout, ok = r.layouts[r.def]
+	}
+	if !ok || layout == nil {
+		log.Debug("Layout not found, writing file untouched")
+		_, err := w.Write(contents)
+		return err
+	}
+
+	log.Debug("Wrapping file in layout")
+
+	core.AddDependency(ctx, core.TemplateDependency(name))
+
+	err = layout.Execute(w, LayoutRendererInfo{
+		Name:     stat.Name(),
+		Layout:   name,
+		Content:  template.HTML(contents),
+		Metadata: m,
+	})
+	if err != nil {
+		return errors.Join(errors.New("failed to execute layout template"), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,270 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"slices"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/visibility"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const listingRendererName = "blogo-listing-renderer"
+
+// Creates a [plugin.Renderer] that renders directories using the provided template,
+// returning a error for any file that isn't a directory, so it can be used alongside
+// other renderers in a [MultiRenderer].
+//
+// The template is executed with a [ListingRendererInfo] value. Entries that have
+// "pinned: true" or "featured: true" on their metadata (see the frontmatter plugin)
+// are placed first on the listing, and are also exposed on their own collections.
+// Entries that aren't visible on [visibility.Listing] are omitted.
+func NewListingRenderer(
+	templt template.Template,
+	opts ...ListingRendererOpts,
+) plugin.Renderer {
+	opt := ListingRendererOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Visibility == nil {
+		opt.Visibility = visibility.Default
+	}
+	if opt.Prefetch == 0 {
+		opt.Prefetch = 8
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &listingRenderer{
+		templt:     templt,
+		visibility: opt.Visibility,
+		prefetch:   opt.Prefetch,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type ListingRendererOpts struct {
+	// Rules used to hide entries from the listing. Defaults to [visibility.Default].
+	Visibility visibility.Rules
+	// Number of entries whose metadata is loaded concurrently before the listing
+	// is rendered, see [metadata.Prefetch]. Defaults to 8, negative values load
+	// the metadata of each entry only when it's used.
+	Prefetch int
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+// Information passed to the template of [NewListingRenderer].
+type ListingRendererInfo struct {
+	Name string
+
+	// All entries of the directory, with pinned entries first, followed by
+	// featured entries and then the rest in the order returned by the file system.
+	Entries []ListingEntry
+
+	// Entries that have "pinned: true" on their metadata.
+	Pinned []ListingEntry
+	// Entries that have "featured: true" on their metadata.
+	Featured []ListingEntry
+
+	Metadata metadata.Metadata
+}
+
+// A entry of a directory listing.
+type ListingEntry struct {
+	Name  string
+	IsDir bool
+
+	Pinned   bool
+	Featured bool
+
+	// Summary of the entry, taken from the "summary" value of it's metadata.
+	Summary string
+
+	// Metadata of the entry, if the file system provides it, otherwise a empty [metadata.Map].
+	Metadata metadata.Metadata
+}
+
+type listingRenderer struct {
+	templt     template.Template
+	visibility visibility.Rules
+	prefetch   int
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (r *listingRenderer) Name() string {
+	return listingRendererName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (r *listingRenderer) SetLogger(logger *slog.Logger) {
+	if r.injectLogger {
+		r.log = logger
+	}
+}
+
+func (r *listingRenderer) Render(src fs.File, w io.Writer) error {
+	return r.RenderContext(context.Background(), src, w)
+}
+
+func (r *listingRenderer) RenderContext(ctx context.Context, src fs.File, w io.Writer) error {
+	r.assert.NotNil(src)
+	r.assert.NotNil(w)
+	r.assert.NotNil(r.visibility)
+	r.assert.NotNil(r.log)
+
+	d, ok := src.(fs.ReadDirFile)
+	if !ok {
+		return errors.New("does not support file, listing renderer only renders directories")
+	}
+
+	stat, err := d.Stat()
+	if err != nil {
+		return errors.Join(errors.New("failed to stat directory"), err)
+	}
+
+	log := r.log.With(slog.String("directory", stat.Name()))
+	log.Debug("Rendering directory listing")
+
+	es, err := d.ReadDir(-1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return errors.Join(errors.New("failed to read directory entries"), err)
+	}
+
+	if r.prefetch > 0 {
+		metadata.Prefetch(ctx, es, r.prefetch)
+	}
+
+	info := ListingRendererInfo{
+		Name:     stat.Name(),
+		Entries:  make([]ListingEntry, 0, len(es)),
+		Pinned:   []ListingEntry{},
+		Featured: []ListingEntry{},
+		Metadata: metadata.Map(map[string]any{}),
+	}
+	if m, err := metadata.GetMetadata(src); err == nil {
+		info.Metadata = m
+	}
+
+	for _, e := range es {
+		entry := newListingEntry(e)
+
+		if !r.visibility.Visible(entryPath(e), entry.Metadata, visibility.Listing) {
+			log.Debug("Entry not visible on listings, skipping it", slog.String("entry", e.Name()))
+			continue
+		}
+
+		// The listing shows the metadata of the entry, so it needs to be rendered
+		// again when the entry changes.
+		core.AddDependency(ctx, entryPath(e))
+
+		if entry.Pinned {
+			info.Pinned = append(info.Pinned, entry)
+		}
+		if entry.Featured {
+			info.Featured = append(info.Featured, entry)
+		}
+
+		info.Entries = append(info.Entries, entry)
+	}
+
+	slices.SortStableFunc(info.Entries, func(a, b ListingEntry) int {
+		return listingEntryWeight(a) - listingEntryWeight(b)
+	})
+
+	core.AddDependency(ctx, core.TemplateDependency(r.templt.Name()))
+
+	if err := r.templt.Execute(w, info); err != nil {
+		log.Error("Failed to execute listing template", slog.String("err", err.Error()))
+		return errors.Join(errors.New("failed to execute listing template"), err)
+	}
+
+	return nil
+}
+
+func newListingEntry(e fs.DirEntry) ListingEntry {
+	entry := ListingEntry{
+		Name:     e.Name(),
+		IsDir:    e.IsDir(),
+		Metadata: metadata.Map(map[string]any{}),
+	}
+
+	m, err := metadata.GetMetadata(e)
+	if err != nil {
+		return entry
+	}
+
+	entry.Metadata = m
+
+	if pinned, err := metadata.GetTyped[bool](m, "pinned"); err == nil {
+		entry.Pinned = pinned
+	}
+	if featured, err := metadata.GetTyped[bool](m, "featured"); err == nil {
+		entry.Featured = featured
+	}
+	if summary, err := metadata.GetTyped[string](m, "summary"); err == nil {
+		entry.Summary = summary
+	}
+
+	return entry
+}
+
+// Returns the path of the entry on the file system, if it provides one (such
+// as the entries of the frontmatter plugin), otherwise just it's name.
+func entryPath(e fs.DirEntry) string {
+	if p, ok := e.(interface{ Path() string }); ok {
+		return p.Path()
+	}
+	return e.Name()
+}
+
+func listingEntryWeight(e ListingEntry) int {
+	switch {
+	case e.Pinned:
+		return 0
+	case e.Featured:
+		return 1
+	default:
+		return 2
+	}
+}
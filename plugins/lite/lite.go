@@ -0,0 +1,503 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lite provides a ultra-lightweight version of every page, for slow
+// networks and terminal browsers, requested with "?lite=1" or under the "/lite/"
+// tree, such as "/lite/posts/hello".
+//
+// Lite pages are rendered by the same pipeline, with the "lite" layout (see
+// [DefaultLayout]) set on the metadata of files, so the layout renderer wraps
+// them in a minimal document with inline CSS. The rendered pages are then
+// stripped of scripts, external stylesheets and embedded frames, and their
+// images are replaced by smaller versions served by the images plugin.
+package lite
+
+import (
+	"bytes"
+	"context"
+	"html"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/plugins/images"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-lite-sourcer"
+
+// Layout of lite pages, to be added to the layouts of the layout renderer with
+// the name of Opts.Layout. Executed with the same values as other layouts, it
+// has the content of the page and it's title, from the "title" metadata.
+var DefaultLayout = template.Must(template.New("lite").Funcs(template.FuncMap{
+	"title": func(m metadata.Metadata, name string) string {
+		if t, err := metadata.GetTyped[string](m, "title"); err == nil && t != "" {
+			return t
+		}
+		return name
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{title .Metadata .Name}}</title>
+<style>
+body { max-width: 40em; margin: 0 auto; padding: 0 1em; font: 1rem/1.5 sans-serif; }
+img, video { max-width: 100%; height: auto; }
+pre { overflow-x: auto; }
+</style>
+</head>
+<body>
+<main>
+{{.Content}}
+</main>
+</body>
+</html>
+`))
+
+// The lite plugin, which wraps a [plugin.Sourcer] to keep it's last file system
+// and is a [plugin.Middleware] that serves the lite version of pages.
+type Plugin interface {
+	plugin.Sourcer
+	plugin.Middleware
+}
+
+// Creates the lite [Plugin], wrapping the sourcer. Lite pages are cached
+// separately (see [core.WithCacheVariant]), and link to the lite version of the
+// other pages of the blog, with a canonical link to the original version.
+func New(sourcer plugin.Sourcer, opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.QueryParam == "" {
+		opt.QueryParam = "lite"
+	}
+	if opt.Prefix == "" {
+		opt.Prefix = "/lite"
+	}
+	if opt.Layout == "" {
+		opt.Layout = "lite"
+	}
+	if opt.ImageWidth == 0 {
+		opt.ImageWidth = 640
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be wrapped should not be nil")
+
+	return &p{
+		sourcer: sourcer,
+
+		queryParam: opt.QueryParam,
+		prefix:     "/" + strings.Trim(opt.Prefix, "/"),
+		layout:     opt.Layout,
+		imageWidth: opt.ImageWidth,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Query parameter that requests the lite version of a page, when "1".
+	// Defaults to "lite".
+	QueryParam string
+	// Path of the tree of lite pages. Defaults to "/lite".
+	Prefix string
+
+	// Name of the layout of lite pages. Defaults to "lite". If the layout renderer
+	// doesn't have the layout, pages use the default one and are only stripped.
+	Layout string
+
+	// Width of the images of lite pages, which only replace the local images if
+	// the images plugin is used and allows the width. Defaults to 640, negative
+	// values keep the original images.
+	ImageWidth int
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	sourcer plugin.Sourcer
+
+	queryParam string
+	prefix     string
+	layout     string
+	imageWidth int
+
+	mu   sync.RWMutex
+	fsys fs.FS
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Source() (fs.FS, error) {
+	p.assert.NotNil(p.sourcer)
+
+	fsys, err := p.sourcer.Source()
+	if err != nil {
+		return fsys, err
+	}
+
+	p.mu.Lock()
+	p.fsys = fsys
+	p.mu.Unlock()
+
+	return fsys, nil
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.log)
+
+		prefixed := r.URL.Path == p.prefix || strings.HasPrefix(r.URL.Path, p.prefix+"/")
+		if !prefixed && r.URL.Query().Get(p.queryParam) != "1" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p.mu.RLock()
+		fsys := p.fsys
+		p.mu.RUnlock()
+
+		if fsys == nil || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		lr := r.Clone(r.Context())
+		if prefixed {
+			lr.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, p.prefix), "/")
+			lr.URL.RawPath = ""
+		}
+
+		ctx := core.WithFiles(lr.Context(), &liteFS{FS: fsys, layout: p.layout})
+		ctx = core.WithCacheVariant(ctx, cacheVariant(ctx))
+		lr = lr.WithContext(ctx)
+
+		p.log.Debug("Serving lite page", slog.String("path", lr.URL.Path))
+
+		w.Header().Add("Link", "<"+lr.URL.Path+`>; rel="canonical"`)
+
+		lw := &liteWriter{ResponseWriter: w}
+		next.ServeHTTP(lw, lr)
+
+		if err := lw.flush(p.strip(lw.buf.Bytes(), prefixed)); err != nil {
+			p.log.Error("Failed to write lite page", slog.String("err", err.Error()))
+		}
+	})
+}
+
+// Returns the cache variant of lite pages, keeping the variant already set on
+// the context, if any, such as by the variant plugin.
+func cacheVariant(ctx context.Context) string {
+	if v, ok := core.CacheVariantFromContext(ctx); ok {
+		return v + "&lite"
+	}
+	return "lite"
+}
+
+var (
+	scriptElement = regexp.MustCompile(`(?is)<script\b.*?</script\s*>`)
+	frameElement  = regexp.MustCompile(`(?is)<iframe\b([^>]*)>.*?</iframe\s*>`)
+	startTag      = regexp.MustCompile(`(?s)<([a-zA-Z][a-zA-Z0-9-]*)\b([^>]*)>`)
+	tagAttr       = regexp.MustCompile(`(?s)\s+([a-zA-Z_:][a-zA-Z0-9_:.-]*)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+))?`)
+)
+
+// Removes the scripts, external stylesheets, frames and event handlers of the
+// page, replacing local images with smaller versions and links with the ones of
+// lite pages.
+func (p *p) strip(page []byte, prefixed bool) []byte {
+	page = scriptElement.ReplaceAll(page, nil)
+
+	page = frameElement.ReplaceAllFunc(page, func(el []byte) []byte {
+		attrs := parseAttrs(frameElement.FindSubmatch(el)[1])
+		src := attrs.get("src")
+		if src == "" {
+			return nil
+		}
+		s := html.EscapeString(src)
+		return []byte(`<a href="` + s + `">` + s + `</a>`)
+	})
+
+	return startTag.ReplaceAllFunc(page, func(tag []byte) []byte {
+		m := startTag.FindSubmatch(tag)
+		name := strings.ToLower(string(m[1]))
+		attrs := parseAttrs(m[2])
+
+		switch name {
+		case "link":
+			switch rel := strings.ToLower(attrs.get("rel")); {
+			case strings.Contains(rel, "stylesheet"),
+				strings.Contains(rel, "preload"),
+				strings.Contains(rel, "prefetch"):
+				return nil
+			}
+		case "img":
+			if src := attrs.get("src"); p.imageWidth > 0 && local(src) && !strings.Contains(src, "?") {
+				attrs.set("src", images.URL(src, p.imageWidth))
+				attrs.del("srcset")
+				attrs.del("sizes")
+			}
+			if attrs.get("loading") == "" {
+				attrs.set("loading", "lazy")
+			}
+		case "a":
+			if href := attrs.get("href"); strings.HasPrefix(href, "/") && !strings.HasPrefix(href, "//") {
+				attrs.set("href", p.link(href, prefixed))
+			}
+		}
+
+		attrs = slices.DeleteFunc(attrs, func(a attr) bool {
+			return strings.HasPrefix(strings.ToLower(a.name), "on")
+		})
+
+		return []byte("<" + string(m[1]) + attrs.String() + ">")
+	})
+}
+
+// Returns the link to the lite version of the page at the path.
+func (p *p) link(href string, prefixed bool) string {
+	if prefixed {
+		return p.prefix + href
+	}
+
+	u, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	q := u.Query()
+	q.Set(p.queryParam, "1")
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Reports if the URL is of a file of the blog.
+func local(src string) bool {
+	return src != "" && !strings.Contains(src, "://") &&
+		!strings.HasPrefix(src, "//") && !strings.HasPrefix(src, "data:")
+}
+
+type attr struct {
+	name  string
+	value string
+	empty bool
+}
+
+type attrs []attr
+
+func parseAttrs(src []byte) attrs {
+	as := attrs{}
+	for _, m := range tagAttr.FindAllSubmatch(src, -1) {
+		a := attr{name: string(m[1]), empty: len(m[2]) == 0}
+		if !a.empty {
+			a.value = html.UnescapeString(strings.Trim(string(m[2]), `"'`))
+		}
+		as = append(as, a)
+	}
+	return as
+}
+
+func (as attrs) get(name string) string {
+	for _, a := range as {
+		if strings.EqualFold(a.name, name) {
+			return a.value
+		}
+	}
+	return ""
+}
+
+func (as *attrs) set(name, value string) {
+	for i, a := range *as {
+		if strings.EqualFold(a.name, name) {
+			(*as)[i].value, (*as)[i].empty = value, false
+			return
+		}
+	}
+	*as = append(*as, attr{name: name, value: value})
+}
+
+func (as *attrs) del(name string) {
+	for i, a := range *as {
+		if strings.EqualFold(a.name, name) {
+			*as = append((*as)[:i], (*as)[i+1:]...)
+			return
+		}
+	}
+}
+
+func (as attrs) String() string {
+	var b strings.Builder
+	for _, a := range as {
+		b.WriteString(" " + a.name)
+		if !a.empty {
+			b.WriteString(`="` + html.EscapeString(a.value) + `"`)
+		}
+	}
+	return b.String()
+}
+
+// Buffers successful HTML responses, to strip them before writing. Other
+// responses are written directly.
+type liteWriter struct {
+	http.ResponseWriter
+	status int
+	html   bool
+	buf    bytes.Buffer
+	header bool
+}
+
+func (w *liteWriter) WriteHeader(status int) {
+	if w.header || status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.header, w.status = true, status
+
+	w.html = status == http.StatusOK &&
+		strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") &&
+		w.Header().Get("Content-Encoding") == ""
+	if !w.html {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *liteWriter) Write(b []byte) (int, error) {
+	if !w.header {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.html {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+func (w *liteWriter) flush(page []byte) error {
+	if !w.html {
+		return nil
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(page)))
+	w.ResponseWriter.WriteHeader(w.status)
+
+	_, err := w.ResponseWriter.Write(page)
+	return err
+}
+
+// File system that sets the layout of lite pages on the metadata of files.
+type liteFS struct {
+	fs.FS
+	layout string
+}
+
+func (fsys *liteFS) Metadata() metadata.Metadata {
+	if m, err := metadata.GetMetadata(fsys.FS); err == nil {
+		return m
+	}
+	return metadata.Map(map[string]any{})
+}
+
+func (fsys *liteFS) Open(name string) (fs.File, error) {
+	f, err := fsys.FS.Open(name)
+	if err != nil {
+		return f, err
+	}
+
+	var fm metadata.Metadata = metadata.Map(map[string]any{})
+	if m, err := metadata.GetMetadata(f); err == nil {
+		fm = m
+	}
+
+	m := metadata.Join(metadata.Map(map[string]any{"layout": fsys.layout}), fm)
+
+	if d, ok := f.(fs.ReadDirFile); ok {
+		return &dirFile{ReadDirFile: d, metadata: m}, nil
+	}
+	if _, ok := f.(io.Seeker); ok {
+		return &seekerFile{file{File: f, metadata: m}}, nil
+	}
+	return &file{File: f, metadata: m}, nil
+}
+
+type file struct {
+	fs.File
+	metadata metadata.Metadata
+}
+
+func (f *file) Metadata() metadata.Metadata {
+	return f.metadata
+}
+
+// Keeps files that implement [io.Seeker] seekable, so renderers can read them
+// more than once.
+type seekerFile struct {
+	file
+}
+
+func (f *seekerFile) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+// Keeps directories readable, so listings also have the lite layout.
+type dirFile struct {
+	fs.ReadDirFile
+	metadata metadata.Metadata
+}
+
+func (f *dirFile) Metadata() metadata.Metadata {
+	return f.metadata
+}
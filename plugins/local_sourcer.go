@@ -0,0 +1,120 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+)
+
+const localSourcerName = "blogo-local-sourcer"
+
+// Creates a [plugin.WritableSourcer] of the files of a directory on the local
+// file system. Files are written atomically, by writing to a temporary file and
+// renaming it, so the file is never partially written when sourced.
+func NewLocalSourcer(dir string) plugin.WritableSourcer {
+	return &localSourcer{dir: dir}
+}
+
+type localSourcer struct {
+	dir string
+}
+
+func (s *localSourcer) Name() string {
+	return localSourcerName
+}
+
+func (s *localSourcer) Source() (fs.FS, error) {
+	return os.DirFS(s.dir), nil
+}
+
+func (s *localSourcer) Create(ctx context.Context, name string, data []byte) error {
+	p, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(p); err == nil {
+		return &fs.PathError{Op: "create", Path: name, Err: fs.ErrExist}
+	}
+	return s.write(ctx, p, data)
+}
+
+func (s *localSourcer) Update(ctx context.Context, name string, data []byte) error {
+	p, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(p); errors.Is(err, fs.ErrNotExist) {
+		return &fs.PathError{Op: "update", Path: name, Err: fs.ErrNotExist}
+	}
+	return s.write(ctx, p, data)
+}
+
+func (s *localSourcer) Delete(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
+
+func (s *localSourcer) path(name string) (string, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return "", &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(name)), nil
+}
+
+func (s *localSourcer) write(ctx context.Context, p string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return errors.Join(errors.New("failed to create directory"), err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".*.tmp")
+	if err != nil {
+		return errors.Join(errors.New("failed to create temporary file"), err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return errors.Join(errors.New("failed to write temporary file"), err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Join(errors.New("failed to close temporary file"), err)
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return errors.Join(errors.New("failed to change mode of temporary file"), err)
+	}
+
+	if err := os.Rename(f.Name(), p); err != nil {
+		return errors.Join(errors.New("failed to rename temporary file"), err)
+	}
+
+	return nil
+}
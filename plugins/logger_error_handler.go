@@ -16,9 +16,11 @@
 package plugins
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 
+	"forge.capytal.company/loreddev/blogo/core"
 	"forge.capytal.company/loreddev/blogo/plugin"
 )
 
@@ -61,6 +63,18 @@ func (h *loggerErrorHandler) log(msg string, args ...any) {
 }
 
 func (h *loggerErrorHandler) Handle(err error) (recovr any, handled bool) {
-	h.log("BLOGO ERROR", slog.String("err", err.Error()))
+	args := []any{slog.String("err", err.Error())}
+
+	var serr core.ServeError
+	if errors.As(err, &serr) {
+		args = append(args,
+			slog.String("path", serr.Req.URL.Path),
+			slog.String("filename", serr.Path),
+			slog.String("stage", string(serr.Stage)),
+			slog.Duration("elapsed", serr.Elapsed),
+		)
+	}
+
+	h.log("BLOGO ERROR", args...)
 	return nil, true
 }
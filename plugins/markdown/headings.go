@@ -0,0 +1,123 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package markdown
+
+import (
+	"fmt"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+
+	"forge.capytal.company/loreddev/blogo/slug"
+)
+
+// Implements [parser.IDs] using a [slug.Slugifier], making the generated heading
+// ids unique inside the document by appending a counter.
+type headingIDs struct {
+	slugifier slug.Slugifier
+	values    map[string]bool
+}
+
+func newHeadingIDs(s slug.Slugifier) *headingIDs {
+	return &headingIDs{slugifier: s, values: map[string]bool{}}
+}
+
+func (ids *headingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	id := ids.slugifier.Slugify(string(value))
+	if id == "" {
+		id = "heading"
+		if kind != ast.KindHeading {
+			id = "id"
+		}
+	}
+
+	if !ids.values[id] {
+		ids.values[id] = true
+		return []byte(id)
+	}
+
+	for i := 1; ; i++ {
+		n := fmt.Sprintf("%s-%d", id, i)
+		if !ids.values[n] {
+			ids.values[n] = true
+			return []byte(n)
+		}
+	}
+}
+
+func (ids *headingIDs) Put(value []byte) {
+	ids.values[string(value)] = true
+}
+
+// Adds a permalink anchor to every heading that has a id.
+type headingAnchors struct {
+	text     string
+	class    string
+	position AnchorPosition
+}
+
+func (t *headingAnchors) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		h, ok := n.(*ast.Heading)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+
+		id, ok := h.AttributeString("id")
+		if !ok {
+			return ast.WalkSkipChildren, nil
+		}
+
+		var idstr string
+		switch id := id.(type) {
+		case []byte:
+			idstr = string(id)
+		case string:
+			idstr = id
+		default:
+			return ast.WalkSkipChildren, nil
+		}
+
+		link := ast.NewLink()
+		link.Destination = []byte("#" + idstr)
+		link.SetAttributeString("class", []byte(t.class))
+
+		icon := ast.NewString([]byte(t.text))
+		icon.SetCode(true)
+		link.AppendChild(link, icon)
+
+		if t.position == AnchorBefore && h.FirstChild() != nil {
+			h.InsertBefore(h, h.FirstChild(), link)
+		} else {
+			h.AppendChild(h, link)
+		}
+
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// Position of the permalink anchor inside headings.
+type AnchorPosition int
+
+const (
+	AnchorAfter AnchorPosition = iota
+	AnchorBefore
+)
@@ -12,8 +12,10 @@ import (
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer"
 	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
 
 	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/slug"
 )
 
 const pluginName = "blogo-markdown-renderer"
@@ -21,19 +23,132 @@ const pluginName = "blogo-markdown-renderer"
 type p struct {
 	parser   parser.Parser
 	renderer renderer.Renderer
+
+	headingIDs bool
+	slugifier  slug.Slugifier
+}
+
+// Options used to configure which markdown extensions are enabled on the renderer.
+// By default, only autolinks and frontmatter parsing are enabled.
+type Opts struct {
+	// Enables footnotes, see https://michelf.ca/projects/php-markdown/extra/#footnotes.
+	Footnotes bool
+	// Renders footnotes as sidenotes on the margin of the content, instead of a
+	// list at the end of the document, unless the frontmatter of the post sets
+	// "sidenotes: false". Posts can also enable them with "sidenotes: true" if
+	// Footnotes is enabled. Implies Footnotes. See [SidenotesStyle].
+	Sidenotes bool
+	// Enables definition lists, see https://michelf.ca/projects/php-markdown/extra/#def-list.
+	DefinitionLists bool
+	// Enables GFM task lists ("- [x] item").
+	TaskLists bool
+	// Enables GFM tables.
+	Tables bool
+	// Enables GFM strikethrough ("~~text~~").
+	Strikethrough bool
+
+	// Disables the conversion of bare URLs to links.
+	DisableAutolinks bool
+
+	// Adds "id" attributes to headings, generated from their text using Slugifier.
+	HeadingIDs bool
+	// Adds permalink anchors to headings. Implies HeadingIDs.
+	HeadingAnchors bool
+	// Contents of the permalink anchors, written as raw HTML so it can be a icon.
+	// Defaults to "#".
+	AnchorText string
+	// Class attribute of the permalink anchors. Defaults to "anchor".
+	AnchorClass string
+	// Where the anchor is placed inside the heading. Defaults to [AnchorAfter].
+	AnchorPosition AnchorPosition
+	// Strategy used to create heading ids. Defaults to [slug.Default].
+	Slugifier slug.Slugifier
+
+	// Additional goldmark extensions to be used by the renderer.
+	Extensions []goldmark.Extender
 }
 
-func New() plugin.Plugin {
+func New(opts ...Opts) plugin.Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	extensions := []goldmark.Extender{meta.Meta}
+
+	if !opt.DisableAutolinks {
+		extensions = append(extensions, extension.NewLinkify())
+	}
+	if opt.Sidenotes {
+		opt.Footnotes = true
+	}
+	if opt.Footnotes {
+		extensions = append(extensions, extension.Footnote)
+	}
+	if opt.DefinitionLists {
+		extensions = append(extensions, extension.DefinitionList)
+	}
+	if opt.TaskLists {
+		extensions = append(extensions, extension.TaskList)
+	}
+	if opt.Tables {
+		extensions = append(extensions, extension.Table)
+	}
+	if opt.Strikethrough {
+		extensions = append(extensions, extension.Strikethrough)
+	}
+
+	extensions = append(extensions, opt.Extensions...)
+
+	if opt.HeadingAnchors {
+		opt.HeadingIDs = true
+	}
+	if opt.AnchorText == "" {
+		opt.AnchorText = "#"
+	}
+	if opt.AnchorClass == "" {
+		opt.AnchorClass = "anchor"
+	}
+	if opt.Slugifier == nil {
+		opt.Slugifier = slug.Default
+	}
+
+	parserOpts := []parser.Option{}
+	if opt.HeadingIDs {
+		parserOpts = append(parserOpts, parser.WithAutoHeadingID())
+	}
+	if opt.HeadingAnchors {
+		parserOpts = append(parserOpts, parser.WithASTTransformers(
+			util.Prioritized(&headingAnchors{
+				text:     opt.AnchorText,
+				class:    opt.AnchorClass,
+				position: opt.AnchorPosition,
+			}, 500),
+		))
+	}
+
+	rendererOpts := []renderer.Option{}
+	if opt.Footnotes {
+		parserOpts = append(parserOpts, parser.WithASTTransformers(
+			util.Prioritized(&sidenotes{enabled: opt.Sidenotes}, 1000),
+		))
+		rendererOpts = append(rendererOpts, renderer.WithNodeRenderers(
+			util.Prioritized(&sidenoteRenderer{}, 500),
+		))
+	}
+
 	m := goldmark.New(
-		goldmark.WithExtensions(
-			extension.NewLinkify(),
-			meta.Meta,
-		),
+		goldmark.WithExtensions(extensions...),
+		goldmark.WithParserOptions(parserOpts...),
+		goldmark.WithRendererOptions(rendererOpts...),
 	)
 
 	return &p{
 		parser:   m.Parser(),
 		renderer: m.Renderer(),
+
+		headingIDs: opt.HeadingIDs,
+		slugifier:  opt.Slugifier,
 	}
 }
 
@@ -54,7 +169,12 @@ func (p *p) Render(f fs.File, w io.Writer) error {
 
 	txt := text.NewReader(src)
 
-	ast := p.parser.Parse(txt)
+	ctx := parser.NewContext()
+	if p.headingIDs {
+		ctx = parser.NewContext(parser.WithIDs(newHeadingIDs(p.slugifier)))
+	}
+
+	ast := p.parser.Parse(txt, parser.WithContext(ctx))
 
 	return p.renderer.Render(w, src, ast)
 }
@@ -0,0 +1,165 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package markdown
+
+import (
+	"fmt"
+
+	meta "github.com/yuin/goldmark-meta"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Key of the frontmatter value that enables or disables sidenotes on a post,
+// overriding [Opts].Sidenotes.
+const SidenotesKey = "sidenotes"
+
+// Stylesheet for the sidenotes markup, placing them on the right margin of the
+// content and, on narrow screens, hiding them until their number is clicked.
+// It can be included on layouts as is or used as a starting point.
+const SidenotesStyle = `.sidenote { float: right; clear: right; width: 40%; margin-right: -50%; font-size: 0.85em; }
+.sidenote-number { cursor: pointer; vertical-align: super; font-size: 0.75em; }
+.sidenote-toggle { display: none; }
+@media (max-width: 760px) {
+  .sidenote { display: none; float: none; width: auto; margin: 0.5em 0; }
+  .sidenote-toggle:checked + .sidenote { display: block; }
+}
+`
+
+// Node of a sidenote, holding the inline contents of the footnote in the place
+// of it's reference.
+type sidenote struct {
+	ast.BaseInline
+	Index int
+}
+
+var kindSidenote = ast.NewNodeKind("Sidenote")
+
+func (n *sidenote) Kind() ast.NodeKind {
+	return kindSidenote
+}
+
+func (n *sidenote) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Index": fmt.Sprint(n.Index)}, nil)
+}
+
+// Moves the contents of footnotes to sidenotes in the place of their references.
+// Footnotes referenced more than once, or with contents other than paragraphs
+// (such as lists and code blocks), are kept on the list of footnotes.
+type sidenotes struct {
+	enabled bool
+}
+
+func (t *sidenotes) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	enabled := t.enabled
+	if v, ok := meta.Get(pc)[SidenotesKey].(bool); ok {
+		enabled = v
+	}
+	if !enabled {
+		return
+	}
+
+	var list *east.FootnoteList
+	for n := doc.LastChild(); n != nil; n = n.PreviousSibling() {
+		if l, ok := n.(*east.FootnoteList); ok {
+			list = l
+			break
+		}
+	}
+	if list == nil {
+		return
+	}
+
+	footnotes := map[int]*east.Footnote{}
+	for n := list.FirstChild(); n != nil; n = n.NextSibling() {
+		if f, ok := n.(*east.Footnote); ok && onlyParagraphs(f) {
+			footnotes[f.Index] = f
+		}
+	}
+
+	links := []*east.FootnoteLink{}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if n == list {
+			return ast.WalkSkipChildren, nil
+		}
+		if l, ok := n.(*east.FootnoteLink); ok && entering && l.RefCount <= 1 && footnotes[l.Index] != nil {
+			links = append(links, l)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, l := range links {
+		f := footnotes[l.Index]
+		note := &sidenote{Index: l.Index}
+
+		for p := f.FirstChild(); p != nil; p = p.NextSibling() {
+			if p != f.FirstChild() {
+				br := ast.NewString([]byte("<br>"))
+				br.SetCode(true)
+				note.AppendChild(note, br)
+			}
+			for c := p.FirstChild(); c != nil; {
+				next := c.NextSibling()
+				if _, ok := c.(*east.FootnoteBacklink); !ok {
+					note.AppendChild(note, c)
+				}
+				c = next
+			}
+		}
+
+		l.Parent().ReplaceChild(l.Parent(), l, note)
+		list.RemoveChild(list, f)
+	}
+
+	if list.ChildCount() == 0 {
+		doc.RemoveChild(doc, list)
+	}
+}
+
+func onlyParagraphs(f *east.Footnote) bool {
+	for n := f.FirstChild(); n != nil; n = n.NextSibling() {
+		if !ast.IsParagraph(n) {
+			return false
+		}
+	}
+	return f.HasChildren()
+}
+
+// Renders sidenotes as a numbered label and a checkbox that toggles the
+// visibility of the note on narrow screens, see [SidenotesStyle].
+type sidenoteRenderer struct{}
+
+func (r *sidenoteRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindSidenote, r.render)
+}
+
+func (r *sidenoteRenderer) render(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	note := n.(*sidenote)
+	if entering {
+		_, _ = fmt.Fprintf(w,
+			`<label for="sidenote-%[1]d" class="sidenote-number">%[1]d</label>`+
+				`<input type="checkbox" id="sidenote-%[1]d" class="sidenote-toggle">`+
+				`<span class="sidenote">`,
+			note.Index)
+	} else {
+		_, _ = w.WriteString(`</span>`)
+	}
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,424 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package members provides members-only content, gating the body of files with
+// "visibility: members" on their metadata behind the login of users.
+//
+// Visitors that aren't logged in see a teaser of gated files: the content before
+// the summary marker ("<!--more-->"), or their summary otherwise, with the
+// [GatedKey] metadata set so templates can show a prompt to log in. Since the
+// sourced file system only has the teasers, gated bodies are also never part of
+// feeds, search indexes or any other plugin that reads the files.
+//
+// Summaries computed by the frontmatter plugin are taken from the start of the
+// body, so gated files should have the marker or a "summary" field, otherwise
+// short posts are shown whole.
+package members
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"forge.capytal.company/loreddev/blogo/auth"
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/plugins/frontmatter"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-members-sourcer"
+
+const (
+	// Metadata key of the visibility of a file.
+	VisibilityKey = "visibility"
+	// Visibility of files that only members can read.
+	Members = "members"
+
+	// Metadata key set to true on the teasers of gated files.
+	GatedKey = "members.gated"
+
+	DefaultMarker = frontmatter.DefaultSummaryMarker
+)
+
+// Reports if the file of the metadata is only readable by members.
+func Gated(m metadata.Metadata) bool {
+	v, err := metadata.GetTyped[string](m, VisibilityKey)
+	return err == nil && v == Members
+}
+
+// The members plugin, which wraps a [plugin.Sourcer] to gate the files and is
+// a [plugin.Middleware] that serves the full files to members.
+type Plugin interface {
+	plugin.Sourcer
+	plugin.Middleware
+}
+
+// Creates the members [Plugin], wrapping the sourcer, whose files should already
+// have their metadata, such as the ones of the frontmatter plugin. It should be
+// the last wrapper of the sourcer of the engine, since members are served the
+// files of the wrapped sourcer directly.
+//
+// Requests authenticated by Opts.Authenticator, with Opts.Scopes, are members,
+// and have the [auth.Identity] set on their context. Renders for members are
+// cached separately (see [core.WithCacheVariant]) and responded as private.
+func New(sourcer plugin.Sourcer, opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Scopes == nil {
+		opt.Scopes = []string{}
+	}
+	if opt.Marker == "" {
+		opt.Marker = DefaultMarker
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be wrapped should not be nil")
+
+	return &p{
+		sourcer: sourcer,
+
+		authenticator: opt.Authenticator,
+		scopes:        opt.Scopes,
+		marker:        []byte(opt.Marker),
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Authenticator of members, such as the [auth.Sessions] of a login flow. If
+	// nil, no request is a member and gated files only have their teasers.
+	Authenticator auth.Authenticator
+	// Scopes that members need to have. Defaults to none, so any authenticated
+	// user is a member.
+	Scopes []string
+
+	// Marker of the end of the teaser on the body of files. Defaults to
+	// [DefaultMarker], the same as the summary marker of the frontmatter plugin.
+	Marker string
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	sourcer plugin.Sourcer
+
+	authenticator auth.Authenticator
+	scopes        []string
+	marker        []byte
+
+	mu   sync.RWMutex
+	fsys fs.FS
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Source() (fs.FS, error) {
+	p.assert.NotNil(p.sourcer)
+
+	fsys, err := p.sourcer.Source()
+	if err != nil {
+		return fsys, err
+	}
+
+	p.mu.Lock()
+	p.fsys = fsys
+	p.mu.Unlock()
+
+	return &gatedFS{FS: fsys, marker: p.marker}, nil
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.log)
+
+		w.Header().Add("Vary", "Cookie")
+
+		p.mu.RLock()
+		fsys := p.fsys
+		p.mu.RUnlock()
+
+		id, ok := p.member(r)
+		if fsys == nil || !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p.log.Debug("Serving request of member", slog.String("subject", id.Subject))
+
+		ctx := auth.WithIdentity(r.Context(), id)
+		ctx = core.WithFiles(ctx, fsys)
+		ctx = core.WithCacheVariant(ctx, cacheVariant(ctx))
+
+		w.Header().Set("Cache-Control", "private")
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (p *p) member(r *http.Request) (auth.Identity, bool) {
+	if p.authenticator == nil {
+		return auth.Identity{}, false
+	}
+
+	id, err := p.authenticator.Authenticate(r)
+	if err != nil {
+		return auth.Identity{}, false
+	}
+
+	for _, s := range p.scopes {
+		if !id.HasScope(s) {
+			return auth.Identity{}, false
+		}
+	}
+
+	return id, true
+}
+
+// Returns the cache variant of members, keeping the variant already set on the
+// context, if any, such as by the variant plugin.
+func cacheVariant(ctx context.Context) string {
+	if v, ok := core.CacheVariantFromContext(ctx); ok {
+		return v + "&" + Members
+	}
+	return Members
+}
+
+// File system that replaces the content of gated files with their teasers,
+// also on the entries of its directories.
+type gatedFS struct {
+	fs.FS
+	marker []byte
+}
+
+func (fsys *gatedFS) Metadata() metadata.Metadata {
+	if m, err := metadata.GetMetadata(fsys.FS); err == nil {
+		return m
+	}
+	return metadata.Map(map[string]any{})
+}
+
+func (fsys *gatedFS) Open(name string) (fs.File, error) {
+	f, err := fsys.FS.Open(name)
+	if err != nil {
+		return f, err
+	}
+
+	if d, ok := f.(fs.ReadDirFile); ok {
+		return &dirFile{ReadDirFile: d, fsys: fsys, path: name}, nil
+	}
+
+	m, err := metadata.GetMetadata(f)
+	if err != nil || !Gated(m) {
+		return f, nil
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	teaser := fsys.teaser(body, m)
+
+	return &teaserFile{
+		Reader: bytes.NewReader(teaser),
+		info:   teaserInfo{FileInfo: info, size: int64(len(teaser))},
+		metadata: metadata.Join(
+			metadata.Map(map[string]any{GatedKey: true}),
+			m,
+		),
+	}, nil
+}
+
+func (fsys *gatedFS) ReadFile(name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
+
+func (fsys *gatedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d, ok := f.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	entries, err := d.ReadDir(-1)
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, err
+}
+
+func (fsys *gatedFS) Stat(name string) (fs.FileInfo, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return f.Stat()
+}
+
+// Returns the content before the marker on the body, or the summary of the
+// metadata if there's no marker.
+func (fsys *gatedFS) teaser(body []byte, m metadata.Metadata) []byte {
+	if before, _, found := bytes.Cut(body, fsys.marker); found {
+		return bytes.TrimSpace(before)
+	}
+	if s, err := metadata.GetTyped[string](m, frontmatter.SummaryKey); err == nil {
+		return []byte(s)
+	}
+	return []byte{}
+}
+
+// Directory whose entries are opened through the gated file system, since
+// entries such as the ones of the frontmatter plugin can open the files of the
+// wrapped file system directly.
+type dirFile struct {
+	fs.ReadDirFile
+	fsys *gatedFS
+	path string
+}
+
+func (f *dirFile) Metadata() metadata.Metadata {
+	if m, err := metadata.GetMetadata(f.ReadDirFile); err == nil {
+		return m
+	}
+	return metadata.Map(map[string]any{})
+}
+
+func (f *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	es, err := f.ReadDirFile.ReadDir(n)
+
+	entries := make([]fs.DirEntry, len(es))
+	for i, e := range es {
+		entries[i] = &dirEntry{DirEntry: e, fsys: f.fsys, path: path.Join(f.path, e.Name())}
+	}
+	return entries, err
+}
+
+type dirEntry struct {
+	fs.DirEntry
+	fsys *gatedFS
+	path string
+}
+
+func (e *dirEntry) Path() string {
+	return e.path
+}
+
+func (e *dirEntry) Open() (fs.File, error) {
+	return e.fsys.Open(e.path)
+}
+
+func (e *dirEntry) Info() (fs.FileInfo, error) {
+	if e.IsDir() {
+		return e.DirEntry.Info()
+	}
+	return e.fsys.Stat(e.path)
+}
+
+func (e *dirEntry) Metadata() metadata.Metadata {
+	m, err := metadata.GetMetadata(e.DirEntry)
+	if err != nil {
+		return metadata.Map(map[string]any{})
+	}
+	if Gated(m) {
+		return metadata.Join(metadata.Map(map[string]any{GatedKey: true}), m)
+	}
+	return m
+}
+
+type teaserFile struct {
+	*bytes.Reader
+	info     teaserInfo
+	metadata metadata.Metadata
+}
+
+func (f *teaserFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *teaserFile) Close() error {
+	return nil
+}
+
+func (f *teaserFile) Metadata() metadata.Metadata {
+	return f.metadata
+}
+
+type teaserInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i teaserInfo) Size() int64 {
+	return i.size
+}
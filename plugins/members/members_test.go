@@ -0,0 +1,107 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package members_test
+
+import (
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugins/frontmatter"
+	"forge.capytal.company/loreddev/blogo/plugins/members"
+)
+
+type sourcer struct {
+	fsys fs.FS
+}
+
+func (s sourcer) Name() string {
+	return "test-sourcer"
+}
+
+func (s sourcer) Source() (fs.FS, error) {
+	return s.fsys, nil
+}
+
+func TestGatedFS(t *testing.T) {
+	fsys, err := members.New(frontmatter.New(sourcer{fstest.MapFS{
+		"posts/gated.md": {Data: []byte("---\nvisibility: members\n---\nTeaser\n<!--more-->\nSecret")},
+	}})).Source()
+	if err != nil {
+		t.Fatalf("failed to source files: %s", err)
+	}
+
+	check := func(how string, b []byte) {
+		t.Helper()
+		if strings.Contains(string(b), "Secret") {
+			t.Fatalf("%s returned the gated body: %q", how, b)
+		}
+	}
+
+	b, err := fs.ReadFile(fsys, "posts/gated.md")
+	if err != nil {
+		t.Fatalf("failed to read file: %s", err)
+	}
+	check("fs.ReadFile", b)
+
+	info, err := fs.Stat(fsys, "posts/gated.md")
+	if err != nil {
+		t.Fatalf("failed to stat file: %s", err)
+	}
+	if info.Size() != int64(len(b)) {
+		t.Fatalf("expected size of the teaser from fs.Stat, got %d", info.Size())
+	}
+
+	es, err := fs.ReadDir(fsys, "posts")
+	if err != nil || len(es) != 1 {
+		t.Fatalf("failed to read directory: %v %v", es, err)
+	}
+
+	m, err := metadata.GetMetadata(es[0])
+	if err != nil {
+		t.Fatalf("expected metadata of directory entry: %s", err)
+	}
+	if !members.Gated(m) {
+		t.Fatalf("expected metadata of directory entry to be gated")
+	}
+
+	info, err = es[0].Info()
+	if err != nil {
+		t.Fatalf("failed to get info of directory entry: %s", err)
+	}
+	if info.Size() != int64(len(b)) {
+		t.Fatalf("expected size of the teaser from directory entry, got %d", info.Size())
+	}
+
+	o, ok := es[0].(interface{ Open() (fs.File, error) })
+	if !ok {
+		t.Fatalf("expected directory entry to be openable")
+	}
+	f, err := o.Open()
+	if err != nil {
+		t.Fatalf("failed to open directory entry: %s", err)
+	}
+	defer f.Close()
+
+	b, err = io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("failed to read directory entry: %s", err)
+	}
+	check("opening the directory entry", b)
+}
@@ -0,0 +1,445 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package micropub provides a [plugin.Middleware] serving a Micropub endpoint
+// (https://micropub.spec.indieweb.org), so posts can be published from Micropub
+// clients, such as mobile apps, writing them back through a [plugin.WritableSourcer].
+package micropub
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/audit"
+	"forge.capytal.company/loreddev/blogo/auth"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/slug"
+	"forge.capytal.company/loreddev/x/tinyssert"
+	"gopkg.in/yaml.v2"
+)
+
+const pluginName = "blogo-micropub-middleware"
+
+// Creates a [plugin.Middleware] that serves the Micropub endpoint on Opts.Path.
+// Requests are authenticated by Opts.Authenticator, usually a IndieAuth token
+// verifier (see the indieauth package), and need the "create" scope to create
+// posts and "delete" to delete them. If no authenticator is provided, all
+// requests are rejected.
+//
+// Posts (h-entry) are written as markdown files with a YAML frontmatter, on
+// Opts.Dir, with the "name" property as "title", "summary" as "summary",
+// "category" as "tags", "published" as "date" and "post-status: draft" as
+// "draft: true". Photos are appended to the content as images, and other
+// properties, such as "in-reply-to" and "like-of", are kept on the frontmatter
+// with the same names.
+//
+// Posts are deleted by their URL, which is mapped back to the path of the file
+// by adding Opts.Ext to it's URL path. Updates and media uploads are not supported.
+func New(sourcer plugin.WritableSourcer, opts ...Opts) plugin.Middleware {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Path == "" {
+		opt.Path = "/micropub"
+	}
+	if opt.Ext == "" {
+		opt.Ext = ".md"
+	}
+	if opt.URL == nil {
+		base := strings.TrimSuffix(opt.BaseURL, "/")
+		opt.URL = func(p string) string {
+			return base + "/" + strings.TrimSuffix(p, path.Ext(p))
+		}
+	}
+	if opt.Slugifier == nil {
+		opt.Slugifier = slug.Default
+	}
+	if opt.Now == nil {
+		opt.Now = time.Now
+	}
+	if opt.Location == nil {
+		opt.Location = time.UTC
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Writable sourcer should not be nil")
+
+	return &p{
+		sourcer:       sourcer,
+		authenticator: opt.Authenticator,
+
+		path:      opt.Path,
+		dir:       strings.Trim(opt.Dir, "/"),
+		ext:       opt.Ext,
+		url:       opt.URL,
+		slugifier: opt.Slugifier,
+		now:       opt.Now,
+		location:  opt.Location,
+		onWrite:   opt.OnWrite,
+		audit:     opt.Audit,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Path of the endpoint. Defaults to "/micropub".
+	Path string
+	// Authenticates requests, which need the "create" or "delete" scopes.
+	Authenticator auth.Authenticator
+
+	// Directory that new posts are written to. Defaults to the root of the
+	// file system.
+	Dir string
+	// Extension of the files of posts. Defaults to ".md".
+	Ext string
+
+	// Base URL of the blog, used to create the URLs of new posts.
+	BaseURL string
+	// Function that returns the public URL of a file path, returned to clients
+	// after a post is created. Defaults to BaseURL joined with the path without
+	// it's extension.
+	URL func(path string) string
+
+	// Slugifier used to create the file names of posts from their names, if the
+	// clients don't send a "mp-slug". Defaults to [slug.Default].
+	Slugifier slug.Slugifier
+	// Returns the current time, used as the date of posts without a "published"
+	// property. Defaults to [time.Now].
+	Now func() time.Time
+	// Time zone of the site, which the dates of posts are written on. Defaults
+	// to [time.UTC].
+	Location *time.Location
+
+	// Called with the path of each file created or deleted, so the application
+	// can refresh the content of the blog.
+	OnWrite func(path string)
+	// Log where created and deleted posts are recorded, with the
+	// "micropub.create" and "micropub.delete" actions. By default they are only
+	// logged.
+	Audit audit.Log
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	sourcer       plugin.WritableSourcer
+	authenticator auth.Authenticator
+
+	path      string
+	dir       string
+	ext       string
+	url       func(string) string
+	slugifier slug.Slugifier
+	now       func() time.Time
+	location  *time.Location
+	onWrite   func(string)
+	audit     audit.Log
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.sourcer)
+		p.assert.NotNil(p.log)
+
+		if r.URL.Path != p.path {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			if _, ok := p.authenticate(w, r); ok {
+				p.serveQuery(w, r)
+			}
+		case http.MethodPost:
+			p.servePost(w, r)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func (p *p) serveQuery(w http.ResponseWriter, r *http.Request) {
+	switch q := r.URL.Query().Get("q"); q {
+	case "config":
+		writeJSON(w, http.StatusOK, map[string]any{
+			"q":            []string{"config", "syndicate-to"},
+			"syndicate-to": []any{},
+		})
+	case "syndicate-to":
+		writeJSON(w, http.StatusOK, map[string]any{"syndicate-to": []any{}})
+	default:
+		writeError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("Unsupported query %q", q))
+	}
+}
+
+func (p *p) servePost(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	req, err := parseRequest(r)
+	if err != nil {
+		p.log.Debug("Invalid Micropub request", slog.String("err", err.Error()))
+		writeError(w, http.StatusBadRequest, "invalid_request", "Invalid request")
+		return
+	}
+
+	id, ok := p.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	log := p.log.With(slog.String("subject", id.Subject))
+
+	switch req.action {
+	case "", "create":
+		if !id.HasScope("create") && !id.HasScope("post") {
+			writeError(w, http.StatusForbidden, "insufficient_scope", "Missing create scope")
+			return
+		}
+		p.create(w, r, req, log)
+	case "delete":
+		if !id.HasScope("delete") {
+			writeError(w, http.StatusForbidden, "insufficient_scope", "Missing delete scope")
+			return
+		}
+		p.delete(w, r, req, log)
+	default:
+		writeError(w, http.StatusBadRequest, "invalid_request",
+			fmt.Sprintf("Unsupported action %q", req.action))
+	}
+}
+
+func (p *p) authenticate(w http.ResponseWriter, r *http.Request) (auth.Identity, bool) {
+	if p.authenticator == nil {
+		p.log.Warn("No authenticator configured, rejecting request")
+		writeError(w, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return auth.Identity{}, false
+	}
+
+	id, err := p.authenticator.Authenticate(r)
+	if err != nil {
+		p.log.Debug("Request not authenticated", slog.String("err", err.Error()))
+		if c, ok := p.authenticator.(auth.Challenger); ok {
+			w.Header().Set("WWW-Authenticate", c.Challenge())
+		}
+		if errors.Is(err, auth.ErrUnauthenticated) {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		} else {
+			writeError(w, http.StatusForbidden, "forbidden", "Invalid access token")
+		}
+		return auth.Identity{}, false
+	}
+
+	return id, true
+}
+
+func (p *p) create(w http.ResponseWriter, r *http.Request, req request, log *slog.Logger) {
+	if req.typ != "" && req.typ != "entry" {
+		writeError(w, http.StatusBadRequest, "invalid_request",
+			fmt.Sprintf("Unsupported type %q", req.typ))
+		return
+	}
+
+	base, data, err := p.post(req.properties)
+	if err != nil {
+		log.Error("Failed to create post", slog.String("err", err.Error()))
+		writeError(w, http.StatusInternalServerError, "server_error", "Failed to create post")
+		return
+	}
+
+	// Don't overwrite existing posts with the same slug.
+	name := path.Join(p.dir, base+p.ext)
+	for i := 2; ; i++ {
+		err = p.sourcer.Create(r.Context(), name, data)
+		if !errors.Is(err, fs.ErrExist) || i > 100 {
+			break
+		}
+		name = path.Join(p.dir, fmt.Sprintf("%s-%d%s", base, i, p.ext))
+	}
+
+	log = log.With(slog.String("file", name))
+
+	if err != nil {
+		log.Error("Failed to write post", slog.String("err", err.Error()))
+		writeError(w, http.StatusInternalServerError, "server_error", "Failed to write post")
+		return
+	}
+
+	log.Info("Post created")
+	audit.Record(r.Context(), p.audit, audit.FromRequest(r, "micropub.create", name), log)
+
+	if p.onWrite != nil {
+		p.onWrite(name)
+	}
+
+	w.Header().Set("Location", p.url(name))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (p *p) delete(w http.ResponseWriter, r *http.Request, req request, log *slog.Logger) {
+	u, err := url.Parse(req.url)
+	if req.url == "" || err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "Invalid URL of post")
+		return
+	}
+
+	name := strings.Trim(path.Clean("/"+u.Path), "/") + p.ext
+	if !fs.ValidPath(name) {
+		writeError(w, http.StatusBadRequest, "invalid_request", "Invalid URL of post")
+		return
+	}
+
+	log = log.With(slog.String("file", name))
+
+	err = p.sourcer.Delete(r.Context(), name)
+	if errors.Is(err, fs.ErrNotExist) {
+		writeError(w, http.StatusBadRequest, "invalid_request", "Post not found")
+		return
+	} else if err != nil {
+		log.Error("Failed to delete post", slog.String("err", err.Error()))
+		writeError(w, http.StatusInternalServerError, "server_error", "Failed to delete post")
+		return
+	}
+
+	log.Info("Post deleted")
+	audit.Record(r.Context(), p.audit, audit.FromRequest(r, "micropub.delete", name), log)
+
+	if p.onWrite != nil {
+		p.onWrite(name)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Properties that are mapped to other fields of the post, and aren't kept on
+// the frontmatter with their names.
+var mappedProperties = []string{
+	"name", "summary", "content", "category", "published", "post-status", "photo",
+}
+
+// Returns the slug and contents of the file of a post with the properties.
+func (p *p) post(props map[string][]any) (string, []byte, error) {
+	now := p.now()
+	date := now
+	if d, ok := first[string](props, "published"); ok {
+		if t, err := time.Parse(time.RFC3339, d); err == nil {
+			date = t
+		}
+	}
+
+	name, _ := first[string](props, "name")
+
+	fm := yaml.MapSlice{}
+	if name != "" {
+		fm = append(fm, yaml.MapItem{Key: "title", Value: name})
+	}
+	fm = append(fm, yaml.MapItem{Key: "date", Value: date.In(p.location).Format(time.RFC3339)})
+	if s, ok := first[string](props, "summary"); ok {
+		fm = append(fm, yaml.MapItem{Key: "summary", Value: s})
+	}
+	if tags := stringValues(props["category"]); len(tags) > 0 {
+		fm = append(fm, yaml.MapItem{Key: "tags", Value: tags})
+	}
+	if s, _ := first[string](props, "post-status"); s == "draft" {
+		fm = append(fm, yaml.MapItem{Key: "draft", Value: true})
+	}
+
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		if !slices.Contains(mappedProperties, k) && !strings.HasPrefix(k, "mp-") {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		if v := props[k]; len(v) == 1 {
+			fm = append(fm, yaml.MapItem{Key: k, Value: v[0]})
+		} else if len(v) > 1 {
+			fm = append(fm, yaml.MapItem{Key: k, Value: v})
+		}
+	}
+
+	header, err := yaml.Marshal(fm)
+	if err != nil {
+		return "", nil, errors.Join(errors.New("failed to marshal frontmatter"), err)
+	}
+
+	var b strings.Builder
+	b.WriteString("---\n")
+	b.Write(header)
+	b.WriteString("---\n\n")
+	if len(props["content"]) > 0 {
+		b.WriteString(strings.TrimSpace(content(props["content"][0])))
+		b.WriteString("\n")
+	}
+	for _, ph := range props["photo"] {
+		u, alt := photo(ph)
+		if u != "" {
+			fmt.Fprintf(&b, "\n![%s](<%s>)\n", alt, u)
+		}
+	}
+
+	base := ""
+	if s, ok := first[string](props, "mp-slug"); ok {
+		base = p.slugifier.Slugify(s)
+	} else if name != "" {
+		base = p.slugifier.Slugify(name)
+	}
+	if base == "" {
+		base = now.UTC().Format("2006-01-02-150405")
+	}
+
+	return base, []byte(b.String()), nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package micropub
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// A Micropub request, normalized from form encoded and JSON requests.
+type request struct {
+	// Type of the object to create, without the "h-" prefix, such as "entry".
+	typ    string
+	action string
+	url    string
+
+	properties map[string][]any
+}
+
+// Form values that aren't properties of the created object.
+var reservedValues = []string{"h", "action", "url", "access_token"}
+
+func parseRequest(r *http.Request) (request, error) {
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if ct == "application/json" {
+		var body struct {
+			Type       []string         `json:"type"`
+			Action     string           `json:"action"`
+			URL        string           `json:"url"`
+			Properties map[string][]any `json:"properties"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return request{}, errors.Join(errors.New("failed to decode JSON request"), err)
+		}
+
+		req := request{action: body.Action, url: body.URL, properties: body.Properties}
+		if len(body.Type) > 0 {
+			req.typ = strings.TrimPrefix(body.Type[0], "h-")
+		}
+		if req.properties == nil {
+			req.properties = map[string][]any{}
+		}
+		return req, nil
+	}
+
+	var err error
+	if ct == "multipart/form-data" {
+		err = r.ParseMultipartForm(1 << 20)
+	} else {
+		err = r.ParseForm()
+	}
+	if err != nil {
+		return request{}, errors.Join(errors.New("failed to parse form request"), err)
+	}
+
+	req := request{
+		typ:        r.PostForm.Get("h"),
+		action:     r.PostForm.Get("action"),
+		url:        r.PostForm.Get("url"),
+		properties: map[string][]any{},
+	}
+
+	for k, vs := range r.PostForm {
+		k = strings.TrimSuffix(k, "[]")
+		if slices.Contains(reservedValues, k) {
+			continue
+		}
+		for _, v := range vs {
+			req.properties[k] = append(req.properties[k], v)
+		}
+	}
+
+	// Tokens can also be sent on the body of form requests, which is moved to
+	// the header so authenticators find it.
+	if t := r.PostForm.Get("access_token"); t != "" && r.Header.Get("Authorization") == "" {
+		r.Header.Set("Authorization", "Bearer "+t)
+	}
+
+	return req, nil
+}
+
+// Returns the first value of the property, if it's of the type.
+func first[T any](props map[string][]any, k string) (T, bool) {
+	var zero T
+	if len(props[k]) == 0 {
+		return zero, false
+	}
+	v, ok := props[k][0].(T)
+	return v, ok
+}
+
+// Returns the string values of the property.
+func stringValues(vs []any) []string {
+	s := make([]string, 0, len(vs))
+	for _, v := range vs {
+		if v, ok := v.(string); ok && v != "" {
+			s = append(s, v)
+		}
+	}
+	return s
+}
+
+// Returns the text of a content value, which is either a string or a object
+// with the "html" or "value" of the content.
+func content(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case map[string]any:
+		if h, ok := v["html"].(string); ok {
+			return h
+		}
+		if t, ok := v["value"].(string); ok {
+			return t
+		}
+	}
+	return ""
+}
+
+// Returns the URL and alternative text of a photo value, which is either a URL
+// or a object with the "value" and "alt" of the photo.
+func photo(v any) (string, string) {
+	switch v := v.(type) {
+	case string:
+		return v, ""
+	case map[string]any:
+		u, _ := v["value"].(string)
+		alt, _ := v["alt"].(string)
+		return u, alt
+	}
+	return "", ""
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, description string) {
+	writeJSON(w, status, map[string]string{"error": code, "error_description": description})
+}
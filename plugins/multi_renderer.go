@@ -17,6 +17,7 @@ package plugins
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -97,6 +98,10 @@ func (r *multiRenderer) Use(p plugin.Plugin) {
 }
 
 func (r *multiRenderer) Render(src fs.File, w io.Writer) error {
+	return r.RenderContext(context.Background(), src, w)
+}
+
+func (r *multiRenderer) RenderContext(ctx context.Context, src fs.File, w io.Writer) error {
 	r.assert.NotNil(r.plugins)
 	r.assert.NotNil(r.log)
 	r.assert.NotNil(src)
@@ -117,7 +122,7 @@ func (r *multiRenderer) Render(src fs.File, w io.Writer) error {
 		log := log.With(slog.String("plugin", pr.Name()))
 
 		log.Debug("Trying to render with plugin")
-		err := pr.Render(src, w)
+		err := plugin.RenderContext(ctx, pr, src, w)
 
 		if err == nil {
 			break
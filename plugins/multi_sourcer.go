@@ -174,3 +174,38 @@ func (mf *multiSourcerFS) Open(name string) (fs.File, error) {
 
 	return nil, fs.ErrNotExist
 }
+
+// Implements [fs.ReadDirFS], reading the directory of the first file system
+// that has it, like Open.
+func (mf *multiSourcerFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return firstOf(mf, func(f fs.FS) ([]fs.DirEntry, error) { return fs.ReadDir(f, name) })
+}
+
+// Implements [fs.ReadFileFS], reading the file of the first file system that
+// has it, like Open.
+func (mf *multiSourcerFS) ReadFile(name string) ([]byte, error) {
+	return firstOf(mf, func(f fs.FS) ([]byte, error) { return fs.ReadFile(f, name) })
+}
+
+// Implements [fs.StatFS], describing the file of the first file system that
+// has it, like Open.
+func (mf *multiSourcerFS) Stat(name string) (fs.FileInfo, error) {
+	return firstOf(mf, func(f fs.FS) (fs.FileInfo, error) { return fs.Stat(f, name) })
+}
+
+func firstOf[T any](mf *multiSourcerFS, fn func(fs.FS) (T, error)) (T, error) {
+	var zero T
+	for _, f := range mf.fileSystems {
+		v, err := fn(f)
+
+		if err != nil && !errors.Is(err, fs.ErrNotExist) && !mf.skipOnError {
+			return v, err
+		}
+
+		if err == nil {
+			return v, err
+		}
+	}
+
+	return zero, fs.ErrNotExist
+}
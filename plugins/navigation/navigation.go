@@ -0,0 +1,227 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package navigation provides the previous and next posts of each post of the
+// blog, computed from a [index.Index], so templates can link posts in
+// chronological order, site-wide, within their section or within their series.
+package navigation
+
+import (
+	"cmp"
+	"html/template"
+	"io"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+
+	"forge.capytal.company/loreddev/blogo/index"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/visibility"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-navigation-plugin"
+
+// Set of posts that are navigated between.
+type Scope string
+
+const (
+	// All posts of the blog, ordered by date.
+	Site Scope = "site"
+	// Posts on the same directory, ordered by date.
+	Section Scope = "section"
+	// Posts with the same "series" metadata, ordered by their "series-order"
+	// metadata and then by date.
+	Series Scope = "series"
+)
+
+// The posts around a post. Prev is the one that comes before it (the older
+// post, or the previous part of the series) and Next the one after it. Either
+// is nil if there isn't one.
+type Links struct {
+	Prev *index.Entry
+	Next *index.Entry
+}
+
+// Returns the posts around the post at the path, on the scope. Only posts
+// visible on listings ([visibility.Listing]) by the rules are navigated to, or
+// all posts if the rules are nil. Returns empty
+// [Links] if the post isn't on the index, or doesn't have a series if the scope
+// is [Series].
+func Neighbors(idx index.Index, p string, scope Scope, rules visibility.Rules) Links {
+	current, ok := idx.Get(p)
+	if !ok {
+		return Links{}
+	}
+
+	series, _ := metadata.GetTyped[string](current.Metadata, "series")
+	if scope == Series && series == "" {
+		return Links{}
+	}
+
+	entries := slices.DeleteFunc(idx.Entries(), func(e index.Entry) bool {
+		if e.Path == current.Path {
+			return false
+		}
+		if rules != nil && !rules.Visible(e.Path, e.Metadata, visibility.Listing) {
+			return true
+		}
+
+		switch scope {
+		case Section:
+			return path.Dir(e.Path) != path.Dir(current.Path)
+		case Series:
+			s, _ := metadata.GetTyped[string](e.Metadata, "series")
+			return s != series
+		default:
+			return false
+		}
+	})
+
+	// Entries of the index are sorted from newest to oldest, navigation goes
+	// from oldest to newest.
+	slices.Reverse(entries)
+	if scope == Series {
+		slices.SortStableFunc(entries, func(a, b index.Entry) int {
+			return cmp.Compare(seriesOrder(a), seriesOrder(b))
+		})
+	}
+
+	i := slices.IndexFunc(entries, func(e index.Entry) bool { return e.Path == current.Path })
+
+	l := Links{}
+	if i > 0 {
+		l.Prev = &entries[i-1]
+	}
+	if i >= 0 && i < len(entries)-1 {
+		l.Next = &entries[i+1]
+	}
+	return l
+}
+
+func seriesOrder(e index.Entry) int {
+	o, _ := metadata.GetTyped[int](e.Metadata, "series-order")
+	return o
+}
+
+// The navigation plugin.
+type Plugin interface {
+	plugin.Plugin
+	// Returns the template functions, where SCOPE is "site" (the default),
+	// "section" or "series":
+	//
+	//   - "prevPost PATH [SCOPE]" returns the [index.Entry] of the post before
+	//     the post at the path, or nil.
+	//   - "nextPost PATH [SCOPE]" returns the [index.Entry] of the post after the
+	//     post at the path, or nil.
+	//   - "postLinks PATH [SCOPE]" returns both as [Links].
+	FuncMap() template.FuncMap
+}
+
+// Creates the navigation [Plugin] of the posts of the index. The path of posts
+// is the path of their file, such as the "frontmatter.path" metadata.
+func New(provider index.Provider, opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Visibility == nil {
+		opt.Visibility = visibility.Default
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(provider, "Index provider should not be nil")
+
+	return &p{
+		provider:   provider,
+		visibility: opt.Visibility,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Rules used to hide posts from the navigation. Defaults to [visibility.Default].
+	Visibility visibility.Rules
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	provider   index.Provider
+	visibility visibility.Rules
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"prevPost": func(name string, scope ...string) *index.Entry {
+			return p.links(name, scope).Prev
+		},
+		"nextPost": func(name string, scope ...string) *index.Entry {
+			return p.links(name, scope).Next
+		},
+		"postLinks": func(name string, scope ...string) Links {
+			return p.links(name, scope)
+		},
+	}
+}
+
+func (p *p) links(name string, scope []string) Links {
+	p.assert.NotNil(p.provider)
+	p.assert.NotNil(p.log)
+
+	s := Site
+	if len(scope) > 0 && scope[0] != "" {
+		s = Scope(scope[0])
+	}
+
+	idx, err := p.provider.Index()
+	if err != nil {
+		p.log.Error("Failed to get index", slog.String("err", err.Error()))
+		return Links{}
+	}
+
+	return Neighbors(idx, strings.TrimPrefix(name, "/"), s, p.visibility)
+}
@@ -16,6 +16,7 @@
 package plugins
 
 import (
+	"bytes"
 	"errors"
 	"html/template"
 	"io"
@@ -101,11 +102,14 @@ func (h *notFoundErrorHandler) Handle(err error) (recovr any, handled bool) {
 
 	log = h.log.With(slog.String("sourceerr", sourceErr.Error()))
 
-	pathErr, ok := sourceErr.Err.(*fs.PathError)
-	if !ok {
+	// Errors of the os package, such as the ones of the local sourcer, have the
+	// syscall error instead of fs.ErrNotExist itself, so it needs to be compared
+	// with errors.Is.
+	var pathErr *fs.PathError
+	if !errors.As(sourceErr.Err, &pathErr) {
 		log.Debug("Error is not a *fs.PathError, ignoring error")
 		return nil, false
-	} else if pathErr.Err != fs.ErrNotExist {
+	} else if !errors.Is(pathErr.Err, fs.ErrNotExist) {
 		log.Debug("Error is not fs.ErrNotExist, ignoring error")
 		return nil, false
 	}
@@ -114,10 +118,10 @@ func (h *notFoundErrorHandler) Handle(err error) (recovr any, handled bool) {
 
 	log.Debug("Handling error")
 
-	w, r := serr.Res, serr.Req
+	r := serr.Req
 
-	w.WriteHeader(http.StatusNotFound)
-	if err := h.templt.Execute(w, NotFoundErrorHandlerInfo{
+	var buf bytes.Buffer
+	if err := h.templt.Execute(&buf, NotFoundErrorHandlerInfo{
 		Plugin:   sourceErr.Sourcer.Name(),
 		Path:     r.URL.Path,
 		FilePath: pathErr.Path,
@@ -128,5 +132,5 @@ func (h *notFoundErrorHandler) Handle(err error) (recovr any, handled bool) {
 		return nil, false
 	}
 
-	return nil, true
+	return core.RecoverWithResponse(respondBody(http.StatusNotFound, buf.Bytes())), true
 }
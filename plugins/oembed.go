@@ -0,0 +1,454 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/httpclient"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const oEmbedName = "blogo-oembed-renderer"
+
+// Providers used by default by [NewOEmbed].
+var DefaultOEmbedProviders = []OEmbedProvider{
+	{
+		Name:     "youtube",
+		Hosts:    []string{"youtube.com", "www.youtube.com", "m.youtube.com", "youtu.be"},
+		Endpoint: "https://www.youtube.com/oembed",
+	},
+	{
+		Name:     "vimeo",
+		Hosts:    []string{"vimeo.com", "www.vimeo.com"},
+		Endpoint: "https://vimeo.com/api/oembed.json",
+	},
+}
+
+// A oEmbed provider allowed to be embedded by [NewOEmbed].
+type OEmbedProvider struct {
+	// Name of the provider, used on the class of the embed wrapper.
+	Name string
+	// Hosts that the provider serves content from.
+	Hosts []string
+	// The oEmbed endpoint of the provider. If empty, the endpoint is discovered by
+	// fetching the URL and searching for a "application/json+oembed" link, which is
+	// useful for federated providers such as Mastodon instances.
+	Endpoint string
+	// Hosts that discovered endpoints are allowed to be on, since the HTML of
+	// their responses is added to the page. Endpoints on other hosts are ignored.
+	// Defaults to Hosts.
+	EndpointHosts []string
+}
+
+// Creates a [plugin.Renderer] that transforms already rendered HTML, replacing
+// paragraphs that only have a bare URL (linked or not) with it's oEmbed HTML,
+// if the URL is from one of the allowed providers.
+//
+// Responses from providers are cached in memory for [OEmbedOpts].CacheDuration.
+// URLs that fail to be fetched are left untouched, and the failures are cached
+// for [OEmbedOpts].ErrorCacheDuration.
+//
+// This renderer is intended to be used after other renderers in a [FoldingRenderer].
+func NewOEmbed(opts ...OEmbedOpts) plugin.Renderer {
+	opt := OEmbedOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Providers == nil {
+		opt.Providers = DefaultOEmbedProviders
+	}
+	injectHTTPClient := opt.HTTPClient == nil
+	if opt.HTTPClient == nil {
+		opt.HTTPClient = httpclient.Default()
+	}
+	if opt.CacheDuration == 0 {
+		opt.CacheDuration = 24 * time.Hour
+	}
+	if opt.ErrorCacheDuration == 0 {
+		opt.ErrorCacheDuration = 5 * time.Minute
+	}
+	if opt.MaxCacheEntries == 0 {
+		opt.MaxCacheEntries = 512
+	}
+	if opt.MaxWidth == 0 {
+		opt.MaxWidth = 640
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &oEmbed{
+		providers:     opt.Providers,
+		client:        opt.HTTPClient,
+		cacheDuration: opt.CacheDuration,
+		errorDuration: opt.ErrorCacheDuration,
+		maxEntries:    opt.MaxCacheEntries,
+		maxWidth:      opt.MaxWidth,
+
+		cache: map[string]oEmbedCacheEntry{},
+
+		injectHTTPClient: injectHTTPClient,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type OEmbedOpts struct {
+	// Allowlist of providers. Defaults to [DefaultOEmbedProviders].
+	Providers []OEmbedProvider
+	// Client used to request the providers. Defaults to [httpclient.Default].
+	HTTPClient *http.Client
+	// How long responses are cached. Defaults to 24 hours.
+	CacheDuration time.Duration
+	// How long failures to get the response of URLs are cached, so pages with
+	// dead URLs don't request the providers on every render. Defaults to 5
+	// minutes.
+	ErrorCacheDuration time.Duration
+	// Max number of cached responses. When reached, the cache is cleared.
+	// Defaults to 512.
+	MaxCacheEntries int
+	// Max width requested to the providers. Defaults to 640.
+	MaxWidth int
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type oEmbed struct {
+	providers     []OEmbedProvider
+	client        *http.Client
+	cacheDuration time.Duration
+	errorDuration time.Duration
+	maxEntries    int
+	maxWidth      int
+
+	cache   map[string]oEmbedCacheEntry
+	cacheMu sync.Mutex
+
+	injectHTTPClient bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+type oEmbedCacheEntry struct {
+	html    string
+	failed  bool
+	expires time.Time
+}
+
+type oEmbedResponse struct {
+	Type string `json:"type"`
+	HTML string `json:"html"`
+	URL  string `json:"url"`
+
+	Title string `json:"title"`
+}
+
+func (r *oEmbed) Name() string {
+	return oEmbedName
+}
+
+// Implements [plugin.HTTPClientAware], using the client of the engine if no
+// client was provided on construction.
+func (r *oEmbed) SetHTTPClient(client *http.Client) {
+	if r.injectHTTPClient {
+		r.client = client
+	}
+}
+
+func (r *oEmbed) Render(src fs.File, w io.Writer) error {
+	r.assert.NotNil(src)
+	r.assert.NotNil(w)
+	r.assert.NotNil(r.cache)
+	r.assert.NotNil(r.log)
+
+	if _, ok := src.(fs.ReadDirFile); ok {
+		return errors.New("does not support directories")
+	}
+
+	contents, err := io.ReadAll(src)
+	if err != nil {
+		return errors.Join(errors.New("failed to read file contents"), err)
+	}
+
+	tokens := tokenizeHTML(contents)
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+
+		if tok.Kind == htmlStartTagToken && tok.Tag == "p" {
+			if u, end, ok := bareURLParagraph(tokens, i); ok {
+				if embed, ok := r.embed(u); ok {
+					if _, err := io.WriteString(w, embed); err != nil {
+						return err
+					}
+					i = end
+					continue
+				}
+			}
+		}
+
+		if _, err := w.Write(tok.Raw); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Checks if the paragraph starting at tokens[start] only has a URL as content,
+// returning said URL and the index of the paragraph's end tag.
+func bareURLParagraph(tokens []htmlToken, start int) (string, int, bool) {
+	var href, text string
+	inLink := false
+
+	for i := start + 1; i < len(tokens); i++ {
+		tok := tokens[i]
+
+		switch {
+		case tok.Kind == htmlEndTagToken && tok.Tag == "p":
+			u := strings.TrimSpace(text)
+			if href != "" && u != href {
+				return "", 0, false
+			}
+			if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+				return "", 0, false
+			}
+			return u, i, true
+
+		case tok.Kind == htmlStartTagToken && tok.Tag == "a" && !inLink && href == "":
+			attrs := parseHTMLAttrs(tok)
+			href, _ = getHTMLAttr(attrs, "href")
+			inLink = true
+
+		case tok.Kind == htmlEndTagToken && tok.Tag == "a" && inLink:
+			inLink = false
+
+		case tok.Kind == htmlTextToken:
+			t := html.UnescapeString(string(tok.Raw))
+			if !inLink && href != "" && strings.TrimSpace(t) != "" {
+				return "", 0, false
+			}
+			text += t
+
+		default:
+			return "", 0, false
+		}
+	}
+
+	return "", 0, false
+}
+
+func (r *oEmbed) embed(rawURL string) (string, bool) {
+	log := r.log.With(slog.String("url", rawURL))
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+
+	provider, ok := r.provider(u)
+	if !ok {
+		return "", false
+	}
+
+	r.cacheMu.Lock()
+	entry, ok := r.cache[rawURL]
+	r.cacheMu.Unlock()
+
+	if ok && time.Now().Before(entry.expires) {
+		log.Debug("Using cached oEmbed response", slog.Bool("failed", entry.failed))
+		return entry.html, !entry.failed
+	}
+
+	log.Debug("Requesting oEmbed provider", slog.String("provider", provider.Name))
+
+	res, err := r.fetch(provider, rawURL)
+	if err != nil {
+		log.Warn("Failed to get oEmbed response, leaving URL untouched",
+			slog.String("err", err.Error()))
+		r.setCache(rawURL, oEmbedCacheEntry{failed: true, expires: time.Now().Add(r.errorDuration)})
+		return "", false
+	}
+
+	var embed string
+	switch {
+	case res.HTML != "":
+		embed = res.HTML
+	case res.Type == "photo" && res.URL != "":
+		embed = fmt.Sprintf(`<img src="%s" alt="%s">`,
+			html.EscapeString(res.URL), html.EscapeString(res.Title))
+	default:
+		log.Warn("oEmbed response has no HTML, leaving URL untouched")
+		r.setCache(rawURL, oEmbedCacheEntry{failed: true, expires: time.Now().Add(r.errorDuration)})
+		return "", false
+	}
+
+	embed = fmt.Sprintf(`<div class="embed embed-%s">%s</div>`,
+		html.EscapeString(provider.Name), embed)
+
+	r.setCache(rawURL, oEmbedCacheEntry{html: embed, expires: time.Now().Add(r.cacheDuration)})
+
+	return embed, true
+}
+
+func (r *oEmbed) setCache(rawURL string, e oEmbedCacheEntry) {
+	r.cacheMu.Lock()
+	defer r.cacheMu.Unlock()
+
+	if len(r.cache) >= r.maxEntries {
+		r.cache = map[string]oEmbedCacheEntry{}
+	}
+	r.cache[rawURL] = e
+}
+
+func (r *oEmbed) provider(u *url.URL) (OEmbedProvider, bool) {
+	host := strings.ToLower(u.Hostname())
+	for _, p := range r.providers {
+		for _, h := range p.Hosts {
+			if strings.ToLower(h) == host {
+				return p, true
+			}
+		}
+	}
+	return OEmbedProvider{}, false
+}
+
+func (r *oEmbed) fetch(provider OEmbedProvider, rawURL string) (oEmbedResponse, error) {
+	endpoint := provider.Endpoint
+	if endpoint == "" {
+		e, err := r.discover(provider, rawURL)
+		if err != nil {
+			return oEmbedResponse{}, err
+		}
+		endpoint = e
+	} else {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return oEmbedResponse{}, errors.Join(errors.New("invalid oEmbed endpoint"), err)
+		}
+		q := u.Query()
+		q.Set("url", rawURL)
+		q.Set("format", "json")
+		q.Set("maxwidth", fmt.Sprint(r.maxWidth))
+		u.RawQuery = q.Encode()
+		endpoint = u.String()
+	}
+
+	body, err := r.get(endpoint)
+	if err != nil {
+		return oEmbedResponse{}, err
+	}
+
+	var res oEmbedResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return oEmbedResponse{}, errors.Join(errors.New("failed to parse oEmbed response"), err)
+	}
+
+	return res, nil
+}
+
+// Discovers the oEmbed endpoint of the URL, searching for a link tag with the
+// "application/json+oembed" type on the page. Endpoints which aren't on the
+// EndpointHosts of the provider are ignored, so pages can't point the request,
+// and the HTML added to the page, to other hosts.
+func (r *oEmbed) discover(provider OEmbedProvider, rawURL string) (string, error) {
+	base, err := url.Parse(rawURL)
+	if err != nil {
+		return "", errors.Join(errors.New("invalid URL"), err)
+	}
+
+	hosts := provider.EndpointHosts
+	if hosts == nil {
+		hosts = provider.Hosts
+	}
+
+	body, err := r.get(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	for _, tok := range tokenizeHTML(body) {
+		if tok.Kind != htmlStartTagToken && tok.Kind != htmlSelfClosingTagToken || tok.Tag != "link" {
+			continue
+		}
+
+		attrs := parseHTMLAttrs(tok)
+		if t, _ := getHTMLAttr(attrs, "type"); t != "application/json+oembed" {
+			continue
+		}
+		href, ok := getHTMLAttr(attrs, "href")
+		if !ok {
+			continue
+		}
+
+		u, err := base.Parse(href)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+			continue
+		}
+		host := strings.ToLower(u.Hostname())
+		if !slices.ContainsFunc(hosts, func(h string) bool { return strings.ToLower(h) == host }) {
+			r.log.Warn("Discovered oEmbed endpoint isn't on the hosts of the provider, ignoring",
+				slog.String("url", rawURL), slog.String("endpoint", u.String()))
+			continue
+		}
+
+		return u.String(), nil
+	}
+
+	return "", errors.New("no allowed oEmbed endpoint found on page")
+}
+
+func (r *oEmbed) get(endpoint string) ([]byte, error) {
+	res, err := r.client.Get(endpoint)
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to request"), err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("unexpected response status %q", res.Status)
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, io.LimitReader(res.Body, 1<<20)); err != nil {
+		return nil, errors.Join(errors.New("failed to read response"), err)
+	}
+
+	return buf.Bytes(), nil
+}
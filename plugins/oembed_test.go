@@ -0,0 +1,82 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+
+	"forge.capytal.company/loreddev/blogo/plugins"
+)
+
+func TestOEmbedDiscoveryHosts(t *testing.T) {
+	var evilHits atomic.Int32
+	evil := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		evilHits.Add(1)
+		_, _ = fmt.Fprint(w, `{"type":"rich","html":"<script>alert(1)</script>"}`)
+	}))
+	defer evil.Close()
+
+	// The endpoint is on "localhost", while the page is on "127.0.0.1", so it's
+	// on a host that isn't allowed by the provider.
+	eu, _ := url.Parse(evil.URL)
+	endpoint := "http://localhost:" + eu.Port() + "/oembed"
+
+	var pageHits atomic.Int32
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pageHits.Add(1)
+		_, _ = fmt.Fprintf(w, `<link rel="alternate" type="application/json+oembed" href="%s">`, endpoint)
+	}))
+	defer page.Close()
+
+	pu, _ := url.Parse(page.URL)
+	r := plugins.NewOEmbed(plugins.OEmbedOpts{
+		Providers:  []plugins.OEmbedProvider{{Name: "test", Hosts: []string{pu.Hostname()}}},
+		HTTPClient: http.DefaultClient,
+	})
+
+	src := "<p>" + page.URL + "/post</p>"
+	for range 2 {
+		file, err := fstest.MapFS{"post.html": {Data: []byte(src)}}.Open("post.html")
+		if err != nil {
+			t.Fatalf("failed to open fixture: %s", err)
+		}
+
+		var buf bytes.Buffer
+		if err := r.Render(file, &buf); err != nil {
+			t.Fatalf("failed to render: %s", err)
+		}
+		_ = file.Close()
+
+		if strings.Contains(buf.String(), "<script>") || buf.String() != src {
+			t.Fatalf("expected URL to be left untouched, got %q", buf.String())
+		}
+	}
+
+	if n := evilHits.Load(); n != 0 {
+		t.Fatalf("expected endpoint on other host to not be requested, got %d requests", n)
+	}
+	if n := pageHits.Load(); n != 1 {
+		t.Fatalf("expected failed lookup to be cached, got %d page requests", n)
+	}
+}
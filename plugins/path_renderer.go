@@ -0,0 +1,194 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path"
+	"strings"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pathRendererName = "blogo-path-renderer"
+
+// Metadata key that [NewPathRenderer] uses to get the path of the file, which is
+// set by the frontmatter plugin.
+const pathRendererPathKey = "frontmatter.path"
+
+// A pattern of paths and the renderer used for the files that match it.
+type PathRoute struct {
+	// Pattern of the paths, using the syntax of [path.Match] on each segment, with
+	// "**" matching any number of segments, such as "docs/**" or "notes/*.md".
+	// Leading slashes are ignored.
+	Pattern  string
+	Renderer plugin.Renderer
+}
+
+// Creates a [plugin.Renderer] that renders each file with the renderer of the
+// first route which pattern matches it's path, so sections of the blog can use
+// different pipelines (such as a docs layout with a sidebar on "docs/**"), without
+// writing a custom renderer. Files that don't match any route are rendered with
+// PathRendererOpts.Default, or return a error if there isn't one, so this can
+// be used on a [MultiRenderer].
+//
+// The path is the one on the metadata of the file set by the frontmatter plugin,
+// or the path being served (see [core.PathFromContext]) for other files.
+func NewPathRenderer(routes []PathRoute, opts ...PathRendererOpts) plugin.Renderer {
+	opt := PathRendererOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	for _, r := range routes {
+		opt.Assertions.NotNil(r.Renderer, "Renderer of route should not be nil")
+	}
+
+	return &pathRenderer{
+		routes: routes,
+		def:    opt.Default,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type PathRendererOpts struct {
+	// Renderer of files that don't match any route.
+	Default plugin.Renderer
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type pathRenderer struct {
+	routes []PathRoute
+	def    plugin.Renderer
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (r *pathRenderer) Name() string {
+	return pathRendererName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (r *pathRenderer) SetLogger(logger *slog.Logger) {
+	if r.injectLogger {
+		r.log = logger
+	}
+}
+
+func (r *pathRenderer) Render(src fs.File, w io.Writer) error {
+	return r.RenderContext(context.Background(), src, w)
+}
+
+func (r *pathRenderer) RenderContext(ctx context.Context, src fs.File, w io.Writer) error {
+	r.assert.NotNil(src)
+	r.assert.NotNil(w)
+	r.assert.NotNil(r.log)
+
+	p, err := metadata.GetTyped[string](getMetadataOrEmpty(src), pathRendererPathKey)
+	if err != nil {
+		p, _ = core.PathFromContext(ctx)
+	}
+	p = strings.Trim(p, "/")
+
+	log := r.log.With(slog.String("path", p))
+
+	for _, route := range r.routes {
+		if !MatchPath(route.Pattern, p) {
+			continue
+		}
+
+		log.Debug("Rendering with renderer of route",
+			slog.String("pattern", route.Pattern), slog.String("renderer", route.Renderer.Name()))
+
+		return plugin.RenderContext(ctx, route.Renderer, src, w)
+	}
+
+	if r.def == nil {
+		return fmt.Errorf("no route matches path %q", p)
+	}
+
+	log.Debug("No route matches path, rendering with default renderer")
+
+	return plugin.RenderContext(ctx, r.def, src, w)
+}
+
+// Reports if the path matches the pattern, using the syntax of [path.Match] on
+// each segment, with "**" matching any number of segments (including none), so
+// "docs/**" matches "docs", "docs/index.md" and "docs/guides/install.md".
+// Leading and trailing slashes of both are ignored.
+func MatchPath(pattern, name string) bool {
+	ps := splitPath(pattern)
+	ns := splitPath(name)
+	return matchSegments(ps, ns)
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" || p == "." {
+		return []string{}
+	}
+	return strings.Split(p, "/")
+}
+
+func matchSegments(ps, ns []string) bool {
+	for len(ps) > 0 {
+		if ps[0] == "**" {
+			rest := ps[1:]
+			for i := 0; i <= len(ns); i++ {
+				if matchSegments(rest, ns[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(ns) == 0 {
+			return false
+		}
+		ok, err := path.Match(ps[0], ns[0])
+		if err != nil || !ok {
+			return false
+		}
+
+		ps, ns = ps[1:], ns[1:]
+	}
+	return len(ns) == 0
+}
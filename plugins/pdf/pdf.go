@@ -0,0 +1,274 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pdf provides a [plugin.Middleware] that exports rendered pages as PDF
+// files on demand, when requested with the "?format=pdf" query parameter.
+//
+// The conversion from HTML to PDF is done by a [Converter], which can be a
+// embedded engine or a external program such as wkhtmltopdf or WeasyPrint
+// (see [NewCommandConverter]).
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-pdf-middleware"
+
+// Converts HTML documents into PDF documents.
+type Converter interface {
+	Convert(ctx context.Context, html io.Reader, pdf io.Writer) error
+}
+
+// Type adapter to allow the use of ordinary functions as [Converter] implementations.
+type ConverterFunc func(ctx context.Context, html io.Reader, pdf io.Writer) error
+
+func (f ConverterFunc) Convert(ctx context.Context, html io.Reader, pdf io.Writer) error {
+	return f(ctx, html, pdf)
+}
+
+// Creates a [Converter] that runs the specified program, writing the HTML on it's
+// standard input and reading the PDF from it's standard output, for example:
+//
+//	pdf.NewCommandConverter("wkhtmltopdf", "--quiet", "-", "-")
+//	pdf.NewCommandConverter("weasyprint", "-", "-")
+func NewCommandConverter(name string, args ...string) Converter {
+	return ConverterFunc(func(ctx context.Context, html io.Reader, pdf io.Writer) error {
+		var stderr bytes.Buffer
+
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Stdin = html
+		cmd.Stdout = pdf
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			return errors.Join(
+				errors.New("failed to run PDF converter: "+strings.TrimSpace(stderr.String())),
+				err,
+			)
+		}
+
+		return nil
+	})
+}
+
+type Opts struct {
+	// Name of the query parameter used to request the PDF version. Defaults to "format".
+	QueryParam string
+	// How long converted documents are cached in memory. Defaults to 1 hour,
+	// negative values disable caching.
+	CacheDuration time.Duration
+	// Max duration of a conversion. Defaults to 30 seconds.
+	Timeout time.Duration
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	converter Converter
+
+	queryParam    string
+	cacheDuration time.Duration
+	timeout       time.Duration
+
+	cache   map[string]cacheEntry
+	cacheMu sync.Mutex
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+type cacheEntry struct {
+	pdf     []byte
+	expires time.Time
+}
+
+// Creates a [plugin.Middleware] that converts the HTML response of a request to
+// PDF when the "?format=pdf" parameter is present. Responses that aren't
+// successful HTML responses are passed through untouched.
+func New(converter Converter, opts ...Opts) plugin.Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.QueryParam == "" {
+		opt.QueryParam = "format"
+	}
+	if opt.CacheDuration == 0 {
+		opt.CacheDuration = time.Hour
+	}
+	if opt.Timeout == 0 {
+		opt.Timeout = 30 * time.Second
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(converter, "PDF converter should not be nil")
+
+	return &p{
+		converter: converter,
+
+		queryParam:    opt.QueryParam,
+		cacheDuration: opt.CacheDuration,
+		timeout:       opt.Timeout,
+
+		cache: map[string]cacheEntry{},
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.converter)
+		p.assert.NotNil(p.cache)
+		p.assert.NotNil(p.log)
+
+		if r.URL.Query().Get(p.queryParam) != "pdf" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		log := p.log.With(slog.String("path", r.URL.Path))
+
+		if pdf, ok := p.cached(r.URL.Path); ok {
+			log.Debug("Serving cached PDF")
+			p.write(w, r, pdf)
+			return
+		}
+
+		rec := newRecorder()
+		next.ServeHTTP(rec, r)
+
+		t, _, _ := mime.ParseMediaType(rec.header.Get("Content-Type"))
+		if rec.status != http.StatusOK || (t != "" && t != "text/html") {
+			log.Debug("Response is not a successful HTML response, passing it through")
+			rec.flush(w)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), p.timeout)
+		defer cancel()
+
+		log.Debug("Converting response to PDF")
+
+		var pdf bytes.Buffer
+		if err := p.converter.Convert(ctx, &rec.body, &pdf); err != nil {
+			log.Error("Failed to convert response to PDF", slog.String("err", err.Error()))
+			http.Error(w, "Failed to convert page to PDF", http.StatusInternalServerError)
+			return
+		}
+
+		if p.cacheDuration > 0 {
+			p.cacheMu.Lock()
+			p.cache[r.URL.Path] = cacheEntry{
+				pdf:     pdf.Bytes(),
+				expires: time.Now().Add(p.cacheDuration),
+			}
+			p.cacheMu.Unlock()
+		}
+
+		p.write(w, r, pdf.Bytes())
+	})
+}
+
+func (p *p) cached(key string) ([]byte, bool) {
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
+
+	e, ok := p.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(p.cache, key)
+		return nil, false
+	}
+	return e.pdf, true
+}
+
+func (p *p) write(w http.ResponseWriter, r *http.Request, pdf []byte) {
+	name := strings.TrimSuffix(path.Base(r.URL.Path), path.Ext(r.URL.Path))
+	if name == "" || name == "/" || name == "." {
+		name = "index"
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{
+		"filename": name + ".pdf",
+	}))
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(pdf); err != nil {
+		p.log.Error("Failed to write PDF response", slog.String("err", err.Error()))
+	}
+}
+
+// Minimal [http.ResponseWriter] that holds the response in memory.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: http.Header{}, status: http.StatusOK}
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	return r.body.Write(p)
+}
+
+func (r *recorder) flush(w http.ResponseWriter) {
+	for k, v := range r.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(r.status)
+	_, _ = w.Write(r.body.Bytes())
+}
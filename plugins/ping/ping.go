@@ -0,0 +1,418 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ping provides a [plugin.Sourcer] wrapper that notifies search engines
+// when the content of the blog changes, using sitemap pings, the IndexNow
+// protocol (https://www.indexnow.org) and WebSub hubs (https://www.w3.org/TR/websub),
+// so feed subscribers are notified of new posts.
+package ping
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/changes"
+	"forge.capytal.company/loreddev/blogo/httpclient"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/visibility"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-ping-sourcer"
+
+// Default endpoints used to ping sitemaps, the sitemap URL is appended to them.
+var DefaultSitemapEndpoints = []string{
+	"https://www.google.com/ping?sitemap=",
+	"https://www.bing.com/ping?sitemap=",
+}
+
+// Default IndexNow endpoint, which shares the submitted URLs with all
+// participating search engines.
+const DefaultIndexNowEndpoint = "https://api.indexnow.org/indexnow"
+
+// Creates a [plugin.Sourcer] that wraps the provided sourcer, comparing the file
+// system on each call to Source with the previous one, and notifying search
+// engines of the URLs of the files that changed, and the WebSub hub of the feeds
+// of the blog.
+//
+// Notifications are sent in the background, so they don't delay the sourcing of
+// files, and failures are only logged.
+//
+// The returned plugin also implements [plugin.Middleware], serving the IndexNow
+// key file at "/<key>.txt" if a IndexNowKey is provided and the plugin is added
+// to the engine.
+func New(sourcer plugin.Sourcer, opts ...Opts) plugin.Sourcer {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.SitemapEndpoints == nil {
+		opt.SitemapEndpoints = DefaultSitemapEndpoints
+	}
+	if opt.IndexNowEndpoint == "" {
+		opt.IndexNowEndpoint = DefaultIndexNowEndpoint
+	}
+	if opt.WebSubHub != "" && opt.WebSubTopics == nil {
+		opt.WebSubTopics = []string{strings.TrimSuffix(opt.BaseURL, "/") + "/feed.xml"}
+	}
+	if opt.URL == nil {
+		base := strings.TrimSuffix(opt.BaseURL, "/")
+		opt.URL = func(p string) string {
+			return base + "/" + strings.TrimSuffix(p, path.Ext(p))
+		}
+	}
+	injectHTTPClient := opt.HTTPClient == nil
+	if opt.HTTPClient == nil {
+		opt.HTTPClient = httpclient.Default()
+	}
+	if opt.Timeout == 0 {
+		opt.Timeout = 30 * time.Second
+	}
+	if opt.Visibility == nil {
+		opt.Visibility = visibility.Default
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be wrapped should not be nil")
+
+	return &p{
+		sourcer: sourcer,
+
+		baseURL:          opt.BaseURL,
+		sitemapURL:       opt.SitemapURL,
+		sitemapEndpoints: opt.SitemapEndpoints,
+		indexNowKey:      opt.IndexNowKey,
+		indexNowEndpoint: opt.IndexNowEndpoint,
+		webSubHub:        opt.WebSubHub,
+		webSubTopics:     opt.WebSubTopics,
+		url:              opt.URL,
+		onFirstSource:    opt.NotifyOnFirstSource,
+		onChange:         opt.OnChange,
+		visibility:       opt.Visibility,
+
+		client:  opt.HTTPClient,
+		timeout: opt.Timeout,
+
+		injectHTTPClient: injectHTTPClient,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+// Options used in the construction of the ping sourcer in [New].
+type Opts struct {
+	// Base URL of the blog, used to create the URLs of the changed files and
+	// the host of IndexNow submissions.
+	BaseURL string
+
+	// URL of the sitemap of the blog. If empty, sitemap pings are disabled.
+	SitemapURL string
+	// Endpoints to ping with the sitemap URL. Defaults to [DefaultSitemapEndpoints].
+	SitemapEndpoints []string
+
+	// IndexNow API key. If empty, IndexNow submissions are disabled.
+	IndexNowKey string
+	// IndexNow endpoint to submit URLs to. Defaults to [DefaultIndexNowEndpoint].
+	IndexNowEndpoint string
+
+	// URL of the WebSub hub to notify when the blog changes, which should be the
+	// same hub linked by the feeds (see the Hub option of the feed package). If
+	// empty, WebSub notifications are disabled.
+	WebSubHub string
+	// URLs of the feeds (the WebSub topics) that the hub is notified of. Defaults
+	// to the "/feed.xml" of BaseURL.
+	WebSubTopics []string
+
+	// Function that returns the public URL of a file path. Defaults to BaseURL
+	// joined with the path without it's extension.
+	URL func(path string) string
+
+	// Notify search engines with all files on the first call to Source. By default
+	// the first call is only used as the base to compare with subsequent calls.
+	NotifyOnFirstSource bool
+
+	// Rules used to hide files from IndexNow submissions, with the
+	// [visibility.Search] target. Defaults to [visibility.Default].
+	Visibility visibility.Rules
+
+	// Called with the change set of each refresh that has changes, before
+	// notifications are sent.
+	OnChange func(changes.Set)
+
+	// Client used to send notifications. Defaults to [httpclient.Default].
+	HTTPClient *http.Client
+	// Timeout of each notification. Defaults to 30 seconds.
+	Timeout time.Duration
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	sourcer plugin.Sourcer
+
+	baseURL          string
+	sitemapURL       string
+	sitemapEndpoints []string
+	indexNowKey      string
+	indexNowEndpoint string
+	webSubHub        string
+	webSubTopics     []string
+	url              func(string) string
+	onFirstSource    bool
+	onChange         func(changes.Set)
+	visibility       visibility.Rules
+
+	client  *http.Client
+	timeout time.Duration
+
+	injectHTTPClient bool
+
+	mu       sync.Mutex
+	snapshot changes.Snapshot
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.HTTPClientAware], using the client of the engine if no
+// client was provided on construction.
+func (p *p) SetHTTPClient(client *http.Client) {
+	if p.injectHTTPClient {
+		p.client = client
+	}
+}
+
+func (p *p) Source() (fs.FS, error) {
+	p.assert.NotNil(p.sourcer)
+	p.assert.NotNil(p.url)
+	p.assert.NotNil(p.log)
+
+	fsys, err := p.sourcer.Source()
+	if err != nil {
+		return fsys, err
+	}
+
+	log := p.log.With(slog.String("sourcer", p.sourcer.Name()))
+
+	s, err := changes.Take(fsys)
+	if err != nil {
+		log.Warn("Failed to take snapshot of file system, skipping notifications",
+			slog.String("err", err.Error()))
+		return fsys, nil
+	}
+
+	p.mu.Lock()
+	old, first := p.snapshot, p.snapshot == nil
+	p.snapshot = s
+	p.mu.Unlock()
+
+	if first && !p.onFirstSource {
+		log.Debug("First snapshot of file system taken")
+		return fsys, nil
+	}
+
+	set := changes.Diff(old, s)
+	if set.Empty() {
+		log.Debug("No changes in file system")
+		return fsys, nil
+	}
+
+	if p.onChange != nil {
+		p.onChange(set)
+	}
+
+	urls := make([]string, 0, len(set.Paths()))
+	for _, f := range set.Paths() {
+		if p.visible(fsys, f) {
+			urls = append(urls, p.url(f))
+		}
+	}
+
+	log.Debug("File system changed, notifying search engines", slog.Int("urls", len(urls)))
+
+	go p.notify(urls)
+
+	return fsys, nil
+}
+
+// Reports if the file is visible on [visibility.Search], so unlisted and noindex
+// posts aren't submitted to search engines. Removed files are always visible,
+// so search engines drop them.
+func (p *p) visible(fsys fs.FS, name string) bool {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return true
+	}
+	defer f.Close()
+
+	var m metadata.Metadata = metadata.Map(map[string]any{})
+	if fm, err := metadata.GetMetadata(f); err == nil {
+		m = fm
+	}
+
+	return p.visibility.Visible(name, m, visibility.Search)
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	if p.indexNowKey == "" {
+		return next
+	}
+
+	keyPath := "/" + p.indexNowKey + ".txt"
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != keyPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte(p.indexNowKey))
+	})
+}
+
+func (p *p) notify(urls []string) {
+	p.assert.NotNil(p.client)
+	p.assert.NotNil(p.log)
+
+	if p.sitemapURL != "" {
+		for _, e := range p.sitemapEndpoints {
+			if err := p.pingSitemap(e); err != nil {
+				p.log.Warn("Failed to ping sitemap",
+					slog.String("endpoint", e), slog.String("err", err.Error()))
+			}
+		}
+	}
+
+	if p.indexNowKey != "" {
+		if err := p.submitIndexNow(urls); err != nil {
+			p.log.Warn("Failed to submit URLs to IndexNow",
+				slog.String("endpoint", p.indexNowEndpoint), slog.String("err", err.Error()))
+		}
+	}
+
+	if p.webSubHub != "" {
+		if err := p.publishWebSub(); err != nil {
+			p.log.Warn("Failed to notify WebSub hub",
+				slog.String("hub", p.webSubHub), slog.String("err", err.Error()))
+		}
+	}
+}
+
+func (p *p) pingSitemap(endpoint string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, endpoint+url.QueryEscape(p.sitemapURL), nil)
+	if err != nil {
+		return errors.Join(errors.New("failed to create request"), err)
+	}
+
+	return p.do(req)
+}
+
+func (p *p) submitIndexNow(urls []string) error {
+	u, err := url.Parse(p.baseURL)
+	if err != nil {
+		return errors.Join(errors.New("failed to parse base URL"), err)
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"host":        u.Host,
+		"key":         p.indexNowKey,
+		"keyLocation": strings.TrimSuffix(p.baseURL, "/") + "/" + p.indexNowKey + ".txt",
+		"urlList":     urls,
+	})
+	if err != nil {
+		return errors.Join(errors.New("failed to marshal IndexNow request"), err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, p.indexNowEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.Join(errors.New("failed to create request"), err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	return p.do(req)
+}
+
+// Notifies the hub that the topics were updated, using the "publish" mode
+// supported by most hubs, since the WebSub recommendation doesn't define how
+// publishers notify hubs.
+func (p *p) publishWebSub() error {
+	form := url.Values{"hub.mode": {"publish"}}
+	for _, t := range p.webSubTopics {
+		form.Add("hub.url", t)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, p.webSubHub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return errors.Join(errors.New("failed to create request"), err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return p.do(req)
+}
+
+func (p *p) do(req *http.Request) error {
+	res, err := p.client.Do(req)
+	if err != nil {
+		return errors.Join(errors.New("failed to send request"), err)
+	}
+	defer res.Body.Close()
+
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %q", res.Status)
+	}
+
+	return nil
+}
@@ -14,3 +14,14 @@
 // limitations under the License.
 
 package plugins
+
+import "forge.capytal.company/loreddev/blogo/metadata"
+
+// Gets the metadata of v, returning a empty [metadata.Map] if it doesn't have any,
+// so wrappers of files can forward the metadata of the files they wrap.
+func getMetadataOrEmpty(v any) metadata.Metadata {
+	if m, err := metadata.GetMetadata(v); err == nil {
+		return m
+	}
+	return metadata.Map(map[string]any{})
+}
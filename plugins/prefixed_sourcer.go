@@ -184,3 +184,47 @@ func (pf *prefixedSourcerFS) Open(name string) (fs.File, error) {
 
 	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
 }
+
+// Implements [fs.ReadDirFS], using the fast path of the prefixed file system
+// if it has one.
+func (pf *prefixedSourcerFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, path, err := pf.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadDir(f, path)
+}
+
+// Implements [fs.ReadFileFS], using the fast path of the prefixed file system
+// if it has one.
+func (pf *prefixedSourcerFS) ReadFile(name string) ([]byte, error) {
+	f, path, err := pf.resolve("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(f, path)
+}
+
+// Implements [fs.StatFS], using the fast path of the prefixed file system if
+// it has one.
+func (pf *prefixedSourcerFS) Stat(name string) (fs.FileInfo, error) {
+	f, path, err := pf.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Stat(f, path)
+}
+
+func (pf *prefixedSourcerFS) resolve(op, name string) (fs.FS, string, error) {
+	prefix, path, found := strings.Cut(name, pf.prefixSeparator)
+	if !found {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	f, ok := pf.fileSystems[prefix]
+	if !ok {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	return f, path, nil
+}
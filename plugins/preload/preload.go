@@ -0,0 +1,310 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package preload provides the "Link: rel=preload" headers of the critical
+// assets of pages, such as the stylesheets and fonts of the theme, and sends
+// them as Early Hints ("103 Early Hints") before the page is rendered, so
+// browsers can start loading them sooner on slow connections.
+package preload
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-preload-middleware"
+
+// A asset to preload.
+type Link struct {
+	URL string
+	// Type of the asset, such as "style", "font", "script" or "image".
+	As string
+	// Media type of the asset, such as "font/woff2".
+	Type string
+	// Whether the asset is fetched with CORS, which fonts always are.
+	CrossOrigin bool
+}
+
+// Returns the value of the link on the "Link" header.
+func (l Link) String() string {
+	s := "<" + l.URL + ">; rel=preload"
+	if l.As != "" {
+		s += "; as=" + l.As
+	}
+	if l.Type != "" {
+		s += `; type="` + l.Type + `"`
+	}
+	if l.CrossOrigin || l.As == "font" {
+		s += "; crossorigin"
+	}
+	return s
+}
+
+// Creates a [plugin.Middleware] that adds the "Link" headers of the assets to
+// preload to HTML pages.
+//
+// The assets are Opts.Links, plus the stylesheets and "<link rel=preload>"
+// elements of the head of each page, discovered when the page is first served
+// and used on the next requests of it. Early Hints are sent with the links
+// before serving the page, over HTTP/2 and later, since some HTTP/1.1 clients
+// don't support informational responses.
+func New(opts ...Opts) plugin.Middleware {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.MaxLinks == 0 {
+		opt.MaxLinks = 8
+	}
+	if opt.MaxPages == 0 {
+		opt.MaxPages = 1024
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &p{
+		links:      opt.Links,
+		discover:   !opt.DisableDiscovery,
+		earlyHints: !opt.DisableEarlyHints,
+		maxLinks:   opt.MaxLinks,
+		maxPages:   opt.MaxPages,
+
+		pages: map[string][]Link{},
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Assets preloaded on all pages, such as the fonts of the theme.
+	Links []Link
+
+	// Disables the discovery of the assets of pages, only preloading Opts.Links.
+	DisableDiscovery bool
+	// Disables Early Hints, only adding the "Link" headers to the responses.
+	DisableEarlyHints bool
+
+	// Max number of discovered assets of each page. Defaults to 8.
+	MaxLinks int
+	// Max number of pages with discovered assets kept in memory. Defaults to 1024.
+	MaxPages int
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	links      []Link
+	discover   bool
+	earlyHints bool
+	maxLinks   int
+	maxPages   int
+
+	mu    sync.RWMutex
+	pages map[string][]Link
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.log)
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p.mu.RLock()
+		discovered, known := p.pages[r.URL.Path]
+		p.mu.RUnlock()
+
+		links := merge(p.links, discovered)
+
+		// Headers of the Early Hints are kept on the final response.
+		hinted := len(links) > 0 && p.earlyHints && r.ProtoMajor >= 2
+		if hinted {
+			for _, l := range links {
+				w.Header().Add("Link", l.String())
+			}
+			w.WriteHeader(http.StatusEarlyHints)
+		}
+
+		pw := &preloadWriter{ResponseWriter: w, sniff: p.discover && !known}
+		if !hinted {
+			pw.links = links
+		}
+		next.ServeHTTP(pw, r)
+
+		if !pw.sniff || !pw.html {
+			return
+		}
+
+		found := parseLinks(pw.buf.Bytes(), p.maxLinks)
+
+		p.mu.Lock()
+		if len(p.pages) < p.maxPages {
+			p.pages[r.URL.Path] = found
+		}
+		p.mu.Unlock()
+
+		p.log.Debug("Discovered assets of page",
+			slog.String("path", r.URL.Path), slog.Int("links", len(found)))
+	})
+}
+
+// Returns the links, followed by the discovered ones that aren't on them.
+func merge(links, discovered []Link) []Link {
+	merged := slices.Clone(links)
+	for _, d := range discovered {
+		if !slices.ContainsFunc(merged, func(l Link) bool { return l.URL == d.URL }) {
+			merged = append(merged, d)
+		}
+	}
+	return merged
+}
+
+// Max size of the start of pages read to discover their assets.
+const maxSniff = 64 << 10
+
+// Adds the "Link" headers of the links to successful HTML responses, keeping the
+// start of their body to discover the assets of the page, if sniff is true.
+type preloadWriter struct {
+	http.ResponseWriter
+	links []Link
+	sniff bool
+
+	header bool
+	html   bool
+	buf    bytes.Buffer
+}
+
+func (w *preloadWriter) WriteHeader(status int) {
+	if w.header || status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.header = true
+
+	h := w.Header()
+	w.html = status == http.StatusOK &&
+		strings.HasPrefix(h.Get("Content-Type"), "text/html") &&
+		h.Get("Content-Encoding") == ""
+
+	if w.html {
+		for _, l := range w.links {
+			h.Add("Link", l.String())
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *preloadWriter) Write(b []byte) (int, error) {
+	if !w.header {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.sniff && w.html && w.buf.Len() < maxSniff {
+		w.buf.Write(b[:min(len(b), maxSniff-w.buf.Len())])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+var (
+	linkTag    = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	linkAttr   = regexp.MustCompile(`(?is)([a-z-]+)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+))?`)
+	headEndTag = regexp.MustCompile(`(?i)</head\s*>|<body\b`)
+)
+
+// Returns the stylesheets and preloaded assets of the head of the page.
+func parseLinks(page []byte, limit int) []Link {
+	if loc := headEndTag.FindIndex(page); loc != nil {
+		page = page[:loc[0]]
+	}
+
+	links := []Link{}
+	for _, tag := range linkTag.FindAll(page, -1) {
+		if len(links) >= limit {
+			break
+		}
+
+		attrs := map[string]string{}
+		for _, m := range linkAttr.FindAllSubmatch(tag[len("<link"):], -1) {
+			attrs[strings.ToLower(string(m[1]))] = html.UnescapeString(strings.Trim(string(m[2]), `"'`))
+		}
+
+		href := attrs["href"]
+		if href == "" || strings.HasPrefix(href, "data:") {
+			continue
+		}
+
+		rels := strings.Fields(strings.ToLower(attrs["rel"]))
+		_, cors := attrs["crossorigin"]
+
+		var l Link
+		switch {
+		case slices.Contains(rels, "preload"):
+			l = Link{URL: href, As: attrs["as"], Type: attrs["type"], CrossOrigin: cors}
+		case slices.Contains(rels, "stylesheet") && !slices.Contains(rels, "alternate"):
+			l = Link{URL: href, As: "style", CrossOrigin: cors}
+		default:
+			continue
+		}
+
+		if !slices.ContainsFunc(links, func(e Link) bool { return e.URL == l.URL }) {
+			links = append(links, l)
+		}
+	}
+
+	return links
+}
@@ -0,0 +1,548 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package qr
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+// Error correction level of a QR code, the higher the level, the more damage
+// the code can sustain while still being readable, at the cost of capacity.
+type Level int
+
+const (
+	// Recovers around 7% of the data.
+	LevelL Level = iota
+	// Recovers around 15% of the data.
+	LevelM
+	// Recovers around 25% of the data.
+	LevelQ
+	// Recovers around 30% of the data.
+	LevelH
+)
+
+// Returned when the data doesn't fit on the largest supported QR code version.
+var ErrTooLong = errors.New("data too long to be encoded as a QR code")
+
+// Largest QR code version supported by [Encode].
+const MaxVersion = 10
+
+// Error correction blocks of each version and level: the number of error
+// correction codewords per block, followed by pairs of the number of blocks
+// and data codewords per block of each group.
+var blocks = [MaxVersion + 1][4][5]int{
+	{},
+	{{7, 1, 19}, {10, 1, 16}, {13, 1, 13}, {17, 1, 9}},
+	{{10, 1, 34}, {16, 1, 28}, {22, 1, 22}, {28, 1, 16}},
+	{{15, 1, 55}, {26, 1, 44}, {18, 2, 17}, {22, 2, 13}},
+	{{20, 1, 80}, {18, 2, 32}, {26, 2, 24}, {16, 4, 9}},
+	{{26, 1, 108}, {24, 2, 43}, {18, 2, 15, 2, 16}, {22, 2, 11, 2, 12}},
+	{{18, 2, 68}, {16, 4, 27}, {24, 4, 19}, {28, 4, 15}},
+	{{20, 2, 78}, {18, 4, 31}, {18, 2, 14, 4, 15}, {26, 4, 13, 1, 14}},
+	{{24, 2, 97}, {22, 2, 38, 2, 39}, {22, 4, 18, 2, 19}, {26, 4, 14, 2, 15}},
+	{{30, 2, 116}, {22, 3, 36, 2, 37}, {20, 4, 16, 4, 17}, {24, 4, 12, 4, 13}},
+	{{18, 2, 68, 2, 69}, {26, 4, 43, 1, 44}, {24, 6, 19, 2, 20}, {28, 6, 15, 2, 16}},
+}
+
+var alignment = [MaxVersion + 1][]int{
+	{}, {}, {6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34},
+	{6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+}
+
+// Format information bits of each level.
+var levelBits = [4]int{LevelL: 1, LevelM: 0, LevelQ: 3, LevelH: 2}
+
+// A encoded QR code.
+type Code struct {
+	// Number of modules on each side of the code.
+	Size    int
+	modules [][]bool
+}
+
+// Reports if the module at the column x and row y is dark.
+func (c *Code) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y][x]
+}
+
+// Creates a grayscale image of the code, with each module being scale pixels
+// wide, surrounded by a quiet zone of border modules.
+func (c *Code) Image(scale, border int) image.Image {
+	scale = max(scale, 1)
+	border = max(border, 0)
+
+	size := (c.Size + border*2) * scale
+	img := image.NewGray(image.Rect(0, 0, size, size))
+
+	for py := 0; py < size; py++ {
+		for px := 0; px < size; px++ {
+			v := color.Gray{Y: 255}
+			if c.Dark(px/scale-border, py/scale-border) {
+				v = color.Gray{Y: 0}
+			}
+			img.SetGray(px, py, v)
+		}
+	}
+
+	return img
+}
+
+// Encodes the data in byte mode, on the smallest QR code version that can hold
+// it with the error correction level, selecting the mask with the lowest penalty.
+func Encode(data []byte, level Level) (*Code, error) {
+	version := 0
+	for v := 1; v <= MaxVersion; v++ {
+		if 4+countBits(v)+len(data)*8 <= dataCodewords(v, level)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	codewords := interleave(version, level, dataBits(version, level, data))
+
+	var best *Code
+	bestPenalty := -1
+	for mask := 0; mask < 8; mask++ {
+		c := newCode(version)
+		c.drawFunctionPatterns(version)
+		c.drawCodewords(codewords)
+		c.applyMask(mask)
+		c.drawFormatBits(level, mask)
+
+		if p := c.penalty(); bestPenalty == -1 || p < bestPenalty {
+			best, bestPenalty = c.Code, p
+		}
+	}
+
+	return best, nil
+}
+
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+func dataCodewords(version int, level Level) int {
+	b := blocks[version][level]
+	n := b[1] * b[2]
+	if len(b) > 3 {
+		n += b[3] * b[4]
+	}
+	return n
+}
+
+func dataBits(version int, level Level, data []byte) []byte {
+	capacity := dataCodewords(version, level)
+
+	var bb bitBuffer
+	bb.append(0b0100, 4)
+	bb.append(len(data), countBits(version))
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+
+	bb.append(0, min(4, capacity*8-bb.len))
+	bb.append(0, (8-bb.len%8)%8)
+
+	for pad := 0; bb.len < capacity*8; pad++ {
+		if pad%2 == 0 {
+			bb.append(0xEC, 8)
+		} else {
+			bb.append(0x11, 8)
+		}
+	}
+
+	return bb.bytes
+}
+
+// Splits the data codewords into blocks, computes the error correction of each
+// block and interleaves all of them as specified.
+func interleave(version int, level Level, data []byte) []byte {
+	b := blocks[version][level]
+	ecLen := b[0]
+
+	groups := [][2]int{{b[1], b[2]}}
+	if len(b) > 3 && b[3] > 0 {
+		groups = append(groups, [2]int{b[3], b[4]})
+	}
+
+	dataBlocks, ecBlocks := [][]byte{}, [][]byte{}
+	divisor := rsDivisor(ecLen)
+	maxLen := 0
+
+	i := 0
+	for _, g := range groups {
+		for range g[0] {
+			block := data[i : i+g[1]]
+			i += g[1]
+
+			dataBlocks = append(dataBlocks, block)
+			ecBlocks = append(ecBlocks, rsRemainder(block, divisor))
+			maxLen = max(maxLen, len(block))
+		}
+	}
+
+	result := make([]byte, 0, len(data)+ecLen*len(ecBlocks))
+	for i := 0; i < maxLen; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < ecLen; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+
+	return result
+}
+
+type bitBuffer struct {
+	bytes []byte
+	len   int
+}
+
+func (bb *bitBuffer) append(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if bb.len%8 == 0 {
+			bb.bytes = append(bb.bytes, 0)
+		}
+		if (v>>i)&1 == 1 {
+			bb.bytes[bb.len/8] |= 0x80 >> (bb.len % 8)
+		}
+		bb.len++
+	}
+}
+
+// Code being constructed, which also tracks what modules are function patterns
+// so they aren't overwritten by data or masks.
+type builder struct {
+	*Code
+	function [][]bool
+}
+
+func newCode(version int) *builder {
+	size := version*4 + 17
+
+	modules, function := make([][]bool, size), make([][]bool, size)
+	for i := range modules {
+		modules[i], function[i] = make([]bool, size), make([]bool, size)
+	}
+
+	return &builder{Code: &Code{Size: size, modules: modules}, function: function}
+}
+
+func (c *builder) set(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+func (c *builder) drawFunctionPatterns(version int) {
+	for i := 0; i < c.Size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+
+	pos := alignment[version]
+	last := len(pos) - 1
+	for i, x := range pos {
+		for j, y := range pos {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			c.drawAlignment(x, y)
+		}
+	}
+
+	// Reserves the format information areas, which are drawn after masking.
+	c.drawFormatBits(LevelL, 0)
+
+	if version >= 7 {
+		rem := version
+		for range 12 {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := version<<12 | rem
+
+		for i := 0; i < 18; i++ {
+			dark := (bits>>i)&1 == 1
+			a, b := c.Size-11+i%3, i/3
+			c.set(a, b, dark)
+			c.set(b, a, dark)
+		}
+	}
+}
+
+func (c *builder) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			c.set(x, y, d != 2 && d != 4)
+		}
+	}
+}
+
+func (c *builder) drawAlignment(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+func (c *builder) drawFormatBits(level Level, mask int) {
+	data := levelBits[level]<<3 | mask
+	rem := data
+	for range 10 {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true)
+}
+
+func (c *builder) drawCodewords(codewords []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if c.function[y][x] {
+					continue
+				}
+				if i < len(codewords)*8 {
+					c.modules[y][x] = (codewords[i/8]>>(7-i%8))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (c *builder) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.function[y][x] {
+				continue
+			}
+
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+
+			c.modules[y][x] = c.modules[y][x] != invert
+		}
+	}
+}
+
+var finderLike = [2][11]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// Computes the penalty score of the code, used to select the best mask.
+func (c *builder) penalty() int {
+	penalty, dark := 0, 0
+
+	for i := 0; i < c.Size; i++ {
+		for _, horizontal := range []bool{true, false} {
+			at := func(j int) bool {
+				if horizontal {
+					return c.modules[i][j]
+				}
+				return c.modules[j][i]
+			}
+
+			run := 1
+			for j := 1; j < c.Size; j++ {
+				if at(j) == at(j-1) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					penalty += run - 2
+				}
+				run = 1
+			}
+			if run >= 5 {
+				penalty += run - 2
+			}
+
+			for j := 0; j+11 <= c.Size; j++ {
+				for _, pattern := range finderLike {
+					match := true
+					for k, v := range pattern {
+						if at(j+k) != v {
+							match = false
+							break
+						}
+					}
+					if match {
+						penalty += 40
+					}
+				}
+			}
+		}
+	}
+
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x+1 < c.Size && y+1 < c.Size {
+				v := c.modules[y][x]
+				if c.modules[y][x+1] == v && c.modules[y+1][x] == v && c.modules[y+1][x+1] == v {
+					penalty += 3
+				}
+			}
+		}
+	}
+
+	total := c.Size * c.Size
+	penalty += abs(dark*100/total-50) / 5 * 10
+
+	return penalty
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+var gfExp, gfLog = func() ([512]byte, [256]byte) {
+	var exp [512]byte
+	var log [256]byte
+
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(x)
+		log[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	for i := 255; i < 512; i++ {
+		exp[i] = exp[i-255]
+	}
+
+	return exp, log
+}()
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+// Returns the coefficients of the Reed-Solomon generator polynomial of the
+// degree, from the highest to the lowest, without the leading term.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+
+	root := byte(1)
+	for range degree {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+
+	return result
+}
+
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMul(d, factor)
+		}
+	}
+
+	return result
+}
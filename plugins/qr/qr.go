@@ -0,0 +1,202 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package qr provides a [plugin.Middleware] that serves QR codes of the canonical
+// URL of posts, such as "/qr/posts/hello.png", useful for slides and printed
+// material. The size of the image can be set with the "size" query parameter.
+//
+// The package also provides a dependency-free QR code encoder, see [Encode].
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-qr-middleware"
+
+// Creates a [plugin.Middleware] that serves PNG images of QR codes on
+// "Opts.Prefix + slug + .png", encoding the URL "Opts.BaseURL + / + slug".
+//
+// Generated images are cached in memory.
+func New(opts ...Opts) plugin.Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Prefix == "" {
+		opt.Prefix = "/qr/"
+	}
+	if opt.DefaultSize == 0 {
+		opt.DefaultSize = 256
+	}
+	if opt.MaxSize == 0 {
+		opt.MaxSize = 2048
+	}
+	if opt.MaxCacheEntries == 0 {
+		opt.MaxCacheEntries = 1024
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &p{
+		prefix:     "/" + strings.Trim(opt.Prefix, "/") + "/",
+		baseURL:    strings.TrimSuffix(opt.BaseURL, "/"),
+		level:      opt.Level,
+		size:       opt.DefaultSize,
+		maxSize:    opt.MaxSize,
+		maxEntries: opt.MaxCacheEntries,
+
+		cache: map[string][]byte{},
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Path prefix of the QR code images. Defaults to "/qr/".
+	Prefix string
+	// Base URL of the canonical URLs encoded, for example "https://example.com".
+	BaseURL string
+	// Error correction level of the codes. Defaults to [LevelL].
+	Level Level
+
+	// Size in pixels of the images if the "size" query parameter isn't present.
+	// Defaults to 256.
+	DefaultSize int
+	// Max size in pixels that can be requested. Defaults to 2048.
+	MaxSize int
+	// Max number of images cached. When reached, the cache is cleared.
+	// Defaults to 1024.
+	MaxCacheEntries int
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	prefix     string
+	baseURL    string
+	level      Level
+	size       int
+	maxSize    int
+	maxEntries int
+
+	cacheMu sync.Mutex
+	cache   map[string][]byte
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.cache)
+		p.assert.NotNil(p.log)
+
+		slug, ok := strings.CutPrefix(r.URL.Path, p.prefix)
+		if !ok || !strings.HasSuffix(slug, ".png") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		slug = strings.TrimSuffix(slug, ".png")
+		if slug == "index" {
+			slug = ""
+		}
+
+		size := p.size
+		if s := r.URL.Query().Get("size"); s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil || v <= 0 {
+				http.Error(w, "Invalid size parameter", http.StatusBadRequest)
+				return
+			}
+			size = min(v, p.maxSize)
+		}
+
+		url := p.baseURL + "/" + slug
+		key := fmt.Sprintf("%d %s", size, url)
+
+		log := p.log.With(slog.String("url", url), slog.Int("size", size))
+
+		p.cacheMu.Lock()
+		img, ok := p.cache[key]
+		p.cacheMu.Unlock()
+
+		if !ok {
+			log.Debug("Generating QR code")
+
+			var err error
+			img, err = p.generate(url, size)
+			if err != nil {
+				log.Error("Failed to generate QR code", slog.String("err", err.Error()))
+				http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
+				return
+			}
+
+			p.cacheMu.Lock()
+			if len(p.cache) >= p.maxEntries {
+				p.cache = map[string][]byte{}
+			}
+			p.cache[key] = img
+			p.cacheMu.Unlock()
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		w.Header().Set("Content-Length", strconv.Itoa(len(img)))
+		if _, err := w.Write(img); err != nil {
+			log.Error("Failed to write QR code", slog.String("err", err.Error()))
+		}
+	})
+}
+
+func (p *p) generate(url string, size int) ([]byte, error) {
+	code, err := Encode([]byte(url), p.level)
+	if err != nil {
+		return nil, err
+	}
+
+	const border = 4
+	scale := max(size/(code.Size+border*2), 1)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, code.Image(scale, border)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,251 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package raw provides the download of the unrendered source of files, such as
+// the Markdown of posts with their frontmatter, at "/raw/<path>", so authors can
+// fetch the originals without cloning the content repository.
+//
+// Downloads are always guarded by a [auth.Authenticator], since sources may
+// contain drafts and other content that isn't published.
+package raw
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"forge.capytal.company/loreddev/blogo/auth"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-raw-sourcer"
+
+// Extensions of files served as "text/plain", so browsers show them instead of
+// downloading them or rendering them as HTML.
+var DefaultTextExtensions = []string{".md", ".markdown", ".txt", ".yml", ".yaml", ".json", ".toml", ".html"}
+
+// The raw source plugin, which wraps a [plugin.Sourcer] to keep it's last file
+// system and is a [plugin.Middleware] serving the downloads.
+type Plugin interface {
+	plugin.Sourcer
+	plugin.Middleware
+}
+
+// Creates the raw source [Plugin], wrapping the sourcer. Requests to
+// "Opts.Prefix + path" are authenticated by Opts.Authenticator, responding
+// with the file at path on the sourced file system. If no authenticator is
+// provided, all downloads are rejected.
+//
+// Adding "?download" to the URL serves the file as a attachment.
+func New(sourcer plugin.Sourcer, opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Prefix == "" {
+		opt.Prefix = "/raw/"
+	}
+	if opt.TextExtensions == nil {
+		opt.TextExtensions = DefaultTextExtensions
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be wrapped should not be nil")
+
+	p := &p{
+		sourcer: sourcer,
+
+		prefix:         "/" + strings.Trim(opt.Prefix, "/") + "/",
+		textExtensions: opt.TextExtensions,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+
+	p.handler = auth.Require(opt.Authenticator, http.HandlerFunc(p.serve), auth.RequireOpts{
+		Scopes:     opt.Scopes,
+		Assertions: opt.Assertions,
+		Logger:     opt.Logger,
+	})
+
+	return p
+}
+
+type Opts struct {
+	// Path prefix of downloads. Defaults to "/raw/".
+	Prefix string
+	// Authenticator of download requests. If nil, all downloads are rejected.
+	Authenticator auth.Authenticator
+	// Scopes that the identity needs to have to download files.
+	Scopes []string
+	// Extensions of files served as "text/plain". Defaults to [DefaultTextExtensions].
+	TextExtensions []string
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	sourcer plugin.Sourcer
+
+	prefix         string
+	textExtensions []string
+	handler        http.Handler
+
+	mu   sync.RWMutex
+	fsys fs.FS
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+func (p *p) Source() (fs.FS, error) {
+	p.assert.NotNil(p.sourcer)
+	p.assert.NotNil(p.log)
+
+	fsys, err := p.sourcer.Source()
+	if err != nil {
+		return fsys, err
+	}
+
+	p.mu.Lock()
+	p.fsys = fsys
+	p.mu.Unlock()
+
+	return fsys, nil
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, p.prefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		p.handler.ServeHTTP(w, r)
+	})
+}
+
+func (p *p) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, p.prefix), "/")
+	log := p.log.With(slog.String("file", name))
+
+	if !fs.ValidPath(name) || name == "." || name == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	fsys, err := p.files()
+	if err != nil {
+		log.Error("Failed to source files", slog.String("err", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	f, err := fsys.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Error("Failed to open file", slog.String("err", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		log.Error("Failed to stat file", slog.String("err", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if stat.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			log.Error("Failed to read file", slog.String("err", err.Error()))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(b)
+	}
+
+	w.Header().Set("Content-Type", p.contentType(name))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if r.URL.Query().Has("download") {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": path.Base(name),
+		}))
+	}
+
+	if id, ok := auth.FromContext(r.Context()); ok {
+		log.Debug("Serving raw file", slog.String("subject", id.Subject))
+	}
+
+	http.ServeContent(w, r, path.Base(name), stat.ModTime(), content)
+}
+
+func (p *p) files() (fs.FS, error) {
+	p.mu.RLock()
+	fsys := p.fsys
+	p.mu.RUnlock()
+
+	if fsys != nil {
+		return fsys, nil
+	}
+	return p.Source()
+}
+
+func (p *p) contentType(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range p.textExtensions {
+		if e == ext {
+			return "text/plain; charset=utf-8"
+		}
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,270 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package reactions provides a lightweight likes and reactions endpoint for
+// posts, with a pluggable [Store] and rate limiting, plus template helpers to
+// render the reaction counts and buttons without any third-party services.
+package reactions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/ratelimit"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-reactions-middleware"
+
+// A reaction that visitors can add to posts.
+type Reaction struct {
+	// Name of the reaction, used as it's key on the [Store].
+	Name string
+	// Label shown on the reaction button, such as a emoji.
+	Label string
+}
+
+// Default reactions available if none are provided in [Opts].
+var DefaultReactions = []Reaction{
+	{Name: "like", Label: "👍"},
+	{Name: "love", Label: "❤️"},
+	{Name: "celebrate", Label: "🎉"},
+}
+
+// The reactions plugin, which is a [plugin.Middleware] serving the endpoint and
+// provides template functions to render reactions on posts.
+type Plugin interface {
+	plugin.Middleware
+	// Returns the template functions:
+	//
+	//   - "reactions PATH" returns the counts of each reaction of the path;
+	//   - "reactionButtons PATH" renders a form with a button for each reaction,
+	//     which works without JavaScript.
+	FuncMap() template.FuncMap
+}
+
+// Creates the reactions [Plugin], serving the endpoint on Opts.Path:
+//
+//   - GET "?path=PATH" returns the reaction counts of the path as JSON;
+//   - POST with the "path" and "reaction" form values adds a reaction, redirecting
+//     back to the page, or returning the new counts as JSON if the request accepts
+//     "application/json".
+func New(store Store, opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Path == "" {
+		opt.Path = "/api/react"
+	}
+	if opt.Reactions == nil {
+		opt.Reactions = DefaultReactions
+	}
+	if opt.Limiter == nil {
+		opt.Limiter = ratelimit.NewFixedWindow(10, time.Hour)
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(store, "Reactions store should not be nil")
+
+	return &p{
+		store:     store,
+		path:      opt.Path,
+		reactions: opt.Reactions,
+		limiter:   opt.Limiter,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Path of the endpoint. Defaults to "/api/react".
+	Path string
+	// Reactions that can be added. Defaults to [DefaultReactions].
+	Reactions []Reaction
+	// Limits reactions by client IP and path. Defaults to 10 reactions per hour.
+	Limiter ratelimit.Limiter
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	store     Store
+	path      string
+	reactions []Reaction
+	limiter   ratelimit.Limiter
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.store)
+		p.assert.NotNil(p.limiter)
+		p.assert.NotNil(p.log)
+
+		if r.URL.Path != p.path {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			p.serveCounts(w, r, r.URL.Query().Get("path"))
+		case http.MethodPost:
+			p.serveReact(w, r)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func (p *p) serveReact(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 4<<10)
+
+	path, reaction := r.PostFormValue("path"), r.PostFormValue("reaction")
+	if path == "" {
+		http.Error(w, "Missing path value", http.StatusBadRequest)
+		return
+	}
+	if !slices.ContainsFunc(p.reactions, func(re Reaction) bool { return re.Name == reaction }) {
+		http.Error(w, "Unknown reaction", http.StatusBadRequest)
+		return
+	}
+
+	log := p.log.With(slog.String("path", path), slog.String("reaction", reaction))
+
+	if !p.limiter.Allow(ratelimit.ClientIP(r) + " " + path) {
+		log.Debug("Reaction rate limited")
+		core.Reject(w, r, core.RejectError{
+			Status: http.StatusTooManyRequests,
+			Reason: "Too many reactions, try again later",
+		})
+		return
+	}
+
+	if err := p.store.Add(r.Context(), path, reaction); err != nil {
+		log.Error("Failed to add reaction", slog.String("err", err.Error()))
+		http.Error(w, "Failed to add reaction", http.StatusInternalServerError)
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		p.serveCounts(w, r, path)
+		return
+	}
+
+	// Only redirect to pages of the same host, to not be used as a open redirect.
+	back := "/" + strings.TrimPrefix(path, "/")
+	if ref, err := url.Parse(r.Referer()); err == nil && ref.Host == r.Host {
+		back = ref.RequestURI()
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+func (p *p) serveCounts(w http.ResponseWriter, r *http.Request, path string) {
+	if path == "" {
+		http.Error(w, "Missing path parameter", http.StatusBadRequest)
+		return
+	}
+
+	counts, err := p.store.Counts(r.Context(), path)
+	if err != nil {
+		p.log.Error("Failed to get reaction counts",
+			slog.String("path", path), slog.String("err", err.Error()))
+		http.Error(w, "Failed to get reaction counts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]any{"path": path, "reactions": counts})
+	if err != nil {
+		p.log.Error("Failed to write reaction counts", slog.String("err", err.Error()))
+	}
+}
+
+var buttonsTemplate = template.Must(template.New("reactions").Parse(
+	`<form class="reactions" method="post" action="{{.Action}}">` +
+		`<input type="hidden" name="path" value="{{.Path}}">` +
+		`{{range .Reactions}}` +
+		`<button type="submit" name="reaction" value="{{.Name}}" title="{{.Name}}">` +
+		`{{.Label}} <span class="reaction-count">{{.Count}}</span></button>` +
+		`{{end}}</form>`,
+))
+
+func (p *p) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"reactions": func(path string) map[string]int64 {
+			counts, err := p.store.Counts(context.Background(), path)
+			if err != nil {
+				return map[string]int64{}
+			}
+			return counts
+		},
+		"reactionButtons": func(path string) (template.HTML, error) {
+			counts, err := p.store.Counts(context.Background(), path)
+			if err != nil {
+				counts = map[string]int64{}
+			}
+
+			type button struct {
+				Reaction
+				Count int64
+			}
+			bs := make([]button, len(p.reactions))
+			for i, re := range p.reactions {
+				bs[i] = button{Reaction: re, Count: counts[re.Name]}
+			}
+
+			var b strings.Builder
+			err = buttonsTemplate.Execute(&b, map[string]any{
+				"Action":    p.path,
+				"Path":      path,
+				"Reactions": bs,
+			})
+			if err != nil {
+				return "", fmt.Errorf("failed to render reaction buttons: %w", err)
+			}
+
+			return template.HTML(b.String()), nil
+		},
+	}
+}
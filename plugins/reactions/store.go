@@ -0,0 +1,183 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reactions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"sync"
+
+	"forge.capytal.company/loreddev/blogo/kv"
+)
+
+// Storage of reaction counts.
+type Store interface {
+	// Increments the count of the reaction on the path.
+	Add(ctx context.Context, path, reaction string) error
+	// Returns the count of each reaction of the path.
+	Counts(ctx context.Context, path string) (map[string]int64, error)
+}
+
+// Creates a [Store] that holds the counts in memory, which are lost on restarts.
+func NewMemoryStore() Store {
+	return &memoryStore{counts: map[string]map[string]int64{}}
+}
+
+type memoryStore struct {
+	mu     sync.RWMutex
+	counts map[string]map[string]int64
+}
+
+func (s *memoryStore) Add(ctx context.Context, path, reaction string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.counts[path]; !ok {
+		s.counts[path] = map[string]int64{}
+	}
+	s.counts[path][reaction]++
+
+	return nil
+}
+
+func (s *memoryStore) Counts(ctx context.Context, path string) (map[string]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := maps.Clone(s.counts[path])
+	if counts == nil {
+		counts = map[string]int64{}
+	}
+
+	return counts, nil
+}
+
+// Creates a [Store] that persists the counts on a SQL database, such as SQLite,
+// creating and migrating the table with [kv.MigrateSQL]. The table name
+// defaults to "blogo_reactions" if empty.
+func NewSQLStore(db *sql.DB, table ...string) Store {
+	t := "blogo_reactions"
+	if len(table) > 0 && table[0] != "" {
+		t = table[0]
+	}
+	return &sqlStore{db: db, table: t}
+}
+
+type sqlStore struct {
+	db    *sql.DB
+	table string
+	once  sync.Once
+	err   error
+}
+
+func (s *sqlStore) init(ctx context.Context) error {
+	s.once.Do(func() {
+		err := kv.MigrateSQL(ctx, s.db, s.table, []kv.SQLMigration{
+			kv.SQLStatement(1, "create table", s.table, "CREATE TABLE IF NOT EXISTS {table} ("+
+				"path TEXT NOT NULL, reaction TEXT NOT NULL, count INTEGER NOT NULL DEFAULT 0, "+
+				"PRIMARY KEY (path, reaction))"),
+		})
+		if err != nil {
+			s.err = errors.Join(errors.New("failed to migrate reactions table"), err)
+		}
+	})
+	return s.err
+}
+
+func (s *sqlStore) Add(ctx context.Context, path, reaction string) error {
+	if err := s.init(ctx); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (path, reaction, count) VALUES (?, ?, 1) "+
+			"ON CONFLICT(path, reaction) DO UPDATE SET count = count + 1",
+		s.table,
+	), path, reaction)
+	if err != nil {
+		return errors.Join(errors.New("failed to add reaction"), err)
+	}
+
+	return nil
+}
+
+func (s *sqlStore) Counts(ctx context.Context, path string) (map[string]int64, error) {
+	if err := s.init(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT reaction, count FROM %s WHERE path = ?", s.table,
+	), path)
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to query reactions"), err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int64{}
+	for rows.Next() {
+		var reaction string
+		var count int64
+		if err := rows.Scan(&reaction, &count); err != nil {
+			return nil, errors.Join(errors.New("failed to scan reactions"), err)
+		}
+		counts[reaction] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// Creates a [Store] that persists the counts on the key-value store, with the
+// keys "reactions:PATH#REACTION".
+func NewKVStore(s kv.Store) Store {
+	return &kvStore{store: s}
+}
+
+type kvStore struct {
+	store kv.Store
+}
+
+func (s *kvStore) Add(ctx context.Context, path, reaction string) error {
+	if _, err := s.store.Incr(ctx, "reactions:"+path+"#"+reaction, 1); err != nil {
+		return errors.Join(errors.New("failed to add reaction"), err)
+	}
+	return nil
+}
+
+func (s *kvStore) Counts(ctx context.Context, path string) (map[string]int64, error) {
+	prefix := "reactions:" + path + "#"
+
+	entries, err := s.store.List(ctx, prefix, 0)
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to list reactions"), err)
+	}
+
+	counts := map[string]int64{}
+	for _, e := range entries {
+		n, err := strconv.ParseInt(string(e.Value), 10, 64)
+		if err != nil {
+			continue
+		}
+		counts[strings.TrimPrefix(e.Key, prefix)] = n
+	}
+
+	return counts, nil
+}
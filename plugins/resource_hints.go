@@ -0,0 +1,292 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const resourceHintsName = "blogo-resourcehints-renderer"
+
+// Creates a [plugin.Renderer] that transforms already rendered HTML, adding
+// "preconnect" and "dns-prefetch" link tags for the third-party origins that the
+// page loads resources from, such as embeds, scripts, images and fonts, so
+// browsers connect to them sooner.
+//
+// Origins are found on the "src", "srcset", "poster" and "data" attributes of
+// elements and on the "href" of link tags, in the order they appear. The first
+// [ResourceHintsOpts].MaxPreconnect origins are preconnected and the rest only
+// have their DNS prefetched, since too many connections compete with the page
+// itself. Origins that the page already hints are skipped.
+//
+// Hints are added after the "<head>" tag, or at the start of pages without one,
+// where they are also allowed, so it can be used before or after the layout.
+//
+// This renderer is intended to be used after other renderers in a [FoldingRenderer].
+func NewResourceHints(opts ...ResourceHintsOpts) plugin.Renderer {
+	opt := ResourceHintsOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.MaxPreconnect == 0 {
+		opt.MaxPreconnect = 4
+	} else if opt.MaxPreconnect < 0 {
+		opt.MaxPreconnect = 0
+	}
+	if opt.CrossOrigin == nil {
+		opt.CrossOrigin = []string{"fonts.gstatic.com"}
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &resourceHints{
+		hosts:         opt.Hosts,
+		allow:         opt.Allow,
+		origins:       opt.Origins,
+		maxPreconnect: opt.MaxPreconnect,
+		crossOrigin:   opt.CrossOrigin,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type ResourceHintsOpts struct {
+	// Hosts of the site itself, which aren't third-party origins.
+	Hosts []string
+	// Domains of the origins that are hinted, such as "youtube-nocookie.com".
+	// Subdomains of the specified domains are also matched. If empty, all
+	// third-party origins are hinted.
+	Allow []string
+	// Origins hinted on all pages before the ones found on them, such as
+	// "https://fonts.gstatic.com", which is only referenced by stylesheets.
+	Origins []string
+
+	// Max number of preconnected origins. Defaults to 4, negative values only
+	// prefetch the DNS of origins.
+	MaxPreconnect int
+	// Domains of the origins preconnected with the "crossorigin" attribute,
+	// which is needed for the connection to be used by requests with CORS,
+	// such as fonts. Origins of elements with the attribute also have it.
+	// Defaults to "fonts.gstatic.com".
+	CrossOrigin []string
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type resourceHints struct {
+	hosts         []string
+	allow         []string
+	origins       []string
+	maxPreconnect int
+	crossOrigin   []string
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+// A third-party origin that a page loads resources from.
+type resourceOrigin struct {
+	origin      string
+	host        string
+	crossOrigin bool
+}
+
+func (r *resourceHints) Name() string {
+	return resourceHintsName
+}
+
+func (r *resourceHints) Render(src fs.File, w io.Writer) error {
+	r.assert.NotNil(src)
+	r.assert.NotNil(w)
+	r.assert.NotNil(r.log)
+
+	if _, ok := src.(fs.ReadDirFile); ok {
+		return errors.New("does not support directories")
+	}
+
+	contents, err := io.ReadAll(src)
+	if err != nil {
+		return errors.Join(errors.New("failed to read file contents"), err)
+	}
+
+	tokens := tokenizeHTML(contents)
+
+	origins := []resourceOrigin{}
+	hinted := map[string]bool{}
+	add := func(u string, crossOrigin bool) {
+		o, ok := r.origin(u)
+		if !ok {
+			return
+		}
+		o.crossOrigin = crossOrigin || r.matches(r.crossOrigin, o.host)
+		if i := slices.IndexFunc(origins, func(e resourceOrigin) bool { return e.origin == o.origin }); i != -1 {
+			origins[i].crossOrigin = origins[i].crossOrigin || o.crossOrigin
+			return
+		}
+		origins = append(origins, o)
+	}
+
+	for _, o := range r.origins {
+		add(o, false)
+	}
+
+	// Index of the token that the hints are written before.
+	at := -1
+
+	for i, tok := range tokens {
+		if tok.Kind != htmlStartTagToken && tok.Kind != htmlSelfClosingTagToken {
+			if at == -1 && tok.Kind == htmlTextToken && len(bytes.TrimSpace(tok.Raw)) > 0 {
+				at = i
+			}
+			continue
+		}
+		if at == -1 {
+			at = i
+			if tok.Tag == "head" || tok.Tag == "html" {
+				at = -1
+			}
+		}
+		if tok.Tag == "head" {
+			at = i + 1
+		}
+
+		attrs := parseHTMLAttrs(tok)
+		_, crossOrigin := getHTMLAttr(attrs, "crossorigin")
+
+		if tok.Tag == "link" {
+			href, _ := getHTMLAttr(attrs, "href")
+			rel, _ := getHTMLAttr(attrs, "rel")
+			rels := strings.Fields(strings.ToLower(rel))
+			if slices.Contains(rels, "preconnect") || slices.Contains(rels, "dns-prefetch") {
+				if o, ok := r.origin(href); ok {
+					hinted[o.origin] = true
+				}
+				continue
+			}
+			if !slices.Contains(rels, "canonical") && !slices.Contains(rels, "alternate") {
+				add(href, crossOrigin)
+			}
+			continue
+		}
+
+		for _, key := range []string{"src", "poster", "data"} {
+			if v, ok := getHTMLAttr(attrs, key); ok {
+				add(v, crossOrigin)
+			}
+		}
+		if v, ok := getHTMLAttr(attrs, "srcset"); ok {
+			for _, c := range strings.Split(v, ",") {
+				if f := strings.Fields(c); len(f) > 0 {
+					add(f[0], crossOrigin)
+				}
+			}
+		}
+	}
+
+	var hints bytes.Buffer
+	preconnects := 0
+	for _, o := range origins {
+		if hinted[o.origin] {
+			continue
+		}
+		if preconnects < r.maxPreconnect {
+			preconnects++
+			hints.WriteString(`<link rel="preconnect" href="` + escapeHTMLText(o.origin) + `"`)
+			if o.crossOrigin {
+				hints.WriteString(" crossorigin")
+			}
+			hints.WriteString(">\n")
+			continue
+		}
+		hints.WriteString(`<link rel="dns-prefetch" href="` + escapeHTMLText(o.origin) + `">` + "\n")
+	}
+
+	if hints.Len() == 0 {
+		_, err := w.Write(contents)
+		return err
+	}
+
+	if at == -1 {
+		at = len(tokens)
+	}
+
+	for i, tok := range tokens {
+		if i == at {
+			if _, err := w.Write(hints.Bytes()); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(tok.Raw); err != nil {
+			return err
+		}
+	}
+	if at == len(tokens) {
+		_, err := w.Write(hints.Bytes())
+		return err
+	}
+
+	return nil
+}
+
+// Returns the third-party origin of the URL, if it's allowed.
+func (r *resourceHints) origin(u string) (resourceOrigin, bool) {
+	if strings.HasPrefix(strings.TrimSpace(u), "//") {
+		u = "https:" + strings.TrimSpace(u)
+	}
+
+	p, err := url.Parse(strings.TrimSpace(u))
+	if err != nil || p.Host == "" || (p.Scheme != "http" && p.Scheme != "https") {
+		return resourceOrigin{}, false
+	}
+
+	host := strings.ToLower(p.Hostname())
+	if slices.Contains(r.hosts, host) {
+		return resourceOrigin{}, false
+	}
+	if len(r.allow) > 0 && !r.matches(r.allow, host) {
+		return resourceOrigin{}, false
+	}
+
+	return resourceOrigin{origin: p.Scheme + "://" + strings.ToLower(p.Host), host: host}, true
+}
+
+func (r *resourceHints) matches(domains []string, host string) bool {
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimPrefix(d, "."))
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
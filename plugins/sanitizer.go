@@ -0,0 +1,110 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+	"github.com/microcosm-cc/bluemonday"
+)
+
+const sanitizerName = "blogo-sanitizer-renderer"
+
+// Policy used by [NewSanitizer] to sanitize HTML, implemented by
+// *bluemonday.Policy (https://github.com/microcosm-cc/bluemonday), so users
+// that need more fine grained policies can create their own.
+type SanitizerPolicy interface {
+	SanitizeReader(r io.Reader) *bytes.Buffer
+}
+
+// Creates a [plugin.Renderer] that sanitizes already rendered HTML using a
+// [SanitizerPolicy], for setups where the content comes from third parties,
+// such as feed aggregation or user submissions. Defaults to [UGCSanitizerPolicy].
+//
+// This renderer is intended to be used after other renderers in a [FoldingRenderer].
+func NewSanitizer(opts ...SanitizerOpts) plugin.Renderer {
+	opt := SanitizerOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Policy == nil {
+		opt.Policy = UGCSanitizerPolicy()
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &sanitizer{
+		policy: opt.Policy,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type SanitizerOpts struct {
+	Policy SanitizerPolicy
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type sanitizer struct {
+	policy SanitizerPolicy
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (r *sanitizer) Name() string {
+	return sanitizerName
+}
+
+func (r *sanitizer) Render(src fs.File, w io.Writer) error {
+	r.assert.NotNil(src)
+	r.assert.NotNil(w)
+	r.assert.NotNil(r.policy)
+
+	if _, ok := src.(fs.ReadDirFile); ok {
+		return errors.New("does not support directories")
+	}
+
+	_, err := io.Copy(w, r.policy.SanitizeReader(src))
+	return err
+}
+
+// Policy that strips all HTML tags, leaving just the text contents, see
+// [bluemonday.StrictPolicy].
+func StrictSanitizerPolicy() SanitizerPolicy {
+	return bluemonday.StrictPolicy()
+}
+
+// Policy that allows common formatting, links, images and tables, see
+// [bluemonday.UGCPolicy]. Links are required to have "rel=nofollow".
+func UGCSanitizerPolicy() SanitizerPolicy {
+	return bluemonday.UGCPolicy()
+}
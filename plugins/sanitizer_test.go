@@ -0,0 +1,59 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"forge.capytal.company/loreddev/blogo/plugins"
+)
+
+func TestSanitizerBypasses(t *testing.T) {
+	cases := []string{
+		`<a href="jav&#x09;ascript:alert(1)">x</a>`,
+		`<a href="&#106;avascript:alert(1)">x</a>`,
+		`<a href=" javascript:alert(1)">x</a>`,
+		`<img src=x onerror=alert(1)>`,
+		`<svg><script>alert(1)</script></svg>`,
+		`<math><mtext><style><img src=x onerror=alert(1)></style></mtext></math>`,
+		`<p title="a" onclick="alert(1)">x</p>`,
+		`<p title='" onclick="alert(1)'>x</p>`,
+	}
+
+	r := plugins.NewSanitizer()
+	for _, c := range cases {
+		fsys := fstest.MapFS{"index.html": {Data: []byte(c)}}
+		f, err := fsys.Open("index.html")
+		if err != nil {
+			t.Fatalf("failed to open file: %s", err)
+		}
+
+		var buf bytes.Buffer
+		if err := r.Render(f, &buf); err != nil {
+			t.Fatalf("failed to render %q: %s", c, err)
+		}
+
+		out := strings.ToLower(buf.String())
+		for _, bad := range []string{"javascript:", "<script", "onerror=", `onclick="`, "<svg", "<math"} {
+			if strings.Contains(out, bad) {
+				t.Fatalf("sanitizing %q returned %q, which contains %q", c, buf.String(), bad)
+			}
+		}
+	}
+}
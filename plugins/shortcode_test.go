@@ -0,0 +1,89 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins_test
+
+import (
+	"bytes"
+	"testing"
+	"testing/fstest"
+
+	"forge.capytal.company/loreddev/blogo/plugins"
+)
+
+var shortcodeFS = fstest.MapFS{
+	"data/table.csv": {Data: []byte("name,value\n\"a, b\",1\nc,\"2\"\"\"\n")},
+	"data/table.tsv": {Data: []byte("name\tvalue\na\t1\n")},
+	"data/bad.csv":   {Data: []byte("a,\"b\nc")},
+	"secret.txt":     {Data: []byte("secret")},
+}
+
+func FuzzCSVEmbed(f *testing.F) {
+	for _, s := range []string{
+		`<p>{{< csv "data/table.csv" >}}</p>`,
+		`<p>{{&lt; csv &quot;/data/table.tsv&quot; caption=&quot;A &lt;b&gt;table&lt;/b&gt;&quot; &gt;}}</p>`,
+		`<p>{{< csv "data/../secret.txt" >}}</p>`,
+		`<p>{{< csv "../../etc/passwd.csv" >}}</p>`,
+		`<p>{{< csv "data/bad.csv" caption="\"" >}}</p><p>{{< csv >}}</p>`,
+		`<p>{{< csv "data/table.csv"`,
+		`<pre><p>{{< csv "data/table.csv" >}}</p></pre>`,
+	} {
+		f.Add([]byte(s))
+	}
+
+	f.Fuzz(func(t *testing.T, src []byte) {
+		fsys := fstest.MapFS{"post.html": {Data: src}}
+		for k, v := range shortcodeFS {
+			fsys[k] = v
+		}
+
+		file, err := fsys.Open("post.html")
+		if err != nil {
+			t.Fatalf("Failed to open fixture: %s %v", err.Error(), err)
+		}
+		defer file.Close()
+
+		var buf bytes.Buffer
+		if err := plugins.NewCSVEmbed(fsys).Render(file, &buf); err != nil {
+			return
+		}
+		if bytes.Contains(buf.Bytes(), []byte("secret")) && !bytes.Contains(src, []byte("secret")) {
+			t.Fatalf("Render should not embed files besides CSV and TSV ones:\n%s", buf.Bytes())
+		}
+	})
+}
+
+func FuzzTypographer(f *testing.F) {
+	for _, s := range []string{
+		`<p>:smile: "quotes" -- and... 'single'</p>`,
+		`<code>:smile:</code><p>:unknown: ::: :</p>`,
+		`<p>:` + "\xff" + `:</p><script>":smile:"</script>`,
+	} {
+		f.Add([]byte(s))
+	}
+
+	f.Fuzz(func(t *testing.T, src []byte) {
+		fsys := fstest.MapFS{"post.html": {Data: src}}
+
+		file, err := fsys.Open("post.html")
+		if err != nil {
+			t.Fatalf("Failed to open fixture: %s %v", err.Error(), err)
+		}
+		defer file.Close()
+
+		var buf bytes.Buffer
+		_ = plugins.NewTypographer().Render(file, &buf)
+	})
+}
@@ -0,0 +1,267 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shorturl provides stable short URLs for posts, such as "/s/abc123",
+// which permanently redirect to the canonical URL of the post.
+//
+// Short codes are derived from the URL path of each post, so they are stable
+// across restarts and deployments without needing any storage. Posts can also
+// define their own code with the "shortcode" frontmatter value.
+package shorturl
+
+import (
+	"crypto/sha256"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-shorturl-sourcer"
+
+// Metadata key of a custom short code for the post.
+const CodeKey = "shortcode"
+
+// The short URL plugin, which wraps a [plugin.Sourcer] to index the short codes
+// of all files and is a [plugin.Middleware] serving the redirects.
+type Plugin interface {
+	plugin.Sourcer
+	plugin.Middleware
+	// Returns the template functions:
+	//
+	//   - "shortURL PATH" returns the short URL of the file path or URL path;
+	//   - "shortCode PATH" returns just the short code of the path.
+	FuncMap() template.FuncMap
+}
+
+// Creates the short URL [Plugin], wrapping the sourcer. Requests to
+// "Opts.Prefix + code" are redirected with "301 Moved Permanently" to the
+// URL path of the file with said code.
+func New(sourcer plugin.Sourcer, opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Prefix == "" {
+		opt.Prefix = "/s/"
+	}
+	if opt.Length == 0 {
+		opt.Length = 6
+	}
+	if opt.Extensions == nil {
+		opt.Extensions = []string{".md"}
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be wrapped should not be nil")
+
+	return &p{
+		sourcer: sourcer,
+
+		prefix:     "/" + strings.Trim(opt.Prefix, "/") + "/",
+		baseURL:    strings.TrimSuffix(opt.BaseURL, "/"),
+		length:     opt.Length,
+		extensions: opt.Extensions,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Path prefix of short URLs. Defaults to "/s/".
+	Prefix string
+	// Base URL prepended to short URLs returned to templates, for example
+	// "https://example.com". By default short URLs are relative.
+	BaseURL string
+	// Length of generated short codes. Defaults to 6.
+	Length int
+	// Extensions of the files that have short URLs. Defaults to ".md".
+	Extensions []string
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	sourcer plugin.Sourcer
+
+	prefix     string
+	baseURL    string
+	length     int
+	extensions []string
+
+	mu    sync.RWMutex
+	codes map[string]string // code to URL path
+	paths map[string]string // URL path to code
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+func (p *p) Source() (fs.FS, error) {
+	p.assert.NotNil(p.sourcer)
+	p.assert.NotNil(p.log)
+
+	fsys, err := p.sourcer.Source()
+	if err != nil {
+		return fsys, err
+	}
+
+	p.index(fsys)
+
+	return fsys, nil
+}
+
+func (p *p) index(fsys fs.FS) {
+	log := p.log.With(slog.String("sourcer", p.sourcer.Name()))
+	log.Debug("Indexing short codes")
+
+	codes, paths := map[string]string{}, map[string]string{}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !slices.Contains(p.extensions, path.Ext(name)) {
+			return nil
+		}
+
+		u := urlPath(name)
+		code := p.code(u)
+
+		if m, err := metadata.GetMetadata(d); err == nil {
+			if c, err := metadata.GetTyped[string](m, CodeKey); err == nil && c != "" {
+				code = c
+			}
+		}
+
+		if other, ok := codes[code]; ok {
+			log.Warn("Short code collision, ignoring file",
+				slog.String("code", code), slog.String("file", name), slog.String("other", other))
+			return nil
+		}
+
+		codes[code], paths[u] = u, code
+
+		return nil
+	})
+	if err != nil {
+		log.Warn("Failed to index short codes", slog.String("err", err.Error()))
+	}
+
+	p.mu.Lock()
+	p.codes, p.paths = codes, paths
+	p.mu.Unlock()
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, ok := strings.CutPrefix(r.URL.Path, p.prefix)
+		if !ok || code == "" || strings.Contains(code, "/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p.mu.RLock()
+		indexed := p.codes != nil
+		p.mu.RUnlock()
+
+		if !indexed {
+			if _, err := p.Source(); err != nil {
+				p.log.Error("Failed to source files for short codes", slog.String("err", err.Error()))
+			}
+		}
+
+		p.mu.RLock()
+		u, ok := p.codes[code]
+		p.mu.RUnlock()
+
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		http.Redirect(w, r, u, http.StatusMovedPermanently)
+	})
+}
+
+func (p *p) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"shortCode": p.lookup,
+		"shortURL": func(name string) string {
+			return p.baseURL + p.prefix + p.lookup(name)
+		},
+	}
+}
+
+func (p *p) lookup(name string) string {
+	u := name
+	if !strings.HasPrefix(u, "/") {
+		u = urlPath(name)
+	}
+
+	p.mu.RLock()
+	code, ok := p.paths[u]
+	p.mu.RUnlock()
+
+	if ok {
+		return code
+	}
+	return p.code(u)
+}
+
+const alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// Generates the short code of the URL path, from the base62 encoding of it's hash.
+func (p *p) code(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	n := new(big.Int).SetBytes(sum[:])
+	base := big.NewInt(int64(len(alphabet)))
+
+	code := make([]byte, p.length)
+	mod := new(big.Int)
+	for i := range code {
+		n.DivMod(n, base, mod)
+		code[i] = alphabet[mod.Int64()]
+	}
+
+	return string(code)
+}
+
+func urlPath(name string) string {
+	return "/" + strings.TrimSuffix(name, path.Ext(name))
+}
@@ -0,0 +1,352 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signedurl provides signed, expiring URLs for private assets, such as
+// downloads for members. Protected files are hidden from the sourced file
+// system, so they aren't served, listed or read by other plugins, and are only
+// served to requests with a valid signature, which templates create with the
+// "signedURL" function.
+package signedurl
+
+import (
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/visibility"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-signedurl-sourcer"
+
+// Metadata key that, if true, protects the file behind signed URLs.
+const SignedKey = "signed"
+
+// The signed URL plugin, which wraps a [plugin.Sourcer] to hide the protected
+// files and is a [plugin.Middleware] that serves them to signed requests.
+type Plugin interface {
+	plugin.Sourcer
+	plugin.Middleware
+
+	// Returns the signed URL of the file at the path, valid for at least ttl,
+	// or Opts.TTL if it's zero.
+	Sign(path string, ttl time.Duration) (string, error)
+	// Functions of templates, "signedURL" which returns the signed URL of a
+	// path, with a optional duration such as "24h" as the TTL.
+	FuncMap() template.FuncMap
+}
+
+// Creates the signed URL [Plugin], wrapping the sourcer. Files that match one
+// of Opts.Patterns, or have "signed: true" on their metadata, are protected. It
+// should be the last wrapper of the sourcer of the engine, since signed requests
+// are served the files of the wrapped sourcer directly.
+//
+// Expiration times are rounded up to multiples of the TTL, so the URLs created
+// by renders are the same until the next multiple and renders can be cached.
+// Signed URLs are valid for between one and two TTLs, which should be longer
+// than the cache TTL of the engine, so cached pages don't link to expired URLs.
+//
+// Requests with a invalid or expired signature are responded with "403 Forbidden",
+// and requests without one are served as if the protected files don't exist.
+func New(sourcer plugin.Sourcer, signer Signer, opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.TTL == 0 {
+		opt.TTL = 24 * time.Hour
+	}
+	if opt.Now == nil {
+		opt.Now = time.Now
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be wrapped should not be nil")
+	opt.Assertions.NotNil(signer, "Signer should not be nil")
+
+	return &p{
+		sourcer: sourcer,
+		signer:  signer,
+
+		patterns: opt.Patterns,
+		ttl:      opt.TTL,
+		now:      opt.Now,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Patterns of the paths of protected files, such as "downloads/**", see
+	// [visibility.Match].
+	Patterns []string
+	// Default duration that signed URLs are valid for, see [New]. Defaults to 24 hours.
+	TTL time.Duration
+	// Returns the current time. Defaults to [time.Now].
+	Now func() time.Time
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	sourcer plugin.Sourcer
+	signer  Signer
+
+	patterns []string
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu   sync.RWMutex
+	fsys fs.FS
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Source() (fs.FS, error) {
+	p.assert.NotNil(p.sourcer)
+
+	fsys, err := p.sourcer.Source()
+	if err != nil {
+		return fsys, err
+	}
+
+	p.mu.Lock()
+	p.fsys = fsys
+	p.mu.Unlock()
+
+	return &protectedFS{FS: fsys, p: p}, nil
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.signer)
+		p.assert.NotNil(p.log)
+
+		if !p.signer.Signed(r.URL) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		name, err := core.FilePath(r.URL.Path)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p.mu.RLock()
+		fsys := p.fsys
+		p.mu.RUnlock()
+
+		if fsys == nil || !p.protected(fsys, name) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		log := p.log.With(slog.String("path", name))
+
+		u := *r.URL
+		u.Path = "/" + name
+		if err := p.signer.Verify(&u, p.now()); err != nil {
+			log.Debug("Rejected signed request", slog.String("err", err.Error()))
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		log.Debug("Serving protected file to signed request")
+
+		// Signed URLs are secrets, so they shouldn't leak to the sites that the
+		// asset links to, and renders of them are private.
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		w.Header().Set("X-Robots-Tag", "noindex")
+		w.Header().Set("Cache-Control", "private, no-store")
+
+		next.ServeHTTP(w, r.WithContext(core.WithFiles(r.Context(), fsys)))
+	})
+}
+
+func (p *p) Sign(name string, ttl time.Duration) (string, error) {
+	p.assert.NotNil(p.signer)
+
+	if ttl <= 0 {
+		ttl = p.ttl
+	}
+
+	clean, err := core.FilePath(name)
+	if err != nil {
+		return "", errors.Join(errors.New("failed to sign path"), err)
+	}
+
+	u := &url.URL{Path: "/" + clean}
+	if err := p.signer.Sign(u, p.now().Truncate(ttl).Add(2*ttl)); err != nil {
+		return "", errors.Join(errors.New("failed to sign path"), err)
+	}
+
+	return u.String(), nil
+}
+
+func (p *p) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"signedURL": func(name string, ttl ...string) (string, error) {
+			var d time.Duration
+			if len(ttl) > 0 {
+				var err error
+				if d, err = time.ParseDuration(ttl[0]); err != nil {
+					return "", errors.Join(errors.New("invalid TTL of signed URL"), err)
+				}
+			}
+			return p.Sign(name, d)
+		},
+	}
+}
+
+// Reports if the file at the path is protected, by the patterns or by it's metadata.
+func (p *p) protected(fsys fs.FS, name string) bool {
+	if slices.ContainsFunc(p.patterns, func(pattern string) bool {
+		return visibility.Match(pattern, name)
+	}) {
+		return true
+	}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	return signed(f)
+}
+
+func signed(v any) bool {
+	m, err := metadata.GetMetadata(v)
+	if err != nil {
+		return false
+	}
+	s, err := metadata.GetTyped[bool](m, SignedKey)
+	return err == nil && s
+}
+
+// File system that hides the protected files.
+type protectedFS struct {
+	fs.FS
+	p *p
+}
+
+func (fsys *protectedFS) Metadata() metadata.Metadata {
+	if m, err := metadata.GetMetadata(fsys.FS); err == nil {
+		return m
+	}
+	return metadata.Map(map[string]any{})
+}
+
+func (fsys *protectedFS) Open(name string) (fs.File, error) {
+	if fsys.matches(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	f, err := fsys.FS.Open(name)
+	if err != nil {
+		return f, err
+	}
+
+	if d, ok := f.(fs.ReadDirFile); ok {
+		return &dirFile{ReadDirFile: d, fsys: fsys, path: name}, nil
+	}
+
+	if signed(f) {
+		_ = f.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return f, nil
+}
+
+func (fsys *protectedFS) matches(name string) bool {
+	return slices.ContainsFunc(fsys.p.patterns, func(pattern string) bool {
+		return visibility.Match(pattern, name)
+	})
+}
+
+// Directory that omits the protected files from it's entries.
+type dirFile struct {
+	fs.ReadDirFile
+	fsys *protectedFS
+	path string
+}
+
+func (f *dirFile) Metadata() metadata.Metadata {
+	if m, err := metadata.GetMetadata(f.ReadDirFile); err == nil {
+		return m
+	}
+	return metadata.Map(map[string]any{})
+}
+
+func (f *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	for {
+		es, err := f.ReadDirFile.ReadDir(n)
+
+		entries := make([]fs.DirEntry, 0, len(es))
+		for _, e := range es {
+			if f.fsys.matches(path.Join(f.path, e.Name())) || (!e.IsDir() && signed(e)) {
+				continue
+			}
+			entries = append(entries, e)
+		}
+
+		// Only return a empty batch at the end of the directory, so callers that
+		// read it in batches don't stop early.
+		if n > 0 && len(entries) == 0 && len(es) > 0 && err == nil {
+			continue
+		}
+		return entries, err
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signedurl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	// Returned by [Signer] implementations if the URL doesn't have a valid signature.
+	ErrInvalidSignature = errors.New("URL signature is invalid")
+	// Returned by [Signer] implementations if the signature of the URL is valid
+	// but has expired.
+	ErrExpired = errors.New("signed URL has expired")
+)
+
+// Signs and verifies URLs, such as with a HMAC (see [NewHMACSigner]) or the
+// scheme of a CDN that also serves the assets.
+type Signer interface {
+	// Adds the signature of the URL, valid until expires, to it.
+	Sign(u *url.URL, expires time.Time) error
+	// Verifies the signature of the URL at the time now, returning [ErrInvalidSignature]
+	// or [ErrExpired] if it isn't valid.
+	Verify(u *url.URL, now time.Time) error
+	// Reports if the URL has a signature, valid or not.
+	Signed(u *url.URL) bool
+}
+
+// Creates a [Signer] that signs URLs with a HMAC-SHA256 of their path and
+// expiration time, added as the "expires" and "signature" query values. The
+// key should be at least 32 random bytes and kept secret, since anyone with it
+// can sign URLs.
+//
+// Other query values aren't signed, so they can't be used to restrict what
+// is served.
+func NewHMACSigner(key []byte, opts ...HMACOpts) Signer {
+	opt := HMACOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.ExpiresParam == "" {
+		opt.ExpiresParam = "expires"
+	}
+	if opt.SignatureParam == "" {
+		opt.SignatureParam = "signature"
+	}
+
+	return &hmacSigner{
+		key:            key,
+		expiresParam:   opt.ExpiresParam,
+		signatureParam: opt.SignatureParam,
+	}
+}
+
+type HMACOpts struct {
+	// Query value of the expiration time, in Unix seconds. Defaults to "expires".
+	ExpiresParam string
+	// Query value of the signature. Defaults to "signature".
+	SignatureParam string
+}
+
+type hmacSigner struct {
+	key            []byte
+	expiresParam   string
+	signatureParam string
+}
+
+func (s *hmacSigner) Sign(u *url.URL, expires time.Time) error {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+
+	q := u.Query()
+	q.Set(s.expiresParam, exp)
+	q.Set(s.signatureParam, s.sign(u.Path, exp))
+	u.RawQuery = q.Encode()
+
+	return nil
+}
+
+func (s *hmacSigner) Verify(u *url.URL, now time.Time) error {
+	q := u.Query()
+
+	exp, sig := q.Get(s.expiresParam), q.Get(s.signatureParam)
+	if exp == "" || sig == "" {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.sign(u.Path, exp))) {
+		return ErrInvalidSignature
+	}
+
+	sec, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !now.Before(time.Unix(sec, 0)) {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+func (s *hmacSigner) Signed(u *url.URL) bool {
+	return u.Query().Has(s.signatureParam)
+}
+
+func (s *hmacSigner) sign(p, expires string) string {
+	mac := hmac.New(sha256.New, s.key)
+	_, _ = mac.Write([]byte(p + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
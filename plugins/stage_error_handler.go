@@ -0,0 +1,116 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const stageErrorHandlerName = "blogo-stageerrorhandler-errorhandler"
+
+// Creates a [plugin.ErrorHandler] that dispatches errors to the handler of the
+// [core.Stage] where they happened, so a fallback renderer is only used on render
+// errors and a fallback sourcer only on sourcing errors, for example.
+//
+// Errors that aren't a [core.ServeError], or of stages without a handler, are
+// passed to StageErrorHandlerOpts.Default, if any.
+func NewStageErrorHandler(opts ...StageErrorHandlerOpts) plugin.ErrorHandler {
+	opt := StageErrorHandlerOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &stageErrorHandler{
+		handlers: map[core.Stage]plugin.ErrorHandler{
+			core.StageSource:    opt.Source,
+			core.StageOpen:      opt.Open,
+			core.StageRender:    opt.Render,
+			core.StageAdmission: opt.Admission,
+		},
+		fallback: opt.Default,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type StageErrorHandlerOpts struct {
+	// Handler of errors on [core.StageSource].
+	Source plugin.ErrorHandler
+	// Handler of errors on [core.StageOpen].
+	Open plugin.ErrorHandler
+	// Handler of errors on [core.StageRender].
+	Render plugin.ErrorHandler
+	// Handler of rejected requests, on [core.StageAdmission].
+	Admission plugin.ErrorHandler
+	// Handler of errors of stages without a handler.
+	Default plugin.ErrorHandler
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type stageErrorHandler struct {
+	handlers map[core.Stage]plugin.ErrorHandler
+	fallback plugin.ErrorHandler
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (h *stageErrorHandler) Name() string {
+	return stageErrorHandlerName
+}
+
+func (h *stageErrorHandler) Handle(err error) (recovr any, handled bool) {
+	h.assert.NotNil(err, "Error should not be nil")
+	h.assert.NotNil(h.handlers, "Handlers map should not be nil")
+	h.assert.NotNil(h.log)
+
+	log := h.log.With(slog.String("err", err.Error()))
+
+	handler := h.fallback
+
+	var serr core.ServeError
+	if errors.As(err, &serr) {
+		log = log.With(slog.String("stage", string(serr.Stage)))
+		if s, ok := h.handlers[serr.Stage]; ok && s != nil {
+			handler = s
+		}
+	}
+
+	if handler == nil {
+		log.Debug("No error handler for stage, ignoring error")
+		return nil, false
+	}
+
+	log.Debug("Handling error with plugin", slog.String("plugin", handler.Name()))
+
+	return handler.Handle(err)
+}
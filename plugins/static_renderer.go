@@ -0,0 +1,192 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"path"
+	"slices"
+	"strings"
+
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const staticRendererName = "blogo-static-renderer"
+
+// File extensions served by default by [NewStaticRenderer].
+var DefaultStaticExtensions = []string{
+	".html", ".htm",
+	".css", ".js", ".mjs", ".json", ".xml", ".txt", ".webmanifest",
+	".pdf", ".zip",
+	".png", ".jpg", ".jpeg", ".gif", ".webp", ".avif", ".svg", ".ico",
+	".woff", ".woff2", ".ttf", ".otf",
+	".mp3", ".ogg", ".mp4", ".webm",
+}
+
+var staticHTMLExtensions = []string{".html", ".htm"}
+
+// Creates a [plugin.Renderer] that serves files untouched, so blogo can host raw
+// HTML pages and static files (stylesheets, fonts, PDFs, etc.) alongside posts.
+// Files with extensions not present on [StaticRendererOpts].Extensions and directories
+// return a error, so it can be used alongside other renderers in a [MultiRenderer].
+//
+// The Content-Type header of the response is set based on the file extension,
+// see [plugin.ResponseRenderer].
+//
+// HTML files can optionally be wrapped in a layout template, see [StaticRendererOpts].
+func NewStaticRenderer(opts ...StaticRendererOpts) plugin.Renderer {
+	opt := StaticRendererOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Extensions == nil {
+		opt.Extensions = DefaultStaticExtensions
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &staticRenderer{
+		extensions: opt.Extensions,
+		layout:     opt.Layout,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type StaticRendererOpts struct {
+	// Extensions of the files served by the renderer. Defaults to [DefaultStaticExtensions].
+	Extensions []string
+
+	// Template used to wrap HTML files that are not full documents (that don't
+	// start with a doctype or html tag). The template is executed with a
+	// [StaticRendererLayoutInfo] value. By default, HTML files are served untouched.
+	Layout *template.Template
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+// Information passed to the layout of [NewStaticRenderer].
+type StaticRendererLayoutInfo struct {
+	Name     string
+	Content  template.HTML
+	Metadata metadata.Metadata
+}
+
+type staticRenderer struct {
+	extensions []string
+	layout     *template.Template
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (r *staticRenderer) Name() string {
+	return staticRendererName
+}
+
+func (r *staticRenderer) Render(src fs.File, w io.Writer) error {
+	return plugin.RenderContext(context.Background(), r, src, w)
+}
+
+func (r *staticRenderer) RenderResponse(
+	ctx context.Context,
+	src fs.File,
+	res plugin.Response,
+	w io.Writer,
+) error {
+	r.assert.NotNil(src)
+	r.assert.NotNil(res)
+	r.assert.NotNil(w)
+	r.assert.NotNil(r.log)
+
+	if _, ok := src.(fs.ReadDirFile); ok {
+		return errors.New("does not support directories")
+	}
+
+	stat, err := src.Stat()
+	if err != nil {
+		return errors.Join(errors.New("failed to stat file"), err)
+	}
+
+	ext := strings.ToLower(path.Ext(stat.Name()))
+	if !slices.Contains(r.extensions, ext) {
+		return errors.New("does not support file")
+	}
+
+	log := r.log.With(slog.String("file", stat.Name()))
+
+	if t := mime.TypeByExtension(ext); t != "" {
+		res.Header().Set("Content-Type", t)
+	}
+
+	if r.layout == nil || !slices.Contains(staticHTMLExtensions, ext) {
+		log.Debug("Serving static file")
+
+		_, err := io.Copy(w, src)
+		return err
+	}
+
+	contents, err := io.ReadAll(src)
+	if err != nil {
+		return errors.Join(errors.New("failed to read file contents"), err)
+	}
+
+	if isHTMLDocument(contents) {
+		log.Debug("Serving full HTML document without layout")
+
+		_, err := w.Write(contents)
+		return err
+	}
+
+	info := StaticRendererLayoutInfo{
+		Name:     stat.Name(),
+		Content:  template.HTML(contents),
+		Metadata: metadata.Map(map[string]any{}),
+	}
+	if m, err := metadata.GetMetadata(src); err == nil {
+		info.Metadata = m
+	}
+
+	log.Debug("Serving HTML file wrapped in layout")
+
+	if err := r.layout.Execute(w, info); err != nil {
+		return errors.Join(errors.New("failed to execute layout template"), err)
+	}
+
+	return nil
+}
+
+func isHTMLDocument(contents []byte) bool {
+	start := bytes.ToLower(bytes.TrimSpace(contents[:min(len(contents), 512)]))
+	return bytes.HasPrefix(start, []byte("<!doctype")) || bytes.HasPrefix(start, []byte("<html"))
+}
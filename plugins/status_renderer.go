@@ -0,0 +1,186 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const statusRendererName = "blogo-status-renderer"
+
+// Creates a [plugin.ResponseRenderer] that sets the status code of the response
+// from the metadata of the file, rendering it with the wrapped renderer:
+//
+//   - "redirect: <url>" responds with "301 Moved Permanently" and the URL as
+//     the location, without rendering the file. Use "status" for other redirects,
+//     such as "status: 302".
+//   - "expires: <date>" responds with "410 Gone" after the date, the file is
+//     still rendered, so layouts can show a notice.
+//   - "status: <code>" responds with the code, such as 451 for content removed
+//     for legal reasons.
+//
+// It should wrap the whole pipeline of renderers, since the files passed to
+// nested renderers may not have the metadata.
+func NewStatusRenderer(renderer plugin.Renderer, opts ...StatusRendererOpts) plugin.Renderer {
+	opt := StatusRendererOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.RedirectKey == "" {
+		opt.RedirectKey = "redirect"
+	}
+	if opt.ExpiresKey == "" {
+		opt.ExpiresKey = "expires"
+	}
+	if opt.StatusKey == "" {
+		opt.StatusKey = "status"
+	}
+	if opt.Now == nil {
+		opt.Now = time.Now
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(renderer, "Renderer to be wrapped should not be nil")
+
+	return &statusRenderer{
+		renderer: renderer,
+
+		redirectKey: opt.RedirectKey,
+		expiresKey:  opt.ExpiresKey,
+		statusKey:   opt.StatusKey,
+		now:         opt.Now,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type StatusRendererOpts struct {
+	// Metadata key of the URL that the file redirects to. Defaults to "redirect".
+	RedirectKey string
+	// Metadata key of the date after which the file is gone. Defaults to "expires".
+	ExpiresKey string
+	// Metadata key of the status code of the response. Defaults to "status".
+	StatusKey string
+
+	// Function used to get the current time. Defaults to [time.Now].
+	Now func() time.Time
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type statusRenderer struct {
+	renderer plugin.Renderer
+
+	redirectKey string
+	expiresKey  string
+	statusKey   string
+	now         func() time.Time
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (r *statusRenderer) Name() string {
+	return statusRendererName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (r *statusRenderer) SetLogger(logger *slog.Logger) {
+	if r.injectLogger {
+		r.log = logger
+	}
+}
+
+func (r *statusRenderer) Render(src fs.File, w io.Writer) error {
+	return plugin.RenderContext(context.Background(), r, src, w)
+}
+
+func (r *statusRenderer) RenderResponse(
+	ctx context.Context,
+	src fs.File,
+	res plugin.Response,
+	w io.Writer,
+) error {
+	r.assert.NotNil(r.renderer)
+	r.assert.NotNil(src)
+	r.assert.NotNil(res)
+	r.assert.NotNil(w)
+	r.assert.NotNil(r.log)
+
+	m, err := metadata.GetMetadata(src)
+	if err != nil {
+		return plugin.RenderContext(ctx, r.renderer, src, w)
+	}
+
+	status := 0
+	if s, err := metadata.GetTyped[int](m, r.statusKey); err == nil && s >= 100 && s <= 999 {
+		status = s
+	}
+
+	if url, err := metadata.GetTyped[string](m, r.redirectKey); err == nil && url != "" {
+		if status < 300 || status > 399 {
+			status = http.StatusMovedPermanently
+		}
+
+		r.log.Debug("Redirecting file", slog.String("url", url), slog.Int("status", status))
+
+		res.Header().Set("Location", url)
+		res.Header().Set("Content-Type", "text/html; charset=utf-8")
+		res.SetStatus(status)
+
+		_, err := fmt.Fprintf(w, "<a href=\"%s\">Moved here</a>.\n", template.HTMLEscapeString(url))
+		return err
+	}
+
+	if expires, err := metadata.GetTime(m, r.expiresKey); err == nil && r.now().After(expires) {
+		if status == 0 {
+			status = http.StatusGone
+		}
+	}
+
+	if status != 0 {
+		r.log.Debug("Setting status of file", slog.Int("status", status))
+		res.SetStatus(status)
+	}
+
+	return plugin.RenderContext(ctx, r.renderer, src, w)
+}
@@ -16,11 +16,13 @@
 package plugins
 
 import (
+	"bytes"
 	"errors"
 	"html/template"
 	"io"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"forge.capytal.company/loreddev/blogo/core"
 	"forge.capytal.company/loreddev/blogo/plugin"
@@ -29,6 +31,13 @@ import (
 
 const templateErrorHandlerName = "blogo-templateerrorhandler-errorhandler"
 
+// Creates a [plugin.ErrorHandler] that responds errors with the template, executed
+// with a [TemplateErrorHandlerInfo] value.
+//
+// Requests rejected by load shedding or rate limits (see [core.RejectError])
+// are responded with their status code, and the server adds their Retry-After
+// header. Rejections of clients that prefer JSON aren't handled, so the server
+// responds them with JSON instead.
 func NewTemplateErrorHandler(
 	templt template.Template,
 	opts ...TemplateErrorHandlerOpts,
@@ -62,6 +71,19 @@ type TemplateErrorHandlerInfo struct {
 	Path     string
 	Error    error
 	ErrorMsg string
+
+	// Path of the file on the file system that the request resolved to.
+	FilePath string
+	// Stage of the server where the error happened, see [core.Stage].
+	Stage string
+	// Time elapsed serving the request until the error.
+	Elapsed time.Duration
+
+	// Status code of the response, such as 429 for requests rejected by rate
+	// limits (see [core.RejectError]).
+	Status int
+	// Duration, if known, that clients should wait before retrying rejected requests.
+	RetryAfter time.Duration
 }
 
 type templateErrorHandler struct {
@@ -90,17 +112,47 @@ func (h *templateErrorHandler) Handle(err error) (recovr any, handled bool) {
 
 	log.Debug("Handling error")
 
-	w, r := serr.Res, serr.Req
+	r := serr.Req
+
+	status := http.StatusInternalServerError
+	msg := serr.Err.Error()
+
+	var rerr core.RejectError
+	if errors.As(serr.Err, &rerr) {
+		// API clients are better served by the JSON response of the server.
+		if core.PrefersJSON(r) {
+			log.Debug("Client prefers JSON, leaving rejection to the server")
+			return nil, false
+		}
+		status, msg = rerr.Status, rerr.Reason
+	}
 
-	w.WriteHeader(http.StatusInternalServerError)
-	if err := h.templt.Execute(w, TemplateErrorHandlerInfo{
+	var buf bytes.Buffer
+	if err := h.templt.Execute(&buf, TemplateErrorHandlerInfo{
 		Path:     r.URL.Path,
 		Error:    serr.Err,
-		ErrorMsg: serr.Err.Error(),
+		ErrorMsg: msg,
+
+		FilePath: serr.Path,
+		Stage:    string(serr.Stage),
+		Elapsed:  serr.Elapsed,
+
+		Status:     status,
+		RetryAfter: rerr.RetryAfter,
 	}); err != nil {
 		log.Error("Failed to execute template and respond error")
 		return nil, false
 	}
 
-	return nil, true
+	return core.RecoverWithResponse(respondBody(status, buf.Bytes())), true
+}
+
+// Returns a [core.Responder] that writes the body with the status code, used by
+// error handlers so the template is fully executed before anything is written.
+func respondBody(status int, body []byte) core.Responder {
+	return core.ResponderFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(status)
+		_, err := w.Write(body)
+		return err
+	})
 }
@@ -0,0 +1,417 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package terminal provides a terminal-friendly rendering of posts, as plain
+// text or styled with ANSI escape codes, served to command line clients such as
+// curl ("curl https://example.com/posts/hello.md") and to requests that prefer
+// "text/plain" by content negotiation.
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/index"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/visibility"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const (
+	rendererName = "blogo-terminal-renderer"
+	pluginName   = "blogo-terminal-sourcer"
+)
+
+// Creates a [plugin.Renderer] that renders markdown files as text for terminals
+// (see [Text]), with their title and date as the header, so it can be used
+// directly on the pipeline of the engine, such as for a "*.txt" path.
+func NewRenderer(opts ...RendererOpts) plugin.Renderer {
+	opt := RendererOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Width == 0 {
+		opt.Width = 80
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+
+	return &renderer{
+		opts:   TextOpts{Width: opt.Width, ANSI: opt.ANSI},
+		assert: opt.Assertions,
+	}
+}
+
+type RendererOpts struct {
+	// Max width of lines. Defaults to 80.
+	Width int
+	// Styles the text with ANSI escape codes.
+	ANSI bool
+
+	Assertions tinyssert.Assertions
+}
+
+type renderer struct {
+	opts   TextOpts
+	assert tinyssert.Assertions
+}
+
+func (r *renderer) Name() string {
+	return rendererName
+}
+
+func (r *renderer) Render(src fs.File, w io.Writer) error {
+	r.assert.NotNil(src)
+	r.assert.NotNil(w)
+
+	if _, ok := src.(fs.ReadDirFile); ok {
+		return errors.New("does not support directories")
+	}
+
+	contents, err := io.ReadAll(src)
+	if err != nil {
+		return errors.Join(errors.New("failed to read file contents"), err)
+	}
+
+	var m metadata.Metadata = metadata.Map(map[string]any{})
+	if fm, err := metadata.GetMetadata(src); err == nil {
+		m = fm
+	}
+
+	_, err = w.Write(render(contents, m, r.opts))
+	return err
+}
+
+// Returns the text of the markdown file, with the title and date of the
+// metadata as the header.
+func render(contents []byte, m metadata.Metadata, opts TextOpts) []byte {
+	t := &plainText{width: opts.Width, ansi: opts.ANSI}
+
+	var b strings.Builder
+	if title, err := metadata.GetTyped[string](m, "title"); err == nil && title != "" {
+		if opts.ANSI {
+			b.WriteString(t.style(magenta, t.style(bold, t.style(underline, title))) + "\n")
+		} else {
+			b.WriteString(title + "\n" + strings.Repeat("=", visibleWidth(title)) + "\n")
+		}
+		if date, err := metadata.GetTime(m, "date"); err == nil {
+			b.WriteString(t.style(dim, date.Format(time.DateOnly)) + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	b.Write(Text(contents, opts))
+	b.WriteString("\n")
+
+	return []byte(b.String())
+}
+
+// Command line clients served with the terminal rendering, by the prefix of
+// their user agent, used if Opts.UserAgents is nil.
+var DefaultUserAgents = []string{"curl/", "Wget/", "HTTPie/", "xh/", "Lynx/"}
+
+// The terminal plugin, which wraps a [plugin.Sourcer] to keep it's last file
+// system and is a [plugin.Middleware] serving the terminal rendering of files.
+type Plugin interface {
+	plugin.Sourcer
+	plugin.Middleware
+}
+
+// Creates the terminal [Plugin], wrapping the sourcer.
+//
+// Markdown files are served as text to requests of Opts.UserAgents, styled with
+// ANSI escape codes if Opts.ANSI is set, and as plain text to requests that
+// prefer "text/plain" over "text/html" on their "Accept" header. Requests for
+// the pages of Opts.Index entries, by their URL, and for directories, listed
+// with their entries, are also served if the index is provided. Other requests
+// are passed to the next handler.
+func New(sourcer plugin.Sourcer, opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.UserAgents == nil {
+		opt.UserAgents = DefaultUserAgents
+	}
+	if opt.Extensions == nil {
+		opt.Extensions = []string{".md"}
+	}
+	if opt.Width == 0 {
+		opt.Width = 80
+	}
+	if opt.Visibility == nil {
+		opt.Visibility = visibility.Default
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be wrapped should not be nil")
+
+	return &p{
+		sourcer: sourcer,
+
+		index:      opt.Index,
+		userAgents: opt.UserAgents,
+		ansi:       opt.ANSI,
+		extensions: opt.Extensions,
+		width:      opt.Width,
+		visibility: opt.Visibility,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Index used to find the files of the URLs of entries and list directories.
+	// By default only files requested by their path are served.
+	Index index.Provider
+
+	// Prefixes of the user agents of command line clients. Defaults to
+	// [DefaultUserAgents].
+	UserAgents []string
+	// Styles the text served to Opts.UserAgents with ANSI escape codes.
+	ANSI bool
+
+	// Extensions of the markdown files rendered. Defaults to ".md".
+	Extensions []string
+	// Max width of lines. Defaults to 80.
+	Width int
+	// Rules used to hide entries from directory listings. Defaults to
+	// [visibility.Default].
+	Visibility visibility.Rules
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	sourcer plugin.Sourcer
+
+	index      index.Provider
+	userAgents []string
+	ansi       bool
+	extensions []string
+	width      int
+	visibility visibility.Rules
+
+	mu   sync.RWMutex
+	fsys fs.FS
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Source() (fs.FS, error) {
+	p.assert.NotNil(p.sourcer)
+
+	fsys, err := p.sourcer.Source()
+	if err != nil {
+		return fsys, err
+	}
+
+	p.mu.Lock()
+	p.fsys = fsys
+	p.mu.Unlock()
+
+	return fsys, nil
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.log)
+
+		w.Header().Add("Vary", "Accept")
+		w.Header().Add("Vary", "User-Agent")
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		client := slices.ContainsFunc(p.userAgents, func(ua string) bool {
+			return strings.HasPrefix(r.UserAgent(), ua)
+		})
+		plain := prefersText(r.Header.Get("Accept"))
+		if !client && !plain {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, ok := p.render(strings.Trim(r.URL.Path, "/"), TextOpts{
+			Width: p.width,
+			ANSI:  p.ansi && client && !plain,
+		})
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p.log.Debug("Serving terminal rendering", slog.String("path", r.URL.Path))
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method != http.MethodHead {
+			_, _ = w.Write(body)
+		}
+	})
+}
+
+// Renders the file or directory at the name, reporting false if it isn't found
+// or can't be rendered.
+func (p *p) render(name string, opts TextOpts) ([]byte, bool) {
+	p.mu.RLock()
+	fsys := p.fsys
+	p.mu.RUnlock()
+
+	var idx index.Index
+	if p.index != nil {
+		if i, err := p.index.Index(); err == nil {
+			idx, fsys = i, i.FS()
+		}
+	}
+	if fsys == nil {
+		return nil, false
+	}
+
+	if idx != nil {
+		if e, ok := idx.Lookup("/" + name); ok {
+			name = e.Path
+		}
+	}
+	if name == "" {
+		name = "."
+	}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.IsDir() {
+		if idx == nil {
+			return nil, false
+		}
+		return p.list(idx, name, opts), true
+	}
+
+	if !slices.Contains(p.extensions, path.Ext(name)) {
+		return nil, false
+	}
+
+	contents, err := io.ReadAll(f)
+	if err != nil {
+		p.log.Error("Failed to read file", slog.String("file", name), slog.String("err", err.Error()))
+		return nil, false
+	}
+
+	var m metadata.Metadata = metadata.Map(map[string]any{})
+	if fm, err := metadata.GetMetadata(f); err == nil {
+		m = fm
+	}
+
+	return render(contents, m, opts), true
+}
+
+// Lists the entries of the directory, with the date, title and URL of each one.
+func (p *p) list(idx index.Index, dir string, opts TextOpts) []byte {
+	t := &plainText{width: opts.Width, ansi: opts.ANSI}
+
+	var b strings.Builder
+	for _, e := range idx.Entries() {
+		if dir != "." && !strings.HasPrefix(e.Path, dir+"/") {
+			continue
+		}
+		if !p.visibility.Visible(e.Path, e.Metadata, visibility.Listing) {
+			continue
+		}
+
+		title := e.Title
+		if title == "" {
+			title = path.Base(e.URL)
+		}
+		if !e.Date.IsZero() {
+			b.WriteString(t.style(dim, e.Date.Format(time.DateOnly)) + "  ")
+		}
+		b.WriteString(t.style(bold, title) + "\n")
+		b.WriteString(fmt.Sprintf("    %s\n\n", t.style(underline, e.URL)))
+	}
+
+	return []byte(b.String())
+}
+
+// Reports if the "Accept" header prefers plain text over HTML.
+func prefersText(accept string) bool {
+	text, html := 0.0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			q = v
+		}
+
+		switch mt {
+		case "text/plain":
+			text = max(text, q)
+		case "text/html":
+			html = max(html, q)
+		}
+	}
+	return text > 0 && text > html
+}
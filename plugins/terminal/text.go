@@ -0,0 +1,238 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package terminal
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+
+	"forge.capytal.company/loreddev/blogo/plugins/frontmatter"
+)
+
+// Converts the markdown body, with or without frontmatter, to text for terminals,
+// with lines of at most Opts.Width characters (other than code blocks and long
+// words). Links are numbered, as in "text [1]", and listed at the end. Raw HTML
+// is dropped.
+func Text(markdown []byte, opts ...TextOpts) []byte {
+	opt := TextOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Width == 0 {
+		opt.Width = 80
+	}
+
+	src := frontmatter.Body(markdown)
+	doc := goldmark.DefaultParser().Parse(text.NewReader(src))
+
+	t := &plainText{src: src, width: opt.Width, ansi: opt.ANSI}
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		t.block(n, "", "")
+	}
+
+	if len(t.links) > 0 {
+		t.buf.WriteString("References\n\n")
+		for i, l := range t.links {
+			t.buf.WriteString(t.style(dim, "["+strconv.Itoa(i+1)+"]") + " " + l + "\n")
+		}
+	}
+
+	return bytes.TrimSpace(t.buf.Bytes())
+}
+
+type TextOpts struct {
+	// Max width of lines. Defaults to 80.
+	Width int
+	// Styles the text with ANSI escape codes, such as bold headings and
+	// underlined links.
+	ANSI bool
+}
+
+type plainText struct {
+	src   []byte
+	width int
+	ansi  bool
+	buf   bytes.Buffer
+	links []string
+}
+
+// ANSI escape codes of a style, to enable and disable it.
+type style struct {
+	on, off string
+}
+
+var (
+	bold      = style{"\x1b[1m", "\x1b[22m"}
+	dim       = style{"\x1b[2m", "\x1b[22m"}
+	italic    = style{"\x1b[3m", "\x1b[23m"}
+	underline = style{"\x1b[4m", "\x1b[24m"}
+	cyan      = style{"\x1b[36m", "\x1b[39m"}
+	magenta   = style{"\x1b[35m", "\x1b[39m"}
+)
+
+// Returns the text with the style, if ANSI escape codes are enabled.
+func (t *plainText) style(st style, s string) string {
+	if !t.ansi || s == "" {
+		return s
+	}
+	return st.on + s + st.off
+}
+
+var escapeCode = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// Returns the width of the text on terminals, without escape codes.
+func visibleWidth(s string) int {
+	return utf8.RuneCountInString(escapeCode.ReplaceAllString(s, ""))
+}
+
+// Writes the block, prefixing the first line with first and the others with rest.
+func (t *plainText) block(n ast.Node, first, rest string) {
+	switch n := n.(type) {
+	case *ast.Heading:
+		s := t.inline(n)
+		if t.ansi {
+			if n.Level == 1 {
+				s = t.style(underline, s)
+			}
+			t.buf.WriteString(first + t.style(magenta, t.style(bold, s)) + "\n\n")
+			return
+		}
+		t.buf.WriteString(first + s + "\n")
+		switch n.Level {
+		case 1:
+			t.buf.WriteString(rest + strings.Repeat("=", visibleWidth(s)) + "\n")
+		case 2:
+			t.buf.WriteString(rest + strings.Repeat("-", visibleWidth(s)) + "\n")
+		}
+		t.buf.WriteString("\n")
+	case *ast.Paragraph, *ast.TextBlock:
+		t.wrap(t.inline(n), first, rest)
+		if _, ok := n.(*ast.TextBlock); !ok {
+			t.buf.WriteString("\n")
+		}
+	case *ast.List:
+		i := n.Start
+		for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+			marker := "* "
+			if n.IsOrdered() {
+				marker = strconv.Itoa(i) + ". "
+				i++
+			}
+			pad := strings.Repeat(" ", len(marker))
+			p := first + marker
+			for c := item.FirstChild(); c != nil; c = c.NextSibling() {
+				t.block(c, p, rest+pad)
+				p = rest + pad
+			}
+			first = rest
+		}
+		if n.Parent().Kind() == ast.KindDocument {
+			t.buf.WriteString("\n")
+		}
+	case *ast.Blockquote:
+		quote := t.style(dim, "> ")
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			t.block(c, first+quote, rest+quote)
+			first = rest
+		}
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		lines := n.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			line := lines.At(i)
+			t.buf.WriteString(rest + "    " + t.style(cyan, strings.TrimRight(string(line.Value(t.src)), "\r\n")) + "\n")
+		}
+		t.buf.WriteString("\n")
+	case *ast.ThematicBreak:
+		t.buf.WriteString(rest + "* * *\n\n")
+	}
+}
+
+// Writes the words of the text in lines of at most the width.
+func (t *plainText) wrap(s, first, rest string) {
+	prefix, line := first, ""
+	for _, w := range strings.Fields(s) {
+		if line != "" && visibleWidth(prefix+line+" "+w) > t.width {
+			t.buf.WriteString(prefix + line + "\n")
+			prefix, line = rest, ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += w
+	}
+	if line != "" {
+		t.buf.WriteString(prefix + line + "\n")
+	}
+}
+
+// Returns the text of the inline children of the node, numbering their links.
+func (t *plainText) inline(n ast.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch c := c.(type) {
+		case *ast.Text:
+			b.Write(c.Segment.Value(t.src))
+			if c.SoftLineBreak() || c.HardLineBreak() {
+				b.WriteString(" ")
+			}
+		case *ast.String:
+			b.Write(c.Value)
+		case *ast.Emphasis:
+			st := italic
+			if c.Level > 1 {
+				st = bold
+			}
+			b.WriteString(t.styleWords(st, t.inline(c)))
+		case *ast.CodeSpan:
+			b.WriteString(t.styleWords(cyan, t.inline(c)))
+		case *ast.Link:
+			b.WriteString(t.styleWords(underline, t.inline(c)) + " " + t.reference(string(c.Destination)))
+		case *ast.AutoLink:
+			b.WriteString(t.style(underline, string(c.URL(t.src))))
+		case *ast.Image:
+			b.WriteString("[image: " + t.inline(c) + "] " + t.reference(string(c.Destination)))
+		case *ast.RawHTML:
+		default:
+			b.WriteString(t.inline(c))
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// Styles each word of the text, so the style is kept when lines are wrapped.
+func (t *plainText) styleWords(st style, s string) string {
+	if !t.ansi {
+		return s
+	}
+	words := strings.Fields(s)
+	for i, w := range words {
+		words[i] = t.style(st, w)
+	}
+	return strings.Join(words, " ")
+}
+
+func (t *plainText) reference(url string) string {
+	t.links = append(t.links, url)
+	return t.style(dim, "["+strconv.Itoa(len(t.links))+"]")
+}
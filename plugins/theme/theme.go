@@ -0,0 +1,458 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package theme provides the light and dark variants of the theme of the site,
+// negotiating the color scheme of each request on the server, so pages are
+// rendered with the right critical CSS and images without scripts that swap
+// them after the page loads.
+//
+// The scheme of each request is available to:
+//
+//   - middlewares and renderers that implement [plugin.ContextRenderer], with
+//     [FromContext];
+//   - templates, with the "theme" metadata of files, or the functions of the
+//     plugin's FuncMap.
+package theme
+
+import (
+	"context"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-theme-sourcer"
+
+// Key of the metadata with the scheme of the request.
+const Key = "theme"
+
+// Key of the metadata with the image of files, used on "og:image" tags. Files
+// can set images for each scheme with "image.light" and "image.dark".
+const ImageKey = "image"
+
+// Header of the client hint with the color scheme preferred by the visitor.
+const HintHeader = "Sec-CH-Prefers-Color-Scheme"
+
+// A color scheme of the theme.
+type Scheme string
+
+const (
+	// The scheme preferred by the visitor, which isn't known by the server, so
+	// pages should support both schemes with "prefers-color-scheme" media queries.
+	Auto  Scheme = "auto"
+	Light Scheme = "light"
+	Dark  Scheme = "dark"
+)
+
+func parseScheme(s string) (Scheme, bool) {
+	switch Scheme(strings.ToLower(strings.TrimSpace(strings.Trim(s, `"`)))) {
+	case Auto:
+		return Auto, true
+	case Light:
+		return Light, true
+	case Dark:
+		return Dark, true
+	default:
+		return "", false
+	}
+}
+
+// Assets of a scheme of the theme.
+type Variant struct {
+	// CSS needed to render the first paint of pages, inlined on their head.
+	CriticalCSS string
+	// Image used by files that don't have one, such as on their "og:image" tag.
+	Image string
+}
+
+type schemeKey struct{}
+
+// Returns the scheme of the request of the context, set by the middleware of
+// the plugin, or [Auto] if none was set.
+func FromContext(ctx context.Context) Scheme {
+	s, ok := ctx.Value(schemeKey{}).(Scheme)
+	if !ok {
+		return Auto
+	}
+	return s
+}
+
+// The theme plugin, which wraps a [plugin.Sourcer] to keep it's last file system
+// and is a [plugin.Middleware] that sets the scheme of requests and serves the
+// endpoint that overrides it.
+type Plugin interface {
+	plugin.Sourcer
+	plugin.Middleware
+
+	// Functions for templates:
+	//
+	//   - "themeCSS METADATA" returns the critical CSS of the scheme of the render
+	//     of the file of the metadata. On [Auto], it's the CSS of the light variant
+	//     followed by the CSS of the dark variant in a "prefers-color-scheme: dark"
+	//     media query;
+	//   - "themeImage METADATA" returns the image of the file for the scheme, from
+	//     the "image.<scheme>" or "image" metadata, or the image of the variant. On
+	//     [Auto], the light image is used;
+	//   - "themeScheme METADATA" returns the scheme, such as "dark".
+	FuncMap() template.FuncMap
+}
+
+// Creates the theme [Plugin] of the variants, wrapping the sourcer.
+//
+// The scheme of each request is taken from the cookie of Opts.Cookie, then from
+// the [HintHeader] client hint, which the middleware asks browsers to send, and
+// otherwise is Opts.Default. Renders of each scheme are cached separately (see
+// [core.WithCacheVariant]).
+//
+// Requests to Opts.Path with "?scheme=light", "dark" or "auto" set the cookie,
+// overriding the preference of the browser, and redirect back to the page of
+// the "redirect" parameter, which needs to be a path on the site, or the
+// referring page, so theme toggles can be plain links or forms.
+func New(sourcer plugin.Sourcer, light, dark Variant, opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Default == "" {
+		opt.Default = Auto
+	}
+	if opt.Path == "" {
+		opt.Path = "/theme"
+	}
+	if opt.Cookie == "" {
+		opt.Cookie = "blogo-theme"
+	}
+	if opt.CookieMaxAge == 0 {
+		opt.CookieMaxAge = 365 * 24 * time.Hour
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be wrapped should not be nil")
+
+	return &p{
+		sourcer: sourcer,
+		light:   light,
+		dark:    dark,
+
+		def:          opt.Default,
+		path:         opt.Path,
+		cookie:       opt.Cookie,
+		cookieMaxAge: opt.CookieMaxAge,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Scheme of requests without a preference. Defaults to [Auto].
+	Default Scheme
+	// Path of the endpoint that overrides the scheme. Defaults to "/theme".
+	Path string
+	// Cookie where the scheme chosen by visitors is kept. Defaults to "blogo-theme".
+	Cookie string
+	// Max age of the cookie. Defaults to 1 year.
+	CookieMaxAge time.Duration
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	sourcer plugin.Sourcer
+	light   Variant
+	dark    Variant
+
+	def          Scheme
+	path         string
+	cookie       string
+	cookieMaxAge time.Duration
+
+	mu   sync.RWMutex
+	fsys fs.FS
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Source() (fs.FS, error) {
+	p.assert.NotNil(p.sourcer)
+
+	fsys, err := p.sourcer.Source()
+	if err != nil {
+		return fsys, err
+	}
+
+	p.mu.Lock()
+	p.fsys = fsys
+	p.mu.Unlock()
+
+	return fsys, nil
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == p.path {
+			p.serveOverride(w, r)
+			return
+		}
+
+		scheme := p.selectScheme(r)
+
+		ctx := context.WithValue(r.Context(), schemeKey{}, scheme)
+
+		// Renders on the auto scheme are the same as the default ones.
+		if scheme != Auto {
+			p.mu.RLock()
+			fsys := p.fsys
+			p.mu.RUnlock()
+
+			if fsys != nil {
+				ctx = core.WithFiles(ctx, &themeFS{FS: fsys, scheme: scheme})
+			}
+			ctx = core.WithCacheVariant(ctx, cacheVariant(ctx, scheme))
+		}
+
+		w.Header().Add("Accept-CH", HintHeader)
+		w.Header().Add("Vary", "Cookie")
+		w.Header().Add("Vary", HintHeader)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (p *p) serveOverride(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	scheme, ok := parseScheme(r.FormValue("scheme"))
+	if !ok {
+		http.Error(w, "Unknown color scheme", http.StatusBadRequest)
+		return
+	}
+
+	p.log.Debug("Overriding color scheme", slog.String("scheme", string(scheme)))
+
+	c := &http.Cookie{
+		Name:     p.cookie,
+		Value:    string(scheme),
+		Path:     "/",
+		MaxAge:   int(p.cookieMaxAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if scheme == Auto {
+		c.Value, c.MaxAge = "", -1
+	}
+	http.SetCookie(w, c)
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, redirectPath(r), http.StatusSeeOther)
+}
+
+// Returns the page to go back after the override, only allowing paths of the
+// site, so the endpoint can't be used to redirect to other sites.
+func redirectPath(r *http.Request) string {
+	if to := r.FormValue("redirect"); localPath(to) {
+		return to
+	}
+	if ref, err := url.Parse(r.Referer()); err == nil && ref.Host == r.Host && localPath(ref.Path) {
+		if ref.RawQuery != "" {
+			return ref.Path + "?" + ref.RawQuery
+		}
+		return ref.Path
+	}
+	return "/"
+}
+
+func localPath(s string) bool {
+	return strings.HasPrefix(s, "/") && !strings.HasPrefix(s, "//") && !strings.ContainsAny(s, "\\\r\n")
+}
+
+func (p *p) selectScheme(r *http.Request) Scheme {
+	if c, err := r.Cookie(p.cookie); err == nil {
+		if s, ok := parseScheme(c.Value); ok && s != Auto {
+			return s
+		}
+	}
+	if s, ok := parseScheme(r.Header.Get(HintHeader)); ok && s != Auto {
+		return s
+	}
+	return p.def
+}
+
+func (p *p) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"themeCSS": func(m metadata.Metadata) template.CSS {
+			switch scheme(m) {
+			case Light:
+				return template.CSS(p.light.CriticalCSS)
+			case Dark:
+				return template.CSS(p.dark.CriticalCSS)
+			default:
+				css := p.light.CriticalCSS
+				if p.dark.CriticalCSS != "" {
+					css += "\n@media (prefers-color-scheme: dark) {\n" + p.dark.CriticalCSS + "\n}\n"
+				}
+				return template.CSS(css)
+			}
+		},
+		"themeImage": func(m metadata.Metadata) string {
+			s := scheme(m)
+			if s == Auto {
+				s = Light
+			}
+			if img, err := metadata.GetTyped[string](m, ImageKey+"."+string(s)); err == nil && img != "" {
+				return img
+			}
+			if img, err := metadata.GetTyped[string](m, ImageKey); err == nil && img != "" {
+				return img
+			}
+			if s == Dark && p.dark.Image != "" {
+				return p.dark.Image
+			}
+			return p.light.Image
+		},
+		"themeScheme": func(m metadata.Metadata) string {
+			return string(scheme(m))
+		},
+	}
+}
+
+// Returns the scheme of the metadata, set by the file system of the middleware.
+func scheme(m metadata.Metadata) Scheme {
+	if m == nil {
+		return Auto
+	}
+	v, err := metadata.GetTyped[string](m, Key)
+	if err != nil {
+		return Auto
+	}
+	if s, ok := parseScheme(v); ok {
+		return s
+	}
+	return Auto
+}
+
+// Returns the cache variant of the scheme, keeping the variant already set on
+// the context, if any, such as by the flags plugin.
+func cacheVariant(ctx context.Context, scheme Scheme) string {
+	v := "theme=" + string(scheme)
+	if prev, ok := core.CacheVariantFromContext(ctx); ok {
+		return prev + "&" + v
+	}
+	return v
+}
+
+// File system that sets the scheme on the metadata of files.
+type themeFS struct {
+	fs.FS
+	scheme Scheme
+}
+
+func (fsys *themeFS) Metadata() metadata.Metadata {
+	if m, err := metadata.GetMetadata(fsys.FS); err == nil {
+		return m
+	}
+	return metadata.Map(map[string]any{})
+}
+
+func (fsys *themeFS) Open(name string) (fs.File, error) {
+	f, err := fsys.FS.Open(name)
+	if err != nil {
+		return f, err
+	}
+
+	var fm metadata.Metadata = metadata.Map(map[string]any{})
+	if m, err := metadata.GetMetadata(f); err == nil {
+		fm = m
+	}
+	m := metadata.Join(metadata.Map(map[string]any{Key: string(fsys.scheme)}), fm)
+
+	if d, ok := f.(fs.ReadDirFile); ok {
+		return &dirFile{ReadDirFile: d, metadata: m}, nil
+	}
+	if _, ok := f.(io.Seeker); ok {
+		return &seekerFile{file{File: f, metadata: m}}, nil
+	}
+	return &file{File: f, metadata: m}, nil
+}
+
+type file struct {
+	fs.File
+	metadata metadata.Metadata
+}
+
+func (f *file) Metadata() metadata.Metadata {
+	return f.metadata
+}
+
+// Keeps files that implement [io.Seeker] seekable, so renderers can read them
+// more than once.
+type seekerFile struct {
+	file
+}
+
+func (f *seekerFile) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+// Keeps directories readable, so listings can also depend on the scheme.
+type dirFile struct {
+	fs.ReadDirFile
+	metadata metadata.Metadata
+}
+
+func (f *dirFile) Metadata() metadata.Metadata {
+	return f.metadata
+}
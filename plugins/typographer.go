@@ -0,0 +1,254 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugins
+
+import (
+	"errors"
+	"html"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const typographerName = "blogo-typographer-renderer"
+
+// Default emoji shortcodes used by [NewTypographer]. Users can add or overwrite
+// shortcodes using [TypographerOpts].Emojis.
+var DefaultEmojis = map[string]string{
+	"+1":               "👍",
+	"-1":               "👎",
+	"100":              "💯",
+	"blush":            "😊",
+	"boom":             "💥",
+	"bug":              "🐛",
+	"check":            "✔️",
+	"clap":             "👏",
+	"coffee":           "☕",
+	"confused":         "😕",
+	"cry":              "😢",
+	"eyes":             "👀",
+	"fire":             "🔥",
+	"grin":             "😁",
+	"heart":            "❤️",
+	"hourglass":        "⌛",
+	"bulb":             "💡",
+	"joy":              "😂",
+	"laughing":         "😆",
+	"memo":             "📝",
+	"ok_hand":          "👌",
+	"party":            "🥳",
+	"pray":             "🙏",
+	"rocket":           "🚀",
+	"see_no_evil":      "🙈",
+	"smile":            "😄",
+	"smiley":           "😃",
+	"sparkles":         "✨",
+	"star":             "⭐",
+	"sweat_smile":      "😅",
+	"tada":             "🎉",
+	"thinking":         "🤔",
+	"thumbsdown":       "👎",
+	"thumbsup":         "👍",
+	"warning":          "⚠️",
+	"wave":             "👋",
+	"wink":             "😉",
+	"x":                "❌",
+	"zap":              "⚡",
+	"white_check_mark": "✅",
+}
+
+// Elements which text contents are not changed by the [NewTypographer] renderer.
+var typographerSkippedElements = []string{
+	"code", "kbd", "pre", "samp", "script", "style", "textarea", "var",
+}
+
+var typographerEmojiPattern = regexp.MustCompile(`:([a-z0-9_+\-]+):`)
+
+// Creates a [plugin.Renderer] that transforms already rendered HTML, converting
+// ":emoji:" shortcodes to their unicode characters and applying typographic
+// replacements (smart quotes, dashes and ellipsis) on text nodes, so the output
+// is consistent independently of which renderer was used to produce the HTML.
+//
+// Text inside code, pre, script and similar elements is left untouched.
+//
+// This renderer is intended to be used after other renderers in a [FoldingRenderer].
+func NewTypographer(opts ...TypographerOpts) plugin.Renderer {
+	opt := TypographerOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	emojis := maps.Clone(DefaultEmojis)
+	maps.Copy(emojis, opt.Emojis)
+
+	return &typographer{
+		emojis: emojis,
+
+		disableEmojis:      opt.DisableEmojis,
+		disableSmartQuotes: opt.DisableSmartQuotes,
+		disableDashes:      opt.DisableDashes,
+		disableEllipsis:    opt.DisableEllipsis,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type TypographerOpts struct {
+	// Additional emoji shortcodes (without the surrounding colons), overwriting
+	// the ones on [DefaultEmojis] if they have the same name.
+	Emojis map[string]string
+
+	DisableEmojis      bool
+	DisableSmartQuotes bool
+	DisableDashes      bool
+	DisableEllipsis    bool
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type typographer struct {
+	emojis map[string]string
+
+	disableEmojis      bool
+	disableSmartQuotes bool
+	disableDashes      bool
+	disableEllipsis    bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (r *typographer) Name() string {
+	return typographerName
+}
+
+func (r *typographer) Render(src fs.File, w io.Writer) error {
+	r.assert.NotNil(src)
+	r.assert.NotNil(w)
+	r.assert.NotNil(r.emojis)
+	r.assert.NotNil(r.log)
+
+	if _, ok := src.(fs.ReadDirFile); ok {
+		return errors.New("does not support directories")
+	}
+
+	contents, err := io.ReadAll(src)
+	if err != nil {
+		return errors.Join(errors.New("failed to read file contents"), err)
+	}
+
+	skip := 0
+	prev := ' '
+
+	for _, tok := range tokenizeHTML(contents) {
+		switch tok.Kind {
+		case htmlStartTagToken:
+			if slices.Contains(typographerSkippedElements, tok.Tag) {
+				skip++
+			}
+		case htmlEndTagToken:
+			if skip > 0 && slices.Contains(typographerSkippedElements, tok.Tag) {
+				skip--
+			}
+		case htmlTextToken:
+			if skip > 0 {
+				break
+			}
+
+			var s string
+			s, prev = r.transform(html.UnescapeString(string(tok.Raw)), prev)
+
+			if _, err := io.WriteString(w, escapeHTMLText(s)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err := w.Write(tok.Raw); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *typographer) transform(s string, prev rune) (string, rune) {
+	if !r.disableEmojis {
+		s = typographerEmojiPattern.ReplaceAllStringFunc(s, func(m string) string {
+			if e, ok := r.emojis[strings.Trim(m, ":")]; ok {
+				return e
+			}
+			return m
+		})
+	}
+
+	if !r.disableDashes {
+		s = strings.ReplaceAll(s, "---", "—")
+		s = strings.ReplaceAll(s, "--", "–")
+	}
+
+	if !r.disableEllipsis {
+		s = strings.ReplaceAll(s, "...", "…")
+	}
+
+	if r.disableSmartQuotes {
+		if len(s) > 0 {
+			prev = []rune(s)[len([]rune(s))-1]
+		}
+		return s, prev
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+
+	for _, c := range s {
+		opening := unicode.IsSpace(prev) || strings.ContainsRune("([{<—–-", prev)
+
+		switch {
+		case c == '"' && opening:
+			b.WriteRune('“')
+		case c == '"':
+			b.WriteRune('”')
+		case c == '\'' && opening:
+			b.WriteRune('‘')
+		case c == '\'':
+			b.WriteRune('’')
+		default:
+			b.WriteRune(c)
+		}
+
+		prev = c
+	}
+
+	return b.String(), prev
+}
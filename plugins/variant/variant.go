@@ -0,0 +1,510 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package variant provides A/B testing of pages, assigning each visitor to a
+// variant of the [Experiment]s and serving alternate versions of the pages,
+// such as other titles or layouts.
+//
+// Files declare the values of each variant on the "variants" key of their
+// metadata, which override the values of the file for visitors of the variant:
+//
+//	title: The original title
+//	variants:
+//	  shorter:
+//	    title: A shorter title
+//
+// The variant of each experiment is also set as the "variant.<experiment>"
+// metadata key, so templates can render anything else differently. Renderers
+// that implement [plugin.ContextRenderer] can use [FromContext] instead.
+package variant
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-variant-sourcer"
+
+// Key of the metadata of files with the values of each variant.
+const VariantsKey = "variants"
+
+// Prefix of the metadata keys with the variant of each experiment.
+const KeyPrefix = "variant."
+
+// A test of alternate versions of pages.
+type Experiment struct {
+	Name string
+	// Names of the variants. The first one is considered the control.
+	Variants []string
+	// Relative weights of the variants, in the same order. By default all
+	// variants have the same weight.
+	Weights []int
+	// Patterns of the paths of the files that are part of the experiment, using
+	// the syntax of [path.Match]. By default all files are.
+	Paths []string
+}
+
+func (e Experiment) matches(name string) bool {
+	if len(e.Paths) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(e.Paths, func(p string) bool {
+		ok, _ := path.Match(strings.Trim(p, "/"), name)
+		return ok
+	})
+}
+
+// Picks a random variant, using the weights of the experiment.
+func (e Experiment) pick() string {
+	total := 0
+	for i := range e.Variants {
+		total += e.weight(i)
+	}
+	if total <= 0 {
+		return e.Variants[0]
+	}
+
+	n := rand.IntN(total)
+	for i, v := range e.Variants {
+		if n -= e.weight(i); n < 0 {
+			return v
+		}
+	}
+	return e.Variants[0]
+}
+
+func (e Experiment) weight(i int) int {
+	if i < len(e.Weights) {
+		return max(e.Weights[i], 0)
+	}
+	return 1
+}
+
+// Hook to select the variant of new visitors, such as by their account. The
+// variant is ignored if it isn't one of the experiment's.
+type Selector interface {
+	Select(r *http.Request, e Experiment) (variant string, ok bool)
+}
+
+// Type adapter to allow the use of ordinary functions as [Selector] implementations.
+type SelectorFunc func(r *http.Request, e Experiment) (string, bool)
+
+func (f SelectorFunc) Select(r *http.Request, e Experiment) (string, bool) {
+	return f(r, e)
+}
+
+// The variants of a request, by the name of their experiment.
+type Variants map[string]string
+
+// Encodes the variants as a URL query, sorted by experiment.
+func (vs Variants) String() string {
+	q := url.Values{}
+	for e, v := range vs {
+		q.Set(e, v)
+	}
+	return q.Encode()
+}
+
+type variantsKey struct{}
+
+// Holder of the variants of a request, so the middleware can record them on
+// contexts created before it runs.
+type tracked struct {
+	mu       sync.Mutex
+	variants Variants
+}
+
+// Returns a context where the variants selected by the middleware are recorded,
+// so handlers that wrap the middleware, such as the analytics plugin, can read
+// them with [FromContext] after the request is served.
+func Track(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(variantsKey{}).(*tracked); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, variantsKey{}, &tracked{})
+}
+
+// Returns the variants selected for the request of the context.
+func FromContext(ctx context.Context) Variants {
+	t, ok := ctx.Value(variantsKey{}).(*tracked)
+	if !ok {
+		return Variants{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.variants == nil {
+		return Variants{}
+	}
+	return maps.Clone(t.variants)
+}
+
+// The variant plugin, which wraps a [plugin.Sourcer] to keep it's last file
+// system and is a [plugin.Middleware] that serves the variants of the files.
+type Plugin interface {
+	plugin.Sourcer
+	plugin.Middleware
+}
+
+// Creates the variant [Plugin] of the experiments, wrapping the sourcer.
+//
+// The variant of each experiment is taken from the header of Opts.Header (for
+// example "X-Blogo-Variant: title=shorter", useful to review variants), then the
+// cookie of Opts.Cookie, then Opts.Selector, and otherwise is picked at random.
+// Assignments are kept on the cookie, so visitors see the same variant on
+// every visit.
+//
+// Renders of each combination of variants are cached separately (see
+// [core.WithCacheVariant]).
+func New(sourcer plugin.Sourcer, experiments []Experiment, opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Header == "" {
+		opt.Header = "X-Blogo-Variant"
+	}
+	if opt.Cookie == "" {
+		opt.Cookie = "blogo-variant"
+	}
+	if opt.CookieMaxAge == 0 {
+		opt.CookieMaxAge = 30 * 24 * time.Hour
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be wrapped should not be nil")
+
+	experiments = slices.DeleteFunc(slices.Clone(experiments), func(e Experiment) bool {
+		return e.Name == "" || len(e.Variants) == 0
+	})
+
+	return &p{
+		sourcer:     sourcer,
+		experiments: experiments,
+
+		header:       opt.Header,
+		cookie:       opt.Cookie,
+		cookieMaxAge: opt.CookieMaxAge,
+		selector:     opt.Selector,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Header of requests that forces variants, in the same format as the cookie.
+	// Defaults to "X-Blogo-Variant".
+	Header string
+	// Cookie where the variants of visitors are kept, as a URL query of the
+	// experiments and their variants. Defaults to "blogo-variant".
+	Cookie string
+	// Max age of the cookie. Defaults to 30 days.
+	CookieMaxAge time.Duration
+	// Selector of the variants of new visitors. By default variants are picked at
+	// random, using the weights of the experiment.
+	Selector Selector
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	sourcer     plugin.Sourcer
+	experiments []Experiment
+
+	header       string
+	cookie       string
+	cookieMaxAge time.Duration
+	selector     Selector
+
+	mu   sync.RWMutex
+	fsys fs.FS
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Source() (fs.FS, error) {
+	p.assert.NotNil(p.sourcer)
+
+	fsys, err := p.sourcer.Source()
+	if err != nil {
+		return fsys, err
+	}
+
+	p.mu.Lock()
+	p.fsys = fsys
+	p.mu.Unlock()
+
+	return fsys, nil
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.RLock()
+		fsys := p.fsys
+		p.mu.RUnlock()
+
+		name := strings.Trim(r.URL.Path, "/")
+		if name == "" {
+			name = "."
+		}
+
+		vs := p.selectVariants(w, r, name)
+		if fsys == nil || len(vs) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := Track(r.Context())
+		t := ctx.Value(variantsKey{}).(*tracked)
+		t.mu.Lock()
+		t.variants = vs
+		t.mu.Unlock()
+
+		ctx = core.WithFiles(ctx, &variantFS{FS: fsys, variants: vs})
+		ctx = core.WithCacheVariant(ctx, vs.String())
+
+		w.Header().Add("Vary", "Cookie")
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Returns the variants of the experiments of the file, assigning the variants
+// of new visitors and updating the cookie.
+func (p *p) selectVariants(w http.ResponseWriter, r *http.Request, name string) Variants {
+	forced := parseVariants(r.Header.Get(p.header))
+
+	kept := Variants{}
+	if c, err := r.Cookie(p.cookie); err == nil {
+		kept = parseVariants(c.Value)
+	}
+
+	vs := Variants{}
+	assigned := false
+	for _, e := range p.experiments {
+		if v, ok := forced[e.Name]; ok && slices.Contains(e.Variants, v) {
+			if e.matches(name) {
+				vs[e.Name] = v
+			}
+			continue
+		}
+
+		if v, ok := kept[e.Name]; ok && slices.Contains(e.Variants, v) {
+			if e.matches(name) {
+				vs[e.Name] = v
+			}
+			continue
+		}
+		if !e.matches(name) {
+			continue
+		}
+
+		v, ok := "", false
+		if p.selector != nil {
+			v, ok = p.selector.Select(r, e)
+		}
+		if !ok || !slices.Contains(e.Variants, v) {
+			v = e.pick()
+		}
+
+		p.log.Debug("Assigned variant",
+			slog.String("experiment", e.Name), slog.String("variant", v))
+
+		vs[e.Name] = v
+		kept[e.Name] = v
+		assigned = true
+	}
+
+	if assigned {
+		http.SetCookie(w, &http.Cookie{
+			Name:     p.cookie,
+			Value:    kept.String(),
+			Path:     "/",
+			MaxAge:   int(p.cookieMaxAge.Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	return vs
+}
+
+func parseVariants(s string) Variants {
+	vs := Variants{}
+	if s == "" {
+		return vs
+	}
+
+	q, err := url.ParseQuery(s)
+	if err != nil {
+		return vs
+	}
+	for e := range q {
+		vs[e] = q.Get(e)
+	}
+	return vs
+}
+
+// File system that overrides the metadata of files with the values of their variants.
+type variantFS struct {
+	fs.FS
+	variants Variants
+}
+
+func (fsys *variantFS) Metadata() metadata.Metadata {
+	if m, err := metadata.GetMetadata(fsys.FS); err == nil {
+		return m
+	}
+	return metadata.Map(map[string]any{})
+}
+
+func (fsys *variantFS) Open(name string) (fs.File, error) {
+	f, err := fsys.FS.Open(name)
+	if err != nil {
+		return f, err
+	}
+
+	// Directories are kept as is, so renderers can still read their entries.
+	if _, ok := f.(fs.ReadDirFile); ok {
+		return f, nil
+	}
+
+	var fm metadata.Metadata = metadata.Map(map[string]any{})
+	if m, err := metadata.GetMetadata(f); err == nil {
+		fm = m
+	}
+
+	m := metadata.Join(variantMetadata(fm, fsys.variants), fm)
+
+	if _, ok := f.(io.Seeker); ok {
+		return &seekerFile{file{File: f, metadata: m}}, nil
+	}
+	return &file{File: f, metadata: m}, nil
+}
+
+// Returns the values of the variants declared on the metadata, and the keys of
+// the variant of each experiment. Experiments are applied in order of their
+// name, so the values of later experiments take precedence.
+func variantMetadata(m metadata.Metadata, vs Variants) metadata.Metadata {
+	values := metadata.Map(map[string]any{})
+
+	experiments := make([]string, 0, len(vs))
+	for e := range vs {
+		experiments = append(experiments, e)
+	}
+	slices.Sort(experiments)
+
+	declared, _ := metadata.Get(m, VariantsKey)
+
+	for _, e := range experiments {
+		v := vs[e]
+		values[KeyPrefix+e] = v
+
+		for k, val := range stringKeys(lookup(declared, v)) {
+			values[k] = val
+		}
+	}
+
+	return values
+}
+
+func lookup(m any, key string) any {
+	switch m := m.(type) {
+	case map[string]any:
+		return m[key]
+	case map[any]any:
+		return m[key]
+	default:
+		return nil
+	}
+}
+
+// Converts the maps decoded from YAML, which have keys of any type, to maps of
+// strings.
+func stringKeys(v any) map[string]any {
+	switch v := v.(type) {
+	case map[string]any:
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = val
+		}
+		return m
+	default:
+		return nil
+	}
+}
+
+type file struct {
+	fs.File
+	metadata metadata.Metadata
+}
+
+func (f *file) Metadata() metadata.Metadata {
+	return f.metadata
+}
+
+// Keeps files that implement [io.Seeker] seekable, so renderers can read them
+// more than once.
+type seekerFile struct {
+	file
+}
+
+func (f *seekerFile) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
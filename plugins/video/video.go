@@ -0,0 +1,384 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package video provides first-class support for video files: a [plugin.Middleware]
+// that serves them with range requests (so players can seek), generates poster
+// thumbnails with a [PosterGenerator], and template functions to render the
+// video element with the caption tracks discovered alongside the file.
+package video
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/index"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-video-middleware"
+
+// Default extensions of video files.
+var DefaultExtensions = []string{".mp4", ".webm", ".mov", ".ogv", ".m4v"}
+
+// Extensions of images used as posters if they are alongside the video file with
+// the same name, for example "clip.jpg" for "clip.mp4".
+var posterExtensions = []string{".jpg", ".jpeg", ".png", ".webp"}
+
+// Generates a poster image of a video, returning the image and it's content type.
+type PosterGenerator interface {
+	Poster(ctx context.Context, video io.Reader) (image []byte, contentType string, err error)
+}
+
+// Type adapter to allow the use of ordinary functions as [PosterGenerator] implementations.
+type PosterGeneratorFunc func(ctx context.Context, video io.Reader) ([]byte, string, error)
+
+func (f PosterGeneratorFunc) Poster(ctx context.Context, video io.Reader) ([]byte, string, error) {
+	return f(ctx, video)
+}
+
+// Creates a [PosterGenerator] that uses ffmpeg to extract a JPEG frame of the video
+// at the offset. The video is written to a temporary file, since most containers
+// can't be read from a pipe.
+func NewFFmpegPoster(offset time.Duration, ffmpeg ...string) PosterGenerator {
+	bin := "ffmpeg"
+	if len(ffmpeg) > 0 && ffmpeg[0] != "" {
+		bin = ffmpeg[0]
+	}
+
+	return PosterGeneratorFunc(func(ctx context.Context, video io.Reader) ([]byte, string, error) {
+		tmp, err := os.CreateTemp("", "blogo-video-*")
+		if err != nil {
+			return nil, "", errors.Join(errors.New("failed to create temporary file"), err)
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		if _, err := io.Copy(tmp, video); err != nil {
+			return nil, "", errors.Join(errors.New("failed to write temporary file"), err)
+		}
+
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, bin,
+			"-ss", fmt.Sprintf("%.3f", offset.Seconds()),
+			"-i", tmp.Name(),
+			"-frames:v", "1",
+			"-f", "image2", "-c:v", "mjpeg",
+			"pipe:1",
+		)
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+		if err := cmd.Run(); err != nil {
+			return nil, "", errors.Join(
+				errors.New("failed to run ffmpeg: "+strings.TrimSpace(stderr.String())),
+				err,
+			)
+		}
+
+		return stdout.Bytes(), "image/jpeg", nil
+	})
+}
+
+// The video plugin, which is a [plugin.Middleware] serving video files and
+// posters, and provides template functions to render videos.
+type Plugin interface {
+	plugin.Middleware
+	// Returns the template functions:
+	//
+	//   - "video PATH" renders a video element of the video at the path, with it's
+	//     poster and the caption tracks found alongside it.
+	FuncMap() template.FuncMap
+}
+
+// Creates the video [Plugin], serving the video files of the file system of the
+// index with support for range requests. Requests with the "poster" query
+// parameter serve the poster of the video: a image alongside the video with the
+// same name, or one generated by Opts.Posters.
+func New(provider index.Provider, opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Extensions == nil {
+		opt.Extensions = DefaultExtensions
+	}
+	if opt.Timeout == 0 {
+		opt.Timeout = time.Minute
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(provider, "Index provider should not be nil")
+
+	return &p{
+		provider:   provider,
+		extensions: opt.Extensions,
+		posters:    opt.Posters,
+		timeout:    opt.Timeout,
+
+		cache: map[string]poster{},
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Extensions of the files served as videos. Defaults to [DefaultExtensions].
+	Extensions []string
+	// Generates posters of videos that don't have a image alongside them. By
+	// default posters aren't generated, see [NewFFmpegPoster].
+	Posters PosterGenerator
+	// Max duration of the generation of a poster. Defaults to 1 minute.
+	Timeout time.Duration
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	provider   index.Provider
+	extensions []string
+	posters    PosterGenerator
+	timeout    time.Duration
+
+	cacheMu sync.Mutex
+	cache   map[string]poster
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+type poster struct {
+	modTime     time.Time
+	image       []byte
+	contentType string
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.provider)
+		p.assert.NotNil(p.log)
+
+		name := strings.Trim(r.URL.Path, "/")
+		if !slices.Contains(p.extensions, strings.ToLower(path.Ext(name))) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		idx, err := p.provider.Index()
+		if err != nil {
+			p.log.Error("Failed to get file system for videos", slog.String("err", err.Error()))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		f, err := idx.FS().Open(name)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer f.Close()
+
+		stat, err := f.Stat()
+		if err != nil || stat.IsDir() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		log := p.log.With(slog.String("video", name))
+
+		if r.URL.Query().Has("poster") {
+			p.servePoster(w, r, idx.FS(), name, f, stat, log)
+			return
+		}
+
+		rs, ok := f.(io.ReadSeeker)
+		if !ok {
+			log.Debug("Video file is not seekable, reading it to memory")
+
+			b, err := io.ReadAll(f)
+			if err != nil {
+				log.Error("Failed to read video file", slog.String("err", err.Error()))
+				http.Error(w, "Failed to read video", http.StatusInternalServerError)
+				return
+			}
+			rs = bytes.NewReader(b)
+		}
+
+		http.ServeContent(w, r, stat.Name(), stat.ModTime(), rs)
+	})
+}
+
+func (p *p) servePoster(
+	w http.ResponseWriter,
+	r *http.Request,
+	fsys fs.FS,
+	name string,
+	video fs.File,
+	stat fs.FileInfo,
+	log *slog.Logger,
+) {
+	if img := findPoster(fsys, name); img != "" {
+		http.Redirect(w, r, "/"+img, http.StatusFound)
+		return
+	}
+
+	if p.posters == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	p.cacheMu.Lock()
+	cached, ok := p.cache[name]
+	p.cacheMu.Unlock()
+
+	if !ok || !cached.modTime.Equal(stat.ModTime()) {
+		log.Debug("Generating video poster")
+
+		ctx, cancel := context.WithTimeout(r.Context(), p.timeout)
+		defer cancel()
+
+		img, contentType, err := p.posters.Poster(ctx, video)
+		if err != nil {
+			log.Error("Failed to generate video poster", slog.String("err", err.Error()))
+			http.Error(w, "Failed to generate poster", http.StatusInternalServerError)
+			return
+		}
+
+		cached = poster{modTime: stat.ModTime(), image: img, contentType: contentType}
+
+		p.cacheMu.Lock()
+		p.cache[name] = cached
+		p.cacheMu.Unlock()
+	}
+
+	w.Header().Set("Content-Type", cached.contentType)
+	http.ServeContent(w, r, "", cached.modTime, bytes.NewReader(cached.image))
+}
+
+func findPoster(fsys fs.FS, name string) string {
+	base := strings.TrimSuffix(name, path.Ext(name))
+	for _, ext := range posterExtensions {
+		if _, err := fs.Stat(fsys, base+ext); err == nil {
+			return base + ext
+		}
+	}
+	return ""
+}
+
+// A caption track of a video.
+type Track struct {
+	Src      string
+	Language string
+	Label    string
+	Kind     string
+}
+
+// Finds the WebVTT caption tracks alongside the video, named "<video>.vtt" or
+// "<video>.<lang>.vtt", for example "clip.en.vtt" for "clip.mp4".
+func Tracks(fsys fs.FS, name string) []Track {
+	base := strings.TrimSuffix(name, path.Ext(name))
+
+	es, err := fs.ReadDir(fsys, path.Dir(name))
+	if err != nil {
+		return []Track{}
+	}
+
+	tracks := []Track{}
+	for _, e := range es {
+		p := path.Join(path.Dir(name), e.Name())
+		if e.IsDir() || path.Ext(p) != ".vtt" || !strings.HasPrefix(p, base+".") {
+			continue
+		}
+
+		lang := strings.TrimPrefix(strings.TrimSuffix(p, ".vtt"), base)
+		lang = strings.TrimPrefix(lang, ".")
+
+		kind := "captions"
+		if lang == "" {
+			kind = "subtitles"
+		}
+
+		t := Track{Src: "/" + p, Language: lang, Label: lang, Kind: kind}
+		if t.Label == "" {
+			t.Label = "Default"
+		}
+
+		tracks = append(tracks, t)
+	}
+
+	slices.SortFunc(tracks, func(a, b Track) int { return strings.Compare(a.Src, b.Src) })
+
+	return tracks
+}
+
+var videoTemplate = template.Must(template.New("video").Parse(
+	`<video controls preload="metadata" src="{{.Src}}"{{if .Poster}} poster="{{.Poster}}"{{end}}>` +
+		`{{range $i, $t := .Tracks}}<track kind="{{$t.Kind}}" src="{{$t.Src}}"` +
+		`{{if $t.Language}} srclang="{{$t.Language}}"{{end}} label="{{$t.Label}}"{{if eq $i 0}} default{{end}}>{{end}}` +
+		`<a href="{{.Src}}">Download video</a>` +
+		`</video>`,
+))
+
+func (p *p) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"video": func(name string) (template.HTML, error) {
+			name = strings.TrimPrefix(name, "/")
+
+			info := map[string]any{"Src": "/" + name, "Tracks": []Track{}}
+
+			if idx, err := p.provider.Index(); err == nil {
+				info["Tracks"] = Tracks(idx.FS(), name)
+				if img := findPoster(idx.FS(), name); img != "" {
+					info["Poster"] = "/" + img
+				}
+			}
+			if info["Poster"] == nil && p.posters != nil {
+				info["Poster"] = "/" + name + "?poster"
+			}
+
+			var b strings.Builder
+			if err := videoTemplate.Execute(&b, info); err != nil {
+				return "", fmt.Errorf("failed to render video: %w", err)
+			}
+			return template.HTML(b.String()), nil
+		},
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit provides simple in-memory rate limiters, used by plugins
+// that expose endpoints to limit how many requests each client can do.
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Limiter reports if a action identified by a key, such as the IP address of
+// a client, is allowed to be done at the moment.
+type Limiter interface {
+	Allow(key string) bool
+}
+
+// Type adapter to allow the use of ordinary functions as [Limiter] implementations.
+type LimiterFunc func(key string) bool
+
+func (f LimiterFunc) Allow(key string) bool {
+	return f(key)
+}
+
+// Creates a [Limiter] that allows up to limit actions per key in each window
+// of time. Expired windows are cleaned up periodically as new keys are seen.
+func NewFixedWindow(limit int, window time.Duration) Limiter {
+	return &fixedWindow{
+		limit:   limit,
+		window:  window,
+		windows: map[string]*windowCount{},
+	}
+}
+
+type fixedWindow struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	windows   map[string]*windowCount
+	lastClean time.Time
+}
+
+type windowCount struct {
+	count   int
+	expires time.Time
+}
+
+func (l *fixedWindow) Allow(key string) bool {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastClean) > l.window {
+		for k, w := range l.windows {
+			if now.After(w.expires) {
+				delete(l.windows, k)
+			}
+		}
+		l.lastClean = now
+	}
+
+	w, ok := l.windows[key]
+	if !ok || now.After(w.expires) {
+		w = &windowCount{expires: now.Add(l.window)}
+		l.windows[key] = w
+	}
+
+	if w.count >= l.limit {
+		return false
+	}
+
+	w.count++
+	return true
+}
+
+// Returns the IP address of the client of the request, without the port,
+// to be used as the key of a [Limiter]. Proxy headers are not trusted, use
+// a middleware that rewrites RemoteAddr if the server is behind one.
+func ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
@@ -0,0 +1,166 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Error replied by the server.
+type Error string
+
+func (err Error) Error() string {
+	return "redis: " + string(err)
+}
+
+// Connection to the server, speaking the RESP2 protocol.
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func dial(ctx context.Context, opt Opts) (*conn, error) {
+	d := &net.Dialer{Timeout: opt.DialTimeout}
+
+	var nc net.Conn
+	var err error
+	if opt.TLS != nil {
+		nc, err = (&tls.Dialer{NetDialer: d, Config: opt.TLS}).DialContext(ctx, "tcp", opt.Addr)
+	} else {
+		nc, err = d.DialContext(ctx, "tcp", opt.Addr)
+	}
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to connect to %q", opt.Addr), err)
+	}
+
+	c := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+
+	if opt.Password != "" {
+		args := []string{"AUTH", opt.Password}
+		if opt.Username != "" {
+			args = []string{"AUTH", opt.Username, opt.Password}
+		}
+		if _, err := c.do(ctx, args...); err != nil {
+			c.Close()
+			return nil, errors.Join(errors.New("failed to authenticate"), err)
+		}
+	}
+	if opt.DB != 0 {
+		if _, err := c.do(ctx, "SELECT", strconv.Itoa(opt.DB)); err != nil {
+			c.Close()
+			return nil, errors.Join(errors.New("failed to select database"), err)
+		}
+	}
+
+	return c, nil
+}
+
+// Sends the command and reads it's reply. Errors replied by the server are
+// returned as [Error].
+func (c *conn) do(ctx context.Context, args ...string) (any, error) {
+	if d, ok := ctx.Deadline(); ok {
+		_ = c.SetDeadline(d)
+	} else {
+		_ = c.SetDeadline(time.Time{})
+	}
+
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+func (c *conn) send(args ...string) error {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if err := c.w.Flush(); err != nil {
+		return errors.Join(errors.New("failed to send command"), err)
+	}
+	return nil
+}
+
+// Reads a reply. Simple strings and bulk strings are returned as []byte, integers
+// as int64, arrays as []any, and nil bulk strings and arrays as nil.
+func (c *conn) read() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, errors.Join(errors.New("failed to read reply"), err)
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed reply %q", line)
+	}
+	kind, line := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return []byte(line), nil
+	case '-':
+		return nil, Error(line)
+	case ':':
+		n, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("malformed integer reply %q", line), err)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("malformed bulk string length %q", line), err)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return nil, errors.Join(errors.New("failed to read bulk string"), err)
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("malformed array length %q", line), err)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		vs := make([]any, n)
+		for i := range vs {
+			v, err := c.read()
+			var rerr Error
+			if errors.As(err, &rerr) {
+				vs[i] = rerr
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			vs[i] = v
+		}
+		return vs, nil
+	default:
+		return nil, fmt.Errorf("unknown reply type %q", kind)
+	}
+}
@@ -0,0 +1,400 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package redis provides a [core.CacheBackend] backed by a Redis (or compatible,
+// such as Valkey and KeyDB) server, so multiple instances of the blog behind a
+// load balancer share their rendered files and invalidations.
+//
+// Values are stored as keys with the prefix of Opts.Prefix, and invalidations are
+// broadcasted with pub/sub on Opts.Channel.
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+// A [core.CacheBackend] backed by a Redis server, which can also be used to
+// publish and subscribe to other channels.
+type Client interface {
+	core.CacheBackend
+	// Publishes the message on the channel.
+	Publish(ctx context.Context, channel string, msg []byte) error
+	// Calls the function with each message published on the channel, until the
+	// context is done, reconnecting on failures.
+	Subscribe(ctx context.Context, channel string, f func(msg []byte)) error
+	// Sends the command to the server, returning it's reply: nil, a int64, a
+	// []byte of strings, or a []any of them. Keys aren't prefixed with
+	// Opts.Prefix. Errors replied by the server are returned as [Error].
+	Do(ctx context.Context, args ...string) (any, error)
+	// Closes the connections of the client.
+	Close() error
+}
+
+// Creates a [Client] connected to the server of Opts.Addr. Connections are made
+// lazily, so the server doesn't need to be available on construction.
+func New(opts ...Opts) Client {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Addr == "" {
+		opt.Addr = "localhost:6379"
+	}
+	if opt.Prefix == "" {
+		opt.Prefix = "blogo:"
+	}
+	if opt.Channel == "" {
+		opt.Channel = opt.Prefix + "invalidations"
+	}
+	if opt.PoolSize == 0 {
+		opt.PoolSize = 8
+	}
+	if opt.DialTimeout == 0 {
+		opt.DialTimeout = 5 * time.Second
+	}
+	if opt.RetryDelay == 0 {
+		opt.RetryDelay = time.Second
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	return &client{
+		opts: opt,
+		id:   hex.EncodeToString(id),
+		pool: make([]*conn, 0, opt.PoolSize),
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+// Returned by operations of a closed [Client].
+var ErrClosed = errors.New("redis: client is closed")
+
+type Opts struct {
+	// Address of the server. Defaults to "localhost:6379".
+	Addr string
+	// Credentials used to authenticate with the server. If Username is empty, the
+	// legacy AUTH with only the password is used.
+	Username string
+	Password string
+	// Database selected on each connection. Defaults to 0.
+	DB int
+	// Configuration of TLS connections. By default connections are plain TCP.
+	TLS *tls.Config
+
+	// Prefix of all keys, so multiple blogs can share one server. Defaults to "blogo:".
+	Prefix string
+	// Channel where invalidations are broadcasted. Defaults to Prefix + "invalidations".
+	Channel string
+
+	// Max number of idle connections kept. Defaults to 8.
+	PoolSize int
+	// Timeout of connecting to the server. Defaults to 5s.
+	DialTimeout time.Duration
+	// Delay between reconnections of subscriptions. Defaults to 1s.
+	RetryDelay time.Duration
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type client struct {
+	opts Opts
+	// Identifier of the client, so it ignores it's own broadcasts.
+	id string
+
+	mu     sync.Mutex
+	pool   []*conn
+	closed bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+// Sends the command using a connection of the pool, discarding the connection
+// if it fails with anything other than a reply of the server.
+func (c *client) do(ctx context.Context, args ...string) (any, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := cn.do(ctx, args...)
+
+	var rerr Error
+	if err != nil && !errors.As(err, &rerr) {
+		cn.Close()
+		return nil, err
+	}
+
+	c.put(cn)
+
+	return v, err
+}
+
+// Returns a idle connection of the pool, or a new connection.
+func (c *client) get(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if n := len(c.pool); n > 0 {
+		cn := c.pool[n-1]
+		c.pool = c.pool[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+
+	return dial(ctx, c.opts)
+}
+
+// Returns the connection to the pool, closing it if the pool is full.
+func (c *client) put(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || len(c.pool) >= c.opts.PoolSize {
+		cn.Close()
+		return
+	}
+	c.pool = append(c.pool, cn)
+}
+
+func (c *client) Do(ctx context.Context, args ...string) (any, error) {
+	return c.do(ctx, args...)
+}
+
+func (c *client) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	v, err := c.do(ctx, "GET", c.opts.Prefix+key)
+	if err != nil {
+		return nil, false, errors.Join(errors.New("failed to get key"), err)
+	}
+	if v == nil {
+		return nil, false, nil
+	}
+
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, false, errors.New("unexpected reply to GET")
+	}
+	return b, true, nil
+}
+
+func (c *client) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
+	ms := strconv.FormatInt(max(ttl.Milliseconds(), 1), 10)
+	if _, err := c.do(ctx, "SET", c.opts.Prefix+key, string(value), "PX", ms); err != nil {
+		return errors.Join(errors.New("failed to set key"), err)
+	}
+	return nil
+}
+
+func (c *client) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	args := make([]string, 0, len(keys)+1)
+	args = append(args, "DEL")
+	for _, k := range keys {
+		args = append(args, c.opts.Prefix+k)
+	}
+
+	if _, err := c.do(ctx, args...); err != nil {
+		return errors.Join(errors.New("failed to delete keys"), err)
+	}
+	return nil
+}
+
+// Implements [core.CacheBackend], removing all keys with the prefix. Keys are
+// found with SCAN, so the server isn't blocked on large databases.
+func (c *client) Clear(ctx context.Context) error {
+	cursor := "0"
+	for {
+		v, err := c.do(ctx, "SCAN", cursor, "MATCH", escapeGlob(c.opts.Prefix)+"*", "COUNT", "100")
+		if err != nil {
+			return errors.Join(errors.New("failed to scan keys"), err)
+		}
+
+		reply, ok := v.([]any)
+		if !ok || len(reply) != 2 {
+			return errors.New("unexpected reply to SCAN")
+		}
+		next, _ := reply[0].([]byte)
+		keys, _ := reply[1].([]any)
+
+		if len(keys) > 0 {
+			args := make([]string, 0, len(keys)+1)
+			args = append(args, "DEL")
+			for _, k := range keys {
+				if k, ok := k.([]byte); ok {
+					args = append(args, string(k))
+				}
+			}
+			if _, err := c.do(ctx, args...); err != nil {
+				return errors.Join(errors.New("failed to delete keys"), err)
+			}
+		}
+
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			return nil
+		}
+	}
+}
+
+type broadcast struct {
+	Origin string   `json:"origin"`
+	Names  []string `json:"names"`
+}
+
+func (c *client) Broadcast(ctx context.Context, names []string) error {
+	msg, err := json.Marshal(broadcast{Origin: c.id, Names: names})
+	if err != nil {
+		return errors.Join(errors.New("failed to encode broadcast"), err)
+	}
+	return c.Publish(ctx, c.opts.Channel, msg)
+}
+
+func (c *client) Listen(ctx context.Context, f func(names []string)) error {
+	return c.Subscribe(ctx, c.opts.Channel, func(msg []byte) {
+		var b broadcast
+		if err := json.Unmarshal(msg, &b); err != nil {
+			c.log.Warn("Ignoring malformed broadcast", slog.String("err", err.Error()))
+			return
+		}
+		if b.Origin == c.id {
+			return
+		}
+		f(b.Names)
+	})
+}
+
+func (c *client) Publish(ctx context.Context, channel string, msg []byte) error {
+	if _, err := c.do(ctx, "PUBLISH", channel, string(msg)); err != nil {
+		return errors.Join(errors.New("failed to publish message"), err)
+	}
+	return nil
+}
+
+func (c *client) Subscribe(ctx context.Context, channel string, f func(msg []byte)) error {
+	log := c.log.With(slog.String("channel", channel))
+
+	for {
+		err := c.subscribe(ctx, channel, f)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		log.Warn("Subscription failed, reconnecting",
+			slog.String("err", err.Error()), slog.Duration("delay", c.opts.RetryDelay))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.opts.RetryDelay):
+		}
+	}
+}
+
+// Subscribes to the channel on a dedicated connection, reading messages until
+// the connection fails or the context is done.
+func (c *client) subscribe(ctx context.Context, channel string, f func(msg []byte)) error {
+	cn, err := dial(ctx, c.opts)
+	if err != nil {
+		return err
+	}
+	defer cn.Close()
+
+	stop := context.AfterFunc(ctx, func() { cn.Close() })
+	defer stop()
+
+	if err := cn.send("SUBSCRIBE", channel); err != nil {
+		return err
+	}
+
+	c.log.Debug("Subscribed to channel", slog.String("channel", channel))
+
+	for {
+		v, err := cn.read()
+		if err != nil {
+			return err
+		}
+
+		reply, ok := v.([]any)
+		if !ok || len(reply) != 3 {
+			continue
+		}
+		if kind, _ := reply[0].([]byte); string(kind) != "message" {
+			continue
+		}
+		if msg, ok := reply[2].([]byte); ok {
+			f(msg)
+		}
+	}
+}
+
+func (c *client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	for _, cn := range c.pool {
+		cn.Close()
+	}
+	c.pool = nil
+
+	return nil
+}
+
+// Escapes the characters of the glob patterns of SCAN.
+func escapeGlob(s string) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', ']', '\\':
+			b = append(b, '\\')
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
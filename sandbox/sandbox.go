@@ -0,0 +1,211 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sandbox restricts the template functions available to themes, so
+// installing a theme from a third party can't exfiltrate data or read arbitrary
+// files. Functions are registered on [Funcs] with the capabilities they need,
+// such as [Files] or [Network], and themes are parsed with a [Profile] of the
+// capabilities they are allowed to use (see [ParseFS]).
+//
+// For example, the functions of the history plugin request the repository, so
+// they should be added with [Network], while the ones of the images plugin only
+// build URLs and need no capabilities.
+package sandbox
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"slices"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// A capability needed by template functions.
+type Capability string
+
+const (
+	// Functions that read files of the site other than the rendered one, or
+	// anything from the file system of the host.
+	Files Capability = "files"
+	// Functions that make requests to other services, such as the API of a
+	// forge or a database.
+	Network Capability = "network"
+	// Functions that expose data of visitors or of the host, such as feature
+	// flags, analytics or secrets.
+	Private Capability = "private"
+)
+
+// Capabilities that themes are allowed to use.
+type Profile struct {
+	Name string
+	// Capabilities allowed for the theme.
+	Allow []Capability
+	// Names of functions always allowed, whatever capabilities they need.
+	Funcs []string
+	// Names of functions never allowed, even without capabilities.
+	Deny []string
+}
+
+var (
+	// Profile of themes that are trusted, allowing all capabilities.
+	Trusted = Profile{Name: "trusted", Allow: []Capability{Files, Network, Private}}
+	// Profile of themes from third parties, only allowing functions that don't
+	// need any capability.
+	Untrusted = Profile{Name: "untrusted"}
+)
+
+// Reports if the profile allows the function with the capabilities.
+func (p Profile) allows(name string, caps []Capability) bool {
+	if slices.Contains(p.Deny, name) {
+		return false
+	}
+	if slices.Contains(p.Funcs, name) {
+		return true
+	}
+	for _, c := range caps {
+		if !slices.Contains(p.Allow, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Template functions and the capabilities they need.
+type Funcs struct {
+	funcs map[string]function
+}
+
+type function struct {
+	fn   any
+	caps []Capability
+}
+
+// Creates a empty set of functions.
+func New() *Funcs {
+	return &Funcs{funcs: map[string]function{}}
+}
+
+// Adds the functions, such as the FuncMap of a plugin, needing the capabilities.
+// Functions added without capabilities are allowed by every profile, so only
+// functions without side effects should be. Replaces functions with the same name.
+func (f *Funcs) Add(funcs template.FuncMap, caps ...Capability) *Funcs {
+	for name, fn := range funcs {
+		f.funcs[name] = function{fn: fn, caps: slices.Clone(caps)}
+	}
+	return f
+}
+
+// Returns the functions allowed by the profile. Functions that aren't allowed
+// are replaced by ones that return a error, so templates that use them still
+// parse but fail when executed.
+func (f *Funcs) FuncMap(p Profile) template.FuncMap {
+	m := make(template.FuncMap, len(f.funcs))
+	for name, fn := range f.funcs {
+		if p.allows(name, fn.caps) {
+			m[name] = fn.fn
+		} else {
+			m[name] = denied(name, p)
+		}
+	}
+	return m
+}
+
+func denied(name string, p Profile) func(...any) (any, error) {
+	return func(...any) (any, error) {
+		return nil, fmt.Errorf("template function %q is not allowed by the %q profile", name, p.Name)
+	}
+}
+
+// Returns the names of the functions used by the templates that the profile
+// doesn't allow, sorted.
+func (f *Funcs) Denied(t *template.Template, p Profile) []string {
+	used := map[string]struct{}{}
+	for _, t := range t.Templates() {
+		if t.Tree != nil {
+			identifiers(t.Tree.Root, used)
+		}
+	}
+
+	names := []string{}
+	for name := range used {
+		if fn, ok := f.funcs[name]; ok && !p.allows(name, fn.caps) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Adds the names of the functions called on the node and it's children.
+func identifiers(n parse.Node, names map[string]struct{}) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, n := range n.Nodes {
+			identifiers(n, names)
+		}
+	case *parse.ActionNode:
+		identifiers(n.Pipe, names)
+	case *parse.IfNode:
+		identifiers(&n.BranchNode, names)
+	case *parse.RangeNode:
+		identifiers(&n.BranchNode, names)
+	case *parse.WithNode:
+		identifiers(&n.BranchNode, names)
+	case *parse.BranchNode:
+		identifiers(n.Pipe, names)
+		identifiers(n.List, names)
+		identifiers(n.ElseList, names)
+	case *parse.TemplateNode:
+		identifiers(n.Pipe, names)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			identifiers(c, names)
+		}
+	case *parse.CommandNode:
+		for _, a := range n.Args {
+			identifiers(a, names)
+		}
+	case *parse.ChainNode:
+		identifiers(n.Node, names)
+	case *parse.IdentifierNode:
+		names[n.Ident] = struct{}{}
+	}
+}
+
+// Parses the templates of the theme on the file system matching the patterns
+// (see [template.ParseFS]), with the functions allowed by the profile. Returns
+// a error if the theme uses functions that the profile doesn't allow, instead
+// of failing when the templates are executed.
+func ParseFS(fsys fs.FS, funcs *Funcs, p Profile, patterns ...string) (*template.Template, error) {
+	t, err := template.New("theme").Funcs(funcs.FuncMap(p)).ParseFS(fsys, patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	if denied := funcs.Denied(t, p); len(denied) > 0 {
+		return nil, fmt.Errorf("theme uses template functions not allowed by the %q profile: %s",
+			p.Name, strings.Join(denied, ", "))
+	}
+
+	return t, nil
+}
@@ -0,0 +1,403 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package schedule provides a small cron-like [Scheduler] of background jobs,
+// such as refreshes of the sources and backups, which runs each job on it's
+// [Schedule] without overlapping runs of the same job, exposes metrics of the
+// runs of each job and allows admins to run jobs on demand with [NewHandler].
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/auth"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-schedule"
+
+var (
+	// Returned by [(Scheduler).Trigger] if the job is already running.
+	ErrRunning = errors.New("job is already running")
+	// Returned by [(Scheduler).Trigger] if there isn't a job with the name.
+	ErrUnknownJob = errors.New("unknown job")
+)
+
+// A background job.
+type Job struct {
+	// Name of the job, such as "backup", used on metrics and to trigger it.
+	Name string
+	// When the job runs. Jobs without a schedule only run when triggered.
+	Schedule Schedule
+	// Runs the job. Errors are logged and counted on the metrics of the job.
+	Run func(ctx context.Context) error
+	// Max duration of a run, after which it's context is canceled. Zero
+	// disables it.
+	Timeout time.Duration
+	// Runs the job when the scheduler starts, besides on it's schedule.
+	RunOnStart bool
+}
+
+// State of a job of the scheduler.
+type Status struct {
+	Name    string `json:"name"`
+	Running bool   `json:"running"`
+
+	// Number of finished runs of the job, including the failed ones.
+	Runs int64 `json:"runs"`
+	// Number of runs that returned a error.
+	Failures int64 `json:"failures"`
+	// Number of scheduled runs skipped because the job was still running.
+	Skipped int64 `json:"skipped"`
+
+	LastRun      time.Time     `json:"last_run"`
+	LastDuration time.Duration `json:"last_duration"`
+	// Error of the last run, empty if it succeeded.
+	LastError string `json:"last_error,omitempty"`
+	// Time of the next scheduled run, zero if the scheduler isn't running or
+	// the job doesn't have a schedule.
+	Next time.Time `json:"next"`
+}
+
+// Runs jobs on their schedules.
+type Scheduler interface {
+	plugin.Instrumented
+	// Runs the jobs on their schedules until the context is done, then waits
+	// for the running jobs to finish. Scheduled runs of jobs that are still
+	// running are skipped, so runs never overlap.
+	Run(ctx context.Context) error
+	// Starts a run of the job right away, returning [ErrRunning] if it's
+	// already running, or [ErrUnknownJob]. The job runs with the context of
+	// [(Scheduler).Run], if the scheduler is running.
+	Trigger(name string) error
+	// Returns the status of each job, in the order they were provided.
+	Jobs() []Status
+}
+
+// Creates a [Scheduler] of the jobs. The metrics of each job are prefixed
+// by it's name, such as "backup.runs", "backup.failures", "backup.skipped",
+// and "backup.duration_ms" (the total duration of it's runs).
+func New(jobs []Job, opts ...Opts) Scheduler {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Now == nil {
+		opt.Now = time.Now
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	s := &scheduler{
+		jobs: make([]*job, 0, len(jobs)),
+		ctx:  context.Background(),
+
+		now:   opt.Now,
+		onRun: opt.OnRun,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+	for _, j := range jobs {
+		opt.Assertions.NotZero(j.Name, "Name of job should not be empty")
+		opt.Assertions.NotNil(j.Run, "Run function of job should not be nil")
+		s.jobs = append(s.jobs, &job{Job: j, status: Status{Name: j.Name}})
+	}
+
+	return s
+}
+
+type Opts struct {
+	// Called after each run of a job, with the status of the job.
+	OnRun func(Status)
+	// Returns the current time. Defaults to [time.Now].
+	Now func() time.Time
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type scheduler struct {
+	jobs []*job
+
+	mu  sync.Mutex
+	ctx context.Context
+	wg  sync.WaitGroup
+
+	now   func() time.Time
+	onRun func(Status)
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+type job struct {
+	Job
+
+	status   Status
+	duration time.Duration
+}
+
+func (s *scheduler) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (s *scheduler) SetLogger(logger *slog.Logger) {
+	if s.injectLogger {
+		s.log = logger
+	}
+}
+
+func (s *scheduler) Run(ctx context.Context) error {
+	s.assert.NotNil(ctx)
+
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	var loops sync.WaitGroup
+	for _, j := range s.jobs {
+		if j.RunOnStart {
+			s.start(j, "start")
+		}
+		if j.Schedule == nil {
+			continue
+		}
+
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			s.loop(ctx, j)
+		}()
+	}
+
+	loops.Wait()
+	s.wg.Wait()
+
+	s.mu.Lock()
+	s.ctx = context.Background()
+	for _, j := range s.jobs {
+		j.status.Next = time.Time{}
+	}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Starts the job at each time of it's schedule, until the context is done.
+func (s *scheduler) loop(ctx context.Context, j *job) {
+	log := s.log.With(slog.String("job", j.Name))
+
+	for {
+		next := j.Schedule.Next(s.now())
+		if next.IsZero() {
+			log.Debug("Job doesn't have more scheduled runs")
+			return
+		}
+
+		s.mu.Lock()
+		j.status.Next = next
+		s.mu.Unlock()
+
+		t := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+
+		if !s.start(j, "schedule") {
+			log.Warn("Job is still running, skipping scheduled run")
+		}
+	}
+}
+
+func (s *scheduler) Trigger(name string) error {
+	i := slices.IndexFunc(s.jobs, func(j *job) bool { return j.Name == name })
+	if i < 0 {
+		return fmt.Errorf("%w %q", ErrUnknownJob, name)
+	}
+	if !s.start(s.jobs[i], "trigger") {
+		return ErrRunning
+	}
+	return nil
+}
+
+// Starts a run of the job, returning false if it's already running.
+func (s *scheduler) start(j *job, reason string) bool {
+	s.mu.Lock()
+	if j.status.Running {
+		if reason == "schedule" {
+			j.status.Skipped++
+		}
+		s.mu.Unlock()
+		return false
+	}
+	j.status.Running = true
+	ctx := s.ctx
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+		s.run(ctx, j, reason)
+	}()
+	return true
+}
+
+func (s *scheduler) run(ctx context.Context, j *job, reason string) {
+	log := s.log.With(slog.String("job", j.Name), slog.String("reason", reason))
+	log.Debug("Running job")
+
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+
+	start := s.now()
+	err := safeRun(ctx, j.Run)
+	elapsed := s.now().Sub(start)
+
+	s.mu.Lock()
+	j.status.Running = false
+	j.status.Runs++
+	j.status.LastRun = start
+	j.status.LastDuration = elapsed
+	j.status.LastError = ""
+	if err != nil {
+		j.status.Failures++
+		j.status.LastError = err.Error()
+	}
+	j.duration += elapsed
+	status := j.status
+	s.mu.Unlock()
+
+	if err != nil {
+		log.Error("Job failed", slog.String("err", err.Error()), slog.Duration("duration", elapsed))
+	} else {
+		log.Debug("Job finished", slog.Duration("duration", elapsed))
+	}
+
+	if s.onRun != nil {
+		s.onRun(status)
+	}
+}
+
+// Runs the function, returning panics as errors, so a failing job doesn't
+// stop the scheduler.
+func safeRun(ctx context.Context, f func(ctx context.Context) error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("job panicked: %v", v)
+		}
+	}()
+	return f(ctx)
+}
+
+func (s *scheduler) Jobs() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]Status, len(s.jobs))
+	for i, j := range s.jobs {
+		statuses[i] = j.status
+	}
+	return statuses
+}
+
+func (s *scheduler) Metrics() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := make(map[string]int64, len(s.jobs)*4)
+	for _, j := range s.jobs {
+		m[j.Name+".runs"] = j.status.Runs
+		m[j.Name+".failures"] = j.status.Failures
+		m[j.Name+".skipped"] = j.status.Skipped
+		m[j.Name+".duration_ms"] = j.duration.Milliseconds()
+	}
+	return m
+}
+
+// Creates a [http.Handler] that responds with the status of the jobs of the
+// scheduler as JSON on GET requests, and triggers the job of the "job" form
+// value on POST requests, responding with "202 Accepted", or "409 Conflict"
+// if the job is already running. Requests are authenticated by the
+// authenticator with the scopes, which default to "admin". If the
+// authenticator is nil, all requests are rejected.
+func NewHandler(s Scheduler, authenticator auth.Authenticator, scopes ...string) http.Handler {
+	if len(scopes) == 0 {
+		scopes = []string{"admin"}
+	}
+
+	return auth.Require(authenticator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(s.Jobs())
+
+		case http.MethodPost:
+			r.Body = http.MaxBytesReader(w, r.Body, 4<<10)
+
+			name := r.FormValue("job")
+			if name == "" {
+				http.Error(w, "Missing job value", http.StatusBadRequest)
+				return
+			}
+
+			switch err := s.Trigger(name); {
+			case errors.Is(err, ErrUnknownJob):
+				http.Error(w, "Unknown job", http.StatusNotFound)
+			case errors.Is(err, ErrRunning):
+				http.Error(w, "Job is already running", http.StatusConflict)
+			case err != nil:
+				http.Error(w, "Failed to run job", http.StatusInternalServerError)
+			default:
+				w.WriteHeader(http.StatusAccepted)
+			}
+
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}), auth.RequireOpts{Scopes: scopes})
+}
@@ -0,0 +1,276 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secrets resolves references to secrets on configuration values, so
+// configuration files can be committed without the tokens of sourcers and other
+// plugins. Values are resolved by their prefix:
+//
+//   - "env:NAME" is the value of the environment variable NAME.
+//   - "file:/run/secrets/token" is the contents of the file.
+//   - "exec:pass show blog/token" is the output of the command, which is run
+//     directly, without a shell.
+//
+// Values without any of the prefixes are returned as they are. The contents of
+// files and outputs of commands have their surrounding whitespace, such as the
+// final newline, removed.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Prefixes of the references.
+const (
+	EnvPrefix  = "env:"
+	FilePrefix = "file:"
+	ExecPrefix = "exec:"
+)
+
+// Error of a reference that couldn't be resolved.
+type Error struct {
+	// The reference, for example "env:GITEA_TOKEN".
+	Ref string
+	Err error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("failed to resolve secret %q: %s", e.Ref, e.Err.Error())
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Resolves the references of secrets, see the package documentation.
+type Resolver struct {
+	disableExec bool
+	execTimeout time.Duration
+	dir         string
+	lookupEnv   func(string) (string, bool)
+}
+
+func New(opts ...Opts) *Resolver {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.ExecTimeout == 0 {
+		opt.ExecTimeout = 10 * time.Second
+	}
+	if opt.LookupEnv == nil {
+		opt.LookupEnv = os.LookupEnv
+	}
+
+	return &Resolver{
+		disableExec: opt.DisableExec,
+		execTimeout: opt.ExecTimeout,
+		dir:         opt.Dir,
+		lookupEnv:   opt.LookupEnv,
+	}
+}
+
+type Opts struct {
+	// Makes "exec:" references fail, for configurations that aren't fully
+	// trusted.
+	DisableExec bool
+	// Max duration of the commands of "exec:" references. Defaults to 10 seconds.
+	ExecTimeout time.Duration
+
+	// Directory that relative paths of "file:" references, and commands of
+	// "exec:" references, are relative to, such as the directory of the
+	// configuration file. Defaults to the working directory.
+	Dir string
+	// Function used to look up environment variables. Defaults to [os.LookupEnv].
+	LookupEnv func(string) (string, bool)
+}
+
+var defaultResolver = New()
+
+// Resolves the value with the default [Resolver].
+func Resolve(ctx context.Context, value string) (string, error) {
+	return defaultResolver.Resolve(ctx, value)
+}
+
+// Resolves the values of v with the default [Resolver], see [(*Resolver).ResolveAll].
+func ResolveAll(ctx context.Context, v any) error {
+	return defaultResolver.ResolveAll(ctx, v)
+}
+
+// Returns the secret of the reference, or the value itself if it isn't a
+// reference. Errors are of type [*Error].
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	var (
+		v   string
+		err error
+	)
+
+	switch {
+	case strings.HasPrefix(value, EnvPrefix):
+		v, err = r.env(strings.TrimPrefix(value, EnvPrefix))
+	case strings.HasPrefix(value, FilePrefix):
+		v, err = r.file(strings.TrimPrefix(value, FilePrefix))
+	case strings.HasPrefix(value, ExecPrefix):
+		v, err = r.exec(ctx, strings.TrimPrefix(value, ExecPrefix))
+	default:
+		return value, nil
+	}
+
+	if err != nil {
+		return "", &Error{Ref: value, Err: err}
+	}
+	return v, nil
+}
+
+func (r *Resolver) env(name string) (string, error) {
+	if name == "" {
+		return "", errors.New("name of environment variable is empty")
+	}
+	v, ok := r.lookupEnv(name)
+	if !ok {
+		return "", errors.New("environment variable is not set")
+	}
+	return v, nil
+}
+
+func (r *Resolver) file(name string) (string, error) {
+	if name == "" {
+		return "", errors.New("file name is empty")
+	}
+	if !filepath.IsAbs(name) && r.dir != "" {
+		name = filepath.Join(r.dir, name)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", errors.Join(errors.New("failed to read file"), err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (r *Resolver) exec(ctx context.Context, command string) (string, error) {
+	if r.disableExec {
+		return "", errors.New("exec references are disabled")
+	}
+
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", errors.New("command is empty")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.execTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = r.dir
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = errors.Join(err, errors.New(msg))
+		}
+		return "", errors.Join(errors.New("failed to run command"), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Resolves, in place, all the string values of v, which should be a pointer to
+// a struct, map or slice, such as the options of a plugin or a configuration
+// decoded from YAML. Exported fields of nested structs, maps, slices and
+// pointers are resolved too. Returns the errors of all the values that couldn't
+// be resolved.
+func (r *Resolver) ResolveAll(ctx context.Context, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer && rv.Kind() != reflect.Map && rv.Kind() != reflect.Slice {
+		return fmt.Errorf("value of type %T can't be resolved in place", v)
+	}
+	return r.resolveValue(ctx, rv)
+}
+
+func (r *Resolver) resolveValue(ctx context.Context, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Interface && v.Elem().Kind() == reflect.String {
+			s, err := r.Resolve(ctx, v.Elem().String())
+			if err != nil {
+				return err
+			}
+			if v.CanSet() {
+				v.Set(reflect.ValueOf(s).Convert(v.Elem().Type()))
+			}
+			return nil
+		}
+		return r.resolveValue(ctx, v.Elem())
+
+	case reflect.String:
+		s, err := r.Resolve(ctx, v.String())
+		if err != nil {
+			return err
+		}
+		if v.CanSet() {
+			v.SetString(s)
+		}
+		return nil
+
+	case reflect.Struct:
+		var errs []error
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			errs = append(errs, r.resolveValue(ctx, v.Field(i)))
+		}
+		return errors.Join(errs...)
+
+	case reflect.Slice, reflect.Array:
+		var errs []error
+		for i := 0; i < v.Len(); i++ {
+			errs = append(errs, r.resolveValue(ctx, v.Index(i)))
+		}
+		return errors.Join(errs...)
+
+	case reflect.Map:
+		var errs []error
+		iter := v.MapRange()
+		for iter.Next() {
+			// Values of maps aren't addressable, so they are resolved on a copy
+			// which is set back on the map.
+			val := reflect.New(iter.Value().Type()).Elem()
+			val.Set(iter.Value())
+			if err := r.resolveValue(ctx, val); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			v.SetMapIndex(iter.Key(), val)
+		}
+		return errors.Join(errs...)
+	}
+
+	return nil
+}
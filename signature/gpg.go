@@ -0,0 +1,126 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signature
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Options of [NewGPGVerifier].
+type GPGOpts struct {
+	// Path of the gpg binary. Defaults to "gpg".
+	Bin string
+	// GnuPG home directory with the keyring of trusted keys, by default the
+	// home of the user running the server.
+	Home string
+}
+
+// Creates a [Verifier] of detached OpenPGP signatures, using the gpg binary.
+// Only signatures of keys on the keyring are verified.
+func NewGPGVerifier(opts ...GPGOpts) Verifier {
+	opt := GPGOpts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Bin == "" {
+		opt.Bin = "gpg"
+	}
+	return &gpgVerifier{bin: opt.Bin, home: opt.Home}
+}
+
+type gpgVerifier struct {
+	bin  string
+	home string
+}
+
+func (v *gpgVerifier) Method() string {
+	return "gpg"
+}
+
+func (v *gpgVerifier) Verify(ctx context.Context, content, signature []byte) (Signer, error) {
+	sig, err := os.CreateTemp("", "blogo-signature-*")
+	if err != nil {
+		return Signer{}, errors.Join(errors.New("failed to create temporary file"), err)
+	}
+	defer os.Remove(sig.Name())
+	defer sig.Close()
+
+	if _, err := sig.Write(signature); err != nil {
+		return Signer{}, errors.Join(errors.New("failed to write temporary file"), err)
+	}
+
+	args := []string{"--batch", "--no-tty", "--status-fd", "1"}
+	if v.home != "" {
+		args = append(args, "--homedir", v.home)
+	}
+	args = append(args, "--verify", sig.Name(), "-")
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, v.bin, args...)
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	runErr := cmd.Run()
+
+	signer, good, bad, unknown := Signer{}, false, false, false
+	s := bufio.NewScanner(&stdout)
+	for s.Scan() {
+		fields := strings.Fields(strings.TrimPrefix(s.Text(), "[GNUPG:] "))
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "GOODSIG":
+			good = true
+			if len(fields) > 2 {
+				signer.Name = strings.Join(fields[2:], " ")
+			}
+		case "VALIDSIG":
+			if len(fields) > 1 {
+				signer.KeyID = fields[1]
+			}
+		case "NO_PUBKEY":
+			unknown = true
+		case "BADSIG", "ERRSIG":
+			bad = true
+		}
+	}
+
+	// Signatures of keys missing from the keyring are reported as both ERRSIG and
+	// NO_PUBKEY, since gpg can't check them.
+	if unknown {
+		return Signer{}, ErrUnknownSigner
+	}
+	if bad || !good {
+		return Signer{}, errors.Join(
+			ErrInvalidSignature,
+			errors.New("gpg: "+strings.TrimSpace(stderr.String())),
+			runErr,
+		)
+	}
+
+	if name, email, ok := strings.Cut(signer.Name, " <"); ok {
+		signer.Name, signer.Email = name, strings.TrimSuffix(email, ">")
+	}
+
+	return signer, nil
+}
@@ -0,0 +1,414 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signature provides the verification of the authenticity of posts, from
+// detached signatures on the file system (such as "post.md.sig" created with
+// "ssh-keygen -Y sign", or "post.md.asc" created with "gpg --detach-sign") or
+// from the signature of the last commit of git-backed sourcers.
+//
+// Use [New] to wrap the sourcer, so files have the [Result] of their verification
+// on their metadata (see [StatusKey] and [ResultKey]), and templates can show a
+// verification badge.
+package signature
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/history"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-signature-sourcer"
+
+const (
+	// Metadata key of the [Status] of the verification of the file, as a string.
+	StatusKey = "signature.status"
+	// Metadata key of the [Result] of the verification of the file.
+	ResultKey = "signature.result"
+)
+
+var (
+	// Returned by verifiers if the signature doesn't match the content or is malformed.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// Returned by verifiers if the signature was made by a key that isn't trusted.
+	ErrUnknownSigner = errors.New("signature of unknown signer")
+)
+
+// The author of a signature.
+type Signer struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+	// Identifier of the key of the signature, such as it's fingerprint.
+	KeyID string `json:"key_id,omitempty"`
+}
+
+// Verifies detached signatures.
+type Verifier interface {
+	// Name of the method of the signatures, such as "ssh" or "gpg".
+	Method() string
+	// Verifies the signature of the content, returning [ErrInvalidSignature] or
+	// [ErrUnknownSigner] if it isn't valid.
+	Verify(ctx context.Context, content, signature []byte) (Signer, error)
+}
+
+// Status of the verification of a file.
+type Status string
+
+const (
+	// The file has a valid signature of a trusted signer.
+	Verified Status = "verified"
+	// The file doesn't have a signature.
+	Unsigned Status = "unsigned"
+	// The file has a signature that doesn't match it's content.
+	Invalid Status = "invalid"
+	// The file has a signature of a signer that isn't trusted.
+	Untrusted Status = "untrusted"
+)
+
+// Result of the verification of a file.
+type Result struct {
+	Status Status `json:"status"`
+	// Method of the signature, such as "ssh", "gpg" or "commit".
+	Method string `json:"method,omitempty"`
+	Signer Signer `json:"signer"`
+	// Reason of the result, such as the error of the verification.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Reports if the file has a valid signature of a trusted signer.
+func (r Result) Verified() bool {
+	return r.Status == Verified
+}
+
+// The signature plugin, which wraps a [plugin.Sourcer] adding the verification
+// of files to their metadata.
+type Plugin interface {
+	plugin.Sourcer
+	// Returns the template functions:
+	//
+	//   - "signature PATH" returns the [Result] of the verification of the file.
+	FuncMap() template.FuncMap
+}
+
+// Creates the signature [Plugin], wrapping the sourcer. Files are verified with
+// the detached signature of the first extension of Opts.Verifiers found on the
+// file system, falling back to the verification of their last commit if
+// Opts.History is set (it defaults to the sourcer if it implements [history.History]).
+//
+// Files are only verified when their metadata keys are first accessed, and the
+// result is cached until the next call to Source.
+func New(sourcer plugin.Sourcer, opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.History == nil {
+		if h, ok := sourcer.(history.History); ok {
+			opt.History = h
+		}
+	}
+	if opt.Extensions == nil {
+		opt.Extensions = []string{".md"}
+	}
+	if opt.Timeout == 0 {
+		opt.Timeout = 10 * time.Second
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be wrapped should not be nil")
+
+	exts := make([]string, 0, len(opt.Verifiers))
+	for ext := range opt.Verifiers {
+		exts = append(exts, ext)
+	}
+	slices.Sort(exts)
+
+	return &p{
+		sourcer:    sourcer,
+		verifiers:  opt.Verifiers,
+		signatures: exts,
+		history:    opt.History,
+		extensions: opt.Extensions,
+		timeout:    opt.Timeout,
+
+		results: map[string]Result{},
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Verifiers of detached signatures, by the extension appended to the path
+	// of the signed file, for example ".sig" for [NewSSHVerifier] and ".asc" for
+	// [NewGPGVerifier].
+	Verifiers map[string]Verifier
+	// History used to verify the last commit of files without detached signatures.
+	// Defaults to the sourcer, if it implements [history.History].
+	History history.History
+	// Extensions of the files that are verified. Defaults to ".md".
+	Extensions []string
+	// Timeout of the verification of a file. Defaults to 10 seconds.
+	Timeout time.Duration
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	sourcer    plugin.Sourcer
+	verifiers  map[string]Verifier
+	signatures []string
+	history    history.History
+	extensions []string
+	timeout    time.Duration
+
+	mu      sync.Mutex
+	fsys    fs.FS
+	results map[string]Result
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Source() (fs.FS, error) {
+	p.assert.NotNil(p.sourcer)
+	p.assert.NotNil(p.log)
+
+	fsys, err := p.sourcer.Source()
+	if err != nil {
+		return fsys, err
+	}
+
+	p.mu.Lock()
+	p.fsys, p.results = fsys, map[string]Result{}
+	p.mu.Unlock()
+
+	return &signatureFS{FS: fsys, p: p}, nil
+}
+
+func (p *p) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"signature": func(name string) Result {
+			return p.verify(strings.TrimPrefix(name, "/"))
+		},
+	}
+}
+
+// Returns the result of the verification of the file, cached until the next
+// call to Source.
+func (p *p) verify(name string) Result {
+	p.mu.Lock()
+	r, ok := p.results[name]
+	fsys := p.fsys
+	p.mu.Unlock()
+	if ok {
+		return r
+	}
+
+	if fsys == nil {
+		var err error
+		if fsys, err = p.Source(); err != nil {
+			return Result{Status: Unsigned, Reason: "failed to source files: " + err.Error()}
+		}
+	}
+
+	log := p.log.With(slog.String("file", name))
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	r = p.verifyDetached(ctx, fsys, name)
+	if r.Status == Unsigned && p.history != nil {
+		r = p.verifyCommit(ctx, name)
+	}
+
+	log.Debug("Verified file", slog.String("status", string(r.Status)), slog.String("method", r.Method))
+
+	p.mu.Lock()
+	p.results[name] = r
+	p.mu.Unlock()
+
+	return r
+}
+
+func (p *p) verifyDetached(ctx context.Context, fsys fs.FS, name string) Result {
+	for _, ext := range p.signatures {
+		sig, err := fs.ReadFile(fsys, name+ext)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return Result{Status: Invalid, Reason: "failed to read signature: " + err.Error()}
+		}
+
+		v := p.verifiers[ext]
+
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return Result{Status: Invalid, Method: v.Method(), Reason: "failed to read file: " + err.Error()}
+		}
+
+		signer, err := v.Verify(ctx, content, sig)
+		switch {
+		case errors.Is(err, ErrUnknownSigner):
+			return Result{Status: Untrusted, Method: v.Method(), Reason: err.Error()}
+		case err != nil:
+			return Result{Status: Invalid, Method: v.Method(), Reason: err.Error()}
+		}
+
+		return Result{Status: Verified, Method: v.Method(), Signer: signer}
+	}
+
+	return Result{Status: Unsigned}
+}
+
+func (p *p) verifyCommit(ctx context.Context, name string) Result {
+	cs, err := p.history.Commits(ctx, name, 1)
+	if err != nil {
+		p.log.Warn("Failed to get last commit of file",
+			slog.String("file", name), slog.String("err", err.Error()))
+		return Result{Status: Unsigned}
+	}
+	if len(cs) == 0 || cs[0].Verification == nil {
+		return Result{Status: Unsigned}
+	}
+
+	v := cs[0].Verification
+	r := Result{
+		Method: "commit",
+		Signer: Signer{Name: v.Signer.Name, Email: v.Signer.Email},
+		Reason: v.Reason,
+	}
+	switch {
+	case v.Verified:
+		r.Status = Verified
+	case v.Reason == "" || strings.Contains(strings.ToLower(v.Reason), "unsigned"):
+		r.Status = Unsigned
+	default:
+		r.Status = Untrusted
+	}
+
+	return r
+}
+
+type signatureFS struct {
+	fs.FS
+	p *p
+}
+
+func (fsys *signatureFS) Metadata() metadata.Metadata {
+	if m, err := metadata.GetMetadata(fsys.FS); err == nil {
+		return m
+	}
+	return metadata.Map(map[string]any{})
+}
+
+func (fsys *signatureFS) Open(name string) (fs.File, error) {
+	f, err := fsys.FS.Open(name)
+	if err != nil || !slices.Contains(fsys.p.extensions, path.Ext(name)) {
+		return f, err
+	}
+
+	var m metadata.Metadata = &signatureMetadata{p: fsys.p, path: name}
+	if fm, err := metadata.GetMetadata(f); err == nil {
+		m = metadata.Join(fm, m)
+	}
+
+	if _, ok := f.(io.Seeker); ok {
+		return &seekerFile{file{File: f, metadata: m}}, nil
+	}
+	return &file{File: f, metadata: m}, nil
+}
+
+type file struct {
+	fs.File
+	metadata metadata.Metadata
+}
+
+func (f *file) Metadata() metadata.Metadata {
+	return f.metadata
+}
+
+// Keeps files that implement [io.Seeker] seekable, so renderers can read them
+// more than once.
+type seekerFile struct {
+	file
+}
+
+func (f *seekerFile) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+// Metadata of the verification of a file, which verifies the file on the first
+// access of one of the signature keys.
+type signatureMetadata struct {
+	p    *p
+	path string
+}
+
+func (m *signatureMetadata) Get(key string) (any, error) {
+	switch key {
+	case StatusKey:
+		return string(m.p.verify(m.path).Status), nil
+	case ResultKey:
+		return m.p.verify(m.path), nil
+	default:
+		return nil, metadata.ErrNotFound
+	}
+}
+
+func (m *signatureMetadata) Set(key string, v any) error {
+	return metadata.ErrImmutable
+}
+
+func (m *signatureMetadata) Delete(key string) error {
+	return metadata.ErrImmutable
+}
@@ -0,0 +1,275 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signature
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"strings"
+)
+
+// Namespace of SSH signatures used by [NewSSHVerifier] if none is provided, the
+// same used by "ssh-keygen -Y sign -n file".
+const DefaultSSHNamespace = "file"
+
+// Creates a [Verifier] of SSH signatures, as created by "ssh-keygen -Y sign",
+// signed by one of the allowed keys. Keys are in the authorized_keys format,
+// such as "ssh-ed25519 AAAA... author@example.com", the comment of the key being
+// used as the name of the [Signer].
+//
+// Only "ssh-ed25519" and "ssh-rsa" (with SHA-2 signatures) keys are supported.
+func NewSSHVerifier(namespace string, allowed ...string) (Verifier, error) {
+	if namespace == "" {
+		namespace = DefaultSSHNamespace
+	}
+
+	keys := make([]sshKey, 0, len(allowed))
+	for _, line := range allowed {
+		k, err := parseAuthorizedKey(line)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("invalid allowed key %q", line), err)
+		}
+		keys = append(keys, k)
+	}
+
+	return &sshVerifier{namespace: namespace, keys: keys}, nil
+}
+
+type sshVerifier struct {
+	namespace string
+	keys      []sshKey
+}
+
+type sshKey struct {
+	blob    []byte
+	comment string
+}
+
+func (v *sshVerifier) Method() string {
+	return "ssh"
+}
+
+func (v *sshVerifier) Verify(ctx context.Context, content, signature []byte) (Signer, error) {
+	sig, err := parseSSHSignature(signature)
+	if err != nil {
+		return Signer{}, err
+	}
+
+	if sig.namespace != v.namespace {
+		return Signer{}, fmt.Errorf("%w: namespace %q is not %q", ErrInvalidSignature, sig.namespace, v.namespace)
+	}
+
+	var key *sshKey
+	for i := range v.keys {
+		if bytes.Equal(v.keys[i].blob, sig.publicKey) {
+			key = &v.keys[i]
+			break
+		}
+	}
+	if key == nil {
+		return Signer{}, ErrUnknownSigner
+	}
+
+	var h hash.Hash
+	switch sig.hashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return Signer{}, fmt.Errorf("%w: unsupported hash algorithm %q", ErrInvalidSignature, sig.hashAlgorithm)
+	}
+	h.Write(content)
+
+	var signed bytes.Buffer
+	signed.WriteString("SSHSIG")
+	writeString(&signed, []byte(sig.namespace))
+	writeString(&signed, sig.reserved)
+	writeString(&signed, []byte(sig.hashAlgorithm))
+	writeString(&signed, h.Sum(nil))
+
+	if err := verifySSH(sig.publicKey, sig.format, sig.blob, signed.Bytes()); err != nil {
+		return Signer{}, errors.Join(ErrInvalidSignature, err)
+	}
+
+	return Signer{Name: key.comment, KeyID: fingerprint(key.blob)}, nil
+}
+
+// Returns the SHA256 fingerprint of the key, in the format of "ssh-keygen -l".
+func fingerprint(blob []byte) string {
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+func parseAuthorizedKey(line string) (sshKey, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return sshKey{}, errors.New("expected key type and base64 blob")
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return sshKey{}, errors.Join(errors.New("invalid base64 key"), err)
+	}
+
+	r := bytes.NewReader(blob)
+	if t, err := readString(r); err != nil || string(t) != fields[0] {
+		return sshKey{}, fmt.Errorf("key blob is not of type %q", fields[0])
+	}
+
+	return sshKey{blob: blob, comment: strings.Join(fields[2:], " ")}, nil
+}
+
+type sshSignature struct {
+	publicKey     []byte
+	namespace     string
+	reserved      []byte
+	hashAlgorithm string
+	format        string
+	blob          []byte
+}
+
+func parseSSHSignature(armored []byte) (sshSignature, error) {
+	s := string(armored)
+	start := strings.Index(s, "-----BEGIN SSH SIGNATURE-----")
+	end := strings.Index(s, "-----END SSH SIGNATURE-----")
+	if start == -1 || end < start {
+		return sshSignature{}, fmt.Errorf("%w: not a armored SSH signature", ErrInvalidSignature)
+	}
+
+	body := strings.Join(strings.Fields(s[start+len("-----BEGIN SSH SIGNATURE-----"):end]), "")
+	data, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return sshSignature{}, errors.Join(ErrInvalidSignature, err)
+	}
+
+	r := bytes.NewReader(data)
+
+	magic := make([]byte, 6)
+	if _, err := r.Read(magic); err != nil || string(magic) != "SSHSIG" {
+		return sshSignature{}, fmt.Errorf("%w: missing SSHSIG magic", ErrInvalidSignature)
+	}
+	var version uint32
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil || version != 1 {
+		return sshSignature{}, fmt.Errorf("%w: unsupported version", ErrInvalidSignature)
+	}
+
+	fields := make([][]byte, 5)
+	for i := range fields {
+		if fields[i], err = readString(r); err != nil {
+			return sshSignature{}, errors.Join(ErrInvalidSignature, err)
+		}
+	}
+
+	sr := bytes.NewReader(fields[4])
+	format, err := readString(sr)
+	if err != nil {
+		return sshSignature{}, errors.Join(ErrInvalidSignature, err)
+	}
+	blob, err := readString(sr)
+	if err != nil {
+		return sshSignature{}, errors.Join(ErrInvalidSignature, err)
+	}
+
+	return sshSignature{
+		publicKey:     fields[0],
+		namespace:     string(fields[1]),
+		reserved:      fields[2],
+		hashAlgorithm: string(fields[3]),
+		format:        string(format),
+		blob:          blob,
+	}, nil
+}
+
+func verifySSH(publicKey []byte, format string, sig []byte, data []byte) error {
+	r := bytes.NewReader(publicKey)
+	t, err := readString(r)
+	if err != nil {
+		return err
+	}
+
+	switch string(t) {
+	case "ssh-ed25519":
+		if format != "ssh-ed25519" {
+			return fmt.Errorf("signature format %q does not match key", format)
+		}
+		key, err := readString(r)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return errors.New("invalid ed25519 key")
+		}
+		if !ed25519.Verify(ed25519.PublicKey(key), data, sig) {
+			return errors.New("ed25519 signature does not match")
+		}
+		return nil
+
+	case "ssh-rsa":
+		e, err := readString(r)
+		if err != nil {
+			return err
+		}
+		n, err := readString(r)
+		if err != nil {
+			return err
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+
+		var digest []byte
+		var h crypto.Hash
+		switch format {
+		case "rsa-sha2-256":
+			sum := sha256.Sum256(data)
+			digest, h = sum[:], crypto.SHA256
+		case "rsa-sha2-512":
+			sum := sha512.Sum512(data)
+			digest, h = sum[:], crypto.SHA512
+		default:
+			return fmt.Errorf("unsupported RSA signature format %q", format)
+		}
+		return rsa.VerifyPKCS1v15(key, h, digest, sig)
+
+	default:
+		return fmt.Errorf("unsupported key type %q", t)
+	}
+}
+
+func readString(r *bytes.Reader) ([]byte, error) {
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, errors.New("unexpected end of data")
+	}
+	if int(n) > r.Len() {
+		return nil, errors.New("string length exceeds data")
+	}
+	b := make([]byte, n)
+	_, _ = r.Read(b)
+	return b, nil
+}
+
+func writeString(w *bytes.Buffer, b []byte) {
+	_ = binary.Write(w, binary.BigEndian, uint32(len(b)))
+	w.Write(b)
+}
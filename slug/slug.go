@@ -0,0 +1,75 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package slug provides the slugification strategies used by plugins to create
+// URL-safe identifiers from arbitrary text, such as heading anchors and permalinks.
+//
+// Plugins should accept a [Slugifier] on their options, defaulting to [Default], so
+// users can change the strategy in one place and have consistent identifiers across
+// renderers and generators, such as heading anchors, permalinks and the URLs of
+// taxonomies. Strategies other than [Slugify], such as transliteration to ASCII
+// and the handling of CJK text, are created with [New]:
+//
+//	slug.Default = slug.New(slug.Opts{
+//		Transliterate: true,
+//		CJK:           slug.SplitCJK,
+//		Replacements:  map[string]string{"&": " and ", "C++": "cpp"},
+//	})
+//
+// Default needs to be set before the plugins are constructed, since they keep
+// the slugifier of their options.
+package slug
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Converts arbitrary text to a URL-safe identifier.
+type Slugifier interface {
+	Slugify(s string) string
+}
+
+// Type adapter to allow the use of ordinary functions as [Slugifier] implementations.
+type Func func(string) string
+
+func (f Func) Slugify(s string) string {
+	return f(s)
+}
+
+// The default [Slugifier] used by plugins, see [Slugify].
+var Default Slugifier = Func(Slugify)
+
+// Lowercases the text, keeping unicode letters and digits, and replacing any other
+// sequence of characters with a single "-". Leading and trailing separators are trimmed.
+func Slugify(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	sep := false
+	for _, c := range strings.ToLower(s) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			if sep && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(c)
+			sep = false
+		} else {
+			sep = true
+		}
+	}
+
+	return b.String()
+}
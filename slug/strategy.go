@@ -0,0 +1,236 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slug
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Handling of CJK (Chinese, Japanese and Korean) characters, which are written
+// without spaces between words.
+type CJK int
+
+const (
+	// Keeps runs of CJK characters together, like other letters.
+	KeepCJK CJK = iota
+	// Separates each CJK character, so slugs of CJK text are split into
+	// characters instead of being a single word.
+	SplitCJK
+	// Removes CJK characters, treating them as separators.
+	DropCJK
+)
+
+// Options of the [Slugifier] created by [New]. The zero value creates the same
+// slugs as [Slugify].
+type Opts struct {
+	// Replaces letters with diacritics and of other alphabets, such as "é",
+	// "ß" and "д", with their ASCII transliterations, such as "e", "ss" and
+	// "d". Letters without a transliteration, such as CJK ones, are kept.
+	Transliterate bool
+	// Handling of CJK characters. Defaults to [KeepCJK].
+	CJK CJK
+	// Text replaced before slugifying, such as "&" with " and " or "C++" with
+	// "cpp". Replacements are case sensitive, and the longest text is replaced
+	// when more than one match.
+	Replacements map[string]string
+	// Separator of the words of slugs. Defaults to "-".
+	Separator string
+	// Max number of characters of slugs, which are cut at the last separator
+	// before the limit if possible. Zero or negative values disable the limit.
+	MaxLength int
+}
+
+// Creates a configurable [Slugifier]. Like [Slugify], slugs are lowercase, and
+// any sequence of characters that aren't letters or digits is replaced with
+// the separator.
+func New(opts ...Opts) Slugifier {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Separator == "" {
+		opt.Separator = "-"
+	}
+
+	s := &slugifier{
+		transliterate: opt.Transliterate,
+		cjk:           opt.CJK,
+		separator:     opt.Separator,
+		maxLength:     opt.MaxLength,
+	}
+
+	if len(opt.Replacements) > 0 {
+		olds := make([]string, 0, len(opt.Replacements))
+		for k := range opt.Replacements {
+			if k != "" {
+				olds = append(olds, k)
+			}
+		}
+		// strings.Replacer uses the first matching pair, so longer texts are
+		// placed first.
+		slices.SortFunc(olds, func(a, b string) int {
+			if c := len(b) - len(a); c != 0 {
+				return c
+			}
+			return strings.Compare(a, b)
+		})
+
+		pairs := make([]string, 0, len(olds)*2)
+		for _, k := range olds {
+			pairs = append(pairs, k, opt.Replacements[k])
+		}
+		s.replacer = strings.NewReplacer(pairs...)
+	}
+
+	return s
+}
+
+// Slugifier that transliterates to ASCII, see Opts.Transliterate.
+var ASCII Slugifier = New(Opts{Transliterate: true})
+
+type slugifier struct {
+	transliterate bool
+	cjk           CJK
+	separator     string
+	maxLength     int
+	replacer      *strings.Replacer
+}
+
+func (s *slugifier) Slugify(text string) string {
+	if s.replacer != nil {
+		text = s.replacer.Replace(text)
+	}
+
+	var b strings.Builder
+	b.Grow(len(text))
+
+	sep := false
+	write := func(w string) {
+		if sep && b.Len() > 0 {
+			b.WriteString(s.separator)
+		}
+		b.WriteString(w)
+		sep = false
+	}
+
+	for _, c := range strings.ToLower(text) {
+		switch {
+		case s.cjk != KeepCJK && isCJK(c):
+			sep = true
+			if s.cjk == SplitCJK {
+				write(string(c))
+				sep = true
+			}
+		case s.transliterate && unicode.Is(unicode.Mn, c):
+			// Combining marks, such as the ones of decomposed diacritics,
+			// are removed with the transliteration of their letters.
+		case unicode.IsLetter(c) || unicode.IsDigit(c):
+			if t, ok := transliterations[c]; ok && s.transliterate {
+				// Signs without a transliteration, such as the soft sign of
+				// Cyrillic, are removed without separating the word.
+				if t != "" {
+					write(t)
+				}
+			} else {
+				write(string(c))
+			}
+		default:
+			sep = true
+		}
+	}
+
+	slug := b.String()
+	if s.maxLength > 0 && utf8.RuneCountInString(slug) > s.maxLength {
+		slug = truncate(slug, s.maxLength, s.separator)
+	}
+
+	return slug
+}
+
+func truncate(slug string, max int, separator string) string {
+	i := 0
+	for n := range slug {
+		if i == max {
+			slug = slug[:n]
+			break
+		}
+		i++
+	}
+
+	if cut := strings.LastIndex(slug, separator); cut > 0 {
+		return slug[:cut]
+	}
+	return strings.TrimSuffix(slug, separator)
+}
+
+func isCJK(c rune) bool {
+	// The prolonged sound and iteration marks are on the common script.
+	return unicode.In(c, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		c == 'ー' || c == '々' || c == 'ゝ' || c == 'ゞ' || c == 'ヽ' || c == 'ヾ'
+}
+
+// Transliterations of lowercase letters to ASCII.
+var transliterations = func() map[rune]string {
+	m := map[rune]string{}
+	for _, group := range []struct{ from, to string }{
+		// Latin.
+		{"àáâãäåāăą", "a"},
+		{"çćĉċč", "c"},
+		{"ďđð", "d"},
+		{"èéêëēĕėęě", "e"},
+		{"ĝğġģ", "g"},
+		{"ĥħ", "h"},
+		{"ìíîïĩīĭįı", "i"},
+		{"ĵ", "j"},
+		{"ķ", "k"},
+		{"ĺļľŀł", "l"},
+		{"ñńņňŉ", "n"},
+		{"òóôõöøōŏő", "o"},
+		{"ŕŗř", "r"},
+		{"śŝşšș", "s"},
+		{"ţťŧț", "t"},
+		{"ùúûüũūŭůűų", "u"},
+		{"ŵ", "w"},
+		{"ýÿŷ", "y"},
+		{"źżž", "z"},
+		// Greek.
+		{"αά", "a"}, {"β", "v"}, {"γ", "g"}, {"δ", "d"}, {"εέ", "e"}, {"ζ", "z"},
+		{"ηή", "i"}, {"ιίϊΐ", "i"}, {"κ", "k"}, {"λ", "l"}, {"μ", "m"}, {"ν", "n"}, {"ξ", "x"},
+		{"οό", "o"}, {"π", "p"}, {"ρ", "r"}, {"σς", "s"}, {"τ", "t"}, {"υύϋΰ", "y"},
+		{"φ", "f"}, {"ψ", "ps"}, {"ωώ", "o"},
+		// Cyrillic.
+		{"а", "a"}, {"б", "b"}, {"в", "v"}, {"г", "g"}, {"д", "d"}, {"её", "e"},
+		{"з", "z"}, {"иій", "i"}, {"к", "k"}, {"л", "l"}, {"м", "m"}, {"н", "n"},
+		{"о", "o"}, {"п", "p"}, {"р", "r"}, {"с", "s"}, {"т", "t"}, {"у", "u"},
+		{"ф", "f"}, {"ц", "c"}, {"ы", "y"}, {"э", "e"}, {"ъь", ""},
+	} {
+		for _, c := range group.from {
+			m[c] = group.to
+		}
+	}
+	for c, t := range map[rune]string{
+		'ß': "ss", 'æ': "ae", 'œ': "oe", 'þ': "th", 'ĳ': "ij",
+		'θ': "th", 'χ': "ch",
+		'ж': "zh", 'х': "kh", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ю': "yu", 'я': "ya", 'є': "ye", 'ї': "yi",
+	} {
+		m[c] = t
+	}
+	return m
+}()
@@ -0,0 +1,364 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package snapshot provides a [plugin.Sourcer] wrapper that keeps snapshots of
+// the file systems of the wrapped sourcer, only swapping to a new one if it
+// passes validation, so a bad push (for example, one that deletes the index
+// page) can't blank the site. Previous snapshots are kept as a warm standby and
+// can be restored with [(Sourcer).Rollback].
+//
+// Snapshots are the [fs.FS] values returned by the wrapped sourcer, so they are
+// only frozen if the file systems are, such as ones pinned to a commit.
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-snapshot-sourcer"
+
+// Returned by [(Sourcer).Rollback] if there isn't a previous snapshot.
+var ErrNoSnapshot = errors.New("no previous snapshot to roll back to")
+
+// Validates a file system before it becomes the current snapshot.
+type Validator interface {
+	Validate(fsys fs.FS) error
+}
+
+// Type adapter to allow the use of ordinary functions as [Validator] implementations.
+type ValidatorFunc func(fsys fs.FS) error
+
+func (f ValidatorFunc) Validate(fsys fs.FS) error {
+	return f(fsys)
+}
+
+// Creates a [Validator] that fails if any of the files don't exist, such as the
+// index page.
+func RequireFiles(names ...string) Validator {
+	return ValidatorFunc(func(fsys fs.FS) error {
+		errs := []error{}
+		for _, name := range names {
+			if _, err := fs.Stat(fsys, name); err != nil {
+				errs = append(errs, fmt.Errorf("required file %q: %w", name, err))
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// Creates a [Validator] that runs check on every file with one of the extensions
+// (or all files, if none are provided), failing if more than max files fail the
+// check, for example files with invalid frontmatter.
+func MaxFileErrors(max int, check func(fsys fs.FS, name string) error, extensions ...string) Validator {
+	return ValidatorFunc(func(fsys fs.FS) error {
+		errs := []error{}
+
+		err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || len(extensions) > 0 && !slices.Contains(extensions, path.Ext(p)) {
+				return nil
+			}
+			if err := check(fsys, p); err != nil {
+				errs = append(errs, fmt.Errorf("file %q: %w", p, err))
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.Join(errors.New("failed to walk file system"), err)
+		}
+
+		if len(errs) > max {
+			return errors.Join(
+				fmt.Errorf("%d files failed validation, more than the max of %d", len(errs), max),
+				errors.Join(errs...),
+			)
+		}
+		return nil
+	})
+}
+
+// Error of a file system that failed validation, which was not swapped in.
+type ValidationError struct {
+	Errs []error
+}
+
+func (e *ValidationError) Error() string {
+	return "file system failed validation: " + errors.Join(e.Errs...).Error()
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Errs
+}
+
+// A file system sourced by the wrapped sourcer.
+type Snapshot struct {
+	// Sequential identifier of the snapshot, starting at 1.
+	ID   int       `json:"id"`
+	Time time.Time `json:"time"`
+	FS   fs.FS     `json:"-"`
+}
+
+// The snapshot [plugin.Sourcer], which file system always serves the current
+// snapshot, so swaps and rollbacks take effect without sourcing again.
+type Sourcer interface {
+	plugin.Sourcer
+	// Sources a new file system from the wrapped sourcer, swapping it in as
+	// the current snapshot if it passes validation. Returns a [*ValidationError]
+	// if it didn't, in which case the current snapshot is kept.
+	Refresh() error
+	// Restores the previous snapshot, discarding the current one. Returns
+	// [ErrNoSnapshot] if there isn't any.
+	Rollback() error
+	// Returns the current snapshot, if any.
+	Current() (Snapshot, bool)
+	// Returns all kept snapshots, from newest to oldest, the first being the
+	// current one.
+	Snapshots() []Snapshot
+}
+
+// Creates the snapshot [Sourcer], wrapping the sourcer. Source refreshes the
+// snapshot and returns a file system that always serves the current one, only
+// returning a error if there isn't any snapshot to serve.
+func New(sourcer plugin.Sourcer, opts ...Opts) Sourcer {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.History == 0 {
+		opt.History = 5
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be wrapped should not be nil")
+
+	return &p{
+		sourcer:    sourcer,
+		validators: opt.Validators,
+		history:    opt.History,
+		onSwap:     opt.OnSwap,
+
+		snapshots: []Snapshot{},
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Validators that new file systems need to pass to be swapped in.
+	Validators []Validator
+	// Number of snapshots kept, including the current one. Defaults to 5.
+	History int
+	// Called after the current snapshot changes, by a refresh or rollback. Old
+	// is the zero value on the first snapshot.
+	OnSwap func(old, new Snapshot)
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	sourcer    plugin.Sourcer
+	validators []Validator
+	history    int
+	onSwap     func(old, new Snapshot)
+
+	mu        sync.RWMutex
+	snapshots []Snapshot
+	nextID    int
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+func (p *p) Source() (fs.FS, error) {
+	p.assert.NotNil(p.log)
+
+	err := p.Refresh()
+	if _, ok := p.Current(); !ok {
+		return nil, errors.Join(errors.New("failed to source first snapshot"), err)
+	}
+	if err != nil {
+		p.log.Error("Failed to refresh snapshot, serving previous snapshot", slog.String("err", err.Error()))
+	}
+
+	return &snapshotFS{p: p}, nil
+}
+
+func (p *p) Refresh() error {
+	p.assert.NotNil(p.sourcer)
+	p.assert.NotNil(p.log)
+
+	log := p.log.With(slog.String("sourcer", p.sourcer.Name()))
+	log.Debug("Refreshing snapshot")
+
+	fsys, err := p.sourcer.Source()
+	if err != nil {
+		return errors.Join(errors.New("failed to source file system"), err)
+	}
+
+	errs := []error{}
+	for _, v := range p.validators {
+		if err := v.Validate(fsys); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		err := &ValidationError{Errs: errs}
+		log.Warn("File system failed validation, keeping current snapshot", slog.String("err", err.Error()))
+		return err
+	}
+
+	p.mu.Lock()
+	p.nextID++
+	s := Snapshot{ID: p.nextID, Time: time.Now(), FS: fsys}
+
+	var old Snapshot
+	if len(p.snapshots) > 0 {
+		old = p.snapshots[0]
+	}
+
+	p.snapshots = append([]Snapshot{s}, p.snapshots...)
+	if len(p.snapshots) > p.history {
+		p.snapshots = p.snapshots[:p.history]
+	}
+	p.mu.Unlock()
+
+	log.Debug("Swapped to new snapshot", slog.Int("snapshot", s.ID))
+
+	if p.onSwap != nil {
+		p.onSwap(old, s)
+	}
+
+	return nil
+}
+
+func (p *p) Rollback() error {
+	p.mu.Lock()
+	if len(p.snapshots) < 2 {
+		p.mu.Unlock()
+		return ErrNoSnapshot
+	}
+
+	old, s := p.snapshots[0], p.snapshots[1]
+	p.snapshots = p.snapshots[1:]
+	p.mu.Unlock()
+
+	p.log.Info("Rolled back snapshot", slog.Int("from", old.ID), slog.Int("to", s.ID))
+
+	if p.onSwap != nil {
+		p.onSwap(old, s)
+	}
+
+	return nil
+}
+
+func (p *p) Current() (Snapshot, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if len(p.snapshots) == 0 {
+		return Snapshot{}, false
+	}
+	return p.snapshots[0], true
+}
+
+func (p *p) Snapshots() []Snapshot {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return slices.Clone(p.snapshots)
+}
+
+// File system that serves the current snapshot.
+type snapshotFS struct {
+	p *p
+}
+
+func (fsys *snapshotFS) current() fs.FS {
+	s, _ := fsys.p.Current()
+	return s.FS
+}
+
+func (fsys *snapshotFS) Open(name string) (fs.File, error) {
+	cur := fsys.current()
+	if cur == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return cur.Open(name)
+}
+
+func (fsys *snapshotFS) Metadata() metadata.Metadata {
+	if m, err := metadata.GetMetadata(fsys.current()); err == nil {
+		return m
+	}
+	return metadata.Map(map[string]any{})
+}
+
+// Creates a [http.Handler] of the snapshots API of the sourcer. GET requests
+// respond with the JSON list of snapshots, and POST requests to ".../rollback"
+// roll back to the previous snapshot, with "409 Conflict" if there isn't any.
+//
+// The handler doesn't authenticate requests, so it should be mounted behind
+// the authentication of the application.
+func NewHandler(s Sourcer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && path.Base(r.URL.Path) == "rollback":
+			if err := s.Rollback(); errors.Is(err, ErrNoSnapshot) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		case r.Method == http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(s.Snapshots())
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source of the configuration of tenants, such as a file or a database.
+type Store interface {
+	// Returns the tenant served on the host, and false if there's none.
+	Lookup(ctx context.Context, host string) (Tenant, bool, error)
+}
+
+// Type adapter to allow the use of ordinary functions as [Store] implementations.
+type StoreFunc func(ctx context.Context, host string) (Tenant, bool, error)
+
+func (f StoreFunc) Lookup(ctx context.Context, host string) (Tenant, bool, error) {
+	return f(ctx, host)
+}
+
+// Creates a [Store] of the tenants.
+func Static(tenants ...Tenant) Store {
+	hosts := hostsOf(tenants)
+	return StoreFunc(func(ctx context.Context, host string) (Tenant, bool, error) {
+		t, ok := hosts[host]
+		return t, ok, nil
+	})
+}
+
+// Creates a [Store] of the tenants on the JSON file, a array of [Tenant]
+// objects, which is read again when it's modification time changes, so tenants
+// can be added and changed without restarting the server.
+func File(name string) Store {
+	return &fileStore{name: name}
+}
+
+type fileStore struct {
+	name string
+
+	mu      sync.Mutex
+	hosts   map[string]Tenant
+	modTime time.Time
+}
+
+func (s *fileStore) Lookup(ctx context.Context, host string) (Tenant, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.name)
+	if err != nil {
+		return Tenant{}, false, errors.Join(fmt.Errorf("failed to stat tenants file %q", s.name), err)
+	}
+
+	if s.hosts == nil || !info.ModTime().Equal(s.modTime) {
+		data, err := os.ReadFile(s.name)
+		if err != nil {
+			return Tenant{}, false, errors.Join(fmt.Errorf("failed to read tenants file %q", s.name), err)
+		}
+
+		var tenants []Tenant
+		if err := json.Unmarshal(data, &tenants); err != nil {
+			return Tenant{}, false, errors.Join(fmt.Errorf("failed to decode tenants file %q", s.name), err)
+		}
+
+		s.hosts, s.modTime = hostsOf(tenants), info.ModTime()
+	}
+
+	t, ok := s.hosts[host]
+	return t, ok, nil
+}
+
+// Returns the tenants by their normalized hosts.
+func hostsOf(tenants []Tenant) map[string]Tenant {
+	hosts := map[string]Tenant{}
+	for _, t := range tenants {
+		for _, h := range t.Hosts {
+			hosts[strings.ToLower(h)] = t
+		}
+	}
+	return hosts
+}
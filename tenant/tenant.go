@@ -0,0 +1,390 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tenant serves multiple blogs from a single process, such as on a small
+// blog-hosting service. The tenant of each request is resolved by it's host
+// from a [Store] of tenant configurations, and it's pipeline, usually a blogo
+// engine with the sourcer of the tenant, is created by a [Factory] on the
+// first request and cached, so idle tenants don't use resources until they are
+// visited. Each tenant has it's own pipeline, and so it's own caches, and a
+// [Quota] of requests and of the caches and limits of it's core server.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/ratelimit"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+// A blog served by the [Resolver].
+type Tenant struct {
+	// Identifier of the tenant, which names it's pipeline.
+	ID string `json:"id"`
+	// Hosts that the blog of the tenant is served on, such as "example.com" or
+	// "alice.blogs.example.com".
+	Hosts []string `json:"hosts"`
+	// Settings of the tenant used by the [Factory] to create it's pipeline, such
+	// as the repository of it's sources.
+	Settings map[string]string `json:"settings,omitempty"`
+	// Name of the time zone of the blog, such as "America/Sao_Paulo". Defaults
+	// to UTC. See [Tenant.Location].
+	TimeZone string `json:"time_zone,omitempty"`
+	Quota    Quota  `json:"quota,omitempty"`
+}
+
+// Returns the location of the TimeZone of the tenant, which factories should
+// pass to the plugins of the tenant, such as with Opts.Location of the index
+// package, so each blog shows dates on it's own time zone.
+func (t Tenant) Location() (*time.Location, error) {
+	if t.TimeZone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(t.TimeZone)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to load time zone of tenant %q", t.ID), err)
+	}
+	return loc, nil
+}
+
+// Limits of the resources used by a tenant.
+type Quota struct {
+	// Max number of requests to the blog of the tenant per minute, answered with
+	// "429 Too Many Requests" when reached. Zero disables the limit.
+	RequestsPerMinute int `json:"requests_per_minute,omitempty"`
+
+	// Duration and max number of the cached renders of the core server of the
+	// tenant, see ServerOpts.CacheTTL of the core package.
+	CacheTTL        time.Duration `json:"cache_ttl,omitempty"`
+	CacheMaxEntries int           `json:"cache_max_entries,omitempty"`
+
+	// Limits of the sizes of sourced and rendered files, see ServerOpts.MaxFileSize
+	// of the core package.
+	MaxFileSize   int64 `json:"max_file_size,omitempty"`
+	MaxOutputSize int64 `json:"max_output_size,omitempty"`
+}
+
+// Returns the options of the core server that apply the quota, which factories
+// should pass to the engine of the tenant, such as with Opts.ServerOptions of
+// the blogo package.
+func (q Quota) ServerOptions() []core.ServerOption {
+	opts := []core.ServerOption{}
+	if q.CacheTTL > 0 {
+		opts = append(opts, core.WithCache(q.CacheTTL, q.CacheMaxEntries))
+	}
+	if q.MaxFileSize > 0 || q.MaxOutputSize > 0 {
+		opts = append(opts, core.WithSizeLimits(q.MaxFileSize, q.MaxOutputSize))
+	}
+	return opts
+}
+
+// Creates the pipeline of the tenant, such as a blogo engine with the sourcer
+// of the repository of the tenant's settings.
+type Factory func(ctx context.Context, t Tenant) (http.Handler, error)
+
+// A [http.Handler] that serves the pipelines of tenants by the host of requests.
+type Resolver interface {
+	http.Handler
+	// Removes the cached configuration and pipeline of the tenant, so they are
+	// created again on the next request.
+	Evict(id string)
+}
+
+// Creates a [Resolver] of the tenants of the store, creating their pipelines
+// with the factory.
+//
+// Tenants of the store are cached for Opts.LookupTTL, and their pipelines are
+// created again when the tenant changes. Up to Opts.MaxTenants pipelines are
+// kept, removing the least recently used ones when the limit is reached.
+// Concurrent requests to a tenant without a pipeline wait for the same call to
+// the factory.
+func New(store Store, factory Factory, opts ...Opts) Resolver {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.MaxTenants == 0 {
+		opt.MaxTenants = 256
+	}
+	if opt.LookupTTL == 0 {
+		opt.LookupTTL = time.Minute
+	}
+	if opt.FactoryTimeout == 0 {
+		opt.FactoryTimeout = 30 * time.Second
+	}
+	if opt.NotFound == nil {
+		opt.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Blog not found", http.StatusNotFound)
+		})
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(store, "Store should not be nil")
+	opt.Assertions.NotNil(factory, "Factory should not be nil")
+
+	return &resolver{
+		store:   store,
+		factory: factory,
+
+		maxTenants:     opt.MaxTenants,
+		lookupTTL:      opt.LookupTTL,
+		factoryTimeout: opt.FactoryTimeout,
+		notFound:       opt.NotFound,
+
+		hosts:     map[string]lookup{},
+		pipelines: map[string]*pipeline{},
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Max number of pipelines kept. Defaults to 256.
+	MaxTenants int
+	// Duration that the tenants of the store are cached, including hosts
+	// without tenants. Defaults to 1 minute.
+	LookupTTL time.Duration
+	// Timeout of the calls to the factory. Defaults to 30 seconds.
+	FactoryTimeout time.Duration
+	// Handler of requests to hosts without a tenant. Defaults to "404 Not Found".
+	NotFound http.Handler
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type resolver struct {
+	store   Store
+	factory Factory
+
+	maxTenants     int
+	lookupTTL      time.Duration
+	factoryTimeout time.Duration
+	notFound       http.Handler
+
+	mu        sync.Mutex
+	hosts     map[string]lookup
+	pipelines map[string]*pipeline
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+// A cached result of the store.
+type lookup struct {
+	tenant  Tenant
+	found   bool
+	expires time.Time
+}
+
+// The pipeline of a tenant.
+type pipeline struct {
+	tenant  Tenant
+	handler http.Handler
+	limiter ratelimit.Limiter
+	err     error
+	// Closed once the pipeline is created.
+	ready chan struct{}
+
+	lastUsed time.Time
+}
+
+func (res *resolver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	res.assert.NotNil(w)
+	res.assert.NotNil(r)
+	res.assert.NotNil(res.log)
+
+	host := hostOf(r)
+	log := res.log.With(slog.String("host", host))
+
+	t, ok, err := res.lookup(r.Context(), host)
+	if err != nil {
+		log.Error("Failed to lookup tenant", slog.String("err", err.Error()))
+		http.Error(w, "Failed to find blog", http.StatusServiceUnavailable)
+		return
+	}
+	if !ok {
+		log.Debug("No tenant of host")
+		res.notFound.ServeHTTP(w, r)
+		return
+	}
+
+	log = log.With(slog.String("tenant", t.ID))
+
+	p := res.pipeline(t, log)
+	select {
+	case <-p.ready:
+	case <-r.Context().Done():
+		return
+	}
+	if p.err != nil {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "Failed to start blog", http.StatusServiceUnavailable)
+		return
+	}
+
+	if p.limiter != nil && !p.limiter.Allow(t.ID) {
+		log.Debug("Tenant over request quota")
+		w.Header().Set("Retry-After", strconv.Itoa(60))
+		http.Error(w, "Too many requests", http.StatusTooManyRequests)
+		return
+	}
+
+	p.handler.ServeHTTP(w, r)
+}
+
+// Returns the tenant of the host, from the cache or the store.
+func (res *resolver) lookup(ctx context.Context, host string) (Tenant, bool, error) {
+	now := time.Now()
+
+	res.mu.Lock()
+	l, ok := res.hosts[host]
+	res.mu.Unlock()
+	if ok && now.Before(l.expires) {
+		return l.tenant, l.found, nil
+	}
+
+	t, found, err := res.store.Lookup(ctx, host)
+	if err != nil {
+		return Tenant{}, false, err
+	}
+
+	res.mu.Lock()
+	// Removes expired lookups, so requests to arbitrary hosts don't grow the cache.
+	for h, l := range res.hosts {
+		if now.After(l.expires) {
+			delete(res.hosts, h)
+		}
+	}
+	res.hosts[host] = lookup{tenant: t, found: found, expires: now.Add(res.lookupTTL)}
+	res.mu.Unlock()
+
+	return t, found, nil
+}
+
+// Returns the pipeline of the tenant, creating it in the background if it
+// doesn't exist or if the tenant changed.
+func (res *resolver) pipeline(t Tenant, log *slog.Logger) *pipeline {
+	res.mu.Lock()
+	defer res.mu.Unlock()
+
+	p, ok := res.pipelines[t.ID]
+	if ok && reflect.DeepEqual(p.tenant, t) {
+		p.lastUsed = time.Now()
+		return p
+	}
+	if ok {
+		log.Debug("Tenant changed, creating pipeline again")
+	}
+
+	if len(res.pipelines) >= res.maxTenants {
+		res.evictOldest(log)
+	}
+
+	p = &pipeline{tenant: t, ready: make(chan struct{}), lastUsed: time.Now()}
+	if t.Quota.RequestsPerMinute > 0 {
+		p.limiter = ratelimit.NewFixedWindow(t.Quota.RequestsPerMinute, time.Minute)
+	}
+	res.pipelines[t.ID] = p
+
+	go res.create(p, log)
+
+	return p
+}
+
+func (res *resolver) create(p *pipeline, log *slog.Logger) {
+	defer close(p.ready)
+
+	log.Debug("Creating pipeline of tenant")
+
+	ctx, cancel := context.WithTimeout(context.Background(), res.factoryTimeout)
+	defer cancel()
+
+	h, err := res.factory(ctx, p.tenant)
+	if err == nil && h == nil {
+		err = errors.New("factory returned a nil handler")
+	}
+	if err != nil {
+		log.Error("Failed to create pipeline of tenant", slog.String("err", err.Error()))
+		p.err = err
+
+		// Failed pipelines are removed, so the next request tries again.
+		res.mu.Lock()
+		if res.pipelines[p.tenant.ID] == p {
+			delete(res.pipelines, p.tenant.ID)
+		}
+		res.mu.Unlock()
+		return
+	}
+
+	p.handler = h
+}
+
+// Removes the least recently used pipeline. Must be called with the mutex locked.
+func (res *resolver) evictOldest(log *slog.Logger) {
+	oldest := ""
+	var t time.Time
+	for id, p := range res.pipelines {
+		if oldest == "" || p.lastUsed.Before(t) {
+			oldest, t = id, p.lastUsed
+		}
+	}
+	if oldest != "" {
+		log.Debug("Max tenants reached, removing least recently used pipeline",
+			slog.String("evicted", oldest))
+		delete(res.pipelines, oldest)
+	}
+}
+
+func (res *resolver) Evict(id string) {
+	res.mu.Lock()
+	defer res.mu.Unlock()
+
+	delete(res.pipelines, id)
+	for h, l := range res.hosts {
+		if l.tenant.ID == id {
+			delete(res.hosts, h)
+		}
+	}
+}
+
+// Returns the host of the request, lowercased and without the port.
+func hostOf(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
@@ -0,0 +1,392 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package usage accounts the bandwidth and render time used by each tenant or
+// path prefix of the server, such as when hosting multiple blogs on shared
+// infrastructure, exposing them as metrics and on a admin endpoint, and
+// optionally enforcing soft limits on them.
+package usage
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/auth"
+	"forge.capytal.company/loreddev/blogo/core"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-usage-middleware"
+
+// Accounts the usage of requests by their key, which is a [plugin.Middleware]
+// and a [plugin.Instrumented] plugin.
+type Plugin interface {
+	plugin.Middleware
+	plugin.Instrumented
+	// Returns the usage of each key on the current window.
+	Usage() map[string]Usage
+	// Reports if the key exceeded it's limit on the current window.
+	Exceeded(key string) bool
+}
+
+// Usage of a key on a window.
+type Usage struct {
+	Requests int64 `json:"requests"`
+	// Bytes of the bodies of responses.
+	Bytes int64 `json:"bytes"`
+	// Time spent by the handler on the requests, including renders and responses
+	// from the cache, used as a approximation of the render CPU.
+	RenderTime time.Duration `json:"render_time"`
+	// Start of the window.
+	Since time.Time `json:"since"`
+	// If the usage exceeded the limit of the key.
+	Exceeded bool `json:"exceeded"`
+}
+
+// Soft limits of the usage of a key on a window. Zero values disable the limit.
+type Limit struct {
+	Bytes      int64         `json:"bytes,omitempty"`
+	RenderTime time.Duration `json:"render_time,omitempty"`
+}
+
+// Reports if the usage exceeds the limit.
+func (l Limit) Exceeds(u Usage) bool {
+	return (l.Bytes > 0 && u.Bytes > l.Bytes) || (l.RenderTime > 0 && u.RenderTime > l.RenderTime)
+}
+
+// Returns the key of the usage of the request.
+type KeyFunc func(r *http.Request) string
+
+// Keys the usage by the host of the request, lowercased and without the port,
+// which is usually the tenant when serving multiple blogs.
+func ByHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// Keys the usage by the longest of the prefixes that the path of the request
+// is under, or "/" if none.
+func ByPrefix(prefixes ...string) KeyFunc {
+	ps := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		ps[i] = "/" + strings.Trim(p, "/")
+	}
+	slices.SortFunc(ps, func(a, b string) int { return len(b) - len(a) })
+
+	return func(r *http.Request) string {
+		for _, p := range ps {
+			if r.URL.Path == p || strings.HasPrefix(r.URL.Path, strings.TrimSuffix(p, "/")+"/") {
+				return p
+			}
+		}
+		return "/"
+	}
+}
+
+// Creates the usage [Plugin].
+//
+// Usage is accounted on windows of Opts.Window, which start on the first request
+// of each key. When the usage of a key exceeds it's limit, Opts.OnExceeded is
+// called once on the window, and if Opts.Enforce is true, the next requests of
+// the key are responded with "429 Too Many Requests" and the Retry-After header
+// until the window ends. Requests in progress are never interrupted, so usage
+// can go over the limit, which is why they are soft limits.
+//
+// GET requests to Opts.Path, authenticated by Opts.Authenticator with
+// Opts.Scopes, respond with the usage of each key as JSON. If there's no
+// authenticator, the endpoint responds "404 Not Found".
+func New(opts ...Opts) Plugin {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Key == nil {
+		opt.Key = ByHost
+	}
+	if opt.Window == 0 {
+		opt.Window = 24 * time.Hour
+	}
+	if opt.Path == "" {
+		opt.Path = "/.blogo/usage"
+	}
+	if opt.Scopes == nil {
+		opt.Scopes = []string{"admin"}
+	}
+	if opt.Now == nil {
+		opt.Now = time.Now
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	return &p{
+		key:           opt.Key,
+		window:        opt.Window,
+		limits:        opt.Limits,
+		defaultLimit:  opt.DefaultLimit,
+		enforce:       opt.Enforce,
+		onExceeded:    opt.OnExceeded,
+		path:          "/" + strings.Trim(opt.Path, "/"),
+		authenticator: opt.Authenticator,
+		scopes:        opt.Scopes,
+		now:           opt.Now,
+
+		usage: map[string]*Usage{},
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Key of the usage of requests. Defaults to [ByHost].
+	Key KeyFunc
+	// Duration of the windows of usage. Defaults to 24 hours.
+	Window time.Duration
+
+	// Limits of the usage of keys, and the limit of keys that aren't on it.
+	Limits       map[string]Limit
+	DefaultLimit Limit
+	// Rejects the requests of keys that exceeded their limits.
+	Enforce bool
+	// Called when a key exceeds it's limit, once per window.
+	OnExceeded func(key string, u Usage)
+
+	// Path of the endpoint of the usage. Defaults to "/.blogo/usage".
+	Path string
+	// Authenticator of the requests to Path. If nil, the endpoint is disabled.
+	Authenticator auth.Authenticator
+	// Scopes that the identity needs to have. Defaults to "admin".
+	Scopes []string
+
+	// Returns the current time. Defaults to [time.Now].
+	Now func() time.Time
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type p struct {
+	key           KeyFunc
+	window        time.Duration
+	limits        map[string]Limit
+	defaultLimit  Limit
+	enforce       bool
+	onExceeded    func(key string, u Usage)
+	path          string
+	authenticator auth.Authenticator
+	scopes        []string
+	now           func() time.Time
+
+	mu    sync.Mutex
+	usage map[string]*Usage
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (p *p) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (p *p) SetLogger(logger *slog.Logger) {
+	if p.injectLogger {
+		p.log = logger
+	}
+}
+
+func (p *p) Middleware(next http.Handler) http.Handler {
+	var endpoint http.Handler = http.NotFoundHandler()
+	if p.authenticator != nil {
+		endpoint = auth.Require(p.authenticator, http.HandlerFunc(p.serveUsage), auth.RequireOpts{
+			Scopes:     p.scopes,
+			Assertions: p.assert,
+			Logger:     p.log,
+		})
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.assert.NotNil(p.log)
+
+		if r.URL.Path == p.path {
+			w.Header().Set("Cache-Control", "no-store")
+			endpoint.ServeHTTP(w, r)
+			return
+		}
+
+		key := p.key(r)
+
+		if p.enforce {
+			if u, ok := p.current(key); ok && u.Exceeded {
+				retry := u.Since.Add(p.window).Sub(p.now())
+				core.Reject(w, r, core.RejectError{
+					Status:     http.StatusTooManyRequests,
+					RetryAfter: retry,
+					Reason:     "Usage limit exceeded",
+				})
+				return
+			}
+		}
+
+		cw := &countingWriter{ResponseWriter: w}
+		start := p.now()
+
+		defer func() {
+			p.add(key, cw.n, p.now().Sub(start))
+		}()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// Returns the usage of the key on the current window, if any.
+func (p *p) current(key string) (Usage, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	u, ok := p.usage[key]
+	if !ok || !p.now().Before(u.Since.Add(p.window)) {
+		return Usage{}, false
+	}
+	return *u, true
+}
+
+func (p *p) add(key string, bytes int64, elapsed time.Duration) {
+	now := p.now()
+
+	p.mu.Lock()
+	u, ok := p.usage[key]
+	if !ok || !now.Before(u.Since.Add(p.window)) {
+		u = &Usage{Since: now}
+		p.usage[key] = u
+	}
+
+	u.Requests++
+	u.Bytes += bytes
+	u.RenderTime += elapsed
+
+	exceeded := !u.Exceeded && p.limit(key).Exceeds(*u)
+	if exceeded {
+		u.Exceeded = true
+	}
+	usage := *u
+	p.mu.Unlock()
+
+	if exceeded {
+		p.log.Warn("Usage limit exceeded",
+			slog.String("key", key),
+			slog.Int64("bytes", usage.Bytes),
+			slog.Duration("render_time", usage.RenderTime))
+
+		if p.onExceeded != nil {
+			p.onExceeded(key, usage)
+		}
+	}
+}
+
+func (p *p) limit(key string) Limit {
+	if l, ok := p.limits[key]; ok {
+		return l
+	}
+	return p.defaultLimit
+}
+
+func (p *p) Usage() map[string]Usage {
+	now := p.now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	usage := make(map[string]Usage, len(p.usage))
+	for k, u := range p.usage {
+		if !now.Before(u.Since.Add(p.window)) {
+			// Expired windows are removed, so keys of arbitrary hosts or paths
+			// don't grow the map.
+			delete(p.usage, k)
+			continue
+		}
+		usage[k] = *u
+	}
+	return usage
+}
+
+func (p *p) Exceeded(key string) bool {
+	u, ok := p.current(key)
+	return ok && u.Exceeded
+}
+
+// Implements [plugin.Instrumented], with the requests, bytes and render time in
+// milliseconds of each key.
+func (p *p) Metrics() map[string]int64 {
+	usage := p.Usage()
+
+	m := make(map[string]int64, len(usage)*3)
+	for k, u := range usage {
+		m[k+".requests"] = u.Requests
+		m[k+".bytes"] = u.Bytes
+		m[k+".render_ms"] = u.RenderTime.Milliseconds()
+	}
+	return m
+}
+
+func (p *p) serveUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(p.Usage())
+}
+
+// [http.ResponseWriter] that counts the bytes of the body.
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *countingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
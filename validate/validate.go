@@ -0,0 +1,517 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package validate provides content validation rules, such as required frontmatter
+// fields, date formats, unique URLs and existing image references, which can be
+// run every time the files are sourced with [New], as a [snapshot.Validator] (see
+// [Rules]), or directly on a build with [Validate].
+//
+// Results are reported as a [Report], which is logged by the sourcer, passed to
+// Opts.OnReport so applications can record metrics, served by [NewHandler] for
+// debugging, and can be turned into a exit code of build commands with
+// [(Report).ExitCode].
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/blogo/contenttype"
+	"forge.capytal.company/loreddev/blogo/index"
+	"forge.capytal.company/loreddev/blogo/metadata"
+	"forge.capytal.company/loreddev/blogo/plugin"
+	"forge.capytal.company/loreddev/blogo/plugins/frontmatter"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+const pluginName = "blogo-validate-sourcer"
+
+// Severity of a [Issue].
+type Severity string
+
+const (
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// A problem found on a file by a [Rule].
+type Issue struct {
+	// Path of the file on the file system.
+	Path     string   `json:"path"`
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s (%s %s)", i.Path, i.Message, i.Rule, i.Severity)
+}
+
+// A validation rule, which checks the entries of the index.
+type Rule interface {
+	Name() string
+	Check(idx index.Index) []Issue
+}
+
+type rule struct {
+	name  string
+	check func(idx index.Index) []Issue
+}
+
+func (r rule) Name() string {
+	return r.name
+}
+
+func (r rule) Check(idx index.Index) []Issue {
+	return r.check(idx)
+}
+
+// Creates a [Rule] from a function, setting the name and [SeverityError] on all
+// issues that don't have them.
+func NewRule(name string, check func(idx index.Index) []Issue) Rule {
+	return rule{name: name, check: func(idx index.Index) []Issue {
+		issues := check(idx)
+		for i := range issues {
+			if issues[i].Rule == "" {
+				issues[i].Rule = name
+			}
+			if issues[i].Severity == "" {
+				issues[i].Severity = SeverityError
+			}
+		}
+		return issues
+	}}
+}
+
+// Wraps the rule, reporting all of it's issues as [SeverityWarning], so they
+// don't fail validation.
+func AsWarning(r Rule) Rule {
+	return rule{name: r.Name(), check: func(idx index.Index) []Issue {
+		issues := r.Check(idx)
+		for i := range issues {
+			issues[i].Severity = SeverityWarning
+		}
+		return issues
+	}}
+}
+
+// Creates a [Rule] that fails for entries that don't have all the metadata fields,
+// or have them empty.
+func RequiredFields(fields ...string) Rule {
+	return NewRule("required-fields", func(idx index.Index) []Issue {
+		issues := []Issue{}
+		for _, e := range idx.Entries() {
+			for _, f := range fields {
+				v, err := metadata.Get(e.Metadata, f)
+				if err != nil || v == nil || v == "" {
+					issues = append(issues, Issue{
+						Path:    e.Path,
+						Message: fmt.Sprintf("missing required field %q", f),
+					})
+				}
+			}
+		}
+		return issues
+	})
+}
+
+// Creates a [Rule] that fails for entries that don't follow the schema of their
+// content type (see [contenttype.Schema]), such as missing required fields,
+// values of the wrong kind or outside of the enum of the field.
+func Schemas(r contenttype.Registry) Rule {
+	return NewRule("schema", func(idx index.Index) []Issue {
+		issues := []Issue{}
+		for _, e := range idx.Entries() {
+			t, ok := r.Detect(e.Path, e.Metadata)
+			if !ok || t.Schema == nil {
+				continue
+			}
+			for _, err := range t.Schema.Check(e.Metadata) {
+				issues = append(issues, Issue{
+					Path:    e.Path,
+					Message: fmt.Sprintf("%s (type %q)", err.Message, t.Name),
+				})
+			}
+		}
+		return issues
+	})
+}
+
+// Creates a [Rule] that fails for entries which metadata fields (defaulting
+// to "date" and "updated") are set but aren't dates in one of the
+// [metadata.TimeLayouts].
+func DateFormat(fields ...string) Rule {
+	if len(fields) == 0 {
+		fields = []string{"date", "updated"}
+	}
+	return NewRule("date-format", func(idx index.Index) []Issue {
+		issues := []Issue{}
+		for _, e := range idx.Entries() {
+			for _, f := range fields {
+				v, err := metadata.Get(e.Metadata, f)
+				if err != nil || v == nil {
+					continue
+				}
+				if _, err := metadata.GetTime(e.Metadata, f); err != nil {
+					issues = append(issues, Issue{
+						Path:    e.Path,
+						Message: fmt.Sprintf("field %q is not a valid date: %v", f, v),
+					})
+				}
+			}
+		}
+		return issues
+	})
+}
+
+// Creates a [Rule] that fails for entries that have the same URL as another,
+// from their "permalink" metadata or their path (see [index.Collision]).
+func UniqueURLs() Rule {
+	return NewRule("unique-urls", func(idx index.Index) []Issue {
+		issues := []Issue{}
+		for _, c := range idx.Collisions() {
+			for _, p := range c.Paths {
+				issues = append(issues, Issue{Path: p, Message: c.Error()})
+			}
+		}
+		return issues
+	})
+}
+
+var (
+	markdownImage = regexp.MustCompile(`!\[[^\]]*\]\(\s*<?([^)\s>]+)`)
+	htmlImage     = regexp.MustCompile(`(?i)<img\s[^>]*src\s*=\s*["']([^"']+)["']`)
+)
+
+// Creates a [Rule] that fails for entries that reference images, in Markdown
+// or HTML syntax, which don't exist on the file system. Relative references are
+// resolved from the directory of the entry and absolute ones from the root of
+// the file system, while external URLs are ignored.
+func ImageReferences() Rule {
+	return NewRule("image-references", func(idx index.Index) []Issue {
+		issues := []Issue{}
+		for _, e := range idx.Entries() {
+			contents, err := fs.ReadFile(idx.FS(), e.Path)
+			if err != nil {
+				issues = append(issues, Issue{Path: e.Path, Message: "failed to read file: " + err.Error()})
+				continue
+			}
+
+			body := frontmatter.Body(contents)
+			refs := append(markdownImage.FindAllSubmatch(body, -1), htmlImage.FindAllSubmatch(body, -1)...)
+
+			for _, ref := range refs {
+				src := string(ref[1])
+				p, ok := resolve(e.Path, src)
+				if !ok {
+					continue
+				}
+				if _, err := fs.Stat(idx.FS(), p); err != nil {
+					issues = append(issues, Issue{
+						Path:    e.Path,
+						Message: fmt.Sprintf("referenced image %q does not exist", src),
+					})
+				}
+			}
+		}
+		return issues
+	})
+}
+
+// Resolves the reference of a file to a path on the file system, returning false
+// if it is a external URL.
+func resolve(file, ref string) (string, bool) {
+	if strings.Contains(ref, ":") || strings.HasPrefix(ref, "//") || strings.HasPrefix(ref, "#") {
+		return "", false
+	}
+
+	ref, _, _ = strings.Cut(ref, "?")
+	ref, _, _ = strings.Cut(ref, "#")
+
+	if strings.HasPrefix(ref, "/") {
+		return path.Clean(strings.TrimPrefix(ref, "/")), true
+	}
+	return path.Join(path.Dir(file), ref), true
+}
+
+// The default rules: [RequiredFields] "title", [DateFormat], [UniqueURLs] and
+// [ImageReferences].
+func DefaultRules() Rules {
+	return Rules{RequiredFields("title"), DateFormat(), UniqueURLs(), ImageReferences()}
+}
+
+// A list of rules, which implements [snapshot.Validator] so file systems that
+// fail validation aren't swapped in.
+type Rules []Rule
+
+// Builds the index of the file system and checks it with the rules, returning
+// [(Report).Err].
+func (rs Rules) Validate(fsys fs.FS) error {
+	return Validate(fsys, rs...).Err()
+}
+
+// Results of a validation.
+type Report struct {
+	Time   time.Time `json:"time"`
+	Issues []Issue   `json:"issues"`
+	// Number of issues of each rule.
+	Counts map[string]int `json:"counts"`
+	// Number of issues with [SeverityError].
+	Errors int `json:"errors"`
+	// Number of issues with [SeverityWarning].
+	Warnings int `json:"warnings"`
+}
+
+// Returns a error with all issues of [SeverityError], or nil if there isn't any.
+func (r Report) Err() error {
+	errs := []error{}
+	for _, i := range r.Issues {
+		if i.Severity == SeverityError {
+			errs = append(errs, errors.New(i.String()))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.Join(
+		fmt.Errorf("content validation failed with %d errors", len(errs)),
+		errors.Join(errs...),
+	)
+}
+
+// Returns the exit code of build commands, 1 if the report has any error and
+// 0 otherwise, so CI can fail on invalid content:
+//
+//	report := validate.Validate(fsys, validate.DefaultRules()...)
+//	os.Exit(report.ExitCode())
+func (r Report) ExitCode() int {
+	if r.Errors > 0 {
+		return 1
+	}
+	return 0
+}
+
+// Builds the index of the file system and checks it with the rules. Rules
+// defaults to [DefaultRules]. Metadata of files is taken from the file system,
+// so it should provide it, for example by using the frontmatter plugin.
+func Validate(fsys fs.FS, rules ...Rule) Report {
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+
+	issues := []Issue{}
+
+	idx, err := index.Build(fsys)
+	if err != nil {
+		issues = append(issues, Issue{
+			Path:     ".",
+			Rule:     "index",
+			Severity: SeverityError,
+			Message:  "failed to build complete index: " + err.Error(),
+		})
+	}
+
+	for _, rule := range rules {
+		issues = append(issues, rule.Check(idx)...)
+	}
+
+	return NewReport(issues)
+}
+
+// Creates a [Report] of the issues, sorted by path and counted by rule and
+// severity, so checks outside of this package (such as the a11y package) can
+// report their results the same way.
+func NewReport(issues []Issue) Report {
+	r := Report{Time: time.Now(), Issues: issues, Counts: map[string]int{}}
+	if r.Issues == nil {
+		r.Issues = []Issue{}
+	}
+
+	slices.SortStableFunc(r.Issues, func(a, b Issue) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	for _, i := range r.Issues {
+		r.Counts[i.Rule]++
+		switch i.Severity {
+		case SeverityError:
+			r.Errors++
+		case SeverityWarning:
+			r.Warnings++
+		}
+	}
+
+	return r
+}
+
+// A [plugin.Sourcer] that validates the files every time they are sourced.
+type Validator interface {
+	plugin.Sourcer
+	// Returns the report of the last validation, false if the files weren't
+	// sourced yet.
+	Report() (Report, bool)
+}
+
+// Creates a [Validator] that wraps the sourcer, validating the file system every
+// time it is sourced and logging the issues found. The file system is returned
+// even if it fails validation, unless Opts.Strict is set.
+func New(sourcer plugin.Sourcer, opts ...Opts) Validator {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Rules == nil {
+		opt.Rules = DefaultRules()
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	injectLogger := opt.Logger == nil
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(sourcer, "Sourcer to be wrapped should not be nil")
+
+	return &validator{
+		sourcer:  sourcer,
+		rules:    opt.Rules,
+		strict:   opt.Strict,
+		onReport: opt.OnReport,
+
+		injectLogger: injectLogger,
+
+		assert: opt.Assertions,
+		log:    opt.Logger,
+	}
+}
+
+type Opts struct {
+	// Rules checked on every source. Defaults to [DefaultRules].
+	Rules Rules
+	// Returns the errors of the report from Source, instead of just logging them.
+	Strict bool
+	// Called with the report of every validation, for example to record metrics.
+	OnReport func(Report)
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+type validator struct {
+	sourcer  plugin.Sourcer
+	rules    Rules
+	strict   bool
+	onReport func(Report)
+
+	mu     sync.RWMutex
+	report *Report
+
+	injectLogger bool
+
+	assert tinyssert.Assertions
+	log    *slog.Logger
+}
+
+func (v *validator) Name() string {
+	return pluginName
+}
+
+// Implements [plugin.LoggerAware], using the logger of the engine if no logger
+// was provided on construction.
+func (v *validator) SetLogger(logger *slog.Logger) {
+	if v.injectLogger {
+		v.log = logger
+	}
+}
+
+func (v *validator) Source() (fs.FS, error) {
+	v.assert.NotNil(v.sourcer)
+	v.assert.NotNil(v.log)
+
+	fsys, err := v.sourcer.Source()
+	if err != nil {
+		return fsys, err
+	}
+
+	log := v.log.With(slog.String("sourcer", v.sourcer.Name()))
+	log.Debug("Validating files")
+
+	r := Validate(fsys, v.rules...)
+
+	for _, i := range r.Issues {
+		l := log.Warn
+		if i.Severity == SeverityError {
+			l = log.Error
+		}
+		l("Content validation issue",
+			slog.String("file", i.Path),
+			slog.String("rule", i.Rule),
+			slog.String("err", i.Message))
+	}
+	log.Debug("Files validated", slog.Int("errors", r.Errors), slog.Int("warnings", r.Warnings))
+
+	v.mu.Lock()
+	v.report = &r
+	v.mu.Unlock()
+
+	if v.onReport != nil {
+		v.onReport(r)
+	}
+
+	if err := r.Err(); err != nil && v.strict {
+		return nil, err
+	}
+
+	return fsys, nil
+}
+
+func (v *validator) Report() (Report, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.report == nil {
+		return Report{}, false
+	}
+	return *v.report, true
+}
+
+// Creates a [http.Handler] that responds with the last report of the validator
+// as JSON, for debugging, with "503 Service Unavailable" if the files weren't
+// validated yet.
+func NewHandler(v Validator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report, ok := v.Report()
+		if !ok {
+			http.Error(w, "Files not validated yet", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
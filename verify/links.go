@@ -0,0 +1,326 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verify
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"forge.capytal.company/loreddev/blogo/validate"
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+// Names of the rules of the issues reported by [Links].
+const (
+	BrokenLinkRule   = "broken-link"
+	BrokenAnchorRule = "broken-anchor"
+)
+
+// Max number of redirects followed by [Links] for each link.
+const maxRedirects = 10
+
+var (
+	linkIgnored = regexp.MustCompile(`(?is)<!--.*?-->|<(script|style|template)\b[^>]*>.*?</(?:script|style|template)\s*>`)
+	linkTag     = regexp.MustCompile(`(?s)<([a-zA-Z][a-zA-Z0-9-]*)\b([^>]*)>`)
+	linkAttr    = regexp.MustCompile(`(?s)\s+([a-zA-Z_:][a-zA-Z0-9_:.-]*)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+))?`)
+)
+
+// Requests the sitemap from the handler, and then every URL in it, checking the
+// links of the pages which are HTML. Links to other pages of the site are
+// requested (following redirects), and are reported as [BrokenLinkRule] if
+// they don't respond with "200 OK". Links with fragments, on the same page or
+// on other pages, are reported as [BrokenAnchorRule] if the page doesn't have
+// an element with the fragment as it's "id" (or a "name" on anchors).
+//
+// Issues have the path of the page with the link and [validate.SeverityError],
+// so the report fails builds, see [validate.Report.ExitCode]. External links
+// aren't checked.
+func Links(ctx context.Context, h http.Handler, opts ...Opts) validate.Report {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Sitemap == "" {
+		opt.Sitemap = DefaultSitemap
+	}
+	if opt.Concurrency <= 0 {
+		opt.Concurrency = 4
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(h, "Handler to be checked should not be nil")
+
+	log := opt.Logger.With(slog.String("sitemap", opt.Sitemap))
+	log.Debug("Checking links of sitemap")
+
+	urls, err := SitemapURLs(ctx, h, opt.Sitemap, opt.Host)
+	if err != nil {
+		log.Error("Failed to read sitemap", slog.String("err", err.Error()))
+		return validate.NewReport([]validate.Issue{{
+			Path:     opt.Sitemap,
+			Rule:     "sitemap",
+			Severity: validate.SeverityError,
+			Message:  "failed to read sitemap: " + err.Error(),
+		}})
+	}
+
+	c := &linkChecker{ctx: ctx, handler: h, host: opt.Host, pages: map[string]*linkedPage{}}
+
+	issues := make([][]validate.Issue, len(urls))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, opt.Concurrency)
+
+	for i, u := range urls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			issues[i] = c.check(u)
+		}()
+	}
+	wg.Wait()
+
+	all := []validate.Issue{}
+	for _, is := range issues {
+		all = append(all, is...)
+	}
+
+	report := validate.NewReport(all)
+
+	for _, i := range report.Issues {
+		log.Error("Broken link",
+			slog.String("page", i.Path),
+			slog.String("rule", i.Rule),
+			slog.String("err", i.Message))
+	}
+	log.Info("Links checked", slog.Int("pages", len(urls)), slog.Int("issues", len(report.Issues)))
+
+	return report
+}
+
+// A page requested by the link checker.
+type linkedPage struct {
+	status   int
+	location string
+	err      error
+
+	html bool
+	// Values of the "id" attributes of the page, and "name" of anchors.
+	ids   map[string]bool
+	links []string
+}
+
+type linkChecker struct {
+	ctx     context.Context
+	handler http.Handler
+	host    string
+
+	mu    sync.Mutex
+	pages map[string]*linkedPage
+}
+
+// Checks the links of the page of the URL, returning their issues.
+func (c *linkChecker) check(u string) []validate.Issue {
+	issues := []validate.Issue{}
+
+	base, err := url.Parse(u)
+	if err != nil {
+		return issues
+	}
+
+	p := c.get(base)
+	if p.status != http.StatusOK || !p.html {
+		// Pages which aren't OK are reported by Run.
+		return issues
+	}
+
+	checked := map[string]bool{}
+
+	for _, l := range p.links {
+		ref, err := url.Parse(strings.TrimSpace(l))
+		if err != nil || checked[l] {
+			continue
+		}
+		checked[l] = true
+
+		target := base.ResolveReference(ref)
+		if !c.internal(base, target) {
+			continue
+		}
+
+		issue := validate.Issue{Path: base.Path, Severity: validate.SeverityError}
+
+		tp, final := p, target
+		if ref.Path != "" || ref.RawQuery != "" || ref.Host != "" {
+			tp, final = c.follow(target)
+		}
+
+		switch {
+		case tp.err != nil:
+			issue.Rule = BrokenLinkRule
+			issue.Message = fmt.Sprintf("link %q is broken: %s", l, tp.err.Error())
+		case tp.status != http.StatusOK:
+			issue.Rule = BrokenLinkRule
+			issue.Message = fmt.Sprintf("link %q responded with status %d", l, tp.status)
+		case target.Fragment == "" || target.Fragment == "top" || !tp.html:
+			continue
+		case !tp.ids[target.Fragment]:
+			issue.Rule = BrokenAnchorRule
+			if tp == p {
+				issue.Message = fmt.Sprintf("anchor %q does not exist on the page", "#"+target.Fragment)
+			} else {
+				issue.Message = fmt.Sprintf("anchor %q does not exist on %q", "#"+target.Fragment, final.Path)
+			}
+		default:
+			continue
+		}
+
+		issues = append(issues, issue)
+	}
+
+	return issues
+}
+
+// Reports if the target is a page of the site, with the host of the base or
+// Opts.Host.
+func (c *linkChecker) internal(base, target *url.URL) bool {
+	if target.Scheme != "" && target.Scheme != "http" && target.Scheme != "https" {
+		return false
+	}
+	return target.Host == "" || target.Host == base.Host || (c.host != "" && target.Host == c.host)
+}
+
+// Requests the target, following redirects to other pages of the site.
+func (c *linkChecker) follow(target *url.URL) (*linkedPage, *url.URL) {
+	p := c.get(target)
+	for range maxRedirects {
+		if p.status < 300 || p.status >= 400 || p.location == "" {
+			return p, target
+		}
+
+		loc, err := url.Parse(p.location)
+		if err != nil {
+			return &linkedPage{err: fmt.Errorf("invalid redirect to %q", p.location)}, target
+		}
+		next := target.ResolveReference(loc)
+		if !c.internal(target, next) {
+			// Redirects out of the site are assumed to be fine.
+			return &linkedPage{status: http.StatusOK}, next
+		}
+		if next.Fragment == "" {
+			next.Fragment = target.Fragment
+		}
+
+		target = next
+		p = c.get(target)
+	}
+	return &linkedPage{err: fmt.Errorf("more than %d redirects", maxRedirects)}, target
+}
+
+// Requests the page of the URL, or returns it if it was already requested.
+func (c *linkChecker) get(u *url.URL) *linkedPage {
+	key := u.Host + u.RequestURI()
+
+	c.mu.Lock()
+	p, ok := c.pages[key]
+	c.mu.Unlock()
+	if ok {
+		return p
+	}
+
+	p = c.request(u)
+
+	c.mu.Lock()
+	c.pages[key] = p
+	c.mu.Unlock()
+
+	return p
+}
+
+func (c *linkChecker) request(u *url.URL) (p *linkedPage) {
+	p = &linkedPage{}
+
+	req, err := newRequest(c.ctx, u.String(), c.host)
+	if err != nil {
+		p.err = err
+		return p
+	}
+
+	defer func() {
+		if v := recover(); v != nil {
+			p = &linkedPage{err: fmt.Errorf("panic: %v", v)}
+		}
+	}()
+
+	w := httptest.NewRecorder()
+	c.handler.ServeHTTP(w, req)
+
+	p.status = w.Code
+	p.location = w.Header().Get("Location")
+
+	if t, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type")); t == "text/html" {
+		p.html = true
+		p.ids, p.links = parseLinks(w.Body.Bytes())
+	}
+
+	return p
+}
+
+// Returns the IDs and the links of the page.
+func parseLinks(page []byte) (map[string]bool, []string) {
+	ids := map[string]bool{}
+	links := []string{}
+
+	page = linkIgnored.ReplaceAll(page, nil)
+
+	for _, m := range linkTag.FindAllSubmatch(page, -1) {
+		name := strings.ToLower(string(m[1]))
+		for _, a := range linkAttr.FindAllSubmatch(m[2], -1) {
+			key := strings.ToLower(string(a[1]))
+			value := html.UnescapeString(strings.Trim(string(a[2]), `"'`))
+
+			switch {
+			case key == "id", key == "name" && name == "a":
+				if value != "" {
+					ids[value] = true
+				}
+			case key == "href" && (name == "a" || name == "area"):
+				links = append(links, value)
+			}
+		}
+	}
+
+	return ids, links
+}
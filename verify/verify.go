@@ -0,0 +1,299 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package verify provides a smoke test of a blog, which requests every URL of
+// it's sitemap against the [http.Handler] in-process, reporting the responses
+// that aren't "200 OK" and the renders that panic. It is meant to be a fast
+// sanity check after deploys, for example as the "verify" command of the
+// application:
+//
+//	if os.Args[1] == "verify" {
+//		report := verify.Run(ctx, blog, verify.Opts{Logger: logger})
+//		os.Exit(report.ExitCode())
+//	}
+//
+// [Links] checks the links of the rendered pages, reporting internal links to
+// pages that don't exist and links to anchors (such as "#section-id") that
+// aren't on their page, since heading slug changes silently break deep links.
+package verify
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"forge.capytal.company/loreddev/x/tinyssert"
+)
+
+// Path of the sitemap requested by [Run] if Opts.Sitemap is empty.
+const DefaultSitemap = "/sitemap.xml"
+
+// Result of the request of a URL.
+type Result struct {
+	URL     string        `json:"url"`
+	Status  int           `json:"status"`
+	Elapsed time.Duration `json:"elapsed"`
+	// Value recovered if the handler panicked, with the stack trace in Stack.
+	Panic any    `json:"panic,omitempty"`
+	Stack string `json:"stack,omitempty"`
+	// Error which prevented the request, such as a invalid URL.
+	Err error `json:"-"`
+}
+
+// Reports if the URL was served with "200 OK" and without panicking.
+func (r Result) OK() bool {
+	return r.Err == nil && r.Panic == nil && r.Status == http.StatusOK
+}
+
+func (r Result) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("%s: %s", r.URL, r.Err.Error())
+	}
+	if r.Panic != nil {
+		return fmt.Sprintf("%s: panic: %v", r.URL, r.Panic)
+	}
+	return fmt.Sprintf("%s: %d %s (%s)", r.URL, r.Status, http.StatusText(r.Status), r.Elapsed)
+}
+
+// Results of a verification.
+type Report struct {
+	// Results of all requested URLs, in the order of the sitemap.
+	Results []Result `json:"results"`
+	// Results which aren't OK.
+	Failures []Result `json:"failures"`
+	// Error which prevented the verification, such as a invalid sitemap.
+	Err error `json:"-"`
+}
+
+// Returns the exit code of verify commands, 1 if the verification failed or any
+// URL isn't OK, and 0 otherwise.
+func (r Report) ExitCode() int {
+	if r.Err != nil || len(r.Failures) > 0 {
+		return 1
+	}
+	return 0
+}
+
+type Opts struct {
+	// Path of the sitemap on the handler. Defaults to [DefaultSitemap]. Sitemap
+	// indexes are followed.
+	Sitemap string
+	// Number of URLs requested concurrently. Defaults to 4.
+	Concurrency int
+	// Host set on the requests, also used to make absolute URLs of the sitemap
+	// relative to the handler. Defaults to the host of the sitemap URLs.
+	Host string
+
+	Assertions tinyssert.Assertions
+	Logger     *slog.Logger
+}
+
+// Requests the sitemap from the handler, and then every URL in it, returning
+// the [Report] of the verification. Failures are logged as errors.
+func Run(ctx context.Context, h http.Handler, opts ...Opts) Report {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Sitemap == "" {
+		opt.Sitemap = DefaultSitemap
+	}
+	if opt.Concurrency <= 0 {
+		opt.Concurrency = 4
+	}
+
+	if opt.Assertions == nil {
+		opt.Assertions = tinyssert.NewDisabledAssertions()
+	}
+	if opt.Logger == nil {
+		opt.Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	}
+
+	opt.Assertions.NotNil(h, "Handler to be verified should not be nil")
+
+	log := opt.Logger.With(slog.String("sitemap", opt.Sitemap))
+	log.Debug("Verifying sitemap")
+
+	urls, err := sitemapURLs(ctx, h, opt.Sitemap, opt.Host, map[string]bool{})
+	if err != nil {
+		log.Error("Failed to read sitemap", slog.String("err", err.Error()))
+		return Report{Results: []Result{}, Failures: []Result{}, Err: err}
+	}
+
+	report := URLs(ctx, h, urls, opt)
+
+	for _, r := range report.Failures {
+		log.Error("URL failed verification",
+			slog.String("url", r.URL), slog.Int("status", r.Status), slog.Any("panic", r.Panic))
+	}
+	log.Info("Sitemap verified",
+		slog.Int("urls", len(report.Results)), slog.Int("failures", len(report.Failures)))
+
+	return report
+}
+
+// Requests every URL against the handler, returning the [Report] of the
+// verification. Only Opts.Concurrency and Opts.Host are used.
+func URLs(ctx context.Context, h http.Handler, urls []string, opts ...Opts) Report {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Concurrency <= 0 {
+		opt.Concurrency = 4
+	}
+
+	results := make([]Result, len(urls))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, opt.Concurrency)
+
+	for i, u := range urls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = request(ctx, h, u, opt.Host)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Results: results, Failures: []Result{}}
+	for _, r := range results {
+		if !r.OK() {
+			report.Failures = append(report.Failures, r)
+		}
+	}
+
+	return report
+}
+
+func request(ctx context.Context, h http.Handler, u string, host string) (result Result) {
+	result = Result{URL: u}
+
+	req, err := newRequest(ctx, u, host)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	w := httptest.NewRecorder()
+	start := time.Now()
+
+	defer func() {
+		result.Elapsed = time.Since(start)
+		if v := recover(); v != nil {
+			result.Panic = v
+			result.Stack = string(debug.Stack())
+		}
+	}()
+
+	h.ServeHTTP(w, req)
+	result.Status = w.Code
+
+	return result
+}
+
+func newRequest(ctx context.Context, u string, host string) (*http.Request, error) {
+	req := httptest.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+
+	pu, err := url.Parse(u)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("invalid URL %q", u), err)
+	}
+
+	req.URL.Path = pu.Path
+	req.URL.RawQuery = pu.RawQuery
+	req.RequestURI = pu.RequestURI()
+
+	switch {
+	case host != "":
+		req.Host = host
+	case pu.Host != "":
+		req.Host = pu.Host
+	}
+
+	return req, nil
+}
+
+// Requests the sitemap from the handler, returning it's URLs and the ones of the
+// sitemaps it indexes, in order and without duplicates. The host is set on the
+// requests, see Opts.Host.
+func SitemapURLs(ctx context.Context, h http.Handler, sitemap string, host string) ([]string, error) {
+	return sitemapURLs(ctx, h, sitemap, host, map[string]bool{})
+}
+
+type sitemap struct {
+	XMLName xml.Name
+	URLs    []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+func sitemapURLs(ctx context.Context, h http.Handler, u string, host string, seen map[string]bool) ([]string, error) {
+	if seen[u] {
+		return []string{}, nil
+	}
+	seen[u] = true
+
+	req, err := newRequest(ctx, u, host)
+	if err != nil {
+		return nil, err
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		return nil, fmt.Errorf("sitemap %q responded with status %d", u, w.Code)
+	}
+
+	var s sitemap
+	if err := xml.NewDecoder(w.Body).Decode(&s); err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to decode sitemap %q", u), err)
+	}
+
+	urls := []string{}
+	for _, l := range s.URLs {
+		if loc := strings.TrimSpace(l.Loc); loc != "" && !slices.Contains(urls, loc) {
+			urls = append(urls, loc)
+		}
+	}
+	for _, l := range s.Sitemaps {
+		us, err := sitemapURLs(ctx, h, strings.TrimSpace(l.Loc), host, seen)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, us...)
+	}
+
+	return urls, nil
+}
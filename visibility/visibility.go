@@ -0,0 +1,185 @@
+// Copyright 2025-present Gustavo "Guz" L. de Mello
+// Copyright 2025-present The Lored.dev Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package visibility provides a central rules engine that decides which files
+// are visible on each subsystem of the blog, such as feeds, search engines and
+// listings.
+//
+// Plugins should accept [Rules] on their options, defaulting to [Default], and
+// consult it with their [Target], so exclusion logic is defined once and is
+// consistent across the blog.
+package visibility
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"forge.capytal.company/loreddev/blogo/metadata"
+)
+
+// A subsystem that lists files.
+type Target string
+
+const (
+	Feed    Target = "feed"
+	Search  Target = "search"
+	Listing Target = "listing"
+)
+
+// All the built-in targets.
+var Targets = []Target{Feed, Search, Listing}
+
+// Metadata key of a list of targets that the file should be excluded from, or
+// a boolean to exclude it from all of them, for example "exclude: [search, feed]".
+const ExcludeKey = "exclude"
+
+// Decides if files are visible on targets.
+type Rules interface {
+	// Reports if the file at the path, with the metadata, is visible on the target.
+	Visible(path string, m metadata.Metadata, t Target) bool
+}
+
+// Type adapter to allow the use of ordinary functions as [Rules] implementations.
+type Func func(path string, m metadata.Metadata, t Target) bool
+
+func (f Func) Visible(path string, m metadata.Metadata, t Target) bool {
+	return f(path, m, t)
+}
+
+// A rule that applies to the files matching a pattern.
+type Rule struct {
+	// Glob pattern of the path of the files, as in [path.Match], where "**"
+	// also matches any number of directories, for example "drafts/**".
+	Pattern string
+	// Targets that the rule applies to. Applies to all targets if empty.
+	Targets []Target
+	// Shows the matched files instead of hiding it, to create exceptions to
+	// previous rules.
+	Show bool
+}
+
+// Boolean metadata flags that hide files on the targets when true, used if
+// Opts.Flags is nil.
+//
+// Rules only decide where files are listed, not if they are served, so
+// "unlisted: true" posts are still available at their URL to anyone that has
+// the link, but are hidden from every target.
+var DefaultFlags = map[string][]Target{
+	"draft":    Targets,
+	"unlisted": Targets,
+	"noindex":  {Search},
+	"nofeed":   {Feed},
+}
+
+// The default [Rules], which only uses [DefaultFlags] and [ExcludeKey].
+var Default Rules = New()
+
+// Creates [Rules] that hide files if any of the metadata flags are true, if their
+// [ExcludeKey] includes the target, or if the last path [Rule] matching it, if
+// any, hides it.
+func New(opts ...Opts) Rules {
+	opt := Opts{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Flags == nil {
+		opt.Flags = DefaultFlags
+	}
+
+	return &rules{rules: opt.Rules, flags: opt.Flags}
+}
+
+type Opts struct {
+	Rules []Rule
+	// Boolean metadata keys that hide files on the targets. Defaults to [DefaultFlags].
+	Flags map[string][]Target
+}
+
+type rules struct {
+	rules []Rule
+	flags map[string][]Target
+}
+
+func (r *rules) Visible(p string, m metadata.Metadata, t Target) bool {
+	p = strings.TrimPrefix(p, "/")
+
+	visible := true
+	for _, rule := range r.rules {
+		if len(rule.Targets) > 0 && !slices.Contains(rule.Targets, t) {
+			continue
+		}
+		if Match(rule.Pattern, p) {
+			visible = rule.Show
+		}
+	}
+	if !visible {
+		return false
+	}
+
+	if m == nil {
+		return true
+	}
+
+	for flag, targets := range r.flags {
+		if v, err := metadata.GetTyped[bool](m, flag); err == nil && v && slices.Contains(targets, t) {
+			return false
+		}
+	}
+
+	if v, err := metadata.GetTyped[bool](m, ExcludeKey); err == nil && v {
+		return false
+	}
+	if v, err := metadata.GetTyped[[]any](m, ExcludeKey); err == nil {
+		for _, e := range v {
+			if s, ok := e.(string); ok && Target(s) == t {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Reports if the path matches the pattern, with the same syntax as [path.Match]
+// plus "**" segments, which match any number of path segments.
+func Match(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}